@@ -91,7 +91,9 @@ package client // import "go.opentelemetry.io/collector/client"
 import (
 	"context"
 	"net"
+	"sort"
 	"strings"
+	"unicode/utf8"
 )
 
 type ctxKey struct{}
@@ -111,9 +113,16 @@ type Info struct {
 	// Metadata is the request metadata from the client connecting to this connector.
 	// Experimental: *NOTE* this structure is subject to change or removal in the future.
 	Metadata Metadata
+
+	// Source is the ID of the component, typically a receiver, that
+	// received the request from the client, e.g. "otlp/internal".  It is
+	// empty when unknown.
+	// Experimental: *NOTE* this field is subject to change or removal in the future.
+	Source string
 }
 
-// Metadata is an immutable map, meant to contain request metadata.
+// Metadata is an immutable map, meant to contain request metadata.  Its
+// keys are case-insensitive, and kept in lower case.
 type Metadata struct {
 	data map[string][]string
 }
@@ -150,34 +159,203 @@ func FromContext(ctx context.Context) Info {
 	return c
 }
 
-// NewMetadata creates a new Metadata object to use in Info. md is used as-is.
+// Merge returns info completed with other, for components adding client
+// information to that of a context without rebuilding it whole.  The
+// fields set in info are kept, the others take the value of other, and
+// the metadata of both is merged key by key, the values of info winning
+// for the keys present in both.
+func (info Info) Merge(other Info) Info {
+	if info.Addr == nil {
+		info.Addr = other.Addr
+	}
+	if info.Auth == nil {
+		info.Auth = other.Auth
+	}
+	if info.Source == "" {
+		info.Source = other.Source
+	}
+	info.Metadata = info.Metadata.merge(other.Metadata)
+	return info
+}
+
+// NewMetadata creates a new Metadata object to use in Info.  The keys are
+// canonicalized to lower case, the values of the keys differing only by
+// case being merged in the order of the keys sorted.  md is copied, keys
+// and values, so that changing it afterwards does not affect the
+// metadata.
 func NewMetadata(md map[string][]string) Metadata {
-	return Metadata{
-		data: md,
+	var b MetadataBuilder
+	canonical := true
+	for k, v := range md {
+		if strings.ToLower(k) != k {
+			canonical = false
+			break
+		}
+		b.Set(k, v)
 	}
+	if canonical {
+		return b.Build()
+	}
+	b = MetadataBuilder{}
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.Add(k, md[k]...)
+	}
+	return b.Build()
 }
 
-// Get gets the value of the key from metadata, returning a copy.
-func (m Metadata) Get(key string) []string {
-	vals := m.data[key]
-	if len(vals) == 0 {
-		// we didn't find the key, but perhaps it just has different cases?
-		for k, v := range m.data {
-			if strings.EqualFold(key, k) {
-				vals = v
-				// we optimize for the next lookup
-				m.data[key] = v
+// MetadataTruncatedSuffix ends the values cut by TruncateMetadata.
+const MetadataTruncatedSuffix = "..."
+
+// TruncateMetadata returns md with at most maxValues values per key, the
+// first ones, and its values longer than maxValueLen bytes cut to that
+// length, on a character boundary, and followed by
+// MetadataTruncatedSuffix.  It also returns the number of values cut or
+// dropped.  A zero limit is not enforced, and md is returned as-is when
+// within the limits.
+func TruncateMetadata(md Metadata, maxValueLen, maxValues int) (Metadata, int) {
+	within := true
+	for _, vals := range md.data {
+		if !valuesWithin(vals, maxValueLen, maxValues) {
+			within = false
+			break
+		}
+	}
+	if within {
+		return md, 0
+	}
+	truncated := 0
+	data := make(map[string][]string, len(md.data))
+	for k, vals := range md.data {
+		if valuesWithin(vals, maxValueLen, maxValues) {
+			data[k] = vals
+			continue
+		}
+		if maxValues > 0 && len(vals) > maxValues {
+			truncated += len(vals) - maxValues
+			vals = vals[:maxValues]
+		}
+		ret := make([]string, len(vals))
+		for i, v := range vals {
+			if maxValueLen > 0 && len(v) > maxValueLen {
+				end := maxValueLen
+				for end > 0 && !utf8.RuneStart(v[end]) {
+					end--
+				}
+				v = v[:end] + MetadataTruncatedSuffix
+				truncated++
 			}
+			ret[i] = v
 		}
+		data[k] = ret
+	}
+	return Metadata{data: data}, truncated
+}
 
-		// if it's still not found, it's really not here
-		if len(vals) == 0 {
-			return nil
+// valuesWithin returns whether vals has at most maxValues values, each
+// of at most maxValueLen bytes, zero limits not being enforced.
+func valuesWithin(vals []string, maxValueLen, maxValues int) bool {
+	if maxValues > 0 && len(vals) > maxValues {
+		return false
+	}
+	if maxValueLen > 0 {
+		for _, v := range vals {
+			if len(v) > maxValueLen {
+				return false
+			}
 		}
 	}
+	return true
+}
+
+// MetadataBuilder composes a Metadata, such as from several sources,
+// without an intermediate map.  Its keys are case-insensitive as those of
+// Metadata, and the values passed to it are copied.  The zero value is
+// ready to use.
+type MetadataBuilder struct {
+	data map[string][]string
+}
+
+// Add appends vals to the values of key.
+func (b *MetadataBuilder) Add(key string, vals ...string) {
+	if b.data == nil {
+		b.data = map[string][]string{}
+	}
+	key = strings.ToLower(key)
+	b.data[key] = append(b.data[key], vals...)
+}
+
+// Set replaces the values of key by vals.
+func (b *MetadataBuilder) Set(key string, vals []string) {
+	if b.data == nil {
+		b.data = map[string][]string{}
+	}
+	b.data[strings.ToLower(key)] = append([]string(nil), vals...)
+}
 
+// Build returns the metadata composed so far, and empties b for it to
+// compose another one.
+func (b *MetadataBuilder) Build() Metadata {
+	md := Metadata{data: b.data}
+	b.data = nil
+	return md
+}
+
+// merge returns the metadata of m and other, the values of m winning for
+// the keys present in both.
+func (m Metadata) merge(other Metadata) Metadata {
+	switch {
+	case len(other.data) == 0:
+		return m
+	case len(m.data) == 0:
+		return other
+	}
+	data := make(map[string][]string, len(m.data)+len(other.data))
+	for k, v := range other.data {
+		data[k] = v
+	}
+	for k, v := range m.data {
+		data[k] = v
+	}
+	return Metadata{data: data}
+}
+
+// Get gets the value of the key from metadata, returning a copy.  The key
+// is case-insensitive.
+func (m Metadata) Get(key string) []string {
+	vals := m.data[strings.ToLower(key)]
+	if len(vals) == 0 {
+		return nil
+	}
 	ret := make([]string, len(vals))
 	copy(ret, vals)
-
 	return ret
 }
+
+// Keys returns the keys present in the metadata, in lower case and in no
+// particular order.  The returned slice is a copy and may be modified by
+// the caller.
+func (m Metadata) Keys() []string {
+	keys := make([]string, 0, len(m.data))
+	for k := range m.data {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Range calls f for each key of the metadata, in lower case and in no
+// particular order, with a copy of its values, until f returns false.
+// Unlike Keys, Range does not allocate the list of keys.
+func (m Metadata) Range(f func(key string, vals []string) bool) {
+	for k, vals := range m.data {
+		ret := make([]string, len(vals))
+		copy(ret, vals)
+		if !f(k, ret) {
+			return
+		}
+	}
+}
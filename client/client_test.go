@@ -37,6 +37,10 @@ func TestNewContext(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc: "client with source",
+			cl:   Info{Source: "otlp/internal"},
+		},
 		{
 			desc: "nil client",
 			cl:   Info{},
@@ -100,3 +104,203 @@ func TestMetadata(t *testing.T) {
 
 	assert.Empty(t, md.Get("non-existent-key"))
 }
+
+func TestMetadataCanonical(t *testing.T) {
+	source := map[string][]string{
+		"X-Tenant": {"a"},
+		"x-tenant": {"b"},
+		"X-TENANT": {"c"},
+		"Other":    {"d"},
+	}
+	md := NewMetadata(source)
+	for _, key := range []string{"x-tenant", "X-Tenant", "X-TENANT", "x-Tenant"} {
+		assert.Equal(t, []string{"c", "a", "b"}, md.Get(key), key)
+	}
+	assert.Equal(t, []string{"d"}, md.Get("other"))
+	assert.Equal(t, []string{"d"}, md.Get("OTHER"))
+
+	// The source is left unchanged.
+	assert.Equal(t, map[string][]string{
+		"X-Tenant": {"a"},
+		"x-tenant": {"b"},
+		"X-TENANT": {"c"},
+		"Other":    {"d"},
+	}, source)
+}
+
+func TestNewMetadataCopies(t *testing.T) {
+	for _, key := range []string{"x-tenant", "X-Tenant"} {
+		t.Run(key, func(t *testing.T) {
+			vals := []string{"a", "b"}
+			source := map[string][]string{key: vals[:1]}
+			md := NewMetadata(source)
+
+			// Neither changing the map nor its values, nor appending to
+			// them, affects the metadata.
+			source["x-other"] = []string{"c"}
+			source[key][0] = "d"
+			_ = append(source[key], "e")
+			delete(source, key)
+			assert.Equal(t, []string{"x-tenant"}, md.Keys())
+			assert.Equal(t, []string{"a"}, md.Get("x-tenant"))
+			assert.Equal(t, []string{"d", "e"}, vals)
+		})
+	}
+}
+
+func TestMetadataBuilder(t *testing.T) {
+	var b MetadataBuilder
+	assert.Empty(t, b.Build().Keys())
+
+	vals := []string{"a"}
+	b.Set("X-Tenant", vals)
+	b.Add("x-tenant", "b", "c")
+	b.Add("X-Region", "eu")
+	b.Set("x-region", []string{"us"})
+	vals[0] = "d"
+	md := b.Build()
+	assert.ElementsMatch(t, []string{"x-tenant", "x-region"}, md.Keys())
+	assert.Equal(t, []string{"a", "b", "c"}, md.Get("x-tenant"))
+	assert.Equal(t, []string{"us"}, md.Get("x-region"))
+
+	// The builder starts over, without affecting the metadata built.
+	b.Add("x-tenant", "e")
+	assert.Equal(t, []string{"e"}, b.Build().Get("x-tenant"))
+	assert.Equal(t, []string{"a", "b", "c"}, md.Get("x-tenant"))
+}
+
+func TestMetadataKeys(t *testing.T) {
+	md := NewMetadata(map[string][]string{
+		"test-key":  {"test-val"},
+		"Other-Key": {"a", "b"},
+		"OTHER-KEY": {"c"},
+	})
+	assert.ElementsMatch(t, []string{"test-key", "other-key"}, md.Keys())
+	assert.Equal(t, []string{"test-val"}, md.Get("Test-Key"))
+	assert.ElementsMatch(t, []string{"test-key", "other-key"}, md.Keys())
+
+	assert.Empty(t, NewMetadata(nil).Keys())
+	assert.Empty(t, NewMetadata(map[string][]string{}).Keys())
+	assert.Empty(t, Metadata{}.Keys())
+}
+
+func TestMetadataRange(t *testing.T) {
+	source := map[string][]string{
+		"test-key":  {"test-val"},
+		"Test-Key":  {"other-val"},
+		"Other-Key": {"a", "b"},
+	}
+	md := NewMetadata(source)
+	got := map[string][]string{}
+	md.Range(func(k string, v []string) bool {
+		got[k] = v
+		return true
+	})
+	assert.Equal(t, map[string][]string{
+		"test-key":  {"other-val", "test-val"},
+		"other-key": {"a", "b"},
+	}, got)
+
+	// The values are copies.
+	got["other-key"][0] = "c"
+	assert.Equal(t, []string{"a", "b"}, source["Other-Key"])
+
+	calls := 0
+	md.Range(func(string, []string) bool {
+		calls++
+		return false
+	})
+	assert.Equal(t, 1, calls)
+
+	for _, md := range []Metadata{NewMetadata(nil), NewMetadata(map[string][]string{}), {}} {
+		md.Range(func(string, []string) bool {
+			t.Fail()
+			return true
+		})
+	}
+}
+
+type testAuthData map[string]any
+
+func (a testAuthData) GetAttribute(name string) any {
+	return a[name]
+}
+
+func (a testAuthData) GetAttributeNames() []string {
+	names := make([]string, 0, len(a))
+	for name := range a {
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestInfoMerge(t *testing.T) {
+	addr := &net.IPAddr{IP: net.IPv4(1, 2, 3, 4)}
+	auth := testAuthData{"subject": "alice"}
+	existing := Info{
+		Addr:     addr,
+		Auth:     auth,
+		Metadata: NewMetadata(map[string][]string{"x-tenant": {"a"}, "x-region": {"eu"}}),
+	}
+	merged := Info{
+		Metadata: NewMetadata(map[string][]string{"X-Tenant": {"b"}, "x-batch": {"1"}}),
+		Source:   "otlp",
+	}.Merge(existing)
+
+	// The fields set are kept, the others are completed.
+	assert.Equal(t, addr, merged.Addr)
+	assert.Equal(t, auth, merged.Auth)
+	assert.Equal(t, "otlp", merged.Source)
+	assert.ElementsMatch(t, []string{"x-tenant", "x-region", "x-batch"}, merged.Metadata.Keys())
+	assert.Equal(t, []string{"b"}, merged.Metadata.Get("x-tenant"))
+	assert.Equal(t, []string{"eu"}, merged.Metadata.Get("x-region"))
+	assert.Equal(t, []string{"1"}, merged.Metadata.Get("x-batch"))
+
+	// The metadata merged from is left unchanged.
+	assert.Equal(t, []string{"a"}, existing.Metadata.Get("x-tenant"))
+	assert.Empty(t, existing.Metadata.Get("x-batch"))
+
+	other := Info{Addr: &net.IPAddr{IP: net.IPv4(5, 6, 7, 8)}, Auth: testAuthData{"subject": "bob"}, Source: "otlp/internal"}
+	merged = existing.Merge(other)
+	assert.Equal(t, addr, merged.Addr)
+	assert.Equal(t, auth, merged.Auth)
+	assert.Equal(t, "otlp/internal", merged.Source)
+	assert.Equal(t, existing.Metadata, merged.Metadata)
+
+	assert.Equal(t, existing, Info{}.Merge(existing))
+	assert.Equal(t, existing, existing.Merge(Info{}))
+}
+
+func TestTruncateMetadata(t *testing.T) {
+	md := NewMetadata(map[string][]string{
+		"x-tenant": {"acme"},
+		"x-long":   {"0123456789", "short"},
+		"x-many":   {"a", "b", "c", "d"},
+		"x-utf8":   {"abcdé"},
+	})
+
+	truncated, n := TruncateMetadata(md, 5, 2)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, []string{"acme"}, truncated.Get("x-tenant"))
+	assert.Equal(t, []string{"01234...", "short"}, truncated.Get("x-long"))
+	assert.Equal(t, []string{"a", "b"}, truncated.Get("x-many"))
+	// The two bytes of "é" are not split.
+	assert.Equal(t, []string{"abcd..."}, truncated.Get("x-utf8"))
+	assert.Equal(t, []string{"0123456789", "short"}, md.Get("x-long"))
+
+	truncated, n = TruncateMetadata(md, 0, 3)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"a", "b", "c"}, truncated.Get("x-many"))
+	assert.Equal(t, []string{"0123456789", "short"}, truncated.Get("x-long"))
+
+	// Within the limits, the metadata is returned as-is.
+	truncated, n = TruncateMetadata(md, 10, 4)
+	assert.Zero(t, n)
+	assert.Equal(t, md, truncated)
+	truncated, n = TruncateMetadata(md, 0, 0)
+	assert.Zero(t, n)
+	assert.Equal(t, md, truncated)
+	truncated, n = TruncateMetadata(Metadata{}, 1, 1)
+	assert.Zero(t, n)
+	assert.Equal(t, Metadata{}, truncated)
+}
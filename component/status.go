@@ -0,0 +1,65 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component // import "go.opentelemetry.io/collector/component"
+
+// Status is the health of a component, as reported in a StatusEvent.
+type Status int
+
+const (
+	// StatusOK indicates that the component works as expected.
+	StatusOK Status = iota
+	// StatusRecoverableError indicates that the component is failing,
+	// but is expected to recover without intervention, e.g. once an
+	// unavailable backend is reachable again.
+	StatusRecoverableError
+)
+
+// String returns the name of the status.
+func (s Status) String() string {
+	switch s {
+	case StatusOK:
+		return "OK"
+	case StatusRecoverableError:
+		return "RecoverableError"
+	}
+	return "Unknown"
+}
+
+// StatusEvent is a change of the status of a component.
+type StatusEvent struct {
+	// Status is the new status of the component.
+	Status Status
+
+	// Err is the error causing a StatusRecoverableError, nil
+	// otherwise.
+	Err error
+
+	// Attributes identify the part of the component the event
+	// applies to, e.g. the client metadata of the failing data.
+	Attributes map[string]string
+}
+
+// StatusReporter is an optional interface implemented by a Host that
+// accepts the status changes of its components, in addition to fatal
+// errors.  Components check whether their host implements it.
+//
+// This is an experimental interface that may change or even be removed
+// completely.
+type StatusReporter interface {
+	// ReportComponentStatus reports a status change of the component
+	// identified by id.  It may be called concurrently, anytime after
+	// Component.Start() begins and until Component.Shutdown() ends.
+	ReportComponentStatus(id ID, event StatusEvent)
+}
@@ -427,11 +427,14 @@ func contextWithClient(ctx context.Context, includeMetadata bool) context.Contex
 	}
 	if includeMetadata {
 		if md, ok := metadata.FromIncomingContext(ctx); ok {
-			copiedMD := md.Copy()
+			var copiedMD client.MetadataBuilder
+			for k, v := range md {
+				copiedMD.Add(k, v...)
+			}
 			if len(md[client.MetadataHostName]) == 0 && len(md[":authority"]) > 0 {
-				copiedMD[client.MetadataHostName] = md[":authority"]
+				copiedMD.Add(client.MetadataHostName, md[":authority"]...)
 			}
-			cl.Metadata = client.NewMetadata(copiedMD)
+			cl.Metadata = copiedMD.Build()
 		}
 	}
 	return client.NewContext(ctx, cl)
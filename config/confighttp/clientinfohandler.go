@@ -50,12 +50,15 @@ func contextWithClient(req *http.Request, includeMetadata bool) context.Context
 	}
 
 	if includeMetadata {
-		md := req.Header.Clone()
-		if len(md.Get(client.MetadataHostName)) == 0 && req.Host != "" {
+		var md client.MetadataBuilder
+		for k, v := range req.Header {
+			md.Add(k, v...)
+		}
+		if len(req.Header.Get(client.MetadataHostName)) == 0 && req.Host != "" {
 			md.Add(client.MetadataHostName, req.Host)
 		}
 
-		cl.Metadata = client.NewMetadata(md)
+		cl.Metadata = md.Build()
 	}
 
 	ctx := client.NewContext(req.Context(), cl)
@@ -26,6 +26,24 @@ type Capabilities struct {
 	// does not modify the data it MUST set this flag to false. If the processor creates
 	// a copy of the data before modifying then this flag can be safely set to false.
 	MutatesData bool
+
+	// AcceptsReadOnly is set to true if Consume* function of the consumer
+	// accepts input data marked read-only, copying it before modifying it if
+	// needed.  Producers may then mark the data they pass read-only.  Without
+	// this flag, the data is only marked read-only when it is shared between
+	// several consumers.
+	AcceptsReadOnly bool
+}
+
+// BatchHintProvider is implemented by consumers which know the size of
+// the requests they prefer to receive, e.g. exporters bounded by a maximum
+// message size, for batching producers to adopt it.  Consumers wrapping
+// others may implement it by returning the hint of the wrapped ones.
+type BatchHintProvider interface {
+	// PreferredBatchSize returns the preferred number of items, i.e.
+	// spans, data points or log records, and the preferred size in bytes
+	// of a request, each zero when the consumer has no preference.
+	PreferredBatchSize() (items int, bytes int)
 }
 
 type baseConsumer interface {
@@ -0,0 +1,87 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumererror // import "go.opentelemetry.io/collector/consumer/consumererror"
+
+import (
+	"errors"
+	"strings"
+)
+
+// combined is an error combining several errors.
+type combined []error
+
+// Combine returns an error combining errs, e.g. the errors of the
+// requests of a split export.  Nil errors are dropped and combined
+// errors are flattened; nil is returned when no error is left, and the
+// error itself when only one is.  Functions Is and As in standard
+// package errors match the combined error when they match any of its
+// members, so it is permanent if any member is, and Count sums the
+// counts of its members.
+func Combine(errs []error) error {
+	var flat combined
+	for _, err := range errs {
+		switch e := err.(type) {
+		case nil:
+		case combined:
+			flat = append(flat, e...)
+		default:
+			flat = append(flat, err)
+		}
+	}
+	switch len(flat) {
+	case 0:
+		return nil
+	case 1:
+		return flat[0]
+	}
+	return flat
+}
+
+func (c combined) Error() string {
+	msgs := make([]string, len(c))
+	for i, err := range c {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the combined errors matches target, for
+// function Is in standard package errors, which only unwraps errors
+// combining several errors as of Go 1.20.
+func (c combined) Is(target error) bool {
+	for _, err := range c {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the combined errors matching target, for
+// function As in standard package errors, which only unwraps errors
+// combining several errors as of Go 1.20.
+func (c combined) As(target any) bool {
+	for _, err := range c {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap returns the combined errors.
+func (c combined) Unwrap() []error {
+	return c
+}
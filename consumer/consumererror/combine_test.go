@@ -0,0 +1,62 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumererror
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCombine(t *testing.T) {
+	assert.NoError(t, Combine(nil))
+	assert.NoError(t, Combine([]error{nil, nil}))
+
+	err := errors.New("some error")
+	assert.Equal(t, err, Combine([]error{nil, err}))
+
+	other := testErrorType{"other error"}
+	combinedErr := Combine([]error{err, nil, Combine([]error{other, NewWithCount(err, 2)})})
+	assert.EqualError(t, combinedErr, "some error; ; some error")
+	assert.Equal(t, combined{err, other, NewWithCount(err, 2)}, combinedErr)
+	assert.ErrorIs(t, combinedErr, err)
+	var target testErrorType
+	require.True(t, errors.As(combinedErr, &target))
+	assert.Equal(t, other, target)
+}
+
+func TestCombine_Permanent(t *testing.T) {
+	err := errors.New("some error")
+	assert.False(t, IsPermanent(Combine([]error{err, err})))
+	assert.True(t, IsPermanent(Combine([]error{err, NewPermanent(err)})))
+	assert.True(t, IsPermanent(Combine([]error{err, Combine([]error{err, fmt.Errorf("wrapped: %w", NewPermanent(err))})})))
+}
+
+func TestCombine_Count(t *testing.T) {
+	err := errors.New("some error")
+	_, ok := Count(Combine([]error{err, err}))
+	assert.False(t, ok)
+
+	n, ok := Count(Combine([]error{NewWithCount(err, 2), err, NewPermanent(NewWithCount(err, 3))}))
+	assert.True(t, ok)
+	assert.Equal(t, 5, n)
+
+	n, ok = Count(Combine([]error{NewWithCount(err, 2), Combine([]error{NewWithCount(err, 4), NewWithCount(err, 1)})}))
+	assert.True(t, ok)
+	assert.Equal(t, 7, n)
+}
@@ -0,0 +1,65 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumererror // import "go.opentelemetry.io/collector/consumer/consumererror"
+
+// rejected is an error carrying the number of items that its source
+// rejected.
+type rejected struct {
+	err   error
+	count int
+}
+
+// NewWithCount wraps an error with the number of items, i.e. spans,
+// data points or log records, that its source rejected, e.g. as
+// reported by an OTLP partial success.  The count survives wrapping,
+// including by NewPermanent, and is retrieved with Count.
+func NewWithCount(err error, rejectedItems int) error {
+	return rejected{err: err, count: rejectedItems}
+}
+
+func (r rejected) Error() string {
+	return r.err.Error()
+}
+
+// Unwrap returns the wrapped error for functions Is and As in standard package errors.
+func (r rejected) Unwrap() error {
+	return r.err
+}
+
+// Count returns the number of items rejected according to the error,
+// and whether any error in its tree was wrapped with NewWithCount.
+// The counts of joined errors, i.e. errors with an Unwrap() []error
+// method, are summed; joined errors without a count add nothing.  The
+// outermost count of a chain of wrapped errors is the one returned.
+func Count(err error) (int, bool) {
+	switch e := err.(type) {
+	case nil:
+		return 0, false
+	case rejected:
+		return e.count, true
+	case interface{ Unwrap() []error }:
+		total, found := 0, false
+		for _, child := range e.Unwrap() {
+			if n, ok := Count(child); ok {
+				total += n
+				found = true
+			}
+		}
+		return total, found
+	case interface{ Unwrap() error }:
+		return Count(e.Unwrap())
+	}
+	return 0, false
+}
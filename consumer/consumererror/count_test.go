@@ -0,0 +1,83 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumererror
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// joinedErrors joins errors the way errors.Join does.
+type joinedErrors []error
+
+func (j joinedErrors) Error() string {
+	msgs := make([]string, 0, len(j))
+	for _, err := range j {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (j joinedErrors) Unwrap() []error {
+	return j
+}
+
+func TestCount(t *testing.T) {
+	base := errors.New("testError")
+
+	_, ok := Count(nil)
+	assert.False(t, ok)
+	_, ok = Count(base)
+	assert.False(t, ok)
+
+	err := NewWithCount(base, 3)
+	assert.Equal(t, base.Error(), err.Error())
+	assert.ErrorIs(t, err, base)
+	n, ok := Count(err)
+	assert.True(t, ok)
+	assert.Equal(t, 3, n)
+
+	n, ok = Count(fmt.Errorf("export: %w", err))
+	assert.True(t, ok)
+	assert.Equal(t, 3, n)
+
+	err = NewPermanent(NewWithCount(base, 4))
+	assert.True(t, IsPermanent(err))
+	n, ok = Count(err)
+	assert.True(t, ok)
+	assert.Equal(t, 4, n)
+
+	err = NewWithCount(NewPermanent(base), 5)
+	assert.True(t, IsPermanent(err))
+	n, ok = Count(err)
+	assert.True(t, ok)
+	assert.Equal(t, 5, n)
+
+	n, ok = Count(NewWithCount(NewWithCount(base, 2), 7))
+	assert.True(t, ok)
+	assert.Equal(t, 7, n)
+
+	err = joinedErrors{NewWithCount(base, 2), base, fmt.Errorf("wrapped: %w", NewPermanent(NewWithCount(base, 6)))}
+	n, ok = Count(err)
+	assert.True(t, ok)
+	assert.Equal(t, 8, n)
+
+	_, ok = Count(joinedErrors{base, NewPermanent(base)})
+	assert.False(t, ok)
+}
@@ -36,7 +36,9 @@ func (err retryable[V]) Data() V {
 }
 
 // Traces is an error that may carry associated Trace data for a subset of received data
-// that failed to be processed or sent.
+// that failed to be processed or sent.  It is retryable: IsPermanent reports
+// false for it unless the wrapped error is permanent, and errors.As finds it
+// through wrapping errors.
 type Traces struct {
 	retryable[ptrace.Traces]
 }
@@ -52,7 +54,9 @@ func NewTraces(err error, data ptrace.Traces) error {
 }
 
 // Logs is an error that may carry associated Log data for a subset of received data
-// that failed to be processed or sent.
+// that failed to be processed or sent.  It is retryable: IsPermanent reports
+// false for it unless the wrapped error is permanent, and errors.As finds it
+// through wrapping errors.
 type Logs struct {
 	retryable[plog.Logs]
 }
@@ -68,7 +72,9 @@ func NewLogs(err error, data plog.Logs) error {
 }
 
 // Metrics is an error that may carry associated Metrics data for a subset of received data
-// that failed to be processed or sent.
+// that failed to be processed or sent.  It is retryable: IsPermanent reports
+// false for it unless the wrapped error is permanent, and errors.As finds it
+// through wrapping errors.
 type Metrics struct {
 	retryable[pmetric.Metrics]
 }
@@ -16,6 +16,7 @@ package consumererror
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -95,3 +96,30 @@ func TestMetrics_Unwrap(t *testing.T) {
 	require.True(t, errors.As(metricErr, &target))
 	require.Equal(t, err, target)
 }
+
+func TestSignalErrors_Wrapped(t *testing.T) {
+	td := testdata.GenerateTraces(1)
+	md := testdata.GenerateMetrics(1)
+	ld := testdata.GenerateLogs(1)
+	err := errors.New("some error")
+
+	tracesErr := fmt.Errorf("export: %w", NewWithCount(NewTraces(err, td), 1))
+	assert.False(t, IsPermanent(tracesErr))
+	var traces Traces
+	require.True(t, errors.As(tracesErr, &traces))
+	assert.Equal(t, td, traces.Data())
+
+	metricsErr := fmt.Errorf("export: %w", NewMetrics(err, md))
+	assert.False(t, IsPermanent(metricsErr))
+	var metrics Metrics
+	require.True(t, errors.As(metricsErr, &metrics))
+	assert.Equal(t, md, metrics.Data())
+
+	logsErr := NewPermanent(NewLogs(err, ld))
+	assert.True(t, IsPermanent(logsErr))
+	var logs Logs
+	require.True(t, errors.As(logsErr, &logs))
+	assert.Equal(t, ld, logs.Data())
+
+	assert.True(t, IsPermanent(NewTraces(NewPermanent(err), td)))
+}
@@ -100,6 +100,20 @@ func (es {{ .structName }}) MoveAndAppendTo(dest {{ .structName }}) {
 	*es.orig = nil
 }
 
+// MoveAndAppendFrom moves all elements from each of the srcs, in order, and appends them
+// to the current slice, growing it at most once.  The srcs will be cleared.
+func (es {{ .structName }}) MoveAndAppendFrom(srcs ...{{ .structName }}) {
+	newLen := len(*es.orig)
+	for _, src := range srcs {
+		newLen += len(*src.orig)
+	}
+	es.EnsureCapacity(newLen)
+	for _, src := range srcs {
+		*es.orig = append(*es.orig, *src.orig...)
+		*src.orig = nil
+	}
+}
+
 // RemoveIf calls f sequentially for each element present in the slice.
 // If f returns true, the element is removed from the slice.
 func (es {{ .structName }}) RemoveIf(f func({{ .elementName }}) bool) {
@@ -236,6 +250,32 @@ func Test{{ .structName }}_MoveAndAppendTo(t *testing.T) {
 	}
 }
 
+func Test{{ .structName }}_MoveAndAppendFrom(t *testing.T) {
+	// Test MoveAndAppendFrom without sources
+	expectedSlice := generateTest{{ .structName }}()
+	dest := New{{ .structName }}()
+	dest.MoveAndAppendFrom()
+	assert.Equal(t, 0, dest.Len())
+
+	// Test MoveAndAppendFrom empty and not empty slices
+	srcs := []{{ .structName }}{generateTest{{ .structName }}(), New{{ .structName }}(), generateTest{{ .structName }}()}
+	dest.MoveAndAppendFrom(srcs...)
+	assert.Equal(t, 2*expectedSlice.Len(), dest.Len())
+	assert.Equal(t, 2*expectedSlice.Len(), cap(*dest.orig))
+	for _, src := range srcs {
+		assert.Equal(t, 0, src.Len())
+	}
+	for i := 0; i < expectedSlice.Len(); i++ {
+		assert.Equal(t, expectedSlice.At(i), dest.At(i))
+		assert.Equal(t, expectedSlice.At(i), dest.At(i+expectedSlice.Len()))
+	}
+
+	// Test MoveAndAppendFrom to not empty slice
+	dest.MoveAndAppendFrom(generateTest{{ .structName }}())
+	assert.Equal(t, 3*expectedSlice.Len(), dest.Len())
+	assert.Equal(t, expectedSlice.At(0), dest.At(2*expectedSlice.Len()))
+}
+
 func Test{{ .structName }}_RemoveIf(t *testing.T) {
 	// Test RemoveIf on empty slice
 	emptySlice := New{{ .structName }}()
@@ -0,0 +1,191 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dedup identifies the equal resources and scopes of telemetry
+// data, for them to be merged.
+package dedup // import "go.opentelemetry.io/collector/pdata/internal/dedup"
+
+import (
+	"math"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// FNV-1a parameters, hashed inline to avoid allocating a hash.Hash64
+// per resource.
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+func hashString(h uint64, s string) uint64 {
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime64
+	}
+	return h
+}
+
+func hashUint64(h uint64, v uint64) uint64 {
+	for i := 0; i < 8; i++ {
+		h ^= v & 0xff
+		h *= fnvPrime64
+		v >>= 8
+	}
+	return h
+}
+
+// hashMap returns a hash of the attributes of m that does not depend
+// on their order.
+func hashMap(m pcommon.Map) uint64 {
+	var sum uint64
+	m.Range(func(k string, v pcommon.Value) bool {
+		sum += hashValue(hashString(fnvOffset64, k), v)
+		return true
+	})
+	return sum
+}
+
+func hashValue(h uint64, v pcommon.Value) uint64 {
+	h = hashUint64(h, uint64(v.Type()))
+	switch v.Type() {
+	case pcommon.ValueTypeStr:
+		h = hashString(h, v.Str())
+	case pcommon.ValueTypeInt:
+		h = hashUint64(h, uint64(v.Int()))
+	case pcommon.ValueTypeDouble:
+		h = hashUint64(h, math.Float64bits(v.Double()))
+	case pcommon.ValueTypeBool:
+		if v.Bool() {
+			h = hashUint64(h, 1)
+		}
+	case pcommon.ValueTypeBytes:
+		for _, b := range v.Bytes().AsRaw() {
+			h ^= uint64(b)
+			h *= fnvPrime64
+		}
+	case pcommon.ValueTypeMap:
+		h = hashUint64(h, hashMap(v.Map()))
+	case pcommon.ValueTypeSlice:
+		s := v.Slice()
+		for i := 0; i < s.Len(); i++ {
+			h = hashValue(h, s.At(i))
+		}
+	}
+	return h
+}
+
+// mapsEqual returns whether a and b hold the same attributes, in any
+// order.
+func mapsEqual(a, b pcommon.Map) bool {
+	if a.Len() != b.Len() {
+		return false
+	}
+	equal := true
+	a.Range(func(k string, va pcommon.Value) bool {
+		vb, ok := b.Get(k)
+		equal = ok && valuesEqual(va, vb)
+		return equal
+	})
+	return equal
+}
+
+func valuesEqual(a, b pcommon.Value) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a.Type() {
+	case pcommon.ValueTypeStr:
+		return a.Str() == b.Str()
+	case pcommon.ValueTypeInt:
+		return a.Int() == b.Int()
+	case pcommon.ValueTypeDouble:
+		return math.Float64bits(a.Double()) == math.Float64bits(b.Double())
+	case pcommon.ValueTypeBool:
+		return a.Bool() == b.Bool()
+	case pcommon.ValueTypeBytes:
+		return string(a.Bytes().AsRaw()) == string(b.Bytes().AsRaw())
+	case pcommon.ValueTypeMap:
+		return mapsEqual(a.Map(), b.Map())
+	case pcommon.ValueTypeSlice:
+		sa, sb := a.Slice(), b.Slice()
+		if sa.Len() != sb.Len() {
+			return false
+		}
+		for i := 0; i < sa.Len(); i++ {
+			if !valuesEqual(sa.At(i), sb.At(i)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// HashResource returns the hash of a resource entry, identified by its
+// resource and schema URL, not depending on the order of the attributes
+// of the resource.
+func HashResource(res pcommon.Resource, schemaURL string) uint64 {
+	h := hashUint64(fnvOffset64, hashMap(res.Attributes()))
+	h = hashUint64(h, uint64(res.DroppedAttributesCount()))
+	return hashString(h, schemaURL)
+}
+
+// ResourcesEqual returns whether two resource entries have the same
+// schema URL, and resources of the same attributes, in any order.
+func ResourcesEqual(a pcommon.Resource, aSchemaURL string, b pcommon.Resource, bSchemaURL string) bool {
+	return aSchemaURL == bSchemaURL &&
+		a.DroppedAttributesCount() == b.DroppedAttributesCount() &&
+		mapsEqual(a.Attributes(), b.Attributes())
+}
+
+// HashScope returns the hash of a scope entry of a resource, identified
+// by its instrumentation scope and schema URL.
+func HashScope(scope pcommon.InstrumentationScope, schemaURL string) uint64 {
+	h := hashString(fnvOffset64, scope.Name())
+	h = hashString(hashUint64(h, uint64(len(scope.Name()))), scope.Version())
+	h = hashUint64(h, hashMap(scope.Attributes()))
+	h = hashUint64(h, uint64(scope.DroppedAttributesCount()))
+	return hashString(h, schemaURL)
+}
+
+// ScopesEqual returns whether two scope entries have the same schema URL,
+// and instrumentation scopes of the same name, version and attributes, in
+// any order.
+func ScopesEqual(a pcommon.InstrumentationScope, aSchemaURL string, b pcommon.InstrumentationScope, bSchemaURL string) bool {
+	return aSchemaURL == bSchemaURL &&
+		a.Name() == b.Name() &&
+		a.Version() == b.Version() &&
+		a.DroppedAttributesCount() == b.DroppedAttributesCount() &&
+		mapsEqual(a.Attributes(), b.Attributes())
+}
+
+// Index maps hashes to the positions of the entries of a slice having
+// them.
+type Index map[uint64][]int
+
+// Find returns the position of the entry of hash h for which equal
+// returns true.
+func (idx Index) Find(h uint64, equal func(i int) bool) (int, bool) {
+	for _, i := range idx[h] {
+		if equal(i) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Put records the entry at position i of hash h.
+func (idx Index) Put(h uint64, i int) {
+	idx[h] = append(idx[h], i)
+}
@@ -0,0 +1,103 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dedup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+func TestHashResource(t *testing.T) {
+	a := pcommon.NewResource()
+	a.Attributes().PutStr("service.name", "checkout")
+	a.Attributes().PutInt("pid", 42)
+	a.Attributes().PutEmptySlice("args").AppendEmpty().SetStr("-v")
+	a.Attributes().PutEmptyMap("host").PutStr("name", "node-1")
+
+	// The same attributes in another order.
+	b := pcommon.NewResource()
+	b.Attributes().PutEmptyMap("host").PutStr("name", "node-1")
+	b.Attributes().PutEmptySlice("args").AppendEmpty().SetStr("-v")
+	b.Attributes().PutInt("pid", 42)
+	b.Attributes().PutStr("service.name", "checkout")
+	assert.Equal(t, HashResource(a, "s"), HashResource(b, "s"))
+	assert.True(t, ResourcesEqual(a, "s", b, "s"))
+
+	assert.False(t, ResourcesEqual(a, "s", b, "t"))
+	assert.NotEqual(t, HashResource(a, "s"), HashResource(b, "t"))
+
+	b.SetDroppedAttributesCount(1)
+	assert.False(t, ResourcesEqual(a, "s", b, "s"))
+	b.SetDroppedAttributesCount(0)
+
+	b.Attributes().PutInt("pid", 43)
+	assert.False(t, ResourcesEqual(a, "s", b, "s"))
+	assert.NotEqual(t, HashResource(a, "s"), HashResource(b, "s"))
+
+	// Values of different types are not equal.
+	b.Attributes().PutStr("pid", "42")
+	assert.False(t, ResourcesEqual(a, "s", b, "s"))
+
+	b.Attributes().PutInt("pid", 42)
+	b.Attributes().Remove("args")
+	assert.False(t, ResourcesEqual(a, "s", b, "s"))
+}
+
+func TestHashScope(t *testing.T) {
+	a := pcommon.NewInstrumentationScope()
+	a.SetName("io.opentelemetry.http")
+	a.SetVersion("1.0.0")
+	a.Attributes().PutStr("k", "v")
+	b := pcommon.NewInstrumentationScope()
+	a.CopyTo(b)
+	assert.Equal(t, HashScope(a, "s"), HashScope(b, "s"))
+	assert.True(t, ScopesEqual(a, "s", b, "s"))
+	assert.False(t, ScopesEqual(a, "s", b, "t"))
+
+	b.SetVersion("1.0.1")
+	assert.False(t, ScopesEqual(a, "s", b, "s"))
+	assert.NotEqual(t, HashScope(a, "s"), HashScope(b, "s"))
+
+	// The name and version are not hashed as one string.
+	a.SetName("ab")
+	a.SetVersion("c")
+	b.SetName("a")
+	b.SetVersion("bc")
+	assert.NotEqual(t, HashScope(a, "s"), HashScope(b, "s"))
+	assert.False(t, ScopesEqual(a, "s", b, "s"))
+
+	b.SetName("ab")
+	b.SetVersion("c")
+	b.Attributes().PutStr("k", "w")
+	assert.False(t, ScopesEqual(a, "s", b, "s"))
+}
+
+func TestIndex(t *testing.T) {
+	idx := Index{}
+	idx.Put(1, 0)
+	idx.Put(1, 2)
+	idx.Put(2, 1)
+
+	i, ok := idx.Find(1, func(i int) bool { return i == 2 })
+	assert.True(t, ok)
+	assert.Equal(t, 2, i)
+	_, ok = idx.Find(2, func(i int) bool { return i == 2 })
+	assert.False(t, ok)
+	_, ok = idx.Find(3, func(int) bool { return true })
+	assert.False(t, ok)
+}
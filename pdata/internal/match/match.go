@@ -0,0 +1,64 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package match pairs the entries of two slices of telemetry data, for
+// them to be compared.
+package match // import "go.opentelemetry.io/collector/pdata/internal/match"
+
+// Pair is an expected entry and the actual entry it is compared with.
+type Pair struct {
+	Expected, Actual int
+}
+
+// Entries pairs the expected entries with the actual ones, by index
+// unless ignoreOrder is set.  Otherwise every expected entry is paired
+// with the first actual entry not paired yet for which same returns true.
+// The expected and actual entries left without a pair are returned as
+// missing and unexpected.
+func Entries(expected, actual int, ignoreOrder bool, same func(i, j int) bool) (pairs []Pair, missing, unexpected []int) {
+	if !ignoreOrder {
+		for i := 0; i < expected && i < actual; i++ {
+			pairs = append(pairs, Pair{Expected: i, Actual: i})
+		}
+		for i := actual; i < expected; i++ {
+			missing = append(missing, i)
+		}
+		for j := expected; j < actual; j++ {
+			unexpected = append(unexpected, j)
+		}
+		return pairs, missing, unexpected
+	}
+
+	paired := make([]bool, actual)
+	for i := 0; i < expected; i++ {
+		found := false
+		for j := 0; j < actual; j++ {
+			if !paired[j] && same(i, j) {
+				paired[j] = true
+				pairs = append(pairs, Pair{Expected: i, Actual: j})
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, i)
+		}
+	}
+	for j := 0; j < actual; j++ {
+		if !paired[j] {
+			unexpected = append(unexpected, j)
+		}
+	}
+	return pairs, missing, unexpected
+}
@@ -0,0 +1,42 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package match
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntries(t *testing.T) {
+	expected := []string{"a", "b", "a", "c"}
+	actual := []string{"a", "d", "a", "b"}
+	same := func(i, j int) bool { return expected[i] == actual[j] }
+
+	pairs, missing, unexpected := Entries(len(expected), len(actual), false, same)
+	assert.Equal(t, []Pair{{0, 0}, {1, 1}, {2, 2}, {3, 3}}, pairs)
+	assert.Empty(t, missing)
+	assert.Empty(t, unexpected)
+
+	pairs, missing, unexpected = Entries(len(expected), len(actual), true, same)
+	assert.Equal(t, []Pair{{0, 0}, {1, 3}, {2, 2}}, pairs)
+	assert.Equal(t, []int{3}, missing)
+	assert.Equal(t, []int{1}, unexpected)
+
+	pairs, missing, unexpected = Entries(3, 1, false, same)
+	assert.Equal(t, []Pair{{0, 0}}, pairs)
+	assert.Equal(t, []int{1, 2}, missing)
+	assert.Empty(t, unexpected)
+}
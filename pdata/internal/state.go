@@ -0,0 +1,43 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal // import "go.opentelemetry.io/collector/pdata/internal"
+
+// State is the ownership state of a Traces, Metrics, or Logs, shared by
+// the copies of the wrapper and by the ExportRequest wrapping the same
+// data.
+type State int32
+
+const (
+	// StateMutable is the state of data owned by its holder.
+	StateMutable State = iota
+	// StateReadOnly is the state of data shared with other
+	// consumers, which must not be mutated.
+	StateReadOnly
+)
+
+// MarkReadOnly marks the data read-only.  It cannot be made mutable
+// again.  A nil state, of a zero-initialized wrapper, holds no data and
+// is left as is.
+func (state *State) MarkReadOnly() {
+	if state != nil {
+		*state = StateReadOnly
+	}
+}
+
+// IsReadOnly returns whether the data was marked read-only.  A nil
+// state, of a zero-initialized wrapper, is mutable.
+func (state *State) IsReadOnly() bool {
+	return state != nil && *state == StateReadOnly
+}
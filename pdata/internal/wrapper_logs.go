@@ -20,15 +20,20 @@ import (
 )
 
 type Logs struct {
-	orig *otlpcollectorlog.ExportLogsServiceRequest
+	orig  *otlpcollectorlog.ExportLogsServiceRequest
+	state *State
 }
 
 func GetOrigLogs(ms Logs) *otlpcollectorlog.ExportLogsServiceRequest {
 	return ms.orig
 }
 
-func NewLogs(orig *otlpcollectorlog.ExportLogsServiceRequest) Logs {
-	return Logs{orig: orig}
+func GetLogsState(ms Logs) *State {
+	return ms.state
+}
+
+func NewLogs(orig *otlpcollectorlog.ExportLogsServiceRequest, state *State) Logs {
+	return Logs{orig: orig, state: state}
 }
 
 // LogsToProto internal helper to convert Logs to protobuf representation.
@@ -40,7 +45,7 @@ func LogsToProto(l Logs) otlplogs.LogsData {
 
 // LogsFromProto internal helper to convert protobuf representation to Logs.
 func LogsFromProto(orig otlplogs.LogsData) Logs {
-	return Logs{orig: &otlpcollectorlog.ExportLogsServiceRequest{
+	return NewLogs(&otlpcollectorlog.ExportLogsServiceRequest{
 		ResourceLogs: orig.ResourceLogs,
-	}}
+	}, new(State))
 }
@@ -121,6 +121,20 @@ func (es Slice) MoveAndAppendTo(dest Slice) {
 	*es.getOrig() = nil
 }
 
+// MoveAndAppendFrom moves all elements from each of the srcs, in order, and appends them
+// to the current slice, growing it at most once.  The srcs will be cleared.
+func (es Slice) MoveAndAppendFrom(srcs ...Slice) {
+	newLen := es.Len()
+	for _, src := range srcs {
+		newLen += src.Len()
+	}
+	es.EnsureCapacity(newLen)
+	for _, src := range srcs {
+		*es.getOrig() = append(*es.getOrig(), *src.getOrig()...)
+		*src.getOrig() = nil
+	}
+}
+
 // RemoveIf calls f sequentially for each element present in the slice.
 // If f returns true, the element is removed from the slice.
 func (es Slice) RemoveIf(f func(Value) bool) {
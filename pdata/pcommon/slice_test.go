@@ -106,6 +106,27 @@ func TestSlice_MoveAndAppendTo(t *testing.T) {
 	}
 }
 
+func TestSlice_MoveAndAppendFrom(t *testing.T) {
+	// Test MoveAndAppendFrom without sources
+	expectedSlice := Slice(internal.GenerateTestSlice())
+	dest := NewSlice()
+	dest.MoveAndAppendFrom()
+	assert.Equal(t, 0, dest.Len())
+
+	// Test MoveAndAppendFrom empty and not empty slices
+	srcs := []Slice{Slice(internal.GenerateTestSlice()), NewSlice(), Slice(internal.GenerateTestSlice())}
+	dest.MoveAndAppendFrom(srcs...)
+	assert.Equal(t, 2*expectedSlice.Len(), dest.Len())
+	assert.Equal(t, 2*expectedSlice.Len(), cap(*dest.getOrig()))
+	for _, src := range srcs {
+		assert.Equal(t, 0, src.Len())
+	}
+	for i := 0; i < expectedSlice.Len(); i++ {
+		assert.Equal(t, expectedSlice.At(i), dest.At(i))
+		assert.Equal(t, expectedSlice.At(i), dest.At(i+expectedSlice.Len()))
+	}
+}
+
 func TestSlice_RemoveIf(t *testing.T) {
 	// Test RemoveIf on empty slice
 	emptySlice := NewSlice()
@@ -0,0 +1,248 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plog // import "go.opentelemetry.io/collector/pdata/plog"
+
+// ChunkOption limits the chunks returned by a ChunkIterator.
+type ChunkOption func(*chunkOptions)
+
+type chunkOptions struct {
+	maxItems int
+	maxBytes int
+	sizer    DeltaSizer
+}
+
+// WithMaxItems limits the chunks to n log records.  It is ignored when n
+// is less than or equal to zero.
+func WithMaxItems(n int) ChunkOption {
+	return func(o *chunkOptions) {
+		o.maxItems = n
+	}
+}
+
+// WithMaxBytes limits the chunks to a marshaled size of maxBytes, as
+// computed by sizer.
+func WithMaxBytes(maxBytes int, sizer DeltaSizer) ChunkOption {
+	return func(o *chunkOptions) {
+		o.maxBytes = maxBytes
+		o.sizer = sizer
+	}
+}
+
+// ChunkIterator returns the log records of a Logs in successive chunks
+// within limits, moving them out of the Logs.  Unlike repeated calls to
+// Split or SplitSize, which remove the log records from the front of the
+// Logs every time, it keeps its position in the Logs, so that every
+// resource, scope and log record is only visited once.
+//
+// The chunks are filled as SplitSize does: a chunk holds the log records
+// of the Logs in order, as many as fit in the limits, and the first log
+// record of a chunk is returned alone, along with its resource and scope,
+// when it exceeds them.  The concatenation of the chunks is the original
+// sequence of log records.
+type ChunkIterator struct {
+	opts  chunkOptions
+	ld    Logs
+	chunk Logs
+
+	// rl, sl and record are the position in ld of the next log record to
+	// return, the log records before it being moved out.
+	rl, sl, record int
+
+	// size and items are those of the chunk being filled, full is set
+	// once a log record does not fit in it.
+	size, items int
+	full        bool
+}
+
+// Chunks returns a ChunkIterator over the log records of ld.  ld must not
+// be used until Next returns false, leaving ld empty, or Stop is called.
+// Without any limit, all of the log records are returned in a single
+// chunk.
+//
+//	it := plog.Chunks(ld, plog.WithMaxItems(n))
+//	for it.Next() {
+//		chunk := it.Chunk()
+//		...
+//	}
+func Chunks(ld Logs, opts ...ChunkOption) *ChunkIterator {
+	it := &ChunkIterator{ld: ld}
+	for _, opt := range opts {
+		opt(&it.opts)
+	}
+	return it
+}
+
+// Next moves the next chunk out of the Logs, returning false when it is
+// empty.  The chunk is a new Logs, sharing no data with the log records
+// not returned yet, and remains valid after the following calls.
+func (it *ChunkIterator) Next() bool {
+	rls := it.ld.ResourceLogs()
+	if it.rl >= rls.Len() {
+		it.chunk = Logs{}
+		it.Stop()
+		return false
+	}
+	it.chunk = NewLogs()
+	it.size, it.items, it.full = 0, 0, false
+	for !it.full && it.rl < rls.Len() {
+		if it.nextResource(rls.At(it.rl)) {
+			it.rl, it.sl, it.record = it.rl+1, 0, 0
+		}
+	}
+	return true
+}
+
+// Chunk returns the chunk moved out by the last call to Next.
+func (it *ChunkIterator) Chunk() Logs {
+	return it.chunk
+}
+
+// Stop ends the iteration, removing the log records already returned from
+// the Logs, which is left with the others and can be used again.  Next may
+// be called again to resume the iteration.
+func (it *ChunkIterator) Stop() {
+	i := -1
+	it.ld.ResourceLogs().RemoveIf(func(rl ResourceLogs) bool {
+		i++
+		if i != it.rl {
+			return i < it.rl
+		}
+		j := -1
+		rl.ScopeLogs().RemoveIf(func(sl ScopeLogs) bool {
+			j++
+			if j != it.sl {
+				return j < it.sl
+			}
+			k := -1
+			sl.LogRecords().RemoveIf(func(LogRecord) bool {
+				k++
+				return k < it.record
+			})
+			return false
+		})
+		return false
+	})
+	it.rl, it.sl, it.record = 0, 0, 0
+}
+
+// fits returns whether items more log records fit in the chunk, once of
+// the given size.
+func (it *ChunkIterator) fits(items, size int) bool {
+	return (it.opts.maxItems <= 0 || it.items+items <= it.opts.maxItems) &&
+		(it.opts.sizer == nil || size <= it.opts.maxBytes)
+}
+
+// nextResource moves the log records of srcRl that fit to the chunk,
+// returning whether all of them were moved.
+func (it *ChunkIterator) nextResource(srcRl ResourceLogs) bool {
+	if it.sl == 0 && it.record == 0 {
+		// If it fully fits, or holds no log record to make progress with.
+		srcRlSize := fieldSize(it.resourceLogsSize(srcRl))
+		srcRlLC := srcRl.LogRecordCount()
+		if it.fits(srcRlLC, it.size+srcRlSize) || (it.items == 0 && srcRlLC == 0) {
+			it.size += srcRlSize
+			it.items += srcRlLC
+			srcRl.MoveTo(it.chunk.ResourceLogs().AppendEmpty())
+			return true
+		}
+	}
+
+	sls := srcRl.ScopeLogs()
+	if sls.Len() == 0 {
+		// Left for the next chunk.
+		it.full = true
+		return false
+	}
+	// The resource is copied to the chunk, rlSize is its size there.
+	destRl := NewResourceLogs()
+	srcRl.Resource().CopyTo(destRl.Resource())
+	destRl.SetSchemaUrl(srcRl.SchemaUrl())
+	rlSize := it.resourceLogsSize(destRl)
+	for !it.full && it.sl < sls.Len() {
+		if it.nextScope(&rlSize, destRl, sls.At(it.sl)) {
+			it.sl, it.record = it.sl+1, 0
+		}
+	}
+	if destRl.ScopeLogs().Len() > 0 {
+		it.size += fieldSize(rlSize)
+		destRl.MoveTo(it.chunk.ResourceLogs().AppendEmpty())
+	}
+	return it.sl == sls.Len()
+}
+
+// nextScope moves the log records of srcSl that fit to destRl, of size
+// rlSize, returning whether all of them were moved.
+func (it *ChunkIterator) nextScope(rlSize *int, destRl ResourceLogs, srcSl ScopeLogs) bool {
+	records := srcSl.LogRecords()
+	if it.record == 0 {
+		srcSlSize := fieldSize(it.scopeLogsSize(srcSl))
+		if it.fits(records.Len(), it.size+fieldSize(*rlSize+srcSlSize)) || (it.items == 0 && records.Len() == 0) {
+			*rlSize += srcSlSize
+			it.items += records.Len()
+			srcSl.MoveTo(destRl.ScopeLogs().AppendEmpty())
+			return true
+		}
+	}
+
+	if records.Len() == 0 {
+		// Left for the next chunk.
+		it.full = true
+		return false
+	}
+	destSl := NewScopeLogs()
+	srcSl.Scope().CopyTo(destSl.Scope())
+	destSl.SetSchemaUrl(srcSl.SchemaUrl())
+	slSize := it.scopeLogsSize(destSl)
+	// The log records are moved without allocating new ones, leaving nil in
+	// ld until Stop removes them.
+	srcOrig, destOrig := *records.orig, destSl.LogRecords().orig
+	for ; it.record < records.Len(); it.record++ {
+		recordSize := fieldSize(it.recordSize(records.At(it.record)))
+		if !it.fits(1, it.size+fieldSize(*rlSize+fieldSize(slSize+recordSize))) && it.items > 0 {
+			it.full = true
+			break
+		}
+		slSize += recordSize
+		it.items++
+		*destOrig = append(*destOrig, srcOrig[it.record])
+		srcOrig[it.record] = nil
+	}
+	if destSl.LogRecords().Len() > 0 {
+		*rlSize += fieldSize(slSize)
+		destSl.MoveTo(destRl.ScopeLogs().AppendEmpty())
+	}
+	return it.record == records.Len()
+}
+
+func (it *ChunkIterator) resourceLogsSize(rl ResourceLogs) int {
+	if it.opts.sizer == nil {
+		return 0
+	}
+	return it.opts.sizer.ResourceLogsSize(rl)
+}
+
+func (it *ChunkIterator) scopeLogsSize(sl ScopeLogs) int {
+	if it.opts.sizer == nil {
+		return 0
+	}
+	return it.opts.sizer.ScopeLogsSize(sl)
+}
+
+func (it *ChunkIterator) recordSize(lr LogRecord) int {
+	if it.opts.sizer == nil {
+		return 0
+	}
+	return it.opts.sizer.LogRecordSize(lr)
+}
@@ -0,0 +1,210 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plog
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// allChunks returns the chunks of ld.
+func allChunks(ld Logs, opts ...ChunkOption) []Logs {
+	var chunks []Logs
+	for it := Chunks(ld, opts...); it.Next(); {
+		chunks = append(chunks, it.Chunk())
+	}
+	return chunks
+}
+
+func TestChunksMaxItems(t *testing.T) {
+	for _, n := range []int{1, 3, 10, 29, 90, 1000} {
+		ld := splitTestLogs(3, 3, 10)
+		expected := splitTestLogs(3, 3, 10)
+		for _, chunk := range allChunks(ld, WithMaxItems(n)) {
+			assertSameLogs(t, Split(expected, n), chunk)
+		}
+		assert.Equal(t, 0, expected.ResourceLogs().Len())
+		assert.Equal(t, 0, ld.ResourceLogs().Len())
+	}
+}
+
+func TestChunksMaxBytes(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	total := sizer.LogsSize(splitSizeTestLogs(3, 3, 10))
+	for maxBytes := 0; maxBytes <= total+10; maxBytes += 37 {
+		ld := splitSizeTestLogs(3, 3, 10)
+		expected := splitSizeTestLogs(3, 3, 10)
+		for _, chunk := range allChunks(ld, WithMaxBytes(maxBytes, sizer)) {
+			assertSameLogs(t, SplitSize(expected, maxBytes, sizer), chunk)
+		}
+		assert.Equal(t, 0, expected.ResourceLogs().Len())
+		assert.Equal(t, 0, ld.ResourceLogs().Len())
+	}
+}
+
+func TestChunksWithoutLimits(t *testing.T) {
+	ld := splitTestLogs(2, 2, 5)
+	chunks := allChunks(ld)
+	require.Len(t, chunks, 1)
+	assertSameLogs(t, splitTestLogs(2, 2, 5), chunks[0])
+	assert.Empty(t, allChunks(NewLogs()))
+}
+
+func TestChunksItemExceedsBudget(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	ld := splitTestLogs(1, 3, 1)
+	ld.ResourceLogs().At(0).Resource().Attributes().PutStr("padding", strings.Repeat("x", 1000))
+
+	// Every log record is returned alone, with the resource.
+	var names [][]string
+	for _, chunk := range allChunks(ld, WithMaxBytes(100, sizer)) {
+		names = append(names, splitLogRecordNames(t, chunk))
+		assert.Equal(t, 2, chunk.ResourceLogs().At(0).Resource().Attributes().Len())
+	}
+	assert.Equal(t, [][]string{{"0-0-0"}, {"0-1-0"}, {"0-2-0"}}, names)
+}
+
+func TestChunksEmptyContainers(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	for _, maxBytes := range []int{0, 50, 100, 1000} {
+		ld := splitTestLogs(3, 2, 2)
+		ld.ResourceLogs().At(0).ScopeLogs().At(1).LogRecords().RemoveIf(func(LogRecord) bool { return true })
+		ld.ResourceLogs().At(1).ScopeLogs().RemoveIf(func(ScopeLogs) bool { return true })
+
+		// The resource and scope without log records are kept, once.
+		var emptyResources, emptyScopes []string
+		for _, chunk := range allChunks(ld, WithMaxBytes(maxBytes, sizer)) {
+			for i := 0; i < chunk.ResourceLogs().Len(); i++ {
+				rs := chunk.ResourceLogs().At(i)
+				if rs.ScopeLogs().Len() == 0 {
+					emptyResources = append(emptyResources, rs.SchemaUrl())
+				}
+				for j := 0; j < rs.ScopeLogs().Len(); j++ {
+					if sl := rs.ScopeLogs().At(j); sl.LogRecords().Len() == 0 {
+						emptyScopes = append(emptyScopes, sl.Scope().Name())
+					}
+				}
+			}
+		}
+		assert.Equal(t, []string{"https://1"}, emptyResources)
+		assert.Equal(t, []string{"0-1"}, emptyScopes)
+	}
+}
+
+func TestChunksIndependent(t *testing.T) {
+	ld := splitTestLogs(1, 1, 10)
+	it := Chunks(ld, WithMaxItems(3))
+	require.True(t, it.Next())
+	first := it.Chunk()
+
+	// Changing a chunk does not change the log records not returned yet.
+	first.ResourceLogs().At(0).Resource().Attributes().PutStr("resource", "changed")
+	first.ResourceLogs().At(0).ScopeLogs().At(0).Scope().SetName("changed")
+	first.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().AppendEmpty()
+	var names []string
+	for it.Next() {
+		names = append(names, splitLogRecordNames(t, it.Chunk())...)
+	}
+	assert.Equal(t, []string{"0-0-3", "0-0-4", "0-0-5", "0-0-6", "0-0-7", "0-0-8", "0-0-9"}, names)
+	assert.Equal(t, 4, first.LogRecordCount())
+}
+
+func TestChunksStop(t *testing.T) {
+	ld := splitTestLogs(2, 2, 3)
+	it := Chunks(ld, WithMaxItems(4))
+	require.True(t, it.Next())
+	assert.Equal(t, []string{"0-0-0", "0-0-1", "0-0-2", "0-1-0"}, splitLogRecordNames(t, it.Chunk()))
+	require.True(t, it.Next())
+	assert.Equal(t, []string{"0-1-1", "0-1-2", "1-0-0", "1-0-1"}, splitLogRecordNames(t, it.Chunk()))
+	it.Stop()
+	assert.Equal(t, []string{"1-0-2", "1-1-0", "1-1-1", "1-1-2"}, splitLogRecordNames(t, ld))
+	assert.Equal(t, 4, ld.LogRecordCount())
+
+	// The iteration resumes with the remaining log records.
+	require.True(t, it.Next())
+	assert.Equal(t, []string{"1-0-2", "1-1-0", "1-1-1", "1-1-2"}, splitLogRecordNames(t, it.Chunk()))
+	assert.False(t, it.Next())
+	assert.Equal(t, 0, ld.ResourceLogs().Len())
+}
+
+func TestChunksOrder(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	sizer := &ProtoMarshaler{}
+	for i := 0; i < 100; i++ {
+		ld := randomSplitTestLogs(r)
+		all := splitLogRecordNames(t, ld)
+		maxItems, maxBytes := 1+r.Intn(10), r.Intn(1000)
+		names := []string{}
+		for _, chunk := range allChunks(ld, WithMaxItems(maxItems), WithMaxBytes(maxBytes, sizer)) {
+			chunkNames := splitLogRecordNames(t, chunk)
+			require.NotEmpty(t, chunkNames)
+			assert.LessOrEqual(t, len(chunkNames), maxItems)
+			if len(chunkNames) > 1 {
+				assert.LessOrEqual(t, sizer.LogsSize(chunk), maxBytes)
+			}
+			names = append(names, chunkNames...)
+		}
+		assert.Equal(t, all, names)
+	}
+}
+
+func benchmarkChunks(b *testing.B, chunks func(ld Logs)) {
+	ld := splitTestLogs(10, 10, 1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		cp := NewLogs()
+		ld.CopyTo(cp)
+		b.StartTimer()
+		chunks(cp)
+	}
+}
+
+func BenchmarkChunks(b *testing.B) {
+	benchmarkChunks(b, func(ld Logs) {
+		for it := Chunks(ld, WithMaxItems(100)); it.Next(); {
+		}
+	})
+}
+
+func BenchmarkChunksSplit(b *testing.B) {
+	benchmarkChunks(b, func(ld Logs) {
+		for ld.LogRecordCount() > 0 {
+			Split(ld, 100)
+		}
+	})
+}
+
+func BenchmarkChunksMaxBytes(b *testing.B) {
+	sizer := &ProtoMarshaler{}
+	benchmarkChunks(b, func(ld Logs) {
+		for it := Chunks(ld, WithMaxBytes(64*1024, sizer)); it.Next(); {
+		}
+	})
+}
+
+func BenchmarkChunksSplitSize(b *testing.B) {
+	sizer := &ProtoMarshaler{}
+	benchmarkChunks(b, func(ld Logs) {
+		for ld.LogRecordCount() > 0 {
+			SplitSize(ld, 64*1024, sizer)
+		}
+	})
+}
@@ -40,3 +40,31 @@ type Sizer interface {
 	// LogsSize returns the size in bytes of a marshaled Logs.
 	LogsSize(ld Logs) int
 }
+
+// DeltaSizer is an optional interface implemented by the Sizer, that
+// calculates the size of the parts of a marshaled Logs, as needed to
+// split it by size.  Callers holding a Sizer detect it with a type
+// assertion.
+type DeltaSizer interface {
+	Sizer
+
+	// ResourceLogsSize returns the size in bytes of a marshaled ResourceLogs, without
+	// the tag and length of its field in its parent.
+	ResourceLogsSize(rl ResourceLogs) int
+
+	// ScopeLogsSize returns the size in bytes of a marshaled ScopeLogs, without
+	// the tag and length of its field in its parent.
+	ScopeLogsSize(sl ScopeLogs) int
+
+	// LogRecordSize returns the size in bytes of a marshaled LogRecord, without
+	// the tag and length of its field in its parent.
+	LogRecordSize(lr LogRecord) int
+
+	// LogRecordDeltaSize returns the size a LogRecord adds to a marshaled
+	// ScopeLogs, with the tag and length of its field.
+	LogRecordDeltaSize(lr LogRecord) int
+
+	// LogsOverheadSize returns the size of a marshaled Logs beyond the
+	// LogRecordDeltaSize of its log records.
+	LogsOverheadSize(ld Logs) int
+}
@@ -101,6 +101,32 @@ func TestLogRecordSlice_MoveAndAppendTo(t *testing.T) {
 	}
 }
 
+func TestLogRecordSlice_MoveAndAppendFrom(t *testing.T) {
+	// Test MoveAndAppendFrom without sources
+	expectedSlice := generateTestLogRecordSlice()
+	dest := NewLogRecordSlice()
+	dest.MoveAndAppendFrom()
+	assert.Equal(t, 0, dest.Len())
+
+	// Test MoveAndAppendFrom empty and not empty slices
+	srcs := []LogRecordSlice{generateTestLogRecordSlice(), NewLogRecordSlice(), generateTestLogRecordSlice()}
+	dest.MoveAndAppendFrom(srcs...)
+	assert.Equal(t, 2*expectedSlice.Len(), dest.Len())
+	assert.Equal(t, 2*expectedSlice.Len(), cap(*dest.orig))
+	for _, src := range srcs {
+		assert.Equal(t, 0, src.Len())
+	}
+	for i := 0; i < expectedSlice.Len(); i++ {
+		assert.Equal(t, expectedSlice.At(i), dest.At(i))
+		assert.Equal(t, expectedSlice.At(i), dest.At(i+expectedSlice.Len()))
+	}
+
+	// Test MoveAndAppendFrom to not empty slice
+	dest.MoveAndAppendFrom(generateTestLogRecordSlice())
+	assert.Equal(t, 3*expectedSlice.Len(), dest.Len())
+	assert.Equal(t, expectedSlice.At(0), dest.At(2*expectedSlice.Len()))
+}
+
 func TestLogRecordSlice_RemoveIf(t *testing.T) {
 	// Test RemoveIf on empty slice
 	emptySlice := NewLogRecordSlice()
@@ -106,6 +106,20 @@ func (es ResourceLogsSlice) MoveAndAppendTo(dest ResourceLogsSlice) {
 	*es.orig = nil
 }
 
+// MoveAndAppendFrom moves all elements from each of the srcs, in order, and appends them
+// to the current slice, growing it at most once.  The srcs will be cleared.
+func (es ResourceLogsSlice) MoveAndAppendFrom(srcs ...ResourceLogsSlice) {
+	newLen := len(*es.orig)
+	for _, src := range srcs {
+		newLen += len(*src.orig)
+	}
+	es.EnsureCapacity(newLen)
+	for _, src := range srcs {
+		*es.orig = append(*es.orig, *src.orig...)
+		*src.orig = nil
+	}
+}
+
 // RemoveIf calls f sequentially for each element present in the slice.
 // If f returns true, the element is removed from the slice.
 func (es ResourceLogsSlice) RemoveIf(f func(ResourceLogs) bool) {
@@ -101,6 +101,32 @@ func TestScopeLogsSlice_MoveAndAppendTo(t *testing.T) {
 	}
 }
 
+func TestScopeLogsSlice_MoveAndAppendFrom(t *testing.T) {
+	// Test MoveAndAppendFrom without sources
+	expectedSlice := generateTestScopeLogsSlice()
+	dest := NewScopeLogsSlice()
+	dest.MoveAndAppendFrom()
+	assert.Equal(t, 0, dest.Len())
+
+	// Test MoveAndAppendFrom empty and not empty slices
+	srcs := []ScopeLogsSlice{generateTestScopeLogsSlice(), NewScopeLogsSlice(), generateTestScopeLogsSlice()}
+	dest.MoveAndAppendFrom(srcs...)
+	assert.Equal(t, 2*expectedSlice.Len(), dest.Len())
+	assert.Equal(t, 2*expectedSlice.Len(), cap(*dest.orig))
+	for _, src := range srcs {
+		assert.Equal(t, 0, src.Len())
+	}
+	for i := 0; i < expectedSlice.Len(); i++ {
+		assert.Equal(t, expectedSlice.At(i), dest.At(i))
+		assert.Equal(t, expectedSlice.At(i), dest.At(i+expectedSlice.Len()))
+	}
+
+	// Test MoveAndAppendFrom to not empty slice
+	dest.MoveAndAppendFrom(generateTestScopeLogsSlice())
+	assert.Equal(t, 3*expectedSlice.Len(), dest.Len())
+	assert.Equal(t, expectedSlice.At(0), dest.At(2*expectedSlice.Len()))
+}
+
 func TestScopeLogsSlice_RemoveIf(t *testing.T) {
 	// Test RemoveIf on empty slice
 	emptySlice := NewScopeLogsSlice()
@@ -24,7 +24,7 @@ import (
 type Logs internal.Logs
 
 func newLogs(orig *otlpcollectorlog.ExportLogsServiceRequest) Logs {
-	return Logs(internal.NewLogs(orig))
+	return Logs(internal.NewLogs(orig, new(internal.State)))
 }
 
 func (ms Logs) getOrig() *otlpcollectorlog.ExportLogsServiceRequest {
@@ -41,21 +41,92 @@ func (ms Logs) CopyTo(dest Logs) {
 	ms.ResourceLogs().CopyTo(dest.ResourceLogs())
 }
 
+// MarkReadOnly marks ms as shared, e.g. by a fan-out to several
+// consumers, so that the consumers needing to mutate it work on a copy,
+// obtained with AsMutable.  The mark is kept by the copies of the Logs
+// value, and cannot be removed.  It is not enforced by the methods
+// mutating ms.
+func (ms Logs) MarkReadOnly() {
+	internal.GetLogsState(internal.Logs(ms)).MarkReadOnly()
+}
+
+// IsReadOnly returns whether ms was marked read-only.
+func (ms Logs) IsReadOnly() bool {
+	return internal.GetLogsState(internal.Logs(ms)).IsReadOnly()
+}
+
+// AsMutable returns ms when it is not marked read-only, and otherwise a
+// mutable copy of it, sharing no data with ms.
+func (ms Logs) AsMutable() Logs {
+	if !ms.IsReadOnly() {
+		return ms
+	}
+	dest := NewLogs()
+	ms.CopyTo(dest)
+	return dest
+}
+
+// Reset removes all the data of ms, keeping the capacity of its
+// ResourceLogs slice so that ms can be filled again without growing it.
+// The removed data is no longer referenced by ms.  Reset must only be
+// called once ms is no longer used elsewhere.
+func (ms Logs) Reset() {
+	orig := ms.getOrig()
+	for i := range orig.ResourceLogs {
+		orig.ResourceLogs[i] = nil
+	}
+	orig.ResourceLogs = orig.ResourceLogs[:0]
+}
+
 // LogRecordCount calculates the total number of log records.
 func (ms Logs) LogRecordCount() int {
 	logCount := 0
-	rss := ms.ResourceLogs()
-	for i := 0; i < rss.Len(); i++ {
-		rs := rss.At(i)
-		ill := rs.ScopeLogs()
-		for i := 0; i < ill.Len(); i++ {
-			logs := ill.At(i)
-			logCount += logs.LogRecords().Len()
-		}
+	rls := ms.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		logCount += rls.At(i).LogRecordCount()
 	}
 	return logCount
 }
 
+// LogRecordCount calculates the number of log records of all the scopes
+// of the ResourceLogs.
+func (ms ResourceLogs) LogRecordCount() int {
+	logCount := 0
+	sls := ms.ScopeLogs()
+	for i := 0; i < sls.Len(); i++ {
+		logCount += sls.At(i).LogRecordCount()
+	}
+	return logCount
+}
+
+// LogRecordCount returns the number of log records of the ScopeLogs.
+func (ms ScopeLogs) LogRecordCount() int {
+	return ms.LogRecords().Len()
+}
+
+// RemoveEmpty removes the ScopeLogs having no log records, then the
+// ResourceLogs left with no scopes, such as the shells left behind by a
+// split or a filter.  It returns the number of entries removed, scopes and
+// resources together.  The order of the remaining entries is kept.
+func (ms Logs) RemoveEmpty() int {
+	removed := 0
+	ms.ResourceLogs().RemoveIf(func(rl ResourceLogs) bool {
+		rl.ScopeLogs().RemoveIf(func(sl ScopeLogs) bool {
+			if sl.LogRecords().Len() == 0 {
+				removed++
+				return true
+			}
+			return false
+		})
+		if rl.ScopeLogs().Len() == 0 {
+			removed++
+			return true
+		}
+		return false
+	})
+	return removed
+}
+
 // ResourceLogs returns the ResourceLogsSlice associated with this Logs.
 func (ms Logs) ResourceLogs() ResourceLogsSlice {
 	return newResourceLogsSlice(&ms.getOrig().ResourceLogs)
@@ -48,6 +48,14 @@ func TestLogRecordCount(t *testing.T) {
 	}
 	// 5 + 1 (from rms.At(0) initialized first)
 	assert.EqualValues(t, 6, logs.LogRecordCount())
+
+	// Each resource and scope counts its own log records.
+	assert.EqualValues(t, 1, rms.At(0).LogRecordCount())
+	assert.EqualValues(t, 0, rms.At(1).LogRecordCount())
+	assert.EqualValues(t, 0, rms.At(1).ScopeLogs().At(0).LogRecordCount())
+	assert.EqualValues(t, 5, rms.At(2).LogRecordCount())
+	assert.EqualValues(t, 5, rms.At(2).ScopeLogs().At(0).LogRecordCount())
+	assert.EqualValues(t, 0, NewResourceLogs().LogRecordCount())
 }
 
 func TestLogRecordCountWithEmpty(t *testing.T) {
@@ -123,3 +131,94 @@ func TestLogsCopyTo(t *testing.T) {
 	logs.CopyTo(logsCopy)
 	assert.EqualValues(t, logs, logsCopy)
 }
+
+func TestLogsReset(t *testing.T) {
+	logs := NewLogs()
+	fillTestResourceLogsSlice(logs.ResourceLogs())
+	rs := logs.ResourceLogs().At(0)
+	capacity := cap(logs.getOrig().ResourceLogs)
+	logs.Reset()
+	assert.Equal(t, 0, logs.ResourceLogs().Len())
+	assert.Equal(t, capacity, cap(logs.getOrig().ResourceLogs))
+	assert.Nil(t, logs.getOrig().ResourceLogs[:1][0])
+
+	// The removed data is unchanged, and not shared with the data
+	// added again.
+	expected := NewLogs()
+	fillTestResourceLogsSlice(expected.ResourceLogs())
+	assert.Equal(t, expected.ResourceLogs().At(0), rs)
+	logs.ResourceLogs().AppendEmpty()
+	assert.NotEqual(t, logs.ResourceLogs().At(0), rs)
+}
+
+func TestLogsRemoveEmpty(t *testing.T) {
+	logs := NewLogs()
+	assert.Equal(t, 0, logs.RemoveEmpty())
+
+	// Shells nested at every level, around two non-empty scopes.
+	rs := logs.ResourceLogs().AppendEmpty()
+	rs.Resource().Attributes().PutStr("name", "first")
+	rs.ScopeLogs().AppendEmpty()
+	rs.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr("a")
+	rs.ScopeLogs().AppendEmpty()
+	logs.ResourceLogs().AppendEmpty()
+	rs = logs.ResourceLogs().AppendEmpty()
+	rs.ScopeLogs().AppendEmpty()
+	rs.ScopeLogs().AppendEmpty()
+	rs = logs.ResourceLogs().AppendEmpty()
+	rs.Resource().Attributes().PutStr("name", "last")
+	rs.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr("b")
+
+	expected := NewLogs()
+	rs = expected.ResourceLogs().AppendEmpty()
+	rs.Resource().Attributes().PutStr("name", "first")
+	rs.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr("a")
+	rs = expected.ResourceLogs().AppendEmpty()
+	rs.Resource().Attributes().PutStr("name", "last")
+	rs.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr("b")
+
+	assert.Equal(t, 6, logs.RemoveEmpty())
+	assert.Equal(t, expected, logs)
+	assert.Equal(t, 0, logs.RemoveEmpty())
+}
+
+func TestLogsReadOnly(t *testing.T) {
+	logs := NewLogs()
+	assert.False(t, logs.IsReadOnly())
+	logs.MarkReadOnly()
+	assert.True(t, logs.IsReadOnly())
+
+	logsCopy := NewLogs()
+	logs.CopyTo(logsCopy)
+	assert.False(t, logsCopy.IsReadOnly())
+
+	// A zero-initialized value, as returned on unmarshal errors, is left as is.
+	assert.NotPanics(t, func() { Logs{}.MarkReadOnly() })
+	assert.False(t, Logs{}.IsReadOnly())
+}
+
+func TestLogsAsMutable(t *testing.T) {
+	logs := NewLogs()
+	fillTestResourceLogsSlice(logs.ResourceLogs())
+	assert.False(t, logs.IsReadOnly())
+	assert.Same(t, logs.getOrig(), logs.AsMutable().getOrig())
+
+	shared := logs
+	logs.MarkReadOnly()
+	assert.True(t, shared.IsReadOnly())
+	mutable := logs.AsMutable()
+	assert.False(t, mutable.IsReadOnly())
+	assert.True(t, logs.IsReadOnly())
+	assert.NotSame(t, logs.getOrig(), mutable.getOrig())
+	assert.Equal(t, logs.ResourceLogs(), mutable.ResourceLogs())
+
+	// The copy does not alias the data of the original.
+	assert.NotSame(t, &logs.getOrig().ResourceLogs[0], &mutable.getOrig().ResourceLogs[0])
+	assert.NotSame(t, logs.getOrig().ResourceLogs[0], mutable.getOrig().ResourceLogs[0])
+	item := mutable.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+	original := logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Body().Str()
+	item.Body().SetStr("changed")
+	assert.Equal(t, original, logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Body().Str())
+	mutable.ResourceLogs().AppendEmpty()
+	assert.Equal(t, logs.ResourceLogs().Len()+1, mutable.ResourceLogs().Len())
+}
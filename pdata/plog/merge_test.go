@@ -0,0 +1,185 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plog
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mergeTestLogs returns traces of a ResourceLogs for every resource
+// attributes, holding a ScopeLogs with a log record for every scope name.
+func mergeTestLogs(t testing.TB, resources []map[string]any, scopes ...string) Logs {
+	td := NewLogs()
+	for _, attrs := range resources {
+		rs := td.ResourceLogs().AppendEmpty()
+		require.NoError(t, rs.Resource().Attributes().FromRaw(attrs))
+		for _, scope := range scopes {
+			ss := rs.ScopeLogs().AppendEmpty()
+			ss.Scope().SetName(scope)
+			ss.LogRecords().AppendEmpty().Body().SetStr(fmt.Sprintf("%v/%s", attrs, scope))
+		}
+	}
+	return td
+}
+
+// mergeLogRecordNames returns the bodies of the log records of every ScopeLogs of
+// td, by the index of its ResourceLogs.
+func mergeLogRecordNames(td Logs) [][][]string {
+	var names [][][]string
+	for i := 0; i < td.ResourceLogs().Len(); i++ {
+		var scopes [][]string
+		sss := td.ResourceLogs().At(i).ScopeLogs()
+		for j := 0; j < sss.Len(); j++ {
+			var records []string
+			for k := 0; k < sss.At(j).LogRecords().Len(); k++ {
+				records = append(records, sss.At(j).LogRecords().At(k).Body().Str())
+			}
+			scopes = append(scopes, records)
+		}
+		names = append(names, scopes)
+	}
+	return names
+}
+
+func TestMergeInto(t *testing.T) {
+	dst := mergeTestLogs(t, []map[string]any{{"a": 1, "b": "x"}}, "s")
+	// The same attributes in another order, and other ones.
+	src := NewLogs()
+	rs := src.ResourceLogs().AppendEmpty()
+	rs.Resource().Attributes().PutStr("b", "x")
+	rs.Resource().Attributes().PutInt("a", 1)
+	for _, scope := range []string{"t", "s"} {
+		ss := rs.ScopeLogs().AppendEmpty()
+		ss.Scope().SetName(scope)
+		ss.LogRecords().AppendEmpty().Body().SetStr("src/" + scope)
+	}
+	mergeTestLogs(t, []map[string]any{{"a": 2}}, "s").ResourceLogs().MoveAndAppendTo(src.ResourceLogs())
+
+	MergeInto(dst, src)
+	assert.Equal(t, 0, src.ResourceLogs().Len())
+	assert.Equal(t, [][][]string{
+		{{"map[a:1 b:x]/s", "src/s"}, {"src/t"}},
+		{{"map[a:2]/s"}},
+	}, mergeLogRecordNames(dst))
+	assert.Equal(t, map[string]any{"a": int64(1), "b": "x"}, dst.ResourceLogs().At(0).Resource().Attributes().AsRaw())
+	assert.Equal(t, "t", dst.ResourceLogs().At(0).ScopeLogs().At(1).Scope().Name())
+}
+
+func TestMergeIntoSourceDuplicates(t *testing.T) {
+	dst := NewLogs()
+	src := mergeTestLogs(t, []map[string]any{{"a": 1}, {"a": 2}, {"a": 1}}, "s", "t", "s")
+	MergeInto(dst, src)
+	assert.Equal(t, [][][]string{
+		{{"map[a:1]/s", "map[a:1]/s", "map[a:1]/s", "map[a:1]/s"}, {"map[a:1]/t", "map[a:1]/t"}},
+		{{"map[a:2]/s", "map[a:2]/s"}, {"map[a:2]/t"}},
+	}, mergeLogRecordNames(dst))
+}
+
+func TestMergeIntoDestinationDuplicates(t *testing.T) {
+	// The entries already in dst are kept.
+	dst := mergeTestLogs(t, []map[string]any{{"a": 1}, {"a": 1}}, "s", "s")
+	MergeInto(dst, mergeTestLogs(t, []map[string]any{{"a": 1}}, "s"))
+	assert.Equal(t, [][][]string{
+		{{"map[a:1]/s", "map[a:1]/s"}, {"map[a:1]/s"}},
+		{{"map[a:1]/s"}, {"map[a:1]/s"}},
+	}, mergeLogRecordNames(dst))
+}
+
+func TestMergeIntoIdentity(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(rs ResourceLogs)
+	}{
+		{
+			name:   "resource schema URL",
+			modify: func(rs ResourceLogs) { rs.SetSchemaUrl("https://other") },
+		},
+		{
+			name:   "resource attributes",
+			modify: func(rs ResourceLogs) { rs.Resource().Attributes().PutStr("b", "y") },
+		},
+		{
+			name:   "resource dropped attributes count",
+			modify: func(rs ResourceLogs) { rs.Resource().SetDroppedAttributesCount(1) },
+		},
+		{
+			name:   "scope schema URL",
+			modify: func(rs ResourceLogs) { rs.ScopeLogs().At(0).SetSchemaUrl("https://other") },
+		},
+		{
+			name:   "scope version",
+			modify: func(rs ResourceLogs) { rs.ScopeLogs().At(0).Scope().SetVersion("2") },
+		},
+		{
+			name:   "scope attributes",
+			modify: func(rs ResourceLogs) { rs.ScopeLogs().At(0).Scope().Attributes().PutStr("b", "y") },
+		},
+		{
+			name:   "scope dropped attributes count",
+			modify: func(rs ResourceLogs) { rs.ScopeLogs().At(0).Scope().SetDroppedAttributesCount(1) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := mergeTestLogs(t, []map[string]any{{"a": 1}}, "s")
+			src := mergeTestLogs(t, []map[string]any{{"a": 1}}, "s")
+			tt.modify(src.ResourceLogs().At(0))
+			MergeInto(dst, src)
+			assert.Equal(t, 2, dst.LogRecordCount())
+			assert.Equal(t, 1, dst.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().Len())
+		})
+	}
+}
+
+func TestMergeIntoWithoutDeduplication(t *testing.T) {
+	dst := mergeTestLogs(t, []map[string]any{{"a": 1}}, "s")
+	src := mergeTestLogs(t, []map[string]any{{"a": 1}}, "s")
+	MergeInto(dst, src, WithoutDeduplication())
+	assert.Equal(t, 0, src.ResourceLogs().Len())
+	assert.Equal(t, [][][]string{{{"map[a:1]/s"}}, {{"map[a:1]/s"}}}, mergeLogRecordNames(dst))
+}
+
+func benchmarkMergeInto(b *testing.B, opts ...MergeOption) {
+	resources := make([]map[string]any, 5)
+	for i := range resources {
+		resources[i] = map[string]any{"service.name": fmt.Sprint("service-", i), "host.name": "node-1"}
+	}
+	requests := make([]Logs, 100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		for j := range requests {
+			requests[j] = mergeTestLogs(b, resources, "s", "t")
+		}
+		b.StartTimer()
+		dst := NewLogs()
+		for _, src := range requests {
+			MergeInto(dst, src, opts...)
+		}
+	}
+}
+
+func BenchmarkMergeInto(b *testing.B) {
+	benchmarkMergeInto(b)
+}
+
+func BenchmarkMergeIntoWithoutDeduplication(b *testing.B) {
+	benchmarkMergeInto(b, WithoutDeduplication())
+}
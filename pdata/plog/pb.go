@@ -20,6 +20,7 @@ import (
 )
 
 var _ MarshalSizer = (*ProtoMarshaler)(nil)
+var _ DeltaSizer = (*ProtoMarshaler)(nil)
 
 type ProtoMarshaler struct{}
 
@@ -28,6 +29,9 @@ func (e *ProtoMarshaler) MarshalLogs(ld Logs) ([]byte, error) {
 	return pb.Marshal()
 }
 
+// LogsSize returns the size of the marshaled ld.  It is computed on each
+// call: ld can be changed through the wrappers of its parts, which do not
+// reference ld, so that a cached size could not be invalidated.
 func (e *ProtoMarshaler) LogsSize(ld Logs) int {
 	pb := internal.LogsToProto(internal.Logs(ld))
 	return pb.Size()
@@ -35,6 +39,50 @@ func (e *ProtoMarshaler) LogsSize(ld Logs) int {
 
 var _ Unmarshaler = (*ProtoUnmarshaler)(nil)
 
+func (e *ProtoMarshaler) ResourceLogsSize(rl ResourceLogs) int {
+	return rl.orig.Size()
+}
+
+func (e *ProtoMarshaler) ScopeLogsSize(sl ScopeLogs) int {
+	return sl.orig.Size()
+}
+
+func (e *ProtoMarshaler) LogRecordSize(lr LogRecord) int {
+	return lr.orig.Size()
+}
+
+// LogRecordDeltaSize returns the size a log record adds to a marshaled
+// ScopeLogs: its LogRecordSize along with the tag and length prefixing
+// it, so that the sizes of log records can be summed.
+func (e *ProtoMarshaler) LogRecordDeltaSize(lr LogRecord) int {
+	return fieldSize(lr.orig.Size())
+}
+
+// LogsOverheadSize returns the size of the marshaled ld beyond the
+// LogRecordDeltaSize of its log records: that of its resources and
+// scopes, and of the tags and lengths prefixing them, so that
+//
+//	LogsSize(ld) = LogsOverheadSize(ld) + Σ LogRecordDeltaSize(lr)
+//
+// The lengths prefixing the resources and scopes grow with their log
+// records, a byte each time they cross 127 bytes, 16383 bytes, and so
+// on, so the overhead of the same resources and scopes holding other log
+// records is only approximated by it.
+func (e *ProtoMarshaler) LogsOverheadSize(ld Logs) int {
+	size := e.LogsSize(ld)
+	rls := ld.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		sls := rls.At(i).ScopeLogs()
+		for j := 0; j < sls.Len(); j++ {
+			lrs := sls.At(j).LogRecords()
+			for k := 0; k < lrs.Len(); k++ {
+				size -= e.LogRecordDeltaSize(lrs.At(k))
+			}
+		}
+	}
+	return size
+}
+
 type ProtoUnmarshaler struct{}
 
 func (d *ProtoUnmarshaler) UnmarshalLogs(buf []byte) (Logs, error) {
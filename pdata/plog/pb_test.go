@@ -15,6 +15,7 @@
 package plog
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -48,6 +49,127 @@ func TestProtoSizerEmptyLogs(t *testing.T) {
 	assert.Equal(t, 0, sizer.LogsSize(NewLogs()))
 }
 
+func TestProtoSizerMutations(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(ld Logs)
+	}{
+		{
+			name: "append",
+			mutate: func(ld Logs) {
+				ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().AppendEmpty().SetSeverityText("a longer severity text")
+			},
+		},
+		{
+			name: "remove",
+			mutate: func(ld Logs) {
+				ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().RemoveIf(func(LogRecord) bool { return true })
+			},
+		},
+		{
+			name: "attribute",
+			mutate: func(ld Logs) {
+				ld.ResourceLogs().At(0).Resource().Attributes().PutStr("host", "a")
+			},
+		},
+		{
+			name: "field",
+			mutate: func(ld Logs) {
+				ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).SetSeverityText("a longer severity text")
+			},
+		},
+	}
+	sizer := &ProtoMarshaler{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The size follows the changes made through the parts of
+			// the data, read-only or not.
+			for _, readOnly := range []bool{false, true} {
+				ld := generateBenchmarkLogs(2)
+				if readOnly {
+					ld.MarkReadOnly()
+				}
+				size := sizer.LogsSize(ld)
+				tt.mutate(ld)
+				assert.NotEqual(t, size, sizer.LogsSize(ld))
+				assert.Equal(t, ld.getOrig().Size(), sizer.LogsSize(ld))
+				buf, err := sizer.MarshalLogs(ld)
+				require.NoError(t, err)
+				assert.Len(t, buf, sizer.LogsSize(ld))
+			}
+		})
+	}
+}
+
+// varintLen returns the length of the varint encoding of v.
+func varintLen(v int) int {
+	switch {
+	case v < 1<<7:
+		return 1
+	case v < 1<<14:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func TestProtoSizerLogRecordDeltaSize(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	// The sizes of the log records, and of their prefixes, cross 127 and
+	// 16383 bytes.
+	sizes := map[int]bool{}
+	for _, lengths := range [][2]int{{100, 140}, {16350, 16400}} {
+		for l := lengths[0]; l < lengths[1]; l++ {
+			sl := NewScopeLogs()
+			empty := sizer.ScopeLogsSize(sl)
+			lr := sl.LogRecords().AppendEmpty()
+			lr.Body().SetStr(strings.Repeat("x", l))
+
+			size := sizer.LogRecordSize(lr)
+			sizes[size] = true
+			assert.Equal(t, size+1+varintLen(size), sizer.LogRecordDeltaSize(lr))
+			assert.Equal(t, sizer.ScopeLogsSize(sl)-empty, sizer.LogRecordDeltaSize(lr))
+		}
+	}
+	for _, size := range []int{127, 128, 16383, 16384} {
+		assert.True(t, sizes[size], "size %d", size)
+	}
+}
+
+func TestProtoSizerLogsOverheadSize(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	ld := NewLogs()
+	for r, length := range []int{10, 200, 20000} {
+		rl := ld.ResourceLogs().AppendEmpty()
+		rl.Resource().Attributes().PutInt("resource", int64(r))
+		for s := 0; s < 2; s++ {
+			sl := rl.ScopeLogs().AppendEmpty()
+			sl.Scope().SetName("scope")
+			sl.LogRecords().AppendEmpty().Body().SetStr(strings.Repeat("x", length))
+			sl.LogRecords().AppendEmpty().Body().SetStr("log")
+		}
+	}
+
+	overhead := sizer.LogsOverheadSize(ld)
+	itemsSize := 0
+	shape := NewLogs()
+	ld.CopyTo(shape)
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		sls := ld.ResourceLogs().At(i).ScopeLogs()
+		for j := 0; j < sls.Len(); j++ {
+			for k := 0; k < sls.At(j).LogRecords().Len(); k++ {
+				itemsSize += sizer.LogRecordDeltaSize(sls.At(j).LogRecords().At(k))
+			}
+			shape.ResourceLogs().At(i).ScopeLogs().At(j).LogRecords().RemoveIf(func(LogRecord) bool { return true })
+		}
+	}
+	assert.Equal(t, sizer.LogsSize(ld), overhead+itemsSize)
+	// The lengths prefixing the resources and scopes of the larger log records
+	// take more bytes once the log records are added: a byte for the scopes and
+	// resource of 200 bytes log records, and two for those of 20000 bytes.
+	assert.Equal(t, sizer.LogsSize(shape)+3*1+3*2, overhead)
+}
+
 func BenchmarkLogsToProto(b *testing.B) {
 	marshaler := &ProtoMarshaler{}
 	logs := generateBenchmarkLogs(128)
@@ -75,6 +197,18 @@ func BenchmarkLogsFromProto(b *testing.B) {
 	}
 }
 
+func BenchmarkLogsSize(b *testing.B) {
+	ld := generateBenchmarkLogs(128)
+	sizer := &ProtoMarshaler{}
+	size := 0
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		size = sizer.LogsSize(ld)
+	}
+	assert.NotEqual(b, 0, size)
+}
+
 func generateBenchmarkLogs(logsCount int) Logs {
 	endTime := pcommon.NewTimestampFromTime(time.Now())
 
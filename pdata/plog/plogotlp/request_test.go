@@ -20,6 +20,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"go.opentelemetry.io/collector/pdata/plog"
 )
 
 var _ json.Unmarshaler = ExportRequest{}
@@ -64,3 +66,14 @@ func TestRequestJSON(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, strings.Join(strings.Fields(string(logsRequestJSON)), ""), string(got))
 }
+
+func TestRequestReadOnly(t *testing.T) {
+	logs := plog.NewLogs()
+	tr := NewExportRequestFromLogs(logs)
+	logs.MarkReadOnly()
+	assert.True(t, tr.Logs().IsReadOnly())
+
+	tr = NewExportRequest()
+	tr.Logs().MarkReadOnly()
+	assert.True(t, tr.Logs().IsReadOnly())
+}
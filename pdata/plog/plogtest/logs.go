@@ -0,0 +1,192 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package plogtest compares logs in tests.
+package plogtest // import "go.opentelemetry.io/collector/pdata/plog/plogtest"
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.uber.org/multierr"
+
+	"go.opentelemetry.io/collector/pdata/internal/dedup"
+	"go.opentelemetry.io/collector/pdata/internal/match"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// CompareLogsOption configures CompareLogs.
+type CompareLogsOption func(*compareOptions)
+
+type compareOptions struct {
+	ignoreResourceOrder  bool
+	ignoreScopeOrder     bool
+	ignoreLogRecordOrder bool
+}
+
+// IgnoreResourceOrder makes CompareLogs match the resources by their
+// attributes and schema URL, rather than by their position.
+func IgnoreResourceOrder() CompareLogsOption {
+	return func(o *compareOptions) {
+		o.ignoreResourceOrder = true
+	}
+}
+
+// IgnoreScopeOrder makes CompareLogs match the scopes of a resource by
+// their name, version, attributes and schema URL, rather than by their
+// position.
+func IgnoreScopeOrder() CompareLogsOption {
+	return func(o *compareOptions) {
+		o.ignoreScopeOrder = true
+	}
+}
+
+// IgnoreLogRecordOrder makes CompareLogs match the log records of a scope
+// by their timestamps, trace and span IDs, and body, rather than by their
+// position.
+func IgnoreLogRecordOrder() CompareLogsOption {
+	return func(o *compareOptions) {
+		o.ignoreLogRecordOrder = true
+	}
+}
+
+// CompareLogs returns an error listing the differences between expected
+// and actual, or nil when they are equal.  Each difference names the
+// resource, scope and log record it is found in.  Attributes are compared
+// regardless of their order.
+func CompareLogs(expected, actual plog.Logs, opts ...CompareLogsOption) error {
+	var o compareOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	exp, act := expected.ResourceLogs(), actual.ResourceLogs()
+	pairs, missing, unexpected := match.Entries(exp.Len(), act.Len(), o.ignoreResourceOrder, func(i, j int) bool {
+		return sameResource(exp.At(i), act.At(j))
+	})
+	var errs error
+	for _, p := range pairs {
+		e, a := exp.At(p.Expected), act.At(p.Actual)
+		if !sameResource(e, a) {
+			errs = multierr.Append(errs, fmt.Errorf("at index %d: expected %s, actual %s", p.Actual, resourceName(e), resourceName(a)))
+			continue
+		}
+		errs = multierr.Append(errs, prefix(resourceName(e), compareScopes(e.ScopeLogs(), a.ScopeLogs(), o)))
+	}
+	for _, i := range missing {
+		errs = multierr.Append(errs, fmt.Errorf("missing %s", resourceName(exp.At(i))))
+	}
+	for _, j := range unexpected {
+		errs = multierr.Append(errs, fmt.Errorf("unexpected %s", resourceName(act.At(j))))
+	}
+	return errs
+}
+
+func compareScopes(exp, act plog.ScopeLogsSlice, o compareOptions) error {
+	pairs, missing, unexpected := match.Entries(exp.Len(), act.Len(), o.ignoreScopeOrder, func(i, j int) bool {
+		return sameScope(exp.At(i), act.At(j))
+	})
+	var errs error
+	for _, p := range pairs {
+		e, a := exp.At(p.Expected), act.At(p.Actual)
+		if !sameScope(e, a) {
+			errs = multierr.Append(errs, fmt.Errorf("at index %d: expected %s, actual %s", p.Actual, scopeName(e), scopeName(a)))
+			continue
+		}
+		errs = multierr.Append(errs, prefix(scopeName(e), compareLogRecords(e.LogRecords(), a.LogRecords(), o)))
+	}
+	for _, i := range missing {
+		errs = multierr.Append(errs, fmt.Errorf("missing %s", scopeName(exp.At(i))))
+	}
+	for _, j := range unexpected {
+		errs = multierr.Append(errs, fmt.Errorf("unexpected %s", scopeName(act.At(j))))
+	}
+	return errs
+}
+
+func compareLogRecords(exp, act plog.LogRecordSlice, o compareOptions) error {
+	pairs, missing, unexpected := match.Entries(exp.Len(), act.Len(), o.ignoreLogRecordOrder, func(i, j int) bool {
+		return sameLogRecord(exp.At(i), act.At(j))
+	})
+	var errs error
+	for _, p := range pairs {
+		e, a := exp.At(p.Expected), act.At(p.Actual)
+		if !sameLogRecord(e, a) {
+			errs = multierr.Append(errs, fmt.Errorf("at index %d: expected %s, actual %s", p.Actual, logRecordName(e), logRecordName(a)))
+			continue
+		}
+		errs = multierr.Append(errs, prefix(logRecordName(e), compareLogRecord(e, a)))
+	}
+	for _, i := range missing {
+		errs = multierr.Append(errs, fmt.Errorf("missing %s", logRecordName(exp.At(i))))
+	}
+	for _, j := range unexpected {
+		errs = multierr.Append(errs, fmt.Errorf("unexpected %s", logRecordName(act.At(j))))
+	}
+	return errs
+}
+
+// compareLogRecord compares the fields of two log records of the same
+// identity.
+func compareLogRecord(e, a plog.LogRecord) error {
+	var errs error
+	field := func(name string, e, a any) {
+		if !reflect.DeepEqual(e, a) {
+			errs = multierr.Append(errs, fmt.Errorf("%s: expected %v, actual %v", name, e, a))
+		}
+	}
+	field("severity number", e.SeverityNumber(), a.SeverityNumber())
+	field("severity text", e.SeverityText(), a.SeverityText())
+	field("flags", e.Flags(), a.Flags())
+	field("attributes", e.Attributes().AsRaw(), a.Attributes().AsRaw())
+	field("dropped attributes count", e.DroppedAttributesCount(), a.DroppedAttributesCount())
+	return errs
+}
+
+func sameResource(e, a plog.ResourceLogs) bool {
+	return dedup.ResourcesEqual(e.Resource(), e.SchemaUrl(), a.Resource(), a.SchemaUrl())
+}
+
+func sameScope(e, a plog.ScopeLogs) bool {
+	return dedup.ScopesEqual(e.Scope(), e.SchemaUrl(), a.Scope(), a.SchemaUrl())
+}
+
+func sameLogRecord(e, a plog.LogRecord) bool {
+	return e.Timestamp() == a.Timestamp() &&
+		e.ObservedTimestamp() == a.ObservedTimestamp() &&
+		e.TraceID() == a.TraceID() &&
+		e.SpanID() == a.SpanID() &&
+		reflect.DeepEqual(e.Body().AsRaw(), a.Body().AsRaw())
+}
+
+func resourceName(rs plog.ResourceLogs) string {
+	return fmt.Sprintf("resource %v", rs.Resource().Attributes().AsRaw())
+}
+
+func scopeName(ss plog.ScopeLogs) string {
+	return fmt.Sprintf("scope %q %q", ss.Scope().Name(), ss.Scope().Version())
+}
+
+func logRecordName(lr plog.LogRecord) string {
+	return fmt.Sprintf("log record %q (timestamp %d, observed timestamp %d, trace ID %s, span ID %s)",
+		lr.Body().AsString(), lr.Timestamp(), lr.ObservedTimestamp(), lr.TraceID(), lr.SpanID())
+}
+
+// prefix prefixes each of the errors of errs with name.
+func prefix(name string, errs error) error {
+	var prefixed error
+	for _, err := range multierr.Errors(errs) {
+		prefixed = multierr.Append(prefixed, fmt.Errorf("%s: %w", name, err))
+	}
+	return prefixed
+}
@@ -0,0 +1,138 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plogtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// testLogs returns logs of two resources, of two scopes of two log
+// records each.
+func testLogs() plog.Logs {
+	ld := plog.NewLogs()
+	for _, host := range []string{"a", "b"} {
+		rl := ld.ResourceLogs().AppendEmpty()
+		rl.Resource().Attributes().PutStr("host", host)
+		rl.Resource().Attributes().PutStr("service", "test")
+		for _, scope := range []string{"x", "y"} {
+			sl := rl.ScopeLogs().AppendEmpty()
+			sl.Scope().SetName(scope)
+			for i := 1; i <= 2; i++ {
+				lr := sl.LogRecords().AppendEmpty()
+				lr.Body().SetStr(host + scope)
+				lr.SetTimestamp(pcommon.Timestamp(i))
+				lr.Attributes().PutInt("index", int64(i))
+			}
+		}
+	}
+	return ld
+}
+
+func TestCompareLogsEqual(t *testing.T) {
+	assert.NoError(t, CompareLogs(testLogs(), testLogs()))
+	assert.NoError(t, CompareLogs(plog.NewLogs(), plog.NewLogs()))
+
+	// Attributes are compared regardless of their order.
+	actual := testLogs()
+	attrs := actual.ResourceLogs().At(0).Resource().Attributes()
+	attrs.Clear()
+	attrs.PutStr("service", "test")
+	attrs.PutStr("host", "a")
+	assert.NoError(t, CompareLogs(testLogs(), actual))
+}
+
+func TestCompareLogsIgnoreOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		reorder func(ld plog.Logs)
+		option  CompareLogsOption
+	}{
+		{
+			name: "resources",
+			reorder: func(ld plog.Logs) {
+				rls := ld.ResourceLogs()
+				rl := plog.NewResourceLogs()
+				rls.At(0).MoveTo(rl)
+				rls.RemoveIf(func(rl plog.ResourceLogs) bool { return rl.ScopeLogs().Len() == 0 })
+				rl.MoveTo(rls.AppendEmpty())
+			},
+			option: IgnoreResourceOrder(),
+		},
+		{
+			name: "scopes",
+			reorder: func(ld plog.Logs) {
+				sls := ld.ResourceLogs().At(0).ScopeLogs()
+				sl := plog.NewScopeLogs()
+				sls.At(0).MoveTo(sl)
+				sls.RemoveIf(func(sl plog.ScopeLogs) bool { return sl.LogRecords().Len() == 0 })
+				sl.MoveTo(sls.AppendEmpty())
+			},
+			option: IgnoreScopeOrder(),
+		},
+		{
+			name: "log records",
+			reorder: func(ld plog.Logs) {
+				lrs := ld.ResourceLogs().At(1).ScopeLogs().At(1).LogRecords()
+				lr := plog.NewLogRecord()
+				lrs.At(0).MoveTo(lr)
+				lrs.RemoveIf(func(lr plog.LogRecord) bool { return lr.Timestamp() == 0 })
+				lr.MoveTo(lrs.AppendEmpty())
+			},
+			option: IgnoreLogRecordOrder(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := testLogs()
+			tt.reorder(actual)
+			assert.Error(t, CompareLogs(testLogs(), actual))
+			assert.NoError(t, CompareLogs(testLogs(), actual, tt.option))
+		})
+	}
+}
+
+func TestCompareLogsPinpoints(t *testing.T) {
+	actual := testLogs()
+	actual.ResourceLogs().At(1).ScopeLogs().At(0).LogRecords().At(1).Attributes().PutInt("index", 3)
+	actual.ResourceLogs().At(0).ScopeLogs().At(1).LogRecords().At(0).SetSeverityText("ERROR")
+	assert.EqualError(t, CompareLogs(testLogs(), actual, IgnoreResourceOrder(), IgnoreScopeOrder(), IgnoreLogRecordOrder()),
+		`resource map[host:a service:test]: scope "y" "": log record "ay" (timestamp 1, observed timestamp 0, trace ID , span ID ): severity text: expected , actual ERROR; `+
+			`resource map[host:b service:test]: scope "x" "": log record "bx" (timestamp 2, observed timestamp 0, trace ID , span ID ): attributes: expected map[index:2], actual map[index:3]`)
+}
+
+func TestCompareLogsMissingUnexpected(t *testing.T) {
+	actual := testLogs()
+	actual.ResourceLogs().At(0).Resource().Attributes().PutStr("host", "c")
+	lrs := actual.ResourceLogs().At(1).ScopeLogs().At(0).LogRecords()
+	lrs.At(0).Body().SetStr("changed")
+
+	err := CompareLogs(testLogs(), actual, IgnoreResourceOrder(), IgnoreLogRecordOrder())
+	require.Error(t, err)
+	assert.EqualError(t, err, `resource map[host:b service:test]: scope "x" "": missing log record "bx" (timestamp 1, observed timestamp 0, trace ID , span ID ); `+
+		`resource map[host:b service:test]: scope "x" "": unexpected log record "changed" (timestamp 1, observed timestamp 0, trace ID , span ID ); `+
+		`missing resource map[host:a service:test]; `+
+		`unexpected resource map[host:c service:test]`)
+
+	// In order, the entries are compared by position.
+	err = CompareLogs(testLogs(), actual)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "at index 0: expected resource map[host:a service:test], actual resource map[host:c service:test]")
+}
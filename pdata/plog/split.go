@@ -0,0 +1,190 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plog // import "go.opentelemetry.io/collector/pdata/plog"
+
+import (
+	"github.com/gogo/protobuf/proto"
+)
+
+// Split removes the first n log records of ld, in order, and returns them
+// in a new Logs.  The log records are moved, not copied: ld is left with
+// the remaining log records only.  A ResourceLogs or ScopeLogs whose log
+// records are cut between both halves has its resource, scope and schema
+// URL copied, so that both halves keep them, and the containers left
+// empty in ld are removed.
+//
+// The order of the log records is kept across resources and scopes: the
+// returned log records are in their order in ld, and ld keeps the
+// remaining ones in their order, so that the concatenation of the
+// results of repeated splits is the original sequence.
+//
+// If n is less than or equal to zero an empty Logs is returned and ld is
+// unchanged.  If n is greater than or equal to the number of log records
+// of ld, all of them are moved, leaving ld empty.
+func Split(ld Logs, n int) Logs {
+	dest := NewLogs()
+	if n <= 0 {
+		return dest
+	}
+	if ld.LogRecordCount() <= n {
+		ld.ResourceLogs().MoveAndAppendTo(dest.ResourceLogs())
+		return dest
+	}
+
+	moved := 0
+	ld.ResourceLogs().RemoveIf(func(srcRl ResourceLogs) bool {
+		// If we are done skip everything else.
+		if moved == n {
+			return false
+		}
+
+		// If it fully fits
+		srcRlLRC := srcRl.LogRecordCount()
+		if moved+srcRlLRC <= n {
+			moved += srcRlLRC
+			srcRl.MoveTo(dest.ResourceLogs().AppendEmpty())
+			return true
+		}
+
+		destRl := dest.ResourceLogs().AppendEmpty()
+		srcRl.Resource().CopyTo(destRl.Resource())
+		destRl.SetSchemaUrl(srcRl.SchemaUrl())
+		srcRl.ScopeLogs().RemoveIf(func(srcSl ScopeLogs) bool {
+			// If we are done skip everything else.
+			if moved == n {
+				return false
+			}
+
+			// If possible to move all log records do that.
+			srcSlLRC := srcSl.LogRecords().Len()
+			if moved+srcSlLRC <= n {
+				moved += srcSlLRC
+				srcSl.MoveTo(destRl.ScopeLogs().AppendEmpty())
+				return true
+			}
+
+			destSl := destRl.ScopeLogs().AppendEmpty()
+			srcSl.Scope().CopyTo(destSl.Scope())
+			destSl.SetSchemaUrl(srcSl.SchemaUrl())
+			srcSl.LogRecords().RemoveIf(func(srcLr LogRecord) bool {
+				// If we are done skip everything else.
+				if moved == n {
+					return false
+				}
+				srcLr.MoveTo(destSl.LogRecords().AppendEmpty())
+				moved++
+				return true
+			})
+			return false
+		})
+		return srcRl.ScopeLogs().Len() == 0
+	})
+
+	return dest
+}
+
+// SplitSize removes the first log records of ld, in order, up to a
+// marshaled size of maxBytes as computed by sizer, and returns them in a
+// new Logs, as Split does.  The size of each resource, scope and log
+// record is computed once, and the envelope of the resources and scopes
+// copied to both halves is accounted for as encoded in the OTLP protobuf:
+// the returned Logs is the largest prefix of ld whose LogsSize does not
+// exceed maxBytes.
+//
+// The first log record of ld is returned alone, along with its resource
+// and scope, when they exceed maxBytes, so that ld can be split until
+// empty.  If ld does not exceed maxBytes, all of its log records are
+// moved, leaving ld empty.
+func SplitSize(ld Logs, maxBytes int, sizer DeltaSizer) Logs {
+	dest := NewLogs()
+	// size is the size of dest, moved is its number of log records, and
+	// done is set once a log record does not fit.
+	size, moved, done := 0, 0, false
+	ld.ResourceLogs().RemoveIf(func(srcRl ResourceLogs) bool {
+		if done {
+			return false
+		}
+
+		// If it fully fits, or holds no log record to make progress with.
+		srcRlSize := fieldSize(sizer.ResourceLogsSize(srcRl))
+		srcRlLRC := srcRl.LogRecordCount()
+		if size+srcRlSize <= maxBytes || (moved == 0 && srcRlLRC == 0) {
+			size += srcRlSize
+			moved += srcRlLRC
+			srcRl.MoveTo(dest.ResourceLogs().AppendEmpty())
+			return true
+		}
+
+		// The resource is copied to both halves, rlSize is its size in
+		// dest.
+		destRl := NewResourceLogs()
+		srcRl.Resource().CopyTo(destRl.Resource())
+		destRl.SetSchemaUrl(srcRl.SchemaUrl())
+		rlSize := sizer.ResourceLogsSize(destRl)
+		srcRl.ScopeLogs().RemoveIf(func(srcSl ScopeLogs) bool {
+			if done {
+				return false
+			}
+
+			srcSlSize := fieldSize(sizer.ScopeLogsSize(srcSl))
+			if size+fieldSize(rlSize+srcSlSize) <= maxBytes || (moved == 0 && srcSl.LogRecords().Len() == 0) {
+				rlSize += srcSlSize
+				moved += srcSl.LogRecords().Len()
+				srcSl.MoveTo(destRl.ScopeLogs().AppendEmpty())
+				return true
+			}
+
+			destSl := NewScopeLogs()
+			srcSl.Scope().CopyTo(destSl.Scope())
+			destSl.SetSchemaUrl(srcSl.SchemaUrl())
+			slSize := sizer.ScopeLogsSize(destSl)
+			srcSl.LogRecords().RemoveIf(func(srcLr LogRecord) bool {
+				if done {
+					return false
+				}
+				lrSize := fieldSize(sizer.LogRecordSize(srcLr))
+				if size+fieldSize(rlSize+fieldSize(slSize+lrSize)) > maxBytes {
+					done = true
+					if moved > 0 {
+						return false
+					}
+				}
+				slSize += lrSize
+				moved++
+				srcLr.MoveTo(destSl.LogRecords().AppendEmpty())
+				return true
+			})
+			if destSl.LogRecords().Len() > 0 {
+				rlSize += fieldSize(slSize)
+				destSl.MoveTo(destRl.ScopeLogs().AppendEmpty())
+			}
+			done = true
+			return srcSl.LogRecords().Len() == 0
+		})
+		if destRl.ScopeLogs().Len() > 0 {
+			size += fieldSize(rlSize)
+			destRl.MoveTo(dest.ResourceLogs().AppendEmpty())
+		}
+		return srcRl.ScopeLogs().Len() == 0
+	})
+
+	return dest
+}
+
+// fieldSize returns the size of a message of size bytes as a field of its
+// parent, with its tag and length.
+func fieldSize(size int) int {
+	return 1 + proto.SizeVarint(uint64(size)) + size
+}
@@ -0,0 +1,275 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plog
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// splitTestLogs returns logs of resources of scopes of log records each,
+// the bodies of the log records after the indexes of their resource, scope and
+// position, and the resources and scopes after those of their log records.
+func splitTestLogs(resources, scopes, records int) Logs {
+	ld := NewLogs()
+	for r := 0; r < resources; r++ {
+		rl := ld.ResourceLogs().AppendEmpty()
+		rl.Resource().Attributes().PutStr("resource", fmt.Sprint(r))
+		rl.SetSchemaUrl(fmt.Sprintf("https://%d", r))
+		for s := 0; s < scopes; s++ {
+			sl := rl.ScopeLogs().AppendEmpty()
+			sl.Scope().SetName(fmt.Sprintf("%d-%d", r, s))
+			sl.SetSchemaUrl(fmt.Sprintf("https://%d-%d", r, s))
+			for i := 0; i < records; i++ {
+				sl.LogRecords().AppendEmpty().Body().SetStr(fmt.Sprintf("%d-%d-%d", r, s, i))
+			}
+		}
+	}
+	return ld
+}
+
+// splitLogRecordNames returns the names of the log records of ld, in order, and
+// checks that each log record is under the resource and scope it was created
+// with.
+func splitLogRecordNames(t *testing.T, ld Logs) []string {
+	names := []string{}
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		rl := ld.ResourceLogs().At(i)
+		r, ok := rl.Resource().Attributes().Get("resource")
+		require.True(t, ok)
+		assert.Equal(t, "https://"+r.Str(), rl.SchemaUrl())
+		require.NotZero(t, rl.ScopeLogs().Len())
+		for j := 0; j < rl.ScopeLogs().Len(); j++ {
+			sl := rl.ScopeLogs().At(j)
+			assert.True(t, strings.HasPrefix(sl.Scope().Name(), r.Str()+"-"))
+			assert.Equal(t, "https://"+sl.Scope().Name(), sl.SchemaUrl())
+			require.NotZero(t, sl.LogRecords().Len())
+			for k := 0; k < sl.LogRecords().Len(); k++ {
+				name := sl.LogRecords().At(k).Body().Str()
+				assert.True(t, strings.HasPrefix(name, sl.Scope().Name()+"-"))
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func TestSplit(t *testing.T) {
+	all := splitLogRecordNames(t, splitTestLogs(2, 2, 5))
+	for _, n := range []int{-1, 0, 1, 3, 5, 7, 10, 13, 19, 20, 25} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			ld := splitTestLogs(2, 2, 5)
+			split := Split(ld, n)
+
+			moved := n
+			if moved < 0 {
+				moved = 0
+			} else if moved > len(all) {
+				moved = len(all)
+			}
+			assert.Equal(t, moved, split.LogRecordCount())
+			assert.Equal(t, len(all)-moved, ld.LogRecordCount())
+			assert.Equal(t, all[:moved], splitLogRecordNames(t, split))
+			assert.Equal(t, all[moved:], splitLogRecordNames(t, ld))
+		})
+	}
+}
+
+func TestSplitUnchanged(t *testing.T) {
+	ld := splitTestLogs(2, 2, 5)
+	split := Split(ld, 0)
+	assert.Equal(t, NewLogs(), split)
+	assert.Equal(t, splitTestLogs(2, 2, 5), ld)
+}
+
+func TestSplitAll(t *testing.T) {
+	ld := splitTestLogs(2, 2, 5)
+	split := Split(ld, 20)
+	assert.Equal(t, splitTestLogs(2, 2, 5), split)
+	assert.Equal(t, 0, ld.ResourceLogs().Len())
+}
+
+func TestSplitRepeated(t *testing.T) {
+	ld := splitTestLogs(3, 2, 5)
+	all := splitLogRecordNames(t, ld)
+	names := []string{}
+	for ld.LogRecordCount() > 0 {
+		split := Split(ld, 4)
+		assert.LessOrEqual(t, split.LogRecordCount(), 4)
+		names = append(names, splitLogRecordNames(t, split)...)
+	}
+	assert.Equal(t, all, names)
+	assert.Equal(t, 0, ld.ResourceLogs().Len())
+}
+
+// splitSizeTestLogs returns the logs of splitTestLogs, with log records
+// of various sizes.
+func splitSizeTestLogs(resources, scopes, records int) Logs {
+	ld := splitTestLogs(resources, scopes, records)
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		sls := ld.ResourceLogs().At(i).ScopeLogs()
+		for j := 0; j < sls.Len(); j++ {
+			for k := 0; k < sls.At(j).LogRecords().Len(); k++ {
+				sls.At(j).LogRecords().At(k).Attributes().PutStr("padding", strings.Repeat("x", (i*7+j*3+k*11)%50))
+			}
+		}
+	}
+	return ld
+}
+
+// splitSizeByMarshaling is the naive SplitSize, binary searching the
+// number of log records to split by sizing the candidates.
+func splitSizeByMarshaling(ld Logs, maxBytes int, sizer DeltaSizer) Logs {
+	lo, hi := 1, ld.LogRecordCount()
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		candidate := NewLogs()
+		ld.CopyTo(candidate)
+		if sizer.LogsSize(Split(candidate, mid)) <= maxBytes {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return Split(ld, lo)
+}
+
+// assertSameLogs asserts that expected and actual marshal the same.
+func assertSameLogs(t *testing.T, expected, actual Logs) {
+	marshaler := &ProtoMarshaler{}
+	expectedBuf, err := marshaler.MarshalLogs(expected)
+	require.NoError(t, err)
+	actualBuf, err := marshaler.MarshalLogs(actual)
+	require.NoError(t, err)
+	require.Equal(t, expectedBuf, actualBuf)
+}
+
+func TestSplitSize(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	total := sizer.LogsSize(splitSizeTestLogs(3, 3, 10))
+	for maxBytes := 0; maxBytes <= total+10; maxBytes += 7 {
+		ld := splitSizeTestLogs(3, 3, 10)
+		split := SplitSize(ld, maxBytes, sizer)
+
+		expectedLd := splitSizeTestLogs(3, 3, 10)
+		expected := splitSizeByMarshaling(expectedLd, maxBytes, sizer)
+		assertSameLogs(t, expected, split)
+		assertSameLogs(t, expectedLd, ld)
+		if split.LogRecordCount() > 1 {
+			assert.LessOrEqual(t, sizer.LogsSize(split), maxBytes)
+		}
+	}
+}
+
+func TestSplitSizeRepeated(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	ld := splitSizeTestLogs(3, 3, 10)
+	all := splitLogRecordNames(t, ld)
+	names := []string{}
+	for ld.LogRecordCount() > 0 {
+		split := SplitSize(ld, 500, sizer)
+		assert.LessOrEqual(t, sizer.LogsSize(split), 500)
+		names = append(names, splitLogRecordNames(t, split)...)
+	}
+	assert.Equal(t, all, names)
+	assert.Equal(t, 0, ld.ResourceLogs().Len())
+}
+
+func TestSplitSizeItemExceedsBudget(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	ld := splitTestLogs(1, 3, 1)
+	ld.ResourceLogs().At(0).Resource().Attributes().PutStr("padding", strings.Repeat("x", 1000))
+
+	// The envelope alone exceeds the budget, the first log record is returned
+	// with it.
+	split := SplitSize(ld, 100, sizer)
+	assert.Equal(t, []string{"0-0-0"}, splitLogRecordNames(t, split))
+	assert.Equal(t, []string{"0-1-0", "0-2-0"}, splitLogRecordNames(t, ld))
+	padding, ok := ld.ResourceLogs().At(0).Resource().Attributes().Get("padding")
+	require.True(t, ok)
+	assert.Len(t, padding.Str(), 1000)
+}
+
+func TestSplitSizeAll(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	ld := splitSizeTestLogs(2, 2, 5)
+	split := SplitSize(ld, sizer.LogsSize(ld), sizer)
+	assert.Equal(t, splitSizeTestLogs(2, 2, 5), split)
+	assert.Equal(t, 0, ld.ResourceLogs().Len())
+}
+
+// randomSplitTestLogs returns logs named as by splitTestLogs, of
+// random numbers of resources, scopes and log records.
+func randomSplitTestLogs(r *rand.Rand) Logs {
+	ld := splitSizeTestLogs(1+r.Intn(5), 4, 10)
+	ld.ResourceLogs().RemoveIf(func(rl ResourceLogs) bool {
+		rl.ScopeLogs().RemoveIf(func(sl ScopeLogs) bool {
+			sl.LogRecords().RemoveIf(func(LogRecord) bool { return r.Intn(3) == 0 })
+			return sl.LogRecords().Len() == 0
+		})
+		return rl.ScopeLogs().Len() == 0
+	})
+	return ld
+}
+
+func TestSplitOrder(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	sizer := &ProtoMarshaler{}
+	for i := 0; i < 100; i++ {
+		ld := randomSplitTestLogs(r)
+		all := splitLogRecordNames(t, ld)
+		names := []string{}
+		for ld.LogRecordCount() > 0 {
+			var split Logs
+			if r.Intn(2) == 0 {
+				split = Split(ld, 1+r.Intn(10))
+			} else {
+				split = SplitSize(ld, r.Intn(1000), sizer)
+			}
+			names = append(names, splitLogRecordNames(t, split)...)
+			require.Equal(t, all[len(names):], splitLogRecordNames(t, ld))
+		}
+		assert.Equal(t, all, names)
+	}
+}
+
+func benchmarkSplitSize(b *testing.B, split func(Logs, int, DeltaSizer) Logs) {
+	sizer := &ProtoMarshaler{}
+	ld := splitSizeTestLogs(10, 10, 100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		cp := NewLogs()
+		ld.CopyTo(cp)
+		b.StartTimer()
+		for cp.LogRecordCount() > 0 {
+			split(cp, 64*1024, sizer)
+		}
+	}
+}
+
+func BenchmarkSplitSize(b *testing.B) {
+	benchmarkSplitSize(b, SplitSize)
+}
+
+func BenchmarkSplitSizeByMarshaling(b *testing.B) {
+	benchmarkSplitSize(b, splitSizeByMarshaling)
+}
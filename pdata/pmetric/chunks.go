@@ -0,0 +1,382 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pmetric // import "go.opentelemetry.io/collector/pdata/pmetric"
+
+// ChunkOption limits the chunks returned by a ChunkIterator.
+type ChunkOption func(*chunkOptions)
+
+type chunkOptions struct {
+	maxItems int
+	maxBytes int
+	sizer    DeltaSizer
+}
+
+// WithMaxItems limits the chunks to n data points.  It is ignored when n
+// is less than or equal to zero.
+func WithMaxItems(n int) ChunkOption {
+	return func(o *chunkOptions) {
+		o.maxItems = n
+	}
+}
+
+// WithMaxBytes limits the chunks to a marshaled size of maxBytes, as
+// computed by sizer.
+func WithMaxBytes(maxBytes int, sizer DeltaSizer) ChunkOption {
+	return func(o *chunkOptions) {
+		o.maxBytes = maxBytes
+		o.sizer = sizer
+	}
+}
+
+// ChunkIterator returns the data points of a Metrics in successive chunks
+// within limits, moving them out of the Metrics.  Unlike repeated calls
+// to Split or SplitSize, which remove the data points from the front of
+// the Metrics every time, it keeps its position in the Metrics, so that
+// every resource, scope, metric and data point is only visited once.
+//
+// The chunks are filled as SplitSize does: a chunk holds the data points
+// of the Metrics in order, as many as fit in the limits, and the first
+// data point of a chunk is returned alone, along with its resource, scope
+// and metric, when it exceeds them.  The concatenation of the chunks is
+// the original sequence of data points.
+type ChunkIterator struct {
+	opts  chunkOptions
+	md    Metrics
+	chunk Metrics
+
+	// rm, sm, metric and dp are the position in md of the next data
+	// point to return, the data points before it being moved out.
+	rm, sm, metric, dp int
+
+	// size and items are those of the chunk being filled, full is set
+	// once a data point does not fit in it.
+	size, items int
+	full        bool
+}
+
+// Chunks returns a ChunkIterator over the data points of md.  md must not
+// be used until Next returns false, leaving md empty, or Stop is called.
+// Without any limit, all of the data points are returned in a single
+// chunk.
+//
+//	it := pmetric.Chunks(md, pmetric.WithMaxItems(n))
+//	for it.Next() {
+//		chunk := it.Chunk()
+//		...
+//	}
+func Chunks(md Metrics, opts ...ChunkOption) *ChunkIterator {
+	it := &ChunkIterator{md: md}
+	for _, opt := range opts {
+		opt(&it.opts)
+	}
+	return it
+}
+
+// Next moves the next chunk out of the Metrics, returning false when it
+// is empty.  The chunk is a new Metrics, sharing no data with the data
+// points not returned yet, and remains valid after the following calls.
+func (it *ChunkIterator) Next() bool {
+	rms := it.md.ResourceMetrics()
+	if it.rm >= rms.Len() {
+		it.chunk = Metrics{}
+		it.Stop()
+		return false
+	}
+	it.chunk = NewMetrics()
+	it.size, it.items, it.full = 0, 0, false
+	for !it.full && it.rm < rms.Len() {
+		if it.nextResource(rms.At(it.rm)) {
+			it.rm, it.sm, it.metric, it.dp = it.rm+1, 0, 0, 0
+		}
+	}
+	return true
+}
+
+// Chunk returns the chunk moved out by the last call to Next.
+func (it *ChunkIterator) Chunk() Metrics {
+	return it.chunk
+}
+
+// Stop ends the iteration, removing the data points already returned
+// from the Metrics, which is left with the others and can be used again.
+// Next may be called again to resume the iteration.
+func (it *ChunkIterator) Stop() {
+	i := -1
+	it.md.ResourceMetrics().RemoveIf(func(rm ResourceMetrics) bool {
+		i++
+		if i != it.rm {
+			return i < it.rm
+		}
+		j := -1
+		rm.ScopeMetrics().RemoveIf(func(sm ScopeMetrics) bool {
+			j++
+			if j != it.sm {
+				return j < it.sm
+			}
+			k := -1
+			sm.Metrics().RemoveIf(func(ms Metric) bool {
+				k++
+				if k == it.metric {
+					removeDataPoints(ms, it.dp)
+				}
+				return k < it.metric
+			})
+			return false
+		})
+		return false
+	})
+	it.rm, it.sm, it.metric, it.dp = 0, 0, 0, 0
+}
+
+// fits returns whether items more data points fit in the chunk, once of
+// the given size.
+func (it *ChunkIterator) fits(items, size int) bool {
+	return (it.opts.maxItems <= 0 || it.items+items <= it.opts.maxItems) &&
+		(it.opts.sizer == nil || size <= it.opts.maxBytes)
+}
+
+// nextResource moves the data points of srcRm that fit to the chunk,
+// returning whether all of them were moved.
+func (it *ChunkIterator) nextResource(srcRm ResourceMetrics) bool {
+	if it.sm == 0 && it.metric == 0 && it.dp == 0 {
+		// If it fully fits, or holds no data point to make progress
+		// with.
+		srcRmSize := 0
+		if it.opts.sizer != nil {
+			srcRmSize = fieldSize(it.opts.sizer.ResourceMetricsSize(srcRm))
+		}
+		srcRmDPC := srcRm.DataPointCount()
+		if it.fits(srcRmDPC, it.size+srcRmSize) || (it.items == 0 && srcRmDPC == 0) {
+			it.size += srcRmSize
+			it.items += srcRmDPC
+			srcRm.MoveTo(it.chunk.ResourceMetrics().AppendEmpty())
+			return true
+		}
+	}
+
+	sms := srcRm.ScopeMetrics()
+	if sms.Len() == 0 {
+		// Left for the next chunk.
+		it.full = true
+		return false
+	}
+	// The resource is copied to the chunk, rmSize is its size there.
+	destRm := NewResourceMetrics()
+	srcRm.Resource().CopyTo(destRm.Resource())
+	destRm.SetSchemaUrl(srcRm.SchemaUrl())
+	rmSize := 0
+	if it.opts.sizer != nil {
+		rmSize = it.opts.sizer.ResourceMetricsSize(destRm)
+	}
+	for !it.full && it.sm < sms.Len() {
+		if it.nextScope(&rmSize, destRm, sms.At(it.sm)) {
+			it.sm, it.metric, it.dp = it.sm+1, 0, 0
+		}
+	}
+	if destRm.ScopeMetrics().Len() > 0 {
+		it.size += fieldSize(rmSize)
+		destRm.MoveTo(it.chunk.ResourceMetrics().AppendEmpty())
+	}
+	return it.sm == sms.Len()
+}
+
+// nextScope moves the data points of srcSm that fit to destRm, of size
+// rmSize, returning whether all of them were moved.
+func (it *ChunkIterator) nextScope(rmSize *int, destRm ResourceMetrics, srcSm ScopeMetrics) bool {
+	if it.metric == 0 && it.dp == 0 {
+		srcSmSize := 0
+		if it.opts.sizer != nil {
+			srcSmSize = fieldSize(it.opts.sizer.ScopeMetricsSize(srcSm))
+		}
+		srcSmDPC := srcSm.DataPointCount()
+		if it.fits(srcSmDPC, it.size+fieldSize(*rmSize+srcSmSize)) || (it.items == 0 && srcSmDPC == 0) {
+			*rmSize += srcSmSize
+			it.items += srcSmDPC
+			srcSm.MoveTo(destRm.ScopeMetrics().AppendEmpty())
+			return true
+		}
+	}
+
+	metrics := srcSm.Metrics()
+	if metrics.Len() == 0 {
+		// Left for the next chunk.
+		it.full = true
+		return false
+	}
+	destSm := NewScopeMetrics()
+	srcSm.Scope().CopyTo(destSm.Scope())
+	destSm.SetSchemaUrl(srcSm.SchemaUrl())
+	smSize := 0
+	if it.opts.sizer != nil {
+		smSize = it.opts.sizer.ScopeMetricsSize(destSm)
+	}
+	for !it.full && it.metric < metrics.Len() {
+		if it.nextMetric(*rmSize, &smSize, destSm, metrics.At(it.metric)) {
+			it.metric, it.dp = it.metric+1, 0
+		}
+	}
+	if destSm.Metrics().Len() > 0 {
+		*rmSize += fieldSize(smSize)
+		destSm.MoveTo(destRm.ScopeMetrics().AppendEmpty())
+	}
+	return it.metric == metrics.Len()
+}
+
+// nextMetric moves the data points of srcMetric that fit to destSm, of
+// size smSize in a resource of size rmSize, returning whether all of them
+// were moved.
+func (it *ChunkIterator) nextMetric(rmSize int, smSize *int, destSm ScopeMetrics, srcMetric Metric) bool {
+	srcMetricDPC := srcMetric.DataPointCount()
+	if it.dp == 0 {
+		srcMetricSize := 0
+		if it.opts.sizer != nil {
+			srcMetricSize = fieldSize(it.opts.sizer.MetricSize(srcMetric))
+		}
+		if it.fits(srcMetricDPC, it.size+fieldSize(rmSize+fieldSize(*smSize+srcMetricSize))) || (it.items == 0 && srcMetricDPC == 0) {
+			*smSize += srcMetricSize
+			it.items += srcMetricDPC
+			srcMetric.MoveTo(destSm.Metrics().AppendEmpty())
+			return true
+		}
+	}
+
+	if srcMetricDPC == 0 {
+		// Left for the next chunk.
+		it.full = true
+		return false
+	}
+	// The fields of the metric are copied to the chunk, with its empty
+	// gauge, sum, histogram or summary.  metricSize is the size of the
+	// metric without it, and dataSize the size of it.
+	destMetric := NewMetric()
+	copyMetricEnvelope(srcMetric, destMetric)
+	metricSize, dataSize := 0, 0
+	if it.opts.sizer != nil {
+		dataSize = metricDataSize(destMetric)
+		metricSize = it.opts.sizer.MetricSize(destMetric) - fieldSize(dataSize)
+	}
+	// take returns whether a data point of dpSize fits in the chunk,
+	// accounting for it when it does.
+	take := func(dpSize int) bool {
+		dpSize = fieldSize(dpSize)
+		if !it.fits(1, it.size+fieldSize(rmSize+fieldSize(*smSize+fieldSize(metricSize+fieldSize(dataSize+dpSize))))) && it.items > 0 {
+			it.full = true
+			return false
+		}
+		dataSize += dpSize
+		it.items++
+		return true
+	}
+	// The data points are moved without allocating new ones, leaving nil
+	// in md until Stop removes them.
+	switch srcMetric.Type() {
+	case MetricTypeGauge:
+		it.moveNumberDataPoints(srcMetric.Gauge().DataPoints(), destMetric.Gauge().DataPoints(), take)
+	case MetricTypeSum:
+		it.moveNumberDataPoints(srcMetric.Sum().DataPoints(), destMetric.Sum().DataPoints(), take)
+	case MetricTypeHistogram:
+		it.moveHistogramDataPoints(srcMetric.Histogram().DataPoints(), destMetric.Histogram().DataPoints(), take)
+	case MetricTypeExponentialHistogram:
+		it.moveExponentialHistogramDataPoints(srcMetric.ExponentialHistogram().DataPoints(), destMetric.ExponentialHistogram().DataPoints(), take)
+	case MetricTypeSummary:
+		it.moveSummaryDataPoints(srcMetric.Summary().DataPoints(), destMetric.Summary().DataPoints(), take)
+	}
+	if destMetric.DataPointCount() > 0 {
+		*smSize += fieldSize(metricSize + fieldSize(dataSize))
+		destMetric.MoveTo(destSm.Metrics().AppendEmpty())
+	}
+	return it.dp == srcMetricDPC
+}
+
+func (it *ChunkIterator) moveNumberDataPoints(src, dest NumberDataPointSlice, take func(dpSize int) bool) {
+	srcOrig, destOrig := *src.orig, dest.orig
+	for ; it.dp < len(srcOrig); it.dp++ {
+		dpSize := 0
+		if it.opts.sizer != nil {
+			dpSize = it.opts.sizer.NumberDataPointSize(src.At(it.dp))
+		}
+		if !take(dpSize) {
+			return
+		}
+		*destOrig = append(*destOrig, srcOrig[it.dp])
+		srcOrig[it.dp] = nil
+	}
+}
+
+func (it *ChunkIterator) moveHistogramDataPoints(src, dest HistogramDataPointSlice, take func(dpSize int) bool) {
+	srcOrig, destOrig := *src.orig, dest.orig
+	for ; it.dp < len(srcOrig); it.dp++ {
+		dpSize := 0
+		if it.opts.sizer != nil {
+			dpSize = it.opts.sizer.HistogramDataPointSize(src.At(it.dp))
+		}
+		if !take(dpSize) {
+			return
+		}
+		*destOrig = append(*destOrig, srcOrig[it.dp])
+		srcOrig[it.dp] = nil
+	}
+}
+
+func (it *ChunkIterator) moveExponentialHistogramDataPoints(src, dest ExponentialHistogramDataPointSlice, take func(dpSize int) bool) {
+	srcOrig, destOrig := *src.orig, dest.orig
+	for ; it.dp < len(srcOrig); it.dp++ {
+		dpSize := 0
+		if it.opts.sizer != nil {
+			dpSize = it.opts.sizer.ExponentialHistogramDataPointSize(src.At(it.dp))
+		}
+		if !take(dpSize) {
+			return
+		}
+		*destOrig = append(*destOrig, srcOrig[it.dp])
+		srcOrig[it.dp] = nil
+	}
+}
+
+func (it *ChunkIterator) moveSummaryDataPoints(src, dest SummaryDataPointSlice, take func(dpSize int) bool) {
+	srcOrig, destOrig := *src.orig, dest.orig
+	for ; it.dp < len(srcOrig); it.dp++ {
+		dpSize := 0
+		if it.opts.sizer != nil {
+			dpSize = it.opts.sizer.SummaryDataPointSize(src.At(it.dp))
+		}
+		if !take(dpSize) {
+			return
+		}
+		*destOrig = append(*destOrig, srcOrig[it.dp])
+		srcOrig[it.dp] = nil
+	}
+}
+
+// removeDataPoints removes the first n data points of ms.
+func removeDataPoints(ms Metric, n int) {
+	i := 0
+	remove := func() bool {
+		i++
+		return i <= n
+	}
+	switch ms.Type() {
+	case MetricTypeGauge:
+		ms.Gauge().DataPoints().RemoveIf(func(NumberDataPoint) bool { return remove() })
+	case MetricTypeSum:
+		ms.Sum().DataPoints().RemoveIf(func(NumberDataPoint) bool { return remove() })
+	case MetricTypeHistogram:
+		ms.Histogram().DataPoints().RemoveIf(func(HistogramDataPoint) bool { return remove() })
+	case MetricTypeExponentialHistogram:
+		ms.ExponentialHistogram().DataPoints().RemoveIf(func(ExponentialHistogramDataPoint) bool { return remove() })
+	case MetricTypeSummary:
+		ms.Summary().DataPoints().RemoveIf(func(SummaryDataPoint) bool { return remove() })
+	}
+}
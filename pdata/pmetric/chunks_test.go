@@ -0,0 +1,220 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pmetric
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// allChunks returns the chunks of md.
+func allChunks(md Metrics, opts ...ChunkOption) []Metrics {
+	var chunks []Metrics
+	for it := Chunks(md, opts...); it.Next(); {
+		chunks = append(chunks, it.Chunk())
+	}
+	return chunks
+}
+
+func TestChunksMaxItems(t *testing.T) {
+	for _, n := range []int{1, 3, 7, 25, 1000} {
+		md := splitTestMetrics(3, 5)
+		expected := splitTestMetrics(3, 5)
+		for _, chunk := range allChunks(md, WithMaxItems(n)) {
+			assertSameMetrics(t, Split(expected, n), chunk)
+		}
+		assert.Equal(t, 0, expected.ResourceMetrics().Len())
+		assert.Equal(t, 0, md.ResourceMetrics().Len())
+	}
+}
+
+func TestChunksMaxBytes(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	total := sizer.MetricsSize(splitSizeTestMetrics(3, 5))
+	for maxBytes := 0; maxBytes <= total+10; maxBytes += 37 {
+		md := splitSizeTestMetrics(3, 5)
+		expected := splitSizeTestMetrics(3, 5)
+		for _, chunk := range allChunks(md, WithMaxBytes(maxBytes, sizer)) {
+			assertSameMetrics(t, SplitSize(expected, maxBytes, sizer), chunk)
+		}
+		assert.Equal(t, 0, expected.ResourceMetrics().Len())
+		assert.Equal(t, 0, md.ResourceMetrics().Len())
+	}
+}
+
+func TestChunksWithoutLimits(t *testing.T) {
+	md := splitTestMetrics(2, 3)
+	chunks := allChunks(md)
+	require.Len(t, chunks, 1)
+	assertSameMetrics(t, splitTestMetrics(2, 3), chunks[0])
+	assert.Empty(t, allChunks(NewMetrics()))
+}
+
+func TestChunksItemExceedsBudget(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	md := splitTestMetrics(1, 2)
+	md.ResourceMetrics().At(0).Resource().Attributes().PutStr("padding", strings.Repeat("x", 1000))
+
+	// Every data point is returned alone, with the resource.
+	var ids [][]string
+	for _, chunk := range allChunks(md, WithMaxBytes(100, sizer)) {
+		ids = append(ids, splitDataPointIDs(t, chunk))
+		assert.Equal(t, 2, chunk.ResourceMetrics().At(0).Resource().Attributes().Len())
+	}
+	assert.Equal(t, [][]string{
+		{"0-0-0"}, {"0-0-1"}, {"0-1-0"}, {"0-1-1"}, {"0-2-0"},
+		{"0-2-1"}, {"0-3-0"}, {"0-3-1"}, {"0-4-0"}, {"0-4-1"},
+	}, ids)
+}
+
+func TestChunksEmptyContainers(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	for _, maxBytes := range []int{0, 100, 200, 10000} {
+		md := splitTestMetrics(3, 2)
+		md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(1).Sum().DataPoints().RemoveIf(func(NumberDataPoint) bool { return true })
+		md.ResourceMetrics().At(1).ScopeMetrics().RemoveIf(func(ScopeMetrics) bool { return true })
+
+		// The resource and metric without data points are kept, once.
+		var emptyResources, emptyMetrics []string
+		for _, chunk := range allChunks(md, WithMaxBytes(maxBytes, sizer)) {
+			for i := 0; i < chunk.ResourceMetrics().Len(); i++ {
+				rm := chunk.ResourceMetrics().At(i)
+				if rm.ScopeMetrics().Len() == 0 {
+					emptyResources = append(emptyResources, rm.SchemaUrl())
+				}
+				for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+					ms := rm.ScopeMetrics().At(j).Metrics()
+					for k := 0; k < ms.Len(); k++ {
+						if ms.At(k).DataPointCount() == 0 {
+							emptyMetrics = append(emptyMetrics, ms.At(k).Name())
+						}
+					}
+				}
+			}
+		}
+		assert.Equal(t, []string{"https://1"}, emptyResources)
+		assert.Equal(t, []string{"0-1"}, emptyMetrics)
+	}
+}
+
+func TestChunksIndependent(t *testing.T) {
+	md := splitTestMetrics(1, 4)
+	it := Chunks(md, WithMaxItems(3))
+	require.True(t, it.Next())
+	first := it.Chunk()
+
+	// Changing a chunk does not change the data points not returned
+	// yet.
+	first.ResourceMetrics().At(0).Resource().Attributes().PutStr("resource", "changed")
+	metric := first.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+	metric.SetName("changed")
+	metric.Gauge().DataPoints().AppendEmpty()
+	var ids []string
+	for it.Next() {
+		ids = append(ids, splitDataPointIDs(t, it.Chunk())...)
+	}
+	assert.Equal(t, 17, len(ids))
+	assert.Equal(t, "0-0-3", ids[0])
+	assert.Equal(t, 4, first.DataPointCount())
+}
+
+func TestChunksStop(t *testing.T) {
+	md := splitTestMetrics(2, 2)
+	it := Chunks(md, WithMaxItems(3))
+	require.True(t, it.Next())
+	assert.Equal(t, []string{"0-0-0", "0-0-1", "0-1-0"}, splitDataPointIDs(t, it.Chunk()))
+	it.Stop()
+	assert.Equal(t, 17, md.DataPointCount())
+	ids := splitDataPointIDs(t, md)
+	assert.Equal(t, []string{"0-1-1", "0-2-0"}, ids[:2])
+
+	// The iteration resumes with the remaining data points.
+	var resumed []string
+	for it.Next() {
+		resumed = append(resumed, splitDataPointIDs(t, it.Chunk())...)
+	}
+	assert.Equal(t, ids, resumed)
+	assert.Equal(t, 0, md.ResourceMetrics().Len())
+}
+
+func TestChunksOrder(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	sizer := &ProtoMarshaler{}
+	for i := 0; i < 100; i++ {
+		md := randomSplitTestMetrics(r)
+		all := splitDataPointIDs(t, md)
+		maxItems, maxBytes := 1+r.Intn(10), r.Intn(1000)
+		ids := []string{}
+		for _, chunk := range allChunks(md, WithMaxItems(maxItems), WithMaxBytes(maxBytes, sizer)) {
+			chunkIDs := splitDataPointIDs(t, chunk)
+			require.NotEmpty(t, chunkIDs)
+			assert.LessOrEqual(t, len(chunkIDs), maxItems)
+			if len(chunkIDs) > 1 {
+				assert.LessOrEqual(t, sizer.MetricsSize(chunk), maxBytes)
+			}
+			ids = append(ids, chunkIDs...)
+		}
+		assert.Equal(t, all, ids)
+	}
+}
+
+func benchmarkChunks(b *testing.B, chunks func(md Metrics)) {
+	md := splitTestMetrics(10, 1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		cp := NewMetrics()
+		md.CopyTo(cp)
+		b.StartTimer()
+		chunks(cp)
+	}
+}
+
+func BenchmarkChunks(b *testing.B) {
+	benchmarkChunks(b, func(md Metrics) {
+		for it := Chunks(md, WithMaxItems(100)); it.Next(); {
+		}
+	})
+}
+
+func BenchmarkChunksSplit(b *testing.B) {
+	benchmarkChunks(b, func(md Metrics) {
+		for md.DataPointCount() > 0 {
+			Split(md, 100)
+		}
+	})
+}
+
+func BenchmarkChunksMaxBytes(b *testing.B) {
+	sizer := &ProtoMarshaler{}
+	benchmarkChunks(b, func(md Metrics) {
+		for it := Chunks(md, WithMaxBytes(64*1024, sizer)); it.Next(); {
+		}
+	})
+}
+
+func BenchmarkChunksSplitSize(b *testing.B) {
+	sizer := &ProtoMarshaler{}
+	benchmarkChunks(b, func(md Metrics) {
+		for md.DataPointCount() > 0 {
+			SplitSize(md, 64*1024, sizer)
+		}
+	})
+}
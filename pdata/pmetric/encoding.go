@@ -39,3 +39,60 @@ type Sizer interface {
 	// MetricsSize returns the size in bytes of a marshaled Metrics.
 	MetricsSize(md Metrics) int
 }
+
+// DeltaSizer is an optional interface implemented by the Sizer, that
+// calculates the size of the parts of a marshaled Metrics, as needed to
+// split it by size.  Callers holding a Sizer detect it with a type
+// assertion.
+type DeltaSizer interface {
+	Sizer
+
+	// ResourceMetricsSize returns the size in bytes of a marshaled ResourceMetrics, without
+	// the tag and length of its field in its parent.
+	ResourceMetricsSize(rm ResourceMetrics) int
+
+	// ScopeMetricsSize returns the size in bytes of a marshaled ScopeMetrics, without
+	// the tag and length of its field in its parent.
+	ScopeMetricsSize(sm ScopeMetrics) int
+
+	// MetricSize returns the size in bytes of a marshaled Metric, without
+	// the tag and length of its field in its parent.
+	MetricSize(ms Metric) int
+
+	// NumberDataPointSize returns the size in bytes of a marshaled NumberDataPoint, without
+	// the tag and length of its field in its parent.
+	NumberDataPointSize(dp NumberDataPoint) int
+
+	// HistogramDataPointSize returns the size in bytes of a marshaled HistogramDataPoint, without
+	// the tag and length of its field in its parent.
+	HistogramDataPointSize(dp HistogramDataPoint) int
+
+	// ExponentialHistogramDataPointSize returns the size in bytes of a marshaled ExponentialHistogramDataPoint, without
+	// the tag and length of its field in its parent.
+	ExponentialHistogramDataPointSize(dp ExponentialHistogramDataPoint) int
+
+	// SummaryDataPointSize returns the size in bytes of a marshaled SummaryDataPoint, without
+	// the tag and length of its field in its parent.
+	SummaryDataPointSize(dp SummaryDataPoint) int
+
+	// NumberDataPointDeltaSize returns the size a NumberDataPoint adds to
+	// a marshaled gauge or sum, with the tag and length of its field.
+	NumberDataPointDeltaSize(dp NumberDataPoint) int
+
+	// HistogramDataPointDeltaSize returns the size a HistogramDataPoint
+	// adds to a marshaled histogram, with the tag and length of its field.
+	HistogramDataPointDeltaSize(dp HistogramDataPoint) int
+
+	// ExponentialHistogramDataPointDeltaSize returns the size an
+	// ExponentialHistogramDataPoint adds to a marshaled exponential
+	// histogram, with the tag and length of its field.
+	ExponentialHistogramDataPointDeltaSize(dp ExponentialHistogramDataPoint) int
+
+	// SummaryDataPointDeltaSize returns the size a SummaryDataPoint adds
+	// to a marshaled summary, with the tag and length of its field.
+	SummaryDataPointDeltaSize(dp SummaryDataPoint) int
+
+	// MetricsOverheadSize returns the size of a marshaled Metrics beyond
+	// the delta sizes of its data points.
+	MetricsOverheadSize(md Metrics) int
+}
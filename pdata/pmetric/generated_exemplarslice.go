@@ -104,6 +104,20 @@ func (es ExemplarSlice) MoveAndAppendTo(dest ExemplarSlice) {
 	*es.orig = nil
 }
 
+// MoveAndAppendFrom moves all elements from each of the srcs, in order, and appends them
+// to the current slice, growing it at most once.  The srcs will be cleared.
+func (es ExemplarSlice) MoveAndAppendFrom(srcs ...ExemplarSlice) {
+	newLen := len(*es.orig)
+	for _, src := range srcs {
+		newLen += len(*src.orig)
+	}
+	es.EnsureCapacity(newLen)
+	for _, src := range srcs {
+		*es.orig = append(*es.orig, *src.orig...)
+		*src.orig = nil
+	}
+}
+
 // RemoveIf calls f sequentially for each element present in the slice.
 // If f returns true, the element is removed from the slice.
 func (es ExemplarSlice) RemoveIf(f func(Exemplar) bool) {
@@ -24,7 +24,7 @@ import (
 type Metrics internal.Metrics
 
 func newMetrics(orig *otlpcollectormetrics.ExportMetricsServiceRequest) Metrics {
-	return Metrics(internal.NewMetrics(orig))
+	return Metrics(internal.NewMetrics(orig, new(internal.State)))
 }
 
 func (ms Metrics) getOrig() *otlpcollectormetrics.ExportMetricsServiceRequest {
@@ -41,22 +41,78 @@ func (ms Metrics) CopyTo(dest Metrics) {
 	ms.ResourceMetrics().CopyTo(dest.ResourceMetrics())
 }
 
+// MarkReadOnly marks ms as shared, e.g. by a fan-out to several
+// consumers, so that the consumers needing to mutate it work on a copy,
+// obtained with AsMutable.  The mark is kept by the copies of the Metrics
+// value, and cannot be removed.  It is not enforced by the methods
+// mutating ms.
+func (ms Metrics) MarkReadOnly() {
+	internal.GetMetricsState(internal.Metrics(ms)).MarkReadOnly()
+}
+
+// IsReadOnly returns whether ms was marked read-only.
+func (ms Metrics) IsReadOnly() bool {
+	return internal.GetMetricsState(internal.Metrics(ms)).IsReadOnly()
+}
+
+// AsMutable returns ms when it is not marked read-only, and otherwise a
+// mutable copy of it, sharing no data with ms.
+func (ms Metrics) AsMutable() Metrics {
+	if !ms.IsReadOnly() {
+		return ms
+	}
+	dest := NewMetrics()
+	ms.CopyTo(dest)
+	return dest
+}
+
+// Reset removes all the data of ms, keeping the capacity of its
+// ResourceMetrics slice so that ms can be filled again without growing it.
+// The removed data is no longer referenced by ms.  Reset must only be
+// called once ms is no longer used elsewhere.
+func (ms Metrics) Reset() {
+	orig := ms.getOrig()
+	for i := range orig.ResourceMetrics {
+		orig.ResourceMetrics[i] = nil
+	}
+	orig.ResourceMetrics = orig.ResourceMetrics[:0]
+}
+
 // ResourceMetrics returns the ResourceMetricsSlice associated with this Metrics.
 func (ms Metrics) ResourceMetrics() ResourceMetricsSlice {
 	return newResourceMetricsSlice(&ms.getOrig().ResourceMetrics)
 }
 
+// RemoveEmpty removes the ScopeMetrics having no metrics, then the
+// ResourceMetrics left with no scopes, such as the shells left behind by
+// a split or a filter.  Metrics without data points are kept, they still
+// describe their stream.  It returns the number of entries removed, scopes
+// and resources together.  The order of the remaining entries is kept.
+func (ms Metrics) RemoveEmpty() int {
+	removed := 0
+	ms.ResourceMetrics().RemoveIf(func(rm ResourceMetrics) bool {
+		rm.ScopeMetrics().RemoveIf(func(sm ScopeMetrics) bool {
+			if sm.Metrics().Len() == 0 {
+				removed++
+				return true
+			}
+			return false
+		})
+		if rm.ScopeMetrics().Len() == 0 {
+			removed++
+			return true
+		}
+		return false
+	})
+	return removed
+}
+
 // MetricCount calculates the total number of metrics.
 func (ms Metrics) MetricCount() int {
 	metricCount := 0
 	rms := ms.ResourceMetrics()
 	for i := 0; i < rms.Len(); i++ {
-		rm := rms.At(i)
-		ilms := rm.ScopeMetrics()
-		for j := 0; j < ilms.Len(); j++ {
-			ilm := ilms.At(j)
-			metricCount += ilm.Metrics().Len()
-		}
+		metricCount += rms.At(i).MetricCount()
 	}
 	return metricCount
 }
@@ -65,27 +121,60 @@ func (ms Metrics) MetricCount() int {
 func (ms Metrics) DataPointCount() (dataPointCount int) {
 	rms := ms.ResourceMetrics()
 	for i := 0; i < rms.Len(); i++ {
-		rm := rms.At(i)
-		ilms := rm.ScopeMetrics()
-		for j := 0; j < ilms.Len(); j++ {
-			ilm := ilms.At(j)
-			ms := ilm.Metrics()
-			for k := 0; k < ms.Len(); k++ {
-				m := ms.At(k)
-				switch m.Type() {
-				case MetricTypeGauge:
-					dataPointCount += m.Gauge().DataPoints().Len()
-				case MetricTypeSum:
-					dataPointCount += m.Sum().DataPoints().Len()
-				case MetricTypeHistogram:
-					dataPointCount += m.Histogram().DataPoints().Len()
-				case MetricTypeExponentialHistogram:
-					dataPointCount += m.ExponentialHistogram().DataPoints().Len()
-				case MetricTypeSummary:
-					dataPointCount += m.Summary().DataPoints().Len()
-				}
-			}
-		}
+		dataPointCount += rms.At(i).DataPointCount()
 	}
 	return
 }
+
+// MetricCount calculates the number of metrics of all the scopes of the
+// ResourceMetrics.
+func (ms ResourceMetrics) MetricCount() (metricCount int) {
+	sms := ms.ScopeMetrics()
+	for i := 0; i < sms.Len(); i++ {
+		metricCount += sms.At(i).MetricCount()
+	}
+	return
+}
+
+// DataPointCount calculates the number of data points of all the metrics
+// of the ResourceMetrics.
+func (ms ResourceMetrics) DataPointCount() (dataPointCount int) {
+	sms := ms.ScopeMetrics()
+	for i := 0; i < sms.Len(); i++ {
+		dataPointCount += sms.At(i).DataPointCount()
+	}
+	return
+}
+
+// MetricCount returns the number of metrics of the ScopeMetrics.
+func (ms ScopeMetrics) MetricCount() int {
+	return ms.Metrics().Len()
+}
+
+// DataPointCount calculates the number of data points of all the metrics
+// of the ScopeMetrics.
+func (ms ScopeMetrics) DataPointCount() (dataPointCount int) {
+	metrics := ms.Metrics()
+	for i := 0; i < metrics.Len(); i++ {
+		dataPointCount += metrics.At(i).DataPointCount()
+	}
+	return
+}
+
+// DataPointCount returns the number of data points of the Metric, zero
+// when its type is MetricTypeEmpty.
+func (ms Metric) DataPointCount() int {
+	switch ms.Type() {
+	case MetricTypeGauge:
+		return ms.Gauge().DataPoints().Len()
+	case MetricTypeSum:
+		return ms.Sum().DataPoints().Len()
+	case MetricTypeHistogram:
+		return ms.Histogram().DataPoints().Len()
+	case MetricTypeExponentialHistogram:
+		return ms.ExponentialHistogram().DataPoints().Len()
+	case MetricTypeSummary:
+		return ms.Summary().DataPoints().Len()
+	}
+	return 0
+}
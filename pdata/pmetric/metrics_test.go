@@ -91,6 +91,13 @@ func TestMetricCount(t *testing.T) {
 	}
 	// 5 + 1 (from rms.At(0) initialized first)
 	assert.EqualValues(t, 6, md.MetricCount())
+
+	// Each resource and scope counts its own metrics.
+	assert.EqualValues(t, 1, rms.At(0).MetricCount())
+	assert.EqualValues(t, 0, rms.At(1).MetricCount())
+	assert.EqualValues(t, 0, rms.At(1).ScopeMetrics().At(0).MetricCount())
+	assert.EqualValues(t, 5, rms.At(2).MetricCount())
+	assert.EqualValues(t, 5, rms.At(2).ScopeMetrics().At(0).MetricCount())
 }
 
 func TestMetricCountWithEmpty(t *testing.T) {
@@ -150,6 +157,16 @@ func TestMetricAndDataPointCount(t *testing.T) {
 
 	ilm.At(4).SetEmptySummary().DataPoints().AppendEmpty()
 	assert.EqualValues(t, 5, md.DataPointCount())
+
+	// Each resource, scope and metric counts its own data points.
+	assert.EqualValues(t, 0, rms.At(0).DataPointCount())
+	assert.EqualValues(t, 0, rms.At(0).ScopeMetrics().At(0).Metrics().At(0).DataPointCount())
+	assert.EqualValues(t, 0, rms.At(1).ScopeMetrics().At(0).DataPointCount())
+	assert.EqualValues(t, 5, rms.At(2).DataPointCount())
+	assert.EqualValues(t, 5, ilms.At(0).DataPointCount())
+	for i := 0; i < ilm.Len(); i++ {
+		assert.EqualValues(t, 1, ilm.At(i).DataPointCount())
+	}
 }
 
 func TestDataPointCountWithEmpty(t *testing.T) {
@@ -168,6 +185,10 @@ func TestDataPointCountWithNilDataPoints(t *testing.T) {
 	ilm.Metrics().AppendEmpty().SetEmptyExponentialHistogram()
 	ilm.Metrics().AppendEmpty().SetEmptySummary()
 	assert.EqualValues(t, 0, metrics.DataPointCount())
+	assert.EqualValues(t, 0, ilm.DataPointCount())
+	for i := 0; i < ilm.Metrics().Len(); i++ {
+		assert.EqualValues(t, 0, ilm.Metrics().At(i).DataPointCount())
+	}
 }
 
 func TestHistogramWithNilSum(t *testing.T) {
@@ -942,3 +963,95 @@ func generateMetricsEmptyDataPoints() Metrics {
 		},
 	})
 }
+
+func TestMetricsReset(t *testing.T) {
+	metrics := NewMetrics()
+	fillTestResourceMetricsSlice(metrics.ResourceMetrics())
+	rs := metrics.ResourceMetrics().At(0)
+	capacity := cap(metrics.getOrig().ResourceMetrics)
+	metrics.Reset()
+	assert.Equal(t, 0, metrics.ResourceMetrics().Len())
+	assert.Equal(t, capacity, cap(metrics.getOrig().ResourceMetrics))
+	assert.Nil(t, metrics.getOrig().ResourceMetrics[:1][0])
+
+	// The removed data is unchanged, and not shared with the data
+	// added again.
+	expected := NewMetrics()
+	fillTestResourceMetricsSlice(expected.ResourceMetrics())
+	assert.Equal(t, expected.ResourceMetrics().At(0), rs)
+	metrics.ResourceMetrics().AppendEmpty()
+	assert.NotEqual(t, metrics.ResourceMetrics().At(0), rs)
+}
+
+func TestMetricsRemoveEmpty(t *testing.T) {
+	metrics := NewMetrics()
+	assert.Equal(t, 0, metrics.RemoveEmpty())
+
+	// Shells nested at every level, around two non-empty scopes.
+	rs := metrics.ResourceMetrics().AppendEmpty()
+	rs.Resource().Attributes().PutStr("name", "first")
+	rs.ScopeMetrics().AppendEmpty()
+	rs.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetName("a")
+	rs.ScopeMetrics().AppendEmpty()
+	metrics.ResourceMetrics().AppendEmpty()
+	rs = metrics.ResourceMetrics().AppendEmpty()
+	rs.ScopeMetrics().AppendEmpty()
+	rs.ScopeMetrics().AppendEmpty()
+	rs = metrics.ResourceMetrics().AppendEmpty()
+	rs.Resource().Attributes().PutStr("name", "last")
+	rs.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetName("b")
+
+	expected := NewMetrics()
+	rs = expected.ResourceMetrics().AppendEmpty()
+	rs.Resource().Attributes().PutStr("name", "first")
+	rs.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetName("a")
+	rs = expected.ResourceMetrics().AppendEmpty()
+	rs.Resource().Attributes().PutStr("name", "last")
+	rs.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetName("b")
+
+	// The metrics have no data points, and are kept all the same.
+	assert.Equal(t, 6, metrics.RemoveEmpty())
+	assert.Equal(t, expected, metrics)
+	assert.Equal(t, 0, metrics.RemoveEmpty())
+}
+
+func TestMetricsReadOnly(t *testing.T) {
+	metrics := NewMetrics()
+	assert.False(t, metrics.IsReadOnly())
+	metrics.MarkReadOnly()
+	assert.True(t, metrics.IsReadOnly())
+
+	metricsCopy := NewMetrics()
+	metrics.CopyTo(metricsCopy)
+	assert.False(t, metricsCopy.IsReadOnly())
+
+	// A zero-initialized value, as returned on unmarshal errors, is left as is.
+	assert.NotPanics(t, func() { Metrics{}.MarkReadOnly() })
+	assert.False(t, Metrics{}.IsReadOnly())
+}
+
+func TestMetricsAsMutable(t *testing.T) {
+	metrics := NewMetrics()
+	fillTestResourceMetricsSlice(metrics.ResourceMetrics())
+	assert.False(t, metrics.IsReadOnly())
+	assert.Same(t, metrics.getOrig(), metrics.AsMutable().getOrig())
+
+	shared := metrics
+	metrics.MarkReadOnly()
+	assert.True(t, shared.IsReadOnly())
+	mutable := metrics.AsMutable()
+	assert.False(t, mutable.IsReadOnly())
+	assert.True(t, metrics.IsReadOnly())
+	assert.NotSame(t, metrics.getOrig(), mutable.getOrig())
+	assert.Equal(t, metrics.ResourceMetrics(), mutable.ResourceMetrics())
+
+	// The copy does not alias the data of the original.
+	assert.NotSame(t, &metrics.getOrig().ResourceMetrics[0], &mutable.getOrig().ResourceMetrics[0])
+	assert.NotSame(t, metrics.getOrig().ResourceMetrics[0], mutable.getOrig().ResourceMetrics[0])
+	item := mutable.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+	original := metrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Name()
+	item.SetName("changed")
+	assert.Equal(t, original, metrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Name())
+	mutable.ResourceMetrics().AppendEmpty()
+	assert.Equal(t, metrics.ResourceMetrics().Len()+1, mutable.ResourceMetrics().Len())
+}
@@ -20,6 +20,7 @@ import (
 )
 
 var _ MarshalSizer = (*ProtoMarshaler)(nil)
+var _ DeltaSizer = (*ProtoMarshaler)(nil)
 
 type ProtoMarshaler struct{}
 
@@ -28,11 +29,120 @@ func (e *ProtoMarshaler) MarshalMetrics(md Metrics) ([]byte, error) {
 	return pb.Marshal()
 }
 
+// MetricsSize returns the size of the marshaled md.  It is computed on each
+// call: md can be changed through the wrappers of its parts, which do not
+// reference md, so that a cached size could not be invalidated.
 func (e *ProtoMarshaler) MetricsSize(md Metrics) int {
 	pb := internal.MetricsToProto(internal.Metrics(md))
 	return pb.Size()
 }
 
+func (e *ProtoMarshaler) ResourceMetricsSize(rm ResourceMetrics) int {
+	return rm.orig.Size()
+}
+
+func (e *ProtoMarshaler) ScopeMetricsSize(sm ScopeMetrics) int {
+	return sm.orig.Size()
+}
+
+func (e *ProtoMarshaler) MetricSize(ms Metric) int {
+	return ms.orig.Size()
+}
+
+func (e *ProtoMarshaler) NumberDataPointSize(dp NumberDataPoint) int {
+	return dp.orig.Size()
+}
+
+func (e *ProtoMarshaler) HistogramDataPointSize(dp HistogramDataPoint) int {
+	return dp.orig.Size()
+}
+
+func (e *ProtoMarshaler) ExponentialHistogramDataPointSize(dp ExponentialHistogramDataPoint) int {
+	return dp.orig.Size()
+}
+
+func (e *ProtoMarshaler) SummaryDataPointSize(dp SummaryDataPoint) int {
+	return dp.orig.Size()
+}
+
+// NumberDataPointDeltaSize returns the size a data point adds to a
+// marshaled gauge or sum: its NumberDataPointSize along with the tag and
+// length prefixing it, so that the sizes of data points can be summed.
+func (e *ProtoMarshaler) NumberDataPointDeltaSize(dp NumberDataPoint) int {
+	return fieldSize(dp.orig.Size())
+}
+
+// HistogramDataPointDeltaSize is the NumberDataPointDeltaSize of
+// histograms.
+func (e *ProtoMarshaler) HistogramDataPointDeltaSize(dp HistogramDataPoint) int {
+	return fieldSize(dp.orig.Size())
+}
+
+// ExponentialHistogramDataPointDeltaSize is the NumberDataPointDeltaSize
+// of exponential histograms.
+func (e *ProtoMarshaler) ExponentialHistogramDataPointDeltaSize(dp ExponentialHistogramDataPoint) int {
+	return fieldSize(dp.orig.Size())
+}
+
+// SummaryDataPointDeltaSize is the NumberDataPointDeltaSize of
+// summaries.
+func (e *ProtoMarshaler) SummaryDataPointDeltaSize(dp SummaryDataPoint) int {
+	return fieldSize(dp.orig.Size())
+}
+
+// MetricsOverheadSize returns the size of the marshaled md beyond the
+// delta sizes of its data points: that of its resources, scopes and
+// metrics, and of the tags and lengths prefixing them, so that
+//
+//	MetricsSize(md) = MetricsOverheadSize(md) + Σ DataPointDeltaSize(dp)
+//
+// The lengths prefixing the resources, scopes and metrics grow with
+// their data points, a byte each time they cross 127 bytes, 16383 bytes,
+// and so on, so the overhead of the same resources, scopes and metrics
+// holding other data points is only approximated by it.
+func (e *ProtoMarshaler) MetricsOverheadSize(md Metrics) int {
+	size := e.MetricsSize(md)
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		sms := rms.At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			ms := sms.At(j).Metrics()
+			for k := 0; k < ms.Len(); k++ {
+				size -= e.metricDataPointsDeltaSize(ms.At(k))
+			}
+		}
+	}
+	return size
+}
+
+// metricDataPointsDeltaSize returns the sum of the delta sizes of the data
+// points of ms.
+func (e *ProtoMarshaler) metricDataPointsDeltaSize(ms Metric) (size int) {
+	switch ms.Type() {
+	case MetricTypeGauge:
+		for i := 0; i < ms.Gauge().DataPoints().Len(); i++ {
+			size += e.NumberDataPointDeltaSize(ms.Gauge().DataPoints().At(i))
+		}
+	case MetricTypeSum:
+		for i := 0; i < ms.Sum().DataPoints().Len(); i++ {
+			size += e.NumberDataPointDeltaSize(ms.Sum().DataPoints().At(i))
+		}
+	case MetricTypeHistogram:
+		for i := 0; i < ms.Histogram().DataPoints().Len(); i++ {
+			size += e.HistogramDataPointDeltaSize(ms.Histogram().DataPoints().At(i))
+		}
+	case MetricTypeExponentialHistogram:
+		for i := 0; i < ms.ExponentialHistogram().DataPoints().Len(); i++ {
+			size += e.ExponentialHistogramDataPointDeltaSize(ms.ExponentialHistogram().DataPoints().At(i))
+		}
+	case MetricTypeSummary:
+		for i := 0; i < ms.Summary().DataPoints().Len(); i++ {
+			size += e.SummaryDataPointDeltaSize(ms.Summary().DataPoints().At(i))
+		}
+	}
+	return size
+}
+
 type ProtoUnmarshaler struct{}
 
 func (d *ProtoUnmarshaler) UnmarshalMetrics(buf []byte) (Metrics, error) {
@@ -15,6 +15,7 @@
 package pmetric
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -47,6 +48,176 @@ func TestProtoSizerEmptyMetrics(t *testing.T) {
 	assert.Equal(t, 0, sizer.MetricsSize(NewMetrics()))
 }
 
+func TestProtoSizerMutations(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(md Metrics)
+	}{
+		{
+			name: "append",
+			mutate: func(md Metrics) {
+				md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().AppendEmpty().SetName("a longer name")
+			},
+		},
+		{
+			name: "remove",
+			mutate: func(md Metrics) {
+				md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().RemoveIf(func(Metric) bool { return true })
+			},
+		},
+		{
+			name: "attribute",
+			mutate: func(md Metrics) {
+				md.ResourceMetrics().At(0).Resource().Attributes().PutStr("host", "a")
+			},
+		},
+		{
+			name: "field",
+			mutate: func(md Metrics) {
+				md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).SetName("a longer name")
+			},
+		},
+	}
+	sizer := &ProtoMarshaler{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The size follows the changes made through the parts of
+			// the data, read-only or not.
+			for _, readOnly := range []bool{false, true} {
+				md := generateBenchmarkMetrics(2)
+				if readOnly {
+					md.MarkReadOnly()
+				}
+				size := sizer.MetricsSize(md)
+				tt.mutate(md)
+				assert.NotEqual(t, size, sizer.MetricsSize(md))
+				assert.Equal(t, md.getOrig().Size(), sizer.MetricsSize(md))
+				buf, err := sizer.MarshalMetrics(md)
+				require.NoError(t, err)
+				assert.Len(t, buf, sizer.MetricsSize(md))
+			}
+		})
+	}
+}
+
+// varintLen returns the length of the varint encoding of v.
+func varintLen(v int) int {
+	switch {
+	case v < 1<<7:
+		return 1
+	case v < 1<<14:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func TestProtoSizerDataPointDeltaSize(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	tests := []struct {
+		typ MetricType
+		// add appends a data point with attrs to metric, returning its
+		// size and delta size.
+		add func(metric Metric, attrs func(pcommon.Map)) (int, int)
+	}{
+		{
+			typ: MetricTypeGauge,
+			add: func(metric Metric, attrs func(pcommon.Map)) (int, int) {
+				dp := metric.Gauge().DataPoints().AppendEmpty()
+				attrs(dp.Attributes())
+				return sizer.NumberDataPointSize(dp), sizer.NumberDataPointDeltaSize(dp)
+			},
+		},
+		{
+			typ: MetricTypeSum,
+			add: func(metric Metric, attrs func(pcommon.Map)) (int, int) {
+				dp := metric.Sum().DataPoints().AppendEmpty()
+				attrs(dp.Attributes())
+				return sizer.NumberDataPointSize(dp), sizer.NumberDataPointDeltaSize(dp)
+			},
+		},
+		{
+			typ: MetricTypeHistogram,
+			add: func(metric Metric, attrs func(pcommon.Map)) (int, int) {
+				dp := metric.Histogram().DataPoints().AppendEmpty()
+				attrs(dp.Attributes())
+				return sizer.HistogramDataPointSize(dp), sizer.HistogramDataPointDeltaSize(dp)
+			},
+		},
+		{
+			typ: MetricTypeExponentialHistogram,
+			add: func(metric Metric, attrs func(pcommon.Map)) (int, int) {
+				dp := metric.ExponentialHistogram().DataPoints().AppendEmpty()
+				attrs(dp.Attributes())
+				return sizer.ExponentialHistogramDataPointSize(dp), sizer.ExponentialHistogramDataPointDeltaSize(dp)
+			},
+		},
+		{
+			typ: MetricTypeSummary,
+			add: func(metric Metric, attrs func(pcommon.Map)) (int, int) {
+				dp := metric.Summary().DataPoints().AppendEmpty()
+				attrs(dp.Attributes())
+				return sizer.SummaryDataPointSize(dp), sizer.SummaryDataPointDeltaSize(dp)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ.String(), func(t *testing.T) {
+			// The sizes of the data points, and of their prefixes, cross
+			// 127 and 16383 bytes.
+			var src Metric
+			ms := splitTestMetrics(1, 1).ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+			for i := 0; i < ms.Len(); i++ {
+				if ms.At(i).Type() == tt.typ {
+					src = ms.At(i)
+				}
+			}
+			prefixes := map[int]bool{}
+			for _, lengths := range [][2]int{{100, 140}, {16350, 16400}} {
+				for l := lengths[0]; l < lengths[1]; l++ {
+					metric := NewMetric()
+					copyMetricEnvelope(src, metric)
+					empty := metricDataSize(metric)
+					size, delta := tt.add(metric, func(attrs pcommon.Map) {
+						attrs.PutStr("padding", strings.Repeat("x", l))
+					})
+
+					prefixes[varintLen(size)] = true
+					assert.Equal(t, size+1+varintLen(size), delta)
+					assert.Equal(t, metricDataSize(metric)-empty, delta)
+				}
+			}
+			assert.Equal(t, map[int]bool{1: true, 2: true, 3: true}, prefixes)
+		})
+	}
+}
+
+func TestProtoSizerMetricsOverheadSize(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	md := splitTestMetrics(2, 3)
+	dps := md.ResourceMetrics().At(1).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints()
+	dps.At(0).Attributes().PutStr("padding", strings.Repeat("x", 200))
+	dps.At(1).Attributes().PutStr("padding", strings.Repeat("x", 20000))
+
+	overhead := sizer.MetricsOverheadSize(md)
+	itemsSize := 0
+	shape := NewMetrics()
+	md.CopyTo(shape)
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		ms := md.ResourceMetrics().At(i).ScopeMetrics().At(0).Metrics()
+		for j := 0; j < ms.Len(); j++ {
+			itemsSize += sizer.metricDataPointsDeltaSize(ms.At(j))
+			copyMetricEnvelope(ms.At(j), shape.ResourceMetrics().At(i).ScopeMetrics().At(0).Metrics().At(j))
+		}
+	}
+	assert.Equal(t, sizer.MetricsSize(md), overhead+itemsSize)
+	// The lengths prefixing the resources, scopes, metrics and data of
+	// metrics take up to 2 more bytes each once the data points are added.
+	shapeSize := sizer.MetricsSize(shape)
+	assert.GreaterOrEqual(t, overhead, shapeSize)
+	assert.LessOrEqual(t, overhead, shapeSize+2*(2+2+10+10))
+}
+
 func BenchmarkMetricsToProto(b *testing.B) {
 	marshaler := &ProtoMarshaler{}
 	metrics := generateBenchmarkMetrics(128)
@@ -74,6 +245,18 @@ func BenchmarkMetricsFromProto(b *testing.B) {
 	}
 }
 
+func BenchmarkMetricsSize(b *testing.B) {
+	md := generateBenchmarkMetrics(128)
+	sizer := &ProtoMarshaler{}
+	size := 0
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		size = sizer.MetricsSize(md)
+	}
+	assert.NotEqual(b, 0, size)
+}
+
 func generateBenchmarkMetrics(metricsCount int) Metrics {
 	now := time.Now()
 	startTime := pcommon.NewTimestampFromTime(now.Add(-10 * time.Second))
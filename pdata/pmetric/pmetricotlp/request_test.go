@@ -20,6 +20,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
 )
 
 var _ json.Unmarshaler = ExportRequest{}
@@ -60,3 +62,14 @@ func TestRequestJSON(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, strings.Join(strings.Fields(string(metricsRequestJSON)), ""), string(got))
 }
+
+func TestRequestReadOnly(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	tr := NewExportRequestFromMetrics(metrics)
+	metrics.MarkReadOnly()
+	assert.True(t, tr.Metrics().IsReadOnly())
+
+	tr = NewExportRequest()
+	tr.Metrics().MarkReadOnly()
+	assert.True(t, tr.Metrics().IsReadOnly())
+}
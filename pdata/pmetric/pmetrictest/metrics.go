@@ -0,0 +1,373 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pmetrictest compares metrics in tests.
+package pmetrictest // import "go.opentelemetry.io/collector/pdata/pmetric/pmetrictest"
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.uber.org/multierr"
+
+	"go.opentelemetry.io/collector/pdata/internal/dedup"
+	"go.opentelemetry.io/collector/pdata/internal/match"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// CompareMetricsOption configures CompareMetrics.
+type CompareMetricsOption func(*compareOptions)
+
+type compareOptions struct {
+	ignoreResourceOrder  bool
+	ignoreScopeOrder     bool
+	ignoreMetricsOrder   bool
+	ignoreDataPointOrder bool
+}
+
+// IgnoreResourceOrder makes CompareMetrics match the resources by their
+// attributes and schema URL, rather than by their position.
+func IgnoreResourceOrder() CompareMetricsOption {
+	return func(o *compareOptions) {
+		o.ignoreResourceOrder = true
+	}
+}
+
+// IgnoreScopeOrder makes CompareMetrics match the scopes of a resource by
+// their name, version, attributes and schema URL, rather than by their
+// position.
+func IgnoreScopeOrder() CompareMetricsOption {
+	return func(o *compareOptions) {
+		o.ignoreScopeOrder = true
+	}
+}
+
+// IgnoreMetricsOrder makes CompareMetrics match the metrics of a scope
+// by their name, rather than by their position.
+func IgnoreMetricsOrder() CompareMetricsOption {
+	return func(o *compareOptions) {
+		o.ignoreMetricsOrder = true
+	}
+}
+
+// IgnoreDataPointOrder makes CompareMetrics match the data points of a
+// metric by their attributes and timestamps, rather than by their
+// position.
+func IgnoreDataPointOrder() CompareMetricsOption {
+	return func(o *compareOptions) {
+		o.ignoreDataPointOrder = true
+	}
+}
+
+// CompareMetrics returns an error listing the differences between expected
+// and actual, or nil when they are equal.  Each difference names the
+// resource, scope, metric and data point it is found in.  Attributes are compared
+// regardless of their order.
+func CompareMetrics(expected, actual pmetric.Metrics, opts ...CompareMetricsOption) error {
+	var o compareOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	exp, act := expected.ResourceMetrics(), actual.ResourceMetrics()
+	pairs, missing, unexpected := match.Entries(exp.Len(), act.Len(), o.ignoreResourceOrder, func(i, j int) bool {
+		return sameResource(exp.At(i), act.At(j))
+	})
+	var errs error
+	for _, p := range pairs {
+		e, a := exp.At(p.Expected), act.At(p.Actual)
+		if !sameResource(e, a) {
+			errs = multierr.Append(errs, fmt.Errorf("at index %d: expected %s, actual %s", p.Actual, resourceName(e), resourceName(a)))
+			continue
+		}
+		errs = multierr.Append(errs, prefix(resourceName(e), compareScopes(e.ScopeMetrics(), a.ScopeMetrics(), o)))
+	}
+	for _, i := range missing {
+		errs = multierr.Append(errs, fmt.Errorf("missing %s", resourceName(exp.At(i))))
+	}
+	for _, j := range unexpected {
+		errs = multierr.Append(errs, fmt.Errorf("unexpected %s", resourceName(act.At(j))))
+	}
+	return errs
+}
+
+func compareScopes(exp, act pmetric.ScopeMetricsSlice, o compareOptions) error {
+	pairs, missing, unexpected := match.Entries(exp.Len(), act.Len(), o.ignoreScopeOrder, func(i, j int) bool {
+		return sameScope(exp.At(i), act.At(j))
+	})
+	var errs error
+	for _, p := range pairs {
+		e, a := exp.At(p.Expected), act.At(p.Actual)
+		if !sameScope(e, a) {
+			errs = multierr.Append(errs, fmt.Errorf("at index %d: expected %s, actual %s", p.Actual, scopeName(e), scopeName(a)))
+			continue
+		}
+		errs = multierr.Append(errs, prefix(scopeName(e), compareMetrics(e.Metrics(), a.Metrics(), o)))
+	}
+	for _, i := range missing {
+		errs = multierr.Append(errs, fmt.Errorf("missing %s", scopeName(exp.At(i))))
+	}
+	for _, j := range unexpected {
+		errs = multierr.Append(errs, fmt.Errorf("unexpected %s", scopeName(act.At(j))))
+	}
+	return errs
+}
+
+func compareMetrics(exp, act pmetric.MetricSlice, o compareOptions) error {
+	pairs, missing, unexpected := match.Entries(exp.Len(), act.Len(), o.ignoreMetricsOrder, func(i, j int) bool {
+		return exp.At(i).Name() == act.At(j).Name()
+	})
+	var errs error
+	for _, p := range pairs {
+		e, a := exp.At(p.Expected), act.At(p.Actual)
+		if e.Name() != a.Name() {
+			errs = multierr.Append(errs, fmt.Errorf("at index %d: expected %s, actual %s", p.Actual, metricName(e), metricName(a)))
+			continue
+		}
+		errs = multierr.Append(errs, prefix(metricName(e), compareMetric(e, a, o)))
+	}
+	for _, i := range missing {
+		errs = multierr.Append(errs, fmt.Errorf("missing %s", metricName(exp.At(i))))
+	}
+	for _, j := range unexpected {
+		errs = multierr.Append(errs, fmt.Errorf("unexpected %s", metricName(act.At(j))))
+	}
+	return errs
+}
+
+// compareMetric compares the fields and data points of two metrics of
+// the same name.
+func compareMetric(e, a pmetric.Metric, o compareOptions) error {
+	var errs error
+	field := func(name string, e, a any) {
+		if !reflect.DeepEqual(e, a) {
+			errs = multierr.Append(errs, fmt.Errorf("%s: expected %v, actual %v", name, e, a))
+		}
+	}
+	field("description", e.Description(), a.Description())
+	field("unit", e.Unit(), a.Unit())
+	field("type", e.Type(), a.Type())
+	if e.Type() != a.Type() {
+		return errs
+	}
+	switch e.Type() {
+	case pmetric.MetricTypeSum:
+		field("aggregation temporality", e.Sum().AggregationTemporality(), a.Sum().AggregationTemporality())
+		field("is monotonic", e.Sum().IsMonotonic(), a.Sum().IsMonotonic())
+	case pmetric.MetricTypeHistogram:
+		field("aggregation temporality", e.Histogram().AggregationTemporality(), a.Histogram().AggregationTemporality())
+	case pmetric.MetricTypeExponentialHistogram:
+		field("aggregation temporality", e.ExponentialHistogram().AggregationTemporality(), a.ExponentialHistogram().AggregationTemporality())
+	}
+	return multierr.Append(errs, compareDataPoints(dataPoints(e), dataPoints(a), o))
+}
+
+func compareDataPoints(exp, act []dataPoint, o compareOptions) error {
+	pairs, missing, unexpected := match.Entries(len(exp), len(act), o.ignoreDataPointOrder, func(i, j int) bool {
+		return exp[i].same(act[j])
+	})
+	var errs error
+	for _, p := range pairs {
+		e, a := exp[p.Expected], act[p.Actual]
+		if !e.same(a) {
+			errs = multierr.Append(errs, fmt.Errorf("at index %d: expected %s, actual %s", p.Actual, e.name(), a.name()))
+			continue
+		}
+		for _, f := range e.fields {
+			if v := a.field(f.name); !reflect.DeepEqual(f.value, v) {
+				errs = multierr.Append(errs, fmt.Errorf("%s: %s: expected %v, actual %v", e.name(), f.name, f.value, v))
+			}
+		}
+	}
+	for _, i := range missing {
+		errs = multierr.Append(errs, fmt.Errorf("missing %s", exp[i].name()))
+	}
+	for _, j := range unexpected {
+		errs = multierr.Append(errs, fmt.Errorf("unexpected %s", act[j].name()))
+	}
+	return errs
+}
+
+// dataPoint is a data point of any metric type, identified by its
+// attributes and timestamps, with its other fields in a fixed order.
+type dataPoint struct {
+	attributes     map[string]any
+	startTimestamp pcommon.Timestamp
+	timestamp      pcommon.Timestamp
+	fields         []dataPointField
+}
+
+type dataPointField struct {
+	name  string
+	value any
+}
+
+func (dp dataPoint) same(other dataPoint) bool {
+	return dp.startTimestamp == other.startTimestamp &&
+		dp.timestamp == other.timestamp &&
+		reflect.DeepEqual(dp.attributes, other.attributes)
+}
+
+func (dp dataPoint) field(name string) any {
+	for _, f := range dp.fields {
+		if f.name == name {
+			return f.value
+		}
+	}
+	return nil
+}
+
+func (dp dataPoint) name() string {
+	return fmt.Sprintf("data point %v (start timestamp %d, timestamp %d)", dp.attributes, dp.startTimestamp, dp.timestamp)
+}
+
+// dataPoints returns the data points of m, of the same fields for the
+// metrics of the same type.
+func dataPoints(m pmetric.Metric) []dataPoint {
+	var dps []dataPoint
+	newDataPoint := func(attrs pcommon.Map, start, ts pcommon.Timestamp, fields ...dataPointField) {
+		dps = append(dps, dataPoint{attributes: attrs.AsRaw(), startTimestamp: start, timestamp: ts, fields: fields})
+	}
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		numberDataPoints(m.Gauge().DataPoints(), newDataPoint)
+	case pmetric.MetricTypeSum:
+		numberDataPoints(m.Sum().DataPoints(), newDataPoint)
+	case pmetric.MetricTypeHistogram:
+		points := m.Histogram().DataPoints()
+		for i := 0; i < points.Len(); i++ {
+			dp := points.At(i)
+			newDataPoint(dp.Attributes(), dp.StartTimestamp(), dp.Timestamp(),
+				dataPointField{"count", dp.Count()},
+				dataPointField{"sum", optional(dp.HasSum(), dp.Sum())},
+				dataPointField{"min", optional(dp.HasMin(), dp.Min())},
+				dataPointField{"max", optional(dp.HasMax(), dp.Max())},
+				dataPointField{"bucket counts", dp.BucketCounts().AsRaw()},
+				dataPointField{"explicit bounds", dp.ExplicitBounds().AsRaw()},
+				dataPointField{"flags", dp.Flags()},
+				dataPointField{"exemplars", rawExemplars(dp.Exemplars())})
+		}
+	case pmetric.MetricTypeExponentialHistogram:
+		points := m.ExponentialHistogram().DataPoints()
+		for i := 0; i < points.Len(); i++ {
+			dp := points.At(i)
+			newDataPoint(dp.Attributes(), dp.StartTimestamp(), dp.Timestamp(),
+				dataPointField{"count", dp.Count()},
+				dataPointField{"sum", optional(dp.HasSum(), dp.Sum())},
+				dataPointField{"min", optional(dp.HasMin(), dp.Min())},
+				dataPointField{"max", optional(dp.HasMax(), dp.Max())},
+				dataPointField{"scale", dp.Scale()},
+				dataPointField{"zero count", dp.ZeroCount()},
+				dataPointField{"positive offset", dp.Positive().Offset()},
+				dataPointField{"positive bucket counts", dp.Positive().BucketCounts().AsRaw()},
+				dataPointField{"negative offset", dp.Negative().Offset()},
+				dataPointField{"negative bucket counts", dp.Negative().BucketCounts().AsRaw()},
+				dataPointField{"flags", dp.Flags()},
+				dataPointField{"exemplars", rawExemplars(dp.Exemplars())})
+		}
+	case pmetric.MetricTypeSummary:
+		points := m.Summary().DataPoints()
+		for i := 0; i < points.Len(); i++ {
+			dp := points.At(i)
+			quantiles := make([][2]float64, 0, dp.QuantileValues().Len())
+			for j := 0; j < dp.QuantileValues().Len(); j++ {
+				q := dp.QuantileValues().At(j)
+				quantiles = append(quantiles, [2]float64{q.Quantile(), q.Value()})
+			}
+			newDataPoint(dp.Attributes(), dp.StartTimestamp(), dp.Timestamp(),
+				dataPointField{"count", dp.Count()},
+				dataPointField{"sum", dp.Sum()},
+				dataPointField{"quantile values", quantiles},
+				dataPointField{"flags", dp.Flags()})
+		}
+	}
+	return dps
+}
+
+func numberDataPoints(points pmetric.NumberDataPointSlice, newDataPoint func(pcommon.Map, pcommon.Timestamp, pcommon.Timestamp, ...dataPointField)) {
+	for i := 0; i < points.Len(); i++ {
+		dp := points.At(i)
+		var value any
+		switch dp.ValueType() {
+		case pmetric.NumberDataPointValueTypeInt:
+			value = dp.IntValue()
+		case pmetric.NumberDataPointValueTypeDouble:
+			value = dp.DoubleValue()
+		}
+		newDataPoint(dp.Attributes(), dp.StartTimestamp(), dp.Timestamp(),
+			dataPointField{"value type", dp.ValueType()},
+			dataPointField{"value", value},
+			dataPointField{"flags", dp.Flags()},
+			dataPointField{"exemplars", rawExemplars(dp.Exemplars())})
+	}
+}
+
+// optional returns v when set, and nil otherwise.
+func optional(set bool, v float64) any {
+	if !set {
+		return nil
+	}
+	return v
+}
+
+func rawExemplars(exemplars pmetric.ExemplarSlice) []map[string]any {
+	raw := make([]map[string]any, 0, exemplars.Len())
+	for i := 0; i < exemplars.Len(); i++ {
+		ex := exemplars.At(i)
+		var value any
+		switch ex.ValueType() {
+		case pmetric.ExemplarValueTypeInt:
+			value = ex.IntValue()
+		case pmetric.ExemplarValueTypeDouble:
+			value = ex.DoubleValue()
+		}
+		raw = append(raw, map[string]any{
+			"timestamp":          ex.Timestamp(),
+			"value":              value,
+			"traceID":            ex.TraceID().String(),
+			"spanID":             ex.SpanID().String(),
+			"filteredAttributes": ex.FilteredAttributes().AsRaw(),
+		})
+	}
+	return raw
+}
+
+func sameResource(e, a pmetric.ResourceMetrics) bool {
+	return dedup.ResourcesEqual(e.Resource(), e.SchemaUrl(), a.Resource(), a.SchemaUrl())
+}
+
+func sameScope(e, a pmetric.ScopeMetrics) bool {
+	return dedup.ScopesEqual(e.Scope(), e.SchemaUrl(), a.Scope(), a.SchemaUrl())
+}
+
+func resourceName(rm pmetric.ResourceMetrics) string {
+	return fmt.Sprintf("resource %v", rm.Resource().Attributes().AsRaw())
+}
+
+func scopeName(sm pmetric.ScopeMetrics) string {
+	return fmt.Sprintf("scope %q %q", sm.Scope().Name(), sm.Scope().Version())
+}
+
+func metricName(m pmetric.Metric) string {
+	return fmt.Sprintf("metric %q", m.Name())
+}
+
+// prefix prefixes each of the errors of errs with name.
+func prefix(name string, errs error) error {
+	var prefixed error
+	for _, err := range multierr.Errors(errs) {
+		prefixed = multierr.Append(prefixed, fmt.Errorf("%s: %w", name, err))
+	}
+	return prefixed
+}
@@ -0,0 +1,161 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pmetrictest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// testMetrics returns metrics of two resources, of a scope of a gauge and
+// a histogram of two data points each.
+func testMetrics() pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	for _, host := range []string{"a", "b"} {
+		rm := md.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().PutStr("host", host)
+		sm := rm.ScopeMetrics().AppendEmpty()
+		sm.Scope().SetName("x")
+		gauge := sm.Metrics().AppendEmpty()
+		gauge.SetName("gauge")
+		gauge.SetEmptyGauge()
+		histogram := sm.Metrics().AppendEmpty()
+		histogram.SetName("histogram")
+		histogram.SetEmptyHistogram()
+		for i := 1; i <= 2; i++ {
+			dp := gauge.Gauge().DataPoints().AppendEmpty()
+			dp.SetTimestamp(pcommon.Timestamp(i))
+			dp.SetIntValue(int64(i))
+			hdp := histogram.Histogram().DataPoints().AppendEmpty()
+			hdp.Attributes().PutInt("index", int64(i))
+			hdp.SetCount(uint64(i))
+			hdp.BucketCounts().FromRaw([]uint64{uint64(i)})
+		}
+	}
+	return md
+}
+
+func TestCompareMetricsEqual(t *testing.T) {
+	assert.NoError(t, CompareMetrics(testMetrics(), testMetrics()))
+	assert.NoError(t, CompareMetrics(pmetric.NewMetrics(), pmetric.NewMetrics()))
+}
+
+func TestCompareMetricsIgnoreOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		reorder func(md pmetric.Metrics)
+		option  CompareMetricsOption
+	}{
+		{
+			name: "resources",
+			reorder: func(md pmetric.Metrics) {
+				rms := md.ResourceMetrics()
+				rm := pmetric.NewResourceMetrics()
+				rms.At(0).MoveTo(rm)
+				rms.RemoveIf(func(rm pmetric.ResourceMetrics) bool { return rm.ScopeMetrics().Len() == 0 })
+				rm.MoveTo(rms.AppendEmpty())
+			},
+			option: IgnoreResourceOrder(),
+		},
+		{
+			name: "metrics",
+			reorder: func(md pmetric.Metrics) {
+				ms := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+				m := pmetric.NewMetric()
+				ms.At(0).MoveTo(m)
+				ms.RemoveIf(func(m pmetric.Metric) bool { return m.Name() == "" })
+				m.MoveTo(ms.AppendEmpty())
+			},
+			option: IgnoreMetricsOrder(),
+		},
+		{
+			name: "data points",
+			reorder: func(md pmetric.Metrics) {
+				dps := md.ResourceMetrics().At(1).ScopeMetrics().At(0).Metrics().At(1).Histogram().DataPoints()
+				dp := pmetric.NewHistogramDataPoint()
+				dps.At(0).MoveTo(dp)
+				dps.RemoveIf(func(dp pmetric.HistogramDataPoint) bool { return dp.Count() == 0 })
+				dp.MoveTo(dps.AppendEmpty())
+			},
+			option: IgnoreDataPointOrder(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := testMetrics()
+			tt.reorder(actual)
+			assert.Error(t, CompareMetrics(testMetrics(), actual))
+			assert.NoError(t, CompareMetrics(testMetrics(), actual, tt.option))
+		})
+	}
+}
+
+func TestCompareMetricsPinpoints(t *testing.T) {
+	actual := testMetrics()
+	ms := actual.ResourceMetrics().At(1).ScopeMetrics().At(0).Metrics()
+	ms.At(0).Gauge().DataPoints().At(1).SetDoubleValue(2)
+	ms.At(1).Histogram().DataPoints().At(0).BucketCounts().Append(0)
+	actual.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).SetUnit("s")
+	assert.EqualError(t, CompareMetrics(testMetrics(), actual, IgnoreResourceOrder(), IgnoreDataPointOrder()),
+		`resource map[host:a]: scope "x" "": metric "gauge": unit: expected , actual s; `+
+			`resource map[host:b]: scope "x" "": metric "gauge": data point map[] (start timestamp 0, timestamp 2): value type: expected Int, actual Double; `+
+			`resource map[host:b]: scope "x" "": metric "gauge": data point map[] (start timestamp 0, timestamp 2): value: expected 2, actual 2; `+
+			`resource map[host:b]: scope "x" "": metric "histogram": data point map[index:1] (start timestamp 0, timestamp 0): bucket counts: expected [1], actual [1 0]`)
+}
+
+func TestCompareMetricsMissingUnexpected(t *testing.T) {
+	actual := testMetrics()
+	ms := actual.ResourceMetrics().At(1).ScopeMetrics().At(0).Metrics()
+	ms.At(1).Histogram().DataPoints().At(1).Attributes().PutInt("index", 3)
+	ms.At(0).SetName("renamed")
+
+	err := CompareMetrics(testMetrics(), actual, IgnoreMetricsOrder(), IgnoreDataPointOrder())
+	require.Error(t, err)
+	assert.EqualError(t, err, `resource map[host:b]: scope "x" "": metric "histogram": missing data point map[index:2] (start timestamp 0, timestamp 0); `+
+		`resource map[host:b]: scope "x" "": metric "histogram": unexpected data point map[index:3] (start timestamp 0, timestamp 0); `+
+		`resource map[host:b]: scope "x" "": missing metric "gauge"; `+
+		`resource map[host:b]: scope "x" "": unexpected metric "renamed"`)
+
+	// In order, the entries are compared by position.
+	err = CompareMetrics(testMetrics(), actual)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `at index 0: expected metric "gauge", actual metric "renamed"`)
+}
+
+func TestCompareMetricsTypes(t *testing.T) {
+	newMetric := func(md pmetric.Metrics) pmetric.Metric {
+		m := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		m.SetName("m")
+		return m
+	}
+	expected, actual := pmetric.NewMetrics(), pmetric.NewMetrics()
+	newMetric(expected).SetEmptySum().DataPoints().AppendEmpty().SetIntValue(1)
+	newMetric(actual).SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(1)
+	assert.EqualError(t, CompareMetrics(expected, actual), `resource map[]: scope "" "": metric "m": type: expected Sum, actual Gauge`)
+
+	for _, md := range []pmetric.Metrics{expected, actual} {
+		m := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+		dps := m.SetEmptyExponentialHistogram().DataPoints()
+		dps.AppendEmpty().Positive().BucketCounts().FromRaw([]uint64{1, 2})
+	}
+	assert.NoError(t, CompareMetrics(expected, actual))
+	actual.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).ExponentialHistogram().DataPoints().At(0).SetMax(1)
+	assert.EqualError(t, CompareMetrics(expected, actual), `resource map[]: scope "" "": metric "m": data point map[] (start timestamp 0, timestamp 0): max: expected <nil>, actual 1`)
+}
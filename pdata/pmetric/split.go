@@ -0,0 +1,388 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pmetric // import "go.opentelemetry.io/collector/pdata/pmetric"
+
+import (
+	"github.com/gogo/protobuf/proto"
+)
+
+// Split removes the first n data points of md, in order, and returns
+// them in a new Metrics.  The data points are moved, not copied: md is
+// left with the remaining data points only.  A ResourceMetrics,
+// ScopeMetrics or Metric whose data points are cut between both halves
+// has its resource, scope and schema URL, or its name, description,
+// unit, type and aggregation settings, copied so that both halves keep
+// them, and the containers left empty in md are removed.
+//
+// The order of the data points is kept across resources and scopes: the
+// returned data points are in their order in md, and md keeps the
+// remaining ones in their order, so that the concatenation of the
+// results of repeated splits is the original sequence.
+//
+// If n is less than or equal to zero an empty Metrics is returned and md
+// is unchanged.  If n is greater than or equal to the number of data
+// points of md, all of them are moved, leaving md empty.
+func Split(md Metrics, n int) Metrics {
+	dest := NewMetrics()
+	if n <= 0 {
+		return dest
+	}
+	if md.DataPointCount() <= n {
+		md.ResourceMetrics().MoveAndAppendTo(dest.ResourceMetrics())
+		return dest
+	}
+
+	moved := 0
+	md.ResourceMetrics().RemoveIf(func(srcRm ResourceMetrics) bool {
+		// If we are done skip everything else.
+		if moved == n {
+			return false
+		}
+
+		// If it fully fits
+		srcRmDPC := srcRm.DataPointCount()
+		if moved+srcRmDPC <= n {
+			moved += srcRmDPC
+			srcRm.MoveTo(dest.ResourceMetrics().AppendEmpty())
+			return true
+		}
+
+		destRm := dest.ResourceMetrics().AppendEmpty()
+		srcRm.Resource().CopyTo(destRm.Resource())
+		destRm.SetSchemaUrl(srcRm.SchemaUrl())
+		srcRm.ScopeMetrics().RemoveIf(func(srcSm ScopeMetrics) bool {
+			// If we are done skip everything else.
+			if moved == n {
+				return false
+			}
+
+			// If possible to move all metrics do that.
+			srcSmDPC := srcSm.DataPointCount()
+			if moved+srcSmDPC <= n {
+				moved += srcSmDPC
+				srcSm.MoveTo(destRm.ScopeMetrics().AppendEmpty())
+				return true
+			}
+
+			destSm := destRm.ScopeMetrics().AppendEmpty()
+			srcSm.Scope().CopyTo(destSm.Scope())
+			destSm.SetSchemaUrl(srcSm.SchemaUrl())
+			srcSm.Metrics().RemoveIf(func(srcMetric Metric) bool {
+				// If we are done skip everything else.
+				if moved == n {
+					return false
+				}
+
+				// If possible to move all points do that.
+				srcMetricDPC := srcMetric.DataPointCount()
+				if moved+srcMetricDPC <= n {
+					moved += srcMetricDPC
+					srcMetric.MoveTo(destSm.Metrics().AppendEmpty())
+					return true
+				}
+
+				// The metric has more data points than free slots, split it.
+				splitMetric(srcMetric, destSm.Metrics().AppendEmpty(), n-moved)
+				moved = n
+				return false
+			})
+			return false
+		})
+		return srcRm.ScopeMetrics().Len() == 0
+	})
+
+	return dest
+}
+
+// SplitSize removes the first data points of md, in order, up to a
+// marshaled size of maxBytes as computed by sizer, and returns them in a
+// new Metrics, as Split does.  The size of each resource, scope, metric
+// and data point is computed once, and the envelope of the resources,
+// scopes and metrics copied to both halves is accounted for as encoded
+// in the OTLP protobuf: the returned Metrics is the largest prefix of md
+// whose MetricsSize does not exceed maxBytes.
+//
+// The first data point of md is returned alone, along with its resource,
+// scope and metric, when they exceed maxBytes, so that md can be split
+// until empty.  If md does not exceed maxBytes, all of its data points
+// are moved, leaving md empty.
+func SplitSize(md Metrics, maxBytes int, sizer DeltaSizer) Metrics {
+	dest := NewMetrics()
+	// size is the size of dest, moved is its number of data points, and
+	// done is set once a data point does not fit.
+	size, moved, done := 0, 0, false
+	md.ResourceMetrics().RemoveIf(func(srcRm ResourceMetrics) bool {
+		if done {
+			return false
+		}
+
+		// If it fully fits, or holds no data point to make progress with.
+		srcRmSize := fieldSize(sizer.ResourceMetricsSize(srcRm))
+		srcRmDPC := srcRm.DataPointCount()
+		if size+srcRmSize <= maxBytes || (moved == 0 && srcRmDPC == 0) {
+			size += srcRmSize
+			moved += srcRmDPC
+			srcRm.MoveTo(dest.ResourceMetrics().AppendEmpty())
+			return true
+		}
+
+		// The resource is copied to both halves, rmSize is its size in
+		// dest.
+		destRm := NewResourceMetrics()
+		srcRm.Resource().CopyTo(destRm.Resource())
+		destRm.SetSchemaUrl(srcRm.SchemaUrl())
+		rmSize := sizer.ResourceMetricsSize(destRm)
+		srcRm.ScopeMetrics().RemoveIf(func(srcSm ScopeMetrics) bool {
+			if done {
+				return false
+			}
+
+			srcSmSize := fieldSize(sizer.ScopeMetricsSize(srcSm))
+			srcSmDPC := srcSm.DataPointCount()
+			if size+fieldSize(rmSize+srcSmSize) <= maxBytes || (moved == 0 && srcSmDPC == 0) {
+				rmSize += srcSmSize
+				moved += srcSmDPC
+				srcSm.MoveTo(destRm.ScopeMetrics().AppendEmpty())
+				return true
+			}
+
+			destSm := NewScopeMetrics()
+			srcSm.Scope().CopyTo(destSm.Scope())
+			destSm.SetSchemaUrl(srcSm.SchemaUrl())
+			smSize := sizer.ScopeMetricsSize(destSm)
+			srcSm.Metrics().RemoveIf(func(srcMetric Metric) bool {
+				if done {
+					return false
+				}
+
+				srcMetricSize := fieldSize(sizer.MetricSize(srcMetric))
+				srcMetricDPC := srcMetric.DataPointCount()
+				if size+fieldSize(rmSize+fieldSize(smSize+srcMetricSize)) <= maxBytes || (moved == 0 && srcMetricDPC == 0) {
+					smSize += srcMetricSize
+					moved += srcMetricDPC
+					srcMetric.MoveTo(destSm.Metrics().AppendEmpty())
+					return true
+				}
+
+				// The data points are held by the gauge, sum, histogram or
+				// summary of the metric, metricSize is the size of the
+				// metric without it, and dataSize the size of it.
+				destMetric := NewMetric()
+				copyMetricEnvelope(srcMetric, destMetric)
+				dataSize := metricDataSize(destMetric)
+				metricSize := sizer.MetricSize(destMetric) - fieldSize(dataSize)
+				take := func(dpSize int) bool {
+					if done {
+						return false
+					}
+					dpSize = fieldSize(dpSize)
+					if size+fieldSize(rmSize+fieldSize(smSize+fieldSize(metricSize+fieldSize(dataSize+dpSize)))) > maxBytes {
+						done = true
+						if moved > 0 {
+							return false
+						}
+					}
+					dataSize += dpSize
+					moved++
+					return true
+				}
+				switch srcMetric.Type() {
+				case MetricTypeGauge:
+					dps := destMetric.Gauge().DataPoints()
+					srcMetric.Gauge().DataPoints().RemoveIf(func(dp NumberDataPoint) bool {
+						if !take(sizer.NumberDataPointSize(dp)) {
+							return false
+						}
+						dp.MoveTo(dps.AppendEmpty())
+						return true
+					})
+				case MetricTypeSum:
+					dps := destMetric.Sum().DataPoints()
+					srcMetric.Sum().DataPoints().RemoveIf(func(dp NumberDataPoint) bool {
+						if !take(sizer.NumberDataPointSize(dp)) {
+							return false
+						}
+						dp.MoveTo(dps.AppendEmpty())
+						return true
+					})
+				case MetricTypeHistogram:
+					dps := destMetric.Histogram().DataPoints()
+					srcMetric.Histogram().DataPoints().RemoveIf(func(dp HistogramDataPoint) bool {
+						if !take(sizer.HistogramDataPointSize(dp)) {
+							return false
+						}
+						dp.MoveTo(dps.AppendEmpty())
+						return true
+					})
+				case MetricTypeExponentialHistogram:
+					dps := destMetric.ExponentialHistogram().DataPoints()
+					srcMetric.ExponentialHistogram().DataPoints().RemoveIf(func(dp ExponentialHistogramDataPoint) bool {
+						if !take(sizer.ExponentialHistogramDataPointSize(dp)) {
+							return false
+						}
+						dp.MoveTo(dps.AppendEmpty())
+						return true
+					})
+				case MetricTypeSummary:
+					dps := destMetric.Summary().DataPoints()
+					srcMetric.Summary().DataPoints().RemoveIf(func(dp SummaryDataPoint) bool {
+						if !take(sizer.SummaryDataPointSize(dp)) {
+							return false
+						}
+						dp.MoveTo(dps.AppendEmpty())
+						return true
+					})
+				}
+				if destMetric.DataPointCount() > 0 {
+					smSize += fieldSize(metricSize + fieldSize(dataSize))
+					destMetric.MoveTo(destSm.Metrics().AppendEmpty())
+				}
+				done = true
+				return srcMetric.DataPointCount() == 0
+			})
+			if destSm.Metrics().Len() > 0 {
+				rmSize += fieldSize(smSize)
+				destSm.MoveTo(destRm.ScopeMetrics().AppendEmpty())
+			}
+			done = true
+			return srcSm.Metrics().Len() == 0
+		})
+		if destRm.ScopeMetrics().Len() > 0 {
+			size += fieldSize(rmSize)
+			destRm.MoveTo(dest.ResourceMetrics().AppendEmpty())
+		}
+		return srcRm.ScopeMetrics().Len() == 0
+	})
+
+	return dest
+}
+
+// splitMetric moves the first n data points of ms, which has more than n
+// of them, to dest, along with the fields of ms.
+func splitMetric(ms, dest Metric, n int) {
+	copyMetricEnvelope(ms, dest)
+	switch ms.Type() {
+	case MetricTypeGauge:
+		splitNumberDataPoints(ms.Gauge().DataPoints(), dest.Gauge().DataPoints(), n)
+	case MetricTypeSum:
+		splitNumberDataPoints(ms.Sum().DataPoints(), dest.Sum().DataPoints(), n)
+	case MetricTypeHistogram:
+		splitHistogramDataPoints(ms.Histogram().DataPoints(), dest.Histogram().DataPoints(), n)
+	case MetricTypeExponentialHistogram:
+		splitExponentialHistogramDataPoints(ms.ExponentialHistogram().DataPoints(), dest.ExponentialHistogram().DataPoints(), n)
+	case MetricTypeSummary:
+		splitSummaryDataPoints(ms.Summary().DataPoints(), dest.Summary().DataPoints(), n)
+	}
+}
+
+// copyMetricEnvelope copies the fields of ms to dest, and sets the data of
+// dest to an empty gauge, sum, histogram or summary with the fields of
+// that of ms.
+func copyMetricEnvelope(ms, dest Metric) {
+	dest.SetName(ms.Name())
+	dest.SetDescription(ms.Description())
+	dest.SetUnit(ms.Unit())
+
+	switch ms.Type() {
+	case MetricTypeGauge:
+		dest.SetEmptyGauge()
+	case MetricTypeSum:
+		destSum := dest.SetEmptySum()
+		destSum.SetAggregationTemporality(ms.Sum().AggregationTemporality())
+		destSum.SetIsMonotonic(ms.Sum().IsMonotonic())
+	case MetricTypeHistogram:
+		dest.SetEmptyHistogram().SetAggregationTemporality(ms.Histogram().AggregationTemporality())
+	case MetricTypeExponentialHistogram:
+		dest.SetEmptyExponentialHistogram().SetAggregationTemporality(ms.ExponentialHistogram().AggregationTemporality())
+	case MetricTypeSummary:
+		dest.SetEmptySummary()
+	}
+}
+
+// metricDataSize returns the size of the marshaled gauge, sum, histogram
+// or summary of ms.
+func metricDataSize(ms Metric) int {
+	switch ms.Type() {
+	case MetricTypeGauge:
+		return ms.orig.GetGauge().Size()
+	case MetricTypeSum:
+		return ms.orig.GetSum().Size()
+	case MetricTypeHistogram:
+		return ms.orig.GetHistogram().Size()
+	case MetricTypeExponentialHistogram:
+		return ms.orig.GetExponentialHistogram().Size()
+	case MetricTypeSummary:
+		return ms.orig.GetSummary().Size()
+	}
+	return 0
+}
+
+func splitNumberDataPoints(src, dest NumberDataPointSlice, n int) {
+	dest.EnsureCapacity(n)
+	i := 0
+	src.RemoveIf(func(dp NumberDataPoint) bool {
+		if i < n {
+			dp.MoveTo(dest.AppendEmpty())
+			i++
+			return true
+		}
+		return false
+	})
+}
+
+func splitHistogramDataPoints(src, dest HistogramDataPointSlice, n int) {
+	dest.EnsureCapacity(n)
+	i := 0
+	src.RemoveIf(func(dp HistogramDataPoint) bool {
+		if i < n {
+			dp.MoveTo(dest.AppendEmpty())
+			i++
+			return true
+		}
+		return false
+	})
+}
+
+func splitExponentialHistogramDataPoints(src, dest ExponentialHistogramDataPointSlice, n int) {
+	dest.EnsureCapacity(n)
+	i := 0
+	src.RemoveIf(func(dp ExponentialHistogramDataPoint) bool {
+		if i < n {
+			dp.MoveTo(dest.AppendEmpty())
+			i++
+			return true
+		}
+		return false
+	})
+}
+
+func splitSummaryDataPoints(src, dest SummaryDataPointSlice, n int) {
+	dest.EnsureCapacity(n)
+	i := 0
+	src.RemoveIf(func(dp SummaryDataPoint) bool {
+		if i < n {
+			dp.MoveTo(dest.AppendEmpty())
+			i++
+			return true
+		}
+		return false
+	})
+}
+
+// fieldSize returns the size of a message of size bytes as a field of its
+// parent, with its tag and length.
+func fieldSize(size int) int {
+	return 1 + proto.SizeVarint(uint64(size)) + size
+}
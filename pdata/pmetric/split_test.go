@@ -0,0 +1,404 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pmetric
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+var splitTestTypes = []MetricType{
+	MetricTypeGauge,
+	MetricTypeSum,
+	MetricTypeHistogram,
+	MetricTypeExponentialHistogram,
+	MetricTypeSummary,
+}
+
+// splitTestMetrics returns metrics of resources of a scope with a metric
+// of each type of points data points, the data points identified by the
+// indexes of their resource, metric and position, and the resources,
+// scopes and metrics named after those of their data points.
+func splitTestMetrics(resources, points int) Metrics {
+	md := NewMetrics()
+	for r := 0; r < resources; r++ {
+		rm := md.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().PutStr("resource", fmt.Sprint(r))
+		rm.SetSchemaUrl(fmt.Sprintf("https://%d", r))
+		sm := rm.ScopeMetrics().AppendEmpty()
+		sm.Scope().SetName(fmt.Sprint(r))
+		sm.SetSchemaUrl(fmt.Sprintf("https://%d", r))
+		for m, typ := range splitTestTypes {
+			metric := sm.Metrics().AppendEmpty()
+			metric.SetName(fmt.Sprintf("%d-%d", r, m))
+			metric.SetDescription("description " + metric.Name())
+			metric.SetUnit("unit " + metric.Name())
+			for i := 0; i < points; i++ {
+				id := fmt.Sprintf("%d-%d-%d", r, m, i)
+				switch typ {
+				case MetricTypeGauge:
+					if i == 0 {
+						metric.SetEmptyGauge()
+					}
+					metric.Gauge().DataPoints().AppendEmpty().Attributes().PutStr("id", id)
+				case MetricTypeSum:
+					if i == 0 {
+						metric.SetEmptySum().SetIsMonotonic(true)
+						metric.Sum().SetAggregationTemporality(AggregationTemporalityCumulative)
+					}
+					metric.Sum().DataPoints().AppendEmpty().Attributes().PutStr("id", id)
+				case MetricTypeHistogram:
+					if i == 0 {
+						metric.SetEmptyHistogram().SetAggregationTemporality(AggregationTemporalityDelta)
+					}
+					metric.Histogram().DataPoints().AppendEmpty().Attributes().PutStr("id", id)
+				case MetricTypeExponentialHistogram:
+					if i == 0 {
+						metric.SetEmptyExponentialHistogram().SetAggregationTemporality(AggregationTemporalityCumulative)
+					}
+					metric.ExponentialHistogram().DataPoints().AppendEmpty().Attributes().PutStr("id", id)
+				case MetricTypeSummary:
+					if i == 0 {
+						metric.SetEmptySummary()
+					}
+					metric.Summary().DataPoints().AppendEmpty().Attributes().PutStr("id", id)
+				}
+			}
+		}
+	}
+	return md
+}
+
+// splitTestAttributes returns the attributes of the data points of
+// metric.
+func splitTestAttributes(metric Metric) []pcommon.Map {
+	var attrs []pcommon.Map
+	switch metric.Type() {
+	case MetricTypeGauge:
+		for i := 0; i < metric.Gauge().DataPoints().Len(); i++ {
+			attrs = append(attrs, metric.Gauge().DataPoints().At(i).Attributes())
+		}
+	case MetricTypeSum:
+		for i := 0; i < metric.Sum().DataPoints().Len(); i++ {
+			attrs = append(attrs, metric.Sum().DataPoints().At(i).Attributes())
+		}
+	case MetricTypeHistogram:
+		for i := 0; i < metric.Histogram().DataPoints().Len(); i++ {
+			attrs = append(attrs, metric.Histogram().DataPoints().At(i).Attributes())
+		}
+	case MetricTypeExponentialHistogram:
+		for i := 0; i < metric.ExponentialHistogram().DataPoints().Len(); i++ {
+			attrs = append(attrs, metric.ExponentialHistogram().DataPoints().At(i).Attributes())
+		}
+	case MetricTypeSummary:
+		for i := 0; i < metric.Summary().DataPoints().Len(); i++ {
+			attrs = append(attrs, metric.Summary().DataPoints().At(i).Attributes())
+		}
+	}
+	return attrs
+}
+
+// splitDataPointIDs returns the ids of the data points of md, in order,
+// and checks that each data point is under the resource, scope and
+// metric it was created with.
+func splitDataPointIDs(t *testing.T, md Metrics) []string {
+	ids := []string{}
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		rm := md.ResourceMetrics().At(i)
+		r, ok := rm.Resource().Attributes().Get("resource")
+		require.True(t, ok)
+		assert.Equal(t, "https://"+r.Str(), rm.SchemaUrl())
+		require.Equal(t, 1, rm.ScopeMetrics().Len())
+		sm := rm.ScopeMetrics().At(0)
+		assert.Equal(t, r.Str(), sm.Scope().Name())
+		assert.Equal(t, "https://"+r.Str(), sm.SchemaUrl())
+		require.NotZero(t, sm.Metrics().Len())
+		for j := 0; j < sm.Metrics().Len(); j++ {
+			metric := sm.Metrics().At(j)
+			require.True(t, strings.HasPrefix(metric.Name(), r.Str()+"-"))
+			var m int
+			_, err := fmt.Sscanf(metric.Name()[len(r.Str())+1:], "%d", &m)
+			require.NoError(t, err)
+			require.Equal(t, splitTestTypes[m], metric.Type())
+			assert.Equal(t, "description "+metric.Name(), metric.Description())
+			assert.Equal(t, "unit "+metric.Name(), metric.Unit())
+
+			switch metric.Type() {
+			case MetricTypeSum:
+				assert.True(t, metric.Sum().IsMonotonic())
+				assert.Equal(t, AggregationTemporalityCumulative, metric.Sum().AggregationTemporality())
+			case MetricTypeHistogram:
+				assert.Equal(t, AggregationTemporalityDelta, metric.Histogram().AggregationTemporality())
+			case MetricTypeExponentialHistogram:
+				assert.Equal(t, AggregationTemporalityCumulative, metric.ExponentialHistogram().AggregationTemporality())
+			}
+			attrs := splitTestAttributes(metric)
+			require.NotEmpty(t, attrs)
+			for _, a := range attrs {
+				id, ok := a.Get("id")
+				require.True(t, ok)
+				assert.True(t, strings.HasPrefix(id.Str(), metric.Name()+"-"))
+				ids = append(ids, id.Str())
+			}
+		}
+	}
+	return ids
+}
+
+func TestSplit(t *testing.T) {
+	all := splitDataPointIDs(t, splitTestMetrics(2, 3))
+	for _, n := range []int{-1, 0, 1, 2, 3, 4, 8, 14, 15, 16, 29, 30, 31} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			md := splitTestMetrics(2, 3)
+			split := Split(md, n)
+
+			moved := n
+			if moved < 0 {
+				moved = 0
+			} else if moved > len(all) {
+				moved = len(all)
+			}
+			assert.Equal(t, moved, split.DataPointCount())
+			assert.Equal(t, len(all)-moved, md.DataPointCount())
+			assert.Equal(t, all[:moved], splitDataPointIDs(t, split))
+			assert.Equal(t, all[moved:], splitDataPointIDs(t, md))
+		})
+	}
+}
+
+func TestSplitMetricTypes(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+	}{
+		{name: "first", n: 1},
+		{name: "middle", n: 3},
+		{name: "last", n: 4},
+		{name: "all", n: 5},
+	}
+	for m, typ := range splitTestTypes {
+		for _, tt := range tests {
+			t.Run(typ.String()+"/"+tt.name, func(t *testing.T) {
+				// A single metric of 5 data points.
+				md := splitTestMetrics(1, 5)
+				md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().RemoveIf(func(metric Metric) bool {
+					return metric.Type() != typ
+				})
+				all := splitDataPointIDs(t, md)
+				require.Len(t, all, 5)
+
+				split := Split(md, tt.n)
+				// Both halves hold the metric with its fields, checked by
+				// splitDataPointIDs, and no empty metric is left.
+				assert.Equal(t, all[:tt.n], splitDataPointIDs(t, split))
+				assert.Equal(t, all[tt.n:], splitDataPointIDs(t, md))
+				assert.Equal(t, 1, split.MetricCount())
+				assert.Equal(t, fmt.Sprintf("0-%d", m), split.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Name())
+				if tt.n == len(all) {
+					assert.Equal(t, 0, md.ResourceMetrics().Len())
+				} else {
+					assert.Equal(t, 1, md.MetricCount())
+				}
+			})
+		}
+	}
+}
+
+func TestSplitUnchanged(t *testing.T) {
+	md := splitTestMetrics(2, 3)
+	split := Split(md, 0)
+	assert.Equal(t, NewMetrics(), split)
+	assert.Equal(t, splitTestMetrics(2, 3), md)
+}
+
+func TestSplitAll(t *testing.T) {
+	md := splitTestMetrics(2, 3)
+	split := Split(md, 30)
+	assert.Equal(t, splitTestMetrics(2, 3), split)
+	assert.Equal(t, 0, md.ResourceMetrics().Len())
+}
+
+func TestSplitRepeated(t *testing.T) {
+	md := splitTestMetrics(3, 4)
+	all := splitDataPointIDs(t, md)
+	ids := []string{}
+	for md.DataPointCount() > 0 {
+		split := Split(md, 7)
+		assert.LessOrEqual(t, split.DataPointCount(), 7)
+		ids = append(ids, splitDataPointIDs(t, split)...)
+	}
+	assert.Equal(t, all, ids)
+	assert.Equal(t, 0, md.ResourceMetrics().Len())
+}
+
+// splitSizeTestMetrics returns the metrics of splitTestMetrics, with data
+// points of various sizes.
+func splitSizeTestMetrics(resources, points int) Metrics {
+	md := splitTestMetrics(resources, points)
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		ms := md.ResourceMetrics().At(i).ScopeMetrics().At(0).Metrics()
+		for j := 0; j < ms.Len(); j++ {
+			for k, attrs := range splitTestAttributes(ms.At(j)) {
+				attrs.PutStr("padding", strings.Repeat("x", (i*7+j*3+k*11)%50))
+			}
+		}
+	}
+	return md
+}
+
+// splitSizeByMarshaling is the naive SplitSize, binary searching the
+// number of data points to split by sizing the candidates.
+func splitSizeByMarshaling(md Metrics, maxBytes int, sizer DeltaSizer) Metrics {
+	lo, hi := 1, md.DataPointCount()
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		candidate := NewMetrics()
+		md.CopyTo(candidate)
+		if sizer.MetricsSize(Split(candidate, mid)) <= maxBytes {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return Split(md, lo)
+}
+
+// assertSameMetrics asserts that expected and actual marshal the same.
+func assertSameMetrics(t *testing.T, expected, actual Metrics) {
+	marshaler := &ProtoMarshaler{}
+	expectedBuf, err := marshaler.MarshalMetrics(expected)
+	require.NoError(t, err)
+	actualBuf, err := marshaler.MarshalMetrics(actual)
+	require.NoError(t, err)
+	require.Equal(t, expectedBuf, actualBuf)
+}
+
+func TestSplitSize(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	total := sizer.MetricsSize(splitSizeTestMetrics(3, 4))
+	for maxBytes := 0; maxBytes <= total+10; maxBytes += 3 {
+		md := splitSizeTestMetrics(3, 4)
+		split := SplitSize(md, maxBytes, sizer)
+
+		expectedMd := splitSizeTestMetrics(3, 4)
+		expected := splitSizeByMarshaling(expectedMd, maxBytes, sizer)
+		assertSameMetrics(t, expected, split)
+		assertSameMetrics(t, expectedMd, md)
+		if split.DataPointCount() > 1 {
+			assert.LessOrEqual(t, sizer.MetricsSize(split), maxBytes)
+		}
+	}
+}
+
+func TestSplitSizeRepeated(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	md := splitSizeTestMetrics(3, 4)
+	all := splitDataPointIDs(t, md)
+	ids := []string{}
+	for md.DataPointCount() > 0 {
+		split := SplitSize(md, 300, sizer)
+		assert.LessOrEqual(t, sizer.MetricsSize(split), 300)
+		ids = append(ids, splitDataPointIDs(t, split)...)
+	}
+	assert.Equal(t, all, ids)
+	assert.Equal(t, 0, md.ResourceMetrics().Len())
+}
+
+func TestSplitSizeItemExceedsBudget(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	md := splitTestMetrics(1, 3)
+	md.ResourceMetrics().At(0).Resource().Attributes().PutStr("padding", strings.Repeat("x", 1000))
+
+	// The envelope alone exceeds the budget, the first data point is
+	// returned with it.
+	split := SplitSize(md, 100, sizer)
+	assert.Equal(t, []string{"0-0-0"}, splitDataPointIDs(t, split))
+	assert.Equal(t, 14, md.DataPointCount())
+	padding, ok := md.ResourceMetrics().At(0).Resource().Attributes().Get("padding")
+	require.True(t, ok)
+	assert.Len(t, padding.Str(), 1000)
+}
+
+func TestSplitSizeAll(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	md := splitSizeTestMetrics(2, 3)
+	split := SplitSize(md, sizer.MetricsSize(md), sizer)
+	assert.Equal(t, splitSizeTestMetrics(2, 3), split)
+	assert.Equal(t, 0, md.ResourceMetrics().Len())
+}
+
+// randomSplitTestMetrics returns metrics named as by splitTestMetrics, of
+// random numbers of resources, metrics and data points.
+func randomSplitTestMetrics(r *rand.Rand) Metrics {
+	md := splitSizeTestMetrics(1+r.Intn(5), 1+r.Intn(6))
+	md.ResourceMetrics().RemoveIf(func(rm ResourceMetrics) bool {
+		ms := rm.ScopeMetrics().At(0).Metrics()
+		ms.RemoveIf(func(Metric) bool { return r.Intn(3) == 0 })
+		return ms.Len() == 0
+	})
+	return md
+}
+
+func TestSplitOrder(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	sizer := &ProtoMarshaler{}
+	for i := 0; i < 100; i++ {
+		md := randomSplitTestMetrics(r)
+		all := splitDataPointIDs(t, md)
+		ids := []string{}
+		for md.DataPointCount() > 0 {
+			var split Metrics
+			if r.Intn(2) == 0 {
+				split = Split(md, 1+r.Intn(10))
+			} else {
+				split = SplitSize(md, r.Intn(1000), sizer)
+			}
+			ids = append(ids, splitDataPointIDs(t, split)...)
+			require.Equal(t, all[len(ids):], splitDataPointIDs(t, md))
+		}
+		assert.Equal(t, all, ids)
+	}
+}
+
+func benchmarkSplitSize(b *testing.B, split func(Metrics, int, DeltaSizer) Metrics) {
+	sizer := &ProtoMarshaler{}
+	md := splitSizeTestMetrics(20, 100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		cp := NewMetrics()
+		md.CopyTo(cp)
+		b.StartTimer()
+		for cp.DataPointCount() > 0 {
+			split(cp, 64*1024, sizer)
+		}
+	}
+}
+
+func BenchmarkSplitSize(b *testing.B) {
+	benchmarkSplitSize(b, SplitSize)
+}
+
+func BenchmarkSplitSizeByMarshaling(b *testing.B) {
+	benchmarkSplitSize(b, splitSizeByMarshaling)
+}
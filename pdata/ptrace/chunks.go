@@ -0,0 +1,247 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptrace // import "go.opentelemetry.io/collector/pdata/ptrace"
+
+// ChunkOption limits the chunks returned by a ChunkIterator.
+type ChunkOption func(*chunkOptions)
+
+type chunkOptions struct {
+	maxItems int
+	maxBytes int
+	sizer    DeltaSizer
+}
+
+// WithMaxItems limits the chunks to n spans.  It is ignored when n is
+// less than or equal to zero.
+func WithMaxItems(n int) ChunkOption {
+	return func(o *chunkOptions) {
+		o.maxItems = n
+	}
+}
+
+// WithMaxBytes limits the chunks to a marshaled size of maxBytes, as
+// computed by sizer.
+func WithMaxBytes(maxBytes int, sizer DeltaSizer) ChunkOption {
+	return func(o *chunkOptions) {
+		o.maxBytes = maxBytes
+		o.sizer = sizer
+	}
+}
+
+// ChunkIterator returns the spans of a Traces in successive chunks within
+// limits, moving them out of the Traces.  Unlike repeated calls to Split
+// or SplitSize, which remove the spans from the front of the Traces every
+// time, it keeps its position in the Traces, so that every resource,
+// scope and span is only visited once.
+//
+// The chunks are filled as SplitSize does: a chunk holds the spans of
+// the Traces in order, as many as fit in the limits, and the first span
+// of a chunk is returned alone, along with its resource and scope, when
+// it exceeds them.  The concatenation of the chunks is the original
+// sequence of spans.
+type ChunkIterator struct {
+	opts  chunkOptions
+	td    Traces
+	chunk Traces
+
+	// rs, ss and span are the position in td of the next span to
+	// return, the spans before it being moved out.
+	rs, ss, span int
+
+	// size and items are those of the chunk being filled, full is set
+	// once a span does not fit in it.
+	size, items int
+	full        bool
+}
+
+// Chunks returns a ChunkIterator over the spans of td.  td must not be
+// used until Next returns false, leaving td empty, or Stop is called.
+// Without any limit, all of the spans are returned in a single chunk.
+//
+//	it := ptrace.Chunks(td, ptrace.WithMaxItems(n))
+//	for it.Next() {
+//		chunk := it.Chunk()
+//		...
+//	}
+func Chunks(td Traces, opts ...ChunkOption) *ChunkIterator {
+	it := &ChunkIterator{td: td}
+	for _, opt := range opts {
+		opt(&it.opts)
+	}
+	return it
+}
+
+// Next moves the next chunk out of the Traces, returning false when it
+// is empty.  The chunk is a new Traces, sharing no data with the spans
+// not returned yet, and remains valid after the following calls.
+func (it *ChunkIterator) Next() bool {
+	rss := it.td.ResourceSpans()
+	if it.rs >= rss.Len() {
+		it.chunk = Traces{}
+		it.Stop()
+		return false
+	}
+	it.chunk = NewTraces()
+	it.size, it.items, it.full = 0, 0, false
+	for !it.full && it.rs < rss.Len() {
+		if it.nextResource(rss.At(it.rs)) {
+			it.rs, it.ss, it.span = it.rs+1, 0, 0
+		}
+	}
+	return true
+}
+
+// Chunk returns the chunk moved out by the last call to Next.
+func (it *ChunkIterator) Chunk() Traces {
+	return it.chunk
+}
+
+// Stop ends the iteration, removing the spans already returned from the
+// Traces, which is left with the others and can be used again.  Next
+// may be called again to resume the iteration.
+func (it *ChunkIterator) Stop() {
+	i := -1
+	it.td.ResourceSpans().RemoveIf(func(rs ResourceSpans) bool {
+		i++
+		if i != it.rs {
+			return i < it.rs
+		}
+		j := -1
+		rs.ScopeSpans().RemoveIf(func(ss ScopeSpans) bool {
+			j++
+			if j != it.ss {
+				return j < it.ss
+			}
+			k := -1
+			ss.Spans().RemoveIf(func(Span) bool {
+				k++
+				return k < it.span
+			})
+			return false
+		})
+		return false
+	})
+	it.rs, it.ss, it.span = 0, 0, 0
+}
+
+// fits returns whether items more spans fit in the chunk, once of the
+// given size.
+func (it *ChunkIterator) fits(items, size int) bool {
+	return (it.opts.maxItems <= 0 || it.items+items <= it.opts.maxItems) &&
+		(it.opts.sizer == nil || size <= it.opts.maxBytes)
+}
+
+// nextResource moves the spans of srcRs that fit to the chunk, returning
+// whether all of them were moved.
+func (it *ChunkIterator) nextResource(srcRs ResourceSpans) bool {
+	if it.ss == 0 && it.span == 0 {
+		// If it fully fits, or holds no span to make progress with.
+		srcRsSize := fieldSize(it.resourceSpansSize(srcRs))
+		srcRsSC := srcRs.SpanCount()
+		if it.fits(srcRsSC, it.size+srcRsSize) || (it.items == 0 && srcRsSC == 0) {
+			it.size += srcRsSize
+			it.items += srcRsSC
+			srcRs.MoveTo(it.chunk.ResourceSpans().AppendEmpty())
+			return true
+		}
+	}
+
+	sss := srcRs.ScopeSpans()
+	if sss.Len() == 0 {
+		// Left for the next chunk.
+		it.full = true
+		return false
+	}
+	// The resource is copied to the chunk, rsSize is its size there.
+	destRs := NewResourceSpans()
+	srcRs.Resource().CopyTo(destRs.Resource())
+	destRs.SetSchemaUrl(srcRs.SchemaUrl())
+	rsSize := it.resourceSpansSize(destRs)
+	for !it.full && it.ss < sss.Len() {
+		if it.nextScope(&rsSize, destRs, sss.At(it.ss)) {
+			it.ss, it.span = it.ss+1, 0
+		}
+	}
+	if destRs.ScopeSpans().Len() > 0 {
+		it.size += fieldSize(rsSize)
+		destRs.MoveTo(it.chunk.ResourceSpans().AppendEmpty())
+	}
+	return it.ss == sss.Len()
+}
+
+// nextScope moves the spans of srcSs that fit to destRs, of size rsSize,
+// returning whether all of them were moved.
+func (it *ChunkIterator) nextScope(rsSize *int, destRs ResourceSpans, srcSs ScopeSpans) bool {
+	spans := srcSs.Spans()
+	if it.span == 0 {
+		srcSsSize := fieldSize(it.scopeSpansSize(srcSs))
+		if it.fits(spans.Len(), it.size+fieldSize(*rsSize+srcSsSize)) || (it.items == 0 && spans.Len() == 0) {
+			*rsSize += srcSsSize
+			it.items += spans.Len()
+			srcSs.MoveTo(destRs.ScopeSpans().AppendEmpty())
+			return true
+		}
+	}
+
+	if spans.Len() == 0 {
+		// Left for the next chunk.
+		it.full = true
+		return false
+	}
+	destSs := NewScopeSpans()
+	srcSs.Scope().CopyTo(destSs.Scope())
+	destSs.SetSchemaUrl(srcSs.SchemaUrl())
+	ssSize := it.scopeSpansSize(destSs)
+	// The spans are moved without allocating new ones, leaving nil in
+	// td until Stop removes them.
+	srcOrig, destOrig := *spans.orig, destSs.Spans().orig
+	for ; it.span < spans.Len(); it.span++ {
+		spanSize := fieldSize(it.spanSize(spans.At(it.span)))
+		if !it.fits(1, it.size+fieldSize(*rsSize+fieldSize(ssSize+spanSize))) && it.items > 0 {
+			it.full = true
+			break
+		}
+		ssSize += spanSize
+		it.items++
+		*destOrig = append(*destOrig, srcOrig[it.span])
+		srcOrig[it.span] = nil
+	}
+	if destSs.Spans().Len() > 0 {
+		*rsSize += fieldSize(ssSize)
+		destSs.MoveTo(destRs.ScopeSpans().AppendEmpty())
+	}
+	return it.span == spans.Len()
+}
+
+func (it *ChunkIterator) resourceSpansSize(rs ResourceSpans) int {
+	if it.opts.sizer == nil {
+		return 0
+	}
+	return it.opts.sizer.ResourceSpansSize(rs)
+}
+
+func (it *ChunkIterator) scopeSpansSize(ss ScopeSpans) int {
+	if it.opts.sizer == nil {
+		return 0
+	}
+	return it.opts.sizer.ScopeSpansSize(ss)
+}
+
+func (it *ChunkIterator) spanSize(span Span) int {
+	if it.opts.sizer == nil {
+		return 0
+	}
+	return it.opts.sizer.SpanSize(span)
+}
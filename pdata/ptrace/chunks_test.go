@@ -0,0 +1,210 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptrace
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// allChunks returns the chunks of td.
+func allChunks(td Traces, opts ...ChunkOption) []Traces {
+	var chunks []Traces
+	for it := Chunks(td, opts...); it.Next(); {
+		chunks = append(chunks, it.Chunk())
+	}
+	return chunks
+}
+
+func TestChunksMaxItems(t *testing.T) {
+	for _, n := range []int{1, 3, 10, 29, 90, 1000} {
+		td := splitTestTraces(3, 3, 10)
+		expected := splitTestTraces(3, 3, 10)
+		for _, chunk := range allChunks(td, WithMaxItems(n)) {
+			assertSameTraces(t, Split(expected, n), chunk)
+		}
+		assert.Equal(t, 0, expected.ResourceSpans().Len())
+		assert.Equal(t, 0, td.ResourceSpans().Len())
+	}
+}
+
+func TestChunksMaxBytes(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	total := sizer.TracesSize(splitSizeTestTraces(3, 3, 10))
+	for maxBytes := 0; maxBytes <= total+10; maxBytes += 37 {
+		td := splitSizeTestTraces(3, 3, 10)
+		expected := splitSizeTestTraces(3, 3, 10)
+		for _, chunk := range allChunks(td, WithMaxBytes(maxBytes, sizer)) {
+			assertSameTraces(t, SplitSize(expected, maxBytes, sizer), chunk)
+		}
+		assert.Equal(t, 0, expected.ResourceSpans().Len())
+		assert.Equal(t, 0, td.ResourceSpans().Len())
+	}
+}
+
+func TestChunksWithoutLimits(t *testing.T) {
+	td := splitTestTraces(2, 2, 5)
+	chunks := allChunks(td)
+	require.Len(t, chunks, 1)
+	assertSameTraces(t, splitTestTraces(2, 2, 5), chunks[0])
+	assert.Empty(t, allChunks(NewTraces()))
+}
+
+func TestChunksItemExceedsBudget(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	td := splitTestTraces(1, 3, 1)
+	td.ResourceSpans().At(0).Resource().Attributes().PutStr("padding", strings.Repeat("x", 1000))
+
+	// Every span is returned alone, with the resource.
+	var names [][]string
+	for _, chunk := range allChunks(td, WithMaxBytes(100, sizer)) {
+		names = append(names, splitSpanNames(t, chunk))
+		assert.Equal(t, 2, chunk.ResourceSpans().At(0).Resource().Attributes().Len())
+	}
+	assert.Equal(t, [][]string{{"0-0-0"}, {"0-1-0"}, {"0-2-0"}}, names)
+}
+
+func TestChunksEmptyContainers(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	for _, maxBytes := range []int{0, 50, 100, 1000} {
+		td := splitTestTraces(3, 2, 2)
+		td.ResourceSpans().At(0).ScopeSpans().At(1).Spans().RemoveIf(func(Span) bool { return true })
+		td.ResourceSpans().At(1).ScopeSpans().RemoveIf(func(ScopeSpans) bool { return true })
+
+		// The resource and scope without spans are kept, once.
+		var emptyResources, emptyScopes []string
+		for _, chunk := range allChunks(td, WithMaxBytes(maxBytes, sizer)) {
+			for i := 0; i < chunk.ResourceSpans().Len(); i++ {
+				rs := chunk.ResourceSpans().At(i)
+				if rs.ScopeSpans().Len() == 0 {
+					emptyResources = append(emptyResources, rs.SchemaUrl())
+				}
+				for j := 0; j < rs.ScopeSpans().Len(); j++ {
+					if ss := rs.ScopeSpans().At(j); ss.Spans().Len() == 0 {
+						emptyScopes = append(emptyScopes, ss.Scope().Name())
+					}
+				}
+			}
+		}
+		assert.Equal(t, []string{"https://1"}, emptyResources)
+		assert.Equal(t, []string{"0-1"}, emptyScopes)
+	}
+}
+
+func TestChunksIndependent(t *testing.T) {
+	td := splitTestTraces(1, 1, 10)
+	it := Chunks(td, WithMaxItems(3))
+	require.True(t, it.Next())
+	first := it.Chunk()
+
+	// Changing a chunk does not change the spans not returned yet.
+	first.ResourceSpans().At(0).Resource().Attributes().PutStr("resource", "changed")
+	first.ResourceSpans().At(0).ScopeSpans().At(0).Scope().SetName("changed")
+	first.ResourceSpans().At(0).ScopeSpans().At(0).Spans().AppendEmpty()
+	var names []string
+	for it.Next() {
+		names = append(names, splitSpanNames(t, it.Chunk())...)
+	}
+	assert.Equal(t, []string{"0-0-3", "0-0-4", "0-0-5", "0-0-6", "0-0-7", "0-0-8", "0-0-9"}, names)
+	assert.Equal(t, 4, first.SpanCount())
+}
+
+func TestChunksStop(t *testing.T) {
+	td := splitTestTraces(2, 2, 3)
+	it := Chunks(td, WithMaxItems(4))
+	require.True(t, it.Next())
+	assert.Equal(t, []string{"0-0-0", "0-0-1", "0-0-2", "0-1-0"}, splitSpanNames(t, it.Chunk()))
+	require.True(t, it.Next())
+	assert.Equal(t, []string{"0-1-1", "0-1-2", "1-0-0", "1-0-1"}, splitSpanNames(t, it.Chunk()))
+	it.Stop()
+	assert.Equal(t, []string{"1-0-2", "1-1-0", "1-1-1", "1-1-2"}, splitSpanNames(t, td))
+	assert.Equal(t, 4, td.SpanCount())
+
+	// The iteration resumes with the remaining spans.
+	require.True(t, it.Next())
+	assert.Equal(t, []string{"1-0-2", "1-1-0", "1-1-1", "1-1-2"}, splitSpanNames(t, it.Chunk()))
+	assert.False(t, it.Next())
+	assert.Equal(t, 0, td.ResourceSpans().Len())
+}
+
+func TestChunksOrder(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	sizer := &ProtoMarshaler{}
+	for i := 0; i < 100; i++ {
+		td := randomSplitTestTraces(r)
+		all := splitSpanNames(t, td)
+		maxItems, maxBytes := 1+r.Intn(10), r.Intn(1000)
+		names := []string{}
+		for _, chunk := range allChunks(td, WithMaxItems(maxItems), WithMaxBytes(maxBytes, sizer)) {
+			chunkNames := splitSpanNames(t, chunk)
+			require.NotEmpty(t, chunkNames)
+			assert.LessOrEqual(t, len(chunkNames), maxItems)
+			if len(chunkNames) > 1 {
+				assert.LessOrEqual(t, sizer.TracesSize(chunk), maxBytes)
+			}
+			names = append(names, chunkNames...)
+		}
+		assert.Equal(t, all, names)
+	}
+}
+
+func benchmarkChunks(b *testing.B, chunks func(td Traces)) {
+	td := splitTestTraces(10, 10, 1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		cp := NewTraces()
+		td.CopyTo(cp)
+		b.StartTimer()
+		chunks(cp)
+	}
+}
+
+func BenchmarkChunks(b *testing.B) {
+	benchmarkChunks(b, func(td Traces) {
+		for it := Chunks(td, WithMaxItems(100)); it.Next(); {
+		}
+	})
+}
+
+func BenchmarkChunksSplit(b *testing.B) {
+	benchmarkChunks(b, func(td Traces) {
+		for td.SpanCount() > 0 {
+			Split(td, 100)
+		}
+	})
+}
+
+func BenchmarkChunksMaxBytes(b *testing.B) {
+	sizer := &ProtoMarshaler{}
+	benchmarkChunks(b, func(td Traces) {
+		for it := Chunks(td, WithMaxBytes(64*1024, sizer)); it.Next(); {
+		}
+	})
+}
+
+func BenchmarkChunksSplitSize(b *testing.B) {
+	sizer := &ProtoMarshaler{}
+	benchmarkChunks(b, func(td Traces) {
+		for td.SpanCount() > 0 {
+			SplitSize(td, 64*1024, sizer)
+		}
+	})
+}
@@ -40,3 +40,31 @@ type Sizer interface {
 	// TracesSize returns the size in bytes of a marshaled Traces.
 	TracesSize(td Traces) int
 }
+
+// DeltaSizer is an optional interface implemented by the Sizer, that
+// calculates the size of the parts of a marshaled Traces, as needed to
+// split it by size.  Callers holding a Sizer detect it with a type
+// assertion.
+type DeltaSizer interface {
+	Sizer
+
+	// ResourceSpansSize returns the size in bytes of a marshaled ResourceSpans, without
+	// the tag and length of its field in its parent.
+	ResourceSpansSize(rs ResourceSpans) int
+
+	// ScopeSpansSize returns the size in bytes of a marshaled ScopeSpans, without
+	// the tag and length of its field in its parent.
+	ScopeSpansSize(ss ScopeSpans) int
+
+	// SpanSize returns the size in bytes of a marshaled Span, without
+	// the tag and length of its field in its parent.
+	SpanSize(span Span) int
+
+	// SpanDeltaSize returns the size a Span adds to a marshaled ScopeSpans,
+	// with the tag and length of its field.
+	SpanDeltaSize(span Span) int
+
+	// TracesOverheadSize returns the size of a marshaled Traces beyond the
+	// SpanDeltaSize of its spans.
+	TracesOverheadSize(td Traces) int
+}
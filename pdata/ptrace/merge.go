@@ -0,0 +1,106 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptrace // import "go.opentelemetry.io/collector/pdata/ptrace"
+
+import (
+	"go.opentelemetry.io/collector/pdata/internal/dedup"
+)
+
+// MergeOption configures MergeInto.
+type MergeOption func(*mergeOptions)
+
+type mergeOptions struct {
+	withoutDeduplication bool
+}
+
+// WithoutDeduplication makes MergeInto append the resources of src to
+// dst as they are, as MoveAndAppendTo does.
+func WithoutDeduplication() MergeOption {
+	return func(o *mergeOptions) {
+		o.withoutDeduplication = true
+	}
+}
+
+// MergeInto moves the spans of src to dst, leaving src empty.  The
+// scopes of a ResourceSpans of src are moved to the ResourceSpans of dst
+// with an equal resource and schema URL, and the spans of a ScopeSpans to
+// the ScopeSpans of that ResourceSpans with an equal scope and schema
+// URL, after its spans.  Resources are equal when they have the same
+// attributes, in any order, and dropped attributes count, and scopes
+// when they have the same name, version, attributes, in any order, and
+// dropped attributes count.  The others are appended to dst, so that
+// equal ResourceSpans or ScopeSpans of src are merged too.
+//
+// Equal ResourceSpans or ScopeSpans already in dst are not merged, the
+// spans of src are moved to the first of them.  dst and src must be
+// different Traces.
+func MergeInto(dst, src Traces, opts ...MergeOption) {
+	var o mergeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.withoutDeduplication {
+		src.ResourceSpans().MoveAndAppendTo(dst.ResourceSpans())
+		return
+	}
+
+	rss := dst.ResourceSpans()
+	resources := make(dedup.Index, rss.Len())
+	for i := 0; i < rss.Len(); i++ {
+		resources.Put(dedup.HashResource(rss.At(i).Resource(), rss.At(i).SchemaUrl()), i)
+	}
+	// scopes holds the index of the scopes of every ResourceSpans of dst,
+	// built when first needed.
+	scopes := make([]dedup.Index, rss.Len())
+
+	srcRss := src.ResourceSpans()
+	for i := 0; i < srcRss.Len(); i++ {
+		srcRs := srcRss.At(i)
+		h := dedup.HashResource(srcRs.Resource(), srcRs.SchemaUrl())
+		j, ok := resources.Find(h, func(j int) bool {
+			return dedup.ResourcesEqual(srcRs.Resource(), srcRs.SchemaUrl(), rss.At(j).Resource(), rss.At(j).SchemaUrl())
+		})
+		if !ok {
+			j = rss.Len()
+			destRs := rss.AppendEmpty()
+			srcRs.Resource().MoveTo(destRs.Resource())
+			destRs.SetSchemaUrl(srcRs.SchemaUrl())
+			resources.Put(h, j)
+			scopes = append(scopes, nil)
+		}
+
+		sss := rss.At(j).ScopeSpans()
+		if scopes[j] == nil {
+			scopes[j] = make(dedup.Index, sss.Len())
+			for k := 0; k < sss.Len(); k++ {
+				scopes[j].Put(dedup.HashScope(sss.At(k).Scope(), sss.At(k).SchemaUrl()), k)
+			}
+		}
+		srcSss := srcRs.ScopeSpans()
+		for k := 0; k < srcSss.Len(); k++ {
+			srcSs := srcSss.At(k)
+			h := dedup.HashScope(srcSs.Scope(), srcSs.SchemaUrl())
+			if l, ok := scopes[j].Find(h, func(l int) bool {
+				return dedup.ScopesEqual(srcSs.Scope(), srcSs.SchemaUrl(), sss.At(l).Scope(), sss.At(l).SchemaUrl())
+			}); ok {
+				srcSs.Spans().MoveAndAppendTo(sss.At(l).Spans())
+				continue
+			}
+			srcSs.MoveTo(sss.AppendEmpty())
+			scopes[j].Put(h, sss.Len()-1)
+		}
+	}
+	srcRss.RemoveIf(func(ResourceSpans) bool { return true })
+}
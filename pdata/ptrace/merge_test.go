@@ -0,0 +1,185 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptrace
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mergeTestTraces returns traces of a ResourceSpans for every resource
+// attributes, holding a ScopeSpans with a span for every scope name.
+func mergeTestTraces(t testing.TB, resources []map[string]any, scopes ...string) Traces {
+	td := NewTraces()
+	for _, attrs := range resources {
+		rs := td.ResourceSpans().AppendEmpty()
+		require.NoError(t, rs.Resource().Attributes().FromRaw(attrs))
+		for _, scope := range scopes {
+			ss := rs.ScopeSpans().AppendEmpty()
+			ss.Scope().SetName(scope)
+			ss.Spans().AppendEmpty().SetName(fmt.Sprintf("%v/%s", attrs, scope))
+		}
+	}
+	return td
+}
+
+// mergeSpanNames returns the names of the spans of every ScopeSpans of
+// td, by the index of its ResourceSpans.
+func mergeSpanNames(td Traces) [][][]string {
+	var names [][][]string
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		var scopes [][]string
+		sss := td.ResourceSpans().At(i).ScopeSpans()
+		for j := 0; j < sss.Len(); j++ {
+			var spans []string
+			for k := 0; k < sss.At(j).Spans().Len(); k++ {
+				spans = append(spans, sss.At(j).Spans().At(k).Name())
+			}
+			scopes = append(scopes, spans)
+		}
+		names = append(names, scopes)
+	}
+	return names
+}
+
+func TestMergeInto(t *testing.T) {
+	dst := mergeTestTraces(t, []map[string]any{{"a": 1, "b": "x"}}, "s")
+	// The same attributes in another order, and other ones.
+	src := NewTraces()
+	rs := src.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("b", "x")
+	rs.Resource().Attributes().PutInt("a", 1)
+	for _, scope := range []string{"t", "s"} {
+		ss := rs.ScopeSpans().AppendEmpty()
+		ss.Scope().SetName(scope)
+		ss.Spans().AppendEmpty().SetName("src/" + scope)
+	}
+	mergeTestTraces(t, []map[string]any{{"a": 2}}, "s").ResourceSpans().MoveAndAppendTo(src.ResourceSpans())
+
+	MergeInto(dst, src)
+	assert.Equal(t, 0, src.ResourceSpans().Len())
+	assert.Equal(t, [][][]string{
+		{{"map[a:1 b:x]/s", "src/s"}, {"src/t"}},
+		{{"map[a:2]/s"}},
+	}, mergeSpanNames(dst))
+	assert.Equal(t, map[string]any{"a": int64(1), "b": "x"}, dst.ResourceSpans().At(0).Resource().Attributes().AsRaw())
+	assert.Equal(t, "t", dst.ResourceSpans().At(0).ScopeSpans().At(1).Scope().Name())
+}
+
+func TestMergeIntoSourceDuplicates(t *testing.T) {
+	dst := NewTraces()
+	src := mergeTestTraces(t, []map[string]any{{"a": 1}, {"a": 2}, {"a": 1}}, "s", "t", "s")
+	MergeInto(dst, src)
+	assert.Equal(t, [][][]string{
+		{{"map[a:1]/s", "map[a:1]/s", "map[a:1]/s", "map[a:1]/s"}, {"map[a:1]/t", "map[a:1]/t"}},
+		{{"map[a:2]/s", "map[a:2]/s"}, {"map[a:2]/t"}},
+	}, mergeSpanNames(dst))
+}
+
+func TestMergeIntoDestinationDuplicates(t *testing.T) {
+	// The entries already in dst are kept.
+	dst := mergeTestTraces(t, []map[string]any{{"a": 1}, {"a": 1}}, "s", "s")
+	MergeInto(dst, mergeTestTraces(t, []map[string]any{{"a": 1}}, "s"))
+	assert.Equal(t, [][][]string{
+		{{"map[a:1]/s", "map[a:1]/s"}, {"map[a:1]/s"}},
+		{{"map[a:1]/s"}, {"map[a:1]/s"}},
+	}, mergeSpanNames(dst))
+}
+
+func TestMergeIntoIdentity(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(rs ResourceSpans)
+	}{
+		{
+			name:   "resource schema URL",
+			modify: func(rs ResourceSpans) { rs.SetSchemaUrl("https://other") },
+		},
+		{
+			name:   "resource attributes",
+			modify: func(rs ResourceSpans) { rs.Resource().Attributes().PutStr("b", "y") },
+		},
+		{
+			name:   "resource dropped attributes count",
+			modify: func(rs ResourceSpans) { rs.Resource().SetDroppedAttributesCount(1) },
+		},
+		{
+			name:   "scope schema URL",
+			modify: func(rs ResourceSpans) { rs.ScopeSpans().At(0).SetSchemaUrl("https://other") },
+		},
+		{
+			name:   "scope version",
+			modify: func(rs ResourceSpans) { rs.ScopeSpans().At(0).Scope().SetVersion("2") },
+		},
+		{
+			name:   "scope attributes",
+			modify: func(rs ResourceSpans) { rs.ScopeSpans().At(0).Scope().Attributes().PutStr("b", "y") },
+		},
+		{
+			name:   "scope dropped attributes count",
+			modify: func(rs ResourceSpans) { rs.ScopeSpans().At(0).Scope().SetDroppedAttributesCount(1) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := mergeTestTraces(t, []map[string]any{{"a": 1}}, "s")
+			src := mergeTestTraces(t, []map[string]any{{"a": 1}}, "s")
+			tt.modify(src.ResourceSpans().At(0))
+			MergeInto(dst, src)
+			assert.Equal(t, 2, dst.SpanCount())
+			assert.Equal(t, 1, dst.ResourceSpans().At(0).ScopeSpans().At(0).Spans().Len())
+		})
+	}
+}
+
+func TestMergeIntoWithoutDeduplication(t *testing.T) {
+	dst := mergeTestTraces(t, []map[string]any{{"a": 1}}, "s")
+	src := mergeTestTraces(t, []map[string]any{{"a": 1}}, "s")
+	MergeInto(dst, src, WithoutDeduplication())
+	assert.Equal(t, 0, src.ResourceSpans().Len())
+	assert.Equal(t, [][][]string{{{"map[a:1]/s"}}, {{"map[a:1]/s"}}}, mergeSpanNames(dst))
+}
+
+func benchmarkMergeInto(b *testing.B, opts ...MergeOption) {
+	resources := make([]map[string]any, 5)
+	for i := range resources {
+		resources[i] = map[string]any{"service.name": fmt.Sprint("service-", i), "host.name": "node-1"}
+	}
+	requests := make([]Traces, 100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		for j := range requests {
+			requests[j] = mergeTestTraces(b, resources, "s", "t")
+		}
+		b.StartTimer()
+		dst := NewTraces()
+		for _, src := range requests {
+			MergeInto(dst, src, opts...)
+		}
+	}
+}
+
+func BenchmarkMergeInto(b *testing.B) {
+	benchmarkMergeInto(b)
+}
+
+func BenchmarkMergeIntoWithoutDeduplication(b *testing.B) {
+	benchmarkMergeInto(b, WithoutDeduplication())
+}
@@ -20,6 +20,7 @@ import (
 )
 
 var _ MarshalSizer = (*ProtoMarshaler)(nil)
+var _ DeltaSizer = (*ProtoMarshaler)(nil)
 
 type ProtoMarshaler struct{}
 
@@ -28,11 +29,58 @@ func (e *ProtoMarshaler) MarshalTraces(td Traces) ([]byte, error) {
 	return pb.Marshal()
 }
 
+// TracesSize returns the size of the marshaled td.  It is computed on each
+// call: td can be changed through the wrappers of its parts, which do not
+// reference td, so that a cached size could not be invalidated.
 func (e *ProtoMarshaler) TracesSize(td Traces) int {
 	pb := internal.TracesToProto(internal.Traces(td))
 	return pb.Size()
 }
 
+func (e *ProtoMarshaler) ResourceSpansSize(rs ResourceSpans) int {
+	return rs.orig.Size()
+}
+
+func (e *ProtoMarshaler) ScopeSpansSize(ss ScopeSpans) int {
+	return ss.orig.Size()
+}
+
+func (e *ProtoMarshaler) SpanSize(span Span) int {
+	return span.orig.Size()
+}
+
+// SpanDeltaSize returns the size a span adds to a marshaled ScopeSpans:
+// its SpanSize along with the tag and length prefixing it, so that the
+// sizes of spans can be summed.
+func (e *ProtoMarshaler) SpanDeltaSize(span Span) int {
+	return fieldSize(span.orig.Size())
+}
+
+// TracesOverheadSize returns the size of the marshaled td beyond the
+// SpanDeltaSize of its spans: that of its resources and scopes, and of
+// the tags and lengths prefixing them, so that
+//
+//	TracesSize(td) = TracesOverheadSize(td) + Σ SpanDeltaSize(span)
+//
+// The lengths prefixing the resources and scopes grow with their spans,
+// a byte each time they cross 127 bytes, 16383 bytes, and so on, so the
+// overhead of the same resources and scopes holding other spans is only
+// approximated by it.
+func (e *ProtoMarshaler) TracesOverheadSize(td Traces) int {
+	size := e.TracesSize(td)
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		sss := rss.At(i).ScopeSpans()
+		for j := 0; j < sss.Len(); j++ {
+			spans := sss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				size -= e.SpanDeltaSize(spans.At(k))
+			}
+		}
+	}
+	return size
+}
+
 type ProtoUnmarshaler struct{}
 
 func (d *ProtoUnmarshaler) UnmarshalTraces(buf []byte) (Traces, error) {
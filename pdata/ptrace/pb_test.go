@@ -15,6 +15,7 @@
 package ptrace
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -48,6 +49,127 @@ func TestProtoSizerEmptyTraces(t *testing.T) {
 	assert.Equal(t, 0, sizer.TracesSize(NewTraces()))
 }
 
+func TestProtoSizerMutations(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(td Traces)
+	}{
+		{
+			name: "append",
+			mutate: func(td Traces) {
+				td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().AppendEmpty().SetName("a longer name")
+			},
+		},
+		{
+			name: "remove",
+			mutate: func(td Traces) {
+				td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().RemoveIf(func(Span) bool { return true })
+			},
+		},
+		{
+			name: "attribute",
+			mutate: func(td Traces) {
+				td.ResourceSpans().At(0).Resource().Attributes().PutStr("host", "a")
+			},
+		},
+		{
+			name: "field",
+			mutate: func(td Traces) {
+				td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).SetName("a longer name")
+			},
+		},
+	}
+	sizer := &ProtoMarshaler{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The size follows the changes made through the parts of
+			// the data, read-only or not.
+			for _, readOnly := range []bool{false, true} {
+				td := generateBenchmarkTraces(2)
+				if readOnly {
+					td.MarkReadOnly()
+				}
+				size := sizer.TracesSize(td)
+				tt.mutate(td)
+				assert.NotEqual(t, size, sizer.TracesSize(td))
+				assert.Equal(t, td.getOrig().Size(), sizer.TracesSize(td))
+				buf, err := sizer.MarshalTraces(td)
+				require.NoError(t, err)
+				assert.Len(t, buf, sizer.TracesSize(td))
+			}
+		})
+	}
+}
+
+// varintLen returns the length of the varint encoding of v.
+func varintLen(v int) int {
+	switch {
+	case v < 1<<7:
+		return 1
+	case v < 1<<14:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func TestProtoSizerSpanDeltaSize(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	// The sizes of the spans, and of their prefixes, cross 127 and 16383
+	// bytes.
+	sizes := map[int]bool{}
+	for _, lengths := range [][2]int{{100, 140}, {16350, 16400}} {
+		for l := lengths[0]; l < lengths[1]; l++ {
+			ss := NewScopeSpans()
+			empty := sizer.ScopeSpansSize(ss)
+			span := ss.Spans().AppendEmpty()
+			span.SetName(strings.Repeat("x", l))
+
+			size := sizer.SpanSize(span)
+			sizes[size] = true
+			assert.Equal(t, size+1+varintLen(size), sizer.SpanDeltaSize(span))
+			assert.Equal(t, sizer.ScopeSpansSize(ss)-empty, sizer.SpanDeltaSize(span))
+		}
+	}
+	for _, size := range []int{127, 128, 16383, 16384} {
+		assert.True(t, sizes[size], "size %d", size)
+	}
+}
+
+func TestProtoSizerTracesOverheadSize(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	td := NewTraces()
+	for r, length := range []int{10, 200, 20000} {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutInt("resource", int64(r))
+		for s := 0; s < 2; s++ {
+			ss := rs.ScopeSpans().AppendEmpty()
+			ss.Scope().SetName("scope")
+			ss.Spans().AppendEmpty().SetName(strings.Repeat("x", length))
+			ss.Spans().AppendEmpty().SetName("span")
+		}
+	}
+
+	overhead := sizer.TracesOverheadSize(td)
+	itemsSize := 0
+	shape := NewTraces()
+	td.CopyTo(shape)
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		sss := td.ResourceSpans().At(i).ScopeSpans()
+		for j := 0; j < sss.Len(); j++ {
+			for k := 0; k < sss.At(j).Spans().Len(); k++ {
+				itemsSize += sizer.SpanDeltaSize(sss.At(j).Spans().At(k))
+			}
+			shape.ResourceSpans().At(i).ScopeSpans().At(j).Spans().RemoveIf(func(Span) bool { return true })
+		}
+	}
+	assert.Equal(t, sizer.TracesSize(td), overhead+itemsSize)
+	// The lengths prefixing the resources and scopes of the larger spans
+	// take more bytes once the spans are added: a byte for the scopes and
+	// resource of 200 bytes spans, and two for those of 20000 bytes.
+	assert.Equal(t, sizer.TracesSize(shape)+3*1+3*2, overhead)
+}
+
 func BenchmarkTracesToProto(b *testing.B) {
 	marshaler := &ProtoMarshaler{}
 	traces := generateBenchmarkTraces(128)
@@ -75,6 +197,18 @@ func BenchmarkTracesFromProto(b *testing.B) {
 	}
 }
 
+func BenchmarkTracesSize(b *testing.B) {
+	td := generateBenchmarkTraces(128)
+	sizer := &ProtoMarshaler{}
+	size := 0
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		size = sizer.TracesSize(td)
+	}
+	assert.NotEqual(b, 0, size)
+}
+
 func generateBenchmarkTraces(metricsCount int) Traces {
 	now := time.Now()
 	startTime := pcommon.NewTimestampFromTime(now.Add(-10 * time.Second))
@@ -20,6 +20,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
 )
 
 var _ json.Unmarshaler = ExportRequest{}
@@ -64,3 +66,14 @@ func TestRequestJSON(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, strings.Join(strings.Fields(string(tracesRequestJSON)), ""), string(got))
 }
+
+func TestRequestReadOnly(t *testing.T) {
+	traces := ptrace.NewTraces()
+	tr := NewExportRequestFromTraces(traces)
+	traces.MarkReadOnly()
+	assert.True(t, tr.Traces().IsReadOnly())
+
+	tr = NewExportRequest()
+	tr.Traces().MarkReadOnly()
+	assert.True(t, tr.Traces().IsReadOnly())
+}
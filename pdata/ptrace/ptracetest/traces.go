@@ -0,0 +1,223 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ptracetest compares traces in tests.
+package ptracetest // import "go.opentelemetry.io/collector/pdata/ptrace/ptracetest"
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.uber.org/multierr"
+
+	"go.opentelemetry.io/collector/pdata/internal/dedup"
+	"go.opentelemetry.io/collector/pdata/internal/match"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// CompareTracesOption configures CompareTraces.
+type CompareTracesOption func(*compareOptions)
+
+type compareOptions struct {
+	ignoreResourceOrder bool
+	ignoreScopeOrder    bool
+	ignoreSpanOrder     bool
+}
+
+// IgnoreResourceOrder makes CompareTraces match the resources by their
+// attributes and schema URL, rather than by their position.
+func IgnoreResourceOrder() CompareTracesOption {
+	return func(o *compareOptions) {
+		o.ignoreResourceOrder = true
+	}
+}
+
+// IgnoreScopeOrder makes CompareTraces match the scopes of a resource by
+// their name, version, attributes and schema URL, rather than by their
+// position.
+func IgnoreScopeOrder() CompareTracesOption {
+	return func(o *compareOptions) {
+		o.ignoreScopeOrder = true
+	}
+}
+
+// IgnoreSpanOrder makes CompareTraces match the spans of a scope by their
+// trace and span IDs, rather than by their position.
+func IgnoreSpanOrder() CompareTracesOption {
+	return func(o *compareOptions) {
+		o.ignoreSpanOrder = true
+	}
+}
+
+// CompareTraces returns an error listing the differences between expected
+// and actual, or nil when they are equal.  Each difference names the
+// resource, scope and span it is found in.  Attributes are compared
+// regardless of their order.
+func CompareTraces(expected, actual ptrace.Traces, opts ...CompareTracesOption) error {
+	var o compareOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	exp, act := expected.ResourceSpans(), actual.ResourceSpans()
+	pairs, missing, unexpected := match.Entries(exp.Len(), act.Len(), o.ignoreResourceOrder, func(i, j int) bool {
+		return sameResource(exp.At(i), act.At(j))
+	})
+	var errs error
+	for _, p := range pairs {
+		e, a := exp.At(p.Expected), act.At(p.Actual)
+		if !sameResource(e, a) {
+			errs = multierr.Append(errs, fmt.Errorf("at index %d: expected %s, actual %s", p.Actual, resourceName(e), resourceName(a)))
+			continue
+		}
+		errs = multierr.Append(errs, prefix(resourceName(e), compareScopes(e.ScopeSpans(), a.ScopeSpans(), o)))
+	}
+	for _, i := range missing {
+		errs = multierr.Append(errs, fmt.Errorf("missing %s", resourceName(exp.At(i))))
+	}
+	for _, j := range unexpected {
+		errs = multierr.Append(errs, fmt.Errorf("unexpected %s", resourceName(act.At(j))))
+	}
+	return errs
+}
+
+func compareScopes(exp, act ptrace.ScopeSpansSlice, o compareOptions) error {
+	pairs, missing, unexpected := match.Entries(exp.Len(), act.Len(), o.ignoreScopeOrder, func(i, j int) bool {
+		return sameScope(exp.At(i), act.At(j))
+	})
+	var errs error
+	for _, p := range pairs {
+		e, a := exp.At(p.Expected), act.At(p.Actual)
+		if !sameScope(e, a) {
+			errs = multierr.Append(errs, fmt.Errorf("at index %d: expected %s, actual %s", p.Actual, scopeName(e), scopeName(a)))
+			continue
+		}
+		errs = multierr.Append(errs, prefix(scopeName(e), compareSpans(e.Spans(), a.Spans(), o)))
+	}
+	for _, i := range missing {
+		errs = multierr.Append(errs, fmt.Errorf("missing %s", scopeName(exp.At(i))))
+	}
+	for _, j := range unexpected {
+		errs = multierr.Append(errs, fmt.Errorf("unexpected %s", scopeName(act.At(j))))
+	}
+	return errs
+}
+
+func compareSpans(exp, act ptrace.SpanSlice, o compareOptions) error {
+	pairs, missing, unexpected := match.Entries(exp.Len(), act.Len(), o.ignoreSpanOrder, func(i, j int) bool {
+		return sameSpan(exp.At(i), act.At(j))
+	})
+	var errs error
+	for _, p := range pairs {
+		e, a := exp.At(p.Expected), act.At(p.Actual)
+		if !sameSpan(e, a) {
+			errs = multierr.Append(errs, fmt.Errorf("at index %d: expected %s, actual %s", p.Actual, spanName(e), spanName(a)))
+			continue
+		}
+		errs = multierr.Append(errs, prefix(spanName(e), compareSpan(e, a)))
+	}
+	for _, i := range missing {
+		errs = multierr.Append(errs, fmt.Errorf("missing %s", spanName(exp.At(i))))
+	}
+	for _, j := range unexpected {
+		errs = multierr.Append(errs, fmt.Errorf("unexpected %s", spanName(act.At(j))))
+	}
+	return errs
+}
+
+// compareSpan compares the fields of two spans of the same IDs.
+func compareSpan(e, a ptrace.Span) error {
+	var errs error
+	field := func(name string, e, a any) {
+		if !reflect.DeepEqual(e, a) {
+			errs = multierr.Append(errs, fmt.Errorf("%s: expected %v, actual %v", name, e, a))
+		}
+	}
+	field("name", e.Name(), a.Name())
+	field("kind", e.Kind(), a.Kind())
+	field("parent span ID", e.ParentSpanID(), a.ParentSpanID())
+	field("trace state", e.TraceState().AsRaw(), a.TraceState().AsRaw())
+	field("start timestamp", e.StartTimestamp(), a.StartTimestamp())
+	field("end timestamp", e.EndTimestamp(), a.EndTimestamp())
+	field("attributes", e.Attributes().AsRaw(), a.Attributes().AsRaw())
+	field("dropped attributes count", e.DroppedAttributesCount(), a.DroppedAttributesCount())
+	field("events", rawEvents(e.Events()), rawEvents(a.Events()))
+	field("dropped events count", e.DroppedEventsCount(), a.DroppedEventsCount())
+	field("links", rawLinks(e.Links()), rawLinks(a.Links()))
+	field("dropped links count", e.DroppedLinksCount(), a.DroppedLinksCount())
+	field("status code", e.Status().Code(), a.Status().Code())
+	field("status message", e.Status().Message(), a.Status().Message())
+	return errs
+}
+
+func rawEvents(events ptrace.SpanEventSlice) []map[string]any {
+	raw := make([]map[string]any, 0, events.Len())
+	for i := 0; i < events.Len(); i++ {
+		event := events.At(i)
+		raw = append(raw, map[string]any{
+			"name":                   event.Name(),
+			"timestamp":              event.Timestamp(),
+			"attributes":             event.Attributes().AsRaw(),
+			"droppedAttributesCount": event.DroppedAttributesCount(),
+		})
+	}
+	return raw
+}
+
+func rawLinks(links ptrace.SpanLinkSlice) []map[string]any {
+	raw := make([]map[string]any, 0, links.Len())
+	for i := 0; i < links.Len(); i++ {
+		link := links.At(i)
+		raw = append(raw, map[string]any{
+			"traceID":                link.TraceID().String(),
+			"spanID":                 link.SpanID().String(),
+			"traceState":             link.TraceState().AsRaw(),
+			"attributes":             link.Attributes().AsRaw(),
+			"droppedAttributesCount": link.DroppedAttributesCount(),
+		})
+	}
+	return raw
+}
+
+func sameResource(e, a ptrace.ResourceSpans) bool {
+	return dedup.ResourcesEqual(e.Resource(), e.SchemaUrl(), a.Resource(), a.SchemaUrl())
+}
+
+func sameScope(e, a ptrace.ScopeSpans) bool {
+	return dedup.ScopesEqual(e.Scope(), e.SchemaUrl(), a.Scope(), a.SchemaUrl())
+}
+
+func sameSpan(e, a ptrace.Span) bool {
+	return e.TraceID() == a.TraceID() && e.SpanID() == a.SpanID()
+}
+
+func resourceName(rs ptrace.ResourceSpans) string {
+	return fmt.Sprintf("resource %v", rs.Resource().Attributes().AsRaw())
+}
+
+func scopeName(ss ptrace.ScopeSpans) string {
+	return fmt.Sprintf("scope %q %q", ss.Scope().Name(), ss.Scope().Version())
+}
+
+func spanName(span ptrace.Span) string {
+	return fmt.Sprintf("span %q (trace ID %s, span ID %s)", span.Name(), span.TraceID(), span.SpanID())
+}
+
+// prefix prefixes each of the errors of errs with name.
+func prefix(name string, errs error) error {
+	var prefixed error
+	for _, err := range multierr.Errors(errs) {
+		prefixed = multierr.Append(prefixed, fmt.Errorf("%s: %w", name, err))
+	}
+	return prefixed
+}
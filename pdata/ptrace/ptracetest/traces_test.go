@@ -0,0 +1,143 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptracetest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// testTraces returns traces of two resources, of two scopes of two
+// spans each.
+func testTraces() ptrace.Traces {
+	td := ptrace.NewTraces()
+	for _, host := range []string{"a", "b"} {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("host", host)
+		rs.Resource().Attributes().PutStr("service", "test")
+		for _, scope := range []string{"x", "y"} {
+			ss := rs.ScopeSpans().AppendEmpty()
+			ss.Scope().SetName(scope)
+			for i := byte(1); i <= 2; i++ {
+				span := ss.Spans().AppendEmpty()
+				span.SetName(host + scope)
+				span.SetTraceID(pcommon.TraceID{host[0], scope[0]})
+				span.SetSpanID(pcommon.SpanID{i})
+				span.Attributes().PutInt("index", int64(i))
+			}
+		}
+	}
+	return td
+}
+
+func TestCompareTracesEqual(t *testing.T) {
+	assert.NoError(t, CompareTraces(testTraces(), testTraces()))
+	assert.NoError(t, CompareTraces(ptrace.NewTraces(), ptrace.NewTraces()))
+
+	// Attributes are compared regardless of their order.
+	actual := testTraces()
+	attrs := actual.ResourceSpans().At(0).Resource().Attributes()
+	attrs.Clear()
+	attrs.PutStr("service", "test")
+	attrs.PutStr("host", "a")
+	assert.NoError(t, CompareTraces(testTraces(), actual))
+}
+
+func TestCompareTracesIgnoreOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		reorder func(td ptrace.Traces)
+		option  CompareTracesOption
+	}{
+		{
+			name: "resources",
+			reorder: func(td ptrace.Traces) {
+				rss := td.ResourceSpans()
+				rs := ptrace.NewResourceSpans()
+				rss.At(0).MoveTo(rs)
+				rss.RemoveIf(func(rs ptrace.ResourceSpans) bool { return rs.ScopeSpans().Len() == 0 })
+				rs.MoveTo(rss.AppendEmpty())
+			},
+			option: IgnoreResourceOrder(),
+		},
+		{
+			name: "scopes",
+			reorder: func(td ptrace.Traces) {
+				sss := td.ResourceSpans().At(0).ScopeSpans()
+				ss := ptrace.NewScopeSpans()
+				sss.At(0).MoveTo(ss)
+				sss.RemoveIf(func(ss ptrace.ScopeSpans) bool { return ss.Spans().Len() == 0 })
+				ss.MoveTo(sss.AppendEmpty())
+			},
+			option: IgnoreScopeOrder(),
+		},
+		{
+			name: "spans",
+			reorder: func(td ptrace.Traces) {
+				spans := td.ResourceSpans().At(1).ScopeSpans().At(1).Spans()
+				span := ptrace.NewSpan()
+				spans.At(0).MoveTo(span)
+				spans.RemoveIf(func(span ptrace.Span) bool { return span.SpanID().IsEmpty() })
+				span.MoveTo(spans.AppendEmpty())
+			},
+			option: IgnoreSpanOrder(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := testTraces()
+			tt.reorder(actual)
+			assert.Error(t, CompareTraces(testTraces(), actual))
+			assert.NoError(t, CompareTraces(testTraces(), actual, tt.option))
+		})
+	}
+}
+
+func TestCompareTracesPinpoints(t *testing.T) {
+	actual := testTraces()
+	actual.ResourceSpans().At(1).ScopeSpans().At(0).Spans().At(1).Attributes().PutInt("index", 3)
+	actual.ResourceSpans().At(0).ScopeSpans().At(1).Spans().At(0).SetKind(ptrace.SpanKindClient)
+	assert.EqualError(t, CompareTraces(testTraces(), actual, IgnoreResourceOrder(), IgnoreScopeOrder(), IgnoreSpanOrder()),
+		`resource map[host:a service:test]: scope "y" "": span "ay" (trace ID 61790000000000000000000000000000, span ID 0100000000000000): kind: expected Unspecified, actual Client; `+
+			`resource map[host:b service:test]: scope "x" "": span "bx" (trace ID 62780000000000000000000000000000, span ID 0200000000000000): attributes: expected map[index:2], actual map[index:3]`)
+}
+
+func TestCompareTracesMissingUnexpected(t *testing.T) {
+	actual := testTraces()
+	actual.ResourceSpans().At(0).Resource().Attributes().PutStr("host", "c")
+	spans := actual.ResourceSpans().At(1).ScopeSpans().At(0).Spans()
+	spans.RemoveIf(func(span ptrace.Span) bool { return span.SpanID() == pcommon.SpanID{1} })
+	extra := spans.AppendEmpty()
+	extra.SetName("extra")
+	extra.SetTraceID(pcommon.TraceID{1})
+	extra.SetSpanID(pcommon.SpanID{3})
+
+	err := CompareTraces(testTraces(), actual, IgnoreResourceOrder(), IgnoreSpanOrder())
+	require.Error(t, err)
+	assert.EqualError(t, err, `resource map[host:b service:test]: scope "x" "": missing span "bx" (trace ID 62780000000000000000000000000000, span ID 0100000000000000); `+
+		`resource map[host:b service:test]: scope "x" "": unexpected span "extra" (trace ID 01000000000000000000000000000000, span ID 0300000000000000); `+
+		`missing resource map[host:a service:test]; `+
+		`unexpected resource map[host:c service:test]`)
+
+	// In order, the entries are compared by position.
+	err = CompareTraces(testTraces(), actual)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "at index 0: expected resource map[host:a service:test], actual resource map[host:c service:test]")
+}
@@ -0,0 +1,190 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptrace // import "go.opentelemetry.io/collector/pdata/ptrace"
+
+import (
+	"github.com/gogo/protobuf/proto"
+)
+
+// Split removes the first n spans of td, in order, and returns them in a
+// new Traces.  The spans are moved, not copied: td is left with the
+// remaining spans only.  A ResourceSpans or ScopeSpans whose spans are
+// cut between both halves has its resource, scope and schema URL copied,
+// so that both halves keep them, and the containers left empty in td are
+// removed.
+//
+// The order of the spans is kept across resources and scopes: the
+// returned spans are in their order in td, and td keeps the
+// remaining ones in their order, so that the concatenation of the
+// results of repeated splits is the original sequence.
+//
+// If n is less than or equal to zero an empty Traces is returned and td
+// is unchanged.  If n is greater than or equal to the number of spans of
+// td, all of them are moved, leaving td empty.
+func Split(td Traces, n int) Traces {
+	dest := NewTraces()
+	if n <= 0 {
+		return dest
+	}
+	if td.SpanCount() <= n {
+		td.ResourceSpans().MoveAndAppendTo(dest.ResourceSpans())
+		return dest
+	}
+
+	moved := 0
+	td.ResourceSpans().RemoveIf(func(srcRs ResourceSpans) bool {
+		// If we are done skip everything else.
+		if moved == n {
+			return false
+		}
+
+		// If it fully fits
+		srcRsSC := srcRs.SpanCount()
+		if moved+srcRsSC <= n {
+			moved += srcRsSC
+			srcRs.MoveTo(dest.ResourceSpans().AppendEmpty())
+			return true
+		}
+
+		destRs := dest.ResourceSpans().AppendEmpty()
+		srcRs.Resource().CopyTo(destRs.Resource())
+		destRs.SetSchemaUrl(srcRs.SchemaUrl())
+		srcRs.ScopeSpans().RemoveIf(func(srcSs ScopeSpans) bool {
+			// If we are done skip everything else.
+			if moved == n {
+				return false
+			}
+
+			// If possible to move all spans do that.
+			srcSsSC := srcSs.Spans().Len()
+			if moved+srcSsSC <= n {
+				moved += srcSsSC
+				srcSs.MoveTo(destRs.ScopeSpans().AppendEmpty())
+				return true
+			}
+
+			destSs := destRs.ScopeSpans().AppendEmpty()
+			srcSs.Scope().CopyTo(destSs.Scope())
+			destSs.SetSchemaUrl(srcSs.SchemaUrl())
+			srcSs.Spans().RemoveIf(func(srcSpan Span) bool {
+				// If we are done skip everything else.
+				if moved == n {
+					return false
+				}
+				srcSpan.MoveTo(destSs.Spans().AppendEmpty())
+				moved++
+				return true
+			})
+			return false
+		})
+		return srcRs.ScopeSpans().Len() == 0
+	})
+
+	return dest
+}
+
+// SplitSize removes the first spans of td, in order, up to a marshaled
+// size of maxBytes as computed by sizer, and returns them in a new
+// Traces, as Split does.  The size of each resource, scope and span is
+// computed once, and the envelope of the resources and scopes copied to
+// both halves is accounted for as encoded in the OTLP protobuf: the
+// returned Traces is the largest prefix of td whose TracesSize does not
+// exceed maxBytes.
+//
+// The first span of td is returned alone, along with its resource and
+// scope, when they exceed maxBytes, so that td can be split until empty.
+// If td does not exceed maxBytes, all of its spans are moved, leaving td
+// empty.
+func SplitSize(td Traces, maxBytes int, sizer DeltaSizer) Traces {
+	dest := NewTraces()
+	// size is the size of dest, moved is its number of spans, and done
+	// is set once a span does not fit.
+	size, moved, done := 0, 0, false
+	td.ResourceSpans().RemoveIf(func(srcRs ResourceSpans) bool {
+		if done {
+			return false
+		}
+
+		// If it fully fits, or holds no span to make progress with.
+		srcRsSize := fieldSize(sizer.ResourceSpansSize(srcRs))
+		srcRsSC := srcRs.SpanCount()
+		if size+srcRsSize <= maxBytes || (moved == 0 && srcRsSC == 0) {
+			size += srcRsSize
+			moved += srcRsSC
+			srcRs.MoveTo(dest.ResourceSpans().AppendEmpty())
+			return true
+		}
+
+		// The resource is copied to both halves, rsSize is its size in
+		// dest.
+		destRs := NewResourceSpans()
+		srcRs.Resource().CopyTo(destRs.Resource())
+		destRs.SetSchemaUrl(srcRs.SchemaUrl())
+		rsSize := sizer.ResourceSpansSize(destRs)
+		srcRs.ScopeSpans().RemoveIf(func(srcSs ScopeSpans) bool {
+			if done {
+				return false
+			}
+
+			srcSsSize := fieldSize(sizer.ScopeSpansSize(srcSs))
+			if size+fieldSize(rsSize+srcSsSize) <= maxBytes || (moved == 0 && srcSs.Spans().Len() == 0) {
+				rsSize += srcSsSize
+				moved += srcSs.Spans().Len()
+				srcSs.MoveTo(destRs.ScopeSpans().AppendEmpty())
+				return true
+			}
+
+			destSs := NewScopeSpans()
+			srcSs.Scope().CopyTo(destSs.Scope())
+			destSs.SetSchemaUrl(srcSs.SchemaUrl())
+			ssSize := sizer.ScopeSpansSize(destSs)
+			srcSs.Spans().RemoveIf(func(srcSpan Span) bool {
+				if done {
+					return false
+				}
+				spanSize := fieldSize(sizer.SpanSize(srcSpan))
+				if size+fieldSize(rsSize+fieldSize(ssSize+spanSize)) > maxBytes {
+					done = true
+					if moved > 0 {
+						return false
+					}
+				}
+				ssSize += spanSize
+				moved++
+				srcSpan.MoveTo(destSs.Spans().AppendEmpty())
+				return true
+			})
+			if destSs.Spans().Len() > 0 {
+				rsSize += fieldSize(ssSize)
+				destSs.MoveTo(destRs.ScopeSpans().AppendEmpty())
+			}
+			done = true
+			return srcSs.Spans().Len() == 0
+		})
+		if destRs.ScopeSpans().Len() > 0 {
+			size += fieldSize(rsSize)
+			destRs.MoveTo(dest.ResourceSpans().AppendEmpty())
+		}
+		return srcRs.ScopeSpans().Len() == 0
+	})
+
+	return dest
+}
+
+// fieldSize returns the size of a message of size bytes as a field of its
+// parent, with its tag and length.
+func fieldSize(size int) int {
+	return 1 + proto.SizeVarint(uint64(size)) + size
+}
@@ -0,0 +1,275 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptrace
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// splitTestTraces returns traces of resources of scopes of spans each,
+// the spans named after the indexes of their resource, scope and
+// position, and the resources and scopes after those of their spans.
+func splitTestTraces(resources, scopes, spans int) Traces {
+	td := NewTraces()
+	for r := 0; r < resources; r++ {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("resource", fmt.Sprint(r))
+		rs.SetSchemaUrl(fmt.Sprintf("https://%d", r))
+		for s := 0; s < scopes; s++ {
+			ss := rs.ScopeSpans().AppendEmpty()
+			ss.Scope().SetName(fmt.Sprintf("%d-%d", r, s))
+			ss.SetSchemaUrl(fmt.Sprintf("https://%d-%d", r, s))
+			for i := 0; i < spans; i++ {
+				ss.Spans().AppendEmpty().SetName(fmt.Sprintf("%d-%d-%d", r, s, i))
+			}
+		}
+	}
+	return td
+}
+
+// splitSpanNames returns the names of the spans of td, in order, and
+// checks that each span is under the resource and scope it was created
+// with.
+func splitSpanNames(t *testing.T, td Traces) []string {
+	names := []string{}
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		r, ok := rs.Resource().Attributes().Get("resource")
+		require.True(t, ok)
+		assert.Equal(t, "https://"+r.Str(), rs.SchemaUrl())
+		require.NotZero(t, rs.ScopeSpans().Len())
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+			assert.True(t, strings.HasPrefix(ss.Scope().Name(), r.Str()+"-"))
+			assert.Equal(t, "https://"+ss.Scope().Name(), ss.SchemaUrl())
+			require.NotZero(t, ss.Spans().Len())
+			for k := 0; k < ss.Spans().Len(); k++ {
+				name := ss.Spans().At(k).Name()
+				assert.True(t, strings.HasPrefix(name, ss.Scope().Name()+"-"))
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func TestSplit(t *testing.T) {
+	all := splitSpanNames(t, splitTestTraces(2, 2, 5))
+	for _, n := range []int{-1, 0, 1, 3, 5, 7, 10, 13, 19, 20, 25} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			td := splitTestTraces(2, 2, 5)
+			split := Split(td, n)
+
+			moved := n
+			if moved < 0 {
+				moved = 0
+			} else if moved > len(all) {
+				moved = len(all)
+			}
+			assert.Equal(t, moved, split.SpanCount())
+			assert.Equal(t, len(all)-moved, td.SpanCount())
+			assert.Equal(t, all[:moved], splitSpanNames(t, split))
+			assert.Equal(t, all[moved:], splitSpanNames(t, td))
+		})
+	}
+}
+
+func TestSplitUnchanged(t *testing.T) {
+	td := splitTestTraces(2, 2, 5)
+	split := Split(td, 0)
+	assert.Equal(t, NewTraces(), split)
+	assert.Equal(t, splitTestTraces(2, 2, 5), td)
+}
+
+func TestSplitAll(t *testing.T) {
+	td := splitTestTraces(2, 2, 5)
+	split := Split(td, 20)
+	assert.Equal(t, splitTestTraces(2, 2, 5), split)
+	assert.Equal(t, 0, td.ResourceSpans().Len())
+}
+
+func TestSplitRepeated(t *testing.T) {
+	td := splitTestTraces(3, 2, 5)
+	all := splitSpanNames(t, td)
+	names := []string{}
+	for td.SpanCount() > 0 {
+		split := Split(td, 4)
+		assert.LessOrEqual(t, split.SpanCount(), 4)
+		names = append(names, splitSpanNames(t, split)...)
+	}
+	assert.Equal(t, all, names)
+	assert.Equal(t, 0, td.ResourceSpans().Len())
+}
+
+// splitSizeTestTraces returns the traces of splitTestTraces, with spans
+// of various sizes.
+func splitSizeTestTraces(resources, scopes, spans int) Traces {
+	td := splitTestTraces(resources, scopes, spans)
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		sss := td.ResourceSpans().At(i).ScopeSpans()
+		for j := 0; j < sss.Len(); j++ {
+			for k := 0; k < sss.At(j).Spans().Len(); k++ {
+				sss.At(j).Spans().At(k).Attributes().PutStr("padding", strings.Repeat("x", (i*7+j*3+k*11)%50))
+			}
+		}
+	}
+	return td
+}
+
+// splitSizeByMarshaling is the naive SplitSize, binary searching the
+// number of spans to split by sizing the candidates.
+func splitSizeByMarshaling(td Traces, maxBytes int, sizer DeltaSizer) Traces {
+	lo, hi := 1, td.SpanCount()
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		candidate := NewTraces()
+		td.CopyTo(candidate)
+		if sizer.TracesSize(Split(candidate, mid)) <= maxBytes {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return Split(td, lo)
+}
+
+// assertSameTraces asserts that expected and actual marshal the same.
+func assertSameTraces(t *testing.T, expected, actual Traces) {
+	marshaler := &ProtoMarshaler{}
+	expectedBuf, err := marshaler.MarshalTraces(expected)
+	require.NoError(t, err)
+	actualBuf, err := marshaler.MarshalTraces(actual)
+	require.NoError(t, err)
+	require.Equal(t, expectedBuf, actualBuf)
+}
+
+func TestSplitSize(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	total := sizer.TracesSize(splitSizeTestTraces(3, 3, 10))
+	for maxBytes := 0; maxBytes <= total+10; maxBytes += 7 {
+		td := splitSizeTestTraces(3, 3, 10)
+		split := SplitSize(td, maxBytes, sizer)
+
+		expectedTd := splitSizeTestTraces(3, 3, 10)
+		expected := splitSizeByMarshaling(expectedTd, maxBytes, sizer)
+		assertSameTraces(t, expected, split)
+		assertSameTraces(t, expectedTd, td)
+		if split.SpanCount() > 1 {
+			assert.LessOrEqual(t, sizer.TracesSize(split), maxBytes)
+		}
+	}
+}
+
+func TestSplitSizeRepeated(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	td := splitSizeTestTraces(3, 3, 10)
+	all := splitSpanNames(t, td)
+	names := []string{}
+	for td.SpanCount() > 0 {
+		split := SplitSize(td, 500, sizer)
+		assert.LessOrEqual(t, sizer.TracesSize(split), 500)
+		names = append(names, splitSpanNames(t, split)...)
+	}
+	assert.Equal(t, all, names)
+	assert.Equal(t, 0, td.ResourceSpans().Len())
+}
+
+func TestSplitSizeItemExceedsBudget(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	td := splitTestTraces(1, 3, 1)
+	td.ResourceSpans().At(0).Resource().Attributes().PutStr("padding", strings.Repeat("x", 1000))
+
+	// The envelope alone exceeds the budget, the first span is returned
+	// with it.
+	split := SplitSize(td, 100, sizer)
+	assert.Equal(t, []string{"0-0-0"}, splitSpanNames(t, split))
+	assert.Equal(t, []string{"0-1-0", "0-2-0"}, splitSpanNames(t, td))
+	padding, ok := td.ResourceSpans().At(0).Resource().Attributes().Get("padding")
+	require.True(t, ok)
+	assert.Len(t, padding.Str(), 1000)
+}
+
+func TestSplitSizeAll(t *testing.T) {
+	sizer := &ProtoMarshaler{}
+	td := splitSizeTestTraces(2, 2, 5)
+	split := SplitSize(td, sizer.TracesSize(td), sizer)
+	assert.Equal(t, splitSizeTestTraces(2, 2, 5), split)
+	assert.Equal(t, 0, td.ResourceSpans().Len())
+}
+
+// randomSplitTestTraces returns traces named as by splitTestTraces, of
+// random numbers of resources, scopes and spans.
+func randomSplitTestTraces(r *rand.Rand) Traces {
+	td := splitSizeTestTraces(1+r.Intn(5), 4, 10)
+	td.ResourceSpans().RemoveIf(func(rs ResourceSpans) bool {
+		rs.ScopeSpans().RemoveIf(func(ss ScopeSpans) bool {
+			ss.Spans().RemoveIf(func(Span) bool { return r.Intn(3) == 0 })
+			return ss.Spans().Len() == 0
+		})
+		return rs.ScopeSpans().Len() == 0
+	})
+	return td
+}
+
+func TestSplitOrder(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	sizer := &ProtoMarshaler{}
+	for i := 0; i < 100; i++ {
+		td := randomSplitTestTraces(r)
+		all := splitSpanNames(t, td)
+		names := []string{}
+		for td.SpanCount() > 0 {
+			var split Traces
+			if r.Intn(2) == 0 {
+				split = Split(td, 1+r.Intn(10))
+			} else {
+				split = SplitSize(td, r.Intn(1000), sizer)
+			}
+			names = append(names, splitSpanNames(t, split)...)
+			require.Equal(t, all[len(names):], splitSpanNames(t, td))
+		}
+		assert.Equal(t, all, names)
+	}
+}
+
+func benchmarkSplitSize(b *testing.B, split func(Traces, int, DeltaSizer) Traces) {
+	sizer := &ProtoMarshaler{}
+	td := splitSizeTestTraces(10, 10, 100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		cp := NewTraces()
+		td.CopyTo(cp)
+		b.StartTimer()
+		for cp.SpanCount() > 0 {
+			split(cp, 64*1024, sizer)
+		}
+	}
+}
+
+func BenchmarkSplitSize(b *testing.B) {
+	benchmarkSplitSize(b, SplitSize)
+}
+
+func BenchmarkSplitSizeByMarshaling(b *testing.B) {
+	benchmarkSplitSize(b, splitSizeByMarshaling)
+}
@@ -24,7 +24,7 @@ import (
 type Traces internal.Traces
 
 func newTraces(orig *otlpcollectortrace.ExportTraceServiceRequest) Traces {
-	return Traces(internal.NewTraces(orig))
+	return Traces(internal.NewTraces(orig, new(internal.State)))
 }
 
 func (ms Traces) getOrig() *otlpcollectortrace.ExportTraceServiceRequest {
@@ -41,20 +41,92 @@ func (ms Traces) CopyTo(dest Traces) {
 	ms.ResourceSpans().CopyTo(dest.ResourceSpans())
 }
 
+// MarkReadOnly marks ms as shared, e.g. by a fan-out to several
+// consumers, so that the consumers needing to mutate it work on a copy,
+// obtained with AsMutable.  The mark is kept by the copies of the Traces
+// value, and cannot be removed.  It is not enforced by the methods
+// mutating ms.
+func (ms Traces) MarkReadOnly() {
+	internal.GetTracesState(internal.Traces(ms)).MarkReadOnly()
+}
+
+// IsReadOnly returns whether ms was marked read-only.
+func (ms Traces) IsReadOnly() bool {
+	return internal.GetTracesState(internal.Traces(ms)).IsReadOnly()
+}
+
+// AsMutable returns ms when it is not marked read-only, and otherwise a
+// mutable copy of it, sharing no data with ms.
+func (ms Traces) AsMutable() Traces {
+	if !ms.IsReadOnly() {
+		return ms
+	}
+	dest := NewTraces()
+	ms.CopyTo(dest)
+	return dest
+}
+
+// Reset removes all the data of ms, keeping the capacity of its
+// ResourceSpans slice so that ms can be filled again without growing it.
+// The removed data is no longer referenced by ms.  Reset must only be
+// called once ms is no longer used elsewhere.
+func (ms Traces) Reset() {
+	orig := ms.getOrig()
+	for i := range orig.ResourceSpans {
+		orig.ResourceSpans[i] = nil
+	}
+	orig.ResourceSpans = orig.ResourceSpans[:0]
+}
+
 // SpanCount calculates the total number of spans.
 func (ms Traces) SpanCount() int {
 	spanCount := 0
 	rss := ms.ResourceSpans()
 	for i := 0; i < rss.Len(); i++ {
-		rs := rss.At(i)
-		ilss := rs.ScopeSpans()
-		for j := 0; j < ilss.Len(); j++ {
-			spanCount += ilss.At(j).Spans().Len()
-		}
+		spanCount += rss.At(i).SpanCount()
 	}
 	return spanCount
 }
 
+// SpanCount calculates the number of spans of all the scopes of the
+// ResourceSpans.
+func (ms ResourceSpans) SpanCount() int {
+	spanCount := 0
+	sss := ms.ScopeSpans()
+	for i := 0; i < sss.Len(); i++ {
+		spanCount += sss.At(i).SpanCount()
+	}
+	return spanCount
+}
+
+// SpanCount returns the number of spans of the ScopeSpans.
+func (ms ScopeSpans) SpanCount() int {
+	return ms.Spans().Len()
+}
+
+// RemoveEmpty removes the ScopeSpans having no spans, then the
+// ResourceSpans left with no scopes, such as the shells left behind by a
+// split or a filter.  It returns the number of entries removed, scopes and
+// resources together.  The order of the remaining entries is kept.
+func (ms Traces) RemoveEmpty() int {
+	removed := 0
+	ms.ResourceSpans().RemoveIf(func(rs ResourceSpans) bool {
+		rs.ScopeSpans().RemoveIf(func(ss ScopeSpans) bool {
+			if ss.Spans().Len() == 0 {
+				removed++
+				return true
+			}
+			return false
+		})
+		if rs.ScopeSpans().Len() == 0 {
+			removed++
+			return true
+		}
+		return false
+	})
+	return removed
+}
+
 // ResourceSpans returns the ResourceSpansSlice associated with this Metrics.
 func (ms Traces) ResourceSpans() ResourceSpansSlice {
 	return newResourceSpansSlice(&ms.getOrig().ResourceSpans)
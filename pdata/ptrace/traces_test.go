@@ -48,6 +48,14 @@ func TestSpanCount(t *testing.T) {
 	}
 	// 5 + 1 (from rms.At(0) initialized first)
 	assert.EqualValues(t, 6, traces.SpanCount())
+
+	// Each resource and scope counts its own spans.
+	assert.EqualValues(t, 1, rms.At(0).SpanCount())
+	assert.EqualValues(t, 0, rms.At(1).SpanCount())
+	assert.EqualValues(t, 0, rms.At(1).ScopeSpans().At(0).SpanCount())
+	assert.EqualValues(t, 5, rms.At(2).SpanCount())
+	assert.EqualValues(t, 5, rms.At(2).ScopeSpans().At(0).SpanCount())
+	assert.EqualValues(t, 0, NewResourceSpans().SpanCount())
 }
 
 func TestSpanCountWithEmpty(t *testing.T) {
@@ -124,3 +132,124 @@ func TestTracesCopyTo(t *testing.T) {
 	traces.CopyTo(tracesCopy)
 	assert.EqualValues(t, traces, tracesCopy)
 }
+
+func TestTracesReset(t *testing.T) {
+	traces := NewTraces()
+	fillTestResourceSpansSlice(traces.ResourceSpans())
+	rs := traces.ResourceSpans().At(0)
+	capacity := cap(traces.getOrig().ResourceSpans)
+	traces.Reset()
+	assert.Equal(t, 0, traces.ResourceSpans().Len())
+	assert.Equal(t, capacity, cap(traces.getOrig().ResourceSpans))
+	assert.Nil(t, traces.getOrig().ResourceSpans[:1][0])
+
+	// The removed data is unchanged, and not shared with the data
+	// added again.
+	expected := NewTraces()
+	fillTestResourceSpansSlice(expected.ResourceSpans())
+	assert.Equal(t, expected.ResourceSpans().At(0), rs)
+	traces.ResourceSpans().AppendEmpty()
+	assert.NotEqual(t, traces.ResourceSpans().At(0), rs)
+}
+
+func TestTracesRemoveEmpty(t *testing.T) {
+	traces := NewTraces()
+	assert.Equal(t, 0, traces.RemoveEmpty())
+
+	// Shells nested at every level, around two non-empty scopes.
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("name", "first")
+	rs.ScopeSpans().AppendEmpty()
+	rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("a")
+	rs.ScopeSpans().AppendEmpty()
+	traces.ResourceSpans().AppendEmpty()
+	rs = traces.ResourceSpans().AppendEmpty()
+	rs.ScopeSpans().AppendEmpty()
+	rs.ScopeSpans().AppendEmpty()
+	rs = traces.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("name", "last")
+	rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("b")
+
+	expected := NewTraces()
+	rs = expected.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("name", "first")
+	rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("a")
+	rs = expected.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("name", "last")
+	rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("b")
+
+	assert.Equal(t, 6, traces.RemoveEmpty())
+	assert.Equal(t, expected, traces)
+	assert.Equal(t, 0, traces.RemoveEmpty())
+}
+
+func TestTracesReadOnly(t *testing.T) {
+	traces := NewTraces()
+	assert.False(t, traces.IsReadOnly())
+	traces.MarkReadOnly()
+	assert.True(t, traces.IsReadOnly())
+
+	tracesCopy := NewTraces()
+	traces.CopyTo(tracesCopy)
+	assert.False(t, tracesCopy.IsReadOnly())
+
+	// A zero-initialized value, as returned on unmarshal errors, is left as is.
+	assert.NotPanics(t, func() { Traces{}.MarkReadOnly() })
+	assert.False(t, Traces{}.IsReadOnly())
+}
+
+func TestTracesAsMutable(t *testing.T) {
+	traces := NewTraces()
+	fillTestResourceSpansSlice(traces.ResourceSpans())
+	assert.False(t, traces.IsReadOnly())
+	assert.Same(t, traces.getOrig(), traces.AsMutable().getOrig())
+
+	shared := traces
+	traces.MarkReadOnly()
+	assert.True(t, shared.IsReadOnly())
+	mutable := traces.AsMutable()
+	assert.False(t, mutable.IsReadOnly())
+	assert.True(t, traces.IsReadOnly())
+	assert.NotSame(t, traces.getOrig(), mutable.getOrig())
+	assert.Equal(t, traces.ResourceSpans(), mutable.ResourceSpans())
+
+	// The copy does not alias the data of the original.
+	assert.NotSame(t, &traces.getOrig().ResourceSpans[0], &mutable.getOrig().ResourceSpans[0])
+	assert.NotSame(t, traces.getOrig().ResourceSpans[0], mutable.getOrig().ResourceSpans[0])
+	item := mutable.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	original := traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Name()
+	item.SetName("changed")
+	assert.Equal(t, original, traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Name())
+	mutable.ResourceSpans().AppendEmpty()
+	assert.Equal(t, traces.ResourceSpans().Len()+1, mutable.ResourceSpans().Len())
+}
+
+// benchmarkMoveAndAppend moves the resources of many requests of a single
+// resource to an empty slice with appendAll.
+func benchmarkMoveAndAppend(b *testing.B, appendAll func(dest ResourceSpansSlice, srcs []ResourceSpansSlice)) {
+	srcs := make([]ResourceSpansSlice, 1000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		for j := range srcs {
+			srcs[j] = NewResourceSpansSlice()
+			srcs[j].AppendEmpty()
+		}
+		b.StartTimer()
+		appendAll(NewResourceSpansSlice(), srcs)
+	}
+}
+
+func BenchmarkMoveAndAppendTo(b *testing.B) {
+	benchmarkMoveAndAppend(b, func(dest ResourceSpansSlice, srcs []ResourceSpansSlice) {
+		for _, src := range srcs {
+			src.MoveAndAppendTo(dest)
+		}
+	})
+}
+
+func BenchmarkMoveAndAppendFrom(b *testing.B) {
+	benchmarkMoveAndAppend(b, func(dest ResourceSpansSlice, srcs []ResourceSpansSlice) {
+		dest.MoveAndAppendFrom(srcs...)
+	})
+}
@@ -16,377 +16,248 @@ package batchprocessor // import "go.opentelemetry.io/collector/processor/batchp
 
 import (
 	"context"
-	"errors"
-	"fmt"
-	"runtime"
-	"sort"
-	"strings"
-	"sync"
-	"time"
-
-	"go.opentelemetry.io/otel/attribute"
-	"go.uber.org/zap"
-
-	"go.opentelemetry.io/collector/client"
+
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.opentelemetry.io/collector/processor"
+	"go.opentelemetry.io/collector/processor/batchprocessor/batching"
 )
 
-// errTooManyBatchers is returned when the MetadataCardinalityLimit has been reached.
-var errTooManyBatchers = consumererror.NewPermanent(errors.New("too many batcher metadata-value combinations"))
-
-// batch_processor is a component that accepts spans and metrics, places them
-// into batches and sends downstream.
-//
-// batch_processor implements consumer.Traces and consumer.Metrics
-//
-// Batches are sent out with any of the following conditions:
-// - batch size reaches cfg.SendBatchSize
-// - cfg.Timeout is elapsed since the timestamp when the previous batch was sent out.
+// batchProcessor is the batch processor of a signal, the
+// batching.Processor of its pdata.
 type batchProcessor struct {
-	logger           *zap.Logger
-	timeout          time.Duration
-	sendBatchSize    int
-	sendBatchMaxSize int
-
-	// batchFunc is a factory for new batch objects corresponding
-	// with the appropriate signal.
-	batchFunc func() batch
-
-	// metadataKeys is the configured list of metadata keys.  When
-	// empty, the `singleton` batcher is used.  When non-empty,
-	// each distinct combination of metadata keys and values
-	// triggers a new batcher, counted in `goroutines`.
-	metadataKeys []string
-
-	// metadataLimit is the limiting size of the batchers map.
-	metadataLimit int
-
-	shutdownC  chan struct{}
-	goroutines sync.WaitGroup
-
-	telemetry *batchProcessorTelemetry
-
-	//  batcherFinder will be either *singletonBatcher or *multiBatcher
-	batcherFinder
-}
-
-type batcherFinder interface {
-	findBatcher(ctx context.Context) (*batcher, error)
-	currentMetadataCardinality() int
-}
-
-// singleBatcher is used when metadataKeys is empty, to avoid the
-// additional lock and map operations used in multiBatcher.
-type singleBatcher struct {
-	*batcher
-}
-
-// multiBatcher is used when metadataKeys is not empty.
-type multiBatcher struct {
-	*batchProcessor
-
-	lock     sync.Mutex
-	batchers map[attribute.Set]*batcher
-}
-
-// batcher is a single instance of the batcher logic.  When metadata
-// keys are in use, one of these is created per distinct combination
-// of values.
-type batcher struct {
-	// processor refers to this processor, for access to common
-	// configuration.
-	processor *batchProcessor
-
-	// exportCtx is a context with the metadata key-values
-	// corresponding with this batcher set.
-	exportCtx context.Context
-
-	// timer informs the batcher send a batch.
-	timer *time.Timer
-
-	// newItem is used to receive data items from producers.
-	newItem chan any
-
-	// batch is an in-flight data item containing one of the
-	// underlying data types.
-	batch batch
-}
-
-// batch is an interface generalizing the individual signal types.
-type batch interface {
-	// export the current batch
-	export(ctx context.Context, sendBatchMaxSize int, returnBytes bool) (sentBatchSize int, sentBatchBytes int, err error)
-
-	// itemCount returns the size of the current batch
-	itemCount() int
-
-	// add item to the current batch
-	add(item any)
+	*batching.Processor
 }
 
 var _ consumer.Traces = (*batchProcessor)(nil)
 var _ consumer.Metrics = (*batchProcessor)(nil)
 var _ consumer.Logs = (*batchProcessor)(nil)
 
-// newBatchProcessor returns a new batch processor component.
-func newBatchProcessor(set processor.CreateSettings, cfg *Config, batchFunc func() batch, useOtel bool) (*batchProcessor, error) {
-	// use lower-case, to be consistent with http/2 headers.
-	mks := make([]string, len(cfg.MetadataKeys))
-	for i, k := range cfg.MetadataKeys {
-		mks[i] = strings.ToLower(k)
-	}
-	sort.Strings(mks)
-	bp := &batchProcessor{
-		logger: set.Logger,
-
-		sendBatchSize:    int(cfg.SendBatchSize),
-		sendBatchMaxSize: int(cfg.SendBatchMaxSize),
-		timeout:          cfg.Timeout,
-		batchFunc:        batchFunc,
-		shutdownC:        make(chan struct{}, 1),
-		metadataKeys:     mks,
-		metadataLimit:    int(cfg.MetadataCardinalityLimit),
-	}
-	if len(bp.metadataKeys) == 0 {
-		bp.batcherFinder = &singleBatcher{bp.newBatcher(nil)}
-	} else {
-		bp.batcherFinder = &multiBatcher{
-			batchProcessor: bp,
-			batchers:       map[attribute.Set]*batcher{},
-		}
-	}
-
-	bpt, err := newBatchProcessorTelemetry(set, bp.currentMetadataCardinality, useOtel)
-	if err != nil {
-		return nil, fmt.Errorf("error creating batch processor telemetry: %w", err)
-	}
-	bp.telemetry = bpt
-
-	return bp, nil
-}
-
-// newBatcher gets or creates a batcher corresponding with attrs.
-func (bp *batchProcessor) newBatcher(md map[string][]string) *batcher {
-	exportCtx := client.NewContext(context.Background(), client.Info{
-		Metadata: client.NewMetadata(md),
-	})
-	b := &batcher{
-		processor: bp,
-		newItem:   make(chan any, runtime.NumCPU()),
-		exportCtx: exportCtx,
-		batch:     bp.batchFunc(),
-	}
-	b.processor.goroutines.Add(1)
-	go b.start()
-	return b
-}
-
-func (bp *batchProcessor) Capabilities() consumer.Capabilities {
-	return consumer.Capabilities{MutatesData: true}
+// ConsumeTraces implements TracesProcessor
+func (bp *batchProcessor) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	return bp.Consume(ctx, td, td.SpanCount())
 }
 
-// Start is invoked during service startup.
-func (bp *batchProcessor) Start(context.Context, component.Host) error {
-	bp.goroutines.Add(1)
-	return nil
+// ConsumeMetrics implements MetricsProcessor
+func (bp *batchProcessor) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
+	return bp.Consume(ctx, md, md.DataPointCount())
 }
 
-// Shutdown is invoked during service shutdown.
-func (bp *batchProcessor) Shutdown(context.Context) error {
-	// Done corresponds with the initial Add(1) in Start.
-	bp.goroutines.Done()
-
-	close(bp.shutdownC)
-
-	// Wait until all goroutines are done.
-	bp.goroutines.Wait()
-	return nil
+// ConsumeLogs implements LogsProcessor
+func (bp *batchProcessor) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	return bp.Consume(ctx, ld, ld.LogRecordCount())
 }
 
-func (b *batcher) start() {
-	defer b.processor.goroutines.Done()
-
-	// timerCh ensures we only block when there is a
-	// timer, since <- from a nil channel is blocking.
-	var timerCh <-chan time.Time
-	if b.processor.timeout != 0 && b.processor.sendBatchSize != 0 {
-		b.timer = time.NewTimer(b.processor.timeout)
-		timerCh = b.timer.C
+// newSettings returns the batching.Settings of the pdata of dataType,
+// but for NewBatch and Next, which depend on the next consumer.
+func newSettings(dataType component.DataType, cfg *Config, fo factoryOptions) (batching.Settings, error) {
+	s := batching.Settings{
+		DataType:            dataType,
+		Count:               countItems,
+		Size:                sizeItems,
+		Reject:              rejectItems,
+		Returned:            returnedData,
+		Mutable:             mutableItem,
+		Partition:           partitionItems,
+		ResourceSet:         resourceSet,
+		MetadataTransformer: fo.metadataTransformer,
+		DeadLetterExporter: func(host component.Host, id component.ID) (func(context.Context, any) error, error) {
+			return deadLetterExporter(host, dataType, id)
+		},
 	}
-	for {
-		select {
-		case <-b.processor.shutdownC:
-		DONE:
-			for {
-				select {
-				case item := <-b.newItem:
-					b.processItem(item)
-				default:
-					break DONE
-				}
-			}
-			// This is the close of the channel
-			if b.batch.itemCount() > 0 {
-				// TODO: Set a timeout on sendTraces or
-				// make it cancellable using the context that Shutdown gets as a parameter
-				b.sendItems(triggerTimeout)
-			}
-			return
-		case item := <-b.newItem:
-			if item == nil {
-				continue
-			}
-			b.processItem(item)
-		case <-timerCh:
-			if b.batch.itemCount() > 0 {
-				b.sendItems(triggerTimeout)
-			}
-			b.resetTimer()
+	s.Marshal, s.Unmarshal = walMarshalers(dataType)
+	if bm := newBypassMatcher(cfg.Bypass); bm != nil {
+		s.Bypass = bm.matches
+	}
+	if c := fo.deadLetter(dataType); c != nil {
+		dl, err := newDeadLetterFunc(dataType, c)
+		if err != nil {
+			return s, err
 		}
+		s.DeadLetter = dl
 	}
+	return s, nil
 }
 
-func (b *batcher) processItem(item any) {
-	b.batch.add(item)
-	sent := false
-	for b.batch.itemCount() > 0 && (!b.hasTimer() || b.batch.itemCount() >= b.processor.sendBatchSize) {
-		sent = true
-		b.sendItems(triggerBatchSize)
-	}
-
-	if sent {
-		b.stopTimer()
-		b.resetTimer()
+// newBatchTracesProcessor creates a new batch processor that batches traces by size or with timeout
+func newBatchTracesProcessor(set processor.CreateSettings, next consumer.Traces, cfg *Config, opts ...FactoryOption) (*batchProcessor, error) {
+	fo := newFactoryOptions(opts)
+	s, err := newSettings(component.DataTypeTraces, cfg, fo)
+	if err != nil {
+		return nil, err
 	}
-}
-
-func (b *batcher) hasTimer() bool {
-	return b.timer != nil
-}
-
-func (b *batcher) stopTimer() {
-	if b.hasTimer() && !b.timer.Stop() {
-		<-b.timer.C
+	// A read-only request cannot be reset to be reused.
+	readOnly := acceptsReadOnly(next)
+	reuse := fo.reuseBatches && !readOnly
+	mutatesData := cfg.MutatesData == nil || *cfg.MutatesData
+	s.NewBatch = func(trackBytes bool) batching.Batch {
+		bt := newBatchTraces(next)
+		bt.reuse = reuse
+		bt.readOnly = readOnly
+		bt.trackBytes = trackBytes
+		bt.splitMode = cfg.SplitMode
+		bt.splitAtResource = cfg.SplitAt == batching.SplitAtResource
+		bt.compact = cfg.Compact
+		bt.copyItems = !mutatesData
+		return bt
 	}
-}
-
-func (b *batcher) resetTimer() {
-	if b.hasTimer() {
-		b.timer.Reset(b.processor.timeout)
+	s.Next = next
+	p, err := batching.NewProcessor(set, cfg, s)
+	if err != nil {
+		return nil, err
 	}
+	return &batchProcessor{Processor: p}, nil
 }
 
-func (b *batcher) sendItems(trigger trigger) {
-	sent, bytes, err := b.batch.export(b.exportCtx, b.processor.sendBatchMaxSize, b.processor.telemetry.detailed)
+// newBatchMetricsProcessor creates a new batch processor that batches metrics by size or with timeout
+func newBatchMetricsProcessor(set processor.CreateSettings, next consumer.Metrics, cfg *Config, opts ...FactoryOption) (*batchProcessor, error) {
+	fo := newFactoryOptions(opts)
+	s, err := newSettings(component.DataTypeMetrics, cfg, fo)
 	if err != nil {
-		b.processor.logger.Warn("Sender failed", zap.Error(err))
-	} else {
-		b.processor.telemetry.record(trigger, int64(sent), int64(bytes))
-	}
-}
-
-func (sb *singleBatcher) findBatcher(_ context.Context) (*batcher, error) {
-	return sb.batcher, nil
-}
-
-func (mb *multiBatcher) findBatcher(ctx context.Context) (*batcher, error) {
-	// Get each metadata key value, form the corresponding
-	// attribute set for use as a map lookup key.
-	info := client.FromContext(ctx)
-	md := map[string][]string{}
-	var attrs []attribute.KeyValue
-	for _, k := range mb.metadataKeys {
-		// Lookup the value in the incoming metadata, copy it
-		// into the outgoing metadata, and create a unique
-		// value for the attributeSet.
-		vs := info.Metadata.Get(k)
-		md[k] = vs
-		if len(vs) == 1 {
-			attrs = append(attrs, attribute.String(k, vs[0]))
-		} else {
-			attrs = append(attrs, attribute.StringSlice(k, vs))
-		}
+		return nil, err
 	}
-	aset := attribute.NewSet(attrs...)
-
-	mb.lock.Lock()
-	defer mb.lock.Unlock()
-
-	b, ok := mb.batchers[aset]
-	if ok {
-		return b, nil
+	// A read-only request cannot be reset to be reused.
+	readOnly := acceptsReadOnly(next)
+	reuse := fo.reuseBatches && !readOnly
+	mutatesData := cfg.MutatesData == nil || *cfg.MutatesData
+	var p *batching.Processor
+	s.NewBatch = func(trackBytes bool) batching.Batch {
+		bm := newBatchMetrics(next)
+		bm.reuse = reuse
+		bm.readOnly = readOnly
+		bm.trackBytes = trackBytes
+		bm.splitAtResource = cfg.SplitAt == batching.SplitAtResource
+		bm.compact = cfg.Compact
+		bm.copyItems = !mutatesData
+		bm.mergeDataPoints = cfg.MergeDataPoints
+		// Only called when adding data, once p is set.
+		bm.onConflicts = func(n int) {
+			p.RecordMetricMergeConflicts(n)
+		}
+		return bm
 	}
-
-	if limit := mb.metadataLimit; limit != 0 && len(mb.batchers) >= limit {
-		return nil, errTooManyBatchers
+	s.Next = next
+	p, err = batching.NewProcessor(set, cfg, s)
+	if err != nil {
+		return nil, err
 	}
-
-	// aset.ToSlice() returns the sorted, deduplicated,
-	// and name-downcased list of attributes.
-	b = mb.newBatcher(md)
-	mb.batchers[aset] = b
-	return b, nil
-}
-
-func (sb *singleBatcher) currentMetadataCardinality() int {
-	return 1
-}
-
-func (mb *multiBatcher) currentMetadataCardinality() int {
-	mb.lock.Lock()
-	defer mb.lock.Unlock()
-	return len(mb.batchers)
+	return &batchProcessor{Processor: p}, nil
 }
 
-// ConsumeTraces implements TracesProcessor
-func (bp *batchProcessor) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
-	b, err := bp.findBatcher(ctx)
+// newBatchLogsProcessor creates a new batch processor that batches logs by size or with timeout
+func newBatchLogsProcessor(set processor.CreateSettings, next consumer.Logs, cfg *Config, opts ...FactoryOption) (*batchProcessor, error) {
+	fo := newFactoryOptions(opts)
+	s, err := newSettings(component.DataTypeLogs, cfg, fo)
+	if err != nil {
+		return nil, err
+	}
+	// A read-only request cannot be reset to be reused.
+	readOnly := acceptsReadOnly(next)
+	reuse := fo.reuseBatches && !readOnly
+	mutatesData := cfg.MutatesData == nil || *cfg.MutatesData
+	s.NewBatch = func(trackBytes bool) batching.Batch {
+		bl := newBatchLogs(next)
+		bl.reuse = reuse
+		bl.readOnly = readOnly
+		bl.trackBytes = trackBytes
+		bl.splitAtResource = cfg.SplitAt == batching.SplitAtResource
+		bl.splitAtScope = cfg.SplitAt == batching.SplitAtScope
+		bl.compact = cfg.Compact
+		bl.copyItems = !mutatesData
+		return bl
+	}
+	s.Next = next
+	p, err := batching.NewProcessor(set, cfg, s)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	b.newItem <- td
-	return nil
+	return &batchProcessor{Processor: p}, nil
 }
 
-// ConsumeMetrics implements MetricsProcessor
-func (bp *batchProcessor) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
-	b, err := bp.findBatcher(ctx)
-	if err != nil {
-		return nil
+// mutableItem returns a mutable copy of a read-only item, and whether
+// it was read-only.
+func mutableItem(item any) (any, bool) {
+	switch data := item.(type) {
+	case ptrace.Traces:
+		if data.IsReadOnly() {
+			return data.AsMutable(), true
+		}
+	case pmetric.Metrics:
+		if data.IsReadOnly() {
+			return data.AsMutable(), true
+		}
+	case plog.Logs:
+		if data.IsReadOnly() {
+			return data.AsMutable(), true
+		}
 	}
-	b.newItem <- md
-	return nil
+	return item, false
 }
 
-// ConsumeLogs implements LogsProcessor
-func (bp *batchProcessor) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
-	b, err := bp.findBatcher(ctx)
-	if err != nil {
-		return nil
+// rejectItems wraps err in the consumererror carrying the rejected
+// items, combined in one request grown once.
+func rejectItems(err error, items []any) error {
+	switch first := items[0].(type) {
+	case ptrace.Traces:
+		srcs := make([]ptrace.ResourceSpansSlice, 0, len(items)-1)
+		for _, item := range items[1:] {
+			srcs = append(srcs, item.(ptrace.Traces).ResourceSpans())
+		}
+		first.ResourceSpans().MoveAndAppendFrom(srcs...)
+		return consumererror.NewTraces(err, first)
+	case pmetric.Metrics:
+		srcs := make([]pmetric.ResourceMetricsSlice, 0, len(items)-1)
+		for _, item := range items[1:] {
+			srcs = append(srcs, item.(pmetric.Metrics).ResourceMetrics())
+		}
+		first.ResourceMetrics().MoveAndAppendFrom(srcs...)
+		return consumererror.NewMetrics(err, first)
+	case plog.Logs:
+		srcs := make([]plog.ResourceLogsSlice, 0, len(items)-1)
+		for _, item := range items[1:] {
+			srcs = append(srcs, item.(plog.Logs).ResourceLogs())
+		}
+		first.ResourceLogs().MoveAndAppendFrom(srcs...)
+		return consumererror.NewLogs(err, first)
 	}
-	b.newItem <- ld
-	return nil
+	return err
 }
 
-// newBatchTracesProcessor creates a new batch processor that batches traces by size or with timeout
-func newBatchTracesProcessor(set processor.CreateSettings, next consumer.Traces, cfg *Config, useOtel bool) (*batchProcessor, error) {
-	return newBatchProcessor(set, cfg, func() batch { return newBatchTraces(next) }, useOtel)
+// countItems returns the number of spans, data points, or log records
+// in an item.
+func countItems(item any) int {
+	switch data := item.(type) {
+	case ptrace.Traces:
+		return data.SpanCount()
+	case pmetric.Metrics:
+		return data.DataPointCount()
+	case plog.Logs:
+		return data.LogRecordCount()
+	}
+	return 0
 }
 
-// newBatchMetricsProcessor creates a new batch processor that batches metrics by size or with timeout
-func newBatchMetricsProcessor(set processor.CreateSettings, next consumer.Metrics, cfg *Config, useOtel bool) (*batchProcessor, error) {
-	return newBatchProcessor(set, cfg, func() batch { return newBatchMetrics(next) }, useOtel)
+// sizeItems returns the marshaled size of an item.
+func sizeItems(item any) int {
+	switch data := item.(type) {
+	case ptrace.Traces:
+		return (&ptrace.ProtoMarshaler{}).TracesSize(data)
+	case pmetric.Metrics:
+		return (&pmetric.ProtoMarshaler{}).MetricsSize(data)
+	case plog.Logs:
+		return (&plog.ProtoMarshaler{}).LogsSize(data)
+	}
+	return 0
 }
 
-// newBatchLogsProcessor creates a new batch processor that batches logs by size or with timeout
-func newBatchLogsProcessor(set processor.CreateSettings, next consumer.Logs, cfg *Config, useOtel bool) (*batchProcessor, error) {
-	return newBatchProcessor(set, cfg, func() batch { return newBatchLogs(next) }, useOtel)
+// acceptsReadOnly returns whether next accepts data marked read-only.
+func acceptsReadOnly(next interface{ Capabilities() consumer.Capabilities }) bool {
+	return next != nil && next.Capabilities().AcceptsReadOnly
 }
 
 type batchTraces struct {
@@ -394,92 +265,581 @@ type batchTraces struct {
 	traceData    ptrace.Traces
 	spanCount    int
 	sizer        ptrace.Sizer
+
+	// resourceHint is the number of resources of the last batch
+	// sent whole, used to size the next one.
+	resourceHint int
+
+	// trackBytes is set when the size in bytes of every export is
+	// needed, so that bytes, the size of the batch, is kept up to
+	// date instead of measuring the batch whole.
+	trackBytes bool
+	bytes      int
+
+	// splitMode is the SplitMode of the processor.  When splitting
+	// by trace, index counts the spans of each trace of the batch
+	// once it has been split, until it is exported whole.
+	splitMode string
+	index     *traceIndex
+
+	// splitAtResource is set when SplitAt is "resource".
+	splitAtResource bool
+
+	// compact is set by Compact.  resources indexes the resource and
+	// scope entries of the batch, until their positions change.
+	compact   bool
+	resources *compactIndex
+
+	// chunks returns the requests split off the batch, until data is
+	// added to the batch or it is exported whole, for the batch to be
+	// walked once however many requests it is split into.  chunkSize is
+	// the number of spans of these requests.  Until then, the
+	// batch still holds the emptied entries of the requests split off.
+	chunks    *ptrace.ChunkIterator
+	chunkSize int
+
+	// copyItems is set when the processor does not mutate its input,
+	// so that the data added is copied instead of moved.
+	copyItems bool
+
+	// reuse is set when the next consumer does not retain the data,
+	// so that spare, the container of the last batch exported whole,
+	// is reused for the next batch.
+	reuse    bool
+	spare    ptrace.Traces
+	hasSpare bool
+
+	// readOnly is set when the next consumer accepts read-only data,
+	// so that the requests exported are marked read-only.
+	readOnly bool
 }
 
 func newBatchTraces(nextConsumer consumer.Traces) *batchTraces {
 	return &batchTraces{nextConsumer: nextConsumer, traceData: ptrace.NewTraces(), sizer: &ptrace.ProtoMarshaler{}}
 }
 
-// add updates current batchTraces by adding new TraceData object
-func (bt *batchTraces) add(item any) {
+// add updates current batchTraces by adding new TraceData object of n
+// spans
+func (bt *batchTraces) Add(item any, n int) {
+	bt.stopChunks()
+	if n == 0 {
+		return
+	}
 	td := item.(ptrace.Traces)
-	newSpanCount := td.SpanCount()
-	if newSpanCount == 0 {
+	bt.spanCount += n
+	if bt.trackBytes {
+		bt.bytes += bt.sizer.TracesSize(td)
+	}
+	if bt.index != nil {
+		bt.index.add(td)
+	}
+	rss := bt.traceData.ResourceSpans()
+	if rss.Len() == 0 && td.ResourceSpans().Len() < bt.resourceHint {
+		// Allocate the batch once instead of growing it with
+		// every small request.
+		rss.EnsureCapacity(bt.resourceHint)
+	}
+	if bt.compact {
+		if bt.copyItems {
+			// Copied whole, for its parts to be moved to the
+			// entries of the batch.
+			clone := ptrace.NewTraces()
+			td.CopyTo(clone)
+			td = clone
+		}
+		bt.addCompact(td)
+		return
+	}
+	if bt.copyItems {
+		src := td.ResourceSpans()
+		for i := 0; i < src.Len(); i++ {
+			src.At(i).CopyTo(rss.AppendEmpty())
+		}
 		return
 	}
+	td.ResourceSpans().MoveAndAppendTo(rss)
+}
 
-	bt.spanCount += newSpanCount
-	td.ResourceSpans().MoveAndAppendTo(bt.traceData.ResourceSpans())
+// addCompact moves the scopes of every resource entry of td to the entry
+// of the batch with the same resource, adding an entry for the others.
+func (bt *batchTraces) addCompact(td ptrace.Traces) {
+	rss := bt.traceData.ResourceSpans()
+	at := func(i int) (pcommon.Resource, string) {
+		rs := rss.At(i)
+		return rs.Resource(), rs.SchemaUrl()
+	}
+	if bt.resources == nil {
+		bt.resources = newCompactIndex(rss.Len(), at)
+	}
+	src := td.ResourceSpans()
+	for i := 0; i < src.Len(); i++ {
+		rs := src.At(i)
+		h := hashResource(rs.Resource(), rs.SchemaUrl())
+		j, ok := bt.resources.findResource(h, rs.Resource(), rs.SchemaUrl(), at)
+		if !ok {
+			j = rss.Len()
+			dst := rss.AppendEmpty()
+			rs.Resource().MoveTo(dst.Resource())
+			dst.SetSchemaUrl(rs.SchemaUrl())
+			bt.resources.addResource(h)
+		}
+		bt.addScopes(j, rs.ScopeSpans())
+	}
+	src.RemoveIf(func(ptrace.ResourceSpans) bool { return true })
+}
+
+// addScopes moves the items of every scope entry of src to the scope
+// entry of the resource entry at position i with the same scope, adding
+// an entry for the others.
+func (bt *batchTraces) addScopes(i int, src ptrace.ScopeSpansSlice) {
+	sss := bt.traceData.ResourceSpans().At(i).ScopeSpans()
+	at := func(k int) (pcommon.InstrumentationScope, string) {
+		ss := sss.At(k)
+		return ss.Scope(), ss.SchemaUrl()
+	}
+	si := bt.resources.scopeIndex(i, sss.Len(), at)
+	for k := 0; k < src.Len(); k++ {
+		ss := src.At(k)
+		h := hashScope(ss.Scope(), ss.SchemaUrl())
+		if l, ok := findScope(si, h, ss.Scope(), ss.SchemaUrl(), at); ok {
+			ss.Spans().MoveAndAppendTo(sss.At(l).Spans())
+			continue
+		}
+		ss.MoveTo(sss.AppendEmpty())
+		si.put(h, sss.Len()-1)
+	}
+}
+
+func (bt *batchTraces) Requeue(data any) {
+	bt.stopChunks()
+	// The returned data may be that of a read-only request.
+	td := data.(ptrace.Traces).AsMutable()
+	bt.spanCount += td.SpanCount()
+	if bt.trackBytes {
+		bt.bytes += bt.sizer.TracesSize(td)
+	}
+	bt.traceData.ResourceSpans().MoveAndAppendTo(td.ResourceSpans())
+	bt.traceData = td
+	// Counted again at the next split, in the new order.
+	bt.index = nil
+	bt.resources = nil
 }
 
-func (bt *batchTraces) export(ctx context.Context, sendBatchMaxSize int, returnBytes bool) (int, int, error) {
+func (bt *batchTraces) Export(ctx context.Context, sendBatchMaxSize int) (any, int, int, error) {
 	var req ptrace.Traces
 	var sent int
 	var bytes int
-	if sendBatchMaxSize > 0 && bt.itemCount() > sendBatchMaxSize {
-		req = splitTraces(sendBatchMaxSize, bt.traceData)
-		bt.spanCount -= sendBatchMaxSize
-		sent = sendBatchMaxSize
+	if sendBatchMaxSize > 0 && bt.ItemCount() > sendBatchMaxSize {
+		req, sent = bt.split(sendBatchMaxSize)
+		// The containers of the cut items are left on both sides.  The
+		// chunk iterator holds on to the remainder, pruned once it is
+		// stopped.
+		req.RemoveEmpty()
+		if bt.chunks == nil {
+			bt.traceData.RemoveEmpty()
+		}
+		bt.spanCount -= sent
+		bt.resources = nil
+		if bt.trackBytes {
+			bytes = bt.sizer.TracesSize(req)
+			// The remainder also holds the resource and scope
+			// of the cut item, which this does not account for.
+			bt.bytes -= bytes
+			if bt.bytes < 0 {
+				bt.bytes = 0
+			}
+		}
 	} else {
+		bt.stopChunks()
 		req = bt.traceData
 		sent = bt.spanCount
-		bt.traceData = ptrace.NewTraces()
+		bytes = bt.bytes
+		bt.resourceHint = req.ResourceSpans().Len()
+		bt.traceData = bt.nextTraces()
 		bt.spanCount = 0
+		bt.bytes = 0
+		bt.resources = nil
+		bt.index = nil
+	}
+	if bt.readOnly {
+		req.MarkReadOnly()
 	}
-	if returnBytes {
+	err := bt.nextConsumer.ConsumeTraces(ctx, req)
+	if err != nil && !bt.trackBytes {
+		// Sized for the failure to be reported.
 		bytes = bt.sizer.TracesSize(req)
 	}
-	return sent, bytes, bt.nextConsumer.ConsumeTraces(ctx, req)
+	// Only a batch exported whole is reused, the parts of a split
+	// batch are new containers.
+	if err == nil && bt.reuse && bt.ItemCount() == 0 {
+		req.Reset()
+		bt.spare, bt.hasSpare = req, true
+	}
+	return req, sent, bytes, err
+}
+
+// split removes a request of about size spans from the batch, cut as
+// set by splitAtResource and splitMode, returning it with its number of
+// spans.
+func (bt *batchTraces) split(size int) (ptrace.Traces, int) {
+	if bt.splitAtResource {
+		return splitTracesAtResource(size, bt.traceData)
+	}
+	if bt.splitMode != batching.SplitModeTrace && bt.splitMode != batching.SplitModeTraceStrict {
+		return bt.nextChunk(size), size
+	}
+	if bt.index == nil {
+		bt.index = newTraceIndex(bt.traceData)
+	}
+	selected, n := bt.index.cut(size, bt.splitMode == batching.SplitModeTraceStrict)
+	return splitTracesByTrace(bt.traceData, selected), n
+}
+
+// nextChunk moves the next request of size spans out of the batch.
+func (bt *batchTraces) nextChunk(size int) ptrace.Traces {
+	if bt.chunks == nil || bt.chunkSize != size {
+		bt.stopChunks()
+		bt.chunks, bt.chunkSize = ptrace.Chunks(bt.traceData, ptrace.WithMaxItems(size)), size
+	}
+	bt.chunks.Next()
+	return bt.chunks.Chunk()
+}
+
+// stopChunks removes the requests already split off from the batch, and
+// the containers they emptied, for it to be changed or exported whole.
+func (bt *batchTraces) stopChunks() {
+	if bt.chunks != nil {
+		bt.chunks.Stop()
+		bt.chunks = nil
+		bt.traceData.RemoveEmpty()
+	}
 }
 
-func (bt *batchTraces) itemCount() int {
+// nextTraces returns the container of the next batch, the spare one when
+// available.
+func (bt *batchTraces) nextTraces() ptrace.Traces {
+	if bt.hasSpare {
+		bt.hasSpare = false
+		return bt.spare
+	}
+	return ptrace.NewTraces()
+}
+
+func (bt *batchTraces) Consume(ctx context.Context, req any) error {
+	return bt.nextConsumer.ConsumeTraces(ctx, req.(ptrace.Traces))
+}
+
+func (bt *batchTraces) ItemCount() int {
 	return bt.spanCount
 }
 
+func (bt *batchTraces) ByteSize() int {
+	return bt.bytes
+}
+
 type batchMetrics struct {
 	nextConsumer   consumer.Metrics
 	metricData     pmetric.Metrics
 	dataPointCount int
 	sizer          pmetric.Sizer
+
+	// resourceHint is the number of resources of the last batch
+	// sent whole, used to size the next one.
+	resourceHint int
+
+	// trackBytes is set when the size in bytes of every export is
+	// needed, so that bytes, the size of the batch, is kept up to
+	// date instead of measuring the batch whole.
+	trackBytes bool
+	bytes      int
+
+	// splitAtResource is set when SplitAt is "resource".
+	splitAtResource bool
+
+	// compact is set by Compact.  resources indexes the resource and
+	// scope entries of the batch, until their positions change.
+	compact   bool
+	resources *compactIndex
+
+	// chunks returns the requests split off the batch, until data is
+	// added to the batch or it is exported whole, for the batch to be
+	// walked once however many requests it is split into.  chunkSize is
+	// the number of data points of these requests.  Until then, the
+	// batch still holds the emptied entries of the requests split off.
+	chunks    *pmetric.ChunkIterator
+	chunkSize int
+
+	// mergeDataPoints is set by MergeDataPoints, onConflicts then
+	// being called with the number of metrics of a request not merged
+	// into a metric of the same name.
+	mergeDataPoints bool
+	onConflicts     func(n int)
+
+	// copyItems is set when the processor does not mutate its input,
+	// so that the data added is copied instead of moved.
+	copyItems bool
+
+	// reuse is set when the next consumer does not retain the data,
+	// so that spare, the container of the last batch exported whole,
+	// is reused for the next batch.
+	reuse    bool
+	spare    pmetric.Metrics
+	hasSpare bool
+
+	// readOnly is set when the next consumer accepts read-only data,
+	// so that the requests exported are marked read-only.
+	readOnly bool
 }
 
 func newBatchMetrics(nextConsumer consumer.Metrics) *batchMetrics {
 	return &batchMetrics{nextConsumer: nextConsumer, metricData: pmetric.NewMetrics(), sizer: &pmetric.ProtoMarshaler{}}
 }
 
-func (bm *batchMetrics) export(ctx context.Context, sendBatchMaxSize int, returnBytes bool) (int, int, error) {
+func (bm *batchMetrics) Export(ctx context.Context, sendBatchMaxSize int) (any, int, int, error) {
 	var req pmetric.Metrics
 	var sent int
 	var bytes int
 	if sendBatchMaxSize > 0 && bm.dataPointCount > sendBatchMaxSize {
-		req = splitMetrics(sendBatchMaxSize, bm.metricData)
-		bm.dataPointCount -= sendBatchMaxSize
-		sent = sendBatchMaxSize
+		req, sent = bm.split(sendBatchMaxSize)
+		// The containers of the cut items are left on both sides.  The
+		// chunk iterator holds on to the remainder, pruned once it is
+		// stopped.
+		req.RemoveEmpty()
+		if bm.chunks == nil {
+			bm.metricData.RemoveEmpty()
+		}
+		bm.dataPointCount -= sent
+		bm.resources = nil
+		if bm.trackBytes {
+			bytes = bm.sizer.MetricsSize(req)
+			// The remainder also holds the resource and scope
+			// of the cut item, which this does not account for.
+			bm.bytes -= bytes
+			if bm.bytes < 0 {
+				bm.bytes = 0
+			}
+		}
 	} else {
+		bm.stopChunks()
 		req = bm.metricData
 		sent = bm.dataPointCount
-		bm.metricData = pmetric.NewMetrics()
+		bytes = bm.bytes
+		bm.resourceHint = req.ResourceMetrics().Len()
+		bm.metricData = bm.nextMetrics()
 		bm.dataPointCount = 0
+		bm.bytes = 0
+		bm.resources = nil
+	}
+	if bm.readOnly {
+		req.MarkReadOnly()
 	}
-	if returnBytes {
+	err := bm.nextConsumer.ConsumeMetrics(ctx, req)
+	if err != nil && !bm.trackBytes {
+		// Sized for the failure to be reported.
 		bytes = bm.sizer.MetricsSize(req)
 	}
-	return sent, bytes, bm.nextConsumer.ConsumeMetrics(ctx, req)
+	// Only a batch exported whole is reused, the parts of a split
+	// batch are new containers.
+	if err == nil && bm.reuse && bm.ItemCount() == 0 {
+		req.Reset()
+		bm.spare, bm.hasSpare = req, true
+	}
+	return req, sent, bytes, err
 }
 
-func (bm *batchMetrics) itemCount() int {
+// split removes a request of about size data points from the batch,
+// cut as set by splitAtResource, returning it with its number of data
+// points.
+func (bm *batchMetrics) split(size int) (pmetric.Metrics, int) {
+	if bm.splitAtResource {
+		return splitMetricsAtResource(size, bm.metricData)
+	}
+	return bm.nextChunk(size), size
+}
+
+// nextChunk moves the next request of size data points out of the batch.
+func (bm *batchMetrics) nextChunk(size int) pmetric.Metrics {
+	if bm.chunks == nil || bm.chunkSize != size {
+		bm.stopChunks()
+		bm.chunks, bm.chunkSize = pmetric.Chunks(bm.metricData, pmetric.WithMaxItems(size)), size
+	}
+	bm.chunks.Next()
+	return bm.chunks.Chunk()
+}
+
+// stopChunks removes the requests already split off from the batch, and
+// the containers they emptied, for it to be changed or exported whole.
+func (bm *batchMetrics) stopChunks() {
+	if bm.chunks != nil {
+		bm.chunks.Stop()
+		bm.chunks = nil
+		bm.metricData.RemoveEmpty()
+	}
+}
+
+// nextMetrics returns the container of the next batch, the spare one when
+// available.
+func (bm *batchMetrics) nextMetrics() pmetric.Metrics {
+	if bm.hasSpare {
+		bm.hasSpare = false
+		return bm.spare
+	}
+	return pmetric.NewMetrics()
+}
+
+func (bm *batchMetrics) Consume(ctx context.Context, req any) error {
+	return bm.nextConsumer.ConsumeMetrics(ctx, req.(pmetric.Metrics))
+}
+
+func (bm *batchMetrics) ItemCount() int {
 	return bm.dataPointCount
 }
 
-func (bm *batchMetrics) add(item any) {
-	md := item.(pmetric.Metrics)
+func (bm *batchMetrics) ByteSize() int {
+	return bm.bytes
+}
 
-	newDataPointCount := md.DataPointCount()
-	if newDataPointCount == 0 {
+func (bm *batchMetrics) Add(item any, n int) {
+	bm.stopChunks()
+	if n == 0 {
+		return
+	}
+	md := item.(pmetric.Metrics)
+	bm.dataPointCount += n
+	if bm.trackBytes {
+		bm.bytes += bm.sizer.MetricsSize(md)
+	}
+	rms := bm.metricData.ResourceMetrics()
+	if rms.Len() == 0 && md.ResourceMetrics().Len() < bm.resourceHint {
+		rms.EnsureCapacity(bm.resourceHint)
+	}
+	if bm.compact {
+		if bm.copyItems {
+			// Copied whole, for its parts to be moved to the
+			// entries of the batch.
+			clone := pmetric.NewMetrics()
+			md.CopyTo(clone)
+			md = clone
+		}
+		bm.addCompact(md)
+		return
+	}
+	if bm.copyItems {
+		src := md.ResourceMetrics()
+		for i := 0; i < src.Len(); i++ {
+			src.At(i).CopyTo(rms.AppendEmpty())
+		}
 		return
 	}
-	bm.dataPointCount += newDataPointCount
-	md.ResourceMetrics().MoveAndAppendTo(bm.metricData.ResourceMetrics())
+	md.ResourceMetrics().MoveAndAppendTo(rms)
+}
+
+// addCompact moves the scopes of every resource entry of md to the entry
+// of the batch with the same resource, adding an entry for the others.
+func (bm *batchMetrics) addCompact(md pmetric.Metrics) {
+	rms := bm.metricData.ResourceMetrics()
+	at := func(i int) (pcommon.Resource, string) {
+		rm := rms.At(i)
+		return rm.Resource(), rm.SchemaUrl()
+	}
+	if bm.resources == nil {
+		bm.resources = newCompactIndex(rms.Len(), at)
+	}
+	src := md.ResourceMetrics()
+	for i := 0; i < src.Len(); i++ {
+		rm := src.At(i)
+		h := hashResource(rm.Resource(), rm.SchemaUrl())
+		j, ok := bm.resources.findResource(h, rm.Resource(), rm.SchemaUrl(), at)
+		if !ok {
+			j = rms.Len()
+			dst := rms.AppendEmpty()
+			rm.Resource().MoveTo(dst.Resource())
+			dst.SetSchemaUrl(rm.SchemaUrl())
+			bm.resources.addResource(h)
+		}
+		bm.addScopes(j, rm.ScopeMetrics())
+	}
+	src.RemoveIf(func(pmetric.ResourceMetrics) bool { return true })
+}
+
+// addScopes moves the items of every scope entry of src to the scope
+// entry of the resource entry at position i with the same scope, adding
+// an entry for the others.
+func (bm *batchMetrics) addScopes(i int, src pmetric.ScopeMetricsSlice) {
+	sms := bm.metricData.ResourceMetrics().At(i).ScopeMetrics()
+	at := func(k int) (pcommon.InstrumentationScope, string) {
+		sm := sms.At(k)
+		return sm.Scope(), sm.SchemaUrl()
+	}
+	si := bm.resources.scopeIndex(i, sms.Len(), at)
+	for k := 0; k < src.Len(); k++ {
+		sm := src.At(k)
+		h := hashScope(sm.Scope(), sm.SchemaUrl())
+		l, ok := findScope(si, h, sm.Scope(), sm.SchemaUrl(), at)
+		if !ok && bm.mergeDataPoints {
+			// Added empty, for the metrics of the request to be
+			// merged as well.
+			l, ok = sms.Len(), true
+			dst := sms.AppendEmpty()
+			sm.Scope().MoveTo(dst.Scope())
+			dst.SetSchemaUrl(sm.SchemaUrl())
+			si.put(h, l)
+		}
+		switch {
+		case !ok:
+			sm.MoveTo(sms.AppendEmpty())
+			si.put(h, sms.Len()-1)
+		case bm.mergeDataPoints:
+			bm.addMetrics(i, l, sm.Metrics())
+		default:
+			sm.Metrics().MoveAndAppendTo(sms.At(l).Metrics())
+		}
+	}
+}
+
+// addMetrics moves the data points of every metric of src to the
+// identical metric of the scope entry at position k of the resource
+// entry at position i, adding the others.
+func (bm *batchMetrics) addMetrics(i, k int, src pmetric.MetricSlice) {
+	ms := bm.metricData.ResourceMetrics().At(i).ScopeMetrics().At(k).Metrics()
+	mi := bm.resources.metricIndex(i, k, ms)
+	conflicts := 0
+	for l := 0; l < src.Len(); l++ {
+		m := src.At(l)
+		name := m.Name()
+		target := -1
+		for _, p := range mi[name] {
+			if metricsIdentical(m, ms.At(p)) {
+				target = p
+				break
+			}
+		}
+		if target >= 0 {
+			moveDataPoints(m, ms.At(target))
+			continue
+		}
+		if len(mi[name]) > 0 {
+			conflicts++
+		}
+		m.MoveTo(ms.AppendEmpty())
+		mi[name] = append(mi[name], ms.Len()-1)
+	}
+	if conflicts > 0 && bm.onConflicts != nil {
+		bm.onConflicts(conflicts)
+	}
+}
+
+func (bm *batchMetrics) Requeue(data any) {
+	bm.stopChunks()
+	// The returned data may be that of a read-only request.
+	md := data.(pmetric.Metrics).AsMutable()
+	bm.dataPointCount += md.DataPointCount()
+	if bm.trackBytes {
+		bm.bytes += bm.sizer.MetricsSize(md)
+	}
+	bm.metricData.ResourceMetrics().MoveAndAppendTo(md.ResourceMetrics())
+	bm.metricData = md
+	bm.resources = nil
 }
 
 type batchLogs struct {
@@ -487,44 +847,258 @@ type batchLogs struct {
 	logData      plog.Logs
 	logCount     int
 	sizer        plog.Sizer
+
+	// resourceHint is the number of resources of the last batch
+	// sent whole, used to size the next one.
+	resourceHint int
+
+	// trackBytes is set when the size in bytes of every export is
+	// needed, so that bytes, the size of the batch, is kept up to
+	// date instead of measuring the batch whole.
+	trackBytes bool
+	bytes      int
+
+	// splitAtResource is set when SplitAt is "resource".
+	splitAtResource bool
+
+	// splitAtScope is set when SplitAt is "scope".
+	splitAtScope bool
+
+	// compact is set by Compact.  resources indexes the resource and
+	// scope entries of the batch, until their positions change.
+	compact   bool
+	resources *compactIndex
+
+	// chunks returns the requests split off the batch, until data is
+	// added to the batch or it is exported whole, for the batch to be
+	// walked once however many requests it is split into.  chunkSize is
+	// the number of log records of these requests.  Until then, the
+	// batch still holds the emptied entries of the requests split off.
+	chunks    *plog.ChunkIterator
+	chunkSize int
+
+	// copyItems is set when the processor does not mutate its input,
+	// so that the data added is copied instead of moved.
+	copyItems bool
+
+	// reuse is set when the next consumer does not retain the data,
+	// so that spare, the container of the last batch exported whole,
+	// is reused for the next batch.
+	reuse    bool
+	spare    plog.Logs
+	hasSpare bool
+
+	// readOnly is set when the next consumer accepts read-only data,
+	// so that the requests exported are marked read-only.
+	readOnly bool
 }
 
 func newBatchLogs(nextConsumer consumer.Logs) *batchLogs {
 	return &batchLogs{nextConsumer: nextConsumer, logData: plog.NewLogs(), sizer: &plog.ProtoMarshaler{}}
 }
 
-func (bl *batchLogs) export(ctx context.Context, sendBatchMaxSize int, returnBytes bool) (int, int, error) {
+func (bl *batchLogs) Export(ctx context.Context, sendBatchMaxSize int) (any, int, int, error) {
 	var req plog.Logs
 	var sent int
 	var bytes int
 
 	if sendBatchMaxSize > 0 && bl.logCount > sendBatchMaxSize {
-		req = splitLogs(sendBatchMaxSize, bl.logData)
-		bl.logCount -= sendBatchMaxSize
-		sent = sendBatchMaxSize
+		req, sent = bl.split(sendBatchMaxSize)
+		// The containers of the cut items are left on both sides.  The
+		// chunk iterator holds on to the remainder, pruned once it is
+		// stopped.
+		req.RemoveEmpty()
+		if bl.chunks == nil {
+			bl.logData.RemoveEmpty()
+		}
+		bl.logCount -= sent
+		bl.resources = nil
+		if bl.trackBytes {
+			bytes = bl.sizer.LogsSize(req)
+			// The remainder also holds the resource and scope
+			// of the cut item, which this does not account for.
+			bl.bytes -= bytes
+			if bl.bytes < 0 {
+				bl.bytes = 0
+			}
+		}
 	} else {
+		bl.stopChunks()
 		req = bl.logData
 		sent = bl.logCount
-		bl.logData = plog.NewLogs()
+		bytes = bl.bytes
+		bl.resourceHint = req.ResourceLogs().Len()
+		bl.logData = bl.nextLogs()
 		bl.logCount = 0
+		bl.bytes = 0
+		bl.resources = nil
 	}
-	if returnBytes {
+	if bl.readOnly {
+		req.MarkReadOnly()
+	}
+	err := bl.nextConsumer.ConsumeLogs(ctx, req)
+	if err != nil && !bl.trackBytes {
+		// Sized for the failure to be reported.
 		bytes = bl.sizer.LogsSize(req)
 	}
-	return sent, bytes, bl.nextConsumer.ConsumeLogs(ctx, req)
+	// Only a batch exported whole is reused, the parts of a split
+	// batch are new containers.
+	if err == nil && bl.reuse && bl.ItemCount() == 0 {
+		req.Reset()
+		bl.spare, bl.hasSpare = req, true
+	}
+	return req, sent, bytes, err
+}
+
+// split removes a request of about size log records from the batch,
+// cut as set by splitAtResource and splitAtScope, returning it with its
+// number of log records.
+func (bl *batchLogs) split(size int) (plog.Logs, int) {
+	switch {
+	case bl.splitAtResource:
+		return splitLogsAtResource(size, bl.logData)
+	case bl.splitAtScope:
+		return splitLogsAtScope(size, bl.logData)
+	}
+	return bl.nextChunk(size), size
+}
+
+// nextChunk moves the next request of size log records out of the batch.
+func (bl *batchLogs) nextChunk(size int) plog.Logs {
+	if bl.chunks == nil || bl.chunkSize != size {
+		bl.stopChunks()
+		bl.chunks, bl.chunkSize = plog.Chunks(bl.logData, plog.WithMaxItems(size)), size
+	}
+	bl.chunks.Next()
+	return bl.chunks.Chunk()
+}
+
+// stopChunks removes the requests already split off from the batch, and
+// the containers they emptied, for it to be changed or exported whole.
+func (bl *batchLogs) stopChunks() {
+	if bl.chunks != nil {
+		bl.chunks.Stop()
+		bl.chunks = nil
+		bl.logData.RemoveEmpty()
+	}
 }
 
-func (bl *batchLogs) itemCount() int {
+// nextLogs returns the container of the next batch, the spare one when
+// available.
+func (bl *batchLogs) nextLogs() plog.Logs {
+	if bl.hasSpare {
+		bl.hasSpare = false
+		return bl.spare
+	}
+	return plog.NewLogs()
+}
+
+func (bl *batchLogs) Consume(ctx context.Context, req any) error {
+	return bl.nextConsumer.ConsumeLogs(ctx, req.(plog.Logs))
+}
+
+func (bl *batchLogs) ItemCount() int {
 	return bl.logCount
 }
 
-func (bl *batchLogs) add(item any) {
-	ld := item.(plog.Logs)
+func (bl *batchLogs) ByteSize() int {
+	return bl.bytes
+}
 
-	newLogsCount := ld.LogRecordCount()
-	if newLogsCount == 0 {
+func (bl *batchLogs) Add(item any, n int) {
+	bl.stopChunks()
+	if n == 0 {
+		return
+	}
+	ld := item.(plog.Logs)
+	bl.logCount += n
+	if bl.trackBytes {
+		bl.bytes += bl.sizer.LogsSize(ld)
+	}
+	rls := bl.logData.ResourceLogs()
+	if rls.Len() == 0 && ld.ResourceLogs().Len() < bl.resourceHint {
+		rls.EnsureCapacity(bl.resourceHint)
+	}
+	if bl.compact {
+		if bl.copyItems {
+			// Copied whole, for its parts to be moved to the
+			// entries of the batch.
+			clone := plog.NewLogs()
+			ld.CopyTo(clone)
+			ld = clone
+		}
+		bl.addCompact(ld)
+		return
+	}
+	if bl.copyItems {
+		src := ld.ResourceLogs()
+		for i := 0; i < src.Len(); i++ {
+			src.At(i).CopyTo(rls.AppendEmpty())
+		}
 		return
 	}
-	bl.logCount += newLogsCount
-	ld.ResourceLogs().MoveAndAppendTo(bl.logData.ResourceLogs())
+	ld.ResourceLogs().MoveAndAppendTo(rls)
+}
+
+// addCompact moves the scopes of every resource entry of ld to the entry
+// of the batch with the same resource, adding an entry for the others.
+func (bl *batchLogs) addCompact(ld plog.Logs) {
+	rls := bl.logData.ResourceLogs()
+	at := func(i int) (pcommon.Resource, string) {
+		rl := rls.At(i)
+		return rl.Resource(), rl.SchemaUrl()
+	}
+	if bl.resources == nil {
+		bl.resources = newCompactIndex(rls.Len(), at)
+	}
+	src := ld.ResourceLogs()
+	for i := 0; i < src.Len(); i++ {
+		rl := src.At(i)
+		h := hashResource(rl.Resource(), rl.SchemaUrl())
+		j, ok := bl.resources.findResource(h, rl.Resource(), rl.SchemaUrl(), at)
+		if !ok {
+			j = rls.Len()
+			dst := rls.AppendEmpty()
+			rl.Resource().MoveTo(dst.Resource())
+			dst.SetSchemaUrl(rl.SchemaUrl())
+			bl.resources.addResource(h)
+		}
+		bl.addScopes(j, rl.ScopeLogs())
+	}
+	src.RemoveIf(func(plog.ResourceLogs) bool { return true })
+}
+
+// addScopes moves the items of every scope entry of src to the scope
+// entry of the resource entry at position i with the same scope, adding
+// an entry for the others.
+func (bl *batchLogs) addScopes(i int, src plog.ScopeLogsSlice) {
+	sls := bl.logData.ResourceLogs().At(i).ScopeLogs()
+	at := func(k int) (pcommon.InstrumentationScope, string) {
+		sl := sls.At(k)
+		return sl.Scope(), sl.SchemaUrl()
+	}
+	si := bl.resources.scopeIndex(i, sls.Len(), at)
+	for k := 0; k < src.Len(); k++ {
+		sl := src.At(k)
+		h := hashScope(sl.Scope(), sl.SchemaUrl())
+		if l, ok := findScope(si, h, sl.Scope(), sl.SchemaUrl(), at); ok {
+			sl.LogRecords().MoveAndAppendTo(sls.At(l).LogRecords())
+			continue
+		}
+		sl.MoveTo(sls.AppendEmpty())
+		si.put(h, sls.Len()-1)
+	}
+}
+
+func (bl *batchLogs) Requeue(data any) {
+	bl.stopChunks()
+	// The returned data may be that of a read-only request.
+	ld := data.(plog.Logs).AsMutable()
+	bl.logCount += ld.LogRecordCount()
+	if bl.trackBytes {
+		bl.bytes += bl.sizer.LogsSize(ld)
+	}
+	bl.logData.ResourceLogs().MoveAndAppendTo(ld.ResourceLogs())
+	bl.logData = ld
+	bl.resources = nil
 }
@@ -16,16 +16,25 @@ package batchprocessor
 
 import (
 	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 
 	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/config/configtelemetry"
 	"go.opentelemetry.io/collector/consumer"
@@ -33,8 +42,10 @@ import (
 	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/internal/testdata"
 	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogtest"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/processor/batchprocessor/batching"
 	"go.opentelemetry.io/collector/processor/processortest"
 )
 
@@ -44,7 +55,7 @@ func TestBatchProcessorSpansDelivered(t *testing.T) {
 	cfg.SendBatchSize = 128
 	creationSet := processortest.NewNopCreateSettings()
 	creationSet.MetricsLevel = configtelemetry.LevelDetailed
-	batcher, err := newBatchTracesProcessor(creationSet, sink, cfg, false)
+	batcher, err := newBatchTracesProcessor(creationSet, sink, cfg)
 	require.NoError(t, err)
 	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
@@ -87,7 +98,7 @@ func TestBatchProcessorSpansDeliveredEnforceBatchSize(t *testing.T) {
 	cfg.SendBatchMaxSize = 130
 	creationSet := processortest.NewNopCreateSettings()
 	creationSet.MetricsLevel = configtelemetry.LevelDetailed
-	batcher, err := newBatchTracesProcessor(creationSet, sink, cfg, false)
+	batcher, err := newBatchTracesProcessor(creationSet, sink, cfg)
 	require.NoError(t, err)
 	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
@@ -125,10 +136,7 @@ func TestBatchProcessorSpansDeliveredEnforceBatchSize(t *testing.T) {
 }
 
 func TestBatchProcessorSentBySize(t *testing.T) {
-	telemetryTest(t, testBatchProcessorSentBySize)
-}
-
-func testBatchProcessorSentBySize(t *testing.T, tel testTelemetry, useOtel bool) {
+	tel := setupTelemetry(t)
 	sizer := &ptrace.ProtoMarshaler{}
 	sink := new(consumertest.TracesSink)
 	cfg := createDefaultConfig().(*Config)
@@ -137,7 +145,7 @@ func testBatchProcessorSentBySize(t *testing.T, tel testTelemetry, useOtel bool)
 	cfg.Timeout = 500 * time.Millisecond
 	creationSet := tel.NewProcessorCreateSettings()
 	creationSet.MetricsLevel = configtelemetry.LevelDetailed
-	batcher, err := newBatchTracesProcessor(creationSet, sink, cfg, useOtel)
+	batcher, err := newBatchTracesProcessor(creationSet, sink, cfg)
 	require.NoError(t, err)
 	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
@@ -179,11 +187,69 @@ func testBatchProcessorSentBySize(t *testing.T, tel testTelemetry, useOtel bool)
 	})
 }
 
-func TestBatchProcessorSentBySizeWithMaxSize(t *testing.T) {
-	telemetryTest(t, testBatchProcessorSentBySizeWithMaxSize)
+func TestBatchProcessorSendSizeBytesEnabled(t *testing.T) {
+	tel := setupTelemetry(t)
+	sizer := &ptrace.ProtoMarshaler{}
+	sink := new(consumertest.TracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 10
+	enabled := true
+	cfg.Metrics.SendSizeBytes = &enabled
+	set := tel.NewProcessorCreateSettings()
+	set.MetricsLevel = configtelemetry.LevelNormal
+	batcher, err := newBatchTracesProcessor(set, sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	sizeSum := 0
+	for i := 0; i < 4; i++ {
+		td := testdata.GenerateTraces(5)
+		sizeSum += sizer.TracesSize(td)
+		require.NoError(t, batcher.ConsumeTraces(context.Background(), td))
+	}
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	tel.assertMetrics(t, expectedMetrics{
+		sendCount:        2,
+		sendSizeSum:      20,
+		sendSizeBytesSum: float64(sizeSum),
+		sizeTrigger:      2,
+	})
+}
+
+func TestBatchProcessorSendSizeBytesDisabled(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := new(consumertest.TracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 10
+	cfg.Timeout = time.Hour
+	disabled := false
+	cfg.Metrics.SendSizeBytes = &disabled
+	set := tel.NewProcessorCreateSettings()
+	set.MetricsLevel = configtelemetry.LevelDetailed
+	batcher, err := newBatchTracesProcessor(set, sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(5)))
+	}
+	// The pending batch is not measured.
+	require.Eventually(t, func() bool {
+		return sink.SpanCount() == 10 && batcher.Snapshot().Batchers[0].Items == 5
+	}, time.Second, 5*time.Millisecond)
+	assert.Zero(t, batcher.Snapshot().Batchers[0].Bytes)
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	tel.assertMetrics(t, expectedMetrics{
+		sendCount:   2,
+		sendSizeSum: 15,
+	})
+	assert.NotContains(t, tel.gather(t), "batch_send_size_bytes")
 }
 
-func testBatchProcessorSentBySizeWithMaxSize(t *testing.T, tel testTelemetry, useOtel bool) {
+func TestBatchProcessorSentBySizeWithMaxSize(t *testing.T) {
+	tel := setupTelemetry(t)
 	sink := new(consumertest.TracesSink)
 	cfg := createDefaultConfig().(*Config)
 	sendBatchSize := 20
@@ -193,7 +259,7 @@ func testBatchProcessorSentBySizeWithMaxSize(t *testing.T, tel testTelemetry, us
 	cfg.Timeout = 500 * time.Millisecond
 	creationSet := tel.NewProcessorCreateSettings()
 	creationSet.MetricsLevel = configtelemetry.LevelDetailed
-	batcher, err := newBatchTracesProcessor(creationSet, sink, cfg, useOtel)
+	batcher, err := newBatchTracesProcessor(creationSet, sink, cfg)
 	require.NoError(t, err)
 	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
@@ -220,10 +286,10 @@ func testBatchProcessorSentBySizeWithMaxSize(t *testing.T, tel testTelemetry, us
 	require.EqualValues(t, expectedBatchesNum, len(receivedTraces))
 
 	tel.assertMetrics(t, expectedMetrics{
-		sendCount:      float64(expectedBatchesNum),
-		sendSizeSum:    float64(sink.SpanCount()),
-		sizeTrigger:    math.Floor(float64(totalSpans) / float64(sendBatchMaxSize)),
-		timeoutTrigger: 1,
+		sendCount:       float64(expectedBatchesNum),
+		sendSizeSum:     float64(sink.SpanCount()),
+		sizeTrigger:     math.Floor(float64(totalSpans) / float64(sendBatchMaxSize)),
+		shutdownTrigger: 1,
 	})
 }
 
@@ -240,7 +306,7 @@ func TestBatchProcessorSentByTimeout(t *testing.T) {
 
 	creationSet := processortest.NewNopCreateSettings()
 	creationSet.MetricsLevel = configtelemetry.LevelDetailed
-	batcher, err := newBatchTracesProcessor(creationSet, sink, cfg, false)
+	batcher, err := newBatchTracesProcessor(creationSet, sink, cfg)
 	require.NoError(t, err)
 	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
@@ -287,7 +353,7 @@ func TestBatchProcessorTraceSendWhenClosing(t *testing.T) {
 
 	creationSet := processortest.NewNopCreateSettings()
 	creationSet.MetricsLevel = configtelemetry.LevelDetailed
-	batcher, err := newBatchTracesProcessor(creationSet, sink, &cfg, false)
+	batcher, err := newBatchTracesProcessor(creationSet, sink, &cfg)
 	require.NoError(t, err)
 	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
@@ -304,6 +370,32 @@ func TestBatchProcessorTraceSendWhenClosing(t *testing.T) {
 	require.Equal(t, 1, len(sink.AllTraces()))
 }
 
+func TestBatchProcessorNoFlushOnShutdown(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := new(consumertest.TracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	flushOnShutdown := false
+	cfg.FlushOnShutdown = &flushOnShutdown
+	batcher, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	requestCount := 10
+	spansPerRequest := 10
+	for requestNum := 0; requestNum < requestCount; requestNum++ {
+		assert.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(spansPerRequest)))
+	}
+
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	require.Equal(t, 0, sink.SpanCount())
+
+	tel.assertMetrics(t, expectedMetrics{
+		droppedItems: map[string]float64{"shutdown_abandoned": float64(requestCount * spansPerRequest)},
+	})
+}
+
 func TestBatchMetricProcessor_ReceivingData(t *testing.T) {
 	// Instantiate the batch processor with low config values to test data
 	// gets sent through the processor.
@@ -318,7 +410,7 @@ func TestBatchMetricProcessor_ReceivingData(t *testing.T) {
 
 	creationSet := processortest.NewNopCreateSettings()
 	creationSet.MetricsLevel = configtelemetry.LevelDetailed
-	batcher, err := newBatchMetricsProcessor(creationSet, sink, &cfg, false)
+	batcher, err := newBatchMetricsProcessor(creationSet, sink, &cfg)
 	require.NoError(t, err)
 	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
@@ -354,10 +446,7 @@ func TestBatchMetricProcessor_ReceivingData(t *testing.T) {
 }
 
 func TestBatchMetricProcessorBatchSize(t *testing.T) {
-	telemetryTest(t, testBatchMetricProcessorBatchSize)
-}
-
-func testBatchMetricProcessorBatchSize(t *testing.T, tel testTelemetry, useOtel bool) {
+	tel := setupTelemetry(t)
 	sizer := &pmetric.ProtoMarshaler{}
 
 	// Instantiate the batch processor with low config values to test data
@@ -375,7 +464,7 @@ func testBatchMetricProcessorBatchSize(t *testing.T, tel testTelemetry, useOtel
 
 	creationSet := tel.NewProcessorCreateSettings()
 	creationSet.MetricsLevel = configtelemetry.LevelDetailed
-	batcher, err := newBatchMetricsProcessor(creationSet, sink, &cfg, useOtel)
+	batcher, err := newBatchMetricsProcessor(creationSet, sink, &cfg)
 	require.NoError(t, err)
 	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
@@ -422,9 +511,9 @@ func TestBatchMetrics_UnevenBatchMaxSize(t *testing.T) {
 	batchMetrics := newBatchMetrics(sink)
 	md := testdata.GenerateMetrics(metricsCount)
 
-	batchMetrics.add(md)
+	batchMetrics.Add(md, md.DataPointCount())
 	require.Equal(t, dataPointsPerMetric*metricsCount, batchMetrics.dataPointCount)
-	sent, _, sendErr := batchMetrics.export(ctx, sendBatchMaxSize, false)
+	_, sent, _, sendErr := batchMetrics.Export(ctx, sendBatchMaxSize)
 	require.NoError(t, sendErr)
 	require.Equal(t, sendBatchMaxSize, sent)
 	remainingDataPointCount := metricsCount*dataPointsPerMetric - sendBatchMaxSize
@@ -442,7 +531,7 @@ func TestBatchMetricsProcessor_Timeout(t *testing.T) {
 
 	creationSet := processortest.NewNopCreateSettings()
 	creationSet.MetricsLevel = configtelemetry.LevelDetailed
-	batcher, err := newBatchMetricsProcessor(creationSet, sink, &cfg, false)
+	batcher, err := newBatchMetricsProcessor(creationSet, sink, &cfg)
 	require.NoError(t, err)
 	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
@@ -491,7 +580,7 @@ func TestBatchMetricProcessor_Shutdown(t *testing.T) {
 
 	creationSet := processortest.NewNopCreateSettings()
 	creationSet.MetricsLevel = configtelemetry.LevelDetailed
-	batcher, err := newBatchMetricsProcessor(creationSet, sink, &cfg, false)
+	batcher, err := newBatchMetricsProcessor(creationSet, sink, &cfg)
 	require.NoError(t, err)
 	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
@@ -576,7 +665,7 @@ func BenchmarkBatchMetricProcessor(b *testing.B) {
 	creationSet := processortest.NewNopCreateSettings()
 	creationSet.MetricsLevel = configtelemetry.LevelDetailed
 	metricsPerRequest := 1000
-	batcher, err := newBatchMetricsProcessor(creationSet, sink, &cfg, false)
+	batcher, err := newBatchMetricsProcessor(creationSet, sink, &cfg)
 	require.NoError(b, err)
 	require.NoError(b, batcher.Start(ctx, componenttest.NewNopHost()))
 
@@ -627,7 +716,7 @@ func TestBatchLogProcessor_ReceivingData(t *testing.T) {
 
 	creationSet := processortest.NewNopCreateSettings()
 	creationSet.MetricsLevel = configtelemetry.LevelDetailed
-	batcher, err := newBatchLogsProcessor(creationSet, sink, &cfg, false)
+	batcher, err := newBatchLogsProcessor(creationSet, sink, &cfg)
 	require.NoError(t, err)
 	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
@@ -663,10 +752,7 @@ func TestBatchLogProcessor_ReceivingData(t *testing.T) {
 }
 
 func TestBatchLogProcessor_BatchSize(t *testing.T) {
-	telemetryTest(t, testBatchLogProcessorBatchSize)
-}
-
-func testBatchLogProcessorBatchSize(t *testing.T, tel testTelemetry, useOtel bool) {
+	tel := setupTelemetry(t)
 	sizer := &plog.ProtoMarshaler{}
 
 	// Instantiate the batch processor with low config values to test data
@@ -682,7 +768,7 @@ func testBatchLogProcessorBatchSize(t *testing.T, tel testTelemetry, useOtel boo
 
 	creationSet := tel.NewProcessorCreateSettings()
 	creationSet.MetricsLevel = configtelemetry.LevelDetailed
-	batcher, err := newBatchLogsProcessor(creationSet, sink, &cfg, useOtel)
+	batcher, err := newBatchLogsProcessor(creationSet, sink, &cfg)
 	require.NoError(t, err)
 	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
@@ -730,7 +816,7 @@ func TestBatchLogsProcessor_Timeout(t *testing.T) {
 
 	creationSet := processortest.NewNopCreateSettings()
 	creationSet.MetricsLevel = configtelemetry.LevelDetailed
-	batcher, err := newBatchLogsProcessor(creationSet, sink, &cfg, false)
+	batcher, err := newBatchLogsProcessor(creationSet, sink, &cfg)
 	require.NoError(t, err)
 	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
@@ -779,7 +865,7 @@ func TestBatchLogProcessor_Shutdown(t *testing.T) {
 
 	creationSet := processortest.NewNopCreateSettings()
 	creationSet.MetricsLevel = configtelemetry.LevelDetailed
-	batcher, err := newBatchLogsProcessor(creationSet, sink, &cfg, false)
+	batcher, err := newBatchLogsProcessor(creationSet, sink, &cfg)
 	require.NoError(t, err)
 	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
@@ -858,7 +944,7 @@ func TestBatchProcessorSpansBatchedByMetadata(t *testing.T) {
 	cfg.MetadataKeys = []string{"token1", "token2"}
 	creationSet := processortest.NewNopCreateSettings()
 	creationSet.MetricsLevel = configtelemetry.LevelDetailed
-	batcher, err := newBatchTracesProcessor(creationSet, sink, cfg, false)
+	batcher, err := newBatchTracesProcessor(creationSet, sink, cfg)
 	require.NoError(t, err)
 	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
@@ -934,6 +1020,365 @@ func TestBatchProcessorSpansBatchedByMetadata(t *testing.T) {
 	}
 }
 
+type resourceLogsSink struct {
+	*consumertest.LogsSink
+
+	lock         sync.Mutex
+	countByToken map[string]int
+}
+
+func (rls *resourceLogsSink) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	rls.lock.Lock()
+	defer rls.lock.Unlock()
+
+	// Each exported request must contain a single combination of
+	// metadata and resource attribute values.
+	token := client.FromContext(ctx).Metadata.Get("token")
+	tenants := map[string]bool{}
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		v, _ := ld.ResourceLogs().At(i).Resource().Attributes().Get("tenant")
+		tenants[v.Str()] = true
+	}
+	if len(tenants) != 1 {
+		return fmt.Errorf("expected one tenant per request, got %d", len(tenants))
+	}
+	for tenant := range tenants {
+		rls.countByToken[formatTwo(token, []string{tenant})] += ld.LogRecordCount()
+	}
+	return rls.LogsSink.ConsumeLogs(ctx, ld)
+}
+
+func TestBatchProcessorLogsBatchedByResourceAttributes(t *testing.T) {
+	sink := &resourceLogsSink{
+		LogsSink:     &consumertest.LogsSink{},
+		countByToken: map[string]int{},
+	}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"token"}
+	cfg.ResourceAttributeKeys = []string{"tenant"}
+	batcher, err := newBatchLogsProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	tenants := []string{"a", "b", "c"}
+	tokens := []string{"x", "y"}
+	requestCount := 100
+	for requestNum := 0; requestNum < requestCount; requestNum++ {
+		// Each request holds one resource per tenant.
+		ld := plog.NewLogs()
+		for _, tenant := range tenants {
+			rl := testdata.GenerateLogs(requestNum%3 + 1).ResourceLogs().At(0)
+			rl.Resource().Attributes().PutStr("tenant", tenant)
+			rl.MoveTo(ld.ResourceLogs().AppendEmpty())
+		}
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{
+				"token": {tokens[requestNum%len(tokens)]},
+			}),
+		})
+		require.NoError(t, batcher.ConsumeLogs(ctx, ld))
+	}
+	assert.Equal(t, len(tenants)*len(tokens), batcher.MetadataCardinality())
+
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	expect := map[string]int{}
+	for requestNum := 0; requestNum < requestCount; requestNum++ {
+		for _, tenant := range tenants {
+			expect[formatTwo([]string{tokens[requestNum%len(tokens)]}, []string{tenant})] += requestNum%3 + 1
+		}
+	}
+	assert.Equal(t, expect, sink.countByToken)
+}
+
+func TestBatchProcessorLogsAllDelivered(t *testing.T) {
+	sink := new(consumertest.LogsSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 50
+	cfg.SendBatchMaxSize = 70
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"token"}
+	cfg.ResourceAttributeKeys = []string{"tenant"}
+	batcher, err := newBatchLogsProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	tenants := []string{"a", "b", "c"}
+	tokens := []string{"x", "y"}
+	sent := plog.NewLogs()
+	for requestNum := 0; requestNum < 100; requestNum++ {
+		ld := plog.NewLogs()
+		for _, tenant := range tenants {
+			rl := testdata.GenerateLogs(requestNum%5 + 1).ResourceLogs().At(0)
+			rl.Resource().Attributes().PutStr("tenant", tenant)
+			lrs := rl.ScopeLogs().At(0).LogRecords()
+			for index := 0; index < lrs.Len(); index++ {
+				lrs.At(index).Body().SetStr(tenant + getTestLogSeverityText(requestNum, index))
+			}
+			rl.MoveTo(ld.ResourceLogs().AppendEmpty())
+		}
+		sentRequest := plog.NewLogs()
+		ld.CopyTo(sentRequest)
+		plog.MergeInto(sent, sentRequest)
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{
+				"token": {tokens[requestNum%len(tokens)]},
+			}),
+		})
+		require.NoError(t, batcher.ConsumeLogs(ctx, ld))
+	}
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	// Everything sent is received, batched by token and tenant, in any
+	// order across the batchers.
+	received := plog.NewLogs()
+	for _, ld := range sink.AllLogs() {
+		plog.MergeInto(received, ld)
+	}
+	assert.NoError(t, plogtest.CompareLogs(sent, received, plogtest.IgnoreResourceOrder(), plogtest.IgnoreLogRecordOrder()))
+}
+
+func TestBatchProcessorLogsGroupBy(t *testing.T) {
+	sink := &resourceLogsSink{
+		LogsSink:     &consumertest.LogsSink{},
+		countByToken: map[string]int{},
+	}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.GroupBy = []GroupBySource{{Metadata: "token"}, {Resource: "tenant"}}
+	batcher, err := newBatchLogsProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	for _, token := range []string{"x", "y", "x"} {
+		// Each request is split across the batchers of its tenants.
+		ld := plog.NewLogs()
+		for _, tenant := range []string{"a", "b"} {
+			rl := testdata.GenerateLogs(2).ResourceLogs().At(0)
+			rl.Resource().Attributes().PutStr("tenant", tenant)
+			rl.MoveTo(ld.ResourceLogs().AppendEmpty())
+		}
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"token": {token}}),
+		})
+		require.NoError(t, batcher.ConsumeLogs(ctx, ld))
+	}
+	assert.Equal(t, 4, batcher.MetadataCardinality())
+
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, map[string]int{
+		formatTwo([]string{"x"}, []string{"a"}): 4,
+		formatTwo([]string{"x"}, []string{"b"}): 4,
+		formatTwo([]string{"y"}, []string{"a"}): 2,
+		formatTwo([]string{"y"}, []string{"b"}): 2,
+	}, sink.countByToken)
+}
+
+func TestBatchProcessorResourceAttributesCardinalityLimit(t *testing.T) {
+	sink := new(consumertest.TracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.ResourceAttributeKeys = []string{"tenant"}
+	cfg.MetadataCardinalityLimit = 1
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	td := testdata.GenerateTraces(1)
+	td.ResourceSpans().At(0).CopyTo(td.ResourceSpans().AppendEmpty())
+	td.ResourceSpans().At(1).Resource().Attributes().PutStr("tenant", "other")
+
+	// Neither resource is accepted when one exceeds the limit.
+	err = batcher.ConsumeTraces(context.Background(), td)
+	assert.True(t, consumererror.IsPermanent(err))
+
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, 0, sink.SpanCount())
+}
+
+type metadataKeysTracesSink struct {
+	*consumertest.TracesSink
+
+	lock             sync.Mutex
+	spanCountByScope map[string]int
+}
+
+func (mks *metadataKeysTracesSink) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	md := client.FromContext(ctx).Metadata
+	keys := md.Keys()
+	sort.Strings(keys)
+	var scope []string
+	for _, k := range keys {
+		scope = append(scope, fmt.Sprintf("%s=%s", k, md.Get(k)))
+	}
+	mks.lock.Lock()
+	defer mks.lock.Unlock()
+	mks.spanCountByScope[strings.Join(scope, ",")] += td.SpanCount()
+	return mks.TracesSink.ConsumeTraces(ctx, td)
+}
+
+func TestBatchProcessorSpansBatchedByMetadataPattern(t *testing.T) {
+	sink := &metadataKeysTracesSink{
+		TracesSink:       &consumertest.TracesSink{},
+		spanCountByScope: map[string]int{},
+	}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"x-scope-*", "tenant"}
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	bg := context.Background()
+	callCtxs := []context.Context{
+		client.NewContext(bg, client.Info{
+			Metadata: client.NewMetadata(map[string][]string{
+				"tenant":    {"a"},
+				"x-scope-a": {"1"},
+				"other":     {"n/a"},
+			}),
+		}),
+		client.NewContext(bg, client.Info{
+			Metadata: client.NewMetadata(map[string][]string{
+				"tenant":    {"a"},
+				"X-Scope-B": {"2"},
+			}),
+		}),
+		client.NewContext(bg, client.Info{
+			Metadata: client.NewMetadata(map[string][]string{
+				"tenant":    {"a"},
+				"x-scope-a": {"1"},
+				"x-scope-b": {"2"},
+			}),
+		}),
+		client.NewContext(bg, client.Info{
+			Metadata: client.NewMetadata(map[string][]string{
+				"other": {"n/a"},
+			}),
+		}),
+	}
+
+	for _, ctx := range callCtxs {
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(2)))
+	}
+	assert.Equal(t, len(callCtxs), batcher.MetadataCardinality())
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	// Non-matching keys are not propagated.
+	assert.Equal(t, map[string]int{
+		"tenant=[a],x-scope-a=[1]":               2,
+		"tenant=[a],x-scope-b=[2]":               2,
+		"tenant=[a],x-scope-a=[1],x-scope-b=[2]": 2,
+		"tenant=[]":                              2,
+	}, sink.spanCountByScope)
+}
+
+func TestBatchProcessorPreserveMetadataCase(t *testing.T) {
+	for _, preserve := range []bool{false, true} {
+		t.Run(fmt.Sprint(preserve), func(t *testing.T) {
+			sink := &metadataKeysTracesSink{
+				TracesSink:       &consumertest.TracesSink{},
+				spanCountByScope: map[string]int{},
+			}
+			cfg := createDefaultConfig().(*Config)
+			cfg.SendBatchSize = 1000
+			cfg.Timeout = 10 * time.Minute
+			cfg.MetadataKeys = []string{"X-Tenant-ID", "x-scope-*"}
+			cfg.PreserveMetadataCase = preserve
+			batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+			require.NoError(t, err)
+			require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+			for _, md := range []map[string][]string{
+				{"x-tenant-id": {"a"}, "X-Scope-A": {"1"}},
+				{"X-TENANT-ID": {"a"}, "x-scope-a": {"1"}},
+			} {
+				ctx := client.NewContext(context.Background(), client.Info{
+					Metadata: client.NewMetadata(md),
+				})
+				require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(2)))
+			}
+			// Batching is not affected by the casing.
+			assert.Equal(t, 1, batcher.MetadataCardinality())
+			require.NoError(t, batcher.Shutdown(context.Background()))
+
+			// client.Metadata lists the keys in lower case either way.
+			assert.Equal(t, map[string]int{"x-scope-a=[1],x-tenant-id=[a]": 4}, sink.spanCountByScope)
+		})
+	}
+}
+
+func TestBatchProcessorMixedCaseMetadata(t *testing.T) {
+	sink := &metadataKeysTracesSink{
+		TracesSink:       &consumertest.TracesSink{},
+		spanCountByScope: map[string]int{},
+	}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"x-tenant"}
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	// Receivers may use any casing, the tenants are kept apart all the
+	// same, and the values of keys differing by case are merged.
+	for _, md := range []map[string][]string{
+		{"X-Tenant": {"a"}},
+		{"x-tenant": {"b"}},
+		{"X-TENANT": {"a"}},
+		{"X-Tenant": {"a"}, "x-tenant": {"b"}},
+	} {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(md),
+		})
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(2)))
+	}
+	assert.Equal(t, 3, batcher.MetadataCardinality())
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	assert.Equal(t, map[string]int{
+		"x-tenant=[a]":   4,
+		"x-tenant=[b]":   2,
+		"x-tenant=[a b]": 2,
+	}, sink.spanCountByScope)
+}
+
+func TestBatchProcessorGroupBySource(t *testing.T) {
+	var lock sync.Mutex
+	spansBySource := map[string]int{}
+	sink, err := consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+		lock.Lock()
+		defer lock.Unlock()
+		spansBySource[client.FromContext(ctx).Source] += td.SpanCount()
+		return nil
+	})
+	require.NoError(t, err)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.GroupBy = []GroupBySource{{Source: true}}
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	for _, source := range []string{"otlp", "otlp/internal", "otlp", ""} {
+		ctx := client.NewContext(context.Background(), client.Info{Source: source})
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(2)))
+	}
+	assert.Equal(t, 3, batcher.MetadataCardinality())
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	assert.Equal(t, map[string]int{
+		"otlp":          4,
+		"otlp/internal": 2,
+		"":              2,
+	}, spansBySource)
+}
+
 func TestBatchProcessorDuplicateMetadataKeys(t *testing.T) {
 	cfg := createDefaultConfig().(*Config)
 	cfg.MetadataKeys = []string{"myTOKEN", "mytoken"}
@@ -943,6 +1388,84 @@ func TestBatchProcessorDuplicateMetadataKeys(t *testing.T) {
 	require.Contains(t, err.Error(), "mytoken")
 }
 
+func TestBatchProcessorDuplicateResourceAttributeKeys(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.ResourceAttributeKeys = []string{"tenant", "Tenant", "tenant"}
+	err := cfg.Validate()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "duplicate")
+	require.Contains(t, err.Error(), "tenant")
+}
+
+type fakeAuthData map[string]any
+
+func (a fakeAuthData) GetAttribute(name string) any {
+	return a[name]
+}
+
+func (a fakeAuthData) GetAttributeNames() []string {
+	var names []string
+	for name := range a {
+		names = append(names, name)
+	}
+	return names
+}
+
+type authTracesSink struct {
+	*consumertest.TracesSink
+
+	lock               sync.Mutex
+	spanCountBySubject map[string]int
+}
+
+func (ats *authTracesSink) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	subject := "<none>"
+	if auth := client.FromContext(ctx).Auth; auth != nil {
+		subject = fmt.Sprint(auth.GetAttribute("subject"))
+	}
+	ats.lock.Lock()
+	defer ats.lock.Unlock()
+	ats.spanCountBySubject[subject] += td.SpanCount()
+	return ats.TracesSink.ConsumeTraces(ctx, td)
+}
+
+func TestBatchProcessorSpansBatchedByAuthKeys(t *testing.T) {
+	sink := &authTracesSink{
+		TracesSink:         &consumertest.TracesSink{},
+		spanCountBySubject: map[string]int{},
+	}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.AuthKeys = []string{"subject"}
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	bg := context.Background()
+	callCtxs := []context.Context{
+		client.NewContext(bg, client.Info{Auth: fakeAuthData{"subject": "alice", "other": "x"}}),
+		client.NewContext(bg, client.Info{Auth: fakeAuthData{"subject": "bob"}}),
+		client.NewContext(bg, client.Info{Auth: fakeAuthData{"subject": "alice", "other": "y"}}),
+		client.NewContext(bg, client.Info{}),
+	}
+	for i, ctx := range callCtxs {
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(i+1)))
+	}
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	assert.Equal(t, map[string]int{"alice": 4, "bob": 2, "<none>": 4}, sink.spanCountBySubject)
+	assert.Len(t, sink.AllTraces(), 3)
+}
+
+func TestBatchProcessorDuplicateAuthKeys(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.AuthKeys = []string{"subject", "subject"}
+	err := cfg.Validate()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "duplicate")
+}
+
 func TestBatchProcessorMetadataCardinalityLimit(t *testing.T) {
 	const cardLimit = 10
 
@@ -951,7 +1474,7 @@ func TestBatchProcessorMetadataCardinalityLimit(t *testing.T) {
 	cfg.MetadataKeys = []string{"token"}
 	cfg.MetadataCardinalityLimit = cardLimit
 	creationSet := processortest.NewNopCreateSettings()
-	batcher, err := newBatchTracesProcessor(creationSet, sink, cfg, false)
+	batcher, err := newBatchTracesProcessor(creationSet, sink, cfg)
 	require.NoError(t, err)
 	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
@@ -982,76 +1505,2545 @@ func TestBatchProcessorMetadataCardinalityLimit(t *testing.T) {
 	require.NoError(t, batcher.Shutdown(context.Background()))
 }
 
-func TestBatchZeroConfig(t *testing.T) {
-	// This is a no-op configuration. No need for a timer, no
-	// minimum, no mxaimum, just a pass through.
-	cfg := Config{}
-
-	require.NoError(t, cfg.Validate())
+func TestBatchProcessorMetadataCardinalityUnlimited(t *testing.T) {
+	sink := new(consumertest.TracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = time.Hour
+	cfg.MetadataKeys = []string{"token"}
+	cfg.MetadataCardinalityLimit = 0
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
-	const requestCount = 5
-	const logsPerRequest = 10
-	sink := new(consumertest.LogsSink)
-	creationSet := processortest.NewNopCreateSettings()
-	creationSet.MetricsLevel = configtelemetry.LevelDetailed
-	batcher, err := newBatchLogsProcessor(creationSet, sink, &cfg, false)
+	// Twice the default limit of 1000.
+	const requestCount = 2000
+	for requestNum := 0; requestNum < requestCount; requestNum++ {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"token": {fmt.Sprint(requestNum)}}),
+		})
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(1)))
+	}
+	assert.Equal(t, requestCount, batcher.MetadataCardinality())
+
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, requestCount, sink.SpanCount())
+}
+
+func TestBatchProcessorMetadataCardinalityLimitOneWarning(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		keys   []string
+		warned bool
+	}{
+		{name: "one key", keys: []string{"tenant"}},
+		{name: "several keys", keys: []string{"tenant", "region"}, warned: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.WarnLevel)
+			set := processortest.NewNopCreateSettings()
+			set.Logger = zap.New(core)
+			cfg := createDefaultConfig().(*Config)
+			cfg.MetadataKeys = tt.keys
+			cfg.MetadataCardinalityLimit = 1
+			_, err := newBatchTracesProcessor(set, consumertest.NewNop(), cfg)
+			require.NoError(t, err)
+			warnings := logs.FilterMessageSnippet("allows a single combination").Len()
+			if tt.warned {
+				assert.Equal(t, 1, warnings)
+			} else {
+				assert.Zero(t, warnings)
+			}
+		})
+	}
+}
+
+func TestBatchProcessorMetadataCardinalityLimitMetricsLogs(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.MetadataKeys = []string{"token"}
+	cfg.MetadataCardinalityLimit = 1
+	set := processortest.NewNopCreateSettings()
+	ctx := func(token string) context.Context {
+		return client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"token": {token}}),
+		})
+	}
+
+	// The data beyond the limit is rejected with an error, not dropped.
+	mp, err := newBatchMetricsProcessor(set, new(consumertest.MetricsSink), cfg)
+	require.NoError(t, err)
+	require.NoError(t, mp.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, mp.ConsumeMetrics(ctx("a"), testdata.GenerateMetrics(1)))
+	err = mp.ConsumeMetrics(ctx("b"), testdata.GenerateMetrics(1))
+	assert.ErrorIs(t, err, batching.ErrTooManyBatchers)
+	require.NoError(t, mp.Shutdown(context.Background()))
+
+	lp, err := newBatchLogsProcessor(set, new(consumertest.LogsSink), cfg)
+	require.NoError(t, err)
+	require.NoError(t, lp.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, lp.ConsumeLogs(ctx("a"), testdata.GenerateLogs(1)))
+	err = lp.ConsumeLogs(ctx("b"), testdata.GenerateLogs(1))
+	assert.ErrorIs(t, err, batching.ErrTooManyBatchers)
+	require.NoError(t, lp.Shutdown(context.Background()))
+}
+
+func TestBatchProcessorMetadataCardinalityOverflowGroup(t *testing.T) {
+	tel := setupTelemetry(t)
+	const cardLimit = 3
+
+	sink := &metadataKeysTracesSink{
+		TracesSink:       &consumertest.TracesSink{},
+		spanCountByScope: map[string]int{},
+	}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"token"}
+	cfg.MetadataCardinalityLimit = cardLimit
+	cfg.CardinalityOverflowMode = "group"
+	batcher, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), sink, cfg)
 	require.NoError(t, err)
 	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
-	expect := 0
-	for requestNum := 0; requestNum < requestCount; requestNum++ {
-		cnt := logsPerRequest + requestNum
-		expect += cnt
-		ld := testdata.GenerateLogs(cnt)
-		assert.NoError(t, batcher.ConsumeLogs(context.Background(), ld))
+	bg := context.Background()
+	for requestNum := 0; requestNum < cardLimit+2; requestNum++ {
+		ctx := client.NewContext(bg, client.Info{
+			Metadata: client.NewMetadata(map[string][]string{
+				"token": {fmt.Sprint(requestNum)},
+			}),
+		})
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(2)))
 	}
 
-	// Wait for all batches.
+	// The overflow batcher does not count toward the limit.
+	assert.Equal(t, cardLimit, batcher.MetadataCardinality())
+
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	assert.Equal(t, map[string]int{
+		"token=[0]": 2,
+		"token=[1]": 2,
+		"token=[2]": 2,
+		"":          4,
+	}, sink.spanCountByScope)
+
+	tel.assertMetrics(t, expectedMetrics{
+		sendCount:     cardLimit + 1,
+		sendSizeSum:   float64(sink.SpanCount()),
+		overflowItems: 4,
+	})
+}
+
+func TestBatchProcessorMetadataEvictionLRU(t *testing.T) {
+	sink := &metadataKeysTracesSink{
+		TracesSink:       &consumertest.TracesSink{},
+		spanCountByScope: map[string]int{},
+	}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"token"}
+	cfg.MetadataCardinalityLimit = 2
+	cfg.MetadataEvictionPolicy = "lru"
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	bg := context.Background()
+	consume := func(token string) {
+		ctx := client.NewContext(bg, client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"token": {token}}),
+		})
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(1)))
+	}
+	consume("a")
+	consume("b")
+	consume("a")
+	// "b" is the least recently used and is flushed on eviction.
+	consume("c")
+
 	require.Eventually(t, func() bool {
-		return sink.LogRecordCount() == expect
+		return sink.SpanCount() == 1
 	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 2, batcher.MetadataCardinality())
 
-	// Expect them to be the original sizes.
-	receivedMds := sink.AllLogs()
-	require.Equal(t, requestCount, len(receivedMds))
-	for i, ld := range receivedMds {
-		require.Equal(t, 1, ld.ResourceLogs().Len())
-		require.Equal(t, logsPerRequest+i, ld.LogRecordCount())
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, map[string]int{
+		"token=[a]": 2,
+		"token=[b]": 1,
+		"token=[c]": 1,
+	}, sink.spanCountByScope)
+}
+
+func TestBatchProcessorMetadataEvictionLRUConcurrent(t *testing.T) {
+	sink := new(consumertest.TracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"token"}
+	cfg.MetadataCardinalityLimit = 3
+	cfg.MetadataEvictionPolicy = "lru"
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	const producers = 8
+	const requestsPerProducer = 200
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < requestsPerProducer; i++ {
+				ctx := client.NewContext(context.Background(), client.Info{
+					Metadata: client.NewMetadata(map[string][]string{
+						"token": {fmt.Sprint((p + i) % 10)},
+					}),
+				})
+				assert.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(1)))
+			}
+		}(p)
 	}
+	wg.Wait()
+
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	// Items enqueued to evicted batchers are flushed, not lost.
+	assert.Equal(t, producers*requestsPerProducer, sink.SpanCount())
 }
 
-func TestBatchSplitOnly(t *testing.T) {
-	const maxBatch = 10
-	const requestCount = 5
-	const logsPerRequest = 100
+func TestBatchProcessorMetadataBatcherIdleTimeout(t *testing.T) {
+	sink := &metadataKeysTracesSink{
+		TracesSink:       &consumertest.TracesSink{},
+		spanCountByScope: map[string]int{},
+	}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"token"}
+	cfg.MetadataBatcherIdleTimeout = 20 * time.Millisecond
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
-	cfg := Config{
-		SendBatchMaxSize: maxBatch,
+	ctx := client.NewContext(context.Background(), client.Info{
+		Metadata: client.NewMetadata(map[string][]string{"token": {"a"}}),
+	})
+	require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(2)))
+	assert.Equal(t, 1, batcher.MetadataCardinality())
+
+	// The idle batcher flushes its pending data and is removed.
+	require.Eventually(t, func() bool {
+		return batcher.MetadataCardinality() == 0 && sink.SpanCount() == 2
+	}, time.Second, 5*time.Millisecond)
+
+	// The same combination re-creates the batcher.
+	require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(3)))
+	assert.Equal(t, 1, batcher.MetadataCardinality())
+
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, map[string]int{"token=[a]": 5}, sink.spanCountByScope)
+	assert.Len(t, sink.AllTraces(), 2)
+}
+
+func TestBatchProcessorSignals(t *testing.T) {
+	logsSize := uint32(4)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 2
+	cfg.Timeout = time.Hour
+	cfg.SyncConsume = true
+	cfg.Signals.Logs.SendBatchSize = &logsSize
+
+	traces := new(consumertest.TracesSink)
+	tp, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), traces, cfg)
+	require.NoError(t, err)
+	require.NoError(t, tp.Start(context.Background(), componenttest.NewNopHost()))
+	logs := new(consumertest.LogsSink)
+	lp, err := newBatchLogsProcessor(processortest.NewNopCreateSettings(), logs, cfg)
+	require.NoError(t, err)
+	require.NoError(t, lp.Start(context.Background(), componenttest.NewNopHost()))
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, tp.ConsumeTraces(context.Background(), testdata.GenerateTraces(1)))
+		require.NoError(t, lp.ConsumeLogs(context.Background(), testdata.GenerateLogs(1)))
 	}
+	assert.Equal(t, []int{2, 2}, spanCounts(traces))
+	require.Len(t, logs.AllLogs(), 1)
+	assert.Equal(t, 4, logs.AllLogs()[0].LogRecordCount())
 
-	require.NoError(t, cfg.Validate())
+	require.NoError(t, tp.Shutdown(context.Background()))
+	require.NoError(t, lp.Shutdown(context.Background()))
+}
 
-	sink := new(consumertest.LogsSink)
-	creationSet := processortest.NewNopCreateSettings()
-	creationSet.MetricsLevel = configtelemetry.LevelDetailed
-	batcher, err := newBatchLogsProcessor(creationSet, sink, &cfg, false)
+func TestBatchProcessorOverrides(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := &metadataKeysTracesSink{
+		TracesSink:       &consumertest.TracesSink{},
+		spanCountByScope: map[string]int{},
+	}
+	size := uint32(4)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"X-Tenant"}
+	cfg.Overrides = []BatchOverride{{
+		Metadata:      map[string]string{"x-tenant": "big"},
+		SendBatchSize: &size,
+	}}
+	batcher, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	bg := context.Background()
+	for _, tenant := range []string{"big", "small"} {
+		ctx := client.NewContext(bg, client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"x-tenant": {tenant}}),
+		})
+		for i := 0; i < 4; i++ {
+			require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(2)))
+		}
+	}
+
+	// Only the overridden batcher sends on size.
+	require.Eventually(t, func() bool {
+		return sink.SpanCount() == 8
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, map[string]int{"x-tenant=[big]": 8}, sink.spanCountByScope)
+
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, map[string]int{"x-tenant=[big]": 8, "x-tenant=[small]": 8}, sink.spanCountByScope)
+
+	tel.assertMetrics(t, expectedMetrics{
+		sendCount:       3,
+		sendSizeSum:     16,
+		sizeTrigger:     2,
+		shutdownTrigger: 1,
+	})
+}
+
+func TestBatchProcessorMetadataCardinalityWarning(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	set := processortest.NewNopCreateSettings()
+	set.Logger = zap.New(core)
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.MetadataKeys = []string{"token"}
+	cfg.MetadataCardinalityLimit = 10
+	cfg.MetadataEvictionPolicy = "lru"
+	batcher, err := newBatchTracesProcessor(set, consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	for requestNum := 0; requestNum < 11; requestNum++ {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"token": {fmt.Sprint(requestNum)}}),
+		})
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(1)))
+	}
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	warnings := logs.FilterMessage("Approaching the metadata cardinality limit").All()
+	require.Len(t, warnings, 1)
+	assert.Equal(t, int64(8), warnings[0].ContextMap()["metadata_cardinality"])
+	assert.Equal(t, int64(10), warnings[0].ContextMap()["metadata_cardinality_limit"])
+
+	// Creation logs are sampled at 10 per second.
+	assert.GreaterOrEqual(t, logs.FilterMessage("Created batcher").Len(), 10)
+	removed := logs.FilterMessage("Removed batcher").All()
+	require.Len(t, removed, 1)
+	assert.Equal(t, "evicted", removed[0].ContextMap()["reason"])
+	assert.Equal(t, "token=0", removed[0].ContextMap()["key"])
+}
+
+func TestBatchProcessorBatcherLifecycleLogsSampled(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	set := processortest.NewNopCreateSettings()
+	set.Logger = zap.New(core)
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.MetadataKeys = []string{"token"}
+	batcher, err := newBatchTracesProcessor(set, consumertest.NewNop(), cfg)
 	require.NoError(t, err)
 	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
 
+	const requestCount = 100
 	for requestNum := 0; requestNum < requestCount; requestNum++ {
-		ld := testdata.GenerateLogs(logsPerRequest)
-		assert.NoError(t, batcher.ConsumeLogs(context.Background(), ld))
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"token": {fmt.Sprint(requestNum)}}),
+		})
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(1)))
 	}
+	require.NoError(t, batcher.Shutdown(context.Background()))
 
-	// Wait for all batches.
+	assert.Less(t, logs.FilterMessage("Created batcher").Len(), requestCount)
+}
+
+// blockingTracesSink blocks every export until unblock is closed.
+type blockingTracesSink struct {
+	consumertest.TracesSink
+	unblock chan struct{}
+}
+
+func (s *blockingTracesSink) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	<-s.unblock
+	return s.TracesSink.ConsumeTraces(ctx, td)
+}
+
+func TestBatchProcessorOnFullError(t *testing.T) {
+	sink := &blockingTracesSink{unblock: make(chan struct{})}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1
+	cfg.OnFull = "error"
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	// The batcher blocks on the first export, the following requests
+	// fill its queue until one is rejected.
+	accepted := 0
+	for {
+		err = batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(1))
+		if err != nil {
+			break
+		}
+		accepted++
+		require.LessOrEqual(t, accepted, runtime.NumCPU()+1)
+	}
+	assert.ErrorIs(t, err, batching.ErrBatcherFull)
+	assert.False(t, consumererror.IsPermanent(err))
+	var tracesErr consumererror.Traces
+	require.ErrorAs(t, err, &tracesErr)
+	assert.Equal(t, 1, tracesErr.Data().SpanCount())
+
+	close(sink.unblock)
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, accepted, sink.SpanCount())
+}
+
+func TestBatchProcessorOnFullDropOldest(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := &blockingTracesSink{unblock: make(chan struct{})}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1
+	cfg.OnFull = "drop_oldest"
+	batcher, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	// The batcher blocks on the first export, the queue holds at most
+	// runtime.NumCPU() of the following requests.
+	requestCount := runtime.NumCPU() + 10
+	for requestNum := 0; requestNum < requestCount; requestNum++ {
+		td := testdata.GenerateTraces(1)
+		td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).SetName(fmt.Sprint(requestNum))
+		require.NoError(t, batcher.ConsumeTraces(context.Background(), td))
+	}
+
+	close(sink.unblock)
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	sent := sink.SpanCount()
+	assert.Less(t, sent, requestCount)
+	// The most recent request is kept.
+	traces := sink.AllTraces()
+	last := traces[len(traces)-1].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, fmt.Sprint(requestCount-1), last.Name())
+
+	tel.assertMetrics(t, expectedMetrics{
+		droppedItems: map[string]float64{"queue_overflow": float64(requestCount - sent)},
+	})
+}
+
+func TestBatchProcessorMaxInFlightItems(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := &blockingTracesSink{unblock: make(chan struct{})}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1
+	cfg.MaxInFlightItems = 10
+	batcher, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(4)))
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(4)))
+
+	err = batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(4))
+	assert.ErrorIs(t, err, batching.ErrInFlightLimit)
+	assert.False(t, consumererror.IsPermanent(err))
+	var tracesErr consumererror.Traces
+	require.ErrorAs(t, err, &tracesErr)
+	assert.Equal(t, 4, tracesErr.Data().SpanCount())
+
+	tel.assertMetrics(t, expectedMetrics{
+		inFlightItems: 8,
+	})
+
+	// Exported items are released from the budget.
+	close(sink.unblock)
 	require.Eventually(t, func() bool {
-		return sink.LogRecordCount() == logsPerRequest*requestCount
+		return sink.SpanCount() == 8
+	}, time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return getMetric(t, "in_flight_items", tel.gather(t)).value == 0
 	}, time.Second, 5*time.Millisecond)
 
-	// Expect them to be the limited by maxBatch.
-	receivedMds := sink.AllLogs()
-	require.Equal(t, requestCount*logsPerRequest/maxBatch, len(receivedMds))
-	for _, ld := range receivedMds {
-		require.Equal(t, maxBatch, ld.LogRecordCount())
+	// A request larger than the budget is accepted when nothing is
+	// in flight.
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(20)))
+
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, 28, sink.SpanCount())
+}
+
+func TestBatchProcessorQueueGauges(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := &blockingTracesSink{unblock: make(chan struct{})}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 4
+	cfg.Timeout = time.Hour
+	cfg.MetadataKeys = []string{"tenant"}
+	batcher, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	tenant := func(name string) context.Context {
+		return client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"tenant": {name}}),
+		})
+	}
+	// Tenant "a" holds a pending batch, tenant "b" blocks on its
+	// export while a request waits in its channel.
+	require.NoError(t, batcher.ConsumeTraces(tenant("a"), testdata.GenerateTraces(2)))
+	require.NoError(t, batcher.ConsumeTraces(tenant("b"), testdata.GenerateTraces(4)))
+	require.Eventually(t, func() bool {
+		pending, queued := 0, 0
+		for _, b := range batcher.Snapshot().Batchers {
+			pending += b.Items
+			queued += b.Queued
+		}
+		return pending == 2 && queued == 0
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, batcher.ConsumeTraces(tenant("b"), testdata.GenerateTraces(1)))
+
+	tel.assertMetrics(t, expectedMetrics{
+		queueLength:   1,
+		queueCapacity: float64(2 * runtime.NumCPU()),
+		pendingItems:  2,
+	})
+
+	close(sink.unblock)
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, 7, sink.SpanCount())
+}
+
+func TestBatchProcessorActiveBatchers(t *testing.T) {
+	tel := setupTelemetry(t)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = time.Hour
+	cfg.MetadataKeys = []string{"tenant"}
+	cfg.MetadataBatcherIdleTimeout = 50 * time.Millisecond
+	batcher, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	activeBatchers := func() float64 {
+		return getMetric(t, "active_batchers", tel.gather(t)).value
+	}
+	assert.Zero(t, activeBatchers())
+	for _, tenant := range []string{"a", "b", "c"} {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"tenant": {tenant}}),
+		})
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(1)))
+	}
+	assert.Equal(t, float64(3), activeBatchers())
+
+	// Expired batchers are no longer counted once their goroutine
+	// exits.
+	require.Eventually(t, func() bool {
+		return activeBatchers() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The gauges are unregistered on shutdown.
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.NotContains(t, tel.gather(t), "active_batchers")
+}
+
+func TestBatchProcessorStaleness(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := new(consumertest.TracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 4
+	cfg.Timeout = time.Hour
+	cfg.SyncConsume = true
+	batcher, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	staleness := func() float64 {
+		return getMetric(t, "staleness", tel.gather(t)).value
 	}
+	// Nothing is pending yet.
+	time.Sleep(20 * time.Millisecond)
+	assert.Zero(t, staleness())
+
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(2)))
+	require.Eventually(t, func() bool {
+		return batcher.Snapshot().Batchers[0].Items == 2
+	}, time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.GreaterOrEqual(t, staleness(), 0.05)
+
+	// The batch is flushed and nothing is pending anymore.
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(2)))
+	assert.Equal(t, 4, sink.SpanCount())
+	assert.Eventually(t, func() bool { return staleness() == 0 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, batcher.Shutdown(context.Background()))
+}
+
+func TestBatchProcessorBatchersCreatedRemoved(t *testing.T) {
+	tel := setupTelemetry(t)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = time.Hour
+	cfg.MetadataKeys = []string{"tenant"}
+	cfg.MetadataCardinalityLimit = 3
+	cfg.MetadataEvictionPolicy = "lru"
+	batcher, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	// 5 distinct tenants, the 2 least recently used are evicted.
+	for i := 0; i < 5; i++ {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"tenant": {fmt.Sprint(i)}}),
+		})
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(1)))
+	}
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	tel.assertMetrics(t, expectedMetrics{
+		batchersCreated: 5,
+		batchersRemoved: map[string]float64{
+			"evicted":  2,
+			"shutdown": 3,
+		},
+	})
+}
+
+func TestBatchProcessorBatchersExpired(t *testing.T) {
+	tel := setupTelemetry(t)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = time.Hour
+	cfg.MetadataKeys = []string{"tenant"}
+	cfg.MetadataBatcherIdleTimeout = 5 * time.Millisecond
+	batcher, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	for i := 0; i < 4; i++ {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"tenant": {fmt.Sprint(i)}}),
+		})
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(1)))
+	}
+	require.Eventually(t, func() bool {
+		return len(batcher.Snapshot().Batchers) == 0
+	}, 5*time.Second, 5*time.Millisecond)
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	tel.assertMetrics(t, expectedMetrics{
+		batchersCreated: 4,
+		batchersRemoved: map[string]float64{"expired": 4},
+	})
+}
+
+func TestBatchProcessorSingleBatcherCreated(t *testing.T) {
+	tel := setupTelemetry(t)
+	batcher, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), consumertest.NewNop(), createDefaultConfig().(*Config))
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	tel.assertMetrics(t, expectedMetrics{
+		batchersCreated: 1,
+		batchersRemoved: map[string]float64{"shutdown": 1},
+	})
+}
+
+func TestBatchProcessorTelemetryIncludeMetadata(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := new(consumertest.TracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 2
+	cfg.Timeout = time.Hour
+	cfg.MetadataKeys = []string{"tenant"}
+	cfg.AuthKeys = []string{"subject"}
+	cfg.TelemetryIncludeMetadata = true
+	set := tel.NewProcessorCreateSettings()
+	set.MetricsLevel = configtelemetry.LevelNormal
+	batcher, err := newBatchTracesProcessor(set, sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	for _, req := range []struct {
+		tenant string
+		spans  int
+	}{{"a", 2}, {"b", 2}, {"b", 2}, {"a", 1}} {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"tenant": {req.tenant}}),
+			Auth:     fakeAuthData{"subject": "secret"},
+		})
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(req.spans)))
+	}
+	require.Eventually(t, func() bool {
+		pending := 0
+		for _, b := range batcher.Snapshot().Batchers {
+			pending += b.Items
+		}
+		return sink.SpanCount() == 6 && pending == 1
+	}, time.Second, 5*time.Millisecond)
+
+	metrics := tel.gather(t)
+	byTenant := func(name string, histogram bool) map[string]float64 {
+		got := map[string]float64{}
+		for _, p := range metrics[name] {
+			_, ok := p.attrs.Value("auth:subject")
+			assert.False(t, ok)
+			if tenant, ok := p.attrs.Value("tenant"); ok {
+				if histogram {
+					got[tenant.AsString()] += float64(p.count)
+				} else {
+					got[tenant.AsString()] += p.value
+				}
+			}
+		}
+		return got
+	}
+	assert.Equal(t, map[string]float64{"a": 1, "b": 2}, byTenant("batch_size_trigger_send", false))
+	assert.Equal(t, map[string]float64{"a": 1, "b": 2}, byTenant("batch_send_size", true))
+	assert.Equal(t, map[string]float64{"a": 1, "b": 2}, byTenant("send_latency", true))
+	assert.Equal(t, map[string]float64{"a": 1, "b": 0}, byTenant("pending_items", false))
+	assert.Equal(t, map[string]float64{"a": 0, "b": 0}, byTenant("queue_length", false))
+
+	require.NoError(t, batcher.Shutdown(context.Background()))
+}
+
+func TestBatchProcessorErrorMode(t *testing.T) {
+	errDownstream := errors.New("downstream failure")
+	for _, tt := range []struct {
+		mode    string
+		wantErr error
+	}{
+		{mode: "ignore"},
+		{mode: "propagate", wantErr: errDownstream},
+	} {
+		t.Run(tt.mode, func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.SendBatchSize = 10
+			cfg.Timeout = 10 * time.Millisecond
+			cfg.ErrorMode = tt.mode
+			batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), consumertest.NewErr(errDownstream), cfg)
+			require.NoError(t, err)
+			require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+			// Every producer whose data was in the failed batch
+			// gets the error.
+			const requestCount = 5
+			errs := make([]error, requestCount)
+			var wg sync.WaitGroup
+			for requestNum := 0; requestNum < requestCount; requestNum++ {
+				wg.Add(1)
+				go func(requestNum int) {
+					defer wg.Done()
+					errs[requestNum] = batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(1))
+				}(requestNum)
+			}
+			wg.Wait()
+			for _, err := range errs {
+				assert.Equal(t, tt.wantErr, err)
+			}
+
+			require.NoError(t, batcher.Shutdown(context.Background()))
+		})
+	}
+}
+
+func TestBatchProcessorErrorModePropagateSuccess(t *testing.T) {
+	sink := new(consumertest.LogsSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Millisecond
+	cfg.ErrorMode = "propagate"
+	cfg.ResourceAttributeKeys = []string{"resource-attr"}
+	batcher, err := newBatchLogsProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	// The call returns once the data has been exported.
+	require.NoError(t, batcher.ConsumeLogs(context.Background(), testdata.GenerateLogs(5)))
+	assert.Equal(t, 5, sink.LogRecordCount())
+
+	require.NoError(t, batcher.Shutdown(context.Background()))
+}
+
+func TestBatchProcessorErrorModePropagateCombined(t *testing.T) {
+	errInvalid, errUnavailable := errors.New("invalid"), errors.New("unavailable")
+	sink := &errorsTracesSink{errs: []error{
+		consumererror.NewPermanent(consumererror.NewWithCount(errInvalid, 2)),
+		nil,
+		consumererror.NewWithCount(errUnavailable, 3),
+	}}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 15
+	cfg.SendBatchMaxSize = 5
+	cfg.Timeout = 10 * time.Millisecond
+	cfg.ErrorMode = "propagate"
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	// The errors of the requests of the split batch are combined.
+	err = batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(15))
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, 5, sink.SpanCount())
+	assert.ErrorIs(t, err, errInvalid)
+	assert.ErrorIs(t, err, errUnavailable)
+	assert.True(t, consumererror.IsPermanent(err))
+	n, ok := consumererror.Count(err)
+	assert.True(t, ok)
+	assert.Equal(t, 5, n)
+}
+
+func TestBatchProcessorErrorModePropagateDropped(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.ErrorMode = "propagate"
+	flushOnShutdown := false
+	cfg.FlushOnShutdown = &flushOnShutdown
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	// Whether the data reaches the batcher before or after it starts
+	// draining, the waiting producer learns it was not exported.
+	errC := make(chan error, 1)
+	go func() {
+		errC <- batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(1))
+	}()
+
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	err = <-errC
+	if !errors.Is(err, batching.ErrShuttingDown) {
+		assert.ErrorIs(t, err, batching.ErrDropped)
+	}
+}
+
+// flakyTracesSink fails the first failures calls with err.
+type flakyTracesSink struct {
+	consumertest.TracesSink
+
+	lock     sync.Mutex
+	calls    int
+	failures int
+	err      error
+}
+
+func (s *flakyTracesSink) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	s.lock.Lock()
+	s.calls++
+	fail := s.calls <= s.failures
+	s.lock.Unlock()
+	if fail {
+		return s.err
+	}
+	return s.TracesSink.ConsumeTraces(ctx, td)
+}
+
+func (s *flakyTracesSink) callCount() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.calls
+}
+
+func retryConfig() *Config {
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 10
+	cfg.Timeout = 10 * time.Minute
+	cfg.Retry = RetryConfig{
+		Enabled:         true,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     4 * time.Millisecond,
+	}
+	return cfg
+}
+
+func TestBatchProcessorRetry(t *testing.T) {
+	sink := &flakyTracesSink{failures: 3, err: errors.New("unavailable")}
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, retryConfig())
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(10)))
+	require.Eventually(t, func() bool {
+		return sink.SpanCount() == 10
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, 4, sink.callCount())
+}
+
+func TestBatchProcessorRetryPermanentError(t *testing.T) {
+	sink := &flakyTracesSink{failures: 1, err: consumererror.NewPermanent(errors.New("malformed"))}
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, retryConfig())
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(10)))
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, 1, sink.callCount())
+	assert.Equal(t, 0, sink.SpanCount())
+}
+
+func TestBatchProcessorRetryMaxElapsedTime(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := &flakyTracesSink{failures: math.MaxInt, err: errors.New("unavailable")}
+	cfg := retryConfig()
+	cfg.Retry.MaxElapsedTime = 20 * time.Millisecond
+	batcher, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(10)))
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Greater(t, sink.callCount(), 1)
+
+	tel.assertMetrics(t, expectedMetrics{
+		droppedItems: map[string]float64{"send_failed": 10},
+	})
+}
+
+func TestBatchProcessorRetryShutdown(t *testing.T) {
+	sink := &flakyTracesSink{failures: math.MaxInt, err: errors.New("unavailable")}
+	cfg := retryConfig()
+	cfg.Retry.InitialInterval = time.Hour
+	cfg.Retry.MaxInterval = time.Hour
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(10)))
+	require.Eventually(t, func() bool {
+		return sink.callCount() == 1
+	}, time.Second, 5*time.Millisecond)
+
+	// Shutdown interrupts the backoff for a final attempt.
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, 2, sink.callCount())
+}
+
+// errorsTracesSink returns the errors of errs in turn, then succeeds.
+// The size of the failed requests is recorded by permanence.
+type errorsTracesSink struct {
+	consumertest.TracesSink
+
+	lock        sync.Mutex
+	errs        []error
+	failedBytes map[string]float64
+}
+
+func (s *errorsTracesSink) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	s.lock.Lock()
+	var err error
+	if len(s.errs) != 0 {
+		err, s.errs = s.errs[0], s.errs[1:]
+	}
+	if err != nil {
+		permanence := "retryable"
+		if consumererror.IsPermanent(err) {
+			permanence = "permanent"
+		}
+		if s.failedBytes == nil {
+			s.failedBytes = map[string]float64{}
+		}
+		s.failedBytes[permanence] += float64((&ptrace.ProtoMarshaler{}).TracesSize(td))
+	}
+	s.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.TracesSink.ConsumeTraces(ctx, td)
+}
+
+func TestBatchProcessorSendFailedPermanence(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := &errorsTracesSink{errs: []error{
+		consumererror.NewPermanent(errors.New("malformed")),
+		errors.New("unavailable"),
+		errors.New("unavailable"),
+	}}
+	core, logs := observer.New(zap.WarnLevel)
+	set := tel.NewProcessorCreateSettings()
+	set.Logger = zap.New(core)
+	set.MetricsLevel = configtelemetry.LevelDetailed
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 5
+	cfg.SendBatchMaxSize = 5
+	cfg.Timeout = 10 * time.Minute
+	cfg.FailureLogInterval = 0
+	batcher, err := newBatchTracesProcessor(set, sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(20)))
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, 5, sink.SpanCount())
+
+	// Only the failed parts of the split batch are dropped.
+	tel.assertMetrics(t, expectedMetrics{
+		sendFailedItems: map[string]float64{
+			"permanent": 5,
+			"retryable": 10,
+		},
+		sendFailedBytes: sink.failedBytes,
+		droppedItems: map[string]float64{
+			"send_failed_permanent": 5,
+			"send_failed":           10,
+		},
+		droppedBytes: map[string]float64{
+			"send_failed_permanent": sink.failedBytes["permanent"],
+			"send_failed":           sink.failedBytes["retryable"],
+		},
+		rejectedItems: 15,
+	})
+
+	failed := logs.FilterMessage("Sender failed").All()
+	require.Len(t, failed, 3)
+	fields := failed[0].ContextMap()
+	assert.Equal(t, "traces", fields["data_type"])
+	assert.Equal(t, "batch_size", fields["trigger"])
+	assert.Equal(t, "permanent", fields["permanence"])
+	assert.Equal(t, int64(5), fields["items"])
+	assert.Equal(t, int64(5), fields["dropped_items"])
+	assert.Equal(t, int64(5), fields["rejected_items"])
+	assert.Equal(t, int64(0), fields["accepted_items"])
+	assert.Greater(t, fields["bytes"], int64(0))
+	assert.Equal(t, "retryable", failed[1].ContextMap()["permanence"])
+}
+
+// halfRejectingTracesSink accepts the first half of the spans of every
+// request, rounded up, and returns the second half in the error.
+type halfRejectingTracesSink struct {
+	consumertest.TracesSink
+
+	lock  sync.Mutex
+	calls int
+}
+
+func (s *halfRejectingTracesSink) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	s.lock.Lock()
+	s.calls++
+	s.lock.Unlock()
+	half := (td.SpanCount() + 1) / 2
+	accepted, rejected := ptrace.NewTraces(), ptrace.NewTraces()
+	td.CopyTo(accepted)
+	td.CopyTo(rejected)
+	removeSpans(accepted, func(i int) bool { return i >= half })
+	removeSpans(rejected, func(i int) bool { return i < half })
+	_ = s.TracesSink.ConsumeTraces(ctx, accepted)
+	if rejected.SpanCount() == 0 {
+		return nil
+	}
+	return consumererror.NewTraces(errors.New("partial failure"), rejected)
+}
+
+// removeSpans removes the spans of td whose index matches f.
+func removeSpans(td ptrace.Traces, f func(int) bool) {
+	i := 0
+	td.ResourceSpans().RemoveIf(func(rs ptrace.ResourceSpans) bool {
+		rs.ScopeSpans().RemoveIf(func(ss ptrace.ScopeSpans) bool {
+			ss.Spans().RemoveIf(func(ptrace.Span) bool {
+				i++
+				return f(i - 1)
+			})
+			return ss.Spans().Len() == 0
+		})
+		return rs.ScopeSpans().Len() == 0
+	})
+}
+
+func TestBatchProcessorRequeuePartialFailure(t *testing.T) {
+	sink := new(halfRejectingTracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 8
+	cfg.Timeout = 10 * time.Millisecond
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	td := testdata.GenerateTraces(8)
+	spans := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+	for i := 0; i < spans.Len(); i++ {
+		spans.At(i).SetName(fmt.Sprint(i))
+	}
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), td))
+	require.Eventually(t, func() bool {
+		return sink.SpanCount() == 8
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	// 8 spans are delivered as 4, 2, 1, and 1, without duplicates.
+	var names []string
+	for _, td := range sink.AllTraces() {
+		spans := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+		for i := 0; i < spans.Len(); i++ {
+			names = append(names, spans.At(i).Name())
+		}
+	}
+	assert.Equal(t, []string{"0", "1", "2", "3", "4", "5", "6", "7"}, names)
+	assert.Equal(t, 4, sink.calls)
+}
+
+func TestBatchProcessorRequeueLimit(t *testing.T) {
+	tel := setupTelemetry(t)
+	td := testdata.GenerateTraces(4)
+	rejected := ptrace.NewTraces()
+	td.CopyTo(rejected)
+	sink := &flakyTracesSink{failures: math.MaxInt}
+	sink.err = consumererror.NewTraces(errors.New("rejected"), rejected)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 4
+	cfg.Timeout = time.Millisecond
+	batcher, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), &requeueCopyingSink{sink}, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	// The rejected data is requeued 3 times, then dropped.
+	const maxRequeues = 3
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), td))
+	require.Eventually(t, func() bool {
+		return sink.callCount() == maxRequeues+1
+	}, time.Second, time.Millisecond)
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, maxRequeues+1, sink.callCount())
+
+	tel.assertMetrics(t, expectedMetrics{
+		droppedItems:  map[string]float64{"send_failed": 4},
+		rejectedItems: 4 * (maxRequeues + 1),
+	})
+}
+
+// requeueCopyingSink returns a copy of the data carried by the errors
+// of its sink, which may be requeued and consumed again.
+type requeueCopyingSink struct {
+	*flakyTracesSink
+}
+
+func (s *requeueCopyingSink) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	err := s.flakyTracesSink.ConsumeTraces(ctx, td)
+	var te consumererror.Traces
+	if errors.As(err, &te) {
+		cp := ptrace.NewTraces()
+		te.Data().CopyTo(cp)
+		return consumererror.NewTraces(err, cp)
+	}
+	return err
+}
+
+func TestBatchZeroConfig(t *testing.T) {
+	// This is a no-op configuration. No need for a timer, no
+	// minimum, no mxaimum, just a pass through.
+	cfg := Config{}
+
+	require.NoError(t, cfg.Validate())
+
+	const requestCount = 5
+	const logsPerRequest = 10
+	sink := new(consumertest.LogsSink)
+	creationSet := processortest.NewNopCreateSettings()
+	creationSet.MetricsLevel = configtelemetry.LevelDetailed
+	batcher, err := newBatchLogsProcessor(creationSet, sink, &cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	expect := 0
+	for requestNum := 0; requestNum < requestCount; requestNum++ {
+		cnt := logsPerRequest + requestNum
+		expect += cnt
+		ld := testdata.GenerateLogs(cnt)
+		assert.NoError(t, batcher.ConsumeLogs(context.Background(), ld))
+	}
+
+	// Wait for all batches.
+	require.Eventually(t, func() bool {
+		return sink.LogRecordCount() == expect
+	}, time.Second, 5*time.Millisecond)
+
+	// Expect them to be the original sizes.
+	receivedMds := sink.AllLogs()
+	require.Equal(t, requestCount, len(receivedMds))
+	for i, ld := range receivedMds {
+		require.Equal(t, 1, ld.ResourceLogs().Len())
+		require.Equal(t, logsPerRequest+i, ld.LogRecordCount())
+	}
+}
+
+func TestBatchSplitOnly(t *testing.T) {
+	const maxBatch = 10
+	const requestCount = 5
+	const logsPerRequest = 100
+
+	cfg := Config{
+		SendBatchMaxSize: maxBatch,
+	}
+
+	require.NoError(t, cfg.Validate())
+
+	sink := new(consumertest.LogsSink)
+	creationSet := processortest.NewNopCreateSettings()
+	creationSet.MetricsLevel = configtelemetry.LevelDetailed
+	batcher, err := newBatchLogsProcessor(creationSet, sink, &cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	for requestNum := 0; requestNum < requestCount; requestNum++ {
+		ld := testdata.GenerateLogs(logsPerRequest)
+		assert.NoError(t, batcher.ConsumeLogs(context.Background(), ld))
+	}
+
+	// Wait for all batches.
+	require.Eventually(t, func() bool {
+		return sink.LogRecordCount() == logsPerRequest*requestCount
+	}, time.Second, 5*time.Millisecond)
+
+	// Expect them to be the limited by maxBatch.
+	receivedMds := sink.AllLogs()
+	require.Equal(t, requestCount*logsPerRequest/maxBatch, len(receivedMds))
+	for _, ld := range receivedMds {
+		require.Equal(t, maxBatch, ld.LogRecordCount())
+	}
+}
+
+// rejectingOnceSink rejects its first request as a whole, returning a
+// copy of its data in a wrapped retryable error, like an exporter whose
+// backend is briefly unavailable, then accepts every request.
+type rejectingOnceSink struct {
+	consumertest.TracesSink
+	consumertest.MetricsSink
+	consumertest.LogsSink
+
+	lock     sync.Mutex
+	rejected bool
+}
+
+func (s *rejectingOnceSink) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{}
+}
+
+func (s *rejectingOnceSink) reject() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	rejected := s.rejected
+	s.rejected = true
+	return !rejected
+}
+
+func (s *rejectingOnceSink) hasRejected() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.rejected
+}
+
+func (s *rejectingOnceSink) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	if s.reject() {
+		failed := ptrace.NewTraces()
+		td.CopyTo(failed)
+		return fmt.Errorf("export: %w", consumererror.NewTraces(errors.New("unavailable"), failed))
+	}
+	return s.TracesSink.ConsumeTraces(ctx, td)
+}
+
+func (s *rejectingOnceSink) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
+	if s.reject() {
+		failed := pmetric.NewMetrics()
+		md.CopyTo(failed)
+		return fmt.Errorf("export: %w", consumererror.NewMetrics(errors.New("unavailable"), failed))
+	}
+	return s.MetricsSink.ConsumeMetrics(ctx, md)
+}
+
+func (s *rejectingOnceSink) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	if s.reject() {
+		failed := plog.NewLogs()
+		ld.CopyTo(failed)
+		return fmt.Errorf("export: %w", consumererror.NewLogs(errors.New("unavailable"), failed))
+	}
+	return s.LogsSink.ConsumeLogs(ctx, ld)
+}
+
+func TestBatchProcessorRequeueRejectedData(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Millisecond
+
+	// The data rejected by the first export is requeued and sent with
+	// the data received since, every item being received exactly once.
+	t.Run("traces", func(t *testing.T) {
+		sink := new(rejectingOnceSink)
+		batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+		require.NoError(t, err)
+		require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+		require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(5)))
+		require.Eventually(t, sink.hasRejected, time.Second, time.Millisecond)
+		require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(3)))
+		require.Eventually(t, func() bool {
+			return sink.SpanCount() == 8
+		}, time.Second, 5*time.Millisecond)
+		require.NoError(t, batcher.Shutdown(context.Background()))
+		assert.Equal(t, 8, sink.SpanCount())
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		sink := new(rejectingOnceSink)
+		batcher, err := newBatchMetricsProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+		require.NoError(t, err)
+		require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+		md, more := testdata.GenerateMetrics(5), testdata.GenerateMetrics(3)
+		want := md.DataPointCount() + more.DataPointCount()
+		require.NoError(t, batcher.ConsumeMetrics(context.Background(), md))
+		require.Eventually(t, sink.hasRejected, time.Second, time.Millisecond)
+		require.NoError(t, batcher.ConsumeMetrics(context.Background(), more))
+		require.Eventually(t, func() bool {
+			return sink.DataPointCount() == want
+		}, time.Second, 5*time.Millisecond)
+		require.NoError(t, batcher.Shutdown(context.Background()))
+		assert.Equal(t, want, sink.DataPointCount())
+	})
+
+	t.Run("logs", func(t *testing.T) {
+		sink := new(rejectingOnceSink)
+		batcher, err := newBatchLogsProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+		require.NoError(t, err)
+		require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+		require.NoError(t, batcher.ConsumeLogs(context.Background(), testdata.GenerateLogs(5)))
+		require.Eventually(t, sink.hasRejected, time.Second, time.Millisecond)
+		require.NoError(t, batcher.ConsumeLogs(context.Background(), testdata.GenerateLogs(3)))
+		require.Eventually(t, func() bool {
+			return sink.LogRecordCount() == 8
+		}, time.Second, 5*time.Millisecond)
+		require.NoError(t, batcher.Shutdown(context.Background()))
+		assert.Equal(t, 8, sink.LogRecordCount())
+	})
+}
+
+// readOnlyTracesSink accepts read-only data.  It records whether the
+// requests it receives are read-only, and rejects the first one as a
+// whole, returning it as is.
+type readOnlyTracesSink struct {
+	consumertest.TracesSink
+
+	lock     sync.Mutex
+	readOnly []bool
+}
+
+func (s *readOnlyTracesSink) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{AcceptsReadOnly: true}
+}
+
+func (s *readOnlyTracesSink) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	s.lock.Lock()
+	s.readOnly = append(s.readOnly, td.IsReadOnly())
+	first := len(s.readOnly) == 1
+	s.lock.Unlock()
+	if first {
+		return consumererror.NewTraces(errors.New("unavailable"), td)
+	}
+	return s.TracesSink.ConsumeTraces(ctx, td)
+}
+
+func TestBatchProcessorReadOnly(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 4
+	cfg.Timeout = 10 * time.Millisecond
+
+	// The requests are marked read-only for a next consumer accepting
+	// read-only data, and the rejected request is requeued as a copy.
+	sink := new(readOnlyTracesSink)
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg, WithBatchReuse())
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(4)))
+	require.Eventually(t, func() bool {
+		return sink.SpanCount() == 4
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(4)))
+	require.Eventually(t, func() bool {
+		return sink.SpanCount() == 8
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, []bool{true, true, true}, sink.readOnly)
+	// The read-only requests are not reused.
+	for _, td := range sink.AllTraces() {
+		assert.Equal(t, 4, td.SpanCount())
+	}
+
+	// Otherwise, they are left mutable.
+	other := new(consumertest.TracesSink)
+	batcher, err = newBatchTracesProcessor(processortest.NewNopCreateSettings(), other, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(4)))
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	require.Len(t, other.AllTraces(), 1)
+	assert.False(t, other.AllTraces()[0].IsReadOnly())
+}
+
+func TestBatchProcessorRejectedItemsPartialFailure(t *testing.T) {
+	tel := setupTelemetry(t)
+	core, logs := observer.New(zap.WarnLevel)
+	set := tel.NewProcessorCreateSettings()
+	set.Logger = zap.New(core)
+	sink := new(halfRejectingTracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 8
+	cfg.Timeout = 10 * time.Millisecond
+	cfg.FailureLogInterval = 0
+	batcher, err := newBatchTracesProcessor(set, sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(8)))
+	require.Eventually(t, func() bool {
+		return sink.SpanCount() == 8
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	// The returned halves, 4, 2, then 1, are rejected, and only the
+	// last export succeeds.
+	tel.assertMetrics(t, expectedMetrics{
+		sendCount:     1,
+		sendSizeSum:   1,
+		rejectedItems: 7,
+	})
+	failed := logs.FilterMessage("Sender failed").All()
+	require.Len(t, failed, 3)
+	assert.Equal(t, int64(4), failed[0].ContextMap()["accepted_items"])
+	assert.Equal(t, int64(4), failed[0].ContextMap()["rejected_items"])
+}
+
+func TestBatchProcessorRejectedItemsCount(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := &errorsTracesSink{errs: []error{
+		consumererror.NewPermanent(consumererror.NewWithCount(errors.New("invalid"), 2)),
+		consumererror.NewWithCount(errors.New("unavailable"), 1),
+		errors.New("unavailable"),
+	}}
+	core, logs := observer.New(zap.WarnLevel)
+	set := tel.NewProcessorCreateSettings()
+	set.Logger = zap.New(core)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 5
+	cfg.SendBatchMaxSize = 5
+	cfg.Timeout = 10 * time.Minute
+	cfg.FailureLogInterval = 0
+	batcher, err := newBatchTracesProcessor(set, sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(20)))
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, 5, sink.SpanCount())
+
+	// The counts carried by the errors are preferred over the size of
+	// the requests, which is used for the error without a count.
+	tel.assertMetrics(t, expectedMetrics{
+		sendFailedItems: map[string]float64{
+			"permanent": 5,
+			"retryable": 10,
+		},
+		sendFailedBytes: sink.failedBytes,
+		droppedItems: map[string]float64{
+			"send_failed_permanent": 2,
+			"send_failed":           6,
+		},
+		rejectedItems: 8,
+	})
+
+	failed := logs.FilterMessage("Sender failed").All()
+	require.Len(t, failed, 3)
+	for i, rejected := range []int64{2, 1, 5} {
+		assert.Equal(t, rejected, failed[i].ContextMap()["rejected_items"])
+		assert.Equal(t, 5-rejected, failed[i].ContextMap()["accepted_items"])
+	}
+}
+
+func TestBatchProcessorFailureLogRateLimited(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	set := processortest.NewNopCreateSettings()
+	set.Logger = zap.New(core)
+	sink := &flakyTracesSink{failures: 5, err: errors.New("unavailable")}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1
+	cfg.MetadataKeys = []string{"tenant"}
+	cfg.FailureLogInterval = time.Hour
+	batcher, err := newBatchTracesProcessor(set, sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	ctx := client.NewContext(context.Background(), client.Info{
+		Metadata: client.NewMetadata(map[string][]string{"tenant": {"acme"}}),
+	})
+	for i := 0; i < 6; i++ {
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(1)))
+	}
+	require.Eventually(t, func() bool {
+		return sink.SpanCount() == 1
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	failed := logs.FilterMessage("Sender failed").All()
+	require.Len(t, failed, 1)
+	fields := failed[0].ContextMap()
+	assert.Equal(t, map[string]string{"tenant": "acme"}, fields["metadata"])
+	assert.Equal(t, int64(0), fields["suppressed_failures"])
+
+	recovered := logs.FilterMessage("Sender recovered").All()
+	require.Len(t, recovered, 1)
+	assert.Equal(t, int64(4), recovered[0].ContextMap()["suppressed_failures"])
+}
+
+func TestBatchProcessorShutdownDrainsSplitBatch(t *testing.T) {
+	sink := new(consumertest.TracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.SendBatchMaxSize = 3
+	cfg.Timeout = 10 * time.Minute
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(10)))
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	assert.Equal(t, 10, sink.SpanCount())
+	require.Len(t, sink.AllTraces(), 4)
+	for _, td := range sink.AllTraces() {
+		assert.LessOrEqual(t, td.SpanCount(), 3)
+	}
+}
+
+func TestBatchProcessorShutdownDeadline(t *testing.T) {
+	sink := &blockingTracesSink{unblock: make(chan struct{})}
+	t.Cleanup(func() { close(sink.unblock) })
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(10)))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = batcher.Shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+// cancellableTracesSink blocks every export until its context is done.
+type cancellableTracesSink struct {
+	lock      sync.Mutex
+	deadlines []bool
+	infos     []BatchInfo
+}
+
+func (s *cancellableTracesSink) ConsumeTraces(ctx context.Context, _ ptrace.Traces) error {
+	_, hasDeadline := ctx.Deadline()
+	info, _ := BatchInfoFromContext(ctx)
+	s.lock.Lock()
+	s.deadlines = append(s.deadlines, hasDeadline)
+	s.infos = append(s.infos, info)
+	s.lock.Unlock()
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *cancellableTracesSink) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: false}
+}
+
+func TestBatchProcessorShutdownDeadlineCancelsExport(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	set := processortest.NewNopCreateSettings()
+	set.Logger = zap.New(core)
+	sink := new(cancellableTracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.SendBatchMaxSize = 3
+	cfg.Timeout = 10 * time.Minute
+	cfg.FailureLogInterval = 0
+	batcher, err := newBatchTracesProcessor(set, sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(10)))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, batcher.Shutdown(ctx), context.DeadlineExceeded)
+	// The batcher drops the remaining data once its export fails.
+	require.Eventually(t, func() bool {
+		return logs.FilterMessage("Dropping pending data on shutdown").Len() == 1
+	}, time.Second, 5*time.Millisecond)
+
+	// The export is cancelled with the shutdown context, but keeps the
+	// values of the batcher's context.
+	assert.Equal(t, []bool{true}, sink.deadlines)
+	assert.Equal(t, BatchTriggerShutdown, sink.infos[0].Trigger)
+
+	// The failed export and the remaining data are counted as dropped.
+	failed := logs.FilterMessage("Sender failed").All()
+	require.Len(t, failed, 1)
+	assert.Equal(t, int64(3), failed[0].ContextMap()["items"])
+	abandoned := logs.FilterMessage("Dropping pending data on shutdown").All()
+	require.Len(t, abandoned, 1)
+	assert.Equal(t, int64(7), abandoned[0].ContextMap()["dropped_items"])
+
+	expired := logs.FilterMessage("Shutdown deadline expired, dropping pending data").All()
+	require.Len(t, expired, 1)
+	assert.Equal(t, int64(0), expired[0].ContextMap()["flushed_batchers"])
+	assert.Equal(t, int64(1), expired[0].ContextMap()["abandoned_batchers"])
+}
+
+func TestBatchProcessorDrainTimeout(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	set := processortest.NewNopCreateSettings()
+	set.Logger = zap.New(core)
+	sink := new(cancellableTracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.DrainTimeout = 100 * time.Millisecond
+	cfg.FailureLogInterval = 0
+	batcher, err := newBatchTracesProcessor(set, sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(10)))
+	// The drain timeout applies before the later deadline of the
+	// shutdown context, and is not an error.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	start := time.Now()
+	require.NoError(t, batcher.Shutdown(ctx))
+	assert.Less(t, time.Since(start), 30*time.Second)
+	require.Eventually(t, func() bool {
+		return logs.FilterMessage("Sender failed").Len() == 1
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, 1, logs.FilterMessage("Drain timeout expired, dropping pending data").Len())
+	failed := logs.FilterMessage("Sender failed").All()
+	require.Len(t, failed, 1)
+	assert.Equal(t, int64(10), failed[0].ContextMap()["items"])
+}
+
+func TestBatchProcessorConsumeDuringShutdown(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 100
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"tenant"}
+	set := processortest.NewNopCreateSettings()
+
+	tests := []struct {
+		name string
+		// start returns a started processor, a function consuming 3
+		// items, and a function counting the items exported.
+		start func(t *testing.T) (*batchProcessor, func(context.Context) error, func() int)
+	}{
+		{
+			name: "traces",
+			start: func(t *testing.T) (*batchProcessor, func(context.Context) error, func() int) {
+				sink := new(consumertest.TracesSink)
+				bp, err := newBatchTracesProcessor(set, sink, cfg)
+				require.NoError(t, err)
+				return bp, func(ctx context.Context) error {
+					return bp.ConsumeTraces(ctx, testdata.GenerateTraces(3))
+				}, sink.SpanCount
+			},
+		},
+		{
+			name: "metrics",
+			start: func(t *testing.T) (*batchProcessor, func(context.Context) error, func() int) {
+				sink := new(consumertest.MetricsSink)
+				bp, err := newBatchMetricsProcessor(set, sink, cfg)
+				require.NoError(t, err)
+				// 3 data points.
+				return bp, func(ctx context.Context) error {
+					md := pmetric.NewMetrics()
+					dps := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetEmptyGauge().DataPoints()
+					for i := 0; i < 3; i++ {
+						dps.AppendEmpty().SetIntValue(int64(i))
+					}
+					return bp.ConsumeMetrics(ctx, md)
+				}, sink.DataPointCount
+			},
+		},
+		{
+			name: "logs",
+			start: func(t *testing.T) (*batchProcessor, func(context.Context) error, func() int) {
+				sink := new(consumertest.LogsSink)
+				bp, err := newBatchLogsProcessor(set, sink, cfg)
+				require.NoError(t, err)
+				return bp, func(ctx context.Context) error {
+					return bp.ConsumeLogs(ctx, testdata.GenerateLogs(3))
+				}, sink.LogRecordCount
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batcher, consume, exported := tt.start(t)
+			require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+			var accepted atomic.Int64
+			var wg sync.WaitGroup
+			for i := 0; i < 32; i++ {
+				ctx := client.NewContext(context.Background(), client.Info{
+					Metadata: client.NewMetadata(map[string][]string{"tenant": {fmt.Sprint(i % 4)}}),
+				})
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						if err := consume(ctx); err != nil {
+							assert.ErrorIs(t, err, batching.ErrShuttingDown)
+							return
+						}
+						accepted.Add(3)
+					}
+				}()
+			}
+			time.Sleep(50 * time.Millisecond)
+			require.NoError(t, batcher.Shutdown(context.Background()))
+			wg.Wait()
+
+			// Every accepted item was exported.
+			assert.Equal(t, int(accepted.Load()), exported())
+		})
+	}
+}
+
+// concurrencyTracesSink records the highest number of concurrent
+// exports.
+type concurrencyTracesSink struct {
+	consumertest.TracesSink
+	active  atomic.Int64
+	highest atomic.Int64
+}
+
+func (s *concurrencyTracesSink) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	n := s.active.Add(1)
+	defer s.active.Add(-1)
+	for {
+		highest := s.highest.Load()
+		if n <= highest || s.highest.CompareAndSwap(highest, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return s.TracesSink.ConsumeTraces(ctx, td)
+}
+
+func TestBatchProcessorShutdownParallelism(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	set := processortest.NewNopCreateSettings()
+	set.Logger = zap.New(core)
+	sink := new(concurrencyTracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"tenant"}
+	cfg.ShutdownParallelism = 3
+	batcher, err := newBatchTracesProcessor(set, sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	tenants := make([]string, 12)
+	for i := range tenants {
+		tenants[i] = fmt.Sprint(i)
+	}
+	consumeTenantTraces(t, batcher, tenants, 2)
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	assert.Equal(t, 24, sink.SpanCount())
+	assert.LessOrEqual(t, sink.highest.Load(), int64(3))
+	assert.Greater(t, sink.highest.Load(), int64(1))
+	flushed := logs.FilterMessage("Flushed pending data on shutdown").All()
+	require.Len(t, flushed, 1)
+	assert.Equal(t, int64(12), flushed[0].ContextMap()["flushed_batchers"])
+	assert.Equal(t, int64(0), flushed[0].ContextMap()["abandoned_batchers"])
+}
+
+func TestBatchProcessorSyncConsume(t *testing.T) {
+	sink := &flakyTracesSink{failures: 1, err: consumererror.NewPermanent(errors.New("rejected"))}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 4
+	cfg.Timeout = 10 * time.Millisecond
+	cfg.SyncConsume = true
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	// The call filling the batch exports it and returns its error.
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(2)))
+	assert.ErrorContains(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(2)), "rejected")
+
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(4)))
+	assert.Equal(t, 4, sink.SpanCount())
+
+	// Pending data is flushed on timeout.
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(1)))
+	require.Eventually(t, func() bool {
+		return sink.SpanCount() == 5
+	}, time.Second, time.Millisecond)
+	require.NoError(t, batcher.Shutdown(context.Background()))
+}
+
+func TestBatchProcessorSyncConsumeTimerContention(t *testing.T) {
+	sink := new(consumertest.LogsSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 50
+	cfg.Timeout = time.Millisecond
+	cfg.SyncConsume = true
+	cfg.MetadataKeys = []string{"tenant"}
+	cfg.MetadataBatcherIdleTimeout = 5 * time.Millisecond
+	batcher, err := newBatchLogsProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"tenant": {fmt.Sprint(i % 2)}}),
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				assert.NoError(t, batcher.ConsumeLogs(ctx, testdata.GenerateLogs(j%7+1)))
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	assert.Equal(t, 16*794, sink.LogRecordCount())
+}
+
+func BenchmarkBatchProcessorConsume(b *testing.B) {
+	for _, syncConsume := range []bool{false, true} {
+		b.Run(fmt.Sprintf("sync_consume=%v", syncConsume), func(b *testing.B) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.SendBatchSize = 1000
+			cfg.Timeout = time.Second
+			cfg.SyncConsume = syncConsume
+			batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), consumertest.NewNop(), cfg)
+			require.NoError(b, err)
+			require.NoError(b, batcher.Start(context.Background(), componenttest.NewNopHost()))
+			tds := make([]ptrace.Traces, b.N)
+			for n := range tds {
+				tds[n] = testdata.GenerateTraces(10)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				require.NoError(b, batcher.ConsumeTraces(context.Background(), tds[n]))
+			}
+			b.StopTimer()
+			require.NoError(b, batcher.Shutdown(context.Background()))
+		})
+	}
+}
+
+// BenchmarkBatchProcessorConsumeLarge consumes requests of thousands of
+// spans, each in a resource of its own so that counting them walks
+// every resource.  They are counted once when accepted, even with an
+// in-flight budget.
+func BenchmarkBatchProcessorConsumeLarge(b *testing.B) {
+	for _, spansPerRequest := range []int{1024, 2048} {
+		b.Run(fmt.Sprintf("spans=%d", spansPerRequest), func(b *testing.B) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.SendBatchSize = uint32(spansPerRequest)
+			cfg.Timeout = time.Second
+			cfg.SyncConsume = true
+			cfg.MaxInFlightItems = uint64(4 * spansPerRequest)
+			batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), consumertest.NewNop(), cfg)
+			require.NoError(b, err)
+			require.NoError(b, batcher.Start(context.Background(), componenttest.NewNopHost()))
+			tds := make([]ptrace.Traces, b.N)
+			for n := range tds {
+				tds[n] = ptrace.NewTraces()
+				tds[n].ResourceSpans().EnsureCapacity(spansPerRequest)
+				for i := 0; i < spansPerRequest; i++ {
+					testdata.GenerateTraces(1).ResourceSpans().MoveAndAppendTo(tds[n].ResourceSpans())
+				}
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				require.NoError(b, batcher.ConsumeTraces(context.Background(), tds[n]))
+			}
+			b.StopTimer()
+			require.NoError(b, batcher.Shutdown(context.Background()))
+		})
+	}
+}
+
+// countingTraces is a batch recording the counts it was given, to check
+// that they match the data.
+type countingTraces struct {
+	*batchTraces
+	counts []int
+}
+
+func (ct *countingTraces) Add(item any, n int) {
+	ct.counts = append(ct.counts, n)
+	ct.batchTraces.Add(item, n)
+}
+
+func TestBatchProcessorCountsOnce(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 100
+	cfg.MaxInFlightItems = 1000
+	cfg.SyncConsume = true
+	sink := new(consumertest.TracesSink)
+	ct := &countingTraces{batchTraces: newBatchTraces(sink)}
+	s, err := newSettings(component.DataTypeTraces, cfg, factoryOptions{})
+	require.NoError(t, err)
+	s.NewBatch = func(bool) batching.Batch { return ct }
+	p, err := batching.NewProcessor(processortest.NewNopCreateSettings(), cfg, s)
+	require.NoError(t, err)
+	bp := &batchProcessor{Processor: p}
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+
+	for _, spans := range []int{3, 0, 40} {
+		require.NoError(t, bp.ConsumeTraces(context.Background(), testdata.GenerateTraces(spans)))
+	}
+	assert.Equal(t, []int{3, 0, 40}, ct.counts)
+	assert.Equal(t, 43, ct.ItemCount())
+	require.NoError(t, bp.Shutdown(context.Background()))
+	assert.Equal(t, 43, sink.SpanCount())
+}
+
+// benchmarkBatchTracesAdd adds requests of spansPerRequest spans to a
+// batch until it holds batchSize spans, then exports it.
+func benchmarkBatchTracesAdd(b *testing.B, batchSize, spansPerRequest int, reuse bool) {
+	bt := newBatchTraces(consumertest.NewNop())
+	bt.reuse = reuse
+	requests := make([]ptrace.Traces, batchSize/spansPerRequest)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		for i := range requests {
+			requests[i] = testdata.GenerateTraces(spansPerRequest)
+		}
+		b.StartTimer()
+		for _, td := range requests {
+			bt.Add(td, spansPerRequest)
+		}
+		_, _, _, err := bt.Export(context.Background(), 0)
+		require.NoError(b, err)
+	}
+}
+
+func BenchmarkBatchTracesAddSmall(b *testing.B) {
+	benchmarkBatchTracesAdd(b, 8192, 1, false)
+}
+
+func BenchmarkBatchTracesAddLarge(b *testing.B) {
+	benchmarkBatchTracesAdd(b, 8192, 1024, false)
+}
+
+func BenchmarkBatchTracesAddSmallReuse(b *testing.B) {
+	benchmarkBatchTracesAdd(b, 8192, 1, true)
+}
+
+// benchmarkBatchTracesCopy batches requests shared with another
+// consumer, either cloned upstream for the processor to move, as a
+// fan-out does for a consumer mutating data, or copied by the processor.
+func benchmarkBatchTracesCopy(b *testing.B, copyItems bool) {
+	bt := newBatchTraces(consumertest.NewNop())
+	bt.copyItems = copyItems
+	requests := make([]ptrace.Traces, 100)
+	for i := range requests {
+		requests[i] = testdata.GenerateTraces(10)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, td := range requests {
+			if !copyItems {
+				clone := ptrace.NewTraces()
+				td.CopyTo(clone)
+				td = clone
+			}
+			bt.Add(td, 10)
+		}
+		_, _, _, err := bt.Export(context.Background(), 0)
+		require.NoError(b, err)
+	}
+}
+
+func BenchmarkBatchTracesUpstreamClone(b *testing.B) {
+	benchmarkBatchTracesCopy(b, false)
+}
+
+func BenchmarkBatchTracesCopyItems(b *testing.B) {
+	benchmarkBatchTracesCopy(b, true)
+}
+
+func TestBatchProcessorMutatesDataFalse(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 4
+	cfg.ResourceAttributeKeys = []string{"tenant"}
+	mutatesData := false
+	cfg.MutatesData = &mutatesData
+
+	// Two processors sharing the same requests, as behind a fan-out
+	// to consumers that do not mutate data.
+	sinks := []*consumertest.TracesSink{new(consumertest.TracesSink), new(consumertest.TracesSink)}
+	var processors []*batchProcessor
+	for _, sink := range sinks {
+		bp, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+		require.NoError(t, err)
+		assert.False(t, bp.Capabilities().MutatesData)
+		require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+		processors = append(processors, bp)
+	}
+
+	var requests, expected []ptrace.Traces
+	for _, tenants := range [][]string{{"a"}, {"a", "b"}, {"b", "a", "b"}} {
+		td := ptrace.NewTraces()
+		for _, tenant := range tenants {
+			rs := testdata.GenerateTraces(1).ResourceSpans().At(0)
+			rs.Resource().Attributes().PutStr("tenant", tenant)
+			rs.MoveTo(td.ResourceSpans().AppendEmpty())
+		}
+		clone := ptrace.NewTraces()
+		td.CopyTo(clone)
+		requests = append(requests, td)
+		expected = append(expected, clone)
+	}
+	for _, td := range requests {
+		for _, bp := range processors {
+			require.NoError(t, bp.ConsumeTraces(context.Background(), td))
+		}
+	}
+	for _, bp := range processors {
+		require.NoError(t, bp.Shutdown(context.Background()))
+	}
+
+	// The requests are left unchanged, and both pipelines get them.
+	assert.Equal(t, expected, requests)
+	for _, sink := range sinks {
+		assert.Equal(t, 6, sink.SpanCount())
+	}
+}
+
+func TestBatchTracesSplitChunks(t *testing.T) {
+	bt := newBatchTraces(consumertest.NewNop())
+	var expected []string
+	add := func(n int) {
+		td := testdata.GenerateTraces(n)
+		spans := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+		for i := 0; i < spans.Len(); i++ {
+			spans.At(i).SetName(fmt.Sprint(len(expected)))
+			expected = append(expected, spans.At(i).Name())
+		}
+		bt.Add(td, n)
+	}
+	add(4)
+	add(6)
+
+	// Data added between the splits is sent after the rest of the
+	// batch.
+	var names []string
+	for i := 0; i < 5; i++ {
+		if i == 2 {
+			add(5)
+		}
+		req, sent, _, err := bt.Export(context.Background(), 3)
+		require.NoError(t, err)
+		assert.Equal(t, 3, sent)
+		spans := req.(ptrace.Traces).ResourceSpans()
+		for j := 0; j < spans.Len(); j++ {
+			for k := 0; k < spans.At(j).ScopeSpans().At(0).Spans().Len(); k++ {
+				names = append(names, spans.At(j).ScopeSpans().At(0).Spans().At(k).Name())
+			}
+		}
+	}
+	assert.Equal(t, expected, names)
+	assert.Equal(t, 0, bt.ItemCount())
+}
+
+func TestBatchTracesCopyItems(t *testing.T) {
+	for _, compact := range []bool{false, true} {
+		bt := newBatchTraces(consumertest.NewNop())
+		bt.copyItems = true
+		bt.compact = compact
+		td := testdata.GenerateTraces(3)
+		expected := ptrace.NewTraces()
+		td.CopyTo(expected)
+		bt.Add(td, 3)
+		bt.Add(td, 3)
+		assert.Equal(t, expected, td)
+		assert.Equal(t, 6, bt.traceData.SpanCount())
+	}
+}
+
+func TestBatchProcessorMutatesDataDefault(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	bp, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+	assert.True(t, bp.Capabilities().MutatesData)
+}
+
+func TestBatchTracesAddPreallocates(t *testing.T) {
+	bt := newBatchTraces(consumertest.NewNop())
+	for i := 0; i < 10; i++ {
+		bt.Add(testdata.GenerateTraces(1), 1)
+	}
+	_, _, _, err := bt.Export(context.Background(), 0)
+	require.NoError(t, err)
+
+	// The next batch is allocated for as many resources, with the
+	// same content as without the hint.
+	for i := 0; i < 3; i++ {
+		bt.Add(testdata.GenerateTraces(2), 2)
+	}
+	assert.Equal(t, 6, bt.ItemCount())
+	assert.Equal(t, 3, bt.traceData.ResourceSpans().Len())
+	expected := ptrace.NewTraces()
+	for i := 0; i < 3; i++ {
+		testdata.GenerateTraces(2).ResourceSpans().MoveAndAppendTo(expected.ResourceSpans())
+	}
+	assert.Equal(t, expected, bt.traceData)
+}
+
+// aliasingTracesSink adds data to the batch being exported while the
+// export is in progress, recording the span counts of the requests.
+type aliasingTracesSink struct {
+	consumertest.TracesSink
+	bt     *batchTraces
+	counts [][2]int
+}
+
+func (s *aliasingTracesSink) ConsumeTraces(_ context.Context, td ptrace.Traces) error {
+	before := td.SpanCount()
+	s.bt.Add(testdata.GenerateTraces(1), 1)
+	s.counts = append(s.counts, [2]int{before, td.SpanCount()})
+	return nil
+}
+
+func TestBatchTracesReuseNoAliasing(t *testing.T) {
+	sink := new(aliasingTracesSink)
+	bt := newBatchTraces(sink)
+	bt.reuse = true
+	sink.bt = bt
+
+	bt.Add(testdata.GenerateTraces(3), 3)
+	for i := 0; i < 3; i++ {
+		_, _, _, err := bt.Export(context.Background(), 0)
+		require.NoError(t, err)
+	}
+	// The data added during each export goes to the next batch, not
+	// to the request being exported.
+	assert.Equal(t, [][2]int{{3, 3}, {1, 1}, {1, 1}}, sink.counts)
+	assert.Equal(t, 1, bt.ItemCount())
+	assert.Equal(t, 1, bt.traceData.SpanCount())
+}
+
+// splitOverhead returns the size of the parts of a request of one
+// resource that are sent twice when it is split: the resource and
+// scope, and for metrics the metric, without their items, along with
+// room for their length prefixes to grow.
+func splitOverhead(size int) int {
+	return size + 2*binary.MaxVarintLen32
+}
+
+func TestBatchTracesTrackBytes(t *testing.T) {
+	sizer := &ptrace.ProtoMarshaler{}
+	empty := testdata.GenerateTraces(1)
+	empty.ResourceSpans().At(0).ScopeSpans().At(0).Spans().RemoveIf(func(ptrace.Span) bool { return true })
+	overhead := splitOverhead(sizer.TracesSize(empty))
+
+	bt := newBatchTraces(consumertest.NewNop())
+	bt.trackBytes = true
+	expected := 0
+	for _, spans := range []int{3, 10, 1, 6} {
+		td := testdata.GenerateTraces(spans)
+		expected += sizer.TracesSize(td)
+		bt.Add(td, spans)
+	}
+	// The sizes of the requests add up to the size of the batch.
+	assert.Equal(t, expected, bt.ByteSize())
+	assert.Equal(t, sizer.TracesSize(bt.traceData), bt.ByteSize())
+
+	splits := 0
+	for bt.ItemCount() > 0 {
+		whole := bt.ItemCount() <= 7
+		req, _, bytes, err := bt.Export(context.Background(), 7)
+		require.NoError(t, err)
+		actual := sizer.TracesSize(req.(ptrace.Traces))
+		if !whole {
+			// The part split off is measured.
+			assert.Equal(t, actual, bytes)
+			splits++
+			continue
+		}
+		assert.LessOrEqual(t, bytes, actual)
+		assert.LessOrEqual(t, actual-bytes, splits*overhead)
+	}
+	assert.Equal(t, 2, splits)
+	assert.Zero(t, bt.ByteSize())
+
+	// Requeued data is measured again.
+	td := testdata.GenerateTraces(4)
+	size := sizer.TracesSize(td)
+	bt.Requeue(td)
+	assert.Equal(t, size, bt.ByteSize())
+}
+
+func TestBatchMetricsTrackBytes(t *testing.T) {
+	sizer := &pmetric.ProtoMarshaler{}
+	empty := testdata.GenerateMetrics(1)
+	empty.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().RemoveIf(func(pmetric.NumberDataPoint) bool { return true })
+	overhead := splitOverhead(sizer.MetricsSize(empty))
+
+	bm := newBatchMetrics(consumertest.NewNop())
+	bm.trackBytes = true
+	expected := 0
+	for _, metrics := range []int{3, 10, 1, 6} {
+		md := testdata.GenerateMetrics(metrics)
+		expected += sizer.MetricsSize(md)
+		bm.Add(md, md.DataPointCount())
+	}
+	assert.Equal(t, expected, bm.ByteSize())
+	assert.Equal(t, sizer.MetricsSize(bm.metricData), bm.ByteSize())
+
+	splits := 0
+	for bm.ItemCount() > 0 {
+		whole := bm.ItemCount() <= 7
+		req, _, bytes, err := bm.Export(context.Background(), 7)
+		require.NoError(t, err)
+		actual := sizer.MetricsSize(req.(pmetric.Metrics))
+		if !whole {
+			assert.Equal(t, actual, bytes)
+			splits++
+			continue
+		}
+		assert.LessOrEqual(t, bytes, actual)
+		assert.LessOrEqual(t, actual-bytes, splits*overhead)
+	}
+	assert.NotZero(t, splits)
+	assert.Zero(t, bm.ByteSize())
+}
+
+func TestBatchLogsTrackBytes(t *testing.T) {
+	sizer := &plog.ProtoMarshaler{}
+	empty := testdata.GenerateLogs(1)
+	empty.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().RemoveIf(func(plog.LogRecord) bool { return true })
+	overhead := splitOverhead(sizer.LogsSize(empty))
+
+	bl := newBatchLogs(consumertest.NewNop())
+	bl.trackBytes = true
+	expected := 0
+	for _, logs := range []int{3, 10, 1, 6} {
+		ld := testdata.GenerateLogs(logs)
+		expected += sizer.LogsSize(ld)
+		bl.Add(ld, logs)
+	}
+	assert.Equal(t, expected, bl.ByteSize())
+	assert.Equal(t, sizer.LogsSize(bl.logData), bl.ByteSize())
+
+	splits := 0
+	for bl.ItemCount() > 0 {
+		whole := bl.ItemCount() <= 7
+		req, _, bytes, err := bl.Export(context.Background(), 7)
+		require.NoError(t, err)
+		actual := sizer.LogsSize(req.(plog.Logs))
+		if !whole {
+			assert.Equal(t, actual, bytes)
+			splits++
+			continue
+		}
+		assert.LessOrEqual(t, bytes, actual)
+		assert.LessOrEqual(t, actual-bytes, splits*overhead)
+	}
+	assert.NotZero(t, splits)
+	assert.Zero(t, bl.ByteSize())
+}
+
+func TestBatchProcessorSplitAtResource(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	set := processortest.NewNopCreateSettings()
+	set.Logger = zap.New(core)
+	sink := new(consumertest.LogsSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 4
+	cfg.SendBatchMaxSize = 4
+	cfg.SplitAt = batching.SplitAtResource
+	cfg.SyncConsume = true
+	bp, err := newBatchLogsProcessor(set, sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+
+	ld := plog.NewLogs()
+	for _, records := range []int{3, 2, 6} {
+		testdata.GenerateLogs(records).ResourceLogs().MoveAndAppendTo(ld.ResourceLogs())
+	}
+	require.NoError(t, bp.ConsumeLogs(context.Background(), ld))
+	require.NoError(t, bp.Shutdown(context.Background()))
+
+	// No resource is split across requests.
+	var counts []int
+	for _, ld := range sink.AllLogs() {
+		require.Equal(t, 1, ld.ResourceLogs().Len())
+		counts = append(counts, ld.LogRecordCount())
+	}
+	assert.Equal(t, []int{3, 2, 6}, counts)
+	warnings := logs.FilterMessage("Sent a resource larger than max_size in a request of its own").All()
+	require.Len(t, warnings, 1)
+	assert.Equal(t, int64(6), warnings[0].ContextMap()["items"])
+}
+
+func TestBatchProcessorSplitAtScope(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	set := processortest.NewNopCreateSettings()
+	set.Logger = zap.New(core)
+	sink := new(consumertest.LogsSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 4
+	cfg.SendBatchMaxSize = 4
+	cfg.SplitAt = batching.SplitAtScope
+	cfg.SyncConsume = true
+	bp, err := newBatchLogsProcessor(set, sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	for _, records := range []int{3, 2, 6} {
+		testdata.GenerateLogs(records).ResourceLogs().At(0).ScopeLogs().MoveAndAppendTo(rl.ScopeLogs())
+	}
+	require.NoError(t, bp.ConsumeLogs(context.Background(), ld))
+	require.NoError(t, bp.Shutdown(context.Background()))
+
+	// No scope is split across requests.
+	var counts []int
+	for _, ld := range sink.AllLogs() {
+		require.Equal(t, 1, ld.ResourceLogs().Len())
+		require.Equal(t, 1, ld.ResourceLogs().At(0).ScopeLogs().Len())
+		counts = append(counts, ld.LogRecordCount())
+	}
+	assert.Equal(t, []int{3, 2, 6}, counts)
+	warnings := logs.FilterMessage("Sent a scope larger than max_size in a request of its own").All()
+	require.Len(t, warnings, 1)
+	assert.Equal(t, int64(6), warnings[0].ContextMap()["items"])
+}
+
+func TestBatchProcessorSplitRemovesEmpty(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		splitMode string
+		splitAt   string
+	}{
+		{name: "item", splitMode: batching.SplitModeAny, splitAt: batching.SplitAtItem},
+		{name: "trace", splitMode: batching.SplitModeTrace, splitAt: batching.SplitAtItem},
+		{name: "resource", splitMode: batching.SplitModeAny, splitAt: batching.SplitAtResource},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := new(consumertest.TracesSink)
+			cfg := createDefaultConfig().(*Config)
+			cfg.SendBatchSize = 2
+			cfg.SendBatchMaxSize = 2
+			cfg.SplitMode = tt.splitMode
+			cfg.SplitAt = tt.splitAt
+			cfg.SyncConsume = true
+			bp, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+			require.NoError(t, err)
+			require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+
+			// Empty resources and scopes around the spans.
+			for i := 0; i < 2; i++ {
+				td := ptrace.NewTraces()
+				td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty()
+				rs := td.ResourceSpans().AppendEmpty()
+				rs.ScopeSpans().AppendEmpty()
+				testdata.GenerateTraces(5).ResourceSpans().At(0).ScopeSpans().MoveAndAppendTo(rs.ScopeSpans())
+				rs.ScopeSpans().AppendEmpty()
+				td.ResourceSpans().AppendEmpty()
+				require.NoError(t, bp.ConsumeTraces(context.Background(), td))
+			}
+			require.NoError(t, bp.Shutdown(context.Background()))
+
+			assert.Equal(t, 10, sink.SpanCount())
+			for _, td := range sink.AllTraces() {
+				rss := td.ResourceSpans()
+				for i := 0; i < rss.Len(); i++ {
+					sss := rss.At(i).ScopeSpans()
+					require.NotZero(t, sss.Len())
+					for j := 0; j < sss.Len(); j++ {
+						require.NotZero(t, sss.At(j).Spans().Len())
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBatchProcessorFlushOnResourceChange(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := new(consumertest.LogsSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 100
+	cfg.Timeout = time.Hour
+	cfg.FlushOnResourceChange = true
+	cfg.SyncConsume = true
+	bp, err := newBatchLogsProcessor(tel.NewProcessorCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+
+	request := func(hosts ...string) plog.Logs {
+		ld := plog.NewLogs()
+		for _, host := range hosts {
+			rl := ld.ResourceLogs().AppendEmpty()
+			rl.Resource().Attributes().PutStr("host.name", host)
+			rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+		}
+		return ld
+	}
+	for _, ld := range []plog.Logs{
+		request("a"),
+		request("a"),
+		request("b"),
+		// The same set of resources, in any order.
+		request("a", "b"),
+		request("b", "a", "b"),
+		request("a"),
+	} {
+		require.NoError(t, bp.ConsumeLogs(context.Background(), ld))
+	}
+	require.NoError(t, bp.Shutdown(context.Background()))
+
+	var counts []int
+	for _, ld := range sink.AllLogs() {
+		counts = append(counts, ld.LogRecordCount())
+	}
+	assert.Equal(t, []int{2, 1, 5, 1}, counts)
+	tel.assertMetrics(t, expectedMetrics{
+		sendCount:             4,
+		sendSizeSum:           9,
+		resourceChangeTrigger: 3,
+		shutdownTrigger:       1,
+	})
+}
+
+func TestBatchProcessorSendLatency(t *testing.T) {
+	tel := setupTelemetry(t)
+	next := &errorsTracesSink{errs: []error{nil, nil, errors.New("export failed")}}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 2
+	cfg.Timeout = time.Hour
+	cfg.SyncConsume = true
+	creationSet := tel.NewProcessorCreateSettings()
+	creationSet.MetricsLevel = configtelemetry.LevelNormal
+	bp, err := newBatchTracesProcessor(creationSet, next, cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, bp.ConsumeTraces(context.Background(), testdata.GenerateTraces(2)))
+	require.NoError(t, bp.ConsumeTraces(context.Background(), testdata.GenerateTraces(2)))
+	assert.Error(t, bp.ConsumeTraces(context.Background(), testdata.GenerateTraces(2)))
+	require.NoError(t, bp.Shutdown(context.Background()))
+
+	assert.Equal(t, map[[3]string]uint64{
+		{"traces", "batch_size", "success"}: 2,
+		{"traces", "batch_size", "failure"}: 1,
+	}, tel.sendLatencyCounts(t))
+}
+
+func TestBatchProcessorSendLatencyBasicLevel(t *testing.T) {
+	tel := setupTelemetry(t)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 2
+	creationSet := tel.NewProcessorCreateSettings()
+	creationSet.MetricsLevel = configtelemetry.LevelBasic
+	bp, err := newBatchTracesProcessor(creationSet, consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, bp.ConsumeTraces(context.Background(), testdata.GenerateTraces(2)))
+	require.NoError(t, bp.Shutdown(context.Background()))
+
+	assert.Empty(t, tel.sendLatencyCounts(t))
+	assert.Empty(t, tel.batchAges(t))
+}
+
+func TestBatchProcessorSplitMetrics(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := new(consumertest.TracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 4
+	cfg.SendBatchMaxSize = 6
+	cfg.Timeout = time.Hour
+	cfg.SyncConsume = true
+	batcher, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	// 10 spans are split into 6 and 4, sent at once, then 5 spans are
+	// sent whole.
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(10)))
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(5)))
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, []int{6, 4, 5}, spanCounts(sink))
+
+	tel.assertMetrics(t, expectedMetrics{
+		sendCount:          3,
+		sendSizeSum:        15,
+		sizeTrigger:        3,
+		sizeSplit:          map[string]float64{"traces": 1},
+		splitLeftoverItems: map[string]float64{"traces": 4},
+	})
+}
+
+func TestBatchProcessorItemsReceived(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := new(consumertest.LogsSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = time.Hour
+	cfg.MaxInFlightItems = 5
+	batcher, err := newBatchLogsProcessor(tel.NewProcessorCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, batcher.ConsumeLogs(context.Background(), testdata.GenerateLogs(4)))
+	// Beyond max_in_flight_items, the request is refused and not
+	// counted.
+	assert.ErrorIs(t, batcher.ConsumeLogs(context.Background(), testdata.GenerateLogs(3)), batching.ErrInFlightLimit)
+	require.NoError(t, batcher.ConsumeLogs(context.Background(), testdata.GenerateLogs(1)))
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, 5, sink.LogRecordCount())
+
+	tel.assertMetrics(t, expectedMetrics{
+		sendCount:       1,
+		sendSizeSum:     5,
+		shutdownTrigger: 1,
+		receivedItems:   map[string]float64{"logs": 5},
+	})
 }
@@ -0,0 +1,42 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor // import "go.opentelemetry.io/collector/processor/batchprocessor"
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/processor/batchprocessor/batching"
+)
+
+// BatchTrigger is the reason a batch was exported.
+type BatchTrigger = batching.BatchTrigger
+
+// The reasons a batch is exported, see batching.BatchTrigger.
+const (
+	BatchTriggerTimeout        = batching.BatchTriggerTimeout
+	BatchTriggerSize           = batching.BatchTriggerSize
+	BatchTriggerBypass         = batching.BatchTriggerBypass
+	BatchTriggerResourceChange = batching.BatchTriggerResourceChange
+	BatchTriggerShutdown       = batching.BatchTriggerShutdown
+)
+
+// BatchInfo describes an export of the batch processor.
+type BatchInfo = batching.BatchInfo
+
+// BatchInfoFromContext returns the BatchInfo of the export a consumer
+// of the batch processor is called for, and whether ctx has one.
+func BatchInfoFromContext(ctx context.Context) (BatchInfo, bool) {
+	return batching.BatchInfoFromContext(ctx)
+}
@@ -0,0 +1,130 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/internal/testdata"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+// batchInfoTracesSink records the BatchInfo of every request, failing
+// t for a request without one.
+type batchInfoTracesSink struct {
+	consumertest.TracesSink
+	t *testing.T
+
+	lock  sync.Mutex
+	infos []BatchInfo
+}
+
+func (bts *batchInfoTracesSink) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	info, ok := BatchInfoFromContext(ctx)
+	if !ok {
+		bts.t.Errorf("missing batch info")
+		return errors.New("missing batch info")
+	}
+	bts.lock.Lock()
+	bts.infos = append(bts.infos, info)
+	bts.lock.Unlock()
+	return bts.TracesSink.ConsumeTraces(ctx, td)
+}
+
+func TestBatchInfoFromContextMissing(t *testing.T) {
+	_, ok := BatchInfoFromContext(context.Background())
+	assert.False(t, ok)
+}
+
+func TestBatchInfoSequences(t *testing.T) {
+	sink := &batchInfoTracesSink{t: t}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 7
+	cfg.SendBatchMaxSize = 7
+	cfg.Timeout = 10 * time.Millisecond
+	cfg.MetadataKeys = []string{"tenant"}
+	bp, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+
+	tenants := []string{"a", "b", "c"}
+	var wg sync.WaitGroup
+	for _, tenant := range tenants {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"tenant": {tenant}}),
+		})
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					assert.NoError(t, bp.ConsumeTraces(ctx, testdata.GenerateTraces(j%10+1)))
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	require.NoError(t, bp.Shutdown(context.Background()))
+
+	sequences := map[uint64][]uint64{}
+	for _, info := range sink.infos {
+		sequences[info.BatcherID] = append(sequences[info.BatcherID], info.Sequence)
+	}
+	require.Len(t, sequences, len(tenants))
+	for _, seq := range sequences {
+		for i, s := range seq {
+			require.Equal(t, uint64(i+1), s)
+		}
+	}
+	assert.Equal(t, 4*len(tenants)*275, sink.SpanCount())
+}
+
+func TestBatchInfoTrigger(t *testing.T) {
+	sink := &batchInfoTracesSink{t: t}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 3
+	cfg.Timeout = 10 * time.Millisecond
+	bp, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, bp.ConsumeTraces(context.Background(), testdata.GenerateTraces(3)))
+	require.NoError(t, bp.ConsumeTraces(context.Background(), testdata.GenerateTraces(1)))
+	require.Eventually(t, func() bool {
+		return sink.SpanCount() == 4
+	}, time.Second, 5*time.Millisecond)
+	require.Len(t, sink.infos, 2)
+	id := sink.infos[0].BatcherID
+	require.NoError(t, bp.Shutdown(context.Background()))
+
+	assert.Equal(t, []BatchInfo{
+		{BatcherID: id, Sequence: 1, Trigger: BatchTriggerSize},
+		{BatcherID: id, Sequence: 2, Trigger: BatchTriggerTimeout},
+	}, sink.infos)
+	assert.Equal(t, "batch_size", BatchTriggerSize.String())
+	assert.Equal(t, "resource_change", BatchTriggerResourceChange.String())
+	assert.Equal(t, "shutdown", BatchTriggerShutdown.String())
+}
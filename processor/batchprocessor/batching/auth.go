@@ -0,0 +1,83 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching // import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"go.opentelemetry.io/collector/client"
+)
+
+// authAttrPrefix distinguishes auth attribute keys from metadata keys
+// in the attribute set identifying a batcher.
+const authAttrPrefix = "auth:"
+
+// batcherAuthData is the client.AuthData placed in the export context
+// of a batcher, holding the configured auth attributes shared by all
+// data in the batcher.
+type batcherAuthData struct {
+	attrs map[string]any
+}
+
+var _ client.AuthData = (*batcherAuthData)(nil)
+
+func (a *batcherAuthData) GetAttribute(name string) any {
+	return a.attrs[name]
+}
+
+func (a *batcherAuthData) GetAttributeNames() []string {
+	names := make([]string, 0, len(a.attrs))
+	for name := range a.attrs {
+		names = append(names, name)
+	}
+	return names
+}
+
+// authKeyValues returns the values of keys in auth for use in the
+// attribute set identifying a batcher, along with the auth data for
+// the export context.  Missing attributes, including when auth is nil
+// for unauthenticated requests, are represented by an empty value, and
+// the returned auth data is nil when no attribute is present.
+func authKeyValues(keys []string, auth client.AuthData) ([]attribute.KeyValue, client.AuthData) {
+	kvs := make([]attribute.KeyValue, len(keys))
+	var attrs map[string]any
+	for i, k := range keys {
+		var v any
+		if auth != nil {
+			v = auth.GetAttribute(k)
+		}
+		switch tv := v.(type) {
+		case nil:
+			kvs[i] = attribute.StringSlice(authAttrPrefix+k, nil)
+			continue
+		case string:
+			kvs[i] = attribute.String(authAttrPrefix+k, tv)
+		case []string:
+			kvs[i] = attribute.StringSlice(authAttrPrefix+k, tv)
+		default:
+			kvs[i] = attribute.String(authAttrPrefix+k, fmt.Sprint(tv))
+		}
+		if attrs == nil {
+			attrs = map[string]any{}
+		}
+		attrs[k] = v
+	}
+	if attrs == nil {
+		return kvs, nil
+	}
+	return kvs, &batcherAuthData{attrs: attrs}
+}
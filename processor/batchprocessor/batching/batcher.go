@@ -0,0 +1,243 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching // import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/processor"
+)
+
+// BatchSettings defines how a Batcher handles requests of type T.
+type BatchSettings[T any] struct {
+	// DataType names the data in logs, usually the signal of the
+	// exporter.  Defaults to "batch".
+	DataType component.DataType
+
+	// New returns an empty request, to which requests are merged.
+	New func() T
+
+	// Count returns the number of items of a request, compared to
+	// min_size and max_size.
+	Count func(req T) int
+
+	// Merge adds the items of src to dst and returns the result.
+	// src is no longer used afterwards.
+	Merge func(dst, src T) T
+
+	// Split removes a request of size items from the front of data,
+	// returning it and the remainder.  It is required when
+	// max_size is set.
+	Split func(size int, data T) (head, rest T)
+
+	// Size returns the size in bytes of a request, reported by the
+	// telemetry.  Optional, sizes are reported as zero without it.
+	Size func(req T) int
+
+	// Export sends a batch.
+	Export func(ctx context.Context, req T) error
+
+	// MetadataTransformer is applied to the values of every metadata
+	// key, nil when not set.
+	MetadataTransformer MetadataTransformer
+}
+
+// Batcher batches requests of type T with the triggers, metadata
+// partitioning, splitting, and telemetry of the batch processor, for
+// exporters to batch in front of their sender.  It is configured like
+// the batch processor, except for the settings specific to pdata.
+type Batcher[T any] struct {
+	p     *Processor
+	count func(req T) int
+	split bool
+}
+
+// NewBatcher returns a Batcher exporting the batches of requests added
+// with bs.Export.  The telemetry is reported as that of a batch
+// processor with the id of set.  cfg is validated, and rejected when it
+// sets resource_attribute_keys, group_by resources, bypass,
+// persistence, dead_letter_exporter, compact, merge_data_points,
+// split_mode, split_at, or flush_on_resource_change.
+func NewBatcher[T any](set processor.CreateSettings, cfg *Config, bs BatchSettings[T]) (*Batcher[T], error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if name := cfg.pdataSetting(); name != "" {
+		return nil, fmt.Errorf("%s is not supported by the batcher", name)
+	}
+	if bs.New == nil || bs.Count == nil || bs.Merge == nil || bs.Export == nil {
+		return nil, errors.New("the batcher requires New, Count, Merge and Export")
+	}
+	dataType := bs.DataType
+	if dataType == "" {
+		dataType = "batch"
+	}
+	if cfg.forSignal(dataType).SendBatchMaxSize > 0 && bs.Split == nil {
+		return nil, errors.New("max_size requires Split")
+	}
+	if (cfg.SendBatchSizeBytes > 0 || cfg.SendBatchMaxSizeBytes > 0) && bs.Size == nil {
+		return nil, errors.New("min_size_bytes and max_size_bytes require Size")
+	}
+	s := Settings{
+		DataType: dataType,
+		NewBatch: func(trackBytes bool) Batch {
+			return &genericBatch[T]{settings: bs, data: bs.New(), trackBytes: trackBytes && bs.Size != nil}
+		},
+		Count:               func(item any) int { return bs.Count(item.(T)) },
+		MetadataTransformer: bs.MetadataTransformer,
+	}
+	if bs.Size != nil {
+		s.Size = func(item any) int { return bs.Size(item.(T)) }
+	}
+	p, err := NewProcessor(set, cfg, s)
+	if err != nil {
+		return nil, err
+	}
+	return &Batcher[T]{p: p, count: bs.Count, split: bs.Split != nil}, nil
+}
+
+// Start starts the batcher.
+func (b *Batcher[T]) Start(ctx context.Context, host component.Host) error {
+	return b.p.Start(ctx, host)
+}
+
+// Shutdown flushes the pending batches and stops the batcher, like the
+// batch processor.
+func (b *Batcher[T]) Shutdown(ctx context.Context) error {
+	return b.p.Shutdown(ctx)
+}
+
+// Add hands req to its batch, which then owns it.  It returns as the
+// batch processor consuming a request, with the export result when
+// error_mode is "propagate" or sync_consume is set.
+func (b *Batcher[T]) Add(ctx context.Context, req T) error {
+	return b.p.Consume(ctx, req, b.count(req))
+}
+
+// Snapshot implements Introspector.
+func (b *Batcher[T]) Snapshot() Snapshot {
+	return b.p.Snapshot()
+}
+
+// pdataSetting returns the name of the first setting of cfg that only
+// applies to pdata, empty when none is set.
+func (cfg *Config) pdataSetting() string {
+	switch {
+	case len(cfg.resourceAttributeKeys()) != 0:
+		return "resource_attribute_keys"
+	case cfg.Bypass.hasRules():
+		return "bypass"
+	case cfg.Persistence.Directory != "":
+		return "persistence"
+	case cfg.DeadLetterExporter != nil:
+		return "dead_letter_exporter"
+	case cfg.Compact:
+		return "compact"
+	case cfg.MergeDataPoints:
+		return "merge_data_points"
+	case cfg.SplitMode != "" && cfg.SplitMode != SplitModeAny:
+		return "split_mode"
+	case cfg.SplitAt != "" && cfg.SplitAt != SplitAtItem:
+		return "split_at"
+	case cfg.FlushOnResourceChange:
+		return "flush_on_resource_change"
+	}
+	return ""
+}
+
+// genericBatch is the batch of a Batcher.
+type genericBatch[T any] struct {
+	settings BatchSettings[T]
+	data     T
+	count    int
+
+	// trackBytes is set when the size in bytes of every export is
+	// needed, so that bytes, the size of the batch, is kept up to
+	// date.
+	trackBytes bool
+	bytes      int
+}
+
+func (gb *genericBatch[T]) Add(item any, n int) {
+	if n == 0 {
+		return
+	}
+	req := item.(T)
+	gb.count += n
+	if gb.trackBytes {
+		gb.bytes += gb.settings.Size(req)
+	}
+	gb.data = gb.settings.Merge(gb.data, req)
+}
+
+func (gb *genericBatch[T]) Requeue(data any) {
+	req := data.(T)
+	gb.count += gb.settings.Count(req)
+	if gb.trackBytes {
+		gb.bytes += gb.settings.Size(req)
+	}
+	gb.data = gb.settings.Merge(req, gb.data)
+}
+
+func (gb *genericBatch[T]) Export(ctx context.Context, sendBatchMaxSize int) (any, int, int, error) {
+	var req T
+	var sent int
+	var bytes int
+	if sendBatchMaxSize > 0 && gb.count > sendBatchMaxSize {
+		req, gb.data = gb.settings.Split(sendBatchMaxSize, gb.data)
+		// Split may not cut exactly sendBatchMaxSize items, e.g. to
+		// keep an item whole.
+		sent = gb.settings.Count(req)
+		gb.count -= sent
+		if gb.trackBytes {
+			bytes = gb.settings.Size(req)
+			gb.bytes -= bytes
+			if gb.bytes < 0 {
+				gb.bytes = 0
+			}
+		}
+	} else {
+		req = gb.data
+		sent = gb.count
+		bytes = gb.bytes
+		gb.data = gb.settings.New()
+		gb.count = 0
+		gb.bytes = 0
+	}
+	return req, sent, bytes, gb.settings.Export(ctx, req)
+}
+
+func (gb *genericBatch[T]) Consume(ctx context.Context, req any) error {
+	return gb.settings.Export(ctx, req.(T))
+}
+
+func (gb *genericBatch[T]) ItemCount() int {
+	return gb.count
+}
+
+func (gb *genericBatch[T]) ByteSize() int {
+	return gb.bytes
+}
+
+// UpdateConfig implements ConfigUpdater.
+func (b *Batcher[T]) UpdateConfig(cfg *Config) error {
+	if cfg.forSignal(b.p.dataType).SendBatchMaxSize > 0 && !b.split {
+		return errors.New("max_size requires Split")
+	}
+	return b.p.UpdateConfig(cfg)
+}
@@ -0,0 +1,232 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+// intsSink records the batches of ints exported by a Batcher.
+type intsSink struct {
+	mu      sync.Mutex
+	batches [][]int
+	tenants []string
+	err     error
+}
+
+func (s *intsSink) settings() BatchSettings[[]int] {
+	return BatchSettings[[]int]{
+		New:   func() []int { return nil },
+		Count: func(req []int) int { return len(req) },
+		Merge: func(dst, src []int) []int { return append(dst, src...) },
+		Split: func(size int, data []int) ([]int, []int) { return data[:size:size], data[size:] },
+		Export: func(ctx context.Context, req []int) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.batches = append(s.batches, req)
+			s.tenants = append(s.tenants, client.FromContext(ctx).Metadata.Get("tenant")...)
+			return s.err
+		},
+	}
+}
+
+func (s *intsSink) exported() [][]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.batches
+}
+
+func TestBatcherSentBySize(t *testing.T) {
+	sink := &intsSink{}
+	cfg := NewDefaultConfig()
+	cfg.SendBatchSize = 4
+	cfg.SendBatchMaxSize = 6
+	cfg.Timeout = time.Hour
+	b, err := NewBatcher(processortest.NewNopCreateSettings(), cfg, sink.settings())
+	require.NoError(t, err)
+	require.NoError(t, b.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, b.Add(context.Background(), []int{1, 2, 3}))
+	require.NoError(t, b.Add(context.Background(), []int{4, 5, 6, 7, 8, 9, 10, 11}))
+	require.NoError(t, b.Add(context.Background(), []int{12}))
+	require.NoError(t, b.Shutdown(context.Background()))
+
+	// The batch of 11 items is split at 6, the remainder is sent on
+	// shutdown with the last item.
+	assert.Equal(t, [][]int{{1, 2, 3, 4, 5, 6}, {7, 8, 9, 10, 11}, {12}}, sink.exported())
+}
+
+func TestBatcherSplitCountsSent(t *testing.T) {
+	sink := &intsSink{}
+	bs := sink.settings()
+	// Split keeps the items in pairs, cutting fewer than size when odd.
+	bs.Split = func(size int, data []int) ([]int, []int) {
+		size -= size % 2
+		return data[:size:size], data[size:]
+	}
+	gb := &genericBatch[[]int]{settings: bs, data: bs.New()}
+	gb.Add([]int{1, 2, 3, 4}, 4)
+
+	req, sent, _, err := gb.Export(context.Background(), 3)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, req)
+	assert.Equal(t, 2, sent)
+	assert.Equal(t, 2, gb.ItemCount())
+}
+
+func TestBatcherSentByTimeout(t *testing.T) {
+	sink := &intsSink{}
+	cfg := NewDefaultConfig()
+	cfg.SendBatchSize = 100
+	cfg.Timeout = 10 * time.Millisecond
+	b, err := NewBatcher(processortest.NewNopCreateSettings(), cfg, sink.settings())
+	require.NoError(t, err)
+	require.NoError(t, b.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, b.Add(context.Background(), []int{1, 2}))
+	require.NoError(t, b.Add(context.Background(), []int{3}))
+	assert.Eventually(t, func() bool { return len(sink.exported()) == 1 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, b.Shutdown(context.Background()))
+
+	assert.Equal(t, [][]int{{1, 2, 3}}, sink.exported())
+}
+
+func TestBatcherMetadataKeys(t *testing.T) {
+	sink := &intsSink{}
+	cfg := NewDefaultConfig()
+	cfg.SendBatchSize = 100
+	cfg.Timeout = time.Hour
+	cfg.MetadataKeys = []string{"tenant"}
+	b, err := NewBatcher(processortest.NewNopCreateSettings(), cfg, sink.settings())
+	require.NoError(t, err)
+	require.NoError(t, b.Start(context.Background(), componenttest.NewNopHost()))
+
+	for i, tenant := range []string{"a", "b", "a"} {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"tenant": {tenant}}),
+		})
+		require.NoError(t, b.Add(ctx, []int{i}))
+	}
+	require.NoError(t, b.Shutdown(context.Background()))
+
+	require.Len(t, sink.batches, 2)
+	byTenant := map[string][]int{}
+	for i, tenant := range sink.tenants {
+		byTenant[tenant] = sink.batches[i]
+	}
+	assert.Equal(t, map[string][]int{"a": {0, 2}, "b": {1}}, byTenant)
+}
+
+func TestBatcherPropagateErrors(t *testing.T) {
+	sink := &intsSink{err: errors.New("export failed")}
+	cfg := NewDefaultConfig()
+	cfg.SendBatchSize = 2
+	cfg.Timeout = time.Hour
+	cfg.ErrorMode = errorModePropagate
+	b, err := NewBatcher(processortest.NewNopCreateSettings(), cfg, sink.settings())
+	require.NoError(t, err)
+	require.NoError(t, b.Start(context.Background(), componenttest.NewNopHost()))
+
+	assert.ErrorIs(t, b.Add(context.Background(), []int{1, 2}), sink.err)
+	require.NoError(t, b.Shutdown(context.Background()))
+	assert.ErrorIs(t, b.Add(context.Background(), []int{3}), ErrShuttingDown)
+}
+
+func TestBatcherSizeBytes(t *testing.T) {
+	sink := &intsSink{}
+	cfg := NewDefaultConfig()
+	cfg.SendBatchSize = 1000
+	cfg.SendBatchSizeBytes = 16
+	cfg.SendBatchMaxSizeBytes = 24
+	cfg.Timeout = 10 * time.Minute
+	cfg.SyncConsume = true
+	bs := sink.settings()
+	bs.Size = func(req []int) int { return 4 * len(req) }
+	b, err := NewBatcher(processortest.NewNopCreateSettings(), cfg, bs)
+	require.NoError(t, err)
+	require.NoError(t, b.Start(context.Background(), componenttest.NewNopHost()))
+
+	for _, req := range [][]int{{1, 2}, {3, 4, 5}, {6, 7, 8, 9}, {10}, {11, 12, 13, 14, 15, 16}} {
+		require.NoError(t, b.Add(context.Background(), req))
+	}
+	require.NoError(t, b.Shutdown(context.Background()))
+
+	// A batch is sent once it reaches 16 bytes, and before a request
+	// would take it past 24 bytes.
+	assert.Equal(t, [][]int{{1, 2, 3, 4, 5}, {6, 7, 8, 9}, {10}, {11, 12, 13, 14, 15, 16}}, sink.exported())
+}
+
+func TestNewBatcherInvalid(t *testing.T) {
+	sink := &intsSink{}
+	tests := []struct {
+		name     string
+		modify   func(cfg *Config, bs *BatchSettings[[]int])
+		expected string
+	}{
+		{
+			name:     "invalid config",
+			modify:   func(cfg *Config, _ *BatchSettings[[]int]) { cfg.SendBatchMaxSize = 1; cfg.SendBatchSize = 2 },
+			expected: "max_size (1) must be greater or equal to min_size (2)",
+		},
+		{
+			name:     "resource keys",
+			modify:   func(cfg *Config, _ *BatchSettings[[]int]) { cfg.ResourceAttributeKeys = []string{"tenant"} },
+			expected: "resource_attribute_keys is not supported by the batcher",
+		},
+		{
+			name:     "compact",
+			modify:   func(cfg *Config, _ *BatchSettings[[]int]) { cfg.Compact = true },
+			expected: "compact is not supported by the batcher",
+		},
+		{
+			name:     "missing merge",
+			modify:   func(_ *Config, bs *BatchSettings[[]int]) { bs.Merge = nil },
+			expected: "the batcher requires New, Count, Merge and Export",
+		},
+		{
+			name: "missing split",
+			modify: func(cfg *Config, bs *BatchSettings[[]int]) {
+				cfg.SendBatchMaxSize = 10000
+				bs.Split = nil
+			},
+			expected: "max_size requires Split",
+		},
+		{
+			name:     "missing size",
+			modify:   func(cfg *Config, _ *BatchSettings[[]int]) { cfg.SendBatchSizeBytes = 100 },
+			expected: "min_size_bytes and max_size_bytes require Size",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewDefaultConfig()
+			bs := sink.settings()
+			tt.modify(cfg, &bs)
+			_, err := NewBatcher(processortest.NewNopCreateSettings(), cfg, bs)
+			assert.EqualError(t, err, tt.expected)
+		})
+	}
+}
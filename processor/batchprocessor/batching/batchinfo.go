@@ -0,0 +1,140 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching // import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// BatchTrigger is the reason a batch was exported.
+type BatchTrigger int
+
+const (
+	// BatchTriggerTimeout is set when the batch was exported because
+	// its timeout expired, or when flushing a batcher removed before
+	// shutdown.
+	BatchTriggerTimeout BatchTrigger = iota
+	// BatchTriggerSize is set when the batch reached min_size.
+	BatchTriggerSize
+	// BatchTriggerBypass is set when the batch was exported by a
+	// bypass rule.
+	BatchTriggerBypass
+	// BatchTriggerResourceChange is set when the batch was exported
+	// before adding data of other resources, with
+	// flush_on_resource_change.
+	BatchTriggerResourceChange
+	// BatchTriggerShutdown is set when the batch was exported when
+	// flushing on shutdown.
+	BatchTriggerShutdown
+)
+
+// String returns the name of the trigger, as used in the processor's
+// metrics.
+func (t BatchTrigger) String() string {
+	switch t {
+	case BatchTriggerTimeout:
+		return "timeout"
+	case BatchTriggerSize:
+		return "batch_size"
+	case BatchTriggerBypass:
+		return "bypass"
+	case BatchTriggerResourceChange:
+		return "resource_change"
+	case BatchTriggerShutdown:
+		return "shutdown"
+	}
+	return "unknown"
+}
+
+// BatchInfo describes an export of the batch processor.
+type BatchInfo struct {
+	// BatcherID identifies the batcher exporting the batch.  It is a
+	// hash of the metadata values the batcher groups, so a batcher
+	// created again for the same values has the same ID.
+	BatcherID uint64
+
+	// Sequence numbers the exports of the batcher, starting at 1.
+	// Every export, including each part of a split batch, takes the
+	// next number, so numbers are contiguous.  They restart only with
+	// the processor.
+	Sequence uint64
+
+	// Trigger is the reason the batch was exported.
+	Trigger BatchTrigger
+}
+
+type batchInfoKey struct{}
+
+// BatchInfoFromContext returns the BatchInfo of the export a consumer
+// of the batch processor is called for, and whether ctx has one.
+func BatchInfoFromContext(ctx context.Context) (BatchInfo, bool) {
+	info, ok := ctx.Value(batchInfoKey{}).(BatchInfo)
+	return info, ok
+}
+
+func contextWithBatchInfo(ctx context.Context, info BatchInfo) context.Context {
+	return context.WithValue(ctx, batchInfoKey{}, info)
+}
+
+// batcherID returns the BatcherID of the batcher identified by key.
+func batcherID(key attribute.Set) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key.Encoded(attribute.DefaultEncoder())))
+	return h.Sum64()
+}
+
+// batchSequences holds the sequence counter of every BatcherID seen by
+// a processor.  The counters are kept when a batcher is removed, so
+// that a batcher created again for the same values, possibly while the
+// removed one is still draining, continues its numbering.
+type batchSequences struct {
+	lock     sync.Mutex
+	counters map[uint64]*atomic.Uint64
+}
+
+// counter returns the sequence counter of id.
+func (s *batchSequences) counter(id uint64) *atomic.Uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	c, ok := s.counters[id]
+	if !ok {
+		if s.counters == nil {
+			s.counters = map[uint64]*atomic.Uint64{}
+		}
+		c = new(atomic.Uint64)
+		s.counters[id] = c
+	}
+	return c
+}
+
+// batchTrigger returns the exported BatchTrigger of t.
+func (t trigger) batchTrigger() BatchTrigger {
+	switch t {
+	case triggerBatchSize:
+		return BatchTriggerSize
+	case triggerBypass:
+		return BatchTriggerBypass
+	case triggerResourceChange:
+		return BatchTriggerResourceChange
+	case triggerShutdown:
+		return BatchTriggerShutdown
+	}
+	return BatchTriggerTimeout
+}
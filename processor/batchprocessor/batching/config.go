@@ -0,0 +1,1035 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching // import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"runtime"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+const (
+	defaultSendBatchSize = uint32(8192)
+	defaultTimeout       = 200 * time.Millisecond
+
+	// defaultMetadataCardinalityLimit should be set to the number
+	// of metadata configurations the user expects to submit to
+	// the collector.
+	defaultMetadataCardinalityLimit = 1000
+
+	// unlimitedMetadataCardinality is the metadata cardinality limit
+	// that lets the processor create any number of batchers.
+	unlimitedMetadataCardinality = 0
+
+	// defaultMetadataCardinalityWarnPercent is the percentage of the
+	// metadata cardinality limit at which a warning is logged.
+	defaultMetadataCardinalityWarnPercent = 80
+
+	// The default retry intervals, used once retry is enabled.
+	defaultRetryInitialInterval = 5 * time.Second
+	defaultRetryMaxInterval     = 30 * time.Second
+	defaultRetryMaxElapsedTime  = 5 * time.Minute
+
+	// defaultStatusFailureThreshold is the number of consecutive
+	// failed exports reported as a recoverable error.
+	defaultStatusFailureThreshold = 5
+
+	// defaultFailureLogInterval is the minimum interval between the
+	// logs of failed exports of a batcher.
+	defaultFailureLogInterval = 10 * time.Second
+)
+
+// Config defines configuration for batch processor.
+type Config struct {
+	// Timeout sets the time after which a batch will be sent regardless of size.
+	// When this is set to zero, batched data will be sent immediately.
+	// The deprecated name "timeout" is also accepted.
+	Timeout time.Duration `mapstructure:"flush_timeout"`
+
+	// SendBatchSize is the size of a batch which after hit, will trigger it to be sent.
+	// SendBatchSize of 0 implies ignoring timeout, data will be sent immediately
+	// subject to only max_size.
+	// The deprecated name "send_batch_size" is also accepted.
+	SendBatchSize uint32 `mapstructure:"min_size"`
+
+	// SendBatchMaxSize is the maximum size of a batch. It must be larger than SendBatchSize.
+	// Larger batches are split into smaller units.
+	// Default value is 0, that means no maximum size.
+	// The deprecated name "send_batch_max_size" is also accepted.
+	SendBatchMaxSize uint32 `mapstructure:"max_size"`
+
+	// SendBatchSizeBytes is the marshaled size of a batch which after
+	// hit, will trigger it to be sent, along with SendBatchSize.
+	// Default value is 0, that means no size in bytes.
+	// The deprecated name "send_batch_size_bytes" is also accepted.
+	SendBatchSizeBytes uint64 `mapstructure:"min_size_bytes"`
+
+	// SendBatchMaxSizeBytes is the maximum marshaled size of a batch.
+	// A pending batch is sent before adding a request that would take
+	// it past this size, so only a single request may exceed it.
+	// Default value is 0, that means no maximum size in bytes.
+	// The deprecated name "send_batch_max_size_bytes" is also accepted.
+	SendBatchMaxSizeBytes uint64 `mapstructure:"max_size_bytes"`
+
+	// SplitMode controls where SendBatchMaxSize cuts a batch of
+	// traces.  With "any" (the default) the cut may fall between two
+	// spans of the same trace.  With "trace" the spans of a trace are
+	// kept in the same request, which may exceed SendBatchMaxSize by
+	// less than the spans of one trace.  With "trace_strict" a trace
+	// that does not fit is left for the next request, so that a
+	// request only exceeds SendBatchMaxSize when it holds a single
+	// trace larger than it.  Spans of a trace arriving after part of
+	// it was sent go to a later request.  Metrics and logs ignore it.
+	SplitMode string `mapstructure:"split_mode"`
+
+	// SplitAt controls the unit at which SendBatchMaxSize cuts a
+	// batch.  With "item" (the default) the cut may fall within a
+	// resource, whose resource is then sent in both requests.  With
+	// "resource" the cut only falls between resources, and a single
+	// resource larger than SendBatchMaxSize is sent in a request of
+	// its own.  SplitMode must be "any" with "resource".  With
+	// "scope" the cut of logs only falls between scopes, and a single
+	// scope larger than SendBatchMaxSize is sent in a request of its
+	// own.  Traces and metrics cut at items with "scope".
+	SplitAt string `mapstructure:"split_at"`
+
+	// Compact merges the resource entries of a batch that have the
+	// same resource attributes and schema URL, appending the scopes of
+	// a request to the entry of its resource instead of adding an
+	// entry per request.  Within a resource entry, the items of a
+	// scope with the same name, version, attributes and schema URL as
+	// one of the entry are appended to it.  It costs hashing the
+	// resource and scopes of every incoming entry.
+	Compact bool `mapstructure:"compact"`
+
+	// MergeDataPoints merges, within a scope entry of a compacted
+	// batch of metrics, the data points of metrics with the same name,
+	// unit and type, and for sums and histograms the same temporality
+	// and monotonicity, into a single metric.  A metric with the name
+	// of another but a different identity is left as is and counted as
+	// a conflict.  It requires Compact, and traces and logs ignore it.
+	MergeDataPoints bool `mapstructure:"merge_data_points"`
+
+	// FlushOnResourceChange sends the pending batch of a batcher
+	// before adding a request whose set of resources differs from that
+	// of the data in the batch, so that every request sent holds data
+	// of the same resources, still cut by SendBatchSize,
+	// SendBatchMaxSize and Timeout.  It suits agents with a handful of
+	// resources, not gateways receiving many of them.
+	FlushOnResourceChange bool `mapstructure:"flush_on_resource_change"`
+
+	// MetadataKeys is a list of client.Metadata keys that will be
+	// used to form distinct batchers.  If this setting is empty,
+	// a single batcher instance will be used.  When this setting
+	// is not empty, one batcher will be used per distinct
+	// combination of values for the listed metadata keys.
+	//
+	// Empty value and unset metadata are treated as distinct cases.
+	//
+	// Entries are case-insensitive.  Duplicated entries will
+	// trigger a validation error.
+	//
+	// Entries may be glob patterns (e.g., "x-scope-*"), in which
+	// case every metadata key of an incoming request matching the
+	// pattern is used.  A pattern matching every key is rejected
+	// unless MetadataKeysAllowMatchAll is set.
+	MetadataKeys []string `mapstructure:"metadata_keys"`
+
+	// PreserveMetadataCase indicates that the outgoing metadata
+	// uses the casing of the keys as written in MetadataKeys,
+	// instead of lower case.  Lookups and the identification of
+	// batchers remain case-insensitive.  Keys matched by a pattern
+	// are always lower case, as are the keys listed by
+	// client.Metadata.
+	PreserveMetadataCase bool `mapstructure:"preserve_metadata_case"`
+
+	// MetadataKeySettings configures the handling of individual
+	// entries of MetadataKeys.
+	MetadataKeySettings []MetadataKeySettings `mapstructure:"metadata_key_settings"`
+
+	// MetadataKeysAllowMatchAll permits a MetadataKeys pattern such
+	// as "*" that matches every metadata key.
+	MetadataKeysAllowMatchAll bool `mapstructure:"metadata_keys_allow_match_all"`
+
+	// MetadataValueLimit bounds the client metadata of incoming
+	// requests before it identifies a batcher or is propagated, for
+	// clients sending enormous values not to inflate every batcher.
+	MetadataValueLimit MetadataValueLimit `mapstructure:"metadata_value_limit"`
+
+	// AuthKeys is a list of client.AuthData attribute names that
+	// will be used to form distinct batchers, in addition to
+	// MetadataKeys.  This batches by authenticated identity, as
+	// provided by the receiver's authenticator, rather than by raw
+	// request metadata.  Requests without authentication data are
+	// grouped together.
+	//
+	// Entries are case-sensitive.  Duplicated entries will trigger
+	// a validation error.
+	AuthKeys []string `mapstructure:"auth_keys"`
+
+	// ResourceAttributeKeys is a list of resource attribute keys
+	// that will be used to form distinct batchers, in addition to
+	// MetadataKeys.  When this setting is not empty, incoming
+	// requests are split by resource and each resource is routed to
+	// the batcher matching its attribute values.
+	//
+	// Entries are case-sensitive.  Duplicated entries will trigger
+	// a validation error.
+	ResourceAttributeKeys []string `mapstructure:"resource_attribute_keys"`
+
+	// GroupBy lists the sources of the batching key in a single list,
+	// mixing client metadata, resource attributes and the receiver of
+	// the data, as an alternative to MetadataKeys and
+	// ResourceAttributeKeys.  Entries are combined with those settings.
+	GroupBy []GroupBySource `mapstructure:"group_by"`
+
+	// MetadataCardinalityLimit indicates the maximum number of
+	// batcher instances that will be created through a distinct
+	// combination of MetadataKeys, AuthKeys, and ResourceAttributeKeys.
+	// Zero means no limit, in which case CardinalityOverflowMode
+	// "group" and MetadataEvictionPolicy "lru" cannot be used.
+	MetadataCardinalityLimit uint32 `mapstructure:"metadata_cardinality_limit"`
+
+	// MetadataCardinalityWarnPercent is the percentage of
+	// MetadataCardinalityLimit at which a warning is logged, ahead of
+	// data being rejected.  Zero disables the warning.
+	MetadataCardinalityWarnPercent uint32 `mapstructure:"metadata_cardinality_warn_percent"`
+
+	// CardinalityOverflowMode controls what happens to data for a new
+	// combination once MetadataCardinalityLimit is reached.  With
+	// "reject" (the default) the data is refused with a permanent
+	// error.  With "group" the data is routed to a single shared
+	// overflow batcher whose exported context carries no metadata.
+	CardinalityOverflowMode string `mapstructure:"cardinality_overflow_mode"`
+
+	// MetadataEvictionPolicy controls whether existing batchers are
+	// evicted once MetadataCardinalityLimit is reached.  With "none"
+	// (the default) batchers live for the lifetime of the processor.
+	// With "lru" the least-recently-used batcher is flushed and
+	// removed to make room for the new combination, in which case
+	// CardinalityOverflowMode does not apply.
+	MetadataEvictionPolicy string `mapstructure:"metadata_eviction_policy"`
+
+	// MetadataBatcherIdleTimeout is the period after which a batcher
+	// that has received no data is flushed and removed, releasing
+	// its goroutine and its slot in MetadataCardinalityLimit.  A
+	// later request with the same combination creates a new batcher.
+	// Zero, the default, means batchers do not expire.
+	MetadataBatcherIdleTimeout time.Duration `mapstructure:"metadata_batcher_idle_timeout"`
+
+	// FlushOnShutdown indicates whether pending batches are sent to
+	// the next consumer when the processor shuts down.  When false,
+	// pending data is counted and dropped so that shutdown does not
+	// wait on the next consumer.  Defaults to true when unset.
+	FlushOnShutdown *bool `mapstructure:"flush_on_shutdown"`
+
+	// MutatesData indicates whether the processor takes ownership of
+	// the data it receives.  When false, it reports that it does not
+	// mutate its input, sparing an upstream fan-out a copy of the data
+	// for it, and copies the data it batches instead.  Defaults to true
+	// when unset.
+	MutatesData *bool `mapstructure:"mutates_data"`
+
+	// DrainTimeout bounds the flush of pending data on shutdown, so
+	// that the processor gives up before the shutdown deadline of the
+	// service and leaves the rest of it to the other components.  The
+	// sooner of DrainTimeout and the deadline of the shutdown context
+	// applies; data not flushed by then is dropped.  Zero, the
+	// default, means only the shutdown context bounds the flush.
+	DrainTimeout time.Duration `mapstructure:"drain_timeout"`
+
+	// ShutdownParallelism is the number of batchers flushing their
+	// pending data at once on shutdown.  Zero, the default, means the
+	// number of CPUs.
+	ShutdownParallelism uint32 `mapstructure:"shutdown_parallelism"`
+
+	// Bypass configures rules for data that should not wait for
+	// a full batch or the timeout.
+	Bypass BypassConfig `mapstructure:"bypass"`
+
+	// PropagateMetadata controls the metadata of the context passed
+	// to the next consumer.  With "batch_keys" (the default) it
+	// carries only the MetadataKeys used for batching.  With "all" it
+	// carries the complete incoming metadata, while batchers remain
+	// keyed by MetadataKeys only.  When requests in the same batch
+	// disagree on a key that is not a batching key, the value of the
+	// first request in the batch carrying the key is kept.
+	PropagateMetadata string `mapstructure:"propagate_metadata"`
+
+	// PropagateClientInfo lists the client.Info fields, "addr",
+	// "auth" and "source", carried into the context passed to the
+	// next consumer.  A field is only set when every request in the
+	// batch has the same value; otherwise it is dropped (for auth,
+	// the attributes of AuthKeys are kept, and for source, the value
+	// of a source entry of GroupBy).
+	PropagateClientInfo []string `mapstructure:"propagate_client_info"`
+
+	// OnFull controls what happens when a batcher cannot accept more
+	// data because it is busy sending to a slow next consumer.  With
+	// "block" (the default) the caller waits.  With "error" the data
+	// is rejected immediately with a retryable error, so that
+	// receivers can push back on their clients.  With "drop_oldest"
+	// the oldest pending request is discarded to make room.
+	OnFull string `mapstructure:"on_full"`
+
+	// SyncConsume makes the Consume calls add their data to the batch
+	// and send it when full on the caller's goroutine, instead of
+	// handing it to the batcher goroutine, which then only flushes on
+	// timeout.  Export errors of the batches sent by a call are
+	// returned by it.  OnFull does not apply, as there is no queue.
+	SyncConsume bool `mapstructure:"sync_consume"`
+
+	// ErrorMode controls whether export failures are returned to the
+	// producers whose data was in the failed batch.  With "ignore"
+	// (the default) the Consume call returns as soon as the data is
+	// queued and failures are only logged.  With "propagate" the call
+	// waits for the batch containing its data to be exported and
+	// returns the error of the next consumer, if any.
+	ErrorMode string `mapstructure:"error_mode"`
+
+	// Ordering controls the order in which the batches of the same
+	// metadata values reach the next consumer.  A batcher exports its
+	// batches one at a time, in the order they were cut, but a batcher
+	// removed by metadata_batcher_idle_timeout or by eviction flushes
+	// its data while the batcher created again for the same values
+	// exports too.  With "none" (the default) their exports may
+	// interleave.  With "per_batcher" the new batcher waits for the
+	// removed one to complete its exports first.
+	Ordering string `mapstructure:"ordering"`
+
+	// MaxInFlightItems is the maximum number of spans, data points, or
+	// log records held by the processor across all batchers, from the
+	// time they are received until they are exported or dropped.
+	// Requests beyond the budget are rejected with a retryable error.
+	// A request is always accepted when nothing is in flight, so that
+	// it cannot be rejected forever.  Zero disables the budget.
+	MaxInFlightItems uint64 `mapstructure:"max_in_flight_items"`
+
+	// DeadLetterExporter is the ID of an exporter, configured in a
+	// pipeline of the same data type, receiving the data of batches
+	// that fail to export instead of dropping it.
+	DeadLetterExporter *component.ID `mapstructure:"dead_letter_exporter"`
+
+	// Persistence configures a write-ahead log of the data held by
+	// the batchers, replayed when the processor starts.
+	Persistence PersistenceConfig `mapstructure:"persistence"`
+
+	// Retry configures the retry of exports failing with an error
+	// that is not permanent.
+	Retry RetryConfig `mapstructure:"retry"`
+
+	// FailureLogInterval is the minimum interval between two logs of
+	// failed exports by the same batcher.  Failures in between are
+	// counted and reported by the next log.  Zero logs every failure.
+	FailureLogInterval time.Duration `mapstructure:"failure_log_interval"`
+
+	// TelemetryIncludeMetadata adds the metadata and resource values
+	// identifying each batcher to the attributes of the metrics of its
+	// exports and queue, with the OTel internal metrics.  Auth values
+	// are never added.  The cardinality of the metrics is bounded by
+	// MetadataCardinalityLimit.
+	TelemetryIncludeMetadata bool `mapstructure:"telemetry_include_metadata"`
+
+	// Metrics enables or disables metrics of the processor otherwise
+	// following the telemetry level.
+	Metrics MetricsConfig `mapstructure:"metrics"`
+
+	// StatusReporting configures the component status reported to
+	// hosts implementing component.StatusReporter.
+	StatusReporting StatusReportingConfig `mapstructure:"status_reporting"`
+
+	// Overrides adjusts the batching settings of batchers whose
+	// metadata matches.  The first matching entry applies.
+	Overrides []BatchOverride `mapstructure:"overrides"`
+
+	// Signals adjusts the batching settings for the data of each
+	// signal, for a processor used in pipelines of several signals.
+	Signals SignalsConfig `mapstructure:"signals"`
+
+	// deprecatedNames lists the deprecated names of the batching
+	// settings found on unmarshaling, to warn about them.
+	deprecatedNames []string
+}
+
+// renamedSettings maps the deprecated names of the batching settings,
+// at the top level, in overrides, and in signals, to their names.
+var renamedSettings = []struct{ deprecated, name string }{
+	{"send_batch_size", "min_size"},
+	{"send_batch_max_size", "max_size"},
+	{"timeout", "flush_timeout"},
+	{"send_batch_size_bytes", "min_size_bytes"},
+	{"send_batch_max_size_bytes", "max_size_bytes"},
+}
+
+var _ confmap.Unmarshaler = (*Config)(nil)
+
+// Unmarshal implements confmap.Unmarshaler, accepting the deprecated
+// names of the batching settings.  When both names of a setting are
+// set, the deprecated one is ignored.  Explicit zero values are kept.
+func (cfg *Config) Unmarshal(conf *confmap.Conf) error {
+	raw := conf.ToStringMap()
+	deprecated := renameSettings(raw, "")
+	if overrides, ok := raw["overrides"].([]any); ok {
+		for i, o := range overrides {
+			if m, ok := o.(map[string]any); ok {
+				deprecated = append(deprecated, renameSettings(m, fmt.Sprintf("overrides[%d]::", i))...)
+			}
+		}
+	}
+	if signals, ok := raw["signals"].(map[string]any); ok {
+		for _, dataType := range []component.DataType{component.DataTypeTraces, component.DataTypeMetrics, component.DataTypeLogs} {
+			if m, ok := signals[string(dataType)].(map[string]any); ok {
+				deprecated = append(deprecated, renameSettings(m, fmt.Sprintf("signals::%s::", dataType))...)
+			}
+		}
+	}
+	if err := confmap.NewFromStringMap(raw).Unmarshal(cfg, confmap.WithErrorUnused()); err != nil {
+		return err
+	}
+	cfg.deprecatedNames = deprecated
+	return nil
+}
+
+// renameSettings replaces the deprecated names of the batching settings
+// in m by their names, unless those are set, and returns the deprecated
+// names found, prefixed by prefix.
+func renameSettings(m map[string]any, prefix string) []string {
+	var deprecated []string
+	for _, r := range renamedSettings {
+		v, ok := m[r.deprecated]
+		if !ok {
+			continue
+		}
+		delete(m, r.deprecated)
+		if _, set := m[r.name]; set {
+			deprecated = append(deprecated, fmt.Sprintf("%s%s (ignored, %s%s is set)", prefix, r.deprecated, prefix, r.name))
+			continue
+		}
+		m[r.name] = v
+		deprecated = append(deprecated, fmt.Sprintf("%s%s (use %s%s)", prefix, r.deprecated, prefix, r.name))
+	}
+	return deprecated
+}
+
+// SignalsConfig holds the batching settings of each signal.
+type SignalsConfig struct {
+	Traces  SignalConfig `mapstructure:"traces"`
+	Metrics SignalConfig `mapstructure:"metrics"`
+	Logs    SignalConfig `mapstructure:"logs"`
+}
+
+// SignalConfig replaces the batching settings of Config for the data of
+// one signal.  Overrides apply on top of it.
+type SignalConfig struct {
+	// SendBatchSize, when set, replaces Config.SendBatchSize.
+	SendBatchSize *uint32 `mapstructure:"min_size"`
+
+	// SendBatchMaxSize, when set, replaces Config.SendBatchMaxSize.
+	SendBatchMaxSize *uint32 `mapstructure:"max_size"`
+
+	// Timeout, when set, replaces Config.Timeout.
+	Timeout *time.Duration `mapstructure:"flush_timeout"`
+}
+
+// BatchOverride replaces the batching settings for the batchers of
+// specific metadata values, e.g. a single large tenant.
+type BatchOverride struct {
+	// Metadata lists the metadata key/value pairs that must all be
+	// present in a batcher's metadata for the override to apply.
+	// Every key must be listed in MetadataKeys.
+	Metadata map[string]string `mapstructure:"metadata"`
+
+	// SendBatchSize, when set, replaces Config.SendBatchSize.
+	SendBatchSize *uint32 `mapstructure:"min_size"`
+
+	// SendBatchMaxSize, when set, replaces Config.SendBatchMaxSize.
+	SendBatchMaxSize *uint32 `mapstructure:"max_size"`
+
+	// Timeout, when set, replaces Config.Timeout.
+	Timeout *time.Duration `mapstructure:"flush_timeout"`
+}
+
+// GroupBySource is one dimension of the batching key.  Exactly one of
+// the fields must be set.
+type GroupBySource struct {
+	// Metadata is a client metadata key, as in MetadataKeys.
+	Metadata string `mapstructure:"metadata"`
+
+	// Resource is a resource attribute key, as in
+	// ResourceAttributeKeys.
+	Resource string `mapstructure:"resource"`
+
+	// Source groups the data by client.Info.Source, the ID of the
+	// receiver of each request.
+	Source bool `mapstructure:"source"`
+}
+
+// MetadataValueLimit bounds the values of the client metadata.  Zero
+// limits are not enforced.
+type MetadataValueLimit struct {
+	// MaxLength is the length in bytes past which a value is cut,
+	// and followed by client.MetadataTruncatedSuffix.
+	MaxLength uint32 `mapstructure:"max_length"`
+
+	// MaxCount is the number of values of a key past which the
+	// following ones are dropped.
+	MaxCount uint32 `mapstructure:"max_count"`
+}
+
+// MetadataKeySettings configures the handling of one metadata key.
+type MetadataKeySettings struct {
+	// Key is the metadata key these settings apply to.  It must be
+	// listed in MetadataKeys, and is case-insensitive.
+	Key string `mapstructure:"key"`
+
+	// Normalize is a list of transformations applied in order to
+	// each value before it is used to identify a batcher and copied
+	// into the outgoing metadata.  Supported transformations are
+	// "lowercase", "trim" (of surrounding whitespace), "prefix:<n>"
+	// (keeps the first n bytes), and "hash" (replaces the value with
+	// its hex-encoded SHA-256).
+	Normalize []string `mapstructure:"normalize"`
+
+	// CaseInsensitiveValues indicates that values differing only in
+	// case identify the same batcher.  Values are lower-cased before
+	// any Normalize transformation, and AllowedValues and Overrides
+	// are matched case-insensitively.
+	CaseInsensitiveValues bool `mapstructure:"case_insensitive_values"`
+
+	// AllowedValues is the list of values that form distinct
+	// batchers.  When not empty, requests with any other value are
+	// grouped into a single batcher using the value "__other__",
+	// which keeps the number of batchers bounded by configuration
+	// instead of by client input.  Unset metadata is not grouped.
+	AllowedValues []string `mapstructure:"allowed_values"`
+
+	// Limit is the maximum number of distinct values of this key
+	// across the live batchers.  Beyond the limit, new values are
+	// replaced by "__overflow__", so that one key cannot exhaust
+	// MetadataCardinalityLimit.  Zero means no limit.
+	Limit uint32 `mapstructure:"limit"`
+
+	// OtherValues controls the outgoing metadata of the batcher for
+	// values not in AllowedValues.  With "replace" (the default) the
+	// key carries the value "__other__", with "omit" the key is not
+	// set.  The original values cannot be forwarded because a batch
+	// mixes data from several of them.
+	OtherValues string `mapstructure:"other_values"`
+}
+
+const (
+	otherValuesReplace = "replace"
+	otherValuesOmit    = "omit"
+)
+
+// allows returns whether the batchers of value v can exist: any value
+// without AllowedValues, otherwise the allowed values and the value
+// replacing the others.
+func (ks MetadataKeySettings) allows(v string) bool {
+	if len(ks.AllowedValues) == 0 || v == metadataOtherValue {
+		return true
+	}
+	for _, a := range ks.AllowedValues {
+		if a == v || (ks.CaseInsensitiveValues && strings.EqualFold(a, v)) {
+			return true
+		}
+	}
+	return false
+}
+
+// PersistenceConfig configures the write-ahead log of the batchers.
+type PersistenceConfig struct {
+	// Directory holds the write-ahead log segments.  Empty disables
+	// persistence.
+	Directory string `mapstructure:"directory"`
+}
+
+// RetryConfig configures the retry of failed exports by the batchers.
+// While a batcher retries, it does not receive new data, so producers
+// are subject to OnFull once its queue is full.
+type RetryConfig struct {
+	// Enabled turns on retries.  Errors marked permanent with
+	// consumererror.NewPermanent are never retried.
+	Enabled bool `mapstructure:"enabled"`
+
+	// InitialInterval is the time to wait before the first retry.
+	// The interval doubles after every failed retry.
+	InitialInterval time.Duration `mapstructure:"initial_interval"`
+
+	// MaxInterval is the upper bound of the interval between two
+	// retries.
+	MaxInterval time.Duration `mapstructure:"max_interval"`
+
+	// MaxElapsedTime is the time after which the batcher gives up
+	// retrying a batch.  Zero means retrying until shutdown.
+	MaxElapsedTime time.Duration `mapstructure:"max_elapsed_time"`
+}
+
+// MetricsConfig overrides the telemetry level for metrics costly to
+// record.
+type MetricsConfig struct {
+	// SendSizeBytes enables the batch_send_size_bytes metric, for
+	// which every batch is measured.  Unset, it is enabled with the
+	// detailed telemetry level.
+	SendSizeBytes *bool `mapstructure:"send_size_bytes"`
+}
+
+// StatusReportingConfig configures the reporting of export failures as
+// component status.
+type StatusReportingConfig struct {
+	// FailureThreshold is the number of consecutive failed exports
+	// of a batcher after which a recoverable error is reported, with
+	// the batcher's metadata as attributes.  OK is reported once every
+	// failing batcher has exported successfully.  Zero disables status
+	// reporting.
+	FailureThreshold uint32 `mapstructure:"failure_threshold"`
+}
+
+// BypassConfig defines match rules for data that is sent with minimal
+// latency.  A request matches when any of its spans, data points, or log
+// records match one of the configured rules.
+type BypassConfig struct {
+	// Mode controls what happens to a matching request.  With
+	// "flush" (the default) the request is added to the current
+	// batch and the batch is sent immediately.  With "forward" the
+	// request is sent on its own, ahead of the pending batch.
+	Mode string `mapstructure:"mode"`
+
+	// LogSeverity is the minimum severity (e.g., "ERROR") at which a
+	// log record matches.  Empty disables the rule.
+	LogSeverity string `mapstructure:"log_severity"`
+
+	// SpanStatusError when true matches spans with an Error status.
+	SpanStatusError bool `mapstructure:"span_status_error"`
+
+	// MetricNames is a list of metric names that match.
+	MetricNames []string `mapstructure:"metric_names"`
+}
+
+// SeverityNumber returns the lowest severity number whose name matches
+// LogSeverity, case-insensitively, and whether one does, e.g. "error"
+// returns plog.SeverityNumberError.
+func (cfg BypassConfig) SeverityNumber() (plog.SeverityNumber, bool) {
+	for sn := plog.SeverityNumberTrace; sn <= plog.SeverityNumberFatal4; sn++ {
+		if strings.EqualFold(sn.String(), cfg.LogSeverity) {
+			return sn, true
+		}
+	}
+	return plog.SeverityNumberUnspecified, false
+}
+
+// hasRules returns whether any bypass rule is configured.
+func (cfg BypassConfig) hasRules() bool {
+	_, severity := cfg.SeverityNumber()
+	return severity || cfg.SpanStatusError || len(cfg.MetricNames) != 0
+}
+
+const (
+	bypassModeFlush   = "flush"
+	bypassModeForward = "forward"
+)
+
+const (
+	cardinalityOverflowReject = "reject"
+	cardinalityOverflowGroup  = "group"
+)
+
+const (
+	propagateMetadataBatchKeys = "batch_keys"
+	propagateMetadataAll       = "all"
+)
+
+const (
+	errorModeIgnore    = "ignore"
+	errorModePropagate = "propagate"
+)
+
+// The values of SplitMode, how a traces batch is cut when it exceeds
+// SendBatchMaxSize.
+const (
+	SplitModeAny         = "any"
+	SplitModeTrace       = "trace"
+	SplitModeTraceStrict = "trace_strict"
+)
+
+// The values of SplitAt, the level at which a batch is cut when it exceeds
+// SendBatchMaxSize.
+const (
+	SplitAtItem     = "item"
+	SplitAtResource = "resource"
+	SplitAtScope    = "scope"
+)
+
+const (
+	orderingNone       = "none"
+	orderingPerBatcher = "per_batcher"
+)
+
+const (
+	onFullBlock      = "block"
+	onFullError      = "error"
+	onFullDropOldest = "drop_oldest"
+)
+
+const (
+	propagateClientInfoAddr   = "addr"
+	propagateClientInfoAuth   = "auth"
+	propagateClientInfoSource = "source"
+)
+
+const (
+	metadataEvictionNone = "none"
+	metadataEvictionLRU  = "lru"
+)
+
+var _ component.Config = (*Config)(nil)
+
+// Validate checks if the processor configuration is valid
+func (cfg *Config) Validate() error {
+	if err := cfg.validateSizes(); err != nil {
+		return err
+	}
+	for _, dataType := range []component.DataType{component.DataTypeTraces, component.DataTypeMetrics, component.DataTypeLogs} {
+		if err := cfg.forSignal(dataType).validateSizes(); err != nil {
+			return fmt.Errorf("signals::%s: %w", dataType, err)
+		}
+	}
+	if cfg.SendBatchMaxSizeBytes > 0 && cfg.SendBatchMaxSizeBytes < cfg.SendBatchSizeBytes {
+		return fmt.Errorf("max_size_bytes (%d) must be greater or equal to min_size_bytes (%d)", cfg.SendBatchMaxSizeBytes, cfg.SendBatchSizeBytes)
+	}
+	sources := 0
+	for i, g := range cfg.GroupBy {
+		if g.Source {
+			sources++
+		}
+		set := 0
+		for _, ok := range []bool{g.Metadata != "", g.Resource != "", g.Source} {
+			if ok {
+				set++
+			}
+		}
+		if set != 1 {
+			return fmt.Errorf("group_by[%d]: exactly one of metadata, resource or source must be set", i)
+		}
+	}
+	if sources > 1 {
+		return errors.New("duplicate entry in group_by: source")
+	}
+	uniq := map[string]bool{}
+	for _, k := range cfg.metadataKeys() {
+		if strings.TrimSpace(k) == "" {
+			return fmt.Errorf("empty entry in metadata_keys: %q", k)
+		}
+		l := strings.ToLower(k)
+		if _, has := uniq[l]; has {
+			return fmt.Errorf("duplicate entry in metadata_keys: %q (case-insensitive)", l)
+		}
+		uniq[l] = true
+		if !isMetadataKeyPattern(l) {
+			continue
+		}
+		if _, err := path.Match(l, ""); err != nil {
+			return fmt.Errorf("invalid pattern in metadata_keys: %q: %w", k, err)
+		}
+		if strings.Trim(l, "*") == "" && !cfg.MetadataKeysAllowMatchAll {
+			return fmt.Errorf("pattern in metadata_keys matches every key: %q (set metadata_keys_allow_match_all to permit it)", k)
+		}
+	}
+	settings := map[string]MetadataKeySettings{}
+	for i, ks := range cfg.MetadataKeySettings {
+		l := strings.ToLower(ks.Key)
+		if !uniq[l] || isMetadataKeyPattern(l) {
+			return fmt.Errorf("metadata_key_settings[%d]: key %q is not listed in metadata_keys", i, ks.Key)
+		}
+		if _, has := settings[l]; has {
+			return fmt.Errorf("metadata_key_settings[%d]: duplicate entry for key %q (case-insensitive)", i, l)
+		}
+		settings[l] = ks
+		for _, n := range ks.Normalize {
+			if _, err := newNormalizer(n); err != nil {
+				return fmt.Errorf("metadata_key_settings[%d]: normalize for key %q: %w", i, ks.Key, err)
+			}
+		}
+		switch ks.OtherValues {
+		case "", otherValuesReplace, otherValuesOmit:
+		default:
+			return fmt.Errorf("metadata_key_settings[%d]: other_values for key %q must be %q or %q, got %q", i, ks.Key, otherValuesReplace, otherValuesOmit, ks.OtherValues)
+		}
+	}
+	uniqAuth := map[string]bool{}
+	for _, k := range cfg.AuthKeys {
+		if uniqAuth[k] {
+			return fmt.Errorf("duplicate entry in auth_keys: %q", k)
+		}
+		uniqAuth[k] = true
+	}
+	uniqResource := map[string]bool{}
+	for _, k := range cfg.resourceAttributeKeys() {
+		if _, has := uniqResource[k]; has {
+			return fmt.Errorf("duplicate entry in resource_attribute_keys: %q", k)
+		}
+		uniqResource[k] = true
+	}
+	if cfg.MetadataCardinalityLimit != unlimitedMetadataCardinality && cfg.MetadataCardinalityLimit != defaultMetadataCardinalityLimit &&
+		len(uniq) == 0 && len(cfg.AuthKeys) == 0 && len(uniqResource) == 0 {
+		return fmt.Errorf("metadata_cardinality_limit (%d) has no effect without metadata_keys, auth_keys, resource_attribute_keys, or group_by", cfg.MetadataCardinalityLimit)
+	}
+	if cfg.MetadataBatcherIdleTimeout < 0 {
+		return fmt.Errorf("metadata_batcher_idle_timeout must be greater or equal to 0, got %v", cfg.MetadataBatcherIdleTimeout)
+	}
+	if cfg.DrainTimeout < 0 {
+		return fmt.Errorf("drain_timeout must be greater or equal to 0, got %v", cfg.DrainTimeout)
+	}
+	if cfg.FailureLogInterval < 0 {
+		return fmt.Errorf("failure_log_interval must be greater or equal to 0, got %v", cfg.FailureLogInterval)
+	}
+	if cfg.Retry.Enabled {
+		if cfg.Retry.InitialInterval <= 0 {
+			return errors.New("retry: initial_interval must be greater than 0")
+		}
+		if cfg.Retry.MaxInterval < cfg.Retry.InitialInterval {
+			return errors.New("retry: max_interval must be greater or equal to initial_interval")
+		}
+		if cfg.Retry.MaxElapsedTime < 0 {
+			return errors.New("retry: max_elapsed_time must be greater or equal to 0")
+		}
+	}
+	for i, o := range cfg.Overrides {
+		if len(o.Metadata) == 0 {
+			return fmt.Errorf("overrides[%d]: metadata must not be empty", i)
+		}
+		for k, v := range o.Metadata {
+			l := strings.ToLower(k)
+			if !uniq[l] || isMetadataKeyPattern(l) {
+				return fmt.Errorf("overrides[%d]: key %q is not listed in metadata_keys", i, k)
+			}
+			if ks, ok := settings[l]; ok && !ks.allows(v) {
+				return fmt.Errorf("overrides[%d]: value %q of key %q is not in its metadata_key_settings allowed_values, the override would never apply", i, v, k)
+			}
+		}
+	}
+	if cfg.MetadataCardinalityWarnPercent > 100 {
+		return errors.New("metadata_cardinality_warn_percent must be less than or equal to 100")
+	}
+	switch cfg.CardinalityOverflowMode {
+	case "", cardinalityOverflowReject:
+	case cardinalityOverflowGroup:
+		if cfg.MetadataCardinalityLimit == unlimitedMetadataCardinality {
+			return fmt.Errorf("cardinality_overflow_mode %q requires a metadata_cardinality_limit", cfg.CardinalityOverflowMode)
+		}
+	default:
+		return fmt.Errorf("cardinality_overflow_mode must be %q or %q, got %q", cardinalityOverflowReject, cardinalityOverflowGroup, cfg.CardinalityOverflowMode)
+	}
+	switch cfg.MetadataEvictionPolicy {
+	case "", metadataEvictionNone:
+	case metadataEvictionLRU:
+		if cfg.MetadataCardinalityLimit == unlimitedMetadataCardinality {
+			return fmt.Errorf("metadata_eviction_policy %q requires a metadata_cardinality_limit", cfg.MetadataEvictionPolicy)
+		}
+	default:
+		return fmt.Errorf("metadata_eviction_policy must be %q or %q, got %q", metadataEvictionNone, metadataEvictionLRU, cfg.MetadataEvictionPolicy)
+	}
+	switch cfg.PropagateMetadata {
+	case "", propagateMetadataBatchKeys, propagateMetadataAll:
+	default:
+		return fmt.Errorf("propagate_metadata must be %q or %q, got %q", propagateMetadataBatchKeys, propagateMetadataAll, cfg.PropagateMetadata)
+	}
+	for _, field := range cfg.PropagateClientInfo {
+		switch field {
+		case propagateClientInfoAddr, propagateClientInfoAuth, propagateClientInfoSource:
+		default:
+			return fmt.Errorf("propagate_client_info: unknown field %q, must be %q, %q or %q", field, propagateClientInfoAddr, propagateClientInfoAuth, propagateClientInfoSource)
+		}
+	}
+	switch cfg.ErrorMode {
+	case "", errorModeIgnore, errorModePropagate:
+	default:
+		return fmt.Errorf("error_mode must be %q or %q, got %q", errorModeIgnore, errorModePropagate, cfg.ErrorMode)
+	}
+	switch cfg.SplitMode {
+	case "", SplitModeAny, SplitModeTrace, SplitModeTraceStrict:
+	default:
+		return fmt.Errorf("split_mode must be %q, %q or %q, got %q", SplitModeAny, SplitModeTrace, SplitModeTraceStrict, cfg.SplitMode)
+	}
+	switch cfg.SplitAt {
+	case "", SplitAtItem, SplitAtScope:
+	case SplitAtResource:
+		if cfg.SplitMode != "" && cfg.SplitMode != SplitModeAny {
+			return fmt.Errorf("split_mode %q cannot be used with split_at %q", cfg.SplitMode, cfg.SplitAt)
+		}
+	default:
+		return fmt.Errorf("split_at must be %q, %q or %q, got %q", SplitAtItem, SplitAtResource, SplitAtScope, cfg.SplitAt)
+	}
+	if cfg.MergeDataPoints && !cfg.Compact {
+		return errors.New("merge_data_points requires compact")
+	}
+	switch cfg.Ordering {
+	case "", orderingNone, orderingPerBatcher:
+	default:
+		return fmt.Errorf("ordering must be %q or %q, got %q", orderingNone, orderingPerBatcher, cfg.Ordering)
+	}
+	switch cfg.OnFull {
+	case "", onFullBlock, onFullError, onFullDropOldest:
+	default:
+		return fmt.Errorf("on_full must be %q, %q or %q, got %q", onFullBlock, onFullError, onFullDropOldest, cfg.OnFull)
+	}
+	if cfg.Persistence.Directory != "" && cfg.OnFull == onFullDropOldest {
+		return fmt.Errorf("persistence cannot be used with on_full %q", onFullDropOldest)
+	}
+	switch cfg.Bypass.Mode {
+	case "", bypassModeFlush, bypassModeForward:
+	default:
+		return fmt.Errorf("bypass::mode must be %q or %q, got %q", bypassModeFlush, bypassModeForward, cfg.Bypass.Mode)
+	}
+	if cfg.Bypass.LogSeverity != "" {
+		if _, ok := cfg.Bypass.SeverityNumber(); !ok {
+			return fmt.Errorf("bypass::log_severity: unknown severity %q", cfg.Bypass.LogSeverity)
+		}
+	}
+	return nil
+}
+
+// validateSizes checks the batching settings of cfg and of its
+// overrides.
+func (cfg *Config) validateSizes() error {
+	if cfg.SendBatchMaxSize > 0 && cfg.SendBatchMaxSize < cfg.SendBatchSize {
+		return fmt.Errorf("max_size (%d) must be greater or equal to min_size (%d)", cfg.SendBatchMaxSize, cfg.SendBatchSize)
+	}
+	if cfg.Timeout < 0 {
+		return fmt.Errorf("flush_timeout must be greater or equal to 0, got %v", cfg.Timeout)
+	}
+	for i, o := range cfg.Overrides {
+		size, maxSize := cfg.SendBatchSize, cfg.SendBatchMaxSize
+		if o.SendBatchSize != nil {
+			size = *o.SendBatchSize
+		}
+		if o.SendBatchMaxSize != nil {
+			maxSize = *o.SendBatchMaxSize
+		}
+		if maxSize > 0 && maxSize < size {
+			return fmt.Errorf("overrides[%d]: max_size (%d) must be greater or equal to min_size (%d)", i, maxSize, size)
+		}
+		if o.Timeout != nil && *o.Timeout < 0 {
+			return fmt.Errorf("overrides[%d]: flush_timeout must be greater or equal to 0, got %v", i, *o.Timeout)
+		}
+	}
+	return nil
+}
+
+// forSignal returns a copy of cfg with the batching settings of the
+// section of dataType, if any, applied, and without Signals or
+// deprecatedNames.
+func (cfg *Config) forSignal(dataType component.DataType) *Config {
+	var sc SignalConfig
+	switch dataType {
+	case component.DataTypeTraces:
+		sc = cfg.Signals.Traces
+	case component.DataTypeMetrics:
+		sc = cfg.Signals.Metrics
+	case component.DataTypeLogs:
+		sc = cfg.Signals.Logs
+	}
+	resolved := *cfg
+	resolved.Signals = SignalsConfig{}
+	resolved.deprecatedNames = nil
+	if sc.SendBatchSize != nil {
+		resolved.SendBatchSize = *sc.SendBatchSize
+	}
+	if sc.SendBatchMaxSize != nil {
+		resolved.SendBatchMaxSize = *sc.SendBatchMaxSize
+	}
+	if sc.Timeout != nil {
+		resolved.Timeout = *sc.Timeout
+	}
+	return &resolved
+}
+
+// metadataKeys returns MetadataKeys followed by the metadata keys of
+// GroupBy.
+func (cfg *Config) metadataKeys() []string {
+	keys := cfg.MetadataKeys
+	for _, g := range cfg.GroupBy {
+		if g.Metadata != "" {
+			keys = append(keys[:len(keys):len(keys)], g.Metadata)
+		}
+	}
+	return keys
+}
+
+// groupBySource returns whether GroupBy has a source entry.
+func (cfg *Config) groupBySource() bool {
+	for _, g := range cfg.GroupBy {
+		if g.Source {
+			return true
+		}
+	}
+	return false
+}
+
+// shutdownParallelism returns ShutdownParallelism, or the number of
+// CPUs when it is zero.
+func (cfg *Config) shutdownParallelism() int {
+	if cfg.ShutdownParallelism == 0 {
+		return runtime.NumCPU()
+	}
+	return int(cfg.ShutdownParallelism)
+}
+
+// resourceAttributeKeys returns ResourceAttributeKeys followed by the
+// resource attribute keys of GroupBy.
+func (cfg *Config) resourceAttributeKeys() []string {
+	keys := cfg.ResourceAttributeKeys
+	for _, g := range cfg.GroupBy {
+		if g.Resource != "" {
+			keys = append(keys[:len(keys):len(keys)], g.Resource)
+		}
+	}
+	return keys
+}
+
+// isMetadataKeyPattern returns true when a metadata_keys entry is a
+// glob pattern rather than a literal key.
+func isMetadataKeyPattern(k string) bool {
+	return strings.ContainsAny(k, "*?[")
+}
+
+// NewDefaultConfig returns the default configuration of the batch
+// processor.
+func NewDefaultConfig() *Config {
+	return &Config{
+		SendBatchSize:            defaultSendBatchSize,
+		Timeout:                  defaultTimeout,
+		MetadataCardinalityLimit: defaultMetadataCardinalityLimit,
+
+		MetadataCardinalityWarnPercent: defaultMetadataCardinalityWarnPercent,
+
+		Retry: RetryConfig{
+			InitialInterval: defaultRetryInitialInterval,
+			MaxInterval:     defaultRetryMaxInterval,
+			MaxElapsedTime:  defaultRetryMaxElapsedTime,
+		},
+		FailureLogInterval: defaultFailureLogInterval,
+		StatusReporting: StatusReportingConfig{
+			FailureThreshold: defaultStatusFailureThreshold,
+		},
+	}
+}
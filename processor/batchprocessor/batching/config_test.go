@@ -0,0 +1,645 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestValidateConfig_DefaultBatchMaxSize(t *testing.T) {
+	cfg := &Config{
+		SendBatchSize:    100,
+		SendBatchMaxSize: 0,
+	}
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidateConfig_ValidBatchSizes(t *testing.T) {
+	cfg := &Config{
+		SendBatchSize:    100,
+		SendBatchMaxSize: 1000,
+	}
+	assert.NoError(t, cfg.Validate())
+
+}
+
+func TestValidateConfig_InvalidBatchSize(t *testing.T) {
+	cfg := &Config{
+		SendBatchSize:    1000,
+		SendBatchMaxSize: 100,
+	}
+	assert.EqualError(t, cfg.Validate(), "max_size (100) must be greater or equal to min_size (1000)")
+}
+
+func TestValidateConfig_InvalidBatchSizeBytes(t *testing.T) {
+	cfg := &Config{
+		SendBatchSizeBytes:    1 << 20,
+		SendBatchMaxSizeBytes: 1 << 10,
+	}
+	assert.EqualError(t, cfg.Validate(), "max_size_bytes (1024) must be greater or equal to min_size_bytes (1048576)")
+
+	cfg.SendBatchMaxSizeBytes = 0
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidateConfig_InvalidTimeout(t *testing.T) {
+	cfg := &Config{
+		Timeout: -5 * time.Second,
+	}
+	assert.EqualError(t, cfg.Validate(), "flush_timeout must be greater or equal to 0, got -5s")
+}
+
+func TestValidateConfig_ValidZero(t *testing.T) {
+	cfg := &Config{}
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidateConfig_Bypass(t *testing.T) {
+	cfg := &Config{
+		Bypass: BypassConfig{
+			Mode:        bypassModeForward,
+			LogSeverity: "warn",
+		},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Bypass.Mode = "later"
+	assert.ErrorContains(t, cfg.Validate(), "bypass::mode")
+
+	cfg.Bypass.Mode = bypassModeFlush
+	cfg.Bypass.LogSeverity = "critical"
+	assert.ErrorContains(t, cfg.Validate(), "bypass::log_severity")
+}
+
+func TestValidateConfig_ErrorMode(t *testing.T) {
+	cfg := &Config{ErrorMode: errorModePropagate}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.ErrorMode = "retry"
+	assert.ErrorContains(t, cfg.Validate(), "error_mode")
+}
+
+func TestValidateConfig_Ordering(t *testing.T) {
+	cfg := &Config{Ordering: orderingPerBatcher}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Ordering = "global"
+	assert.ErrorContains(t, cfg.Validate(), "ordering")
+}
+
+func TestValidateConfig_SplitMode(t *testing.T) {
+	for _, mode := range []string{SplitModeAny, SplitModeTrace, SplitModeTraceStrict} {
+		cfg := &Config{SplitMode: mode}
+		assert.NoError(t, cfg.Validate())
+	}
+
+	cfg := &Config{SplitMode: "resource"}
+	assert.ErrorContains(t, cfg.Validate(), "split_mode")
+}
+
+func TestValidateConfig_SplitAt(t *testing.T) {
+	cfg := &Config{SplitAt: SplitAtResource}
+	assert.NoError(t, cfg.Validate())
+	cfg.SplitMode = SplitModeAny
+	assert.NoError(t, cfg.Validate())
+
+	cfg.SplitMode = SplitModeTrace
+	assert.ErrorContains(t, cfg.Validate(), "split_mode")
+
+	cfg = &Config{SplitAt: SplitAtScope, SplitMode: SplitModeTrace}
+	assert.NoError(t, cfg.Validate())
+
+	cfg = &Config{SplitAt: "trace"}
+	assert.ErrorContains(t, cfg.Validate(), "split_at")
+}
+
+func TestValidateConfig_MergeDataPoints(t *testing.T) {
+	cfg := &Config{MergeDataPoints: true}
+	assert.ErrorContains(t, cfg.Validate(), "compact")
+
+	cfg.Compact = true
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidateConfig_OnFull(t *testing.T) {
+	cfg := &Config{OnFull: onFullError}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.OnFull = onFullDropOldest
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Persistence.Directory = "/var/lib/otelcol/batch"
+	assert.ErrorContains(t, cfg.Validate(), "persistence")
+	cfg.Persistence.Directory = ""
+
+	cfg.OnFull = "drop"
+	assert.ErrorContains(t, cfg.Validate(), "on_full")
+}
+
+func TestValidateConfig_MetadataKeyPatterns(t *testing.T) {
+	cfg := &Config{
+		MetadataKeys: []string{"x-scope-*", "tenant-?"},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.MetadataKeys = []string{"x-scope-["}
+	assert.ErrorContains(t, cfg.Validate(), "invalid pattern")
+
+	cfg.MetadataKeys = []string{"**"}
+	assert.ErrorContains(t, cfg.Validate(), "matches every key")
+
+	cfg.MetadataKeysAllowMatchAll = true
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidateConfig_EmptyMetadataKeys(t *testing.T) {
+	cfg := &Config{MetadataKeys: []string{"tenant", ""}}
+	assert.EqualError(t, cfg.Validate(), `empty entry in metadata_keys: ""`)
+
+	cfg.MetadataKeys = []string{" \t"}
+	assert.EqualError(t, cfg.Validate(), `empty entry in metadata_keys: " \t"`)
+
+	cfg = &Config{GroupBy: []GroupBySource{{Metadata: " "}}}
+	assert.EqualError(t, cfg.Validate(), `empty entry in metadata_keys: " "`)
+}
+
+func TestValidateConfig_DuplicateMetadataKeys(t *testing.T) {
+	cfg := &Config{MetadataKeys: []string{"X-Tenant", "x-tenant"}}
+	assert.EqualError(t, cfg.Validate(), `duplicate entry in metadata_keys: "x-tenant" (case-insensitive)`)
+}
+
+func TestValidateConfig_MetadataKeySettings(t *testing.T) {
+	cfg := &Config{
+		MetadataKeys: []string{"X-Tenant", "x-scope-*"},
+		MetadataKeySettings: []MetadataKeySettings{
+			{Key: "x-tenant", AllowedValues: []string{"a"}, OtherValues: otherValuesOmit},
+		},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.MetadataKeySettings[0].OtherValues = "keep"
+	assert.ErrorContains(t, cfg.Validate(), "other_values")
+
+	cfg.MetadataKeySettings[0].OtherValues = ""
+	cfg.MetadataKeySettings = append(cfg.MetadataKeySettings, MetadataKeySettings{Key: "X-TENANT"})
+	assert.ErrorContains(t, cfg.Validate(), "duplicate entry")
+}
+
+func TestValidateConfig_MetadataKeySettingsUnknownKey(t *testing.T) {
+	cfg := &Config{
+		MetadataKeys:        []string{"x-tenant", "x-scope-*"},
+		MetadataKeySettings: []MetadataKeySettings{{Key: "x-tenant"}, {Key: "x-region"}},
+	}
+	assert.EqualError(t, cfg.Validate(), `metadata_key_settings[1]: key "x-region" is not listed in metadata_keys`)
+
+	cfg.MetadataKeySettings[1] = MetadataKeySettings{Key: "x-scope-*"}
+	assert.EqualError(t, cfg.Validate(), `metadata_key_settings[1]: key "x-scope-*" is not listed in metadata_keys`)
+}
+
+func TestValidateConfig_MetadataKeySettingsNormalize(t *testing.T) {
+	cfg := &Config{
+		MetadataKeys: []string{"x-tenant"},
+		MetadataKeySettings: []MetadataKeySettings{
+			{Key: "x-tenant", Normalize: []string{"trim", "prefix:8", "hash"}},
+		},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.MetadataKeySettings[0].Normalize = []string{"prefix:-1"}
+	assert.ErrorContains(t, cfg.Validate(), "invalid prefix length")
+
+	cfg.MetadataKeySettings[0].Normalize = []string{"uppercase"}
+	assert.ErrorContains(t, cfg.Validate(), "unknown transformation")
+}
+
+func TestValidateConfig_CardinalityLimitWithoutKeys(t *testing.T) {
+	cfg := &Config{MetadataCardinalityLimit: 5}
+	assert.EqualError(t, cfg.Validate(), "metadata_cardinality_limit (5) has no effect without metadata_keys, auth_keys, resource_attribute_keys, or group_by")
+
+	cfg.MetadataCardinalityLimit = defaultMetadataCardinalityLimit
+	assert.NoError(t, cfg.Validate())
+
+	cfg.MetadataCardinalityLimit = 5
+	for _, keys := range []func(cfg *Config){
+		func(cfg *Config) { cfg.MetadataKeys = []string{"x-tenant"} },
+		func(cfg *Config) { cfg.AuthKeys = []string{"subject"} },
+		func(cfg *Config) { cfg.ResourceAttributeKeys = []string{"service.name"} },
+		func(cfg *Config) { cfg.GroupBy = []GroupBySource{{Metadata: "x-tenant"}} },
+	} {
+		withKeys := *cfg
+		keys(&withKeys)
+		assert.NoError(t, withKeys.Validate())
+	}
+}
+
+func TestValidateConfig_CardinalityOverflowMode(t *testing.T) {
+	cfg := &Config{CardinalityOverflowMode: cardinalityOverflowGroup, MetadataKeys: []string{"x-tenant"}, MetadataCardinalityLimit: 10}
+	assert.NoError(t, cfg.Validate())
+
+	// Without a limit, nothing overflows.
+	cfg.MetadataCardinalityLimit = unlimitedMetadataCardinality
+	assert.EqualError(t, cfg.Validate(), `cardinality_overflow_mode "group" requires a metadata_cardinality_limit`)
+
+	cfg.CardinalityOverflowMode = "drop"
+	assert.ErrorContains(t, cfg.Validate(), "cardinality_overflow_mode")
+}
+
+func TestValidateConfig_MetadataEvictionPolicy(t *testing.T) {
+	cfg := &Config{MetadataEvictionPolicy: metadataEvictionLRU, MetadataKeys: []string{"x-tenant"}, MetadataCardinalityLimit: 10}
+	assert.NoError(t, cfg.Validate())
+
+	// Without a limit, nothing is evicted.
+	cfg.MetadataCardinalityLimit = unlimitedMetadataCardinality
+	assert.EqualError(t, cfg.Validate(), `metadata_eviction_policy "lru" requires a metadata_cardinality_limit`)
+
+	cfg.MetadataEvictionPolicy = "lfu"
+	assert.ErrorContains(t, cfg.Validate(), "metadata_eviction_policy")
+}
+
+func TestValidateConfig_MetadataBatcherIdleTimeout(t *testing.T) {
+	cfg := &Config{MetadataBatcherIdleTimeout: -time.Second}
+	assert.ErrorContains(t, cfg.Validate(), "metadata_batcher_idle_timeout")
+}
+
+func TestValidateConfig_DrainTimeout(t *testing.T) {
+	cfg := &Config{DrainTimeout: -time.Second}
+	assert.ErrorContains(t, cfg.Validate(), "drain_timeout")
+}
+
+func TestConfigShutdownParallelism(t *testing.T) {
+	cfg := &Config{}
+	assert.Equal(t, runtime.NumCPU(), cfg.shutdownParallelism())
+	cfg.ShutdownParallelism = 4
+	assert.Equal(t, 4, cfg.shutdownParallelism())
+}
+
+func TestUnmarshalConfig_Overrides(t *testing.T) {
+	cm := confmap.NewFromStringMap(map[string]any{
+		"metadata_keys": []any{"x-tenant"},
+		"overrides": []any{
+			map[string]any{
+				"metadata":      map[string]any{"x-tenant": "big"},
+				"min_size":      8192,
+				"flush_timeout": "1s",
+			},
+		},
+	})
+	cfg := NewDefaultConfig()
+	require.NoError(t, component.UnmarshalConfig(cm, cfg))
+	require.Len(t, cfg.Overrides, 1)
+	o := cfg.Overrides[0]
+	assert.Equal(t, map[string]string{"x-tenant": "big"}, o.Metadata)
+	require.NotNil(t, o.SendBatchSize)
+	assert.Equal(t, uint32(8192), *o.SendBatchSize)
+	assert.Nil(t, o.SendBatchMaxSize)
+	require.NotNil(t, o.Timeout)
+	assert.Equal(t, time.Second, *o.Timeout)
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestUnmarshalConfig_DeprecatedNames(t *testing.T) {
+	tests := []struct {
+		name       string
+		conf       map[string]any
+		deprecated []string
+	}{
+		{
+			name: "deprecated only",
+			conf: map[string]any{
+				"send_batch_size":           100,
+				"send_batch_max_size":       200,
+				"timeout":                   "0s",
+				"send_batch_size_bytes":     1000,
+				"send_batch_max_size_bytes": 2000,
+				"overrides": []any{
+					map[string]any{"metadata": map[string]any{"x-tenant": "a"}, "send_batch_size": 10},
+				},
+				"signals": map[string]any{
+					"logs": map[string]any{"timeout": "1s"},
+				},
+			},
+			deprecated: []string{
+				"send_batch_size (use min_size)",
+				"send_batch_max_size (use max_size)",
+				"timeout (use flush_timeout)",
+				"send_batch_size_bytes (use min_size_bytes)",
+				"send_batch_max_size_bytes (use max_size_bytes)",
+				"overrides[0]::send_batch_size (use overrides[0]::min_size)",
+				"signals::logs::timeout (use signals::logs::flush_timeout)",
+			},
+		},
+		{
+			name: "current only",
+			conf: map[string]any{
+				"min_size":       100,
+				"max_size":       200,
+				"flush_timeout":  "0s",
+				"min_size_bytes": 1000,
+				"max_size_bytes": 2000,
+				"overrides": []any{
+					map[string]any{"metadata": map[string]any{"x-tenant": "a"}, "min_size": 10},
+				},
+				"signals": map[string]any{
+					"logs": map[string]any{"flush_timeout": "1s"},
+				},
+			},
+		},
+		{
+			name: "mixed",
+			conf: map[string]any{
+				"min_size":                  100,
+				"send_batch_size":           5,
+				"max_size":                  200,
+				"timeout":                   "0s",
+				"min_size_bytes":            1000,
+				"send_batch_max_size_bytes": 2000,
+				"overrides": []any{
+					map[string]any{"metadata": map[string]any{"x-tenant": "a"}, "min_size": 10, "send_batch_size": 20},
+				},
+				"signals": map[string]any{
+					"logs": map[string]any{"flush_timeout": "1s"},
+				},
+			},
+			deprecated: []string{
+				"send_batch_size (ignored, min_size is set)",
+				"timeout (use flush_timeout)",
+				"send_batch_max_size_bytes (use max_size_bytes)",
+				"overrides[0]::send_batch_size (ignored, overrides[0]::min_size is set)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewDefaultConfig()
+			cfg.MetadataKeys = []string{"x-tenant"}
+			require.NoError(t, component.UnmarshalConfig(confmap.NewFromStringMap(tt.conf), cfg))
+			require.NoError(t, cfg.Validate())
+			assert.Equal(t, uint32(100), cfg.SendBatchSize)
+			assert.Equal(t, uint32(200), cfg.SendBatchMaxSize)
+			// The explicit zero replaces the default timeout.
+			assert.Zero(t, cfg.Timeout)
+			assert.Equal(t, uint64(1000), cfg.SendBatchSizeBytes)
+			assert.Equal(t, uint64(2000), cfg.SendBatchMaxSizeBytes)
+			require.Len(t, cfg.Overrides, 1)
+			require.NotNil(t, cfg.Overrides[0].SendBatchSize)
+			assert.Equal(t, uint32(10), *cfg.Overrides[0].SendBatchSize)
+			require.NotNil(t, cfg.Signals.Logs.Timeout)
+			assert.Equal(t, time.Second, *cfg.Signals.Logs.Timeout)
+			assert.Equal(t, tt.deprecated, cfg.deprecatedNames)
+
+			// The config is marshaled with the current names.
+			conf := confmap.New()
+			require.NoError(t, conf.Marshal(cfg))
+			for _, r := range renamedSettings {
+				assert.False(t, conf.IsSet(r.deprecated), r.deprecated)
+				assert.True(t, conf.IsSet(r.name), r.name)
+			}
+			roundTrip := NewDefaultConfig()
+			require.NoError(t, component.UnmarshalConfig(conf, roundTrip))
+			assert.Equal(t, cfg.SendBatchSize, roundTrip.SendBatchSize)
+			assert.Equal(t, cfg.SendBatchMaxSize, roundTrip.SendBatchMaxSize)
+			assert.Equal(t, cfg.Timeout, roundTrip.Timeout)
+			assert.Equal(t, cfg.SendBatchSizeBytes, roundTrip.SendBatchSizeBytes)
+			assert.Equal(t, cfg.SendBatchMaxSizeBytes, roundTrip.SendBatchMaxSizeBytes)
+			assert.Equal(t, cfg.Overrides, roundTrip.Overrides)
+			assert.Equal(t, cfg.Signals, roundTrip.Signals)
+			assert.Empty(t, roundTrip.deprecatedNames)
+		})
+	}
+}
+
+func TestValidateConfig_Overrides(t *testing.T) {
+	maxSize := uint32(10)
+	cfg := &Config{
+		SendBatchSize: 100,
+		MetadataKeys:  []string{"x-tenant", "x-scope-*"},
+		Overrides: []BatchOverride{
+			{Metadata: map[string]string{"X-Tenant": "a"}},
+		},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Overrides[0].SendBatchMaxSize = &maxSize
+	assert.EqualError(t, cfg.Validate(), "overrides[0]: max_size (10) must be greater or equal to min_size (100)")
+
+	timeout := -time.Second
+	cfg.Overrides[0] = BatchOverride{Metadata: map[string]string{"x-tenant": "a"}, Timeout: &timeout}
+	assert.EqualError(t, cfg.Validate(), "overrides[0]: flush_timeout must be greater or equal to 0, got -1s")
+
+	cfg.Overrides[0] = BatchOverride{}
+	assert.ErrorContains(t, cfg.Validate(), "must not be empty")
+}
+
+func TestValidateConfig_OverridesUnknownKey(t *testing.T) {
+	cfg := &Config{
+		MetadataKeys: []string{"x-tenant", "x-scope-*"},
+		Overrides: []BatchOverride{
+			{Metadata: map[string]string{"x-tenant": "a"}},
+			{Metadata: map[string]string{"x-region": "a"}},
+		},
+	}
+	assert.EqualError(t, cfg.Validate(), `overrides[1]: key "x-region" is not listed in metadata_keys`)
+
+	cfg.Overrides[1] = BatchOverride{Metadata: map[string]string{"x-scope-a": "a"}}
+	assert.EqualError(t, cfg.Validate(), `overrides[1]: key "x-scope-a" is not listed in metadata_keys`)
+}
+
+func TestValidateConfig_OverridesAllowedValues(t *testing.T) {
+	cfg := &Config{
+		MetadataKeys: []string{"x-tenant"},
+		MetadataKeySettings: []MetadataKeySettings{
+			{Key: "X-Tenant", AllowedValues: []string{"a", "B"}},
+		},
+		Overrides: []BatchOverride{
+			{Metadata: map[string]string{"x-tenant": "a"}},
+			{Metadata: map[string]string{"x-tenant": metadataOtherValue}},
+		},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Overrides[1] = BatchOverride{Metadata: map[string]string{"x-tenant": "b"}}
+	assert.EqualError(t, cfg.Validate(), `overrides[1]: value "b" of key "x-tenant" is not in its metadata_key_settings allowed_values, the override would never apply`)
+
+	cfg.MetadataKeySettings[0].CaseInsensitiveValues = true
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestUnmarshalConfig_Signals(t *testing.T) {
+	cm := confmap.NewFromStringMap(map[string]any{
+		"min_size": 8192,
+		"signals": map[string]any{
+			"logs": map[string]any{
+				"min_size": 1024,
+				"max_size": 2048,
+			},
+			"metrics": map[string]any{
+				"flush_timeout": "1s",
+			},
+		},
+	})
+	cfg := NewDefaultConfig()
+	require.NoError(t, component.UnmarshalConfig(cm, cfg))
+	assert.NoError(t, cfg.Validate())
+
+	traces := cfg.forSignal(component.DataTypeTraces)
+	assert.Equal(t, uint32(8192), traces.SendBatchSize)
+	assert.Equal(t, uint32(0), traces.SendBatchMaxSize)
+	assert.Equal(t, 200*time.Millisecond, traces.Timeout)
+	assert.Equal(t, SignalsConfig{}, traces.Signals)
+
+	metrics := cfg.forSignal(component.DataTypeMetrics)
+	assert.Equal(t, uint32(8192), metrics.SendBatchSize)
+	assert.Equal(t, time.Second, metrics.Timeout)
+
+	logs := cfg.forSignal(component.DataTypeLogs)
+	assert.Equal(t, uint32(1024), logs.SendBatchSize)
+	assert.Equal(t, uint32(2048), logs.SendBatchMaxSize)
+	assert.Equal(t, 200*time.Millisecond, logs.Timeout)
+}
+
+func TestValidateConfig_Signals(t *testing.T) {
+	maxSize := uint32(100)
+	cfg := &Config{
+		SendBatchSize: 1000,
+		Signals: SignalsConfig{
+			Traces: SignalConfig{SendBatchMaxSize: &maxSize},
+		},
+	}
+	assert.EqualError(t, cfg.Validate(), "signals::traces: max_size (100) must be greater or equal to min_size (1000)")
+
+	size := uint32(10)
+	cfg.Signals.Traces.SendBatchSize = &size
+	assert.NoError(t, cfg.Validate())
+
+	// Overrides apply on top of the settings of the signal.
+	cfg.MetadataKeys = []string{"x-tenant"}
+	cfg.Overrides = []BatchOverride{{Metadata: map[string]string{"x-tenant": "a"}, SendBatchSize: &cfg.SendBatchSize}}
+	assert.EqualError(t, cfg.Validate(), "signals::traces: overrides[0]: max_size (100) must be greater or equal to min_size (1000)")
+
+	timeout := -time.Second
+	cfg.Overrides = nil
+	cfg.Signals.Logs.Timeout = &timeout
+	assert.EqualError(t, cfg.Validate(), "signals::logs: flush_timeout must be greater or equal to 0, got -1s")
+}
+
+func TestValidateConfig_PropagateMetadata(t *testing.T) {
+	cfg := &Config{PropagateMetadata: propagateMetadataAll}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.PropagateMetadata = "some"
+	assert.ErrorContains(t, cfg.Validate(), "propagate_metadata")
+}
+
+func TestValidateConfig_PropagateClientInfo(t *testing.T) {
+	cfg := &Config{PropagateClientInfo: []string{propagateClientInfoAddr, propagateClientInfoAuth, propagateClientInfoSource}}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.PropagateClientInfo = []string{"metadata"}
+	assert.ErrorContains(t, cfg.Validate(), "propagate_client_info")
+}
+
+func TestUnmarshalConfig_GroupBy(t *testing.T) {
+	cm := confmap.NewFromStringMap(map[string]any{
+		"group_by": []any{
+			map[string]any{"metadata": "x-tenant"},
+			map[string]any{"resource": "deployment.environment"},
+			map[string]any{"source": true},
+		},
+	})
+	cfg := NewDefaultConfig()
+	require.NoError(t, component.UnmarshalConfig(cm, cfg))
+	assert.Equal(t, []GroupBySource{
+		{Metadata: "x-tenant"},
+		{Resource: "deployment.environment"},
+		{Source: true},
+	}, cfg.GroupBy)
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, []string{"x-tenant"}, cfg.metadataKeys())
+	assert.Equal(t, []string{"deployment.environment"}, cfg.resourceAttributeKeys())
+	assert.True(t, cfg.groupBySource())
+}
+
+func TestValidateConfig_GroupBy(t *testing.T) {
+	cfg := &Config{GroupBy: []GroupBySource{{Metadata: "x-tenant", Resource: "tenant"}}}
+	assert.ErrorContains(t, cfg.Validate(), "exactly one")
+
+	cfg.GroupBy = []GroupBySource{{}}
+	assert.ErrorContains(t, cfg.Validate(), "exactly one")
+
+	cfg.GroupBy = []GroupBySource{{Resource: "tenant", Source: true}}
+	assert.ErrorContains(t, cfg.Validate(), "exactly one")
+
+	cfg.GroupBy = []GroupBySource{{Source: true}, {Source: true}}
+	assert.ErrorContains(t, cfg.Validate(), "duplicate entry in group_by: source")
+
+	cfg = &Config{
+		MetadataKeys: []string{"x-tenant"},
+		GroupBy:      []GroupBySource{{Metadata: "X-Tenant"}},
+	}
+	assert.ErrorContains(t, cfg.Validate(), "duplicate entry")
+
+	cfg = &Config{
+		ResourceAttributeKeys: []string{"tenant"},
+		GroupBy:               []GroupBySource{{Resource: "tenant"}},
+	}
+	assert.ErrorContains(t, cfg.Validate(), "duplicate entry")
+}
+
+func TestValidateConfig_Retry(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Retry.InitialInterval = 0
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Retry.Enabled = true
+	assert.ErrorContains(t, cfg.Validate(), "initial_interval")
+
+	cfg.Retry.InitialInterval = time.Minute
+	assert.ErrorContains(t, cfg.Validate(), "max_interval")
+
+	cfg.Retry.MaxInterval = time.Minute
+	cfg.Retry.MaxElapsedTime = -time.Second
+	assert.ErrorContains(t, cfg.Validate(), "max_elapsed_time")
+
+	cfg.Retry.MaxElapsedTime = 0
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidateConfig_FailureLogInterval(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.FailureLogInterval = -time.Second
+	assert.ErrorContains(t, cfg.Validate(), "failure_log_interval")
+}
+
+func TestBypassConfigSeverityNumber(t *testing.T) {
+	sn, ok := BypassConfig{LogSeverity: "ERROR"}.SeverityNumber()
+	assert.True(t, ok)
+	assert.Equal(t, plog.SeverityNumberError, sn)
+
+	sn, ok = BypassConfig{LogSeverity: "warn2"}.SeverityNumber()
+	assert.True(t, ok)
+	assert.Equal(t, plog.SeverityNumberWarn2, sn)
+
+	_, ok = BypassConfig{LogSeverity: "critical"}.SeverityNumber()
+	assert.False(t, ok)
+}
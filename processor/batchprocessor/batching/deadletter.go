@@ -0,0 +1,47 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching // import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+)
+
+// sendDeadLetter hands the data of a failed export to the dead-letter
+// consumer.  Its failures are logged and counted as dropped, with attrs
+// and classified by the permanence of their error.
+func (bp *Processor) sendDeadLetter(ctx context.Context, attrs *telemetryAttrs, data any) {
+	items := bp.settings.Count(data)
+	if err := bp.deadLetter(ctx, data); err != nil {
+		bp.logger.Warn("Dead-letter consumer failed",
+			zap.String("data_type", string(bp.dataType)),
+			zap.Int("dropped_items", items),
+			zap.Error(err))
+		reason := reasonSendFailed
+		if consumererror.IsPermanent(err) {
+			reason = reasonSendFailedPermanent
+		}
+		bytes := 0
+		if bp.telemetry.enabled.droppedBytes {
+			bytes = bp.sizeItems(data)
+		}
+		bp.telemetry.recordDropped(attrs, int64(items), int64(bytes), reason)
+		return
+	}
+	bp.telemetry.recordDeadLetterItems(int64(items))
+}
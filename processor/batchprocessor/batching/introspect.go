@@ -0,0 +1,204 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching // import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+import (
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"go.opentelemetry.io/collector/component"
+)
+
+// Introspector is implemented by the batch processor, the batch
+// connector, and Batcher, for debugging pages and other components to
+// list the data pending in their batchers.  The connector is found
+// among the exporters returned by component.Host.GetExporters.
+type Introspector interface {
+	// Snapshot returns the current state of the batchers.
+	Snapshot() Snapshot
+}
+
+// Snapshot is the state of the batchers of a processor.
+type Snapshot struct {
+	// DataType is the type of the data batched.
+	DataType component.DataType
+
+	// Batchers are ordered by their attributes.
+	Batchers []BatcherSnapshot
+}
+
+// BatcherSnapshot is the state of a batcher.
+type BatcherSnapshot struct {
+	// ID is the BatcherID of the batcher.
+	ID uint64
+
+	// Attributes are the metadata and resource values identifying
+	// the batcher, empty for the only batcher of a processor without
+	// metadata keys and for the overflow batcher.
+	Attributes []attribute.KeyValue
+
+	// Overflow is set for the batcher grouping the combinations
+	// beyond metadata_cardinality_limit.
+	Overflow bool
+
+	// Items is the number of spans, data points, or log records of
+	// the pending batch.
+	Items int
+
+	// Bytes is the estimated size of the pending batch, zero unless
+	// send_size_bytes is enabled.
+	Bytes int
+
+	// Queued is the number of requests waiting to be added to the
+	// batch, out of QueueCapacity.
+	Queued        int
+	QueueCapacity int
+
+	// SinceLastExport is the time since the batcher last exported a
+	// batch, or since it was created.
+	SinceLastExport time.Duration
+
+	// LastError is the error of the last failed export, nil when no
+	// export failed.
+	LastError error
+}
+
+// batcherState is the state of a batcher read by snapshots, copied from
+// the batcher goroutine.
+type batcherState struct {
+	items      int
+	bytes      int
+	lastExport time.Time
+	lastErr    error
+
+	// lastFlush is the time of the last successful export, or of the
+	// creation of the batcher.
+	lastFlush time.Time
+}
+
+var _ Introspector = (*Processor)(nil)
+
+// Snapshot implements Introspector.  Each batcher is only locked while
+// its state is copied.
+func (bp *Processor) Snapshot() Snapshot {
+	batchers := bp.allBatchers()
+	keys := make(map[*batcher]string, len(batchers))
+	for _, b := range batchers {
+		keys[b] = b.key.Encoded(attribute.DefaultEncoder())
+	}
+	sort.Slice(batchers, func(i, j int) bool {
+		return keys[batchers[i]] < keys[batchers[j]]
+	})
+	snap := Snapshot{DataType: bp.dataType, Batchers: make([]BatcherSnapshot, 0, len(batchers))}
+	now := time.Now()
+	for _, b := range batchers {
+		b.stateLock.Lock()
+		state := b.state
+		b.stateLock.Unlock()
+		snap.Batchers = append(snap.Batchers, BatcherSnapshot{
+			ID:              b.id,
+			Attributes:      b.key.ToSlice(),
+			Overflow:        b.overflow,
+			Items:           state.items,
+			Bytes:           state.bytes,
+			Queued:          len(b.newItem),
+			QueueCapacity:   cap(b.newItem),
+			SinceLastExport: now.Sub(state.lastExport),
+			LastError:       state.lastErr,
+		})
+	}
+	return snap
+}
+
+// publishState copies the state of the pending batch for snapshots.
+func (b *batcher) publishState() {
+	b.stateLock.Lock()
+	b.state.items = b.batch.ItemCount()
+	b.state.bytes = b.batch.ByteSize()
+	b.stateLock.Unlock()
+}
+
+// publishExport records an export for snapshots, along with the state
+// of the pending batch.
+func (b *batcher) publishExport(err error) {
+	b.stateLock.Lock()
+	b.state.items = b.batch.ItemCount()
+	b.state.bytes = b.batch.ByteSize()
+	b.state.lastExport = time.Now()
+	if err != nil {
+		b.state.lastErr = err
+	} else {
+		b.state.lastFlush = b.state.lastExport
+	}
+	b.stateLock.Unlock()
+}
+
+// queueUsage sums the state of the batchers recording their metrics
+// with attrs: the requests waiting in their channels, the capacity of
+// the channels, and the items of their pending batches.  staleness is
+// the longest time since one of them with a pending batch last
+// exported successfully.
+type queueUsage struct {
+	attrs        *telemetryAttrs
+	length       int64
+	capacity     int64
+	pendingItems int64
+	staleness    time.Duration
+}
+
+// queueUsage returns the queueUsage of the batchers, a single one
+// summing all of them unless telemetry_include_metadata is set.
+func (bp *Processor) queueUsage() []queueUsage {
+	usages := []queueUsage{{attrs: bp.telemetry.attrs}}
+	index := map[attribute.Distinct]int{bp.telemetry.attrs.set.Equivalent(): 0}
+	now := time.Now()
+	for _, b := range bp.allBatchers() {
+		i, ok := index[b.attrs.set.Equivalent()]
+		if !ok {
+			i = len(usages)
+			index[b.attrs.set.Equivalent()] = i
+			usages = append(usages, queueUsage{attrs: b.attrs})
+		}
+		usage := &usages[i]
+		usage.length += int64(len(b.newItem))
+		usage.capacity += int64(cap(b.newItem))
+		b.stateLock.Lock()
+		usage.pendingItems += int64(b.state.items)
+		if staleness := now.Sub(b.state.lastFlush); b.state.items > 0 && staleness > usage.staleness {
+			usage.staleness = staleness
+		}
+		b.stateLock.Unlock()
+	}
+	return usages
+}
+
+func (sb *singleBatcher) allBatchers() []*batcher {
+	return []*batcher{sb.batcher}
+}
+
+func (mb *multiBatcher) allBatchers() []*batcher {
+	mb.lock.RLock()
+	defer mb.lock.RUnlock()
+	batchers := make([]*batcher, 0, len(mb.batchers)+1)
+	for _, b := range mb.batchers {
+		batchers = append(batchers, b)
+	}
+	if mb.overflow != nil {
+		batchers = append(batchers, mb.overflow)
+	}
+	return batchers
+}
@@ -0,0 +1,164 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching // import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/collector/client"
+)
+
+// metadataOtherValue replaces metadata values that are not allowed.
+const metadataOtherValue = "__other__"
+
+// metadataOverflowValue replaces new values of a metadata key that
+// has reached its distinct value limit.
+const metadataOverflowValue = "__overflow__"
+
+// metadataKeyHandler applies the MetadataKeySettings of one key to
+// incoming metadata values.
+type metadataKeyHandler struct {
+	normalizers     []func(string) string
+	caseInsensitive bool
+	allowed         map[string]bool
+	omitOther       bool
+	limit           int
+}
+
+// limitedValue is the value of a metadata key with a distinct value
+// limit, as used to identify a batcher.
+type limitedValue struct {
+	key      string
+	value    string
+	limit    int
+	overflow bool
+}
+
+const normalizePrefix = "prefix:"
+
+// newNormalizer returns the transformation named by a Normalize entry.
+func newNormalizer(name string) (func(string) string, error) {
+	switch name {
+	case "lowercase":
+		return strings.ToLower, nil
+	case "trim":
+		return strings.TrimSpace, nil
+	case "hash":
+		return func(v string) string {
+			sum := sha256.Sum256([]byte(v))
+			return hex.EncodeToString(sum[:])
+		}, nil
+	}
+	if strings.HasPrefix(name, normalizePrefix) {
+		n, err := strconv.Atoi(name[len(normalizePrefix):])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid prefix length in %q", name)
+		}
+		return func(v string) string {
+			if len(v) > n {
+				return v[:n]
+			}
+			return v
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown transformation %q", name)
+}
+
+// newMetadataKeyHandlers returns the handlers for the configured
+// settings, indexed by lower-case key.
+func newMetadataKeyHandlers(settings []MetadataKeySettings) map[string]*metadataKeyHandler {
+	if len(settings) == 0 {
+		return nil
+	}
+	handlers := make(map[string]*metadataKeyHandler, len(settings))
+	for _, ks := range settings {
+		h := &metadataKeyHandler{
+			caseInsensitive: ks.CaseInsensitiveValues,
+			omitOther:       ks.OtherValues == otherValuesOmit,
+			limit:           int(ks.Limit),
+		}
+		if h.caseInsensitive {
+			h.normalizers = append(h.normalizers, strings.ToLower)
+		}
+		for _, name := range ks.Normalize {
+			// Names were checked by Config.Validate.
+			if n, err := newNormalizer(name); err == nil {
+				h.normalizers = append(h.normalizers, n)
+			}
+		}
+		if len(ks.AllowedValues) != 0 {
+			h.allowed = make(map[string]bool, len(ks.AllowedValues))
+			for _, v := range ks.AllowedValues {
+				if h.caseInsensitive {
+					v = strings.ToLower(v)
+				}
+				h.allowed[v] = true
+			}
+		}
+		handlers[strings.ToLower(ks.Key)] = h
+	}
+	return handlers
+}
+
+// normalize applies the configured transformations to vs in place.
+func (h *metadataKeyHandler) normalize(vs []string) []string {
+	for _, n := range h.normalizers {
+		for i, v := range vs {
+			vs[i] = n(v)
+		}
+	}
+	return vs
+}
+
+// apply returns the values identifying the batcher, the values for the
+// outgoing metadata (nil to omit the key), and whether the values were
+// replaced by metadataOtherValue.
+func (h *metadataKeyHandler) apply(vs []string) (key []string, outgoing []string, other bool) {
+	if h.allowed == nil || len(vs) == 0 {
+		return vs, vs, false
+	}
+	for _, v := range vs {
+		if !h.allowed[v] {
+			key = []string{metadataOtherValue}
+			if h.omitOther {
+				return key, nil, true
+			}
+			return key, key, true
+		}
+	}
+	return vs, vs, false
+}
+
+// limitMetadata returns ctx with its client metadata within
+// metadataValueLimit, counting the values cut or dropped.
+func (bp *Processor) limitMetadata(ctx context.Context) context.Context {
+	limit := bp.metadataValueLimit
+	if limit.MaxLength == 0 && limit.MaxCount == 0 {
+		return ctx
+	}
+	info := client.FromContext(ctx)
+	md, n := client.TruncateMetadata(info.Metadata, int(limit.MaxLength), int(limit.MaxCount))
+	if n == 0 {
+		return ctx
+	}
+	bp.telemetry.recordTruncatedValues(int64(n))
+	info.Metadata = md
+	return client.NewContext(ctx, info)
+}
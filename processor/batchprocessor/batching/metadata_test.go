@@ -0,0 +1,130 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+func TestMetadataKeyHandlerAllowedValues(t *testing.T) {
+	handlers := newMetadataKeyHandlers([]MetadataKeySettings{
+		{Key: "X-Tenant", AllowedValues: []string{"a", "b"}},
+		{Key: "x-region", AllowedValues: []string{"us"}, OtherValues: otherValuesOmit},
+	})
+	require.Len(t, handlers, 2)
+
+	tenant := handlers["x-tenant"]
+	key, outgoing, other := tenant.apply([]string{"a"})
+	assert.Equal(t, []string{"a"}, key)
+	assert.Equal(t, []string{"a"}, outgoing)
+	assert.False(t, other)
+
+	key, outgoing, other = tenant.apply([]string{"a", "garbage"})
+	assert.Equal(t, []string{metadataOtherValue}, key)
+	assert.Equal(t, []string{metadataOtherValue}, outgoing)
+	assert.True(t, other)
+
+	// Unset metadata is not grouped.
+	key, outgoing, other = tenant.apply(nil)
+	assert.Nil(t, key)
+	assert.Nil(t, outgoing)
+	assert.False(t, other)
+
+	key, outgoing, other = handlers["x-region"].apply([]string{"eu"})
+	assert.Equal(t, []string{metadataOtherValue}, key)
+	assert.Nil(t, outgoing)
+	assert.True(t, other)
+}
+
+func TestNewNormalizer(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output string
+	}{
+		{name: "lowercase", input: "ACME", output: "acme"},
+		{name: "trim", input: "  acme\t", output: "acme"},
+		{name: "prefix:4", input: "acme-0123456789", output: "acme"},
+		{name: "prefix:40", input: "acme", output: "acme"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := newNormalizer(tt.name)
+			require.NoError(t, err)
+			assert.Equal(t, tt.output, n(tt.input))
+		})
+	}
+
+	hash, err := newNormalizer("hash")
+	require.NoError(t, err)
+	assert.Len(t, hash("acme"), 64)
+	assert.Equal(t, hash("acme"), hash("acme"))
+	assert.NotEqual(t, hash("acme"), hash("globex"))
+
+	for _, name := range []string{"upper", "prefix:", "prefix:0", "prefix:x"} {
+		_, err := newNormalizer(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestMetadataKeyHandlerNormalize(t *testing.T) {
+	handlers := newMetadataKeyHandlers([]MetadataKeySettings{
+		{Key: "x-tenant", Normalize: []string{"trim", "lowercase", "prefix:4"}, AllowedValues: []string{"acme"}},
+	})
+	h := handlers["x-tenant"]
+	assert.Equal(t, []string{"acme", "glob"}, h.normalize([]string{" ACME-token ", "Globex"}))
+
+	// Values are normalized before checking the allow-list.
+	key, _, other := h.apply(h.normalize([]string{"AcMe-1234"}))
+	assert.Equal(t, []string{"acme"}, key)
+	assert.False(t, other)
+}
+
+func TestNewMetadataKeyHandlersEmpty(t *testing.T) {
+	assert.Nil(t, newMetadataKeyHandlers(nil))
+}
+
+func TestBatchProcessorMetadataKeyLimitReleasedOnExpiry(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.MetadataKeys = []string{"x-source"}
+	cfg.MetadataKeySettings = []MetadataKeySettings{
+		{Key: "x-source", Limit: 1},
+	}
+	cfg.MetadataBatcherIdleTimeout = 10 * time.Millisecond
+	b, err := NewBatcher(processortest.NewNopCreateSettings(), cfg, (&intsSink{}).settings())
+	require.NoError(t, err)
+	require.NoError(t, b.Start(context.Background(), componenttest.NewNopHost()))
+
+	ctx := client.NewContext(context.Background(), client.Info{
+		Metadata: client.NewMetadata(map[string][]string{"x-source": {"s1"}}),
+	})
+	require.NoError(t, b.Add(ctx, []int{1}))
+	assert.Equal(t, map[string]int{"x-source": 1}, b.p.currentMetadataKeyCardinality())
+
+	require.Eventually(t, func() bool {
+		return b.p.currentMetadataKeyCardinality()["x-source"] == 0
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, b.Shutdown(context.Background()))
+}
@@ -0,0 +1,673 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching // import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/internal/obsreportconfig/obsmetrics"
+	"go.opentelemetry.io/collector/obsreport"
+	"go.opentelemetry.io/collector/processor"
+)
+
+const (
+	scopeName = "go.opentelemetry.io/collector/processor/batchprocessor"
+
+	// metadataKeyAttr is the attribute naming the metadata key of
+	// per-key metrics.
+	metadataKeyAttr = "metadata_key"
+
+	// permanenceAttr is the attribute classifying the errors of
+	// failed exports, with the values below.
+	permanenceAttr      = "permanence"
+	permanenceRetryable = "retryable"
+	permanencePermanent = "permanent"
+
+	// signalAttr, triggerAttr, and outcomeAttr classify the
+	// exports measured by the send latency, outcomeAttr with the
+	// values below.
+	signalAttr     = "signal"
+	triggerAttr    = "trigger"
+	outcomeAttr    = "outcome"
+	outcomeSuccess = "success"
+	outcomeFailure = "failure"
+
+	// reasonAttr is the attribute classifying the dropped data, with
+	// the values below.
+	reasonAttr                = "reason"
+	reasonSendFailedPermanent = "send_failed_permanent"
+	reasonSendFailed          = "send_failed"
+	reasonShutdownAbandoned   = "shutdown_abandoned"
+	reasonQueueOverflow       = "queue_overflow"
+
+	// removalEvicted, removalExpired, and removalShutdown are the
+	// values of reasonAttr classifying the removal of batchers.
+	removalEvicted  = "evicted"
+	removalExpired  = "expired"
+	removalShutdown = "shutdown"
+)
+
+type trigger int
+
+const (
+	triggerTimeout trigger = iota
+	triggerBatchSize
+	triggerBypass
+	triggerResourceChange
+	triggerShutdown
+)
+
+// batchProcessorTelemetry records the metrics of a processor with the
+// OTel MeterProvider of its settings.  Which metrics are recorded
+// depends on the telemetry level:
+//   - none: no metrics.
+//   - basic: the counts of exports, items, drops, and batchers, the
+//     batch_send_size histogram, and the gauges.
+//   - normal: the send_latency and batch_age histograms, and the
+//     attributes identifying batchers with telemetry_include_metadata.
+//   - detailed: the byte sizes of the data sent and dropped, unless
+//     MetricsConfig says otherwise.
+type batchProcessorTelemetry struct {
+	level   configtelemetry.Level
+	enabled enabledMetrics
+
+	// latency is set when the send latency and the batch age are
+	// recorded, from the normal level, for exports of signal.
+	latency bool
+	signal  string
+
+	// includeMetadata adds the attributes identifying batchers to
+	// their metrics, attrs being those of the processor.
+	includeMetadata bool
+	attrs           *telemetryAttrs
+
+	processorAttr            []attribute.KeyValue
+	batchSizeTriggerSend     metric.Int64Counter
+	timeoutTriggerSend       metric.Int64Counter
+	bypassTriggerSend        metric.Int64Counter
+	resourceChangeSend       metric.Int64Counter
+	shutdownTriggerSend      metric.Int64Counter
+	batchSendSize            metric.Int64Histogram
+	batchSendSizeBytes       metric.Int64Histogram
+	droppedItems             metric.Int64Counter
+	droppedBytes             metric.Int64Counter
+	otherValuesItems         metric.Int64Counter
+	truncatedValues          metric.Int64Counter
+	overflowItems            metric.Int64Counter
+	batchMetadataCardinality metric.Int64ObservableUpDownCounter
+	metadataKeyCardinality   metric.Int64ObservableGauge
+	inFlightItems            metric.Int64ObservableGauge
+	queueLength              metric.Int64ObservableGauge
+	queueCapacity            metric.Int64ObservableGauge
+	pendingItems             metric.Int64ObservableGauge
+	activeBatchers           metric.Int64ObservableGauge
+	staleness                metric.Float64ObservableGauge
+	batchersCreated          metric.Int64Counter
+	batchersRemoved          metric.Int64Counter
+	batchSizeSplit           metric.Int64Counter
+	itemsReceived            metric.Int64Counter
+	splitLeftoverItems       metric.Int64Counter
+	deadLetterItems          metric.Int64Counter
+	batchSendFailed          metric.Int64Counter
+	batchSendFailedBytes     metric.Int64Counter
+	batchItemsRejected       metric.Int64Counter
+	metricMergeConflicts     metric.Int64Counter
+	readOnlyCopies           metric.Int64Counter
+	sendLatency              metric.Float64Histogram
+	batchAge                 metric.Float64Histogram
+
+	// registration is the callback observing the gauges, unregistered
+	// on shutdown.
+	registration metric.Registration
+}
+
+func newBatchProcessorTelemetry(set processor.CreateSettings, cfg *Config, dataType component.DataType, currentMetadataCardinality func() int, currentMetadataKeyCardinality func() map[string]int, currentInFlightItems func() int64, currentQueueUsage func() []queueUsage, currentActiveBatchers func() int64) (*batchProcessorTelemetry, error) {
+	bpt := &batchProcessorTelemetry{
+		processorAttr: []attribute.KeyValue{attribute.String(obsmetrics.ProcessorKey, set.ID.String())},
+		level:         set.MetricsLevel,
+		enabled:       newEnabledMetrics(set.MetricsLevel, cfg.Metrics),
+		latency:       set.MetricsLevel >= configtelemetry.LevelNormal,
+		signal:        string(dataType),
+
+		includeMetadata: cfg.TelemetryIncludeMetadata && set.MetricsLevel >= configtelemetry.LevelNormal,
+	}
+	bpt.attrs = newTelemetryAttrs(bpt.processorAttr)
+
+	mp := set.MeterProvider
+	if set.MetricsLevel == configtelemetry.LevelNone {
+		mp = noop.NewMeterProvider()
+	}
+	err := bpt.createOtelMetrics(mp, currentMetadataCardinality, currentMetadataKeyCardinality, currentInFlightItems, currentQueueUsage, currentActiveBatchers)
+	if err != nil {
+		return nil, err
+	}
+
+	return bpt, nil
+}
+
+func (bpt *batchProcessorTelemetry) createOtelMetrics(mp metric.MeterProvider, currentMetadataCardinality func() int, currentMetadataKeyCardinality func() map[string]int, currentInFlightItems func() int64, currentQueueUsage func() []queueUsage, currentActiveBatchers func() int64) error {
+	var err error
+	meter := mp.Meter(scopeName)
+
+	bpt.batchSizeTriggerSend, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "batch_size_trigger_send"),
+		metric.WithDescription("Number of times the batch was sent due to a size trigger"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.timeoutTriggerSend, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "timeout_trigger_send"),
+		metric.WithDescription("Number of times the batch was sent due to a timeout trigger"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.resourceChangeSend, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "resource_change_trigger_send"),
+		metric.WithDescription("Number of times the batch was sent due to data of other resources"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.shutdownTriggerSend, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "shutdown_trigger_send"),
+		metric.WithDescription("Number of times the batch was sent due to the shutdown of the processor"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.sendLatency, err = meter.Float64Histogram(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "send_latency"),
+		metric.WithDescription("Duration of the exports of batches, including their splitting and sizing"),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.batchAge, err = meter.Float64Histogram(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "batch_age"),
+		metric.WithDescription("Time since the oldest data of a batch was added, when the batch is sent"),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.bypassTriggerSend, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "bypass_trigger_send"),
+		metric.WithDescription("Number of times the batch was sent due to a bypass rule"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.batchSendSize, err = meter.Int64Histogram(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "batch_send_size"),
+		metric.WithDescription("Number of units in the batch"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.batchSendSizeBytes, err = meter.Int64Histogram(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "batch_send_size_bytes"),
+		metric.WithDescription("Number of bytes in batch that was sent"),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.droppedItems, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "dropped_items"),
+		metric.WithDescription("Number of spans, data points, or log records dropped by the processor"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.droppedBytes, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "dropped_bytes"),
+		metric.WithDescription("Number of bytes of the data dropped by the processor"),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.otherValuesItems, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "metadata_other_items"),
+		metric.WithDescription("Number of spans, data points, or log records grouped into the batcher for metadata values not in allowed_values"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.truncatedValues, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "metadata_truncated_values"),
+		metric.WithDescription("Number of client metadata values cut or dropped by metadata_value_limit"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.overflowItems, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "metadata_overflow_items"),
+		metric.WithDescription("Number of spans, data points, or log records routed to the overflow batcher after reaching the metadata cardinality limit"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.batchMetadataCardinality, err = meter.Int64ObservableUpDownCounter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "metadata_cardinality"),
+		metric.WithDescription("Number of distinct metadata value combinations being processed"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.metadataKeyCardinality, err = meter.Int64ObservableGauge(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "metadata_key_cardinality"),
+		metric.WithDescription("Number of distinct values of a metadata key with a limit"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.deadLetterItems, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "dead_letter_items"),
+		metric.WithDescription("Number of spans, data points, or log records of failed exports handed to the dead-letter consumer"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.batchSendFailed, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "batch_send_failed"),
+		metric.WithDescription("Number of spans, data points, or log records in exports that failed"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.batchSendFailedBytes, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "batch_send_failed_bytes"),
+		metric.WithDescription("Number of bytes in exports that failed"),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.batchItemsRejected, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "batch_items_rejected"),
+		metric.WithDescription("Number of spans, data points, or log records rejected by the next consumer"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.metricMergeConflicts, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "metric_merge_conflicts"),
+		metric.WithDescription("Number of metrics not merged into a metric of the same name with another type, unit, temporality, or monotonicity"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.readOnlyCopies, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "read_only_copies"),
+		metric.WithDescription("Number of read-only requests copied before being batched"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.inFlightItems, err = meter.Int64ObservableGauge(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "in_flight_items"),
+		metric.WithDescription("Number of spans, data points, or log records held by the processor, counted against max_in_flight_items"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.queueLength, err = meter.Int64ObservableGauge(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "queue_length"),
+		metric.WithDescription("Number of requests waiting in the channels of the batchers"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.queueCapacity, err = meter.Int64ObservableGauge(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "queue_capacity"),
+		metric.WithDescription("Number of requests the channels of the batchers can hold"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.pendingItems, err = meter.Int64ObservableGauge(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "pending_items"),
+		metric.WithDescription("Number of spans, data points, or log records in the batches not yet sent"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.activeBatchers, err = meter.Int64ObservableGauge(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "active_batchers"),
+		metric.WithDescription("Number of batchers running, including those flushing after their removal"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.batchersCreated, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "batchers_created"),
+		metric.WithDescription("Number of batchers created"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.batchersRemoved, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "batchers_removed"),
+		metric.WithDescription("Number of batchers removed"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.itemsReceived, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "items_received"),
+		metric.WithDescription("Number of spans, data points, or log records accepted by the processor"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.batchSizeSplit, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "batch_size_trigger_split"),
+		metric.WithDescription("Number of times a batch was split to send at most max_size"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.splitLeftoverItems, err = meter.Int64Counter(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "split_leftover_items"),
+		metric.WithDescription("Number of spans, data points, or log records kept in the batch after a split"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.staleness, err = meter.Float64ObservableGauge(
+		obsreport.BuildProcessorCustomMetricName(typeStr, "staleness"),
+		metric.WithDescription("Longest time since a batcher with pending data last exported successfully"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return err
+	}
+
+	bpt.registration, err = meter.RegisterCallback(func(_ context.Context, obs metric.Observer) error {
+		obs.ObserveInt64(bpt.batchMetadataCardinality, int64(currentMetadataCardinality()))
+		for k, n := range currentMetadataKeyCardinality() {
+			attrs := append([]attribute.KeyValue{attribute.String(metadataKeyAttr, k)}, bpt.processorAttr...)
+			obs.ObserveInt64(bpt.metadataKeyCardinality, int64(n), metric.WithAttributes(attrs...))
+		}
+		obs.ObserveInt64(bpt.inFlightItems, currentInFlightItems(), bpt.attrs.opt)
+		obs.ObserveInt64(bpt.activeBatchers, currentActiveBatchers(), bpt.attrs.opt)
+		for _, usage := range currentQueueUsage() {
+			obs.ObserveInt64(bpt.queueLength, usage.length, usage.attrs.opt)
+			obs.ObserveInt64(bpt.queueCapacity, usage.capacity, usage.attrs.opt)
+			obs.ObserveInt64(bpt.pendingItems, usage.pendingItems, usage.attrs.opt)
+			obs.ObserveFloat64(bpt.staleness, usage.staleness.Seconds(), usage.attrs.opt)
+		}
+		return nil
+	}, bpt.batchMetadataCardinality, bpt.metadataKeyCardinality, bpt.inFlightItems, bpt.activeBatchers, bpt.queueLength, bpt.queueCapacity, bpt.pendingItems, bpt.staleness)
+	return err
+}
+
+// unregister stops observing the gauges.
+func (bpt *batchProcessorTelemetry) unregister() error {
+	if bpt.registration == nil {
+		return nil
+	}
+	return bpt.registration.Unregister()
+}
+
+// enabledMetrics tells which of the metrics costly to record are
+// recorded, from the telemetry level and the MetricsConfig.
+type enabledMetrics struct {
+	// sendSizeBytes enables batch_send_size_bytes, for which the
+	// batches track their size in bytes.
+	sendSizeBytes bool
+	// droppedBytes enables dropped_bytes.
+	droppedBytes bool
+}
+
+func newEnabledMetrics(level configtelemetry.Level, cfg MetricsConfig) enabledMetrics {
+	detailed := level == configtelemetry.LevelDetailed
+	enabled := enabledMetrics{sendSizeBytes: detailed, droppedBytes: detailed}
+	if cfg.SendSizeBytes != nil {
+		enabled.sendSizeBytes = *cfg.SendSizeBytes
+	}
+	return enabled
+}
+
+// telemetryAttrs are the attributes of the metrics recorded for a
+// batcher, built once when the batcher is created.
+type telemetryAttrs struct {
+	// list holds the attributes, and opt records them.
+	list []attribute.KeyValue
+	set  attribute.Set
+	opt  metric.MeasurementOption
+}
+
+func newTelemetryAttrs(list []attribute.KeyValue) *telemetryAttrs {
+	set := attribute.NewSet(list...)
+	return &telemetryAttrs{list: list, set: set, opt: metric.WithAttributeSet(set)}
+}
+
+// batcherAttrs returns the telemetryAttrs of the batcher identified by
+// key, those of the processor unless includeMetadata is set.  Auth
+// values are left out.
+func (bpt *batchProcessorTelemetry) batcherAttrs(key attribute.Set) *telemetryAttrs {
+	if !bpt.includeMetadata || key.Len() == 0 {
+		return bpt.attrs
+	}
+	list := make([]attribute.KeyValue, 0, key.Len()+len(bpt.processorAttr))
+	for _, kv := range key.ToSlice() {
+		if !strings.HasPrefix(string(kv.Key), authAttrPrefix) {
+			list = append(list, kv)
+		}
+	}
+	return newTelemetryAttrs(append(list, bpt.processorAttr...))
+}
+
+// record records an export of sent items and bytes.  The measurements
+// are recorded with ctx, the context of the span of the export, so that
+// the SDK can attach exemplars to them.
+func (bpt *batchProcessorTelemetry) record(ctx context.Context, attrs *telemetryAttrs, trigger trigger, sent, bytes int64) {
+	switch trigger {
+	case triggerBatchSize:
+		bpt.batchSizeTriggerSend.Add(ctx, 1, attrs.opt)
+	case triggerTimeout:
+		bpt.timeoutTriggerSend.Add(ctx, 1, attrs.opt)
+	case triggerBypass:
+		bpt.bypassTriggerSend.Add(ctx, 1, attrs.opt)
+	case triggerResourceChange:
+		bpt.resourceChangeSend.Add(ctx, 1, attrs.opt)
+	case triggerShutdown:
+		bpt.shutdownTriggerSend.Add(ctx, 1, attrs.opt)
+	}
+
+	bpt.batchSendSize.Record(ctx, sent, attrs.opt)
+	if bpt.enabled.sendSizeBytes {
+		bpt.batchSendSizeBytes.Record(ctx, bytes, attrs.opt)
+	}
+}
+
+// recordDropped records the items and bytes of dropped data, classified
+// by the reason they were dropped.  Bytes are only recorded with the
+// detailed level.
+func (bpt *batchProcessorTelemetry) recordDropped(attrs *telemetryAttrs, items, bytes int64, reason string) {
+	opt := metric.WithAttributes(append([]attribute.KeyValue{
+		attribute.String(signalAttr, bpt.signal),
+		attribute.String(reasonAttr, reason),
+	}, attrs.list...)...)
+	bpt.droppedItems.Add(context.Background(), items, opt)
+	if bpt.enabled.droppedBytes {
+		bpt.droppedBytes.Add(context.Background(), bytes, opt)
+	}
+}
+
+func (bpt *batchProcessorTelemetry) recordOtherValuesItems(items int64) {
+	bpt.otherValuesItems.Add(context.Background(), items, metric.WithAttributes(bpt.processorAttr...))
+}
+
+func (bpt *batchProcessorTelemetry) recordTruncatedValues(values int64) {
+	bpt.truncatedValues.Add(context.Background(), values, metric.WithAttributes(bpt.processorAttr...))
+}
+
+func (bpt *batchProcessorTelemetry) recordOverflowItems(items int64) {
+	bpt.overflowItems.Add(context.Background(), items, metric.WithAttributes(bpt.processorAttr...))
+}
+
+func (bpt *batchProcessorTelemetry) recordDeadLetterItems(items int64) {
+	bpt.deadLetterItems.Add(context.Background(), items, metric.WithAttributes(bpt.processorAttr...))
+}
+
+// recordSendFailed records the items and bytes of a failed export,
+// classified by the permanence of its error.
+func (bpt *batchProcessorTelemetry) recordSendFailed(items, bytes int64, permanence string) {
+	attrs := metric.WithAttributes(append([]attribute.KeyValue{attribute.String(permanenceAttr, permanence)}, bpt.processorAttr...)...)
+	bpt.batchSendFailed.Add(context.Background(), items, attrs)
+	bpt.batchSendFailedBytes.Add(context.Background(), bytes, attrs)
+}
+
+func (bpt *batchProcessorTelemetry) recordRejectedItems(items int64) {
+	bpt.batchItemsRejected.Add(context.Background(), items, metric.WithAttributes(bpt.processorAttr...))
+}
+
+// RecordMetricMergeConflicts records n metrics that could not be merged
+// into a batch because of a conflicting definition.
+func (bp *Processor) RecordMetricMergeConflicts(n int) {
+	bp.telemetry.recordMetricMergeConflicts(int64(n))
+}
+
+func (bpt *batchProcessorTelemetry) recordMetricMergeConflicts(metrics int64) {
+	bpt.metricMergeConflicts.Add(context.Background(), metrics, metric.WithAttributes(bpt.processorAttr...))
+}
+
+// recordReadOnlyCopy records a read-only request copied for the
+// processor to take ownership of its data.
+func (bpt *batchProcessorTelemetry) recordReadOnlyCopy() {
+	bpt.readOnlyCopies.Add(context.Background(), 1, metric.WithAttributes(bpt.processorAttr...))
+}
+
+// recordSendLatency records the duration of an export of trigger, and
+// whether it failed, with ctx like record.
+func (bpt *batchProcessorTelemetry) recordSendLatency(ctx context.Context, attrs *telemetryAttrs, trigger trigger, d time.Duration, failed bool) {
+	outcome := outcomeSuccess
+	if failed {
+		outcome = outcomeFailure
+	}
+	ms := float64(d) / float64(time.Millisecond)
+	bpt.sendLatency.Record(ctx, ms, metric.WithAttributes(append([]attribute.KeyValue{
+		attribute.String(signalAttr, bpt.signal),
+		attribute.String(triggerAttr, trigger.batchTrigger().String()),
+		attribute.String(outcomeAttr, outcome),
+	}, attrs.list...)...))
+}
+
+// recordBatchAge records the time since the oldest data of a batch sent
+// by trigger was added, with ctx like record.
+func (bpt *batchProcessorTelemetry) recordBatchAge(ctx context.Context, attrs *telemetryAttrs, trigger trigger, age time.Duration) {
+	ms := float64(age) / float64(time.Millisecond)
+	bpt.batchAge.Record(ctx, ms, metric.WithAttributes(append([]attribute.KeyValue{
+		attribute.String(signalAttr, bpt.signal),
+		attribute.String(triggerAttr, trigger.batchTrigger().String()),
+	}, attrs.list...)...))
+}
+
+func (bpt *batchProcessorTelemetry) recordBatcherCreated() {
+	bpt.batchersCreated.Add(context.Background(), 1, bpt.attrs.opt)
+}
+
+// recordBatcherRemoved records the removal of a batcher, classified by
+// its reason.
+func (bpt *batchProcessorTelemetry) recordBatcherRemoved(reason string) {
+	bpt.batchersRemoved.Add(context.Background(), 1, metric.WithAttributes(append([]attribute.KeyValue{attribute.String(reasonAttr, reason)}, bpt.processorAttr...)...))
+}
+
+// recordReceivedItems records the items of a request accepted by a
+// batcher.
+func (bpt *batchProcessorTelemetry) recordReceivedItems(attrs *telemetryAttrs, items int64) {
+	bpt.itemsReceived.Add(context.Background(), items, metric.WithAttributes(append([]attribute.KeyValue{attribute.String(signalAttr, bpt.signal)}, attrs.list...)...))
+}
+
+// recordSplit records a batch split to send at most
+// max_size, and the items left in the batch.
+func (bpt *batchProcessorTelemetry) recordSplit(attrs *telemetryAttrs, leftover int64) {
+	opt := metric.WithAttributes(append([]attribute.KeyValue{attribute.String(signalAttr, bpt.signal)}, attrs.list...)...)
+	bpt.batchSizeSplit.Add(context.Background(), 1, opt)
+	bpt.splitLeftoverItems.Add(context.Background(), leftover, opt)
+}
@@ -0,0 +1,53 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching // import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+import "sync"
+
+// batcherTurns orders the exports of the batchers created for the
+// same BatcherID.  A batcher exports its batches one at a time, but
+// one removed from the multiBatcher flushes while the batcher created
+// again for the same values already receives data.  With ordering
+// "per_batcher", the new batcher waits for the removed one to exit
+// before its first export.
+type batcherTurns struct {
+	lock sync.Mutex
+	last map[uint64]chan struct{}
+}
+
+// next registers a new batcher for id.  It returns the channel closed
+// when the previous batcher for id exits, nil if there is none, and the
+// channel to pass to done when the new batcher exits.
+func (t *batcherTurns) next(id uint64) (<-chan struct{}, chan struct{}) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.last == nil {
+		t.last = map[uint64]chan struct{}{}
+	}
+	prev := t.last[id]
+	exited := make(chan struct{})
+	t.last[id] = exited
+	return prev, exited
+}
+
+// done is called when the batcher for id registered with exited exits.
+func (t *batcherTurns) done(id uint64, exited chan struct{}) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	close(exited)
+	if t.last[id] == exited {
+		delete(t.last, id)
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatcherTurns(t *testing.T) {
+	var turns batcherTurns
+	after, first := turns.next(1)
+	assert.Nil(t, after)
+	after, second := turns.next(1)
+	assert.NotNil(t, after)
+
+	turns.done(1, first)
+	select {
+	case <-after:
+	default:
+		t.Fatal("the previous batcher has exited")
+	}
+	turns.done(1, second)
+	assert.Empty(t, turns.last)
+}
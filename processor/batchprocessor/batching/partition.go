@@ -0,0 +1,421 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching // import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/client"
+)
+
+// cardinalityWarnInterval is the minimum interval between warnings
+// about the metadata cardinality limit.
+const cardinalityWarnInterval = time.Minute
+
+// Partition is the part of a request routed to the batcher of the
+// values of its resource attribute keys.
+type Partition struct {
+	// Attrs are the values of the resource attribute keys.
+	Attrs []attribute.KeyValue
+	// Item is the data of the partition, of Items items.
+	Item  any
+	Items int
+}
+
+type batcherFinder interface {
+	findBatcher(ctx context.Context, resourceAttrs []attribute.KeyValue) (*batcher, error)
+	currentMetadataCardinality() int
+	currentMetadataKeyCardinality() map[string]int
+	allBatchers() []*batcher
+}
+
+// singleBatcher is used when no metadata keys or resource keys are configured, to avoid the
+// additional lock and map operations used in multiBatcher.
+type singleBatcher struct {
+	*batcher
+}
+
+// multiBatcher is used when metadata keys or resource keys are configured.
+type multiBatcher struct {
+	*Processor
+
+	// lock guards the fields below.  Lookups of existing batchers
+	// take the read lock, unless the LRU order is maintained.
+	lock     sync.RWMutex
+	batchers map[attribute.Set]*batcher
+
+	// overflow is the shared batcher for combinations beyond the
+	// cardinality limit when CardinalityOverflowMode is "group".
+	// It is created on first use and not counted toward the limit.
+	overflow *batcher
+
+	// lru orders the batchers from most to least recently used,
+	// maintained only when evictLRU is set.
+	lru *list.List
+
+	// keyValues counts, for each metadata key with a distinct value
+	// limit, the batchers using each value of the key.
+	keyValues map[string]map[string]int
+
+	// warnThreshold is the number of batchers at which a warning is
+	// logged, zero when disabled.  warned is set once the warning has
+	// been logged, until the number of batchers falls below the
+	// threshold, and lastWarn rate-limits it.
+	warnThreshold int
+	warned        bool
+	lastWarn      time.Time
+
+	// lifecycleLogger logs the creation and removal of batchers,
+	// sampled so that a cardinality explosion does not turn into a
+	// log explosion.
+	lifecycleLogger *zap.Logger
+}
+
+func (sb *singleBatcher) findBatcher(context.Context, []attribute.KeyValue) (*batcher, error) {
+	return sb.batcher, nil
+}
+
+// sourceAttrKey is the key of client.Info.Source in the attribute set
+// identifying a batcher.  Metadata keys have no colon, so it cannot be
+// mistaken for one.
+const sourceAttrKey = "client:source"
+
+func (mb *multiBatcher) findBatcher(ctx context.Context, resourceAttrs []attribute.KeyValue) (*batcher, error) {
+	// Get each metadata key value, form the corresponding
+	// attribute set for use as a map lookup key.
+	info := client.FromContext(ctx)
+	md := map[string][]string{}
+	attrs := make([]attribute.KeyValue, 0, len(mb.metadataKeys)+len(resourceAttrs))
+	otherValues := false
+	var limited []limitedValue
+	for _, k := range mb.matchingMetadataKeys(info.Metadata) {
+		// Lookup the value in the incoming metadata, copy it
+		// into the outgoing metadata, and create a unique
+		// value for the attributeSet.
+		vs := info.Metadata.Get(k)
+		h := mb.metadataKeyHandlers[k]
+		if h != nil {
+			vs = h.normalize(vs)
+		}
+		if t := mb.settings.MetadataTransformer; t != nil {
+			vs = t(k, vs)
+		}
+		if h != nil {
+			var outgoing []string
+			var other bool
+			vs, outgoing, other = h.apply(vs)
+			otherValues = otherValues || other
+			if outgoing != nil {
+				md[mb.outgoingMetadataKey(k)] = outgoing
+			}
+		} else {
+			md[mb.outgoingMetadataKey(k)] = vs
+		}
+		if h != nil && h.limit > 0 && len(vs) != 0 && (len(vs) != 1 || vs[0] != metadataOtherValue) {
+			limited = append(limited, limitedValue{key: k, value: strings.Join(vs, "\x00"), limit: h.limit})
+		}
+		if len(vs) == 1 {
+			attrs = append(attrs, attribute.String(k, vs[0]))
+		} else {
+			attrs = append(attrs, attribute.StringSlice(k, vs))
+		}
+	}
+	var auth client.AuthData
+	if len(mb.authKeys) != 0 {
+		var authAttrs []attribute.KeyValue
+		authAttrs, auth = authKeyValues(mb.authKeys, info.Auth)
+		attrs = append(attrs, authAttrs...)
+	}
+	var source string
+	if mb.groupBySource {
+		source = info.Source
+		attrs = append(attrs, attribute.String(sourceAttrKey, source))
+	}
+	attrs = append(attrs, resourceAttrs...)
+	aset := attribute.NewSet(attrs...)
+
+	if mb.lru == nil {
+		mb.lock.RLock()
+		b, ok := mb.batchers[aset]
+		mb.lock.RUnlock()
+		if ok {
+			return b, nil
+		}
+	}
+
+	mb.lock.Lock()
+	defer mb.lock.Unlock()
+
+	// The batcher may have been created since the lookup above.
+	b, ok := mb.batchers[aset]
+	if !ok && len(limited) != 0 && mb.foldLimitedValues(limited, attrs, md) {
+		aset = attribute.NewSet(attrs...)
+		b, ok = mb.batchers[aset]
+	}
+	if ok {
+		if mb.lru != nil {
+			mb.lru.MoveToFront(b.lruElem)
+		}
+		return b, nil
+	}
+
+	if limit := mb.metadataLimit; limit != unlimitedMetadataCardinality && len(mb.batchers) >= limit {
+		switch {
+		case mb.lru != nil:
+			mb.removeBatcher(mb.lru.Back().Value.(*batcher), removalEvicted)
+		case mb.overflowGroup:
+			if mb.overflow == nil {
+				mb.overflow = mb.newBatcher(attribute.NewSet(), nil, nil, "")
+				mb.overflow.overflow = true
+			}
+			return mb.overflow, nil
+		default:
+			return nil, ErrTooManyBatchers
+		}
+	}
+
+	// aset.ToSlice() returns the sorted, deduplicated,
+	// and name-downcased list of attributes.
+	b = mb.newBatcher(aset, md, auth, source)
+	b.otherValues = otherValues
+	mb.acquireLimitedValues(b, limited)
+	if mb.lru != nil {
+		b.lruElem = mb.lru.PushFront(b)
+	}
+	mb.batchers[aset] = b
+	mb.lifecycleLogger.Debug("Created batcher",
+		zap.String("key", aset.Encoded(attribute.DefaultEncoder())),
+		zap.Int("metadata_cardinality", len(mb.batchers)))
+	mb.checkCardinality()
+	return b, nil
+}
+
+// checkCardinality logs a warning when the number of batchers reaches
+// the warning threshold.  Callers hold mb.lock.
+func (mb *multiBatcher) checkCardinality() {
+	if mb.warnThreshold == 0 || mb.warned || len(mb.batchers) < mb.warnThreshold {
+		return
+	}
+	mb.warned = true
+	if now := time.Now(); now.Sub(mb.lastWarn) >= cardinalityWarnInterval {
+		mb.lastWarn = now
+		mb.logger.Warn("Approaching the metadata cardinality limit",
+			zap.Int("metadata_cardinality", len(mb.batchers)),
+			zap.Int("metadata_cardinality_limit", mb.metadataLimit))
+	}
+}
+
+// foldLimitedValues replaces the values of limited keys that would
+// exceed their distinct value limit by metadataOverflowValue in attrs
+// and md, returning true when any value was replaced.  Callers hold
+// mb.lock.
+func (mb *multiBatcher) foldLimitedValues(limited []limitedValue, attrs []attribute.KeyValue, md map[string][]string) bool {
+	folded := false
+	for i := range limited {
+		lv := &limited[i]
+		counts := mb.keyValues[lv.key]
+		if counts[lv.value] > 0 || len(counts) < lv.limit {
+			continue
+		}
+		lv.overflow = true
+		folded = true
+		for j := range attrs {
+			if attrs[j].Key == attribute.Key(lv.key) {
+				attrs[j] = attribute.String(lv.key, metadataOverflowValue)
+			}
+		}
+		if _, ok := md[mb.outgoingMetadataKey(lv.key)]; ok {
+			md[mb.outgoingMetadataKey(lv.key)] = []string{metadataOverflowValue}
+		}
+	}
+	return folded
+}
+
+// acquireLimitedValues counts the limited values of a new batcher.
+// Callers hold mb.lock.
+func (mb *multiBatcher) acquireLimitedValues(b *batcher, limited []limitedValue) {
+	for _, lv := range limited {
+		if lv.overflow {
+			continue
+		}
+		if mb.keyValues == nil {
+			mb.keyValues = map[string]map[string]int{}
+		}
+		counts := mb.keyValues[lv.key]
+		if counts == nil {
+			counts = map[string]int{}
+			mb.keyValues[lv.key] = counts
+		}
+		counts[lv.value]++
+		b.limitedValues = append(b.limitedValues, lv)
+	}
+}
+
+// releaseLimitedValues reverses acquireLimitedValues for a removed
+// batcher.  Callers hold mb.lock.
+func (mb *multiBatcher) releaseLimitedValues(b *batcher) {
+	for _, lv := range b.limitedValues {
+		counts := mb.keyValues[lv.key]
+		if counts[lv.value]--; counts[lv.value] <= 0 {
+			delete(counts, lv.value)
+		}
+	}
+	b.limitedValues = nil
+}
+
+// expireBatcher removes b when it is still present in the batchers
+// map.  The overflow batcher does not expire.
+func (mb *multiBatcher) expireBatcher(b *batcher) bool {
+	mb.lock.Lock()
+	defer mb.lock.Unlock()
+	if b.overflow || mb.batchers[b.key] != b {
+		return false
+	}
+	mb.removeBatcher(b, removalExpired)
+	return true
+}
+
+// removeBatcher removes b from the multiBatcher and stops it once
+// pending producers have enqueued their items.  Callers hold mb.lock.
+func (mb *multiBatcher) removeBatcher(b *batcher, reason string) {
+	delete(mb.batchers, b.key)
+	mb.lifecycleLogger.Debug("Removed batcher",
+		zap.String("key", b.key.Encoded(attribute.DefaultEncoder())),
+		zap.String("reason", reason),
+		zap.Int("metadata_cardinality", len(mb.batchers)))
+	b.removed.Store(true)
+	mb.telemetry.recordBatcherRemoved(reason)
+	if mb.warned && len(mb.batchers) < mb.warnThreshold {
+		mb.warned = false
+	}
+	mb.releaseLimitedValues(b)
+	if b.lruElem != nil {
+		mb.lru.Remove(b.lruElem)
+		b.lruElem = nil
+	}
+	mb.goroutines.Add(1)
+	go b.stop()
+}
+
+// outgoingMetadataKey returns the key to use in the outgoing metadata
+// for the lower-case key k.
+func (mb *multiBatcher) outgoingMetadataKey(k string) string {
+	if c, ok := mb.metadataKeyCase[k]; ok {
+		return c
+	}
+	return k
+}
+
+// matchingMetadataKeys returns the configured metadata keys followed by
+// the keys of md matching a configured pattern, in lower case.
+func (mb *multiBatcher) matchingMetadataKeys(md client.Metadata) []string {
+	if len(mb.metadataPatterns) == 0 {
+		return mb.metadataKeys
+	}
+	keys := append([]string(nil), mb.metadataKeys...)
+	seen := map[string]bool{}
+	for _, k := range mb.metadataKeys {
+		seen[k] = true
+	}
+	for _, k := range md.Keys() {
+		if seen[k] {
+			continue
+		}
+		for _, p := range mb.metadataPatterns {
+			if ok, _ := path.Match(p, k); ok {
+				keys = append(keys, k)
+				break
+			}
+		}
+	}
+	return keys
+}
+
+func (sb *singleBatcher) currentMetadataCardinality() int {
+	return 1
+}
+
+func (mb *multiBatcher) currentMetadataCardinality() int {
+	mb.lock.RLock()
+	defer mb.lock.RUnlock()
+	return len(mb.batchers)
+}
+
+func (sb *singleBatcher) currentMetadataKeyCardinality() map[string]int {
+	return nil
+}
+
+// currentMetadataKeyCardinality returns the number of distinct values
+// of each metadata key with a distinct value limit.
+func (mb *multiBatcher) currentMetadataKeyCardinality() map[string]int {
+	mb.lock.RLock()
+	defer mb.lock.RUnlock()
+	card := make(map[string]int, len(mb.keyValues))
+	for k, counts := range mb.keyValues {
+		card[k] = len(counts)
+	}
+	return card
+}
+
+// consumePartitions routes each partition of a request to its batcher.
+// Batchers are found for every partition before any data is enqueued,
+// so that a request is either accepted or rejected as a whole.  With
+// wait, it returns the channel notified with the export result of
+// every partition.
+func (bp *Processor) consumePartitions(ctx context.Context, parts []Partition, wait bool) (chan error, error) {
+	batchers := make([]*batcher, len(parts))
+	for i, part := range parts {
+		b, err := bp.findBatcher(ctx, part.Attrs)
+		if err != nil {
+			for _, part := range parts {
+				bp.releaseInFlight(part.Items)
+			}
+			return nil, err
+		}
+		batchers[i] = b
+	}
+	var done chan error
+	if wait || bp.syncConsume {
+		done = make(chan error, len(parts))
+	}
+	for i, b := range batchers {
+		if err := bp.enqueue(ctx, parts[i].Attrs, b, parts[i].Item, parts[i].Items, done); err != nil {
+			// enqueue released the items of this partition.
+			for _, part := range parts[i+1:] {
+				bp.releaseInFlight(part.Items)
+			}
+			if !errors.Is(err, ErrBatcherFull) {
+				return nil, err
+			}
+			// Return the data of this and the remaining
+			// partitions, the previous ones were accepted.
+			rejected := make([]any, 0, len(parts)-i)
+			for _, part := range parts[i:] {
+				rejected = append(rejected, part.Item)
+			}
+			return nil, bp.reject(ErrBatcherFull, rejected...)
+		}
+	}
+	return done, nil
+}
@@ -0,0 +1,131 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+func TestMultiBatcherFindBatcherConcurrentCreate(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.MetadataKeys = []string{"tenant"}
+	b, err := NewBatcher(processortest.NewNopCreateSettings(), cfg, (&intsSink{}).settings())
+	require.NoError(t, err)
+	require.NoError(t, b.Start(context.Background(), componenttest.NewNopHost()))
+	mb := b.p.batcherFinder.(*multiBatcher)
+
+	for _, tenant := range []string{"a", "b", "c"} {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"tenant": {tenant}}),
+		})
+		start := make(chan struct{})
+		found := make([]*batcher, 32)
+		var wg sync.WaitGroup
+		for i := range found {
+			i := i
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				b, err := mb.findBatcher(ctx, nil)
+				assert.NoError(t, err)
+				found[i] = b
+			}()
+		}
+		close(start)
+		wg.Wait()
+		for _, b := range found {
+			assert.Same(t, found[0], b)
+		}
+	}
+	assert.Equal(t, 3, mb.currentMetadataCardinality())
+	require.NoError(t, b.Shutdown(context.Background()))
+}
+
+func BenchmarkMultiBatcherFindBatcher(b *testing.B) {
+	cfg := NewDefaultConfig()
+	cfg.MetadataKeys = []string{"tenant"}
+	bt, err := NewBatcher(processortest.NewNopCreateSettings(), cfg, (&intsSink{}).settings())
+	require.NoError(b, err)
+	require.NoError(b, bt.Start(context.Background(), componenttest.NewNopHost()))
+	mb := bt.p.batcherFinder.(*multiBatcher)
+	ctx := client.NewContext(context.Background(), client.Info{
+		Metadata: client.NewMetadata(map[string][]string{"tenant": {"a"}}),
+	})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := mb.findBatcher(ctx, nil); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+	b.StopTimer()
+	require.NoError(b, bt.Shutdown(context.Background()))
+}
+
+func TestBatchProcessorMetadataBatcherIdleTimeoutChurn(t *testing.T) {
+	const waves = 20
+	const combinationsPerWave = 500
+
+	sink := &intsSink{}
+	cfg := NewDefaultConfig()
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"token"}
+	cfg.MetadataCardinalityLimit = combinationsPerWave
+	cfg.MetadataBatcherIdleTimeout = 5 * time.Millisecond
+	b, err := NewBatcher(processortest.NewNopCreateSettings(), cfg, sink.settings())
+	require.NoError(t, err)
+	require.NoError(t, b.Start(context.Background(), componenttest.NewNopHost()))
+
+	baseline := runtime.NumGoroutine()
+	for wave := 0; wave < waves; wave++ {
+		for i := 0; i < combinationsPerWave; i++ {
+			ctx := client.NewContext(context.Background(), client.Info{
+				Metadata: client.NewMetadata(map[string][]string{
+					"token": {fmt.Sprint(wave*combinationsPerWave + i)},
+				}),
+			})
+			require.NoError(t, b.Add(ctx, []int{i}))
+		}
+		require.Eventually(t, func() bool {
+			return b.p.currentMetadataCardinality() == 0
+		}, 5*time.Second, 5*time.Millisecond)
+	}
+
+	// All batchers expired and their goroutines exited.
+	require.Eventually(t, func() bool {
+		return runtime.NumGoroutine() <= baseline+10
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Zero(t, b.p.activeBatchers.Load())
+
+	require.NoError(t, b.Shutdown(context.Background()))
+	assert.Equal(t, waves*combinationsPerWave, sink.itemCount())
+}
@@ -0,0 +1,830 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching // import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/internal/obsreportconfig/obsmetrics"
+	"go.opentelemetry.io/collector/processor"
+)
+
+const (
+	// typeStr is the type of the batch processor, naming its metrics.
+	typeStr = "batch"
+
+	// lifecycleLogsPerSecond is the number of batcher creation and
+	// removal logs, of each kind, written per second.
+	lifecycleLogsPerSecond = 10
+
+	// droppedOldestLogsPerSecond is the number of warnings written per
+	// second when the drop_oldest policy discards data.
+	droppedOldestLogsPerSecond = 1
+)
+
+// ErrTooManyBatchers is returned when the MetadataCardinalityLimit has been reached.
+var ErrTooManyBatchers = consumererror.NewPermanent(errors.New("too many batcher metadata-value combinations"))
+
+// ErrBatcherFull is returned, wrapped with the rejected data, when the
+// OnFull policy is "error" and a batcher cannot accept more data.
+var ErrBatcherFull = errors.New("batch processor queue is full")
+
+// ErrInFlightLimit is returned, wrapped with the rejected data, when
+// accepting a request would exceed MaxInFlightItems.
+var ErrInFlightLimit = errors.New("batch processor in-flight items limit reached")
+
+// ErrDropped is returned to producers waiting for the export of data
+// that was dropped instead, when ErrorMode is "propagate".
+var ErrDropped = errors.New("data dropped by the batch processor")
+
+// ErrShuttingDown is returned, wrapped with the rejected data, for
+// requests received once the processor has started draining on
+// shutdown.
+var ErrShuttingDown = errors.New("batch processor is shutting down")
+
+// errBatcherStopped is returned by tryEnqueue when the batcher has
+// been removed from the multiBatcher.
+var errBatcherStopped = errors.New("batcher stopped")
+
+// Processor is the core of the batch processor: it accepts requests,
+// places them into batches and exports them, with the batching of the
+// type of data defined by its Settings.
+//
+// Batches are sent out with any of the following conditions:
+// - batch size reaches cfg.SendBatchSize
+// - cfg.Timeout is elapsed since the timestamp when the previous batch was sent out.
+type Processor struct {
+	logger          *zap.Logger
+	dataType        component.DataType
+	flushOnShutdown bool
+	syncConsume     bool
+	splitAtResource bool
+	splitAtScope    bool
+	drainTimeout    time.Duration
+
+	// sizes are the timeout, min_size, and max_size
+	// of the processor, replaced by UpdateConfig.
+	sizes atomic.Pointer[batchSizes]
+
+	// sendBatchSizeBytes and sendBatchMaxSizeBytes are the
+	// min_size_bytes and max_size_bytes of the processor, zero when
+	// unset.
+	sendBatchSizeBytes    int
+	sendBatchMaxSizeBytes int
+
+	// trackBytes is set when the batches track their size in bytes,
+	// for the byte sizes or the batch_send_size_bytes metric.
+	trackBytes bool
+
+	// cfgLock serializes UpdateConfig, and guards cfg, the current
+	// configuration.
+	cfgLock sync.Mutex
+	cfg     Config
+
+	// mutatesData is false when the incoming data is copied to the
+	// batches, leaving it unchanged.
+	mutatesData bool
+
+	// settings adapts the processor to its type of data.
+	settings Settings
+	// now returns the current time for the age of the batches,
+	// replaced by tests.
+	now func() time.Time
+
+	// flushOnResourceChange sends the pending batch of a batcher
+	// before adding data of another set of resources.
+	flushOnResourceChange bool
+
+	// metadataKeys is the configured list of metadata keys.  When
+	// empty, the `singleton` batcher is used.  When non-empty,
+	// each distinct combination of metadata keys and values
+	// triggers a new batcher, counted in `goroutines`.
+	metadataKeys []string
+
+	// metadataPatterns is the list of glob patterns configured in
+	// metadata keys.  Every incoming metadata key matching one of
+	// the patterns is used in addition to metadataKeys.
+	metadataPatterns []string
+
+	// metadataKeyCase maps lower-case metadata keys to the casing
+	// configured by the user, for use in the outgoing metadata.
+	// Only keys whose configured casing differs are present.
+	metadataKeyCase map[string]string
+
+	// metadataKeyHandlers applies the configured per-key settings,
+	// indexed by lower-case key.
+	metadataKeyHandlers map[string]*metadataKeyHandler
+
+	// metadataValueLimit bounds the client metadata of incoming
+	// requests.
+	metadataValueLimit MetadataValueLimit
+
+	// authKeys is the configured list of client.AuthData
+	// attributes used, in addition to metadata keys, to form
+	// distinct batchers.
+	authKeys []string
+
+	// groupBySource forms distinct batchers by client.Info.Source,
+	// in addition to the other keys.
+	groupBySource bool
+
+	// resourceKeys is the configured list of resource attribute
+	// keys.  When non-empty, incoming requests are partitioned by
+	// resource before being routed to a batcher.
+	resourceKeys []string
+
+	// metadataLimit is the limiting size of the batchers map, or
+	// unlimitedMetadataCardinality.
+	metadataLimit int
+
+	// overflowGroup routes combinations beyond metadataLimit to a
+	// shared overflow batcher instead of rejecting them.
+	overflowGroup bool
+
+	// idleTimeout is the period without items after which a
+	// batcher is flushed and removed from the multiBatcher, zero
+	// when batchers do not expire.
+	idleTimeout time.Duration
+
+	// expireBatcher removes an idle batcher, returning false when
+	// the batcher is not eligible for expiry.  Set along with
+	// idleTimeout.
+	expireBatcher func(*batcher) bool
+
+	// evictLRU evicts the least-recently-used batcher when
+	// metadataLimit is reached, instead of rejecting or grouping
+	// the new combination.
+	evictLRU bool
+
+	// propagateAllMetadata merges the complete incoming metadata
+	// into the export context, instead of only the batching keys.
+	propagateAllMetadata bool
+
+	// propagateAddr, propagateAuth and propagateSource carry the
+	// client address, auth data and source into the export context
+	// when shared by a batch.
+	propagateAddr   bool
+	propagateAuth   bool
+	propagateSource bool
+
+	// propagateErrors makes producers wait for the export of their
+	// data and return its error.
+	propagateErrors bool
+
+	// onFull is the policy applied when a batcher cannot accept more
+	// data: block, reject with ErrBatcherFull, or drop the oldest
+	// pending request.
+	onFull string
+
+	// maxInFlight is the budget of items held by the processor, and
+	// inFlight the number currently held.  Items are counted only
+	// when maxInFlight is set.
+	maxInFlight int64
+	inFlight    atomic.Int64
+
+	// retry configures the retry of failed exports.
+	retry RetryConfig
+
+	// status reports the status of the processor to the host.
+	status statusTracker
+
+	// failureLogInterval is the minimum interval between the logs
+	// of failed exports of a batcher.
+	failureLogInterval time.Duration
+
+	// deadLetter receives the data of failed exports, nil when no
+	// dead-letter consumer is configured.  deadLetterID is the
+	// exporter resolved as deadLetter on Start.
+	deadLetter   func(ctx context.Context, data any) error
+	deadLetterID *component.ID
+
+	// walDir is the directory of the write-ahead log segments, empty
+	// when persistence is off.  walSegments numbers the segment
+	// files, so that a batcher replacing a removed one with the same
+	// key, or rolling over after a failed export, does not write to a
+	// file still in use.
+	walDir      string
+	walSegments atomic.Uint64
+
+	// sequences numbers the exports of each batcher for BatchInfo.
+	sequences batchSequences
+
+	// turns orders the exports of the batchers created for the same
+	// values, nil unless ordering is "per_batcher".
+	turns *batcherTurns
+
+	// dropLogger logs the requests discarded by the drop_oldest
+	// policy, rate-limited.
+	dropLogger *zap.Logger
+
+	// overrides adjusts the settings of batchers by metadata.
+	overrides []BatchOverride
+
+	// bypassForward sends the requests matched by Settings.Bypass in
+	// requests of their own, leaving the pending batch untouched.
+	bypassForward bool
+
+	shutdownC  chan struct{}
+	goroutines sync.WaitGroup
+
+	// activeBatchers is the number of batcher goroutines running.
+	activeBatchers atomic.Int64
+
+	// drainLock is held for reading by the requests being handed to
+	// the batchers.  Shutdown takes it to set draining once they have
+	// all been enqueued, after which requests are rejected, so that
+	// the batchers' final drain sees every accepted item.
+	drainLock sync.RWMutex
+	draining  bool
+
+	// shutdownCtx is the context passed to Shutdown, limited by
+	// drainTimeout, set before shutdownC is closed.  It bounds the
+	// final flush.
+	shutdownCtx context.Context
+	// flushExpired is set when a batcher ran out of time flushing.
+	flushExpired atomic.Bool
+	// flushSlots bounds the number of batchers flushing at once on
+	// shutdown to shutdown_parallelism.
+	flushSlots chan struct{}
+	// shutdownBatchers and flushedBatchers count the batchers that
+	// started and completed their flush on shutdown.
+	shutdownBatchers atomic.Int64
+	flushedBatchers  atomic.Int64
+
+	// stoppedC is closed once every batcher goroutine has exited.
+	stoppedC chan struct{}
+
+	telemetry *batchProcessorTelemetry
+
+	// tracer starts the spans named spanName of the exports.
+	tracer   trace.Tracer
+	spanName string
+
+	//  batcherFinder will be either *singletonBatcher or *multiBatcher
+	batcherFinder
+}
+
+// Batch is the pending batch of a batcher, generalizing the types of
+// data batched.
+type Batch interface {
+	// Export sends the current batch, or its first sendBatchMaxSize
+	// items when it is larger, returning the request sent, its size,
+	// and its size in bytes when tracked or the export failed.
+	Export(ctx context.Context, sendBatchMaxSize int) (req any, sentBatchSize int, sentBatchBytes int, err error)
+
+	// Consume sends a request returned by Export again.
+	Consume(ctx context.Context, req any) error
+
+	// ItemCount returns the size of the current batch.
+	ItemCount() int
+
+	// ByteSize returns the marshaled size of the current batch when
+	// it is tracked, zero otherwise.  Every request is measured when
+	// added, and the sizes of the requests add up to the size of the
+	// batch.  Only the part split off a batch is measured, and the
+	// remainder is approximated: the resource and scope of the item
+	// cut, and for metrics the metric, are sent in both parts.  For
+	// every split since the batch was last exported whole, the
+	// remainder is underestimated by at most the size of these
+	// without their items, plus a few bytes of length prefixes.  With
+	// compaction the batch is overestimated by the resources of the
+	// requests merged into an entry of the batch.
+	ByteSize() int
+
+	// Add adds item, of n spans, data points, log records, or
+	// requests items, to the current batch.
+	Add(item any, n int)
+
+	// Requeue puts data that failed to export at the front of the
+	// current batch, to be sent first by the next export.
+	Requeue(data any)
+}
+
+// Settings adapts a Processor to the type of data it batches.  NewBatch
+// and Count are required, the other functions are only called for the
+// settings of the Config requiring them.
+type Settings struct {
+	// DataType names the data in logs and telemetry.
+	DataType component.DataType
+
+	// NewBatch returns an empty batch.  With trackBytes, the batch
+	// measures the requests added to it, for ByteSize and the sizes
+	// returned by Export.
+	NewBatch func(trackBytes bool) Batch
+
+	// Count returns the number of items of a request.
+	Count func(item any) int
+
+	// Size returns the marshaled size of a request, reported as zero
+	// when nil.
+	Size func(item any) int
+
+	// Reject wraps err, rejecting the data of items, in the error
+	// returned to the producers.  err is returned as is when nil.
+	Reject func(err error, items []any) error
+
+	// Returned returns the data of req that failed to export, carried
+	// by err, and whether err carried data.
+	Returned func(req any, err error) (any, bool)
+
+	// Mutable returns a mutable copy of a read-only request, and
+	// whether it was read-only.  It is called when the processor
+	// mutates data.
+	Mutable func(item any) (any, bool)
+
+	// Partition splits a request of n items by the values of the
+	// resource attribute keys.  With keep, item is left unchanged.
+	Partition func(keys []string, item any, n int, keep bool) []Partition
+
+	// ResourceSet identifies the resources of a request, for
+	// flush_on_resource_change.
+	ResourceSet func(item any) uint64
+
+	// Bypass returns whether a request is sent without waiting for
+	// the batch to fill, nil when no bypass rule is configured.
+	Bypass func(item any) bool
+
+	// Marshal and Unmarshal encode requests in the records of the
+	// write-ahead log, for persistence.
+	Marshal   func(item any) ([]byte, error)
+	Unmarshal func(buf []byte) (any, error)
+
+	// DeadLetter receives the data of failed exports, nil when no
+	// dead-letter consumer is set.
+	DeadLetter func(ctx context.Context, data any) error
+
+	// DeadLetterExporter returns the function sending data to the
+	// exporter id of host, for dead_letter_exporter.
+	DeadLetterExporter func(host component.Host, id component.ID) (func(ctx context.Context, data any) error, error)
+
+	// Next is the next consumer, whose batch size hint, if any, is
+	// adopted on Start.
+	Next any
+
+	// MetadataTransformer is applied to the values of every metadata
+	// key, nil when not set.
+	MetadataTransformer MetadataTransformer
+}
+
+// MetadataTransformer normalizes the values of a metadata key before
+// they are used to identify a batcher and copied into the outgoing
+// metadata.  It is applied after the built-in normalizations
+// configured in metadata_key_settings.  The values passed in may be
+// modified.
+type MetadataTransformer func(key string, values []string) []string
+
+// NewProcessor returns the Processor batching the data of s with cfg,
+// and the batching settings of the section of its signal, if any.  It
+// reports the telemetry of the batch processor with the id of set.
+func NewProcessor(set processor.CreateSettings, cfg *Config, s Settings) (*Processor, error) {
+	dataType := s.DataType
+	warnDeprecatedNames(set.Logger, cfg)
+	cfg = cfg.forSignal(dataType)
+	// use lower-case, to be consistent with http/2 headers.
+	var mks, patterns []string
+	var keyCase map[string]string
+	for _, k := range cfg.metadataKeys() {
+		l := strings.ToLower(k)
+		switch {
+		case isMetadataKeyPattern(l):
+			patterns = append(patterns, l)
+			continue
+		case cfg.PreserveMetadataCase && l != k:
+			if keyCase == nil {
+				keyCase = map[string]string{}
+			}
+			keyCase[l] = k
+		}
+		mks = append(mks, l)
+	}
+	sort.Strings(mks)
+	bp := &Processor{
+		logger:   set.Logger,
+		dataType: dataType,
+
+		settings:              s,
+		flushOnShutdown:       cfg.FlushOnShutdown == nil || *cfg.FlushOnShutdown,
+		mutatesData:           cfg.MutatesData == nil || *cfg.MutatesData,
+		now:                   time.Now,
+		drainTimeout:          cfg.DrainTimeout,
+		syncConsume:           cfg.SyncConsume,
+		splitAtResource:       cfg.SplitAt == SplitAtResource,
+		splitAtScope:          cfg.SplitAt == SplitAtScope && dataType == component.DataTypeLogs,
+		flushSlots:            make(chan struct{}, cfg.shutdownParallelism()),
+		shutdownC:             make(chan struct{}, 1),
+		stoppedC:              make(chan struct{}),
+		metadataKeys:          mks,
+		metadataPatterns:      patterns,
+		metadataKeyCase:       keyCase,
+		metadataKeyHandlers:   newMetadataKeyHandlers(cfg.MetadataKeySettings),
+		metadataValueLimit:    cfg.MetadataValueLimit,
+		authKeys:              cfg.AuthKeys,
+		groupBySource:         cfg.groupBySource(),
+		resourceKeys:          cfg.resourceAttributeKeys(),
+		metadataLimit:         int(cfg.MetadataCardinalityLimit),
+		overflowGroup:         cfg.CardinalityOverflowMode == cardinalityOverflowGroup,
+		evictLRU:              cfg.MetadataEvictionPolicy == metadataEvictionLRU,
+		overrides:             cfg.Overrides,
+		deadLetterID:          cfg.DeadLetterExporter,
+		onFull:                cfg.OnFull,
+		propagateErrors:       cfg.ErrorMode == errorModePropagate,
+		maxInFlight:           int64(cfg.MaxInFlightItems),
+		sendBatchSizeBytes:    int(cfg.SendBatchSizeBytes),
+		sendBatchMaxSizeBytes: int(cfg.SendBatchMaxSizeBytes),
+		retry:                 cfg.Retry,
+		failureLogInterval:    cfg.FailureLogInterval,
+		status:                statusTracker{id: set.ID, threshold: int(cfg.StatusReporting.FailureThreshold)},
+
+		propagateAllMetadata:  cfg.PropagateMetadata == propagateMetadataAll,
+		flushOnResourceChange: cfg.FlushOnResourceChange,
+		bypassForward:         cfg.Bypass.Mode == bypassModeForward,
+	}
+	bp.cfg = *cfg
+	bp.sizes.Store(newBatchSizes(cfg))
+	if cfg.Ordering == orderingPerBatcher {
+		bp.turns = &batcherTurns{}
+	}
+	if bp.onFull == onFullDropOldest {
+		bp.dropLogger = bp.logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewSamplerWithOptions(core, time.Second, droppedOldestLogsPerSecond, 0)
+		}))
+	}
+	for _, field := range cfg.PropagateClientInfo {
+		switch field {
+		case propagateClientInfoAddr:
+			bp.propagateAddr = true
+		case propagateClientInfoAuth:
+			bp.propagateAuth = true
+		case propagateClientInfoSource:
+			bp.propagateSource = true
+		}
+	}
+	var sb *singleBatcher
+	if len(bp.metadataKeys) == 0 && len(bp.metadataPatterns) == 0 && len(bp.authKeys) == 0 && !bp.groupBySource && len(bp.resourceKeys) == 0 {
+		// The batcher is created once the telemetry it records
+		// to is.
+		sb = &singleBatcher{}
+		bp.batcherFinder = sb
+	} else {
+		mb := &multiBatcher{
+			Processor: bp,
+			batchers:  map[attribute.Set]*batcher{},
+			lifecycleLogger: bp.logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+				return zapcore.NewSamplerWithOptions(core, time.Second, lifecycleLogsPerSecond, 0)
+			})),
+		}
+		if bp.metadataLimit != unlimitedMetadataCardinality && cfg.MetadataCardinalityWarnPercent != 0 {
+			mb.warnThreshold = (bp.metadataLimit*int(cfg.MetadataCardinalityWarnPercent) + 99) / 100
+		}
+		if bp.evictLRU {
+			mb.lru = list.New()
+		}
+		keys := len(mks) + len(patterns) + len(bp.authKeys) + len(bp.resourceKeys)
+		if bp.groupBySource {
+			keys++
+		}
+		if bp.metadataLimit == 1 && keys > 1 {
+			bp.logger.Warn("metadata_cardinality_limit of 1 allows a single combination of the values of several keys",
+				zap.Int("keys", keys))
+		}
+		if cfg.MetadataBatcherIdleTimeout > 0 {
+			bp.idleTimeout = cfg.MetadataBatcherIdleTimeout
+			bp.expireBatcher = mb.expireBatcher
+		}
+		bp.batcherFinder = mb
+	}
+
+	bpt, err := newBatchProcessorTelemetry(set, cfg, dataType, bp.currentMetadataCardinality, bp.currentMetadataKeyCardinality, bp.inFlight.Load, bp.queueUsage, bp.activeBatchers.Load)
+	if err != nil {
+		return nil, fmt.Errorf("error creating batch processor telemetry: %w", err)
+	}
+	bp.telemetry = bpt
+	bp.trackBytes = bpt.enabled.sendSizeBytes || bp.sendBatchSizeBytes > 0 || bp.sendBatchMaxSizeBytes > 0
+	if sb != nil {
+		sb.batcher = bp.newBatcher(attribute.NewSet(), nil, nil, "")
+	}
+
+	tp := set.TracerProvider
+	if tp == nil {
+		tp = trace.NewNoopTracerProvider()
+	}
+	bp.tracer = tp.Tracer(set.ID.String())
+	bp.spanName = obsmetrics.ProcessorPrefix + set.ID.String() + sendSpanSuffix
+
+	if cfg.Persistence.Directory != "" {
+		bp.walDir = walDirectory(cfg.Persistence.Directory, set.ID, dataType)
+	}
+	bp.deadLetter = s.DeadLetter
+
+	return bp, nil
+}
+
+// newBatcher creates the batcher identified by key.
+func (bp *Processor) newBatcher(key attribute.Set, md map[string][]string, auth client.AuthData, source string) *batcher {
+	now := time.Now()
+	metadata := client.NewMetadata(md)
+	exportCtx := client.NewContext(context.Background(), client.Info{
+		Metadata: metadata,
+		Auth:     auth,
+		Source:   source,
+	})
+	b := &batcher{
+		processor: bp,
+		newItem:   make(chan incomingItem, runtime.NumCPU()),
+		exportCtx: exportCtx,
+		batch:     bp.settings.NewBatch(bp.trackBytes),
+		stopC:     make(chan struct{}),
+		override:  bp.findOverride(metadata),
+		key:       key,
+		id:        batcherID(key),
+		attrs:     bp.telemetry.batcherAttrs(key),
+		state:     batcherState{lastExport: now, lastFlush: now},
+	}
+	b.sequence = bp.sequences.counter(b.id)
+	if bp.turns != nil {
+		b.after, b.exited = bp.turns.next(b.id)
+	}
+	if bp.walDir != "" {
+		b.wal = newWALSegment(bp.walDir, key, &bp.walSegments, newWALHeader(md, auth, source), bp.settings.Marshal)
+	}
+	b.propagated = newPropagatedInfo(bp.propagateAllMetadata, bp.propagateAddr, bp.propagateAuth, bp.propagateSource, md)
+	b.updateSizes()
+	// The timer is created before the batcher is returned, as
+	// producers use it with sync_consume.
+	if b.timeout != 0 && b.sendBatchSize != 0 {
+		b.timer = time.NewTimer(b.timeout)
+	}
+	b.processor.goroutines.Add(1)
+	b.processor.activeBatchers.Add(1)
+	b.processor.telemetry.recordBatcherCreated()
+	go b.start()
+	return b
+}
+
+// findOverride returns the first override matching md, or nil.
+func (bp *Processor) findOverride(md client.Metadata) *BatchOverride {
+NEXT:
+	for i := range bp.overrides {
+		o := &bp.overrides[i]
+		for k, v := range o.Metadata {
+			vs := md.Get(k)
+			if len(vs) != 1 {
+				continue NEXT
+			}
+			if h := bp.metadataKeyHandlers[strings.ToLower(k)]; h != nil && h.caseInsensitive {
+				if !strings.EqualFold(vs[0], v) {
+					continue NEXT
+				}
+			} else if vs[0] != v {
+				continue NEXT
+			}
+		}
+		return o
+	}
+	return nil
+}
+
+func (bp *Processor) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: bp.mutatesData}
+}
+
+// Start is invoked during service startup.
+func (bp *Processor) Start(ctx context.Context, host component.Host) error {
+	bp.goroutines.Add(1)
+	bp.status.start(host)
+	if hp, ok := bp.settings.Next.(consumer.BatchHintProvider); ok {
+		bp.applyBatchHint(hp.PreferredBatchSize())
+	}
+	if bp.deadLetterID != nil {
+		dl, err := bp.settings.DeadLetterExporter(host, *bp.deadLetterID)
+		if err != nil {
+			return err
+		}
+		bp.deadLetter = dl
+	}
+	if bp.walDir != "" {
+		if err := os.MkdirAll(bp.walDir, 0o700); err != nil {
+			return fmt.Errorf("failed to create the write-ahead log directory: %w", err)
+		}
+		return bp.replayWAL(ctx)
+	}
+	return nil
+}
+
+// Consume hands a request of n items to its batchers and, when errors
+// are propagated or with sync_consume, returns their export results.
+func (bp *Processor) Consume(ctx context.Context, item any, n int) error {
+	done, err := bp.accept(ctx, item, n)
+	if err != nil {
+		return err
+	}
+	return bp.wait(ctx, done)
+}
+
+// MetadataCardinality returns the number of batchers of distinct
+// metadata values, not counting the overflow batcher.
+func (bp *Processor) MetadataCardinality() int {
+	return bp.currentMetadataCardinality()
+}
+
+// accept hands a request of n items to its batchers, returning the
+// channel notified with their export results when errors are
+// propagated.
+func (bp *Processor) accept(ctx context.Context, item any, n int) (chan error, error) {
+	ctx = bp.limitMetadata(ctx)
+	bp.drainLock.RLock()
+	defer bp.drainLock.RUnlock()
+	if bp.draining {
+		return nil, bp.reject(ErrShuttingDown, item)
+	}
+	// Counted once, the count follows the data to its batch.
+	if bp.maxInFlight != 0 && !bp.acquireInFlight(n) {
+		return nil, bp.reject(ErrInFlightLimit, item)
+	}
+	if bp.mutatesData && bp.settings.Mutable != nil {
+		if mutable, copied := bp.settings.Mutable(item); copied {
+			// Data shared by a fan-out is copied before being moved.
+			item = mutable
+			bp.telemetry.recordReadOnlyCopy()
+		}
+	}
+	if len(bp.resourceKeys) != 0 {
+		return bp.consumePartitions(ctx, bp.settings.Partition(bp.resourceKeys, item, n, !bp.mutatesData), bp.propagateErrors)
+	}
+	b, err := bp.findBatcher(ctx, nil)
+	if err != nil {
+		bp.releaseInFlight(n)
+		return nil, err
+	}
+	return bp.consumeItem(ctx, b, item, n, bp.propagateErrors)
+}
+
+// consumeItem hands a request of n items to its batcher.  With wait, it
+// returns the channel notified with its export result.
+func (bp *Processor) consumeItem(ctx context.Context, b *batcher, item any, n int, wait bool) (chan error, error) {
+	var done chan error
+	if wait || bp.syncConsume {
+		done = make(chan error, 1)
+	}
+	if err := bp.enqueue(ctx, nil, b, item, n, done); err != nil {
+		return nil, err
+	}
+	return done, nil
+}
+
+// wait returns the export errors of the items notified on done, one
+// per slot of its buffer, combined, nil when errors are not propagated.
+func (bp *Processor) wait(ctx context.Context, done chan error) error {
+	if done == nil {
+		return nil
+	}
+	var errs []error
+	if !bp.propagateErrors {
+		// With sync_consume, return the errors of the exports made
+		// by the caller, without waiting for the pending data.
+		for n := len(done); n > 0; n-- {
+			errs = append(errs, <-done)
+		}
+		return consumererror.Combine(errs)
+	}
+	for n := cap(done); n > 0; n-- {
+		var err error
+		select {
+		case err = <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-bp.stoppedC:
+			// Every batcher has exited, pending results were
+			// already sent.
+			select {
+			case err = <-done:
+			default:
+				err = ErrDropped
+			}
+		}
+		errs = append(errs, err)
+	}
+	return consumererror.Combine(errs)
+}
+
+// enqueue hands an item of n spans, data points, or log records to a
+// batcher.  When the batcher has been removed from the multiBatcher
+// since it was found, the batcher for the same combination is found
+// again.  done, when not nil, is notified with the export result of
+// the item.
+func (bp *Processor) enqueue(ctx context.Context, resourceAttrs []attribute.KeyValue, b *batcher, item any, n int, done chan<- error) error {
+	var info client.Info
+	if bp.propagateAllMetadata || bp.propagateAddr || bp.propagateAuth || bp.propagateSource {
+		info = client.FromContext(ctx)
+	}
+	link := trace.SpanContextFromContext(ctx)
+	for {
+		err := b.tryEnqueue(item, n, info, link, done)
+		switch {
+		case err == nil:
+			bp.telemetry.recordReceivedItems(b.attrs, int64(n))
+			return nil
+		case errors.Is(err, ErrBatcherFull):
+			bp.releaseInFlight(n)
+			if resourceAttrs != nil {
+				// consumePartitions wraps the rejected data.
+				return err
+			}
+			return bp.reject(ErrBatcherFull, item)
+		case !errors.Is(err, errBatcherStopped):
+			bp.releaseInFlight(n)
+			return err
+		}
+		if b, err = bp.findBatcher(ctx, resourceAttrs); err != nil {
+			bp.releaseInFlight(n)
+			return err
+		}
+	}
+}
+
+// acquireInFlight adds n items to the in-flight count, returning false
+// without adding them when that would exceed MaxInFlightItems.  A
+// request is accepted when nothing else is in flight, whatever its size.
+func (bp *Processor) acquireInFlight(n int) bool {
+	if cur := bp.inFlight.Add(int64(n)); cur > bp.maxInFlight && cur != int64(n) {
+		bp.inFlight.Add(-int64(n))
+		return false
+	}
+	return true
+}
+
+// releaseInFlight removes n exported or dropped items from the
+// in-flight count.
+func (bp *Processor) releaseInFlight(n int) {
+	if bp.maxInFlight == 0 || n == 0 {
+		return
+	}
+	bp.inFlight.Add(-int64(n))
+}
+
+// warnDeprecatedNames logs the deprecated names of the batching settings
+// found when cfg was unmarshaled.
+func warnDeprecatedNames(logger *zap.Logger, cfg *Config) {
+	if len(cfg.deprecatedNames) != 0 {
+		logger.Warn("The batching settings use deprecated names, rename them",
+			zap.Strings("settings", cfg.deprecatedNames))
+	}
+}
+
+// reject wraps err, rejecting the data of items, in the error returned
+// to the producers.
+func (bp *Processor) reject(err error, items ...any) error {
+	if bp.settings.Reject == nil {
+		return err
+	}
+	return bp.settings.Reject(err, items)
+}
+
+// returnedData returns the data that failed to export in req, which is
+// the data carried by err when the next consumer reported a partial
+// failure, and whether err carried data.
+func (bp *Processor) returnedData(req any, err error) (any, bool) {
+	if bp.settings.Returned == nil {
+		return req, false
+	}
+	return bp.settings.Returned(req, err)
+}
+
+// sizeItems returns the marshaled size of an item, zero when unknown.
+func (bp *Processor) sizeItems(item any) int {
+	if bp.settings.Size == nil {
+		return 0
+	}
+	return bp.settings.Size(item)
+}
@@ -0,0 +1,206 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/obsreport"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+func TestBatchProcessorGroupByMetadataOnly(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.GroupBy = []GroupBySource{{Metadata: "X-Tenant"}}
+	b, err := NewBatcher(processortest.NewNopCreateSettings(), cfg, (&intsSink{}).settings())
+	require.NoError(t, err)
+
+	// Metadata-only grouping does not partition the incoming data.
+	assert.Equal(t, []string{"x-tenant"}, b.p.metadataKeys)
+	assert.Empty(t, b.p.resourceKeys)
+	require.NoError(t, b.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, b.Shutdown(context.Background()))
+}
+
+func TestBatchProcessorDeprecatedNamesWarning(t *testing.T) {
+	cfg := NewDefaultConfig()
+	require.NoError(t, component.UnmarshalConfig(confmap.NewFromStringMap(map[string]any{"send_batch_size": 100}), cfg))
+
+	core, logs := observer.New(zap.WarnLevel)
+	set := processortest.NewNopCreateSettings()
+	set.Logger = zap.New(core)
+	b, err := NewBatcher(set, cfg, (&intsSink{}).settings())
+	require.NoError(t, err)
+	assert.Equal(t, 100, b.p.sizes.Load().sendBatchSize)
+	warnings := logs.FilterMessageSnippet("deprecated names").All()
+	require.Len(t, warnings, 1)
+	assert.Equal(t, []any{"send_batch_size (use min_size)"}, warnings[0].ContextMap()["settings"])
+}
+
+func TestBatchProcessorMaxInFlightItemsReleasedOnExport(t *testing.T) {
+	sink := &intsSink{}
+	cfg := NewDefaultConfig()
+	cfg.SendBatchSize = 4
+	cfg.SendBatchMaxSize = 4
+	cfg.Timeout = 10 * time.Minute
+	cfg.MaxInFlightItems = 100
+	cfg.SyncConsume = true
+	b, err := NewBatcher(processortest.NewNopCreateSettings(), cfg, sink.settings())
+	require.NoError(t, err)
+	require.NoError(t, b.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, b.Add(context.Background(), []int{1, 2, 3, 4, 5, 6}))
+	// The items of the sent batch are released, the remainder waits for
+	// the next batch.
+	assert.Equal(t, int64(2), b.p.inFlight.Load())
+
+	require.NoError(t, b.Shutdown(context.Background()))
+	assert.Equal(t, 6, sink.itemCount())
+	assert.Equal(t, int64(0), b.p.inFlight.Load())
+}
+
+func TestBatchProcessorMaxInFlightItemsReleasedOnDrop(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MaxInFlightItems = 100
+	flushOnShutdown := false
+	cfg.FlushOnShutdown = &flushOnShutdown
+	sink := &intsSink{}
+	b, err := NewBatcher(processortest.NewNopCreateSettings(), cfg, sink.settings())
+	require.NoError(t, err)
+	require.NoError(t, b.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, b.Add(context.Background(), make([]int, 10)))
+	assert.Equal(t, int64(10), b.p.inFlight.Load())
+
+	require.NoError(t, b.Shutdown(context.Background()))
+	assert.Empty(t, sink.exported())
+	assert.Equal(t, int64(0), b.p.inFlight.Load())
+}
+
+// fakeClock is a clock advanced by tests.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// batchAges returns the points of the batch age histogram collected by
+// reader, by trigger.
+func batchAges(t *testing.T, reader sdkmetric.Reader) map[string]metricdata.HistogramDataPoint[float64] {
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	ages := map[string]metricdata.HistogramDataPoint[float64]{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != obsreport.BuildProcessorCustomMetricName(typeStr, "batch_age") {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+				trigger, _ := dp.Attributes.Value("trigger")
+				ages[trigger.AsString()] = dp
+			}
+		}
+	}
+	return ages
+}
+
+// bucketCount returns the number of samples of the histogram point p up
+// to upperBound.
+func bucketCount(t *testing.T, p metricdata.HistogramDataPoint[float64], upperBound float64) uint64 {
+	var count uint64
+	for i, bound := range p.Bounds {
+		count += p.BucketCounts[i]
+		if bound == upperBound {
+			return count
+		}
+	}
+	require.Failf(t, "bucket not found", "no bucket with upper bound %v", upperBound)
+	return 0
+}
+
+func TestBatchProcessorBatchAge(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithResource(resource.Empty()), sdkmetric.WithReader(reader))
+	t.Cleanup(func() { assert.NoError(t, mp.Shutdown(context.Background())) })
+
+	sink := &intsSink{}
+	cfg := NewDefaultConfig()
+	cfg.SendBatchSize = 4
+	cfg.Timeout = 250 * time.Millisecond
+	cfg.SyncConsume = true
+	set := processortest.NewNopCreateSettings()
+	set.ID = component.NewID(typeStr)
+	set.MeterProvider = mp
+	set.MetricsLevel = configtelemetry.LevelNormal
+	b, err := NewBatcher(set, cfg, sink.settings())
+	require.NoError(t, err)
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	b.p.now = clock.Now
+	require.NoError(t, b.Start(context.Background(), componenttest.NewNopHost()))
+
+	// The timer sends the batch about when the clock reaches the
+	// timeout.
+	require.NoError(t, b.Add(context.Background(), []int{1, 2}))
+	clock.advance(240 * time.Millisecond)
+	require.Eventually(t, func() bool { return sink.itemCount() == 2 }, time.Second, 5*time.Millisecond)
+
+	// The batch is filled 3ms after its first items.
+	clock.advance(time.Second)
+	require.NoError(t, b.Add(context.Background(), []int{3}))
+	clock.advance(3 * time.Millisecond)
+	require.NoError(t, b.Add(context.Background(), []int{4, 5, 6}))
+	assert.Equal(t, 6, sink.itemCount())
+	require.NoError(t, b.Shutdown(context.Background()))
+
+	ages := batchAges(t, reader)
+	require.Len(t, ages, 2)
+	timeout := ages["timeout"]
+	assert.Equal(t, uint64(1), timeout.Count)
+	assert.InDelta(t, 240, timeout.Sum, 0.001)
+	assert.Zero(t, bucketCount(t, timeout, 100))
+	assert.Equal(t, uint64(1), bucketCount(t, timeout, 250))
+	size := ages["batch_size"]
+	assert.Equal(t, uint64(1), size.Count)
+	assert.InDelta(t, 3, size.Sum, 0.001)
+	assert.Equal(t, uint64(1), bucketCount(t, size, 5))
+}
@@ -0,0 +1,192 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching // import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"go.opentelemetry.io/collector/client"
+)
+
+// incomingItem carries an item along with its number of spans, data
+// points, or log records, counted once when the request is accepted,
+// the client information of the request it arrived with, when it is
+// propagated to the next consumer, the channel notified of its export
+// result when ErrorMode is "propagate", and the end of its write-ahead
+// log record when persistence is on.
+type incomingItem struct {
+	data   any
+	items  int
+	info   client.Info
+	done   chan<- error
+	walEnd int64
+	link   trace.SpanContext
+}
+
+// snapshotMetadata copies md so that it can be read by the batcher
+// goroutine without racing with the producer.
+func snapshotMetadata(md client.Metadata) map[string][]string {
+	snapshot := map[string][]string{}
+	md.Range(func(k string, v []string) bool {
+		snapshot[k] = v
+		return true
+	})
+	return snapshot
+}
+
+// propagatedInfo merges the client information of the requests in
+// the pending batch for the context passed to the next consumer.
+//
+// For metadata, the batching keys keep the batcher's values, and for
+// any other key the first request in the batch carrying it wins; keys
+// are compared case-insensitively.  The address and auth data are
+// only set when every request in the batch has the same value.
+type propagatedInfo struct {
+	metadata bool
+	addr     bool
+	auth     bool
+	source   bool
+
+	// base is the metadata of the batcher's batching keys.
+	base map[string][]string
+
+	// The remaining fields describe the pending batch, and are
+	// valid when pending is true.
+	pending        bool
+	md             map[string][]string
+	seen           map[string]bool
+	addrValue      net.Addr
+	addrConflict   bool
+	authValue      client.AuthData
+	authConflict   bool
+	sourceValue    string
+	sourceConflict bool
+}
+
+// newPropagatedInfo returns nil when nothing is propagated.
+func newPropagatedInfo(metadata, addr, auth, source bool, base map[string][]string) *propagatedInfo {
+	if !metadata && !addr && !auth && !source {
+		return nil
+	}
+	return &propagatedInfo{metadata: metadata, addr: addr, auth: auth, source: source, base: base}
+}
+
+// incoming adds the client information to propagate to an item.
+func (p *propagatedInfo) incoming(in incomingItem, info client.Info) incomingItem {
+	if p.metadata {
+		in.info.Metadata = client.NewMetadata(snapshotMetadata(info.Metadata))
+	}
+	if p.addr {
+		in.info.Addr = info.Addr
+	}
+	if p.auth {
+		in.info.Auth = info.Auth
+	}
+	if p.source {
+		in.info.Source = info.Source
+	}
+	return in
+}
+
+// merge adds the client information of a request in the batch.
+func (p *propagatedInfo) merge(info client.Info) {
+	if !p.pending {
+		p.pending = true
+		p.addrValue = info.Addr
+		p.authValue = info.Auth
+		p.sourceValue = info.Source
+		if p.metadata {
+			p.md = make(map[string][]string, len(p.base))
+			p.seen = make(map[string]bool, len(p.base))
+			for k, v := range p.base {
+				p.md[k] = v
+				p.seen[strings.ToLower(k)] = true
+			}
+		}
+	} else {
+		p.addrConflict = p.addrConflict || !addrEqual(p.addrValue, info.Addr)
+		p.authConflict = p.authConflict || !authEqual(p.authValue, info.Auth)
+		p.sourceConflict = p.sourceConflict || p.sourceValue != info.Source
+	}
+	if !p.metadata {
+		return
+	}
+	info.Metadata.Range(func(k string, v []string) bool {
+		if !p.seen[k] {
+			p.seen[k] = true
+			p.md[k] = v
+		}
+		return true
+	})
+}
+
+// reset clears the merged information once the batch has been sent.
+func (p *propagatedInfo) reset() {
+	*p = propagatedInfo{metadata: p.metadata, addr: p.addr, auth: p.auth, source: p.source, base: p.base}
+}
+
+// context returns exportCtx with the merged client information.
+func (p *propagatedInfo) context(exportCtx context.Context) context.Context {
+	if !p.pending {
+		return exportCtx
+	}
+	var info client.Info
+	if p.metadata {
+		info.Metadata = client.NewMetadata(p.md)
+	}
+	if p.addr && !p.addrConflict {
+		info.Addr = p.addrValue
+	}
+	if p.auth && !p.authConflict {
+		info.Auth = p.authValue
+	}
+	if p.source && !p.sourceConflict {
+		info.Source = p.sourceValue
+	}
+	// The fields left unset, as on conflict, keep the values of the
+	// batching keys, if any.
+	return client.NewContext(context.Background(), info.Merge(client.FromContext(exportCtx)))
+}
+
+func addrEqual(a, b net.Addr) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Network() == b.Network() && a.String() == b.String()
+}
+
+func authEqual(a, b client.AuthData) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	an, bn := a.GetAttributeNames(), b.GetAttributeNames()
+	if len(an) != len(bn) {
+		return false
+	}
+	sort.Strings(an)
+	sort.Strings(bn)
+	for i, name := range an {
+		if name != bn[i] || !reflect.DeepEqual(a.GetAttribute(name), b.GetAttribute(name)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.opentelemetry.io/collector/client"
+)
+
+// fakeAuthData is the client.AuthData of a test request.
+type fakeAuthData map[string]any
+
+func (a fakeAuthData) GetAttribute(name string) any {
+	return a[name]
+}
+
+func (a fakeAuthData) GetAttributeNames() []string {
+	var names []string
+	for name := range a {
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestPropagatedInfoMergeMetadata(t *testing.T) {
+	p := newPropagatedInfo(true, false, false, false, map[string][]string{"x-tenant": {"a"}})
+	p.merge(client.Info{Metadata: client.NewMetadata(map[string][]string{"X-Tenant": {"b"}, "x-b3-flags": {"1"}})})
+	p.merge(client.Info{Metadata: client.NewMetadata(map[string][]string{"X-B3-Flags": {"0"}, "x-other": {"v"}})})
+	assert.Equal(t, map[string][]string{
+		"x-tenant":   {"a"},
+		"x-b3-flags": {"1"},
+		"x-other":    {"v"},
+	}, p.md)
+
+	p.reset()
+	assert.Nil(t, p.md)
+	ctx := context.Background()
+	assert.Equal(t, ctx, p.context(ctx))
+}
+
+func TestNewPropagatedInfoDisabled(t *testing.T) {
+	assert.Nil(t, newPropagatedInfo(false, false, false, false, nil))
+}
+
+func TestPropagatedInfoMergeAddrAndAuth(t *testing.T) {
+	addr1 := &net.IPAddr{IP: net.IPv4(10, 0, 0, 1)}
+	addr2 := &net.IPAddr{IP: net.IPv4(10, 0, 0, 2)}
+
+	p := newPropagatedInfo(false, true, true, false, nil)
+	p.merge(client.Info{Addr: addr1, Auth: fakeAuthData{"subject": "alice"}})
+	p.merge(client.Info{Addr: &net.IPAddr{IP: net.IPv4(10, 0, 0, 1)}, Auth: fakeAuthData{"subject": "alice"}})
+	info := client.FromContext(p.context(context.Background()))
+	assert.Equal(t, addr1, info.Addr)
+	assert.Equal(t, "alice", info.Auth.GetAttribute("subject"))
+
+	// Differing values are dropped rather than picked arbitrarily.
+	p.merge(client.Info{Addr: addr2, Auth: fakeAuthData{"subject": "bob"}})
+	info = client.FromContext(p.context(context.Background()))
+	assert.Nil(t, info.Addr)
+	assert.Nil(t, info.Auth)
+}
+
+func TestPropagatedInfoMergeSource(t *testing.T) {
+	p := newPropagatedInfo(false, false, false, true, nil)
+	p.merge(client.Info{Source: "otlp"})
+	p.merge(client.Info{Source: "otlp"})
+	assert.Equal(t, "otlp", client.FromContext(p.context(context.Background())).Source)
+
+	// On conflict, the source of the export context is kept.
+	p.merge(client.Info{Source: "otlp/internal"})
+	ctx := client.NewContext(context.Background(), client.Info{Source: "batcher"})
+	assert.Equal(t, "batcher", client.FromContext(p.context(ctx)).Source)
+	assert.Empty(t, client.FromContext(p.context(context.Background())).Source)
+}
@@ -0,0 +1,161 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching // import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+)
+
+// maxRequeues is the number of consecutive exports of a batcher whose
+// failed data, returned by the next consumer, is put back in the batch.
+// The data of the following failure is dropped.
+const maxRequeues = 3
+
+// retrySend sends a failed request again with exponential backoff,
+// until it succeeds, fails with a permanent error, or MaxElapsedTime
+// passes.  On shutdown, the request is sent a last time without
+// waiting.  It returns the error of the last attempt.
+func (b *batcher) retrySend(ctx context.Context, req any, err error) error {
+	cfg := b.processor.retry
+	interval := cfg.InitialInterval
+	start := time.Now()
+	for err != nil && !consumererror.IsPermanent(err) {
+		if _, returned := b.processor.returnedData(req, err); returned {
+			// Only the returned data is sent again, by requeuing
+			// it, so that accepted data is not duplicated.
+			return err
+		}
+		if cfg.MaxElapsedTime > 0 && time.Since(start)+interval > cfg.MaxElapsedTime {
+			return err
+		}
+		b.processor.logger.Debug("Sender failed, retrying",
+			zap.Duration("interval", interval), zap.Error(err))
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-b.processor.shutdownC:
+			timer.Stop()
+			return b.batch.Consume(ctx, req)
+		}
+		err = b.batch.Consume(ctx, req)
+		if interval *= 2; interval > cfg.MaxInterval {
+			interval = cfg.MaxInterval
+		}
+	}
+	return err
+}
+
+// exportFailed handles the failure of an export of sent items.  Data
+// returned by the next consumer with a retryable error is put back in
+// the batch, up to maxRequeues times in a row and unless the processor
+// is shutting down; otherwise the failed data is handed to the
+// dead-letter consumer or dropped.
+func (b *batcher) exportFailed(ctx context.Context, trigger trigger, req any, sent, bytes int, err error) {
+	permanence, reason := permanenceRetryable, reasonSendFailed
+	if consumererror.IsPermanent(err) {
+		permanence, reason = permanencePermanent, reasonSendFailedPermanent
+	}
+	// Only the failed data is lost, not the rest of a split batch
+	// nor the data accepted by the next consumer.  Without returned
+	// data, the count of rejected items carried by the error, if any,
+	// is used, and otherwise the whole request is counted as rejected.
+	failed, returned := b.processor.returnedData(req, err)
+	failedItems, failedBytes := sent, bytes
+	if returned {
+		failedItems = b.processor.settings.Count(failed)
+		if b.processor.telemetry.enabled.droppedBytes {
+			failedBytes = b.processor.sizeItems(failed)
+		}
+	} else if n, ok := consumererror.Count(err); ok && n >= 0 && n < sent {
+		failedItems = n
+		failedBytes = int(int64(bytes) * int64(n) / int64(sent))
+	}
+	fields := []zap.Field{
+		zap.String("data_type", string(b.processor.dataType)),
+		zap.Stringer("trigger", trigger.batchTrigger()),
+		zap.String("permanence", permanence),
+		zap.Int("items", sent),
+		zap.Int("bytes", bytes),
+		zap.Int("rejected_items", failedItems),
+		zap.Int("accepted_items", sent-failedItems),
+	}
+	b.processor.telemetry.recordSendFailed(int64(sent), int64(bytes), permanence)
+	b.processor.telemetry.recordRejectedItems(int64(failedItems))
+	if returned && permanence == permanenceRetryable && b.requeues < maxRequeues && !b.processor.shuttingDown() {
+		b.requeues++
+		b.batch.Requeue(failed)
+		b.processor.releaseInFlight(sent - failedItems)
+		b.logSendFailed(append(fields, zap.Int("requeued_items", failedItems)), err)
+		return
+	}
+	b.requeues = 0
+	b.processor.releaseInFlight(sent)
+	if b.processor.deadLetter == nil {
+		fields = append(fields, zap.Int("dropped_items", failedItems))
+	}
+	b.logSendFailed(fields, err)
+	if b.processor.deadLetter != nil {
+		b.processor.sendDeadLetter(ctx, b.attrs, failed)
+	} else {
+		b.processor.telemetry.recordDropped(b.attrs, int64(failedItems), int64(failedBytes), reason)
+	}
+	b.waitErr = consumererror.Combine([]error{b.waitErr, err})
+	b.rollWAL()
+}
+
+// logSendFailed logs a failed export, at most once per
+// failureLogInterval for the batcher.  The log includes the number of
+// failures suppressed since the previous one.
+func (b *batcher) logSendFailed(fields []zap.Field, err error) {
+	now := time.Now()
+	if interval := b.processor.failureLogInterval; interval > 0 && !b.lastFailureLog.IsZero() && now.Sub(b.lastFailureLog) < interval {
+		b.suppressedFailures++
+		return
+	}
+	b.lastFailureLog = now
+	if attrs := b.metadataAttributes(); len(attrs) != 0 {
+		fields = append(fields, zap.Any("metadata", attrs))
+	}
+	fields = append(fields, zap.Int("suppressed_failures", b.suppressedFailures), zap.Error(err))
+	b.suppressedFailures = 0
+	b.processor.logger.Warn("Sender failed", fields...)
+}
+
+// logSendRecovered logs the failures suppressed before a successful
+// export, so that the next failure is logged immediately.
+func (b *batcher) logSendRecovered() {
+	if b.suppressedFailures != 0 {
+		b.processor.logger.Info("Sender recovered",
+			zap.String("data_type", string(b.processor.dataType)),
+			zap.Int("suppressed_failures", b.suppressedFailures))
+	}
+	b.suppressedFailures = 0
+	b.lastFailureLog = time.Time{}
+}
+
+// shuttingDown returns whether Shutdown has been called.
+func (bp *Processor) shuttingDown() bool {
+	select {
+	case <-bp.shutdownC:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching // import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Shutdown is invoked during service shutdown.  The final flush is
+// bounded by ctx and by drain_timeout: when either expires before every
+// batch has been exported, the pending data is dropped.  Only the
+// expiry of ctx is returned as an error.
+func (bp *Processor) Shutdown(ctx context.Context) error {
+	// Done corresponds with the initial Add(1) in Start.
+	bp.goroutines.Done()
+	defer func() {
+		if err := bp.telemetry.unregister(); err != nil {
+			bp.logger.Warn("Failed to unregister the metrics callback", zap.Error(err))
+		}
+	}()
+
+	drainCtx := ctx
+	if bp.drainTimeout > 0 {
+		var cancel context.CancelFunc
+		drainCtx, cancel = context.WithTimeout(ctx, bp.drainTimeout)
+		defer cancel()
+	}
+	bp.shutdownCtx = drainCtx
+
+	done := make(chan struct{})
+	go func() {
+		// Wait for the requests being enqueued, the batchers keep
+		// receiving until shutdownC is closed.
+		bp.drainLock.Lock()
+		bp.draining = true
+		bp.drainLock.Unlock()
+		close(bp.shutdownC)
+
+		// Wait until all goroutines are done.
+		bp.goroutines.Wait()
+		close(bp.stoppedC)
+		close(done)
+	}()
+	select {
+	case <-done:
+		if !bp.flushExpired.Load() {
+			bp.logger.Debug("Flushed pending data on shutdown", bp.shutdownFields()...)
+			return nil
+		}
+	case <-drainCtx.Done():
+	}
+	if err := ctx.Err(); err != nil {
+		bp.logger.Warn("Shutdown deadline expired, dropping pending data", bp.shutdownFields()...)
+		return fmt.Errorf("failed to flush the batch processor before the shutdown deadline: %w", err)
+	}
+	bp.logger.Warn("Drain timeout expired, dropping pending data",
+		append(bp.shutdownFields(), zap.Duration("drain_timeout", bp.drainTimeout))...)
+	return nil
+}
+
+// shutdownFields returns the log fields describing the flush on
+// shutdown: the batchers that completed it, and those that have not,
+// whose data is dropped.
+func (bp *Processor) shutdownFields() []zap.Field {
+	flushed := bp.flushedBatchers.Load()
+	return []zap.Field{
+		zap.String("data_type", string(bp.dataType)),
+		zap.Int64("flushed_batchers", flushed),
+		zap.Int64("abandoned_batchers", bp.shutdownBatchers.Load()-flushed),
+	}
+}
+
+// shutdown drains the batcher and flushes its pending data, once one
+// of the shutdown_parallelism flush slots is free.  Data not flushed
+// before the shutdown deadline is dropped.
+func (b *batcher) shutdown() {
+	bp := b.processor
+	if !b.removed.Load() {
+		bp.telemetry.recordBatcherRemoved(removalShutdown)
+	}
+	if !bp.flushOnShutdown {
+		b.dropPending()
+		return
+	}
+	bp.shutdownBatchers.Add(1)
+	if b.after != nil {
+		// The removed batcher for the same values may be waiting
+		// for a flush slot, let it exit before taking one.
+		select {
+		case <-b.after:
+			b.after = nil
+		case <-bp.shutdownCtx.Done():
+			bp.flushExpired.Store(true)
+			b.dropPending()
+			return
+		}
+	}
+	select {
+	case bp.flushSlots <- struct{}{}:
+		defer func() { <-bp.flushSlots }()
+	case <-bp.shutdownCtx.Done():
+		bp.flushExpired.Store(true)
+		b.dropPending()
+		return
+	}
+	b.exportCtx = flushContext{Context: b.exportCtx, cancel: bp.shutdownCtx}
+DONE:
+	for {
+		select {
+		case item := <-b.newItem:
+			b.processItem(item)
+		default:
+			break DONE
+		}
+	}
+	// This is the close of the channel.  Failed parts of a split
+	// batch are dropped, so the loop ends.
+	for b.batch.ItemCount() > 0 {
+		if bp.shutdownCtx.Err() != nil {
+			bp.flushExpired.Store(true)
+			b.dropPending()
+			return
+		}
+		b.sendItems(triggerShutdown)
+	}
+	if bp.shutdownCtx.Err() != nil {
+		// The last export may have been cancelled.
+		bp.flushExpired.Store(true)
+		return
+	}
+	bp.flushedBatchers.Add(1)
+}
+
+// drainAndStop flushes the items of a batcher removed from the
+// multiBatcher and releases its timer.
+func (b *batcher) drainAndStop() {
+DONE:
+	for {
+		select {
+		case item := <-b.newItem:
+			b.processItem(item)
+		default:
+			break DONE
+		}
+	}
+	b.stopTimer()
+	for b.batch.ItemCount() > 0 {
+		b.sendItems(triggerTimeout)
+	}
+	if b.wal != nil {
+		if err := b.wal.remove(); err != nil {
+			b.processor.logger.Warn("Failed to remove the write-ahead log", zap.Error(err))
+		}
+	}
+}
+
+// stop marks the batcher stopped, waiting for producers that are
+// enqueuing to it, then signals its goroutine to flush and exit.
+// Called in its own goroutine, as the batcher must keep receiving
+// while producers complete.
+func (b *batcher) stop() {
+	defer b.processor.goroutines.Done()
+	b.stopLock.Lock()
+	b.stopped = true
+	b.stopLock.Unlock()
+	close(b.stopC)
+}
+
+// dropPending drains the channel and discards the pending batch
+// without calling the next consumer.
+func (b *batcher) dropPending() {
+DONE:
+	for {
+		select {
+		case in := <-b.newItem:
+			b.addWaiter(in.done)
+			b.addToBatch(in.data, in.items)
+		default:
+			break DONE
+		}
+	}
+	b.notifyWaiters(ErrDropped)
+	b.links = nil
+	dropped, bytes := b.batch.ItemCount(), b.batch.ByteSize()
+	if dropped == 0 {
+		return
+	}
+	b.batch = b.processor.settings.NewBatch(b.processor.trackBytes)
+	b.firstAdd = time.Time{}
+	b.publishState()
+	b.processor.logger.Warn("Dropping pending data on shutdown",
+		zap.String("data_type", string(b.processor.dataType)),
+		zap.Int("dropped_items", dropped))
+	b.processor.telemetry.recordDropped(b.attrs, int64(dropped), int64(bytes), reasonShutdownAbandoned)
+	b.processor.releaseInFlight(dropped)
+}
+
+// flushContext is the context of the exports made on shutdown: it has
+// the values of the batcher's context, and the deadline and
+// cancellation of the context passed to Shutdown.
+type flushContext struct {
+	context.Context
+	cancel context.Context
+}
+
+func (c flushContext) Deadline() (time.Time, bool) {
+	return c.cancel.Deadline()
+}
+
+func (c flushContext) Done() <-chan struct{} {
+	return c.cancel.Done()
+}
+
+func (c flushContext) Err() error {
+	return c.cancel.Err()
+}
@@ -0,0 +1,118 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching // import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+import (
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+)
+
+const (
+	// statusMaxAttributes and statusMaxValueLength bound the batcher
+	// attributes included in reported status events.
+	statusMaxAttributes  = 16
+	statusMaxValueLength = 128
+)
+
+// statusTracker reports the status of the processor to the host: a
+// recoverable error once a batcher reaches the threshold of consecutive
+// failed exports, and OK once no batcher is failing anymore.
+type statusTracker struct {
+	id        component.ID
+	threshold int
+
+	// lock guards the fields below and serializes the reports.
+	lock     sync.Mutex
+	reporter component.StatusReporter
+	failing  int
+}
+
+// start sets the reporter from the host, when it accepts status
+// reports.
+func (s *statusTracker) start(host component.Host) {
+	r, ok := host.(component.StatusReporter)
+	if !ok {
+		return
+	}
+	s.lock.Lock()
+	s.reporter = r
+	s.lock.Unlock()
+}
+
+// exportResult records the result of an export of b.
+func (b *batcher) exportResult(err error) {
+	s := &b.processor.status
+	if s.threshold == 0 {
+		return
+	}
+	if err == nil {
+		b.recovered()
+		return
+	}
+	b.failures++
+	if b.failures != s.threshold {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failing++
+	if s.reporter != nil {
+		s.reporter.ReportComponentStatus(s.id, component.StatusEvent{
+			Status:     component.StatusRecoverableError,
+			Err:        err,
+			Attributes: b.metadataAttributes(),
+		})
+	}
+}
+
+// recovered resets the failures of b, reporting OK if it was the last
+// failing batcher.
+func (b *batcher) recovered() {
+	s := &b.processor.status
+	failing := b.failures >= s.threshold
+	b.failures = 0
+	if !failing {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failing--
+	if s.failing == 0 && s.reporter != nil {
+		s.reporter.ReportComponentStatus(s.id, component.StatusEvent{Status: component.StatusOK})
+	}
+}
+
+// metadataAttributes returns the attributes identifying b in status
+// events and logs, without its auth attributes.
+func (b *batcher) metadataAttributes() map[string]string {
+	attrs := map[string]string{}
+	for _, kv := range b.key.ToSlice() {
+		if len(attrs) == statusMaxAttributes {
+			break
+		}
+		k := string(kv.Key)
+		if strings.HasPrefix(k, authAttrPrefix) {
+			continue
+		}
+		v := kv.Value.Emit()
+		if len(v) > statusMaxValueLength {
+			v = v[:statusMaxValueLength]
+		}
+		attrs[k] = v
+	}
+	return attrs
+}
@@ -0,0 +1,39 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestBatcherStatusAttributesBounded(t *testing.T) {
+	var kvs []attribute.KeyValue
+	for i := 0; i < 2*statusMaxAttributes; i++ {
+		kvs = append(kvs, attribute.String(string(rune('a'+i)), strings.Repeat("x", 2*statusMaxValueLength)))
+	}
+	kvs = append(kvs, attribute.String(authAttrPrefix+"subject", "secret"))
+	b := &batcher{key: attribute.NewSet(kvs...)}
+
+	attrs := b.metadataAttributes()
+	assert.Len(t, attrs, statusMaxAttributes)
+	for k, v := range attrs {
+		assert.NotContains(t, k, authAttrPrefix)
+		assert.Len(t, v, statusMaxValueLength)
+	}
+}
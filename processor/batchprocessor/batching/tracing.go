@@ -0,0 +1,68 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching // import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// maxSpanLinks bounds the links to the producer spans of the data
+	// of a batch recorded in the span of its export.
+	maxSpanLinks = 128
+
+	sendSpanSuffix = "/send"
+)
+
+var (
+	triggerAttrKey = attribute.Key("batch.trigger")
+	itemsAttrKey   = attribute.Key("batch.items")
+	bytesAttrKey   = attribute.Key("batch.bytes")
+)
+
+// addLink records the span of the producer of data added to the pending
+// batch, up to maxSpanLinks.
+func (b *batcher) addLink(sc trace.SpanContext) {
+	if !sc.IsValid() || len(b.links) >= maxSpanLinks {
+		return
+	}
+	b.links = append(b.links, trace.Link{SpanContext: sc})
+}
+
+// startSpan starts the span of an export of the pending batch, linked to
+// the producer spans of its data and parent of the spans of the next
+// consumer.
+func (b *batcher) startSpan(ctx context.Context, trigger trigger) (context.Context, trace.Span) {
+	attrs := make([]attribute.KeyValue, 0, 1+b.key.Len())
+	attrs = append(attrs, triggerAttrKey.String(trigger.batchTrigger().String()))
+	attrs = append(attrs, b.key.ToSlice()...)
+	return b.processor.tracer.Start(ctx, b.processor.spanName,
+		trace.WithLinks(b.links...),
+		trace.WithAttributes(attrs...))
+}
+
+// endSpan ends the span of an export of sent items and bytes, with an
+// error status when it failed.
+func endSpan(span trace.Span, sent, bytes int, err error) {
+	span.SetAttributes(itemsAttrKey.Int(sent), bytesAttrKey.Int(bytes))
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,146 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching // import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+import (
+	"errors"
+	"reflect"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	errUpdateNotSupported = errors.New("only flush_timeout, min_size and max_size can be updated without a restart")
+	errUpdateTimer        = errors.New("flush_timeout and min_size cannot be updated from or to zero without a restart")
+)
+
+// ConfigUpdater is implemented by the batch processor, the batch
+// connector, and Batcher, for their batching sizes to be tuned without
+// a restart.
+type ConfigUpdater interface {
+	// UpdateConfig applies the flush_timeout, min_size, and
+	// max_size of cfg, and returns an error when cfg is
+	// invalid or changes any other setting.  Each batcher applies
+	// them, along with its override, when it next receives data or
+	// its timer expires, keeping its pending batch.
+	UpdateConfig(cfg *Config) error
+}
+
+// batchSizes are the settings that can be updated without a restart.
+type batchSizes struct {
+	timeout          time.Duration
+	sendBatchSize    int
+	sendBatchMaxSize int
+}
+
+func newBatchSizes(cfg *Config) *batchSizes {
+	return &batchSizes{
+		timeout:          cfg.Timeout,
+		sendBatchSize:    int(cfg.SendBatchSize),
+		sendBatchMaxSize: int(cfg.SendBatchMaxSize),
+	}
+}
+
+var _ ConfigUpdater = (*Processor)(nil)
+
+// UpdateConfig implements ConfigUpdater.  A batcher without a timer,
+// because the flush_timeout or min_size is zero, is not given one, so
+// they cannot be updated from or to zero.  The settings of the signal
+// of the processor in cfg.Signals apply, and can be updated.
+func (bp *Processor) UpdateConfig(cfg *Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	cfg = cfg.forSignal(bp.dataType)
+	bp.cfgLock.Lock()
+	defer bp.cfgLock.Unlock()
+	if (cfg.Timeout == 0) != (bp.cfg.Timeout == 0) || (cfg.SendBatchSize == 0) != (bp.cfg.SendBatchSize == 0) {
+		return errUpdateTimer
+	}
+	updated := bp.cfg
+	updated.Timeout = cfg.Timeout
+	updated.SendBatchSize = cfg.SendBatchSize
+	updated.SendBatchMaxSize = cfg.SendBatchMaxSize
+	if !reflect.DeepEqual(&updated, cfg) {
+		return errUpdateNotSupported
+	}
+	bp.cfg = updated
+	bp.sizes.Store(newBatchSizes(cfg))
+	bp.logger.Info("Updated the batching sizes",
+		zap.String("data_type", string(bp.dataType)),
+		zap.Duration("flush_timeout", cfg.Timeout),
+		zap.Uint32("min_size", cfg.SendBatchSize),
+		zap.Uint32("max_size", cfg.SendBatchMaxSize))
+	return nil
+}
+
+// updateSizes applies the sizes of the processor, when they were updated
+// since last applied, with the override of the batcher.
+func (b *batcher) updateSizes() {
+	sizes := b.processor.sizes.Load()
+	if sizes == b.sizes {
+		return
+	}
+	b.sizes = sizes
+	b.timeout, b.sendBatchSize, b.sendBatchMaxSize = sizes.timeout, sizes.sendBatchSize, sizes.sendBatchMaxSize
+	if o := b.override; o != nil {
+		if o.Timeout != nil {
+			b.timeout = *o.Timeout
+		}
+		if o.SendBatchSize != nil {
+			b.sendBatchSize = int(*o.SendBatchSize)
+		}
+		if o.SendBatchMaxSize != nil {
+			b.sendBatchMaxSize = int(*o.SendBatchMaxSize)
+		}
+	}
+}
+
+// applyBatchHint adopts the number of items preferred by the next
+// consumer as min_size and max_size, when they are left at their
+// defaults, keeping max_size no smaller than min_size.  The preferred
+// size in bytes is not used, as the batches measure their size in bytes
+// or not from the creation of the processor, before the hint is known.
+func (bp *Processor) applyBatchHint(items, _ int) {
+	if items <= 0 {
+		return
+	}
+	bp.cfgLock.Lock()
+	defer bp.cfgLock.Unlock()
+	cfg := bp.cfg
+	hint := uint32(items)
+	if cfg.SendBatchSize == defaultSendBatchSize {
+		cfg.SendBatchSize = hint
+		if cfg.SendBatchMaxSize != 0 && cfg.SendBatchMaxSize < hint {
+			cfg.SendBatchSize = cfg.SendBatchMaxSize
+		}
+	}
+	if cfg.SendBatchMaxSize == 0 {
+		cfg.SendBatchMaxSize = hint
+		if cfg.SendBatchMaxSize < cfg.SendBatchSize {
+			cfg.SendBatchMaxSize = cfg.SendBatchSize
+		}
+	}
+	if cfg.SendBatchSize == bp.cfg.SendBatchSize && cfg.SendBatchMaxSize == bp.cfg.SendBatchMaxSize {
+		return
+	}
+	bp.cfg = cfg
+	bp.sizes.Store(newBatchSizes(&cfg))
+	bp.logger.Info("Adopted the batch size preferred by the next consumer",
+		zap.String("data_type", string(bp.dataType)),
+		zap.Uint32("min_size", cfg.SendBatchSize),
+		zap.Uint32("max_size", cfg.SendBatchMaxSize))
+}
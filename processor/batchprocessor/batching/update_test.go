@@ -0,0 +1,109 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+func TestBatchProcessorUpdateConfigRejected(t *testing.T) {
+	cfg := NewDefaultConfig()
+	b, err := NewBatcher(processortest.NewNopCreateSettings(), cfg, (&intsSink{}).settings())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		modify   func(cfg *Config)
+		expected string
+	}{
+		{
+			name:     "metadata keys",
+			modify:   func(cfg *Config) { cfg.MetadataKeys = []string{"tenant"} },
+			expected: errUpdateNotSupported.Error(),
+		},
+		{
+			name:     "timeout to zero",
+			modify:   func(cfg *Config) { cfg.Timeout = 0 },
+			expected: errUpdateTimer.Error(),
+		},
+		{
+			name:     "invalid",
+			modify:   func(cfg *Config) { cfg.SendBatchMaxSize = 1 },
+			expected: "max_size (1) must be greater or equal to min_size (8192)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := *cfg
+			tt.modify(&updated)
+			assert.EqualError(t, b.UpdateConfig(&updated), tt.expected)
+		})
+	}
+	// The configuration is unchanged.
+	assert.Equal(t, newBatchSizes(cfg), b.p.sizes.Load())
+}
+
+// hintingConsumer is a next consumer preferring batches of items
+// items.
+type hintingConsumer struct {
+	items int
+}
+
+func (c *hintingConsumer) PreferredBatchSize() (int, int) {
+	return c.items, 1 << 20
+}
+
+func TestBatchProcessorBatchHint(t *testing.T) {
+	tests := []struct {
+		name             string
+		next             any
+		minSize, maxSize uint32
+		wantMin, wantMax int
+	}{
+		{name: "no hint", next: nil, minSize: defaultSendBatchSize, wantMin: 8192, wantMax: 0},
+		{name: "zero hint", next: &hintingConsumer{}, minSize: defaultSendBatchSize, wantMin: 8192, wantMax: 0},
+		{name: "defaults", next: &hintingConsumer{items: 100}, minSize: defaultSendBatchSize, wantMin: 100, wantMax: 100},
+		{name: "min_size set", next: &hintingConsumer{items: 100}, minSize: 50, wantMin: 50, wantMax: 100},
+		{name: "min_size above hint", next: &hintingConsumer{items: 100}, minSize: 500, wantMin: 500, wantMax: 500},
+		{name: "max_size set", next: &hintingConsumer{items: 100}, minSize: defaultSendBatchSize, maxSize: 10000, wantMin: 100, wantMax: 10000},
+		{name: "max_size below hint", next: &hintingConsumer{items: 10000}, minSize: defaultSendBatchSize, maxSize: 9000, wantMin: 9000, wantMax: 9000},
+		{name: "both set", next: &hintingConsumer{items: 100}, minSize: 10, maxSize: 20, wantMin: 10, wantMax: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewDefaultConfig()
+			cfg.SendBatchSize = tt.minSize
+			cfg.SendBatchMaxSize = tt.maxSize
+			require.NoError(t, cfg.Validate())
+			b, err := NewBatcher(processortest.NewNopCreateSettings(), cfg, (&intsSink{}).settings())
+			require.NoError(t, err)
+			b.p.settings.Next = tt.next
+			require.NoError(t, b.Start(context.Background(), componenttest.NewNopHost()))
+			defer func() { require.NoError(t, b.Shutdown(context.Background())) }()
+
+			// Explicit sizes are kept, the defaults adopt the hint.
+			sizes := b.p.sizes.Load()
+			assert.Equal(t, tt.wantMin, sizes.sendBatchSize)
+			assert.Equal(t, tt.wantMax, sizes.sendBatchMaxSize)
+		})
+	}
+}
@@ -0,0 +1,648 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching // import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component"
+)
+
+const (
+	// walSegmentExt is the extension of the segment files of live
+	// batchers.
+	walSegmentExt = ".wal"
+
+	// walReplayExt is the extension given to the segments of a
+	// previous run while they are being replayed.
+	walReplayExt = ".replay"
+
+	// walRecordHeaderSize is the size of the length and checksum
+	// preceding every record.
+	walRecordHeaderSize = 8
+
+	// walMaxRecordSize bounds the length read from a record header,
+	// so that a corrupted length is not used to allocate memory.
+	walMaxRecordSize = 1 << 30
+
+	// walReplayRetryInterval is the time waited before replaying
+	// again data rejected by a full batcher.
+	walReplayRetryInterval = 50 * time.Millisecond
+)
+
+// errWALCorrupted is returned when a record cannot be read back.
+var errWALCorrupted = errors.New("corrupted write-ahead log record")
+
+// walDirectory returns the directory holding the segments of a
+// processor, below the configured persistence directory.
+func walDirectory(dir string, id component.ID, dataType component.DataType) string {
+	return filepath.Join(dir, strings.ReplaceAll(id.String(), "/", "_"), string(dataType))
+}
+
+// walHeader is the first record of a segment, holding the client.Info
+// of its batcher, used to find the batcher again on replay.
+type walHeader struct {
+	Metadata map[string][]string `json:"metadata,omitempty"`
+	// Auth holds the auth attributes of the batcher, strings or
+	// slices of strings as in the attribute set identifying it.
+	Auth map[string]any `json:"auth,omitempty"`
+	// Source is the client.Info.Source of the batcher, set when it
+	// groups by source.
+	Source string `json:"source,omitempty"`
+}
+
+func newWALHeader(md map[string][]string, auth client.AuthData, source string) walHeader {
+	h := walHeader{Metadata: md, Source: source}
+	if auth != nil {
+		h.Auth = map[string]any{}
+		for _, name := range auth.GetAttributeNames() {
+			switch v := auth.GetAttribute(name).(type) {
+			case string, []string:
+				h.Auth[name] = v
+			default:
+				h.Auth[name] = fmt.Sprint(v)
+			}
+		}
+	}
+	return h
+}
+
+// info returns the client.Info of the batcher of the segment.
+func (h walHeader) info() client.Info {
+	info := client.Info{Metadata: client.NewMetadata(h.Metadata), Source: h.Source}
+	if len(h.Auth) == 0 {
+		return info
+	}
+	attrs := make(map[string]any, len(h.Auth))
+	for name, v := range h.Auth {
+		// Slices are decoded as []any.
+		if vs, ok := v.([]any); ok {
+			strs := make([]string, len(vs))
+			for i, v := range vs {
+				strs[i] = fmt.Sprint(v)
+			}
+			attrs[name] = strs
+			continue
+		}
+		attrs[name] = v
+	}
+	info.Auth = &batcherAuthData{attrs: attrs}
+	return info
+}
+
+// walSegment is the write-ahead log of a batcher.  Producers append
+// the items they enqueue, and the batcher compacts the segment once
+// the items have been exported.  The file starts with a record holding
+// the batcher's walHeader.  Records are synced to disk before the items
+// are accepted.  When an export fails, the batcher rolls over to a new
+// file, leaving the records of the failed items to be replayed on the
+// next start.
+type walSegment struct {
+	// mu is held by producers while appending an item and handing
+	// it to the batcher, so that items reach the batcher in the
+	// order of their records.
+	mu sync.Mutex
+
+	path    string
+	info    walHeader
+	marshal func(any) ([]byte, error)
+	// nextPath returns the path of the file the segment rolls over
+	// to.
+	nextPath func() string
+
+	f *os.File
+	// header is the size of the metadata record and size the
+	// current end of the file.
+	header int64
+	size   int64
+	// last is the size before the latest append, to undo it.
+	last int64
+	// removed is the size of the records removed by compaction.
+	// Offsets handed to the batcher include it, so that they remain
+	// valid once earlier records are removed.
+	removed int64
+	// pending is the end offset of a compaction given up because a
+	// producer held mu, applied when the producer releases it, and
+	// rollPending likewise a roll over.
+	pending     atomic.Int64
+	rollPending atomic.Bool
+}
+
+// newWALSegment returns the segment of the batcher identified by key,
+// whose files are numbered by seq.  The file is created on the first
+// append.
+func newWALSegment(dir string, key attribute.Set, seq *atomic.Uint64, info walHeader, marshal func(any) ([]byte, error)) *walSegment {
+	id := batcherID(key)
+	nextPath := func() string {
+		return filepath.Join(dir, fmt.Sprintf("%016x-%d%s", id, seq.Add(1), walSegmentExt))
+	}
+	return &walSegment{
+		path:     nextPath(),
+		info:     info,
+		marshal:  marshal,
+		nextPath: nextPath,
+	}
+}
+
+// appendItem writes item to the segment and returns the end offset of
+// its record, counting removed records.  The caller holds mu.
+func (s *walSegment) appendItem(item any) (int64, error) {
+	if s.f == nil {
+		if err := s.create(); err != nil {
+			return 0, err
+		}
+	}
+	data, err := s.marshal(item)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = s.f.Write(encodeWALRecord(data)); err != nil {
+		return 0, err
+	}
+	if err = s.f.Sync(); err != nil {
+		return 0, err
+	}
+	s.last = s.size
+	s.size += int64(walRecordHeaderSize + len(data))
+	return s.removed + s.size, nil
+}
+
+// undoAppend removes the latest record, whose item was not accepted by
+// the batcher.  The caller holds mu.
+func (s *walSegment) undoAppend() error {
+	if err := s.f.Truncate(s.last); err != nil {
+		return err
+	}
+	_, err := s.f.Seek(s.last, io.SeekStart)
+	s.size = s.last
+	return err
+}
+
+// create writes a new segment file holding the header record.
+func (s *walSegment) create() error {
+	hdr, err := json.Marshal(s.info)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	rec := encodeWALRecord(hdr)
+	if _, err = f.Write(rec); err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = syncDir(filepath.Dir(s.path))
+	}
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	s.f = f
+	s.header = int64(len(rec))
+	s.size = s.header
+	return nil
+}
+
+// compact removes the records up to offset end, as returned by
+// appendItem, whose items have been exported.  When a producer holds
+// mu, which may be waiting for the batcher, the records are removed
+// once the producer releases it.
+func (s *walSegment) compact(end int64) error {
+	s.pending.Store(end)
+	if !s.mu.TryLock() {
+		return nil
+	}
+	return s.unlock()
+}
+
+// roll leaves the current file, holding the records of items whose
+// export failed, to be replayed on the next start, and makes the
+// segment append to a new file.  Like compact, it is applied once a
+// producer holding mu releases it.
+func (s *walSegment) roll() error {
+	s.rollPending.Store(true)
+	if !s.mu.TryLock() {
+		return nil
+	}
+	return s.unlock()
+}
+
+// unlock applies the pending compaction, then releases mu.
+func (s *walSegment) unlock() error {
+	defer s.mu.Unlock()
+	return s.compactPending()
+}
+
+// compactPending applies the pending roll over or compaction.  The
+// caller holds mu.
+func (s *walSegment) compactPending() error {
+	if s.rollPending.Swap(false) {
+		return s.rollOver()
+	}
+	end := s.pending.Swap(0)
+	if end == 0 {
+		return nil
+	}
+	end -= s.removed
+	if s.f == nil || end <= s.header {
+		return nil
+	}
+	if end >= s.size {
+		if err := s.f.Truncate(s.header); err != nil {
+			return err
+		}
+		_, err := s.f.Seek(s.header, io.SeekStart)
+		s.removed += s.size - s.header
+		s.size = s.header
+		return err
+	}
+	// Rewrite the remaining records in a new file, replacing the
+	// segment only once complete.
+	tail := make([]byte, s.size-end)
+	if _, err := s.f.ReadAt(tail, end); err != nil {
+		return err
+	}
+	hdr, err := json.Marshal(s.info)
+	if err != nil {
+		return err
+	}
+	f, err := replaceWALFile(s.path, append(encodeWALRecord(hdr), tail...))
+	if err != nil {
+		return err
+	}
+	_ = s.f.Close()
+	s.f = f
+	s.removed += end - s.header
+	s.size = s.header + int64(len(tail))
+	return nil
+}
+
+// rollOver closes the current file and starts a new one.  Offsets
+// handed out before remain below those of the new file, so that
+// compacting up to them leaves the new file untouched.  The caller
+// holds mu.
+func (s *walSegment) rollOver() error {
+	// Compactions pending for the current file no longer apply.
+	s.pending.Store(0)
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	s.removed += s.size - s.header
+	s.size, s.last = s.header, s.header
+	s.path = s.nextPath()
+	return err
+}
+
+// close closes the segment file, leaving it to be replayed.
+func (s *walSegment) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.compactPending()
+	if s.f == nil {
+		return err
+	}
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	s.f = nil
+	return err
+}
+
+// remove closes and deletes the segment of a batcher whose items have
+// all been exported.
+func (s *walSegment) remove() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// A pending roll over keeps the file of a failed export.
+	if err := s.compactPending(); err != nil || s.f == nil {
+		return err
+	}
+	_ = s.f.Close()
+	s.f = nil
+	return os.Remove(s.path)
+}
+
+// replaceWALFile replaces the file at path with one holding data,
+// written to a temporary file synced to disk before it is renamed, and
+// returns it open at its end.
+func replaceWALFile(path string, data []byte) (*os.File, error) {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err == nil {
+		err = syncDir(filepath.Dir(path))
+	}
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return nil, err
+	}
+	return f, nil
+}
+
+// syncDir syncs the entries of dir to disk, for the files created or
+// renamed in it to persist.
+func syncDir(dir string) error {
+	d, err := os.Open(filepath.Clean(dir))
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func encodeWALRecord(data []byte) []byte {
+	rec := make([]byte, walRecordHeaderSize+len(data))
+	binary.LittleEndian.PutUint32(rec, uint32(len(data)))
+	binary.LittleEndian.PutUint32(rec[4:], crc32.ChecksumIEEE(data))
+	copy(rec[walRecordHeaderSize:], data)
+	return rec
+}
+
+// readWALRecord returns the next record of r, io.EOF at the end of the
+// segment, or errWALCorrupted when the record is truncated or its
+// checksum does not match.
+func readWALRecord(r *bufio.Reader) ([]byte, error) {
+	var hdr [walRecordHeaderSize]byte
+	if n, err := io.ReadFull(r, hdr[:]); err != nil {
+		if n == 0 && errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, errWALCorrupted
+	}
+	size := binary.LittleEndian.Uint32(hdr[:])
+	if size > walMaxRecordSize {
+		return nil, errWALCorrupted
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, errWALCorrupted
+	}
+	if crc32.ChecksumIEEE(data) != binary.LittleEndian.Uint32(hdr[4:]) {
+		return nil, errWALCorrupted
+	}
+	return data, nil
+}
+
+// readWALSegment returns the header and the records of a segment.
+// Reading stops at the first corrupted record, returning the records
+// before it along with errWALCorrupted.
+func readWALSegment(path string) (walHeader, [][]byte, error) {
+	var h walHeader
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return h, nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	hdr, err := readWALRecord(r)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			// The file was created but its header not written.
+			return h, nil, nil
+		}
+		return h, nil, err
+	}
+	if err = json.Unmarshal(hdr, &h); err != nil {
+		return h, nil, errWALCorrupted
+	}
+	var records [][]byte
+	for {
+		rec, err := readWALRecord(r)
+		if errors.Is(err, io.EOF) {
+			return h, records, nil
+		}
+		if err != nil {
+			return h, records, err
+		}
+		records = append(records, rec)
+	}
+}
+
+// pendingWALSegments renames the segments left by a previous run, so
+// that they are not overwritten by the batchers they are replayed into,
+// and returns them along with those of an interrupted replay.
+func pendingWALSegments(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	suffix := fmt.Sprintf(".%d%s", time.Now().UnixNano(), walReplayExt)
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		switch filepath.Ext(e.Name()) {
+		case walReplayExt:
+			paths = append(paths, path)
+		case walSegmentExt:
+			replay := path + suffix
+			if err := os.Rename(path, replay); err != nil {
+				return nil, err
+			}
+			paths = append(paths, replay)
+		}
+	}
+	if len(paths) != 0 {
+		if err := syncDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// replayWAL enqueues the data of the segments left by a previous run
+// into the batchers of their client.Info, then removes the segments.
+// The data is written to the segments of the new batchers, so that it
+// is not lost if the processor stops again before exporting it.  The
+// records that could not be replayed, e.g. for exceeding the metadata
+// cardinality limit, are kept in their segment to be replayed on the
+// next start.
+func (bp *Processor) replayWAL(ctx context.Context) error {
+	paths, err := pendingWALSegments(bp.walDir)
+	if err != nil {
+		return fmt.Errorf("failed to list the write-ahead log segments: %w", err)
+	}
+	for _, path := range paths {
+		h, records, err := readWALSegment(path)
+		switch {
+		case errors.Is(err, errWALCorrupted):
+			bp.logger.Warn("Skipping corrupted write-ahead log records",
+				zap.String("path", path),
+				zap.Int("replayed_records", len(records)))
+		case err != nil:
+			return fmt.Errorf("failed to read the write-ahead log: %w", err)
+		}
+		replayCtx := client.NewContext(ctx, h.info())
+		var kept [][]byte
+		for i, rec := range records {
+			item, err := bp.settings.Unmarshal(rec)
+			if err != nil {
+				bp.logger.Warn("Skipping undecodable write-ahead log record", zap.String("path", path), zap.Error(err))
+				continue
+			}
+			rejected, err := bp.replayRecord(replayCtx, item)
+			if err == nil {
+				continue
+			}
+			if data, merr := bp.settings.Marshal(rejected); merr == nil {
+				rec = data
+			}
+			kept = append(kept, rec)
+			if ctx.Err() != nil {
+				// Keep the records not replayed yet too.
+				kept = append(kept, records[i+1:]...)
+				if kerr := keepWALRecords(path, h, kept); kerr != nil {
+					return fmt.Errorf("failed to keep the write-ahead log records not replayed: %w", kerr)
+				}
+				return ctx.Err()
+			}
+			bp.logger.Warn("Failed to replay write-ahead log record, keeping it", zap.String("path", path), zap.Error(err))
+		}
+		if len(kept) != 0 {
+			if err := keepWALRecords(path, h, kept); err != nil {
+				return fmt.Errorf("failed to keep the write-ahead log records not replayed: %w", err)
+			}
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove the replayed write-ahead log: %w", err)
+		}
+	}
+	return nil
+}
+
+// replayRecord replays item, waiting for a full batcher to accept it
+// rather than rejecting it as with on_full: error.  It returns the data
+// not accepted along with the error rejecting it, item itself unless
+// the batcher was full.
+func (bp *Processor) replayRecord(ctx context.Context, item any) (any, error) {
+	for {
+		err := bp.replayItem(ctx, item)
+		if !errors.Is(err, ErrBatcherFull) {
+			return item, err
+		}
+		item, _ = bp.returnedData(item, err)
+		select {
+		case <-ctx.Done():
+			return item, ctx.Err()
+		case <-time.After(walReplayRetryInterval):
+		}
+	}
+}
+
+// replayItem enqueues replayed data like the Consume functions, but
+// without waiting for its export or applying the in-flight budget,
+// which it is still counted against.
+func (bp *Processor) replayItem(ctx context.Context, item any) error {
+	n := bp.settings.Count(item)
+	if bp.maxInFlight != 0 {
+		bp.inFlight.Add(int64(n))
+	}
+	if len(bp.resourceKeys) != 0 {
+		// The data is copied, for a rejected record to be kept
+		// whole.
+		parts := bp.settings.Partition(bp.resourceKeys, item, n, true)
+		_, err := bp.consumePartitions(ctx, parts, false)
+		return err
+	}
+	b, err := bp.findBatcher(ctx, nil)
+	if err != nil {
+		bp.releaseInFlight(n)
+		return err
+	}
+	_, err = bp.consumeItem(ctx, b, item, n, false)
+	return err
+}
+
+// keepWALRecords rewrites the segment at path with only records, to be
+// replayed again on the next start.
+func keepWALRecords(path string, h walHeader, records [][]byte) error {
+	hdr, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	data := encodeWALRecord(hdr)
+	for _, rec := range records {
+		data = append(data, encodeWALRecord(rec)...)
+	}
+	f, err := replaceWALFile(path, data)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// addWALEnd records that the item whose record ends at end was added
+// to the pending batch.
+func (b *batcher) addWALEnd(end int64) {
+	if end > b.walEnd {
+		b.walEnd = end
+	}
+}
+
+// compactWAL removes the records of the items of the pending batch,
+// once the batch has been sent.  The records of a failed export were
+// left behind by rollWAL, and are not removed.
+func (b *batcher) compactWAL() {
+	if b.wal == nil || b.walEnd == 0 {
+		return
+	}
+	if err := b.wal.compact(b.walEnd); err != nil {
+		b.processor.logger.Warn("Failed to compact the write-ahead log", zap.Error(err))
+	}
+}
+
+// rollWAL keeps the records of the items whose export failed, to be
+// replayed on the next start.
+func (b *batcher) rollWAL() {
+	if b.wal == nil {
+		return
+	}
+	if err := b.wal.roll(); err != nil {
+		b.processor.logger.Warn("Failed to roll over the write-ahead log", zap.Error(err))
+	}
+}
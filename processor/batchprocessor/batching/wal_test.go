@@ -0,0 +1,296 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/processor"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+// intsDataType is the data type of the processors of ints.
+const intsDataType component.DataType = "ints"
+
+func marshalInts(item any) ([]byte, error) {
+	return json.Marshal(item)
+}
+
+func unmarshalInts(buf []byte) (any, error) {
+	var req []int
+	if err := json.Unmarshal(buf, &req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// newPersistentProcessor returns a processor of ints exporting to sink,
+// which logs its pending data as the batch processor does with
+// persistence.
+func newPersistentProcessor(t *testing.T, set processor.CreateSettings, cfg *Config, sink *intsSink) *Processor {
+	bs := sink.settings()
+	p, err := NewProcessor(set, cfg, Settings{
+		DataType: intsDataType,
+		NewBatch: func(bool) Batch {
+			return &genericBatch[[]int]{settings: bs, data: bs.New()}
+		},
+		Count:     func(item any) int { return len(item.([]int)) },
+		Marshal:   marshalInts,
+		Unmarshal: unmarshalInts,
+	})
+	require.NoError(t, err)
+	return p
+}
+
+func persistentConfig(dir string) *Config {
+	cfg := NewDefaultConfig()
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"tenant"}
+	cfg.Persistence.Directory = dir
+	return cfg
+}
+
+// walSegments returns the segment files of the processors of ints
+// created with processortest.NewNopCreateSettings.
+func walSegments(t *testing.T, dir string) []string {
+	paths, err := filepath.Glob(filepath.Join(walDirectory(dir, processortest.NewNopCreateSettings().ID, intsDataType), "*"+walSegmentExt))
+	require.NoError(t, err)
+	return paths
+}
+
+// consumeTenantInts sends n items for each tenant.
+func consumeTenantInts(t *testing.T, p *Processor, tenants []string, n int) {
+	for _, tenant := range tenants {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"tenant": {tenant}}),
+		})
+		require.NoError(t, p.Consume(ctx, make([]int, n), n))
+	}
+}
+
+// itemCount returns the number of items exported to s.
+func (s *intsSink) itemCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, b := range s.batches {
+		n += len(b)
+	}
+	return n
+}
+
+func TestBatchProcessorPersistenceReplayRemoved(t *testing.T) {
+	dir := t.TempDir()
+	cfg := persistentConfig(dir)
+	// Stopping without flushing leaves the pending data on disk, as
+	// when the process is killed.
+	flushOnShutdown := false
+	cfg.FlushOnShutdown = &flushOnShutdown
+
+	first := newPersistentProcessor(t, processortest.NewNopCreateSettings(), cfg, &intsSink{})
+	require.NoError(t, first.Start(context.Background(), componenttest.NewNopHost()))
+	consumeTenantInts(t, first, []string{"a", "b"}, 3)
+	require.NoError(t, first.Shutdown(context.Background()))
+	require.Len(t, walSegments(t, dir), 2)
+
+	sink := &intsSink{}
+	cfg.FlushOnShutdown = nil
+	second := newPersistentProcessor(t, processortest.NewNopCreateSettings(), cfg, sink)
+	require.NoError(t, second.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, second.Shutdown(context.Background()))
+	assert.Equal(t, 6, sink.itemCount())
+
+	// The exported data is removed from the log.
+	for _, path := range walSegments(t, dir) {
+		_, records, err := readWALSegment(path)
+		require.NoError(t, err)
+		assert.Empty(t, records)
+	}
+}
+
+func TestBatchProcessorPersistenceCompaction(t *testing.T) {
+	dir := t.TempDir()
+	cfg := persistentConfig(dir)
+	cfg.SendBatchSize = 4
+	sink := &intsSink{}
+	p := newPersistentProcessor(t, processortest.NewNopCreateSettings(), cfg, sink)
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+
+	consumeTenantInts(t, p, []string{"a", "a"}, 2)
+	require.Eventually(t, func() bool {
+		return sink.itemCount() == 4
+	}, time.Second, 5*time.Millisecond)
+
+	// Pending data remains in the log until exported.
+	consumeTenantInts(t, p, []string{"a"}, 1)
+	paths := walSegments(t, dir)
+	require.Len(t, paths, 1)
+	require.Eventually(t, func() bool {
+		_, records, err := readWALSegment(paths[0])
+		return err == nil && len(records) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, p.Shutdown(context.Background()))
+	_, records, err := readWALSegment(paths[0])
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestBatchProcessorPersistenceCorruptedTail(t *testing.T) {
+	dir := t.TempDir()
+	cfg := persistentConfig(dir)
+	flushOnShutdown := false
+	cfg.FlushOnShutdown = &flushOnShutdown
+
+	first := newPersistentProcessor(t, processortest.NewNopCreateSettings(), cfg, &intsSink{})
+	require.NoError(t, first.Start(context.Background(), componenttest.NewNopHost()))
+	consumeTenantInts(t, first, []string{"a", "a"}, 2)
+	require.NoError(t, first.Shutdown(context.Background()))
+
+	// Simulate a record partially written when the process stopped.
+	paths := walSegments(t, dir)
+	require.Len(t, paths, 1)
+	f, err := os.OpenFile(paths[0], os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = f.Write(encodeWALRecord([]byte("partial record"))[:10])
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	core, logs := observer.New(zap.WarnLevel)
+	set := processortest.NewNopCreateSettings()
+	set.Logger = zap.New(core)
+	sink := &intsSink{}
+	cfg.FlushOnShutdown = nil
+	second := newPersistentProcessor(t, set, cfg, sink)
+	require.NoError(t, second.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, second.Shutdown(context.Background()))
+
+	assert.Equal(t, 4, sink.itemCount())
+	assert.Equal(t, 1, logs.FilterMessage("Skipping corrupted write-ahead log records").Len())
+}
+
+func TestWALSegmentUndoAppend(t *testing.T) {
+	var seq atomic.Uint64
+	s := newWALSegment(t.TempDir(), attribute.NewSet(attribute.String("tenant", "a")), &seq, walHeader{Metadata: map[string][]string{"tenant": {"a"}}}, marshalInts)
+
+	_, err := s.appendItem([]int{1})
+	require.NoError(t, err)
+	_, err = s.appendItem([]int{2, 3})
+	require.NoError(t, err)
+	require.NoError(t, s.undoAppend())
+	require.NoError(t, s.close())
+
+	h, records, err := readWALSegment(s.path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"tenant": {"a"}}, h.Metadata)
+	require.Len(t, records, 1)
+	item, err := unmarshalInts(records[0])
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, item)
+}
+
+func TestWALSegmentCompact(t *testing.T) {
+	var seq atomic.Uint64
+	s := newWALSegment(t.TempDir(), attribute.NewSet(), &seq, walHeader{}, marshalInts)
+
+	var ends []int64
+	for i := 1; i <= 3; i++ {
+		end, err := s.appendItem(make([]int, i))
+		require.NoError(t, err)
+		ends = append(ends, end)
+	}
+	require.NoError(t, s.compact(ends[0]))
+
+	// Offsets returned before a compaction remain valid after it.
+	end, err := s.appendItem([]int{4, 4, 4, 4})
+	require.NoError(t, err)
+	ends = append(ends, end)
+	require.NoError(t, s.compact(ends[2]))
+	require.NoError(t, s.close())
+
+	_, records, err := readWALSegment(s.path)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	item, err := unmarshalInts(records[0])
+	require.NoError(t, err)
+	assert.Equal(t, []int{4, 4, 4, 4}, item)
+}
+
+func TestWALSegmentRoll(t *testing.T) {
+	var seq atomic.Uint64
+	s := newWALSegment(t.TempDir(), attribute.NewSet(), &seq, walHeader{}, marshalInts)
+
+	var ends []int64
+	for i := 1; i <= 2; i++ {
+		end, err := s.appendItem(make([]int, i))
+		require.NoError(t, err)
+		ends = append(ends, end)
+	}
+	failed := s.path
+	require.NoError(t, s.roll())
+	assert.NotEqual(t, failed, s.path)
+
+	// Compacting up to the records of the rolled file leaves both
+	// files untouched.
+	end, err := s.appendItem([]int{3, 3, 3})
+	require.NoError(t, err)
+	require.Greater(t, end, ends[1])
+	require.NoError(t, s.compact(ends[1]))
+	require.NoError(t, s.close())
+
+	_, records, err := readWALSegment(failed)
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+	_, records, err = readWALSegment(s.path)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	item, err := unmarshalInts(records[0])
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 3, 3}, item)
+}
+
+func TestWALHeaderInfo(t *testing.T) {
+	auth := &batcherAuthData{attrs: map[string]any{"subject": "a", "groups": []string{"x", "y"}, "level": 3}}
+	h := newWALHeader(map[string][]string{"tenant": {"a"}}, auth, "otlp/in")
+	data, err := json.Marshal(h)
+	require.NoError(t, err)
+	var decoded walHeader
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	// The replayed data finds the batcher of its client.Info.
+	info := decoded.info()
+	assert.Equal(t, []string{"a"}, info.Metadata.Get("tenant"))
+	assert.Equal(t, "otlp/in", info.Source)
+	keys := []string{"subject", "groups", "level", "missing"}
+	expected, _ := authKeyValues(keys, auth)
+	got, _ := authKeyValues(keys, info.Auth)
+	assert.Equal(t, expected, got)
+}
@@ -0,0 +1,568 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batching // import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/client"
+)
+
+// batcher is a single instance of the batcher logic.  When metadata
+// keys are in use, one of these is created per distinct combination
+// of values.
+type batcher struct {
+	// processor refers to this processor, for access to common
+	// configuration.
+	processor *Processor
+
+	// exportCtx is a context with the metadata key-values
+	// corresponding with this batcher set.
+	exportCtx context.Context
+
+	// propagated merges the incoming client information of the
+	// pending batch, nil unless some of it is propagated.
+	propagated *propagatedInfo
+
+	// timer informs the batcher send a batch.
+	timer *time.Timer
+
+	// sizes are the processor's sizes last applied by updateSizes,
+	// and override the override matching the batcher, if any.
+	// timeout, sendBatchSize, and sendBatchMaxSize are the sizes
+	// with the override applied.
+	sizes            *batchSizes
+	override         *BatchOverride
+	timeout          time.Duration
+	sendBatchSize    int
+	sendBatchMaxSize int
+
+	// newItem is used to receive data items from producers.
+	newItem chan incomingItem
+
+	// batch is an in-flight data item containing one of the
+	// underlying data types.
+	batch Batch
+
+	// resourceSet, with FlushOnResourceChange, identifies the set of
+	// resources of the data in the batch.
+	resourceSet uint64
+
+	// wal is the write-ahead log of the batcher, nil when persistence
+	// is off, and walEnd the end of the latest record whose item was
+	// added to the pending batch.
+	wal    *walSegment
+	walEnd int64
+
+	// waiters are notified of the export result of the pending batch
+	// once it has been completely sent, with waitErr, the errors since
+	// the previous notification combined.  Used when ErrorMode is
+	// "propagate".
+	waiters []chan<- error
+	waitErr error
+
+	// links are the spans of the producers of the data of the
+	// pending batch, linked from the spans of its exports.
+	links []trace.Link
+
+	// firstAdd is the time the oldest data of the pending batch was
+	// added, zero when the batch is empty.
+	firstAdd time.Time
+
+	// otherValues is true when this batcher groups metadata values
+	// that are not allowed by MetadataKeySettings.
+	otherValues bool
+
+	// overflow is true for the batcher that groups combinations
+	// beyond the cardinality limit.
+	overflow bool
+
+	// key is the attribute set identifying this batcher in the
+	// multiBatcher, and lruElem its position in the LRU list.
+	key     attribute.Set
+	lruElem *list.Element
+
+	// attrs are the attributes of the metrics recorded for this
+	// batcher.
+	attrs *telemetryAttrs
+
+	// id is the BatcherID of the batcher and sequence the counter
+	// numbering its exports.
+	id       uint64
+	sequence *atomic.Uint64
+
+	// after, with ordering "per_batcher", is closed when the batcher
+	// previously created for the same values has exited, and exited
+	// is closed when this one exits.
+	after  <-chan struct{}
+	exited chan struct{}
+
+	// requeues is the number of consecutive exports whose failed
+	// data was put back in the batch, bounded by maxRequeues.
+	requeues int
+
+	// failures is the number of consecutive failed exports, for
+	// status reporting.
+	failures int
+
+	// lastFailureLog is the time failed exports were last logged,
+	// and suppressedFailures the number not logged since then.
+	lastFailureLog     time.Time
+	suppressedFailures int
+
+	// limitedValues are the values of keys with a distinct value
+	// limit that identify this batcher, released on removal.
+	limitedValues []limitedValue
+
+	// stopC is closed once the batcher has been removed from the
+	// multiBatcher and no more items will be enqueued to it.
+	stopC chan struct{}
+
+	// stopLock guards stopped.  Producers hold the read lock while
+	// enqueuing, so that once stopped is set under the write lock
+	// every accepted item is already in newItem.
+	stopLock sync.RWMutex
+	stopped  bool
+
+	// removed is set once the batcher is removed from the
+	// multiBatcher, so that it is not counted again on shutdown.
+	removed atomic.Bool
+
+	// syncLock guards the batch state when producers process their
+	// items themselves with sync_consume, against the batcher
+	// goroutine flushing on timeout.  lastItem is the time an item
+	// was last processed by a producer.
+	syncLock sync.Mutex
+	lastItem time.Time
+
+	// stateLock guards state, the copy of the batch state read by
+	// snapshots.
+	stateLock sync.Mutex
+	state     batcherState
+}
+
+func (b *batcher) start() {
+	defer b.processor.goroutines.Done()
+	defer b.processor.activeBatchers.Add(-1)
+	if b.exited != nil {
+		defer b.processor.turns.done(b.id, b.exited)
+	}
+	// A removed batcher is no longer failing.
+	defer b.recovered()
+	if b.wal != nil {
+		// Data not exported on shutdown is replayed on the next start.
+		defer b.wal.close()
+	}
+
+	// timerCh ensures we only block when there is a
+	// timer, since <- from a nil channel is blocking.
+	var timerCh <-chan time.Time
+	if b.hasTimer() {
+		timerCh = b.timer.C
+	}
+	var idleTimer *time.Timer
+	var idleCh <-chan time.Time
+	if b.processor.idleTimeout > 0 {
+		idleTimer = time.NewTimer(b.processor.idleTimeout)
+		idleCh = idleTimer.C
+		defer idleTimer.Stop()
+	}
+	for {
+		select {
+		case <-b.processor.shutdownC:
+			b.syncLock.Lock()
+			defer b.syncLock.Unlock()
+			b.shutdown()
+			return
+		case <-b.stopC:
+			b.syncLock.Lock()
+			defer b.syncLock.Unlock()
+			b.drainAndStop()
+			return
+		case item := <-b.newItem:
+			if item.data == nil {
+				continue
+			}
+			b.syncLock.Lock()
+			b.processItem(item)
+			b.syncLock.Unlock()
+			if idleCh != nil {
+				if !idleTimer.Stop() {
+					<-idleTimer.C
+				}
+				idleTimer.Reset(b.processor.idleTimeout)
+			}
+		case <-idleCh:
+			if b.processor.syncConsume {
+				// Items processed by producers do not reset the
+				// idle timer.
+				b.syncLock.Lock()
+				idle := time.Since(b.lastItem)
+				b.syncLock.Unlock()
+				if idle < b.processor.idleTimeout {
+					idleTimer.Reset(b.processor.idleTimeout - idle)
+					continue
+				}
+			}
+			if b.processor.expireBatcher(b) {
+				// Keep receiving until stop() closes stopC.
+				idleCh = nil
+			} else {
+				idleTimer.Reset(b.processor.idleTimeout)
+			}
+		case <-timerCh:
+			b.syncLock.Lock()
+			b.updateSizes()
+			if b.batch.ItemCount() > 0 {
+				b.sendItems(triggerTimeout)
+			}
+			b.resetTimer()
+			b.syncLock.Unlock()
+		}
+	}
+}
+
+// tryEnqueue hands an item of n spans, data points, or log records to
+// the batcher, returning errBatcherStopped if the batcher has been
+// stopped, or ErrBatcherFull if it cannot accept the item and the
+// OnFull policy is "error".  info is the client information of the
+// incoming request, used when it is propagated, and done, when not
+// nil, is notified with its export result.  link is the span of the
+// producer of the item.
+func (b *batcher) tryEnqueue(item any, n int, info client.Info, link trace.SpanContext, done chan<- error) error {
+	b.stopLock.RLock()
+	defer b.stopLock.RUnlock()
+	if b.stopped {
+		return errBatcherStopped
+	}
+	in := incomingItem{data: item, items: n, link: link}
+	if b.propagated != nil {
+		in = b.propagated.incoming(in, info)
+	}
+	in.done = done
+	if b.wal != nil {
+		// Hold the lock until the item is handed to the batcher, so
+		// that items arrive in the order of their records.
+		b.wal.mu.Lock()
+		defer func() {
+			if err := b.wal.unlock(); err != nil {
+				b.processor.logger.Warn("Failed to compact the write-ahead log", zap.Error(err))
+			}
+		}()
+		end, err := b.wal.appendItem(item)
+		if err != nil {
+			return fmt.Errorf("failed to persist data: %w", err)
+		}
+		in.walEnd = end
+	}
+	switch {
+	case b.processor.syncConsume:
+		b.syncLock.Lock()
+		b.lastItem = time.Now()
+		b.processItem(in)
+		b.syncLock.Unlock()
+	case b.processor.onFull == onFullError:
+		select {
+		case b.newItem <- in:
+		default:
+			if b.wal != nil {
+				if err := b.wal.undoAppend(); err != nil {
+					b.processor.logger.Warn("Failed to remove rejected data from the write-ahead log", zap.Error(err))
+				}
+			}
+			return ErrBatcherFull
+		}
+	case b.processor.onFull == onFullDropOldest:
+		b.sendDroppingOldest(in)
+	default:
+		b.newItem <- in
+	}
+	if b.otherValues {
+		b.processor.telemetry.recordOtherValuesItems(int64(n))
+	}
+	if b.overflow {
+		b.processor.telemetry.recordOverflowItems(int64(n))
+	}
+	return nil
+}
+
+// sendDroppingOldest sends item to the batcher, discarding the oldest
+// pending requests until there is room for it.  Concurrent producers
+// and the batcher goroutine may take from the channel in between, in
+// which case the loop simply retries.
+func (b *batcher) sendDroppingOldest(in incomingItem) {
+	for {
+		select {
+		case b.newItem <- in:
+			return
+		default:
+		}
+		select {
+		case old := <-b.newItem:
+			if old.done != nil {
+				old.done <- ErrDropped
+			}
+			dropped, bytes := old.items, 0
+			if b.processor.telemetry.enabled.droppedBytes {
+				bytes = b.processor.sizeItems(old.data)
+			}
+			b.processor.dropLogger.Warn("Batcher queue is full, dropping the oldest pending request",
+				zap.String("data_type", string(b.processor.dataType)),
+				zap.Int("dropped_items", dropped))
+			b.processor.telemetry.recordDropped(b.attrs, int64(dropped), int64(bytes), reasonQueueOverflow)
+			b.processor.releaseInFlight(dropped)
+		default:
+		}
+	}
+}
+
+func (b *batcher) processItem(in incomingItem) {
+	b.updateSizes()
+	if bypass := b.processor.settings.Bypass; bypass != nil && bypass(in.data) {
+		b.processBypassItem(in)
+		return
+	}
+
+	if b.processor.flushOnResourceChange && in.items > 0 {
+		set := b.processor.settings.ResourceSet(in.data)
+		if b.batch.ItemCount() > 0 && set != b.resourceSet {
+			for b.batch.ItemCount() > 0 {
+				b.sendItems(triggerResourceChange)
+			}
+			b.stopTimer()
+			b.resetTimer()
+		}
+		b.resourceSet = set
+	}
+
+	if maxBytes := b.processor.sendBatchMaxSizeBytes; maxBytes > 0 && b.batch.ItemCount() > 0 &&
+		b.batch.ByteSize()+b.processor.sizeItems(in.data) > maxBytes {
+		// Send the pending batch first, for the request not to take
+		// it past max_size_bytes.
+		for b.batch.ItemCount() > 0 {
+			b.sendItems(triggerBatchSize)
+		}
+		b.stopTimer()
+		b.resetTimer()
+	}
+
+	if b.propagated != nil {
+		b.propagated.merge(in.info)
+	}
+	b.addWaiter(in.done)
+	b.addWALEnd(in.walEnd)
+	b.addLink(in.link)
+	b.addToBatch(in.data, in.items)
+	if b.batch.ItemCount() == 0 {
+		// Nothing to wait for, e.g. an empty request.
+		b.notifyWaiters(nil)
+		b.compactWAL()
+		b.links = nil
+	}
+	sent := false
+	for b.batch.ItemCount() > 0 && (!b.hasTimer() || b.batch.ItemCount() >= b.sendBatchSize || b.reachedSizeBytes()) {
+		sent = true
+		b.sendItems(triggerBatchSize)
+	}
+
+	if sent {
+		b.stopTimer()
+		b.resetTimer()
+	}
+	b.publishState()
+}
+
+// processBypassItem sends an item matching a bypass rule without
+// waiting for the size or timeout triggers.
+func (b *batcher) processBypassItem(in incomingItem) {
+	if b.processor.bypassForward {
+		// Send the item in a request of its own, leaving the
+		// pending batch, its waiters, and its timer untouched.
+		// The write-ahead log is compacted along with the pending
+		// batch, whose records precede that of the item.
+		pending, pendingWaiters, pendingErr, pendingWALEnd, pendingLinks, pendingFirstAdd := b.batch, b.waiters, b.waitErr, b.walEnd, b.links, b.firstAdd
+		b.waiters, b.waitErr, b.walEnd, b.links, b.firstAdd = nil, nil, 0, nil, time.Time{}
+		b.addWaiter(in.done)
+		b.addLink(in.link)
+		var pendingInfo propagatedInfo
+		if b.propagated != nil {
+			pendingInfo = *b.propagated
+			b.propagated.reset()
+			b.propagated.merge(in.info)
+		}
+		b.batch = b.processor.settings.NewBatch(b.processor.trackBytes)
+		b.addToBatch(in.data, in.items)
+		for b.batch.ItemCount() > 0 {
+			b.sendItems(triggerBypass)
+		}
+		b.batch, b.waiters, b.waitErr, b.walEnd, b.links, b.firstAdd = pending, pendingWaiters, pendingErr, pendingWALEnd, pendingLinks, pendingFirstAdd
+		b.addWALEnd(in.walEnd)
+		if b.propagated != nil {
+			*b.propagated = pendingInfo
+		}
+		b.publishState()
+		return
+	}
+
+	if b.propagated != nil {
+		b.propagated.merge(in.info)
+	}
+	b.addWaiter(in.done)
+	b.addWALEnd(in.walEnd)
+	b.addLink(in.link)
+	b.addToBatch(in.data, in.items)
+	for b.batch.ItemCount() > 0 {
+		b.sendItems(triggerBypass)
+	}
+	b.stopTimer()
+	b.resetTimer()
+	b.publishState()
+}
+
+// addToBatch adds n items of data to the pending batch, recording the
+// time of the first ones added to an empty batch.
+func (b *batcher) addToBatch(data any, n int) {
+	if n > 0 && b.batch.ItemCount() == 0 {
+		b.firstAdd = b.processor.now()
+	}
+	b.batch.Add(data, n)
+}
+
+// reachedSizeBytes returns whether the batch reached min_size_bytes.
+func (b *batcher) reachedSizeBytes() bool {
+	return b.processor.sendBatchSizeBytes > 0 && b.batch.ByteSize() >= b.processor.sendBatchSizeBytes
+}
+
+func (b *batcher) hasTimer() bool {
+	return b.timer != nil
+}
+
+func (b *batcher) stopTimer() {
+	if b.hasTimer() && !b.timer.Stop() {
+		// With sync_consume, the batcher goroutine may have
+		// received the expiry already and be waiting for syncLock.
+		select {
+		case <-b.timer.C:
+		default:
+		}
+	}
+}
+
+func (b *batcher) resetTimer() {
+	if b.hasTimer() {
+		b.timer.Reset(b.timeout)
+	}
+}
+
+func (b *batcher) sendItems(trigger trigger) {
+	if b.after != nil {
+		// Wait for the removed batcher for the same values to
+		// export its data first.
+		<-b.after
+		b.after = nil
+	}
+	exportCtx := b.exportCtx
+	if b.propagated != nil {
+		exportCtx = b.propagated.context(exportCtx)
+		defer func() {
+			if b.batch.ItemCount() == 0 {
+				b.propagated.reset()
+			}
+		}()
+	}
+	exportCtx = contextWithBatchInfo(exportCtx, BatchInfo{
+		BatcherID: b.id,
+		Sequence:  b.sequence.Add(1),
+		Trigger:   trigger.batchTrigger(),
+	})
+	exportCtx, span := b.startSpan(exportCtx, trigger)
+	var start time.Time
+	if b.processor.telemetry.latency {
+		start = time.Now()
+		if !b.firstAdd.IsZero() {
+			b.processor.telemetry.recordBatchAge(exportCtx, b.attrs, trigger, b.processor.now().Sub(b.firstAdd))
+		}
+	}
+	split := b.sendBatchMaxSize > 0 && b.batch.ItemCount() > b.sendBatchMaxSize
+	req, sent, bytes, err := b.batch.Export(exportCtx, b.sendBatchMaxSize)
+	if split {
+		b.processor.telemetry.recordSplit(b.attrs, int64(b.batch.ItemCount()))
+	}
+	if b.processor.telemetry.latency {
+		b.processor.telemetry.recordSendLatency(exportCtx, b.attrs, trigger, time.Since(start), err != nil)
+	}
+	if err != nil && b.processor.retry.Enabled {
+		err = b.retrySend(exportCtx, req, err)
+	}
+	if b.sendBatchMaxSize > 0 && sent > b.sendBatchMaxSize {
+		switch {
+		case b.processor.splitAtResource:
+			b.processor.logger.Warn("Sent a resource larger than max_size in a request of its own",
+				zap.String("data_type", string(b.processor.dataType)),
+				zap.Int("items", sent),
+				zap.Int("max_size", b.sendBatchMaxSize))
+		case b.processor.splitAtScope:
+			b.processor.logger.Warn("Sent a scope larger than max_size in a request of its own",
+				zap.String("data_type", string(b.processor.dataType)),
+				zap.Int("items", sent),
+				zap.Int("max_size", b.sendBatchMaxSize))
+		}
+	}
+	b.exportResult(err)
+	// The measurements are recorded before the span ends, for
+	// exemplars to refer to it.
+	if err == nil {
+		b.requeues = 0
+		b.logSendRecovered()
+		b.processor.releaseInFlight(sent)
+		b.processor.telemetry.record(exportCtx, b.attrs, trigger, int64(sent), int64(bytes))
+	} else {
+		b.exportFailed(exportCtx, trigger, req, sent, bytes, err)
+	}
+	endSpan(span, sent, bytes, err)
+	if b.batch.ItemCount() == 0 {
+		b.notifyWaiters(b.waitErr)
+		b.compactWAL()
+		b.links = nil
+		b.firstAdd = time.Time{}
+	}
+	b.publishExport(err)
+}
+
+// addWaiter registers done to be notified with the export result of
+// the pending batch.
+func (b *batcher) addWaiter(done chan<- error) {
+	if done != nil {
+		b.waiters = append(b.waiters, done)
+	}
+}
+
+// notifyWaiters sends err to the producers waiting for the pending
+// batch.  Their channels are buffered, so this does not block.
+func (b *batcher) notifyWaiters(err error) {
+	for _, done := range b.waiters {
+		done <- err
+	}
+	b.waiters, b.waitErr = nil, nil
+}
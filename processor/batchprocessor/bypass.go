@@ -0,0 +1,117 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor // import "go.opentelemetry.io/collector/processor/batchprocessor"
+
+import (
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// bypassMatcher evaluates the configured bypass rules against
+// incoming requests.
+type bypassMatcher struct {
+	severity    plog.SeverityNumber
+	spanErrors  bool
+	metricNames map[string]struct{}
+}
+
+// newBypassMatcher returns nil when no bypass rule is configured.
+func newBypassMatcher(cfg BypassConfig) *bypassMatcher {
+	m := &bypassMatcher{spanErrors: cfg.SpanStatusError}
+	if cfg.LogSeverity != "" {
+		m.severity, _ = cfg.SeverityNumber()
+	}
+	if len(cfg.MetricNames) != 0 {
+		m.metricNames = make(map[string]struct{}, len(cfg.MetricNames))
+		for _, name := range cfg.MetricNames {
+			m.metricNames[name] = struct{}{}
+		}
+	}
+	if m.severity == plog.SeverityNumberUnspecified && !m.spanErrors && m.metricNames == nil {
+		return nil
+	}
+	return m
+}
+
+// matches returns true when any item in the request matches a rule.
+func (m *bypassMatcher) matches(item any) bool {
+	switch data := item.(type) {
+	case ptrace.Traces:
+		return m.matchesTraces(data)
+	case pmetric.Metrics:
+		return m.matchesMetrics(data)
+	case plog.Logs:
+		return m.matchesLogs(data)
+	}
+	return false
+}
+
+func (m *bypassMatcher) matchesTraces(td ptrace.Traces) bool {
+	if !m.spanErrors {
+		return false
+	}
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		sss := rss.At(i).ScopeSpans()
+		for j := 0; j < sss.Len(); j++ {
+			spans := sss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				if spans.At(k).Status().Code() == ptrace.StatusCodeError {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (m *bypassMatcher) matchesMetrics(md pmetric.Metrics) bool {
+	if m.metricNames == nil {
+		return false
+	}
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		sms := rms.At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			metrics := sms.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				if _, ok := m.metricNames[metrics.At(k).Name()]; ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (m *bypassMatcher) matchesLogs(ld plog.Logs) bool {
+	if m.severity == plog.SeverityNumberUnspecified {
+		return false
+	}
+	rls := ld.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		sls := rls.At(i).ScopeLogs()
+		for j := 0; j < sls.Len(); j++ {
+			logs := sls.At(j).LogRecords()
+			for k := 0; k < logs.Len(); k++ {
+				if logs.At(k).SeverityNumber() >= m.severity {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/internal/testdata"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+func TestNewBypassMatcherDisabled(t *testing.T) {
+	assert.Nil(t, newBypassMatcher(BypassConfig{}))
+	assert.Nil(t, newBypassMatcher(BypassConfig{Mode: "forward"}))
+}
+
+// generateTracesWithoutErrors returns test traces with every span status unset.
+func generateTracesWithoutErrors(count int) ptrace.Traces {
+	td := testdata.GenerateTraces(count)
+	spans := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+	for i := 0; i < spans.Len(); i++ {
+		spans.At(i).Status().SetCode(ptrace.StatusCodeUnset)
+	}
+	return td
+}
+
+func TestBypassMatcher(t *testing.T) {
+	m := newBypassMatcher(BypassConfig{
+		LogSeverity:     "error",
+		SpanStatusError: true,
+		MetricNames:     []string{testdata.TestGaugeDoubleMetricName},
+	})
+	require.NotNil(t, m)
+
+	ld := testdata.GenerateLogs(2)
+	assert.False(t, m.matches(ld))
+	ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(1).SetSeverityNumber(plog.SeverityNumberFatal)
+	assert.True(t, m.matches(ld))
+
+	td := generateTracesWithoutErrors(2)
+	assert.False(t, m.matches(td))
+	td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(1).Status().SetCode(ptrace.StatusCodeError)
+	assert.True(t, m.matches(td))
+
+	md := testdata.GenerateMetrics(2)
+	assert.True(t, m.matches(md))
+	md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().RemoveIf(func(pm pmetric.Metric) bool {
+		return pm.Name() == testdata.TestGaugeDoubleMetricName
+	})
+	assert.False(t, m.matches(md))
+}
+
+func TestBatchProcessorBypassFlush(t *testing.T) {
+	sink := new(consumertest.LogsSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.Bypass.LogSeverity = "ERROR"
+	batcher, err := newBatchLogsProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, batcher.ConsumeLogs(context.Background(), testdata.GenerateLogs(5)))
+
+	ld := testdata.GenerateLogs(3)
+	ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).SetSeverityNumber(plog.SeverityNumberError)
+	require.NoError(t, batcher.ConsumeLogs(context.Background(), ld))
+
+	// The pending batch is sent together with the matching request.
+	require.Eventually(t, func() bool {
+		return sink.LogRecordCount() == 8
+	}, time.Second, 5*time.Millisecond)
+	assert.Len(t, sink.AllLogs(), 1)
+
+	require.NoError(t, batcher.Shutdown(context.Background()))
+}
+
+func TestBatchProcessorBypassForward(t *testing.T) {
+	sink := new(consumertest.TracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.Bypass.Mode = "forward"
+	cfg.Bypass.SpanStatusError = true
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), generateTracesWithoutErrors(5)))
+
+	td := generateTracesWithoutErrors(3)
+	td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(2).Status().SetCode(ptrace.StatusCodeError)
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), td))
+
+	// Only the matching request is sent, the pending batch waits.
+	require.Eventually(t, func() bool {
+		return sink.SpanCount() == 3
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	require.Equal(t, 8, sink.SpanCount())
+	require.Len(t, sink.AllTraces(), 2)
+	assert.Equal(t, 5, sink.AllTraces()[1].SpanCount())
+}
+
+func TestBatchProcessorBypassTelemetry(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := new(consumertest.MetricsSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.Bypass.MetricNames = []string{testdata.TestGaugeDoubleMetricName}
+	batcher, err := newBatchMetricsProcessor(tel.NewProcessorCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	const requestCount = 3
+	for i := 0; i < requestCount; i++ {
+		require.NoError(t, batcher.ConsumeMetrics(context.Background(), testdata.GenerateMetrics(2)))
+	}
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	tel.assertMetrics(t, expectedMetrics{
+		sendCount:     requestCount,
+		sendSizeSum:   float64(sink.DataPointCount()),
+		bypassTrigger: requestCount,
+	})
+}
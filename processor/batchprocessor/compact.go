@@ -0,0 +1,348 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor // import "go.opentelemetry.io/collector/processor/batchprocessor"
+
+import (
+	"math"
+	"sort"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// FNV-1a parameters, hashed inline to avoid allocating a hash.Hash64
+// per resource.
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+func hashString(h uint64, s string) uint64 {
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime64
+	}
+	return h
+}
+
+func hashUint64(h uint64, v uint64) uint64 {
+	for i := 0; i < 8; i++ {
+		h ^= v & 0xff
+		h *= fnvPrime64
+		v >>= 8
+	}
+	return h
+}
+
+// hashMap returns a hash of the attributes of m that does not depend
+// on their order.
+func hashMap(m pcommon.Map) uint64 {
+	var sum uint64
+	m.Range(func(k string, v pcommon.Value) bool {
+		sum += hashValue(hashString(fnvOffset64, k), v)
+		return true
+	})
+	return sum
+}
+
+func hashValue(h uint64, v pcommon.Value) uint64 {
+	h = hashUint64(h, uint64(v.Type()))
+	switch v.Type() {
+	case pcommon.ValueTypeStr:
+		h = hashString(h, v.Str())
+	case pcommon.ValueTypeInt:
+		h = hashUint64(h, uint64(v.Int()))
+	case pcommon.ValueTypeDouble:
+		h = hashUint64(h, math.Float64bits(v.Double()))
+	case pcommon.ValueTypeBool:
+		if v.Bool() {
+			h = hashUint64(h, 1)
+		}
+	case pcommon.ValueTypeBytes:
+		for _, b := range v.Bytes().AsRaw() {
+			h ^= uint64(b)
+			h *= fnvPrime64
+		}
+	case pcommon.ValueTypeMap:
+		h = hashUint64(h, hashMap(v.Map()))
+	case pcommon.ValueTypeSlice:
+		s := v.Slice()
+		for i := 0; i < s.Len(); i++ {
+			h = hashValue(h, s.At(i))
+		}
+	}
+	return h
+}
+
+// mapsEqual returns whether a and b hold the same attributes, in any
+// order.
+func mapsEqual(a, b pcommon.Map) bool {
+	if a.Len() != b.Len() {
+		return false
+	}
+	equal := true
+	a.Range(func(k string, va pcommon.Value) bool {
+		vb, ok := b.Get(k)
+		equal = ok && valuesEqual(va, vb)
+		return equal
+	})
+	return equal
+}
+
+func valuesEqual(a, b pcommon.Value) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a.Type() {
+	case pcommon.ValueTypeStr:
+		return a.Str() == b.Str()
+	case pcommon.ValueTypeInt:
+		return a.Int() == b.Int()
+	case pcommon.ValueTypeDouble:
+		return math.Float64bits(a.Double()) == math.Float64bits(b.Double())
+	case pcommon.ValueTypeBool:
+		return a.Bool() == b.Bool()
+	case pcommon.ValueTypeBytes:
+		return string(a.Bytes().AsRaw()) == string(b.Bytes().AsRaw())
+	case pcommon.ValueTypeMap:
+		return mapsEqual(a.Map(), b.Map())
+	case pcommon.ValueTypeSlice:
+		sa, sb := a.Slice(), b.Slice()
+		if sa.Len() != sb.Len() {
+			return false
+		}
+		for i := 0; i < sa.Len(); i++ {
+			if !valuesEqual(sa.At(i), sb.At(i)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// hashResource returns the hash of a resource entry of a request,
+// identified by its resource and schema URL.
+func hashResource(res pcommon.Resource, schemaURL string) uint64 {
+	h := hashUint64(fnvOffset64, hashMap(res.Attributes()))
+	h = hashUint64(h, uint64(res.DroppedAttributesCount()))
+	return hashString(h, schemaURL)
+}
+
+func resourcesEqual(a pcommon.Resource, aSchemaURL string, b pcommon.Resource, bSchemaURL string) bool {
+	return aSchemaURL == bSchemaURL &&
+		a.DroppedAttributesCount() == b.DroppedAttributesCount() &&
+		mapsEqual(a.Attributes(), b.Attributes())
+}
+
+// resourceSet returns a hash of the distinct resources of the request
+// data, not depending on their order or repetition.
+func resourceSet(data any) uint64 {
+	var hashes []uint64
+	switch d := data.(type) {
+	case ptrace.Traces:
+		rss := d.ResourceSpans()
+		hashes = make([]uint64, rss.Len())
+		for i := range hashes {
+			hashes[i] = hashResource(rss.At(i).Resource(), rss.At(i).SchemaUrl())
+		}
+	case pmetric.Metrics:
+		rms := d.ResourceMetrics()
+		hashes = make([]uint64, rms.Len())
+		for i := range hashes {
+			hashes[i] = hashResource(rms.At(i).Resource(), rms.At(i).SchemaUrl())
+		}
+	case plog.Logs:
+		rls := d.ResourceLogs()
+		hashes = make([]uint64, rls.Len())
+		for i := range hashes {
+			hashes[i] = hashResource(rls.At(i).Resource(), rls.At(i).SchemaUrl())
+		}
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	h := uint64(fnvOffset64)
+	for i, v := range hashes {
+		if i == 0 || v != hashes[i-1] {
+			h = hashUint64(h, v)
+		}
+	}
+	return h
+}
+
+// hashScope returns the hash of a scope entry of a resource, identified
+// by its instrumentation scope and schema URL.
+func hashScope(scope pcommon.InstrumentationScope, schemaURL string) uint64 {
+	h := hashString(fnvOffset64, scope.Name())
+	h = hashString(hashUint64(h, uint64(len(scope.Name()))), scope.Version())
+	h = hashUint64(h, hashMap(scope.Attributes()))
+	h = hashUint64(h, uint64(scope.DroppedAttributesCount()))
+	return hashString(h, schemaURL)
+}
+
+func scopesEqual(a pcommon.InstrumentationScope, aSchemaURL string, b pcommon.InstrumentationScope, bSchemaURL string) bool {
+	return aSchemaURL == bSchemaURL &&
+		a.Name() == b.Name() &&
+		a.Version() == b.Version() &&
+		a.DroppedAttributesCount() == b.DroppedAttributesCount() &&
+		mapsEqual(a.Attributes(), b.Attributes())
+}
+
+// hashIndex maps hashes to the positions of the entries of a slice
+// having them.
+type hashIndex map[uint64][]int
+
+// find returns the position of the entry of hash h for which equal
+// returns true.
+func (hi hashIndex) find(h uint64, equal func(i int) bool) (int, bool) {
+	for _, i := range hi[h] {
+		if equal(i) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (hi hashIndex) put(h uint64, i int) {
+	hi[h] = append(hi[h], i)
+}
+
+// compactIndex indexes the resource entries of a batch, and the scope
+// entries of each of them, so that the scopes of an incoming resource
+// equal to one of the batch are merged into its entry, and the items of
+// an incoming scope equal to one of that entry are appended to it,
+// instead of adding entries per request.  Positions change when the
+// batch is split or data is requeued, after which the index is built
+// again.
+type compactIndex struct {
+	resources hashIndex
+	// scopes holds the index of the scopes of every resource entry,
+	// built when first needed.
+	scopes []hashIndex
+	// metrics holds, for batches merging data points, the positions
+	// of the metrics of every scope entry by name, built when first
+	// needed.
+	metrics map[scopePosition]map[string][]int
+}
+
+// scopePosition is the position of a scope entry in a batch.
+type scopePosition struct {
+	resource, scope int
+}
+
+// newCompactIndex indexes the n resource entries returned by at.
+func newCompactIndex(n int, at func(i int) (pcommon.Resource, string)) *compactIndex {
+	ci := &compactIndex{resources: make(hashIndex, n), scopes: make([]hashIndex, n)}
+	for i := 0; i < n; i++ {
+		res, schemaURL := at(i)
+		ci.resources.put(hashResource(res, schemaURL), i)
+	}
+	return ci
+}
+
+// findResource returns the position of the entry equal to res and
+// schemaURL, of hash h, among those returned by at.
+func (ci *compactIndex) findResource(h uint64, res pcommon.Resource, schemaURL string, at func(i int) (pcommon.Resource, string)) (int, bool) {
+	return ci.resources.find(h, func(i int) bool {
+		other, otherSchemaURL := at(i)
+		return resourcesEqual(res, schemaURL, other, otherSchemaURL)
+	})
+}
+
+// addResource records a resource entry of hash h appended to the batch.
+func (ci *compactIndex) addResource(h uint64) {
+	ci.resources.put(h, len(ci.scopes))
+	ci.scopes = append(ci.scopes, nil)
+}
+
+// scopeIndex returns the index of the n scope entries, returned by at,
+// of the resource entry at position i.
+func (ci *compactIndex) scopeIndex(i, n int, at func(k int) (pcommon.InstrumentationScope, string)) hashIndex {
+	if ci.scopes[i] == nil {
+		si := make(hashIndex, n)
+		for k := 0; k < n; k++ {
+			scope, schemaURL := at(k)
+			si.put(hashScope(scope, schemaURL), k)
+		}
+		ci.scopes[i] = si
+	}
+	return ci.scopes[i]
+}
+
+// findScope returns the position of the scope entry equal to scope and
+// schemaURL, of hash h, in si among those returned by at.
+func findScope(si hashIndex, h uint64, scope pcommon.InstrumentationScope, schemaURL string, at func(k int) (pcommon.InstrumentationScope, string)) (int, bool) {
+	return si.find(h, func(k int) bool {
+		other, otherSchemaURL := at(k)
+		return scopesEqual(scope, schemaURL, other, otherSchemaURL)
+	})
+}
+
+// metricIndex returns the positions by name of the metrics ms of the
+// scope entry at position k of the resource entry at position i.
+func (ci *compactIndex) metricIndex(i, k int, ms pmetric.MetricSlice) map[string][]int {
+	pos := scopePosition{resource: i, scope: k}
+	mi, ok := ci.metrics[pos]
+	if !ok {
+		if ci.metrics == nil {
+			ci.metrics = map[scopePosition]map[string][]int{}
+		}
+		mi = make(map[string][]int, ms.Len())
+		for l := 0; l < ms.Len(); l++ {
+			name := ms.At(l).Name()
+			mi[name] = append(mi[name], l)
+		}
+		ci.metrics[pos] = mi
+	}
+	return mi
+}
+
+// metricsIdentical returns whether the data points of a and b may be
+// merged into a single metric: they have the same name, unit and type,
+// and for sums and histograms the same temporality and monotonicity.
+func metricsIdentical(a, b pmetric.Metric) bool {
+	if a.Name() != b.Name() || a.Unit() != b.Unit() || a.Type() != b.Type() {
+		return false
+	}
+	switch a.Type() {
+	case pmetric.MetricTypeGauge, pmetric.MetricTypeSummary:
+		return true
+	case pmetric.MetricTypeSum:
+		return a.Sum().AggregationTemporality() == b.Sum().AggregationTemporality() &&
+			a.Sum().IsMonotonic() == b.Sum().IsMonotonic()
+	case pmetric.MetricTypeHistogram:
+		return a.Histogram().AggregationTemporality() == b.Histogram().AggregationTemporality()
+	case pmetric.MetricTypeExponentialHistogram:
+		return a.ExponentialHistogram().AggregationTemporality() == b.ExponentialHistogram().AggregationTemporality()
+	}
+	// Metrics without data are not merged.
+	return false
+}
+
+// moveDataPoints appends the data points of src to dst, identical to it.
+func moveDataPoints(src, dst pmetric.Metric) {
+	switch src.Type() {
+	case pmetric.MetricTypeGauge:
+		src.Gauge().DataPoints().MoveAndAppendTo(dst.Gauge().DataPoints())
+	case pmetric.MetricTypeSum:
+		src.Sum().DataPoints().MoveAndAppendTo(dst.Sum().DataPoints())
+	case pmetric.MetricTypeHistogram:
+		src.Histogram().DataPoints().MoveAndAppendTo(dst.Histogram().DataPoints())
+	case pmetric.MetricTypeExponentialHistogram:
+		src.ExponentialHistogram().DataPoints().MoveAndAppendTo(dst.ExponentialHistogram().DataPoints())
+	case pmetric.MetricTypeSummary:
+		src.Summary().DataPoints().MoveAndAppendTo(dst.Summary().DataPoints())
+	}
+}
@@ -0,0 +1,477 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/internal/testdata"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestHashResource(t *testing.T) {
+	a := pcommon.NewResource()
+	a.Attributes().PutStr("service.name", "checkout")
+	a.Attributes().PutInt("pid", 42)
+	a.Attributes().PutEmptySlice("args").AppendEmpty().SetStr("-v")
+	a.Attributes().PutEmptyMap("host").PutStr("name", "node-1")
+
+	// The same attributes in another order.
+	b := pcommon.NewResource()
+	b.Attributes().PutEmptyMap("host").PutStr("name", "node-1")
+	b.Attributes().PutEmptySlice("args").AppendEmpty().SetStr("-v")
+	b.Attributes().PutInt("pid", 42)
+	b.Attributes().PutStr("service.name", "checkout")
+	assert.Equal(t, hashResource(a, "s"), hashResource(b, "s"))
+	assert.True(t, resourcesEqual(a, "s", b, "s"))
+
+	assert.False(t, resourcesEqual(a, "s", b, "t"))
+	assert.NotEqual(t, hashResource(a, "s"), hashResource(b, "t"))
+
+	b.SetDroppedAttributesCount(1)
+	assert.False(t, resourcesEqual(a, "s", b, "s"))
+	b.SetDroppedAttributesCount(0)
+
+	b.Attributes().PutInt("pid", 43)
+	assert.False(t, resourcesEqual(a, "s", b, "s"))
+	assert.NotEqual(t, hashResource(a, "s"), hashResource(b, "s"))
+
+	// Values of different types are not equal.
+	b.Attributes().PutStr("pid", "42")
+	assert.False(t, resourcesEqual(a, "s", b, "s"))
+
+	b.Attributes().PutInt("pid", 42)
+	b.Attributes().Remove("args")
+	assert.False(t, resourcesEqual(a, "s", b, "s"))
+}
+
+func TestResourceSet(t *testing.T) {
+	request := func(hosts ...string) plog.Logs {
+		ld := plog.NewLogs()
+		for _, host := range hosts {
+			ld.ResourceLogs().AppendEmpty().Resource().Attributes().PutStr("host.name", host)
+		}
+		return ld
+	}
+	assert.Equal(t, resourceSet(request("a", "b")), resourceSet(request("b", "a", "a")))
+	assert.NotEqual(t, resourceSet(request("a")), resourceSet(request("a", "b")))
+	assert.NotEqual(t, resourceSet(request("a")), resourceSet(request("b")))
+
+	td := testdata.GenerateTraces(1)
+	md := testdata.GenerateMetrics(1)
+	assert.Equal(t, resourceSet(td), resourceSet(md))
+	md.ResourceMetrics().At(0).SetSchemaUrl("https://opentelemetry.io/schemas/1.21.0")
+	assert.NotEqual(t, resourceSet(td), resourceSet(md))
+}
+
+func TestBatchTracesCompact(t *testing.T) {
+	bt := newBatchTraces(consumertest.NewNop())
+	bt.compact = true
+	for i := 0; i < 5; i++ {
+		bt.Add(testdata.GenerateTraces(2), 2)
+	}
+	other := testdata.GenerateTraces(3)
+	other.ResourceSpans().At(0).Resource().Attributes().PutStr("host.name", "other")
+	bt.Add(other, 3)
+
+	rss := bt.traceData.ResourceSpans()
+	require.Equal(t, 2, rss.Len())
+	require.Equal(t, 1, rss.At(0).ScopeSpans().Len())
+	assert.Equal(t, 10, rss.At(0).ScopeSpans().At(0).Spans().Len())
+	assert.Equal(t, 1, rss.At(1).ScopeSpans().Len())
+	assert.Equal(t, 13, bt.traceData.SpanCount())
+	assert.Equal(t, 13, bt.ItemCount())
+
+	// The split part and the remainder add up to the batch.
+	req, sent, _, err := bt.Export(context.Background(), 4)
+	require.NoError(t, err)
+	assert.Equal(t, 4, sent)
+	assert.Equal(t, 4, req.(ptrace.Traces).SpanCount())
+	assert.Equal(t, 9, bt.ItemCount())
+	// The spans split off are removed from the batch once it changes.
+	bt.stopChunks()
+	assert.Equal(t, 9, bt.traceData.SpanCount())
+
+	// Requests are merged into the entries left by the split.
+	bt.Add(testdata.GenerateTraces(2), 2)
+	other = testdata.GenerateTraces(1)
+	other.ResourceSpans().At(0).Resource().Attributes().PutStr("host.name", "other")
+	bt.Add(other, 1)
+	assert.Equal(t, 2, bt.traceData.ResourceSpans().Len())
+	assert.Equal(t, 12, bt.traceData.SpanCount())
+
+	sent = 0
+	for bt.ItemCount() > 0 {
+		req, n, _, err := bt.Export(context.Background(), 5)
+		require.NoError(t, err)
+		assert.Equal(t, n, req.(ptrace.Traces).SpanCount())
+		sent += n
+	}
+	assert.Equal(t, 12, sent)
+}
+
+func TestHashScope(t *testing.T) {
+	a := pcommon.NewInstrumentationScope()
+	a.SetName("io.opentelemetry.http")
+	a.SetVersion("1.0.0")
+	a.Attributes().PutStr("k", "v")
+	b := pcommon.NewInstrumentationScope()
+	a.CopyTo(b)
+	assert.Equal(t, hashScope(a, "s"), hashScope(b, "s"))
+	assert.True(t, scopesEqual(a, "s", b, "s"))
+	assert.False(t, scopesEqual(a, "s", b, "t"))
+
+	b.SetVersion("1.0.1")
+	assert.False(t, scopesEqual(a, "s", b, "s"))
+	assert.NotEqual(t, hashScope(a, "s"), hashScope(b, "s"))
+
+	// The name and version are not hashed as one string.
+	a.SetName("ab")
+	a.SetVersion("c")
+	b.SetName("a")
+	b.SetVersion("bc")
+	assert.NotEqual(t, hashScope(a, "s"), hashScope(b, "s"))
+	assert.False(t, scopesEqual(a, "s", b, "s"))
+
+	b.SetName("ab")
+	b.SetVersion("c")
+	b.Attributes().PutStr("k", "w")
+	assert.False(t, scopesEqual(a, "s", b, "s"))
+}
+
+func TestBatchTracesCompactScopes(t *testing.T) {
+	request := func(versions ...string) ptrace.Traces {
+		td := ptrace.NewTraces()
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", "checkout")
+		for _, version := range versions {
+			ss := rs.ScopeSpans().AppendEmpty()
+			ss.Scope().SetName("io.opentelemetry.http")
+			ss.Scope().SetVersion(version)
+			ss.Spans().AppendEmpty()
+		}
+		return td
+	}
+
+	bt := newBatchTraces(consumertest.NewNop())
+	bt.compact = true
+	// Equal scopes of a single request are merged too.
+	bt.Add(request("1", "1", "2"), 3)
+	bt.Add(request("2"), 1)
+	bt.Add(request("3", "1"), 2)
+
+	rss := bt.traceData.ResourceSpans()
+	require.Equal(t, 1, rss.Len())
+	sss := rss.At(0).ScopeSpans()
+	require.Equal(t, 3, sss.Len())
+	for k, expected := range map[string]int{"1": 3, "2": 2, "3": 1} {
+		found := false
+		for i := 0; i < sss.Len(); i++ {
+			if sss.At(i).Scope().Version() == k {
+				assert.Equal(t, expected, sss.At(i).Spans().Len())
+				found = true
+			}
+		}
+		assert.True(t, found, k)
+	}
+	assert.Equal(t, 6, bt.ItemCount())
+
+	// After a split, requests are merged into the scopes left.
+	req, sent, _, err := bt.Export(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, 2, req.(ptrace.Traces).SpanCount())
+	assert.Equal(t, 2, sent)
+	bt.Add(request("1", "2", "3"), 3)
+	assert.Equal(t, 7, bt.ItemCount())
+	assert.Equal(t, 7, bt.traceData.SpanCount())
+	assert.Equal(t, 3, bt.traceData.ResourceSpans().At(0).ScopeSpans().Len())
+}
+
+func TestBatchTracesCompactRequeue(t *testing.T) {
+	bt := newBatchTraces(consumertest.NewNop())
+	bt.compact = true
+	bt.Add(testdata.GenerateTraces(2), 2)
+	bt.Requeue(testdata.GenerateTraces(1))
+	// The requeued entry comes first, and later requests are merged
+	// into it.
+	bt.Add(testdata.GenerateTraces(3), 3)
+	rss := bt.traceData.ResourceSpans()
+	require.Equal(t, 2, rss.Len())
+	require.Equal(t, 1, rss.At(0).ScopeSpans().Len())
+	assert.Equal(t, 4, rss.At(0).ScopeSpans().At(0).Spans().Len())
+	assert.Equal(t, 6, bt.ItemCount())
+}
+
+func TestBatchMetricsCompact(t *testing.T) {
+	bm := newBatchMetrics(consumertest.NewNop())
+	bm.compact = true
+	for i := 0; i < 4; i++ {
+		md := testdata.GenerateMetrics(2)
+		bm.Add(md, md.DataPointCount())
+	}
+	require.Equal(t, 1, bm.metricData.ResourceMetrics().Len())
+	require.Equal(t, 1, bm.metricData.ResourceMetrics().At(0).ScopeMetrics().Len())
+	assert.Equal(t, 8, bm.metricData.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().Len())
+	total := bm.ItemCount()
+	assert.Equal(t, total, bm.metricData.DataPointCount())
+
+	sent := 0
+	for bm.ItemCount() > 0 {
+		req, n, _, err := bm.Export(context.Background(), 3)
+		require.NoError(t, err)
+		assert.Equal(t, n, req.(pmetric.Metrics).DataPointCount())
+		sent += n
+	}
+	assert.Equal(t, total, sent)
+}
+
+func TestBatchLogsCompact(t *testing.T) {
+	bl := newBatchLogs(consumertest.NewNop())
+	bl.compact = true
+	for i := 0; i < 4; i++ {
+		bl.Add(testdata.GenerateLogs(3), 3)
+	}
+	require.Equal(t, 1, bl.logData.ResourceLogs().Len())
+	require.Equal(t, 1, bl.logData.ResourceLogs().At(0).ScopeLogs().Len())
+	assert.Equal(t, 12, bl.logData.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().Len())
+
+	sent := 0
+	for bl.ItemCount() > 0 {
+		req, n, _, err := bl.Export(context.Background(), 5)
+		require.NoError(t, err)
+		assert.Equal(t, n, req.(plog.Logs).LogRecordCount())
+		sent += n
+	}
+	assert.Equal(t, 12, sent)
+}
+
+// metricsRequest returns a request of the metric set by build, of the
+// same resource and scope as the others.
+func metricsRequest(build func(m pmetric.Metric)) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "scraper")
+	sm := rm.ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName("prometheus")
+	m := sm.Metrics().AppendEmpty()
+	m.SetName("requests")
+	m.SetUnit("1")
+	build(m)
+	return md
+}
+
+func TestBatchMetricsMergeDataPoints(t *testing.T) {
+	tests := []struct {
+		name string
+		// build sets a metric of one data point.
+		build func(m pmetric.Metric)
+		// other sets a metric of the same name and type, not
+		// identical to the one of build.
+		other func(m pmetric.Metric)
+		// dataPoints returns the number of data points of m.
+		dataPoints func(m pmetric.Metric) int
+	}{
+		{
+			name:       "gauge",
+			build:      func(m pmetric.Metric) { m.SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(1) },
+			other:      func(m pmetric.Metric) { m.SetUnit("s"); m.SetEmptyGauge().DataPoints().AppendEmpty() },
+			dataPoints: func(m pmetric.Metric) int { return m.Gauge().DataPoints().Len() },
+		},
+		{
+			name: "sum",
+			build: func(m pmetric.Metric) {
+				sum := m.SetEmptySum()
+				sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+				sum.SetIsMonotonic(true)
+				sum.DataPoints().AppendEmpty().SetIntValue(1)
+			},
+			other: func(m pmetric.Metric) {
+				sum := m.SetEmptySum()
+				sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+				sum.DataPoints().AppendEmpty()
+			},
+			dataPoints: func(m pmetric.Metric) int { return m.Sum().DataPoints().Len() },
+		},
+		{
+			name: "histogram",
+			build: func(m pmetric.Metric) {
+				h := m.SetEmptyHistogram()
+				h.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+				h.DataPoints().AppendEmpty().SetCount(1)
+			},
+			other: func(m pmetric.Metric) {
+				h := m.SetEmptyHistogram()
+				h.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+				h.DataPoints().AppendEmpty()
+			},
+			dataPoints: func(m pmetric.Metric) int { return m.Histogram().DataPoints().Len() },
+		},
+		{
+			name: "exponential histogram",
+			build: func(m pmetric.Metric) {
+				h := m.SetEmptyExponentialHistogram()
+				h.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+				h.DataPoints().AppendEmpty().SetCount(1)
+			},
+			other: func(m pmetric.Metric) {
+				h := m.SetEmptyExponentialHistogram()
+				h.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+				h.DataPoints().AppendEmpty()
+			},
+			dataPoints: func(m pmetric.Metric) int { return m.ExponentialHistogram().DataPoints().Len() },
+		},
+		{
+			name:       "summary",
+			build:      func(m pmetric.Metric) { m.SetEmptySummary().DataPoints().AppendEmpty().SetCount(1) },
+			other:      func(m pmetric.Metric) { m.SetUnit("s"); m.SetEmptySummary().DataPoints().AppendEmpty() },
+			dataPoints: func(m pmetric.Metric) int { return m.Summary().DataPoints().Len() },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflicts := 0
+			bm := newBatchMetrics(consumertest.NewNop())
+			bm.compact = true
+			bm.mergeDataPoints = true
+			bm.onConflicts = func(n int) { conflicts += n }
+			for i := 0; i < 3; i++ {
+				bm.Add(metricsRequest(tt.build), 1)
+			}
+			ms := bm.metricData.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+			require.Equal(t, 1, ms.Len())
+			assert.Equal(t, 3, tt.dataPoints(ms.At(0)))
+			assert.Zero(t, conflicts)
+
+			bm.Add(metricsRequest(tt.other), 1)
+			bm.Add(metricsRequest(tt.other), 1)
+			require.Equal(t, 2, ms.Len())
+			assert.Equal(t, 3, tt.dataPoints(ms.At(0)))
+			assert.Equal(t, 2, tt.dataPoints(ms.At(1)))
+			// Only the first is not merged into a metric of the batch.
+			assert.Equal(t, 1, conflicts)
+
+			assert.Equal(t, 5, bm.ItemCount())
+			assert.Equal(t, 5, bm.metricData.DataPointCount())
+		})
+	}
+}
+
+func TestBatchMetricsMergeDataPointsTypeConflict(t *testing.T) {
+	conflicts := 0
+	bm := newBatchMetrics(consumertest.NewNop())
+	bm.compact = true
+	bm.mergeDataPoints = true
+	bm.onConflicts = func(n int) { conflicts += n }
+
+	gauge := func(m pmetric.Metric) { m.SetEmptyGauge().DataPoints().AppendEmpty() }
+	sum := func(m pmetric.Metric) { m.SetEmptySum().DataPoints().AppendEmpty() }
+	bm.Add(metricsRequest(gauge), 1)
+	bm.Add(metricsRequest(sum), 1)
+	bm.Add(metricsRequest(gauge), 1)
+	ms := bm.metricData.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	require.Equal(t, 2, ms.Len())
+	assert.Equal(t, pmetric.MetricTypeGauge, ms.At(0).Type())
+	assert.Equal(t, 2, ms.At(0).Gauge().DataPoints().Len())
+	assert.Equal(t, pmetric.MetricTypeSum, ms.At(1).Type())
+	assert.Equal(t, 1, conflicts)
+
+	// The metrics of the remainder of a split are merged into as well.
+	req, sent, _, err := bm.Export(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, 1, req.(pmetric.Metrics).DataPointCount())
+	bm.Add(metricsRequest(sum), 1)
+	bm.Add(metricsRequest(gauge), 1)
+	assert.Equal(t, 4, bm.ItemCount())
+	assert.Equal(t, 4, bm.metricData.DataPointCount())
+	ms = bm.metricData.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	assert.Equal(t, 2, ms.Len())
+	assert.Equal(t, 1, conflicts)
+}
+
+func TestBatchProcessorMergeDataPointsConflicts(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := new(consumertest.MetricsSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 4
+	cfg.Compact = true
+	cfg.MergeDataPoints = true
+	batcher, err := newBatchMetricsProcessor(tel.NewProcessorCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	gauge := func(m pmetric.Metric) { m.SetEmptyGauge().DataPoints().AppendEmpty() }
+	sum := func(m pmetric.Metric) { m.SetEmptySum().DataPoints().AppendEmpty() }
+	for _, build := range []func(m pmetric.Metric){gauge, sum, gauge, sum} {
+		require.NoError(t, batcher.ConsumeMetrics(context.Background(), metricsRequest(build)))
+	}
+	require.Eventually(t, func() bool {
+		return sink.DataPointCount() == 4
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	require.Len(t, sink.AllMetrics(), 1)
+	assert.Equal(t, 2, sink.AllMetrics()[0].MetricCount())
+	tel.assertMetrics(t, expectedMetrics{
+		sendCount:            1,
+		sendSizeSum:          4,
+		sizeTrigger:          1,
+		metricMergeConflicts: 1,
+	})
+}
+
+// benchmarkBatchTracesCompact batches 500 requests of a span from the
+// same resource and scope, reporting the size of the exported payload.
+func benchmarkBatchTracesCompact(b *testing.B, compact bool) {
+	sizer := &ptrace.ProtoMarshaler{}
+	bt := newBatchTraces(consumertest.NewNop())
+	bt.compact = compact
+	requests := make([]ptrace.Traces, 500)
+	var size int
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		for i := range requests {
+			requests[i] = testdata.GenerateTraces(1)
+		}
+		b.StartTimer()
+		for _, td := range requests {
+			bt.Add(td, 1)
+		}
+		size = sizer.TracesSize(bt.traceData)
+		_, _, _, err := bt.Export(context.Background(), 0)
+		require.NoError(b, err)
+	}
+	b.ReportMetric(float64(size), "payload-bytes")
+}
+
+func BenchmarkBatchTracesPayload(b *testing.B) {
+	benchmarkBatchTracesCompact(b, false)
+}
+
+func BenchmarkBatchTracesPayloadCompact(b *testing.B) {
+	benchmarkBatchTracesCompact(b, true)
+}
@@ -46,42 +46,17 @@ func TestUnmarshalConfig(t *testing.T) {
 			SendBatchMaxSize:         uint32(11000),
 			Timeout:                  time.Second * 10,
 			MetadataCardinalityLimit: 1000,
-		}, cfg)
-}
-
-func TestValidateConfig_DefaultBatchMaxSize(t *testing.T) {
-	cfg := &Config{
-		SendBatchSize:    100,
-		SendBatchMaxSize: 0,
-	}
-	assert.NoError(t, cfg.Validate())
-}
-
-func TestValidateConfig_ValidBatchSizes(t *testing.T) {
-	cfg := &Config{
-		SendBatchSize:    100,
-		SendBatchMaxSize: 1000,
-	}
-	assert.NoError(t, cfg.Validate())
-
-}
-
-func TestValidateConfig_InvalidBatchSize(t *testing.T) {
-	cfg := &Config{
-		SendBatchSize:    1000,
-		SendBatchMaxSize: 100,
-	}
-	assert.Error(t, cfg.Validate())
-}
 
-func TestValidateConfig_InvalidTimeout(t *testing.T) {
-	cfg := &Config{
-		Timeout: -time.Second,
-	}
-	assert.Error(t, cfg.Validate())
-}
-
-func TestValidateConfig_ValidZero(t *testing.T) {
-	cfg := &Config{}
-	assert.NoError(t, cfg.Validate())
+			MetadataCardinalityWarnPercent: 80,
+
+			Retry: RetryConfig{
+				InitialInterval: 5 * time.Second,
+				MaxInterval:     30 * time.Second,
+				MaxElapsedTime:  5 * time.Minute,
+			},
+			FailureLogInterval: 10 * time.Second,
+			StatusReporting: StatusReportingConfig{
+				FailureThreshold: 5,
+			},
+		}, cfg)
 }
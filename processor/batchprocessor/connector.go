@@ -0,0 +1,79 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor // import "go.opentelemetry.io/collector/processor/batchprocessor"
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/connector"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/processor"
+)
+
+// NewConnectorFactory returns a new factory for the batch connector, a
+// batch processor shared by the pipelines exporting to it.  The
+// collector creates a single connector per id and signal, whose
+// batchers, metadata cardinality, and telemetry are then shared by all
+// these pipelines, started before any of them and shut down once all
+// of them are.  It batches traces, metrics, or logs into pipelines of
+// the same signal, with the configuration of the batch processor.
+func NewConnectorFactory(opts ...FactoryOption) connector.Factory {
+	return connector.NewFactory(
+		typeStr,
+		createDefaultConfig,
+		connector.WithTracesToTraces(createTracesToTracesFunc(opts), component.StabilityLevelDevelopment),
+		connector.WithMetricsToMetrics(createMetricsToMetricsFunc(opts), component.StabilityLevelDevelopment),
+		connector.WithLogsToLogs(createLogsToLogsFunc(opts), component.StabilityLevelDevelopment))
+}
+
+// processorSettings returns the settings of the batch processor of a
+// connector.
+func processorSettings(set connector.CreateSettings) processor.CreateSettings {
+	return processor.CreateSettings{ID: set.ID, TelemetrySettings: set.TelemetrySettings, BuildInfo: set.BuildInfo}
+}
+
+func createTracesToTracesFunc(opts []FactoryOption) connector.CreateTracesToTracesFunc {
+	return func(
+		_ context.Context,
+		set connector.CreateSettings,
+		cfg component.Config,
+		nextConsumer consumer.Traces,
+	) (connector.Traces, error) {
+		return newBatchTracesProcessor(processorSettings(set), nextConsumer, cfg.(*Config), opts...)
+	}
+}
+
+func createMetricsToMetricsFunc(opts []FactoryOption) connector.CreateMetricsToMetricsFunc {
+	return func(
+		_ context.Context,
+		set connector.CreateSettings,
+		cfg component.Config,
+		nextConsumer consumer.Metrics,
+	) (connector.Metrics, error) {
+		return newBatchMetricsProcessor(processorSettings(set), nextConsumer, cfg.(*Config), opts...)
+	}
+}
+
+func createLogsToLogsFunc(opts []FactoryOption) connector.CreateLogsToLogsFunc {
+	return func(
+		_ context.Context,
+		set connector.CreateSettings,
+		cfg component.Config,
+		nextConsumer consumer.Logs,
+	) (connector.Logs, error) {
+		return newBatchLogsProcessor(processorSettings(set), nextConsumer, cfg.(*Config), opts...)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/connector/connectortest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/internal/testdata"
+)
+
+func TestCreateConnector(t *testing.T) {
+	factory := NewConnectorFactory()
+	assert.Equal(t, component.Type(typeStr), factory.Type())
+
+	cfg := factory.CreateDefaultConfig()
+	assert.NoError(t, componenttest.CheckConfigStruct(cfg))
+	set := connectortest.NewNopCreateSettings()
+
+	tc, err := factory.CreateTracesToTraces(context.Background(), set, cfg, consumertest.NewNop())
+	assert.NoError(t, err)
+	assert.NotNil(t, tc)
+
+	mc, err := factory.CreateMetricsToMetrics(context.Background(), set, cfg, consumertest.NewNop())
+	assert.NoError(t, err)
+	assert.NotNil(t, mc)
+
+	lc, err := factory.CreateLogsToLogs(context.Background(), set, cfg, consumertest.NewNop())
+	assert.NoError(t, err)
+	assert.NotNil(t, lc)
+
+	// Batches are only sent to pipelines of the same signal.
+	_, err = factory.CreateTracesToMetrics(context.Background(), set, cfg, consumertest.NewNop())
+	assert.Error(t, err)
+}
+
+func TestBatchConnectorSharedAcrossPipelines(t *testing.T) {
+	factory := NewConnectorFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.SendBatchSize = 6
+	cfg.Timeout = time.Hour
+	sink := new(consumertest.TracesSink)
+	tc, err := factory.CreateTracesToTraces(context.Background(), connectortest.NewNopCreateSettings(), cfg, sink)
+	require.NoError(t, err)
+	require.NoError(t, tc.Start(context.Background(), componenttest.NewNopHost()))
+
+	// Three pipelines exporting to the connector fill a single batch.
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, tc.ConsumeTraces(context.Background(), testdata.GenerateTraces(2)))
+		}()
+	}
+	wg.Wait()
+	require.Eventually(t, func() bool {
+		return sink.SpanCount() == 6
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, tc.Shutdown(context.Background()))
+	assert.Len(t, sink.AllTraces(), 1)
+}
@@ -0,0 +1,119 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor // import "go.opentelemetry.io/collector/processor/batchprocessor"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// deadLetterFunc hands the data of a failed export to the dead-letter
+// consumer of the processor's data type.
+type deadLetterFunc func(ctx context.Context, data any) error
+
+// newDeadLetterFunc returns the deadLetterFunc sending to c, which must
+// be a consumer of dataType.  The data of read-only requests is copied
+// for a consumer which does not accept read-only data.
+func newDeadLetterFunc(dataType component.DataType, c any) (deadLetterFunc, error) {
+	switch dataType {
+	case component.DataTypeTraces:
+		if tc, ok := c.(consumer.Traces); ok {
+			readOnly := tc.Capabilities().AcceptsReadOnly
+			return func(ctx context.Context, data any) error {
+				if readOnly {
+					return tc.ConsumeTraces(ctx, data.(ptrace.Traces))
+				}
+				return tc.ConsumeTraces(ctx, data.(ptrace.Traces).AsMutable())
+			}, nil
+		}
+	case component.DataTypeMetrics:
+		if mc, ok := c.(consumer.Metrics); ok {
+			readOnly := mc.Capabilities().AcceptsReadOnly
+			return func(ctx context.Context, data any) error {
+				if readOnly {
+					return mc.ConsumeMetrics(ctx, data.(pmetric.Metrics))
+				}
+				return mc.ConsumeMetrics(ctx, data.(pmetric.Metrics).AsMutable())
+			}, nil
+		}
+	case component.DataTypeLogs:
+		if lc, ok := c.(consumer.Logs); ok {
+			readOnly := lc.Capabilities().AcceptsReadOnly
+			return func(ctx context.Context, data any) error {
+				if readOnly {
+					return lc.ConsumeLogs(ctx, data.(plog.Logs))
+				}
+				return lc.ConsumeLogs(ctx, data.(plog.Logs).AsMutable())
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("dead-letter consumer does not accept %s", dataType)
+}
+
+// deadLetter returns the dead-letter consumer set by the factory
+// options for dataType, nil when not set.
+func (fo *factoryOptions) deadLetter(dataType component.DataType) any {
+	switch {
+	case dataType == component.DataTypeTraces && fo.tracesDeadLetter != nil:
+		return fo.tracesDeadLetter
+	case dataType == component.DataTypeMetrics && fo.metricsDeadLetter != nil:
+		return fo.metricsDeadLetter
+	case dataType == component.DataTypeLogs && fo.logsDeadLetter != nil:
+		return fo.logsDeadLetter
+	}
+	return nil
+}
+
+// deadLetterExporter returns the deadLetterFunc for the exporter
+// configured in DeadLetterExporter.
+func deadLetterExporter(host component.Host, dataType component.DataType, id component.ID) (deadLetterFunc, error) {
+	exp, ok := host.GetExporters()[dataType][id]
+	if !ok {
+		return nil, fmt.Errorf("dead-letter exporter %q not found in %s pipelines", id, dataType)
+	}
+	return newDeadLetterFunc(dataType, exp)
+}
+
+// returnedData returns the data that failed to export in req, which is
+// the data carried by err when the next consumer reported a partial
+// failure, and whether err carried data.
+func returnedData(req any, err error) (any, bool) {
+	switch req.(type) {
+	case ptrace.Traces:
+		var te consumererror.Traces
+		if errors.As(err, &te) {
+			return te.Data(), true
+		}
+	case pmetric.Metrics:
+		var me consumererror.Metrics
+		if errors.As(err, &me) {
+			return me.Data(), true
+		}
+	case plog.Logs:
+		var le consumererror.Logs
+		if errors.As(err, &le) {
+			return le.Data(), true
+		}
+	}
+	return req, false
+}
@@ -0,0 +1,149 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/internal/testdata"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+// failingTracesSink records the traces it receives and fails.
+type failingTracesSink struct {
+	consumertest.TracesSink
+}
+
+func (s *failingTracesSink) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	// Record a copy, the dead-letter consumer receives the same data.
+	cp := ptrace.NewTraces()
+	td.CopyTo(cp)
+	_ = s.TracesSink.ConsumeTraces(ctx, cp)
+	return errors.New("export failed")
+}
+
+func TestBatchProcessorDeadLetter(t *testing.T) {
+	tel := setupTelemetry(t)
+	failing := new(failingTracesSink)
+	dlq := new(consumertest.TracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 3
+	cfg.SendBatchMaxSize = 3
+	cfg.Timeout = 10 * time.Minute
+	batcher, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), failing, cfg, WithTracesDeadLetter(dlq))
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(10)))
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	// Each split request is handed to the dead-letter consumer as sent.
+	require.Len(t, failing.AllTraces(), 4)
+	assert.Equal(t, failing.AllTraces(), dlq.AllTraces())
+	assert.Equal(t, 10, dlq.SpanCount())
+
+	tel.assertMetrics(t, expectedMetrics{
+		deadLetterItems: 10,
+	})
+}
+
+func TestBatchProcessorDeadLetterPartialFailure(t *testing.T) {
+	failed := testdata.GenerateLogs(2)
+	dlq := new(consumertest.LogsSink)
+	cfg := createDefaultConfig().(*Config)
+	next := consumertest.NewErr(consumererror.NewLogs(errors.New("partial failure"), failed))
+	batcher, err := newBatchLogsProcessor(processortest.NewNopCreateSettings(), next, cfg, WithLogsDeadLetter(dlq))
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, batcher.ConsumeLogs(context.Background(), testdata.GenerateLogs(5)))
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	// Only the data reported as failed is sent to the dead-letter consumer.
+	assert.Equal(t, []plog.Logs{failed}, dlq.AllLogs())
+}
+
+func TestBatchProcessorDeadLetterFailure(t *testing.T) {
+	tel := setupTelemetry(t)
+	cfg := createDefaultConfig().(*Config)
+	next := consumertest.NewErr(errors.New("export failed"))
+	dlq := consumertest.NewErr(errors.New("dead-letter failed"))
+	batcher, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), next, cfg, WithTracesDeadLetter(dlq))
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(5)))
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	tel.assertMetrics(t, expectedMetrics{
+		droppedItems: map[string]float64{"send_failed": 5},
+	})
+}
+
+// exportersHost is a component.Host returning a fixed set of exporters.
+type exportersHost struct {
+	component.Host
+	exporters map[component.DataType]map[component.ID]component.Component
+}
+
+func (h *exportersHost) GetExporters() map[component.DataType]map[component.ID]component.Component {
+	return h.exporters
+}
+
+// tracesSinkExporter is an exporter component consuming into a sink.
+type tracesSinkExporter struct {
+	component.StartFunc
+	component.ShutdownFunc
+	*consumertest.TracesSink
+}
+
+func TestBatchProcessorDeadLetterExporter(t *testing.T) {
+	id := component.NewIDWithName("file", "dlq")
+	dlq := new(consumertest.TracesSink)
+	host := &exportersHost{
+		Host: componenttest.NewNopHost(),
+		exporters: map[component.DataType]map[component.ID]component.Component{
+			component.DataTypeTraces: {id: &tracesSinkExporter{TracesSink: dlq}},
+		},
+	}
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.DeadLetterExporter = &id
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), consumertest.NewErr(errors.New("export failed")), cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), host))
+
+	require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(5)))
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Equal(t, 5, dlq.SpanCount())
+
+	// The exporter must be configured for the same data type.
+	logs, err := newBatchLogsProcessor(processortest.NewNopCreateSettings(), consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+	assert.ErrorContains(t, logs.Start(context.Background(), host), "not found")
+	require.NoError(t, logs.Shutdown(context.Background()))
+}
@@ -16,68 +16,136 @@ package batchprocessor // import "go.opentelemetry.io/collector/processor/batchp
 
 import (
 	"context"
-	"time"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
-	"go.opentelemetry.io/collector/internal/obsreportconfig"
 	"go.opentelemetry.io/collector/processor"
+	"go.opentelemetry.io/collector/processor/batchprocessor/batching"
 )
 
-const (
-	// The value of "type" key in configuration.
-	typeStr = "batch"
+// The value of "type" key in configuration.
+const typeStr = "batch"
 
-	defaultSendBatchSize = uint32(8192)
-	defaultTimeout       = 200 * time.Millisecond
+// FactoryOption configures the batch processors created by a factory.
+type FactoryOption func(*factoryOptions)
 
-	// defaultMetadataCardinalityLimit should be set to the number
-	// of metadata configurations the user expects to submit to
-	// the collector.
-	defaultMetadataCardinalityLimit = 1000
-)
+type factoryOptions struct {
+	metadataTransformer MetadataTransformer
+
+	tracesDeadLetter  consumer.Traces
+	metricsDeadLetter consumer.Metrics
+	logsDeadLetter    consumer.Logs
+
+	reuseBatches bool
+}
+
+func newFactoryOptions(opts []FactoryOption) factoryOptions {
+	var fo factoryOptions
+	for _, opt := range opts {
+		opt(&fo)
+	}
+	return fo
+}
+
+// MetadataTransformer normalizes the values of a metadata key before
+// they are used to identify a batcher and copied into the outgoing
+// metadata.  It is applied after the built-in normalizations
+// configured in metadata_key_settings.  The values passed in may be
+// modified.
+type MetadataTransformer = batching.MetadataTransformer
+
+// WithMetadataTransformer sets a function applied to the values of
+// every configured metadata key.
+func WithMetadataTransformer(t MetadataTransformer) FactoryOption {
+	return func(o *factoryOptions) {
+		o.metadataTransformer = t
+	}
+}
+
+// WithTracesDeadLetter sets a consumer receiving the traces of batches
+// that fail to export, with the context of the failed export.  The
+// dead_letter_exporter setting takes precedence when configured.
+func WithTracesDeadLetter(c consumer.Traces) FactoryOption {
+	return func(o *factoryOptions) {
+		o.tracesDeadLetter = c
+	}
+}
+
+// WithMetricsDeadLetter sets a consumer receiving the metrics of
+// batches that fail to export, with the context of the failed export.
+// The dead_letter_exporter setting takes precedence when configured.
+func WithMetricsDeadLetter(c consumer.Metrics) FactoryOption {
+	return func(o *factoryOptions) {
+		o.metricsDeadLetter = c
+	}
+}
+
+// WithLogsDeadLetter sets a consumer receiving the logs of batches that
+// fail to export, with the context of the failed export.  The
+// dead_letter_exporter setting takes precedence when configured.
+func WithLogsDeadLetter(c consumer.Logs) FactoryOption {
+	return func(o *factoryOptions) {
+		o.logsDeadLetter = c
+	}
+}
+
+// WithBatchReuse declares that the next consumer does not retain the
+// data passed to it once its Consume call returns, e.g. because it
+// marshals or copies it, so that the container of a batch that was
+// exported successfully can be emptied and reused for the next batch.
+// Using it with a consumer that retains the data, such as an exporter
+// queue holding the requests in memory, corrupts that data.  Batches
+// are not reused when the next consumer accepts read-only data, as the
+// requests passed to it are marked read-only.
+func WithBatchReuse() FactoryOption {
+	return func(o *factoryOptions) {
+		o.reuseBatches = true
+	}
+}
 
 // NewFactory returns a new factory for the Batch processor.
-func NewFactory() processor.Factory {
+func NewFactory(opts ...FactoryOption) processor.Factory {
 	return processor.NewFactory(
 		typeStr,
 		createDefaultConfig,
-		processor.WithTraces(createTraces, component.StabilityLevelStable),
-		processor.WithMetrics(createMetrics, component.StabilityLevelStable),
-		processor.WithLogs(createLogs, component.StabilityLevelStable))
+		processor.WithTraces(createTracesFunc(opts), component.StabilityLevelStable),
+		processor.WithMetrics(createMetricsFunc(opts), component.StabilityLevelStable),
+		processor.WithLogs(createLogsFunc(opts), component.StabilityLevelStable))
 }
 
 func createDefaultConfig() component.Config {
-	return &Config{
-		SendBatchSize:            defaultSendBatchSize,
-		Timeout:                  defaultTimeout,
-		MetadataCardinalityLimit: defaultMetadataCardinalityLimit,
-	}
+	return batching.NewDefaultConfig()
 }
 
-func createTraces(
-	_ context.Context,
-	set processor.CreateSettings,
-	cfg component.Config,
-	nextConsumer consumer.Traces,
-) (processor.Traces, error) {
-	return newBatchTracesProcessor(set, nextConsumer, cfg.(*Config), obsreportconfig.UseOtelForInternalMetricsfeatureGate.IsEnabled())
+func createTracesFunc(opts []FactoryOption) processor.CreateTracesFunc {
+	return func(
+		_ context.Context,
+		set processor.CreateSettings,
+		cfg component.Config,
+		nextConsumer consumer.Traces,
+	) (processor.Traces, error) {
+		return newBatchTracesProcessor(set, nextConsumer, cfg.(*Config), opts...)
+	}
 }
 
-func createMetrics(
-	_ context.Context,
-	set processor.CreateSettings,
-	cfg component.Config,
-	nextConsumer consumer.Metrics,
-) (processor.Metrics, error) {
-	return newBatchMetricsProcessor(set, nextConsumer, cfg.(*Config), obsreportconfig.UseOtelForInternalMetricsfeatureGate.IsEnabled())
+func createMetricsFunc(opts []FactoryOption) processor.CreateMetricsFunc {
+	return func(
+		_ context.Context,
+		set processor.CreateSettings,
+		cfg component.Config,
+		nextConsumer consumer.Metrics,
+	) (processor.Metrics, error) {
+		return newBatchMetricsProcessor(set, nextConsumer, cfg.(*Config), opts...)
+	}
 }
 
-func createLogs(
-	_ context.Context,
-	set processor.CreateSettings,
-	cfg component.Config,
-	nextConsumer consumer.Logs,
-) (processor.Logs, error) {
-	return newBatchLogsProcessor(set, nextConsumer, cfg.(*Config), obsreportconfig.UseOtelForInternalMetricsfeatureGate.IsEnabled())
+func createLogsFunc(opts []FactoryOption) processor.CreateLogsFunc {
+	return func(
+		_ context.Context,
+		set processor.CreateSettings,
+		cfg component.Config,
+		nextConsumer consumer.Logs,
+	) (processor.Logs, error) {
+		return newBatchLogsProcessor(set, nextConsumer, cfg.(*Config), opts...)
+	}
 }
@@ -19,8 +19,12 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"go.opentelemetry.io/collector/client"
 	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/internal/testdata"
 	"go.opentelemetry.io/collector/processor/processortest"
 )
 
@@ -49,3 +53,25 @@ func TestCreateProcessor(t *testing.T) {
 	assert.NotNil(t, lp)
 	assert.NoError(t, err, "cannot create logs processor")
 }
+
+func TestCreateProcessorWithMetadataTransformer(t *testing.T) {
+	var transformed []string
+	transformer := func(key string, values []string) []string {
+		transformed = append(transformed, key)
+		return values
+	}
+	factory := NewFactory(WithMetadataTransformer(transformer))
+
+	cfg := factory.CreateDefaultConfig()
+	cfg.(*Config).MetadataKeys = []string{"tenant"}
+	creationSet := processortest.NewNopCreateSettings()
+	tp, err := factory.CreateTracesProcessor(context.Background(), creationSet, cfg, consumertest.NewNop())
+	assert.NoError(t, err)
+	require.NoError(t, tp.Start(context.Background(), componenttest.NewNopHost()))
+	ctx := client.NewContext(context.Background(), client.Info{
+		Metadata: client.NewMetadata(map[string][]string{"tenant": {"a"}}),
+	})
+	require.NoError(t, tp.ConsumeTraces(ctx, testdata.GenerateTraces(1)))
+	require.NoError(t, tp.Shutdown(context.Background()))
+	assert.Equal(t, []string{"tenant"}, transformed)
+}
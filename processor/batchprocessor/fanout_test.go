@@ -0,0 +1,183 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/connector"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/extension"
+	"go.opentelemetry.io/collector/internal/testdata"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/processor"
+	"go.opentelemetry.io/collector/receiver"
+	"go.opentelemetry.io/collector/service"
+	"go.opentelemetry.io/collector/service/telemetry"
+)
+
+// fanoutReceiver exposes the consumer of the receiver, which fans out
+// to the pipelines sharing it.
+type fanoutReceiver struct {
+	component.StartFunc
+	component.ShutdownFunc
+	consumer.Traces
+}
+
+type sinkExporter struct {
+	component.StartFunc
+	component.ShutdownFunc
+	*consumertest.TracesSink
+}
+
+type forwardConnector struct {
+	component.StartFunc
+	component.ShutdownFunc
+	consumer.Traces
+}
+
+// TestBatchProcessorFanout runs the processor in a collector where a
+// receiver is shared by an exporter and by a connector forwarding to
+// the pipeline of the processor, so that the processor receives data
+// marked read-only by the fan-out.
+func TestBatchProcessorFanout(t *testing.T) {
+	rcv := &fanoutReceiver{}
+	sinks := map[component.ID]*consumertest.TracesSink{
+		component.NewIDWithName("sink", "direct"):  new(consumertest.TracesSink),
+		component.NewIDWithName("sink", "batched"): new(consumertest.TracesSink),
+	}
+
+	receiverFactory := receiver.NewFactory("fanout", func() component.Config { return &struct{}{} },
+		receiver.WithTraces(func(_ context.Context, _ receiver.CreateSettings, _ component.Config, next consumer.Traces) (receiver.Traces, error) {
+			rcv.Traces = next
+			return rcv, nil
+		}, component.StabilityLevelDevelopment))
+	exporterFactory := exporter.NewFactory("sink", func() component.Config { return &struct{}{} },
+		exporter.WithTraces(func(_ context.Context, set exporter.CreateSettings, _ component.Config) (exporter.Traces, error) {
+			return &sinkExporter{TracesSink: sinks[set.ID]}, nil
+		}, component.StabilityLevelDevelopment))
+	connectorFactory := connector.NewFactory("forward", func() component.Config { return &struct{}{} },
+		connector.WithTracesToTraces(func(_ context.Context, _ connector.CreateSettings, _ component.Config, next consumer.Traces) (connector.Traces, error) {
+			return &forwardConnector{Traces: next}, nil
+		}, component.StabilityLevelDevelopment))
+	processorFactory := NewFactory()
+	batchCfg := processorFactory.CreateDefaultConfig().(*Config)
+	batchCfg.SendBatchSize = 4
+	batchCfg.Timeout = time.Hour
+
+	set := service.Settings{
+		BuildInfo: component.NewDefaultBuildInfo(),
+		Receivers: receiver.NewBuilder(
+			map[component.ID]component.Config{component.NewID("fanout"): receiverFactory.CreateDefaultConfig()},
+			map[component.Type]receiver.Factory{receiverFactory.Type(): receiverFactory}),
+		Processors: processor.NewBuilder(
+			map[component.ID]component.Config{component.NewID(typeStr): batchCfg},
+			map[component.Type]processor.Factory{processorFactory.Type(): processorFactory}),
+		Exporters: exporter.NewBuilder(
+			map[component.ID]component.Config{
+				component.NewIDWithName("sink", "direct"):  exporterFactory.CreateDefaultConfig(),
+				component.NewIDWithName("sink", "batched"): exporterFactory.CreateDefaultConfig(),
+			},
+			map[component.Type]exporter.Factory{exporterFactory.Type(): exporterFactory}),
+		Connectors: connector.NewBuilder(
+			map[component.ID]component.Config{component.NewID("forward"): connectorFactory.CreateDefaultConfig()},
+			map[component.Type]connector.Factory{connectorFactory.Type(): connectorFactory}),
+		Extensions: extension.NewBuilder(nil, nil),
+	}
+	cfg := service.Config{
+		Telemetry: telemetry.Config{
+			Logs:    telemetry.LogsConfig{Encoding: "console", OutputPaths: []string{"stderr"}},
+			Metrics: telemetry.MetricsConfig{Level: configtelemetry.LevelNone},
+		},
+		Pipelines: map[component.ID]*service.PipelineConfig{
+			component.NewIDWithName("traces", "direct"): {
+				Receivers: []component.ID{component.NewID("fanout")},
+				Exporters: []component.ID{component.NewIDWithName("sink", "direct")},
+			},
+			component.NewIDWithName("traces", "shared"): {
+				Receivers: []component.ID{component.NewID("fanout")},
+				Exporters: []component.ID{component.NewID("forward")},
+			},
+			component.NewIDWithName("traces", "batched"): {
+				Receivers:  []component.ID{component.NewID("forward")},
+				Processors: []component.ID{component.NewID(typeStr)},
+				Exporters:  []component.ID{component.NewIDWithName("sink", "batched")},
+			},
+		},
+	}
+
+	srv, err := service.New(context.Background(), set, cfg)
+	require.NoError(t, err)
+	require.NoError(t, srv.Start(context.Background()))
+
+	var sent []ptrace.Traces
+	for i := 0; i < 4; i++ {
+		td := testdata.GenerateTraces(2)
+		sent = append(sent, testdata.GenerateTraces(2))
+		require.NoError(t, rcv.ConsumeTraces(context.Background(), td))
+		assert.True(t, td.IsReadOnly())
+	}
+	require.NoError(t, srv.Shutdown(context.Background()))
+
+	direct := sinks[component.NewIDWithName("sink", "direct")]
+	require.Len(t, direct.AllTraces(), len(sent))
+	for i, td := range direct.AllTraces() {
+		// The data shared with the processor is left unchanged.
+		assert.Equal(t, sent[i].ResourceSpans(), td.ResourceSpans())
+	}
+	batched := sinks[component.NewIDWithName("sink", "batched")]
+	assert.Equal(t, 2*len(sent), batched.SpanCount())
+	for _, td := range batched.AllTraces() {
+		assert.False(t, td.IsReadOnly())
+	}
+}
+
+func TestBatchProcessorReadOnlyCopies(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := new(consumertest.TracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 4
+	cfg.Timeout = time.Hour
+	bp, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+
+	for i := 0; i < 4; i++ {
+		td := testdata.GenerateTraces(2)
+		if i%2 == 0 {
+			td.MarkReadOnly()
+		}
+		require.NoError(t, bp.ConsumeTraces(context.Background(), td))
+	}
+	require.NoError(t, bp.Shutdown(context.Background()))
+
+	assert.Equal(t, 8, sink.SpanCount())
+	tel.assertMetrics(t, expectedMetrics{
+		sendCount:      2,
+		sendSizeSum:    8,
+		sizeTrigger:    2,
+		readOnlyCopies: 2,
+	})
+}
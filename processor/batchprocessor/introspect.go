@@ -0,0 +1,30 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor // import "go.opentelemetry.io/collector/processor/batchprocessor"
+
+import "go.opentelemetry.io/collector/processor/batchprocessor/batching"
+
+type (
+	// Introspector is implemented by the batch processor and the
+	// batch connector, for debugging pages and other components to
+	// list the data pending in their batchers.  The connector is
+	// found among the exporters returned by
+	// component.Host.GetExporters.
+	Introspector = batching.Introspector
+	// Snapshot is the state of the batchers of a processor.
+	Snapshot = batching.Snapshot
+	// BatcherSnapshot is the state of a batcher.
+	BatcherSnapshot = batching.BatcherSnapshot
+)
@@ -0,0 +1,95 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/internal/testdata"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+func TestBatchProcessorSnapshot(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 100
+	cfg.Timeout = time.Hour
+	cfg.MetadataKeys = []string{"tenant"}
+	bp, err := newBatchLogsProcessor(processortest.NewNopCreateSettings(), new(consumertest.LogsSink), cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+
+	for _, tenant := range []string{"b", "a", "b"} {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"tenant": {tenant}}),
+		})
+		require.NoError(t, bp.ConsumeLogs(ctx, testdata.GenerateLogs(2)))
+	}
+
+	var snap Snapshot
+	assert.Eventually(t, func() bool {
+		snap = bp.Snapshot()
+		return len(snap.Batchers) == 2 && snap.Batchers[0].Items+snap.Batchers[1].Items == 6
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, component.DataTypeLogs, snap.DataType)
+	// Ordered by attributes.
+	assert.Equal(t, []attribute.KeyValue{attribute.String("tenant", "a")}, snap.Batchers[0].Attributes)
+	assert.Equal(t, 2, snap.Batchers[0].Items)
+	assert.Equal(t, []attribute.KeyValue{attribute.String("tenant", "b")}, snap.Batchers[1].Attributes)
+	assert.Equal(t, 4, snap.Batchers[1].Items)
+	for _, b := range snap.Batchers {
+		assert.NotZero(t, b.ID)
+		assert.Zero(t, b.Queued)
+		assert.NotZero(t, b.QueueCapacity)
+		assert.NoError(t, b.LastError)
+	}
+
+	require.NoError(t, bp.Shutdown(context.Background()))
+	snap = bp.Snapshot()
+	assert.Zero(t, snap.Batchers[0].Items)
+	assert.Zero(t, snap.Batchers[1].Items)
+}
+
+func TestBatchProcessorSnapshotLastError(t *testing.T) {
+	errExport := errors.New("export failed")
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 2
+	cfg.Timeout = time.Hour
+	cfg.SyncConsume = true
+	bp, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), consumertest.NewErr(errExport), cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+
+	snap := bp.Snapshot()
+	require.Len(t, snap.Batchers, 1)
+	assert.Empty(t, snap.Batchers[0].Attributes)
+	assert.NoError(t, snap.Batchers[0].LastError)
+
+	assert.Error(t, bp.ConsumeTraces(context.Background(), testdata.GenerateTraces(2)))
+	snap = bp.Snapshot()
+	assert.ErrorIs(t, snap.Batchers[0].LastError, errExport)
+	assert.Less(t, snap.Batchers[0].SinceLastExport, time.Minute)
+	require.NoError(t, bp.Shutdown(context.Background()))
+}
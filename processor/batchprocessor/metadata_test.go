@@ -0,0 +1,246 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/internal/testdata"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+func TestBatchProcessorMetadataNormalize(t *testing.T) {
+	sink := &metadataKeysTracesSink{
+		TracesSink:       &consumertest.TracesSink{},
+		spanCountByScope: map[string]int{},
+	}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"x-tenant", "x-region"}
+	cfg.MetadataKeySettings = []MetadataKeySettings{
+		{Key: "x-tenant", Normalize: []string{"prefix:4"}},
+	}
+	// The transformer sees values after the built-in normalizations.
+	transformer := func(key string, values []string) []string {
+		if key != "x-region" {
+			return values
+		}
+		for i, v := range values {
+			values[i] = strings.TrimSuffix(v, "-1")
+		}
+		return values
+	}
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg, WithMetadataTransformer(transformer))
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	for _, md := range []map[string][]string{
+		{"x-tenant": {"acme-token-1"}, "x-region": {"us-1"}},
+		{"x-tenant": {"acme-token-2"}, "x-region": {"us"}},
+		{"x-tenant": {"glob-token-1"}, "x-region": {"us-1"}},
+	} {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(md),
+		})
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(2)))
+	}
+	assert.Equal(t, 2, batcher.MetadataCardinality())
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	// The outgoing metadata carries the normalized values.
+	assert.Equal(t, map[string]int{
+		"x-region=[us],x-tenant=[acme]": 4,
+		"x-region=[us],x-tenant=[glob]": 2,
+	}, sink.spanCountByScope)
+}
+
+func TestBatchProcessorMetadataAllowedValues(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := &metadataKeysTracesSink{
+		TracesSink:       &consumertest.TracesSink{},
+		spanCountByScope: map[string]int{},
+	}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"x-tenant"}
+	cfg.MetadataCardinalityLimit = 3
+	cfg.MetadataKeySettings = []MetadataKeySettings{
+		{Key: "x-tenant", AllowedValues: []string{"a", "b"}},
+	}
+	batcher, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	// Garbage values do not consume the cardinality limit.
+	for _, tenant := range []string{"a", "b", "garbage1", "garbage2", "garbage3", "a"} {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"x-tenant": {tenant}}),
+		})
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(2)))
+	}
+	assert.Equal(t, 3, batcher.MetadataCardinality())
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	assert.Equal(t, map[string]int{
+		"x-tenant=[a]":         4,
+		"x-tenant=[b]":         2,
+		"x-tenant=[__other__]": 6,
+	}, sink.spanCountByScope)
+
+	tel.assertMetrics(t, expectedMetrics{
+		otherValuesItems: 6,
+	})
+}
+
+func TestBatchProcessorMetadataValueLimit(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := &metadataKeysTracesSink{
+		TracesSink:       &consumertest.TracesSink{},
+		spanCountByScope: map[string]int{},
+	}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"x-tenant"}
+	cfg.MetadataValueLimit = MetadataValueLimit{MaxLength: 4, MaxCount: 1}
+	batcher, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	// Values past the limits share a batcher, rather than each having
+	// their own.
+	for _, vals := range [][]string{
+		{"acme-" + strings.Repeat("x", 1000)},
+		{"acme-" + strings.Repeat("y", 1000)},
+		{"a", "b"},
+		{"a"},
+	} {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"x-tenant": vals}),
+		})
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(2)))
+	}
+	assert.Equal(t, 2, batcher.MetadataCardinality())
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	assert.Equal(t, map[string]int{
+		"x-tenant=[acme...]": 4,
+		"x-tenant=[a]":       4,
+	}, sink.spanCountByScope)
+
+	tel.assertMetrics(t, expectedMetrics{
+		truncatedValues: 3,
+	})
+}
+
+func TestBatchProcessorMetadataKeyLimit(t *testing.T) {
+	tel := setupTelemetry(t)
+	sink := &metadataKeysTracesSink{
+		TracesSink:       &consumertest.TracesSink{},
+		spanCountByScope: map[string]int{},
+	}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"x-tenant", "x-source"}
+	cfg.MetadataKeySettings = []MetadataKeySettings{
+		{Key: "x-source", Limit: 2},
+	}
+	batcher, err := newBatchTracesProcessor(tel.NewProcessorCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	for _, req := range [][2]string{
+		{"a", "s1"},
+		{"b", "s1"},
+		{"a", "s2"},
+		{"a", "s3"},
+		{"b", "s4"},
+		{"b", "s2"},
+	} {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{
+				"x-tenant": {req[0]},
+				"x-source": {req[1]},
+			}),
+		})
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(1)))
+	}
+	// The gauges are observed until shutdown.
+	tel.assertMetrics(t, expectedMetrics{
+		metadataKeyCardinality: map[string]float64{"x-source": 2},
+	})
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	// Values beyond the limit fold into the overflow bucket of the
+	// limited key, while the other key keeps its values.
+	assert.Equal(t, map[string]int{
+		"x-source=[s1],x-tenant=[a]":           1,
+		"x-source=[s1],x-tenant=[b]":           1,
+		"x-source=[s2],x-tenant=[a]":           1,
+		"x-source=[s2],x-tenant=[b]":           1,
+		"x-source=[__overflow__],x-tenant=[a]": 1,
+		"x-source=[__overflow__],x-tenant=[b]": 1,
+	}, sink.spanCountByScope)
+}
+
+func TestBatchProcessorMetadataCaseInsensitiveValues(t *testing.T) {
+	sink := &metadataKeysTracesSink{
+		TracesSink:       &consumertest.TracesSink{},
+		spanCountByScope: map[string]int{},
+	}
+	size := uint32(4)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"x-tenant"}
+	cfg.MetadataKeySettings = []MetadataKeySettings{
+		{Key: "x-tenant", CaseInsensitiveValues: true, AllowedValues: []string{"ACME"}},
+	}
+	cfg.Overrides = []BatchOverride{{
+		Metadata:      map[string]string{"x-tenant": "Acme"},
+		SendBatchSize: &size,
+	}}
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	for _, tenant := range []string{"ACME", "acme"} {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"X-Tenant": {tenant}}),
+		})
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(2)))
+	}
+
+	// Both values share one batcher, which the override applies to.
+	assert.Equal(t, 1, batcher.MetadataCardinality())
+	require.Eventually(t, func() bool {
+		return sink.SpanCount() == 4
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	assert.Equal(t, map[string]int{"x-tenant=[acme]": 4}, sink.spanCountByScope)
+}
@@ -16,230 +16,376 @@ package batchprocessor
 
 import (
 	"context"
-	"fmt"
 	"math"
-	"net/http"
-	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
-	ocprom "contrib.go.opencensus.io/exporter/prometheus"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-	io_prometheus_client "github.com/prometheus/client_model/go"
-	"github.com/prometheus/common/expfmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"go.opencensus.io/stats/view"
-	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/attribute"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/metric/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	"go.opentelemetry.io/otel/sdk/resource"
 
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/internal/testdata"
 	"go.opentelemetry.io/collector/obsreport"
 	"go.opentelemetry.io/collector/processor"
 	"go.opentelemetry.io/collector/processor/processortest"
 )
 
-func TestBatchProcessorMetrics(t *testing.T) {
-	viewNames := []string{
-		"batch_size_trigger_send",
-		"timeout_trigger_send",
-		"batch_send_size",
-		"batch_send_size_bytes",
+func TestBatchProcessorTelemetryLevels(t *testing.T) {
+	tests := []struct {
+		level    configtelemetry.Level
+		expected map[string]bool
+	}{
+		{
+			level: configtelemetry.LevelNone,
+			expected: map[string]bool{
+				"batch_send_size": false, "send_latency": false, "batch_age": false, "batch_send_size_bytes": false,
+			},
+		},
+		{
+			level: configtelemetry.LevelBasic,
+			expected: map[string]bool{
+				"batch_send_size": true, "send_latency": false, "batch_age": false, "batch_send_size_bytes": false,
+			},
+		},
+		{
+			level: configtelemetry.LevelNormal,
+			expected: map[string]bool{
+				"batch_send_size": true, "send_latency": true, "batch_age": true, "batch_send_size_bytes": false,
+			},
+		},
+		{
+			level: configtelemetry.LevelDetailed,
+			expected: map[string]bool{
+				"batch_send_size": true, "send_latency": true, "batch_age": true, "batch_send_size_bytes": true,
+			},
+		},
 	}
-	views := metricViews()
-	for i, viewName := range viewNames {
-		assert.Equal(t, "processor/batch/"+viewName, views[i].Name)
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			tel := setupTelemetry(t)
+			cfg := createDefaultConfig().(*Config)
+			cfg.SendBatchSize = 2
+			cfg.Timeout = time.Hour
+			cfg.SyncConsume = true
+			set := tel.NewProcessorCreateSettings()
+			set.MetricsLevel = tt.level
+			bp, err := newBatchTracesProcessor(set, consumertest.NewNop(), cfg)
+			require.NoError(t, err)
+			require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+			require.NoError(t, bp.ConsumeTraces(context.Background(), testdata.GenerateTraces(2)))
+			require.NoError(t, bp.Shutdown(context.Background()))
+
+			metrics := tel.gather(t)
+			if tt.level == configtelemetry.LevelNone {
+				assert.Empty(t, metrics)
+			}
+			for name, expected := range tt.expected {
+				_, ok := metrics[name]
+				assert.Equal(t, expected, ok, name)
+			}
+		})
 	}
 }
 
-type testTelemetry struct {
-	meter         view.Meter
-	promHandler   http.Handler
-	useOtel       bool
-	meterProvider *sdkmetric.MeterProvider
+func TestBatchProcessorTelemetryIncludeMetadataLevel(t *testing.T) {
+	tel := setupTelemetry(t)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 2
+	cfg.Timeout = time.Hour
+	cfg.SyncConsume = true
+	cfg.MetadataKeys = []string{"tenant"}
+	cfg.TelemetryIncludeMetadata = true
+	set := tel.NewProcessorCreateSettings()
+	set.MetricsLevel = configtelemetry.LevelBasic
+	bp, err := newBatchTracesProcessor(set, consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, bp.ConsumeTraces(context.Background(), testdata.GenerateTraces(2)))
+	require.NoError(t, bp.Shutdown(context.Background()))
+
+	// The attributes identifying batchers are only added from the
+	// normal level.
+	tel.assertMetrics(t, expectedMetrics{
+		sendCount:   1,
+		sendSizeSum: 2,
+		sizeTrigger: 1,
+	})
 }
 
 type expectedMetrics struct {
-	// processor_batch_batch_send_size_count
-	// processor_batch_batch_send_size_bytes_count
+	// send_size_count
+	// send_size_bytes_count
 	sendCount float64
-	// processor_batch_batch_send_size_sum
+	// send_size_sum
 	sendSizeSum float64
-	// processor_batch_batch_send_size_bytes_sum
+	// send_size_bytes_sum
 	sendSizeBytesSum float64
-	// processor_batch_batch_size_trigger_send
+	// size_trigger_send
 	sizeTrigger float64
-	// processor_batch_batch_timeout_trigger_send
+	// timeout_trigger_send
 	timeoutTrigger float64
+	// bypass_trigger_send
+	bypassTrigger float64
+	// resource_change_trigger_send
+	resourceChangeTrigger float64
+	// shutdown_trigger_send
+	shutdownTrigger float64
+	// dropped_items, by reason
+	droppedItems map[string]float64
+	// dropped_bytes, by reason
+	droppedBytes map[string]float64
+	// metadata_other_items
+	otherValuesItems float64
+	// metadata_truncated_values
+	truncatedValues float64
+	// metadata_overflow_items
+	overflowItems float64
+	// metadata_key_cardinality, by metadata_key
+	metadataKeyCardinality map[string]float64
+	// in_flight_items
+	inFlightItems float64
+	// dead_letter_items
+	deadLetterItems float64
+	// send_failed, by permanence
+	sendFailedItems map[string]float64
+	// send_failed_bytes, by permanence
+	sendFailedBytes map[string]float64
+	// items_rejected
+	rejectedItems float64
+	// metric_merge_conflicts
+	metricMergeConflicts float64
+	// read_only_copies
+	readOnlyCopies float64
+	// batchers_created
+	batchersCreated float64
+	// batchers_removed, by reason
+	batchersRemoved map[string]float64
+	// items_received, by signal
+	receivedItems map[string]float64
+	// size_trigger_split, by signal
+	sizeSplit map[string]float64
+	// split_leftover_items, by signal
+	splitLeftoverItems map[string]float64
+	// queue_length
+	queueLength float64
+	// queue_capacity
+	queueCapacity float64
+	// pending_items
+	pendingItems float64
 }
 
-func telemetryTest(t *testing.T, testFunc func(t *testing.T, tel testTelemetry, useOtel bool)) {
-	t.Run("WithOC", func(t *testing.T) {
-		testFunc(t, setupTelemetry(t, false), false)
-	})
-
-	t.Run("WithOTel", func(t *testing.T) {
-		testFunc(t, setupTelemetry(t, true), true)
-	})
+// testTelemetry collects the metrics of the processors created with the
+// settings it returns with an in-memory reader.
+type testTelemetry struct {
+	reader        sdkmetric.Reader
+	meterProvider *sdkmetric.MeterProvider
 }
 
-func setupTelemetry(t *testing.T, useOtel bool) testTelemetry {
-	// Unregister the views first since they are registered by the init, this way we reset them.
-	views := metricViews()
-	view.Unregister(views...)
-	require.NoError(t, view.Register(views...))
-
-	telemetry := testTelemetry{
-		meter:   view.NewMeter(),
-		useOtel: useOtel,
-	}
-
-	if useOtel {
-		promReg := prometheus.NewRegistry()
-		exporter, err := otelprom.New(otelprom.WithRegisterer(promReg), otelprom.WithoutUnits(), otelprom.WithoutScopeInfo())
-		require.NoError(t, err)
-
-		telemetry.meterProvider = sdkmetric.NewMeterProvider(
+func setupTelemetry(t *testing.T) testTelemetry {
+	reader := sdkmetric.NewManualReader()
+	tel := testTelemetry{
+		reader: reader,
+		meterProvider: sdkmetric.NewMeterProvider(
 			sdkmetric.WithResource(resource.Empty()),
-			sdkmetric.WithReader(exporter),
+			sdkmetric.WithReader(reader),
 			sdkmetric.WithView(batchViews()...),
-		)
-
-		telemetry.promHandler = promhttp.HandlerFor(promReg, promhttp.HandlerOpts{})
-
-		t.Cleanup(func() { assert.NoError(t, telemetry.meterProvider.Shutdown(context.Background())) })
-	} else {
-		promReg := prometheus.NewRegistry()
-
-		ocExporter, err := ocprom.NewExporter(ocprom.Options{Registry: promReg})
-		require.NoError(t, err)
-
-		telemetry.promHandler = ocExporter
-
-		view.RegisterExporter(ocExporter)
-		t.Cleanup(func() { view.UnregisterExporter(ocExporter) })
+		),
 	}
-
-	return telemetry
+	t.Cleanup(func() { assert.NoError(t, tel.meterProvider.Shutdown(context.Background())) })
+	return tel
 }
 
 func (tt *testTelemetry) NewProcessorCreateSettings() processor.CreateSettings {
 	settings := processortest.NewNopCreateSettings()
 	settings.MeterProvider = tt.meterProvider
 	settings.ID = component.NewID(typeStr)
+	// The default level of the service.
+	settings.MetricsLevel = configtelemetry.LevelBasic
 
 	return settings
 }
 
-func (tt *testTelemetry) assertMetrics(t *testing.T, expected expectedMetrics) {
-	for _, v := range metricViews() {
-		// Forces a flush for the opencensus view data.
-		_, _ = view.RetrieveData(v.Name)
-	}
-
-	req, err := http.NewRequest(http.MethodGet, "/metrics", nil)
-	require.NoError(t, err)
+// point is a data point of any of the metric types recorded by the
+// processor: value is the value of sums and gauges, and the other
+// fields those of histograms.
+type point struct {
+	attrs        attribute.Set
+	value        float64
+	count        uint64
+	sum          float64
+	bounds       []float64
+	bucketCounts []uint64
+}
 
-	rr := httptest.NewRecorder()
-	tt.promHandler.ServeHTTP(rr, req)
+// gather returns the data points of the metrics collected from the
+// processors, by name without the "processor/batch/" prefix.
+func (tt *testTelemetry) gather(t *testing.T) map[string][]point {
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, tt.reader.Collect(context.Background(), &rm))
+	prefix := obsreport.BuildProcessorCustomMetricName(typeStr, "")
+	metrics := map[string][]point{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			var points []point
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					points = append(points, point{attrs: dp.Attributes, value: float64(dp.Value)})
+				}
+			case metricdata.Gauge[int64]:
+				for _, dp := range data.DataPoints {
+					points = append(points, point{attrs: dp.Attributes, value: float64(dp.Value)})
+				}
+			case metricdata.Gauge[float64]:
+				for _, dp := range data.DataPoints {
+					points = append(points, point{attrs: dp.Attributes, value: dp.Value})
+				}
+			case metricdata.Histogram[int64]:
+				for _, dp := range data.DataPoints {
+					points = append(points, point{attrs: dp.Attributes, count: dp.Count, sum: float64(dp.Sum), bounds: dp.Bounds, bucketCounts: dp.BucketCounts})
+				}
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					points = append(points, point{attrs: dp.Attributes, count: dp.Count, sum: dp.Sum, bounds: dp.Bounds, bucketCounts: dp.BucketCounts})
+				}
+			default:
+				require.Failf(t, "unexpected metric type", "metric %q of type %T", m.Name, m.Data)
+			}
+			if len(points) > 0 {
+				metrics[strings.TrimPrefix(m.Name, prefix)] = points
+			}
+		}
+	}
+	return metrics
+}
 
-	var parser expfmt.TextParser
-	metrics, err := parser.TextToMetricFamilies(rr.Body)
-	require.NoError(t, err)
+func (tt *testTelemetry) assertMetrics(t *testing.T, expected expectedMetrics) {
+	metrics := tt.gather(t)
 
 	if expected.sendSizeBytesSum > 0 {
-		name := "processor_batch_batch_send_size_bytes"
-		metric := tt.getMetric(t, name, io_prometheus_client.MetricType_HISTOGRAM, metrics)
-
-		assertFloat(t, expected.sendSizeBytesSum, metric.GetHistogram().GetSampleSum(), name)
-		assertFloat(t, expected.sendCount, float64(metric.GetHistogram().GetSampleCount()), name)
-
-		tt.assertBoundaries(t,
-			[]float64{10, 25, 50, 75, 100, 250, 500, 750, 1000, 2000, 3000, 4000, 5000, 6000, 7000, 8000, 9000, 10000, 20000, 30000, 50000,
-				100_000, 200_000, 300_000, 400_000, 500_000, 600_000, 700_000, 800_000, 900_000,
-				1000_000, 2000_000, 3000_000, 4000_000, 5000_000, 6000_000, 7000_000, 8000_000, 9000_000, math.Inf(1)},
-			metric.GetHistogram(),
-			name,
-		)
+		name := "batch_send_size_bytes"
+		p := getMetric(t, name, metrics)
+
+		assertFloat(t, expected.sendSizeBytesSum, p.sum, name)
+		assertFloat(t, expected.sendCount, float64(p.count), name)
+		assert.Equal(t, []float64{10, 25, 50, 75, 100, 250, 500, 750, 1000, 2000, 3000, 4000, 5000, 6000, 7000, 8000, 9000, 10000, 20000, 30000, 50000,
+			100_000, 200_000, 300_000, 400_000, 500_000, 600_000, 700_000, 800_000, 900_000,
+			1000_000, 2000_000, 3000_000, 4000_000, 5000_000, 6000_000, 7000_000, 8000_000, 9000_000}, p.bounds, name)
 	}
 
 	if expected.sendSizeSum > 0 {
-		name := "processor_batch_batch_send_size"
-		metric := tt.getMetric(t, name, io_prometheus_client.MetricType_HISTOGRAM, metrics)
-
-		assertFloat(t, expected.sendSizeSum, metric.GetHistogram().GetSampleSum(), name)
-		assertFloat(t, expected.sendCount, float64(metric.GetHistogram().GetSampleCount()), name)
-
-		tt.assertBoundaries(t,
-			[]float64{10, 25, 50, 75, 100, 250, 500, 750, 1000, 2000, 3000, 4000, 5000, 6000, 7000, 8000, 9000, 10000, 20000, 30000, 50000, 100000, math.Inf(1)},
-			metric.GetHistogram(),
-			name,
-		)
-	}
-
-	if expected.sizeTrigger > 0 {
-		name := "processor_batch_batch_size_trigger_send"
-		metric := tt.getMetric(t, name, io_prometheus_client.MetricType_COUNTER, metrics)
-
-		assertFloat(t, expected.sizeTrigger, metric.GetCounter().GetValue(), name)
-	}
-
-	if expected.timeoutTrigger > 0 {
-		name := "processor_batch_timeout_trigger_send"
-		metric := tt.getMetric(t, name, io_prometheus_client.MetricType_COUNTER, metrics)
+		name := "batch_send_size"
+		p := getMetric(t, name, metrics)
 
-		assertFloat(t, expected.timeoutTrigger, metric.GetCounter().GetValue(), name)
+		assertFloat(t, expected.sendSizeSum, p.sum, name)
+		assertFloat(t, expected.sendCount, float64(p.count), name)
+		assert.Equal(t, []float64{10, 25, 50, 75, 100, 250, 500, 750, 1000, 2000, 3000, 4000, 5000, 6000, 7000, 8000, 9000, 10000, 20000, 30000, 50000, 100000}, p.bounds, name)
 	}
-}
 
-func (tt *testTelemetry) assertBoundaries(t *testing.T, expected []float64, histogram *io_prometheus_client.Histogram, metric string) {
-	var got []float64
-	for _, bucket := range histogram.GetBucket() {
-		got = append(got, bucket.GetUpperBound())
+	for name, value := range map[string]float64{
+		"batch_size_trigger_send":      expected.sizeTrigger,
+		"timeout_trigger_send":         expected.timeoutTrigger,
+		"bypass_trigger_send":          expected.bypassTrigger,
+		"resource_change_trigger_send": expected.resourceChangeTrigger,
+		"shutdown_trigger_send":        expected.shutdownTrigger,
+		"batchers_created":             expected.batchersCreated,
+		"metadata_other_items":         expected.otherValuesItems,
+		"metadata_truncated_values":    expected.truncatedValues,
+		"metadata_overflow_items":      expected.overflowItems,
+		"dead_letter_items":            expected.deadLetterItems,
+		"batch_items_rejected":         expected.rejectedItems,
+		"metric_merge_conflicts":       expected.metricMergeConflicts,
+		"read_only_copies":             expected.readOnlyCopies,
+		"in_flight_items":              expected.inFlightItems,
+	} {
+		if value > 0 {
+			assertFloat(t, value, getMetric(t, name, metrics).value, name)
+		}
 	}
 
-	if len(expected) != len(got) {
-		assert.Failf(t, "different boundaries size", "metric '%s' expected boundaries '%x' but got '%x'", metric, expected, got)
-		return
+	for name, byAttr := range map[string]struct {
+		attr     string
+		expected map[string]float64
+	}{
+		"dropped_items":            {"reason", expected.droppedItems},
+		"dropped_bytes":            {"reason", expected.droppedBytes},
+		"batchers_removed":         {"reason", expected.batchersRemoved},
+		"items_received":           {"signal", expected.receivedItems},
+		"batch_size_trigger_split": {"signal", expected.sizeSplit},
+		"split_leftover_items":     {"signal", expected.splitLeftoverItems},
+		"metadata_key_cardinality": {"metadata_key", expected.metadataKeyCardinality},
+		"batch_send_failed":        {"permanence", expected.sendFailedItems},
+		"batch_send_failed_bytes":  {"permanence", expected.sendFailedBytes},
+	} {
+		if byAttr.expected != nil {
+			assert.Equal(t, byAttr.expected, valuesByAttr(metrics[name], byAttr.attr), name)
+		}
 	}
 
-	for i := range expected {
-		if math.Abs(expected[i]-got[i]) > 0.00001 {
-			assert.Failf(t, "unexpected boundary", "boundary for metric '%s' did no match, expected '%f' got '%f'", metric, expected[i], got[i])
+	if expected.queueCapacity > 0 {
+		for name, value := range map[string]float64{
+			"queue_length":   expected.queueLength,
+			"queue_capacity": expected.queueCapacity,
+			"pending_items":  expected.pendingItems,
+		} {
+			assertFloat(t, value, getMetric(t, name, metrics).value, name)
 		}
 	}
-
 }
 
-func (tt *testTelemetry) getMetric(t *testing.T, name string, mtype io_prometheus_client.MetricType, got map[string]*io_prometheus_client.MetricFamily) *io_prometheus_client.Metric {
-	if tt.useOtel && mtype == io_prometheus_client.MetricType_COUNTER {
-		// OTel Go suffixes counters with `_total`
-		name += "_total"
+// sendLatencyCounts returns the number of exports measured by the send
+// latency, by signal, trigger, and outcome.
+func (tt *testTelemetry) sendLatencyCounts(t *testing.T) map[[3]string]uint64 {
+	counts := map[[3]string]uint64{}
+	for _, p := range tt.gather(t)["send_latency"] {
+		signal, _ := p.attrs.Value("signal")
+		trigger, _ := p.attrs.Value("trigger")
+		outcome, _ := p.attrs.Value("outcome")
+		counts[[3]string{signal.AsString(), trigger.AsString(), outcome.AsString()}] = p.count
 	}
-
-	metricFamily, ok := got[name]
-	require.True(t, ok, "expected metric '%s' not found", name)
-	require.Equal(t, mtype, metricFamily.GetType())
-
-	metric, err := getSingleMetric(metricFamily)
-	require.NoError(t, err)
-
-	return metric
+	return counts
 }
 
-func getSingleMetric(metric *io_prometheus_client.MetricFamily) (*io_prometheus_client.Metric, error) {
-	if l := len(metric.Metric); l != 1 {
-		return nil, fmt.Errorf("expected metric '%s' with one set of attributes, but found %d", metric.GetName(), l)
+// batchAges returns the points of the batch age by trigger.
+func (tt *testTelemetry) batchAges(t *testing.T) map[string]point {
+	ages := map[string]point{}
+	for _, p := range tt.gather(t)["batch_age"] {
+		trigger, _ := p.attrs.Value("trigger")
+		ages[trigger.AsString()] = p
 	}
-	first := metric.Metric[0]
+	return ages
+}
 
-	if len(first.Label) != 1 || "processor" != first.Label[0].GetName() || "batch" != first.Label[0].GetValue() {
-		return nil, fmt.Errorf("expected metric '%s' with a single `batch=processor` attribute but got '%s'", metric.GetName(), first.GetLabel())
+// valuesByAttr returns the values of points by the values of one of
+// their attributes, summing those with the same value.
+func valuesByAttr(points []point, attr string) map[string]float64 {
+	got := map[string]float64{}
+	for _, p := range points {
+		if v, ok := p.attrs.Value(attribute.Key(attr)); ok {
+			got[v.Emit()] += p.value
+		}
 	}
+	return got
+}
 
-	return first, nil
+// getMetric returns the single point of a metric, which must only have
+// the attribute of the processor.
+func getMetric(t *testing.T, name string, metrics map[string][]point) point {
+	points, ok := metrics[name]
+	require.True(t, ok, "expected metric '%s' not found", name)
+	require.Len(t, points, 1, "expected metric '%s' with one set of attributes", name)
+	require.Equal(t, attribute.NewSet(attribute.String("processor", "batch")), points[0].attrs,
+		"expected metric '%s' with a single `processor=batch` attribute", name)
+	return points[0]
 }
 
 func assertFloat(t *testing.T, expected, got float64, metric string) {
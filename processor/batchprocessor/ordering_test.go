@@ -0,0 +1,89 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/internal/testdata"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+// orderTracesSink records the span counts of the requests of each
+// tenant in arrival order, delaying the first request.
+type orderTracesSink struct {
+	consumertest.TracesSink
+
+	lock   sync.Mutex
+	calls  int
+	counts map[string][]int
+}
+
+func (s *orderTracesSink) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	s.lock.Lock()
+	s.calls++
+	first := s.calls == 1
+	s.lock.Unlock()
+	if first {
+		time.Sleep(100 * time.Millisecond)
+	}
+	tenant := client.FromContext(ctx).Metadata.Get("tenant")[0]
+	s.lock.Lock()
+	if s.counts == nil {
+		s.counts = map[string][]int{}
+	}
+	s.counts[tenant] = append(s.counts[tenant], td.SpanCount())
+	s.lock.Unlock()
+	return s.TracesSink.ConsumeTraces(ctx, td)
+}
+
+func TestBatchProcessorOrderingPerBatcher(t *testing.T) {
+	sink := new(orderTracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1
+	cfg.MetadataKeys = []string{"tenant"}
+	cfg.MetadataCardinalityLimit = 1
+	cfg.MetadataEvictionPolicy = "lru"
+	cfg.Ordering = "per_batcher"
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	consume := func(tenant string, spanCount int) {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"tenant": {tenant}}),
+		})
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(spanCount)))
+	}
+	// The first export of "a" is delayed, its batcher is evicted by
+	// "b" and created again while it is still flushing.
+	consume("a", 1)
+	consume("a", 2)
+	consume("b", 1)
+	consume("a", 3)
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	assert.Equal(t, []int{1, 2, 3}, sink.counts["a"])
+}
@@ -0,0 +1,167 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor // import "go.opentelemetry.io/collector/processor/batchprocessor"
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/processor/batchprocessor/batching"
+)
+
+// resourceAttrPrefix distinguishes resource attribute keys from
+// metadata keys in the attribute set identifying a batcher.  The
+// prefix cannot appear in a valid metadata key.
+const resourceAttrPrefix = "resource:"
+
+// resourceKeyValues returns the values of keys in a resource's
+// attributes.  As with metadata, a missing attribute and an empty
+// value are treated as distinct cases.
+func resourceKeyValues(keys []string, attrs pcommon.Map) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, len(keys))
+	for i, k := range keys {
+		if v, ok := attrs.Get(k); ok {
+			kvs[i] = attribute.String(resourceAttrPrefix+k, v.AsString())
+		} else {
+			kvs[i] = attribute.StringSlice(resourceAttrPrefix+k, nil)
+		}
+	}
+	return kvs
+}
+
+// partitionResources groups the n resources of a request by their
+// values for keys.  It returns the attributes of each group along with
+// the indexes of the resources belonging to it, in order of first
+// appearance.
+func partitionResources(keys []string, n int, resource func(i int) pcommon.Map) ([][]attribute.KeyValue, [][]int) {
+	var groups [][]attribute.KeyValue
+	var members [][]int
+	index := map[attribute.Set]int{}
+	for i := 0; i < n; i++ {
+		kvs := resourceKeyValues(keys, resource(i))
+		set := attribute.NewSet(kvs...)
+		g, ok := index[set]
+		if !ok {
+			g = len(groups)
+			index[set] = g
+			groups = append(groups, kvs)
+			members = append(members, nil)
+		}
+		members[g] = append(members[g], i)
+	}
+	return groups, members
+}
+
+// partitionTraces partitions td, of n spans.  With keep, td is left
+// unchanged, its resources being copied to the partitions instead of
+// moved when it is split.
+func partitionTraces(keys []string, td ptrace.Traces, n int, keep bool) []batching.Partition {
+	rss := td.ResourceSpans()
+	groups, members := partitionResources(keys, rss.Len(), func(i int) pcommon.Map {
+		return rss.At(i).Resource().Attributes()
+	})
+	switch len(groups) {
+	case 0:
+		return nil
+	case 1:
+		// Common case: the request does not need to be split.
+		return []batching.Partition{{Attrs: groups[0], Item: td, Items: n}}
+	}
+	parts := make([]batching.Partition, len(groups))
+	for g, idxs := range members {
+		part := ptrace.NewTraces()
+		part.ResourceSpans().EnsureCapacity(len(idxs))
+		for _, i := range idxs {
+			if keep {
+				rss.At(i).CopyTo(part.ResourceSpans().AppendEmpty())
+			} else {
+				rss.At(i).MoveTo(part.ResourceSpans().AppendEmpty())
+			}
+		}
+		parts[g] = batching.Partition{Attrs: groups[g], Item: part, Items: part.SpanCount()}
+	}
+	return parts
+}
+
+func partitionMetrics(keys []string, md pmetric.Metrics, n int, keep bool) []batching.Partition {
+	rms := md.ResourceMetrics()
+	groups, members := partitionResources(keys, rms.Len(), func(i int) pcommon.Map {
+		return rms.At(i).Resource().Attributes()
+	})
+	switch len(groups) {
+	case 0:
+		return nil
+	case 1:
+		return []batching.Partition{{Attrs: groups[0], Item: md, Items: n}}
+	}
+	parts := make([]batching.Partition, len(groups))
+	for g, idxs := range members {
+		part := pmetric.NewMetrics()
+		part.ResourceMetrics().EnsureCapacity(len(idxs))
+		for _, i := range idxs {
+			if keep {
+				rms.At(i).CopyTo(part.ResourceMetrics().AppendEmpty())
+			} else {
+				rms.At(i).MoveTo(part.ResourceMetrics().AppendEmpty())
+			}
+		}
+		parts[g] = batching.Partition{Attrs: groups[g], Item: part, Items: part.DataPointCount()}
+	}
+	return parts
+}
+
+func partitionLogs(keys []string, ld plog.Logs, n int, keep bool) []batching.Partition {
+	rls := ld.ResourceLogs()
+	groups, members := partitionResources(keys, rls.Len(), func(i int) pcommon.Map {
+		return rls.At(i).Resource().Attributes()
+	})
+	switch len(groups) {
+	case 0:
+		return nil
+	case 1:
+		return []batching.Partition{{Attrs: groups[0], Item: ld, Items: n}}
+	}
+	parts := make([]batching.Partition, len(groups))
+	for g, idxs := range members {
+		part := plog.NewLogs()
+		part.ResourceLogs().EnsureCapacity(len(idxs))
+		for _, i := range idxs {
+			if keep {
+				rls.At(i).CopyTo(part.ResourceLogs().AppendEmpty())
+			} else {
+				rls.At(i).MoveTo(part.ResourceLogs().AppendEmpty())
+			}
+		}
+		parts[g] = batching.Partition{Attrs: groups[g], Item: part, Items: part.LogRecordCount()}
+	}
+	return parts
+}
+
+// partitionItems partitions item, of n items, as the
+// batching.Settings.Partition of the signals.
+func partitionItems(keys []string, item any, n int, keep bool) []batching.Partition {
+	switch data := item.(type) {
+	case ptrace.Traces:
+		return partitionTraces(keys, data, n, keep)
+	case pmetric.Metrics:
+		return partitionMetrics(keys, data, n, keep)
+	case plog.Logs:
+		return partitionLogs(keys, data, n, keep)
+	}
+	return nil
+}
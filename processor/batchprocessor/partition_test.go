@@ -0,0 +1,117 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+
+	"go.opentelemetry.io/collector/internal/testdata"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestPartitionTracesSingleGroup(t *testing.T) {
+	td := testdata.GenerateTraces(2)
+	td.ResourceSpans().At(0).CopyTo(td.ResourceSpans().AppendEmpty())
+	td.ResourceSpans().At(0).Resource().Attributes().PutStr("tenant", "a")
+	td.ResourceSpans().At(1).Resource().Attributes().PutStr("tenant", "a")
+
+	parts := partitionTraces([]string{"tenant"}, td, td.SpanCount(), false)
+	require.Len(t, parts, 1)
+	// The request is passed through without copying.
+	assert.Equal(t, td, parts[0].Item)
+	assert.Equal(t, td.SpanCount(), parts[0].Items)
+	assert.Equal(t, []attribute.KeyValue{attribute.String("resource:tenant", "a")}, parts[0].Attrs)
+}
+
+func TestPartitionTraces(t *testing.T) {
+	td := ptrace.NewTraces()
+	for _, tenant := range []string{"a", "b", "a", ""} {
+		rs := testdata.GenerateTraces(1).ResourceSpans().At(0)
+		rs.Resource().Attributes().PutStr("tenant", tenant)
+		rs.MoveTo(td.ResourceSpans().AppendEmpty())
+	}
+	rs := testdata.GenerateTraces(1).ResourceSpans().At(0)
+	rs.MoveTo(td.ResourceSpans().AppendEmpty())
+
+	parts := partitionTraces([]string{"tenant"}, td, td.SpanCount(), false)
+	require.Len(t, parts, 4)
+	assert.Equal(t, []attribute.KeyValue{attribute.String("resource:tenant", "a")}, parts[0].Attrs)
+	assert.Equal(t, 2, parts[0].Item.(ptrace.Traces).ResourceSpans().Len())
+	assert.Equal(t, 2, parts[0].Items)
+	assert.Equal(t, []attribute.KeyValue{attribute.String("resource:tenant", "b")}, parts[1].Attrs)
+	assert.Equal(t, 1, parts[1].Item.(ptrace.Traces).ResourceSpans().Len())
+	// Empty and missing values are distinct.
+	assert.Equal(t, []attribute.KeyValue{attribute.String("resource:tenant", "")}, parts[2].Attrs)
+	assert.Equal(t, []attribute.KeyValue{attribute.StringSlice("resource:tenant", nil)}, parts[3].Attrs)
+}
+
+func TestPartitionMetrics(t *testing.T) {
+	md := pmetric.NewMetrics()
+	for _, tenant := range []string{"a", "b", "a"} {
+		rm := testdata.GenerateMetrics(2).ResourceMetrics().At(0)
+		rm.Resource().Attributes().PutStr("tenant", tenant)
+		rm.MoveTo(md.ResourceMetrics().AppendEmpty())
+	}
+
+	parts := partitionMetrics([]string{"tenant"}, md, md.DataPointCount(), false)
+	require.Len(t, parts, 2)
+	assert.Equal(t, 2, parts[0].Item.(pmetric.Metrics).ResourceMetrics().Len())
+	assert.Equal(t, 1, parts[1].Item.(pmetric.Metrics).ResourceMetrics().Len())
+}
+
+func TestPartitionLogs(t *testing.T) {
+	ld := plog.NewLogs()
+	for _, tenant := range []string{"a", "b", "b"} {
+		rl := testdata.GenerateLogs(2).ResourceLogs().At(0)
+		rl.Resource().Attributes().PutStr("tenant", tenant)
+		rl.MoveTo(ld.ResourceLogs().AppendEmpty())
+	}
+
+	parts := partitionLogs([]string{"tenant"}, ld, ld.LogRecordCount(), false)
+	require.Len(t, parts, 2)
+	assert.Equal(t, 2, parts[0].Item.(plog.Logs).LogRecordCount())
+	assert.Equal(t, 4, parts[1].Item.(plog.Logs).LogRecordCount())
+	assert.Equal(t, []int{2, 4}, []int{parts[0].Items, parts[1].Items})
+}
+
+func TestPartitionLogsKeep(t *testing.T) {
+	ld := plog.NewLogs()
+	for _, tenant := range []string{"a", "b", "b"} {
+		rl := testdata.GenerateLogs(2).ResourceLogs().At(0)
+		rl.Resource().Attributes().PutStr("tenant", tenant)
+		rl.MoveTo(ld.ResourceLogs().AppendEmpty())
+	}
+	expected := plog.NewLogs()
+	ld.CopyTo(expected)
+
+	parts := partitionLogs([]string{"tenant"}, ld, ld.LogRecordCount(), true)
+	require.Len(t, parts, 2)
+	assert.Equal(t, 2, parts[0].Item.(plog.Logs).LogRecordCount())
+	assert.Equal(t, 4, parts[1].Item.(plog.Logs).LogRecordCount())
+	// The request is left unchanged.
+	assert.Equal(t, expected, ld)
+}
+
+func TestPartitionEmpty(t *testing.T) {
+	assert.Empty(t, partitionTraces([]string{"tenant"}, ptrace.NewTraces(), 0, false))
+	assert.Empty(t, partitionMetrics([]string{"tenant"}, pmetric.NewMetrics(), 0, false))
+	assert.Empty(t, partitionLogs([]string{"tenant"}, plog.NewLogs(), 0, false))
+}
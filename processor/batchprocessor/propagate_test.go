@@ -0,0 +1,178 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/internal/testdata"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+// contextMetadataTracesSink records the outgoing metadata of every request.
+type contextMetadataTracesSink struct {
+	consumertest.TracesSink
+
+	lock     sync.Mutex
+	metadata []client.Metadata
+}
+
+func (mts *contextMetadataTracesSink) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	mts.lock.Lock()
+	mts.metadata = append(mts.metadata, client.FromContext(ctx).Metadata)
+	mts.lock.Unlock()
+	return mts.TracesSink.ConsumeTraces(ctx, td)
+}
+
+func TestBatchProcessorPropagateAllMetadata(t *testing.T) {
+	sink := &contextMetadataTracesSink{}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"x-tenant"}
+	cfg.PropagateMetadata = "all"
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	for _, md := range []map[string][]string{
+		{"x-tenant": {"a"}, "x-b3-flags": {"1"}},
+		{"x-tenant": {"a"}, "x-b3-flags": {"0"}, "x-request-source": {"ci"}},
+	} {
+		ctx := client.NewContext(context.Background(), client.Info{Metadata: client.NewMetadata(md)})
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(1)))
+	}
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	// Both requests share the batcher of tenant "a", and non-key
+	// headers survive the batched export.
+	require.Len(t, sink.metadata, 1)
+	md := sink.metadata[0]
+	assert.Equal(t, []string{"a"}, md.Get("x-tenant"))
+	assert.Equal(t, []string{"1"}, md.Get("x-b3-flags"))
+	assert.Equal(t, []string{"ci"}, md.Get("x-request-source"))
+}
+
+func TestBatchProcessorPropagateBatchKeysMetadata(t *testing.T) {
+	sink := &contextMetadataTracesSink{}
+	cfg := createDefaultConfig().(*Config)
+	cfg.MetadataKeys = []string{"x-tenant"}
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	ctx := client.NewContext(context.Background(), client.Info{Metadata: client.NewMetadata(map[string][]string{
+		"x-tenant":   {"a"},
+		"x-b3-flags": {"1"},
+	})})
+	require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(1)))
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	require.Len(t, sink.metadata, 1)
+	assert.Equal(t, []string{"x-tenant"}, sink.metadata[0].Keys())
+}
+
+func TestBatchProcessorPropagateClientInfo(t *testing.T) {
+	var lock sync.Mutex
+	var infos []client.Info
+	next, err := consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+		lock.Lock()
+		defer lock.Unlock()
+		infos = append(infos, client.FromContext(ctx))
+		return nil
+	})
+	require.NoError(t, err)
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"x-tenant"}
+	cfg.PropagateClientInfo = []string{"addr", "auth", "source"}
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), next, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+
+	addr := &net.IPAddr{IP: net.IPv4(10, 0, 0, 1)}
+	for _, req := range []struct {
+		tenant string
+		addr   net.Addr
+		source string
+	}{
+		{"a", addr, "otlp"},
+		{"a", addr, "otlp"},
+		{"b", addr, "otlp"},
+		{"b", &net.IPAddr{IP: net.IPv4(10, 0, 0, 2)}, "otlp/internal"},
+	} {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Addr:     req.addr,
+			Source:   req.source,
+			Auth:     fakeAuthData{"subject": "alice"},
+			Metadata: client.NewMetadata(map[string][]string{"x-tenant": {req.tenant}}),
+		})
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(1)))
+	}
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	require.Len(t, infos, 2)
+	byTenant := map[string]client.Info{}
+	for _, info := range infos {
+		byTenant[info.Metadata.Get("x-tenant")[0]] = info
+	}
+	assert.Equal(t, addr, byTenant["a"].Addr)
+	assert.Nil(t, byTenant["b"].Addr)
+	assert.Equal(t, "otlp", byTenant["a"].Source)
+	assert.Empty(t, byTenant["b"].Source)
+	for _, info := range infos {
+		require.NotNil(t, info.Auth)
+		assert.Equal(t, "alice", info.Auth.GetAttribute("subject"))
+	}
+}
+
+func TestBatchProcessorPropagateSource(t *testing.T) {
+	var lock sync.Mutex
+	var sources []string
+	next, err := consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+		lock.Lock()
+		defer lock.Unlock()
+		sources = append(sources, client.FromContext(ctx).Source)
+		return nil
+	})
+	require.NoError(t, err)
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.PropagateClientInfo = []string{"source"}
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), next, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), componenttest.NewNopHost()))
+	ctx := client.NewContext(context.Background(), client.Info{Source: "otlp"})
+	require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(1)))
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	assert.Equal(t, []string{"otlp"}, sources)
+}
@@ -18,67 +18,71 @@ import (
 	"go.opentelemetry.io/collector/pdata/plog"
 )
 
-// splitLogs removes logrecords from the input data and returns a new data of the specified size.
-func splitLogs(size int, src plog.Logs) plog.Logs {
-	if src.LogRecordCount() <= size {
-		return src
-	}
-	totalCopiedLogRecords := 0
+// splitLogsAtResource removes whole resources from the input data, up to
+// size log records, and returns them in a new data along with their number
+// of log records.  A first resource of more than size log records is returned
+// alone.
+func splitLogsAtResource(size int, src plog.Logs) (plog.Logs, int) {
 	dest := plog.NewLogs()
-
+	total := 0
+	done := false
 	src.ResourceLogs().RemoveIf(func(srcRl plog.ResourceLogs) bool {
-		// If we are done skip everything else.
-		if totalCopiedLogRecords == size {
+		if done {
+			return false
+		}
+		n := srcRl.LogRecordCount()
+		if total > 0 && total+n > size {
+			done = true
 			return false
 		}
+		total += n
+		done = total >= size
+		srcRl.MoveTo(dest.ResourceLogs().AppendEmpty())
+		return true
+	})
+	return dest, total
+}
 
-		// If it fully fits
-		srcRlLRC := resourceLRC(srcRl)
-		if (totalCopiedLogRecords + srcRlLRC) <= size {
-			totalCopiedLogRecords += srcRlLRC
+// splitLogsAtScope removes whole scopes from the input data, up to size
+// log records, and returns them in a new data along with their number of
+// log records.  The scopes of a resource are returned under a copy of the
+// resource, which is removed from the input data with its last scope.  A
+// first scope of more than size log records is returned alone.
+func splitLogsAtScope(size int, src plog.Logs) (plog.Logs, int) {
+	dest := plog.NewLogs()
+	total := 0
+	done := false
+	src.ResourceLogs().RemoveIf(func(srcRl plog.ResourceLogs) bool {
+		if done {
+			return false
+		}
+		if srcRl.ScopeLogs().Len() == 0 {
 			srcRl.MoveTo(dest.ResourceLogs().AppendEmpty())
 			return true
 		}
-
-		destRl := dest.ResourceLogs().AppendEmpty()
-		srcRl.Resource().CopyTo(destRl.Resource())
-		srcRl.ScopeLogs().RemoveIf(func(srcIll plog.ScopeLogs) bool {
-			// If we are done skip everything else.
-			if totalCopiedLogRecords == size {
+		var destRl plog.ResourceLogs
+		moved := false
+		srcRl.ScopeLogs().RemoveIf(func(srcSl plog.ScopeLogs) bool {
+			if done {
 				return false
 			}
-
-			// If possible to move all metrics do that.
-			srcIllLRC := srcIll.LogRecords().Len()
-			if size >= srcIllLRC+totalCopiedLogRecords {
-				totalCopiedLogRecords += srcIllLRC
-				srcIll.MoveTo(destRl.ScopeLogs().AppendEmpty())
-				return true
+			n := srcSl.LogRecords().Len()
+			if total > 0 && total+n > size {
+				done = true
+				return false
 			}
-
-			destIll := destRl.ScopeLogs().AppendEmpty()
-			srcIll.Scope().CopyTo(destIll.Scope())
-			srcIll.LogRecords().RemoveIf(func(srcMetric plog.LogRecord) bool {
-				// If we are done skip everything else.
-				if totalCopiedLogRecords == size {
-					return false
-				}
-				srcMetric.MoveTo(destIll.LogRecords().AppendEmpty())
-				totalCopiedLogRecords++
-				return true
-			})
-			return false
+			total += n
+			done = total >= size
+			if !moved {
+				destRl = dest.ResourceLogs().AppendEmpty()
+				srcRl.Resource().CopyTo(destRl.Resource())
+				destRl.SetSchemaUrl(srcRl.SchemaUrl())
+				moved = true
+			}
+			srcSl.MoveTo(destRl.ScopeLogs().AppendEmpty())
+			return true
 		})
 		return srcRl.ScopeLogs().Len() == 0
 	})
-
-	return dest
-}
-
-// resourceLRC calculates the total number of log records in the plog.ResourceLogs.
-func resourceLRC(rs plog.ResourceLogs) (count int) {
-	for k := 0; k < rs.ScopeLogs().Len(); k++ {
-		count += rs.ScopeLogs().At(k).LogRecords().Len()
-	}
-	return
+	return dest, total
 }
@@ -15,70 +15,123 @@
 package batchprocessor // import "go.opentelemetry.io/collector/processor/batchprocessor"
 
 import (
+	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 )
 
-// splitTraces removes spans from the input trace and returns a new trace of the specified size.
-func splitTraces(size int, src ptrace.Traces) ptrace.Traces {
-	if src.SpanCount() <= size {
-		return src
-	}
-	totalCopiedSpans := 0
+// splitTracesAtResource removes whole resources from the input data, up to
+// size spans, and returns them in a new data along with their number
+// of spans.  A first resource of more than size spans is returned
+// alone.
+func splitTracesAtResource(size int, src ptrace.Traces) (ptrace.Traces, int) {
 	dest := ptrace.NewTraces()
-
+	total := 0
+	done := false
 	src.ResourceSpans().RemoveIf(func(srcRs ptrace.ResourceSpans) bool {
-		// If we are done skip everything else.
-		if totalCopiedSpans == size {
+		if done {
 			return false
 		}
-
-		// If it fully fits
-		srcRsSC := resourceSC(srcRs)
-		if (totalCopiedSpans + srcRsSC) <= size {
-			totalCopiedSpans += srcRsSC
-			srcRs.MoveTo(dest.ResourceSpans().AppendEmpty())
-			return true
+		n := srcRs.SpanCount()
+		if total > 0 && total+n > size {
+			done = true
+			return false
 		}
+		total += n
+		done = total >= size
+		srcRs.MoveTo(dest.ResourceSpans().AppendEmpty())
+		return true
+	})
+	return dest, total
+}
 
-		destRs := dest.ResourceSpans().AppendEmpty()
-		srcRs.Resource().CopyTo(destRs.Resource())
-		srcRs.ScopeSpans().RemoveIf(func(srcIls ptrace.ScopeSpans) bool {
-			// If we are done skip everything else.
-			if totalCopiedSpans == size {
-				return false
-			}
+// traceIndex counts the spans of each trace of a batch, in order of
+// first appearance, so that a batch split repeatedly by trace is walked
+// once rather than at every split.
+type traceIndex struct {
+	counts map[pcommon.TraceID]int
+	order  []pcommon.TraceID
+}
 
-			// If possible to move all metrics do that.
-			srcIlsSC := srcIls.Spans().Len()
-			if size-totalCopiedSpans >= srcIlsSC {
-				totalCopiedSpans += srcIlsSC
-				srcIls.MoveTo(destRs.ScopeSpans().AppendEmpty())
-				return true
+func newTraceIndex(td ptrace.Traces) *traceIndex {
+	ti := &traceIndex{counts: map[pcommon.TraceID]int{}}
+	ti.add(td)
+	return ti
+}
+
+// add counts the spans of td.
+func (ti *traceIndex) add(td ptrace.Traces) {
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		sss := rss.At(i).ScopeSpans()
+		for j := 0; j < sss.Len(); j++ {
+			spans := sss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				id := spans.At(k).TraceID()
+				if _, ok := ti.counts[id]; !ok {
+					ti.order = append(ti.order, id)
+				}
+				ti.counts[id]++
 			}
+		}
+	}
+}
 
-			destIls := destRs.ScopeSpans().AppendEmpty()
-			srcIls.Scope().CopyTo(destIls.Scope())
-			srcIls.Spans().RemoveIf(func(srcSpan ptrace.Span) bool {
-				// If we are done skip everything else.
-				if totalCopiedSpans == size {
+// cut removes the first traces from the index and returns them along
+// with their number of spans, up to size spans.  The first trace is
+// always taken.  Unless strict, so is the trace crossing size.
+func (ti *traceIndex) cut(size int, strict bool) (map[pcommon.TraceID]struct{}, int) {
+	selected := map[pcommon.TraceID]struct{}{}
+	total := 0
+	for len(ti.order) > 0 && total < size {
+		id := ti.order[0]
+		n := ti.counts[id]
+		crossing := total > 0 && total+n > size
+		if crossing && strict {
+			break
+		}
+		ti.order = ti.order[1:]
+		delete(ti.counts, id)
+		selected[id] = struct{}{}
+		total += n
+		if crossing {
+			break
+		}
+	}
+	return selected, total
+}
+
+// splitTracesByTrace removes the spans of the selected traces from src
+// and returns them in a new Traces, keeping their resource and scope.
+func splitTracesByTrace(src ptrace.Traces, selected map[pcommon.TraceID]struct{}) ptrace.Traces {
+	dest := ptrace.NewTraces()
+	src.ResourceSpans().RemoveIf(func(srcRs ptrace.ResourceSpans) bool {
+		var destRs ptrace.ResourceSpans
+		hasRs := false
+		srcRs.ScopeSpans().RemoveIf(func(srcSs ptrace.ScopeSpans) bool {
+			var destSs ptrace.ScopeSpans
+			hasSs := false
+			srcSs.Spans().RemoveIf(func(srcSpan ptrace.Span) bool {
+				if _, ok := selected[srcSpan.TraceID()]; !ok {
 					return false
 				}
-				srcSpan.MoveTo(destIls.Spans().AppendEmpty())
-				totalCopiedSpans++
+				if !hasSs {
+					if !hasRs {
+						destRs = dest.ResourceSpans().AppendEmpty()
+						srcRs.Resource().CopyTo(destRs.Resource())
+						destRs.SetSchemaUrl(srcRs.SchemaUrl())
+						hasRs = true
+					}
+					destSs = destRs.ScopeSpans().AppendEmpty()
+					srcSs.Scope().CopyTo(destSs.Scope())
+					destSs.SetSchemaUrl(srcSs.SchemaUrl())
+					hasSs = true
+				}
+				srcSpan.MoveTo(destSs.Spans().AppendEmpty())
 				return true
 			})
-			return false
+			return srcSs.Spans().Len() == 0
 		})
 		return srcRs.ScopeSpans().Len() == 0
 	})
-
 	return dest
 }
-
-// resourceSC calculates the total number of spans in the ptrace.ResourceSpans.
-func resourceSC(rs ptrace.ResourceSpans) (count int) {
-	for k := 0; k < rs.ScopeSpans().Len(); k++ {
-		count += rs.ScopeSpans().At(k).Spans().Len()
-	}
-	return
-}
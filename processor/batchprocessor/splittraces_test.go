@@ -15,144 +15,183 @@
 package batchprocessor
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/internal/testdata"
+	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/processor/batchprocessor/batching"
+	"go.opentelemetry.io/collector/processor/processortest"
 )
 
-func TestSplitTraces_noop(t *testing.T) {
-	td := testdata.GenerateTraces(20)
-	splitSize := 40
-	split := splitTraces(splitSize, td)
-	assert.Equal(t, td, split)
-
-	i := 0
-	td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().RemoveIf(func(_ ptrace.Span) bool {
-		i++
-		return i > 5
-	})
-	assert.EqualValues(t, td, split)
-}
-
-func TestSplitTraces(t *testing.T) {
-	td := testdata.GenerateTraces(20)
-	spans := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
-	for i := 0; i < spans.Len(); i++ {
-		spans.At(i).SetName(getTestSpanName(0, i))
+// interleavedTraces returns a request of resources, each holding one
+// span of every trace of ids.
+func interleavedTraces(resources int, ids ...byte) ptrace.Traces {
+	td := ptrace.NewTraces()
+	for r := 0; r < resources; r++ {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutInt("resource", int64(r))
+		spans := rs.ScopeSpans().AppendEmpty().Spans()
+		for _, id := range ids {
+			spans.AppendEmpty().SetTraceID(pcommon.TraceID{id})
+		}
 	}
-	cp := ptrace.NewTraces()
-	cpSpans := cp.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
-	cpSpans.EnsureCapacity(5)
-	td.ResourceSpans().At(0).Resource().CopyTo(
-		cp.ResourceSpans().At(0).Resource())
-	td.ResourceSpans().At(0).ScopeSpans().At(0).Scope().CopyTo(
-		cp.ResourceSpans().At(0).ScopeSpans().At(0).Scope())
-	spans.At(0).CopyTo(cpSpans.AppendEmpty())
-	spans.At(1).CopyTo(cpSpans.AppendEmpty())
-	spans.At(2).CopyTo(cpSpans.AppendEmpty())
-	spans.At(3).CopyTo(cpSpans.AppendEmpty())
-	spans.At(4).CopyTo(cpSpans.AppendEmpty())
-
-	splitSize := 5
-	split := splitTraces(splitSize, td)
-	assert.Equal(t, splitSize, split.SpanCount())
-	assert.Equal(t, cp, split)
-	assert.Equal(t, 15, td.SpanCount())
-	assert.Equal(t, "test-span-0-0", split.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Name())
-	assert.Equal(t, "test-span-0-4", split.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(4).Name())
-
-	split = splitTraces(splitSize, td)
-	assert.Equal(t, 10, td.SpanCount())
-	assert.Equal(t, "test-span-0-5", split.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Name())
-	assert.Equal(t, "test-span-0-9", split.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(4).Name())
-
-	split = splitTraces(splitSize, td)
-	assert.Equal(t, 5, td.SpanCount())
-	assert.Equal(t, "test-span-0-10", split.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Name())
-	assert.Equal(t, "test-span-0-14", split.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(4).Name())
-
-	split = splitTraces(splitSize, td)
-	assert.Equal(t, 5, td.SpanCount())
-	assert.Equal(t, "test-span-0-15", split.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Name())
-	assert.Equal(t, "test-span-0-19", split.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(4).Name())
+	return td
 }
 
-func TestSplitTracesMultipleResourceSpans(t *testing.T) {
-	td := testdata.GenerateTraces(20)
-	spans := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
-	for i := 0; i < spans.Len(); i++ {
-		spans.At(i).SetName(getTestSpanName(0, i))
-	}
-	// add second index to resource spans
-	testdata.GenerateTraces(20).
-		ResourceSpans().At(0).CopyTo(td.ResourceSpans().AppendEmpty())
-	spans = td.ResourceSpans().At(1).ScopeSpans().At(0).Spans()
-	for i := 0; i < spans.Len(); i++ {
-		spans.At(i).SetName(getTestSpanName(1, i))
+// traceIDs returns the number of spans of each trace of td.
+func traceIDs(td ptrace.Traces) map[pcommon.TraceID]int {
+	ids := map[pcommon.TraceID]int{}
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		sss := rss.At(i).ScopeSpans()
+		for j := 0; j < sss.Len(); j++ {
+			spans := sss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				ids[spans.At(k).TraceID()]++
+			}
+		}
 	}
+	return ids
+}
 
-	splitSize := 5
-	split := splitTraces(splitSize, td)
-	assert.Equal(t, splitSize, split.SpanCount())
-	assert.Equal(t, 35, td.SpanCount())
-	assert.Equal(t, "test-span-0-0", split.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Name())
-	assert.Equal(t, "test-span-0-4", split.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(4).Name())
+func TestTraceIndexCut(t *testing.T) {
+	td := ptrace.NewTraces()
+	interleavedTraces(2, 1, 2, 3).ResourceSpans().MoveAndAppendTo(td.ResourceSpans())
+	interleavedTraces(1, 3, 4).ResourceSpans().MoveAndAppendTo(td.ResourceSpans())
+	// Traces 1, 2, 3, 4 of 2, 2, 3, and 1 spans.
+
+	ti := newTraceIndex(td)
+	selected, n := ti.cut(3, false)
+	assert.Equal(t, map[pcommon.TraceID]struct{}{{1}: {}, {2}: {}}, selected)
+	assert.Equal(t, 4, n)
+	selected, n = ti.cut(3, false)
+	assert.Equal(t, map[pcommon.TraceID]struct{}{{3}: {}}, selected)
+	assert.Equal(t, 3, n)
+
+	ti = newTraceIndex(td)
+	selected, n = ti.cut(3, true)
+	assert.Equal(t, map[pcommon.TraceID]struct{}{{1}: {}}, selected)
+	assert.Equal(t, 2, n)
+	// The first trace is taken even when larger than size.
+	selected, n = ti.cut(1, true)
+	assert.Equal(t, map[pcommon.TraceID]struct{}{{2}: {}}, selected)
+	assert.Equal(t, 2, n)
+	// Spans added later are counted.
+	ti.add(interleavedTraces(1, 4, 5))
+	selected, n = ti.cut(10, true)
+	assert.Equal(t, map[pcommon.TraceID]struct{}{{3}: {}, {4}: {}, {5}: {}}, selected)
+	assert.Equal(t, 6, n)
+	assert.Empty(t, ti.counts)
+	assert.Empty(t, ti.order)
 }
 
-func TestSplitTracesMultipleResourceSpans_SplitSizeGreaterThanSpanSize(t *testing.T) {
-	td := testdata.GenerateTraces(20)
-	spans := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
-	for i := 0; i < spans.Len(); i++ {
-		spans.At(i).SetName(getTestSpanName(0, i))
-	}
-	// add second index to resource spans
-	testdata.GenerateTraces(20).
-		ResourceSpans().At(0).CopyTo(td.ResourceSpans().AppendEmpty())
-	spans = td.ResourceSpans().At(1).ScopeSpans().At(0).Spans()
-	for i := 0; i < spans.Len(); i++ {
-		spans.At(i).SetName(getTestSpanName(1, i))
+func TestSplitTracesByTrace(t *testing.T) {
+	td := interleavedTraces(3, 1, 2, 3)
+	td.ResourceSpans().At(1).SetSchemaUrl("schema")
+	split := splitTracesByTrace(td, map[pcommon.TraceID]struct{}{{2}: {}})
+	assert.Equal(t, map[pcommon.TraceID]int{{2}: 3}, traceIDs(split))
+	assert.Equal(t, map[pcommon.TraceID]int{{1}: 3, {3}: 3}, traceIDs(td))
+	// Resources are kept.
+	require.Equal(t, 3, split.ResourceSpans().Len())
+	for i := 0; i < 3; i++ {
+		v, _ := split.ResourceSpans().At(i).Resource().Attributes().Get("resource")
+		assert.Equal(t, int64(i), v.Int())
 	}
+	assert.Equal(t, "schema", split.ResourceSpans().At(1).SchemaUrl())
 
-	splitSize := 25
-	split := splitTraces(splitSize, td)
-	assert.Equal(t, splitSize, split.SpanCount())
-	assert.Equal(t, 40-splitSize, td.SpanCount())
-	assert.Equal(t, 1, td.ResourceSpans().Len())
-	assert.Equal(t, "test-span-0-0", split.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Name())
-	assert.Equal(t, "test-span-0-19", split.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(19).Name())
-	assert.Equal(t, "test-span-1-0", split.ResourceSpans().At(1).ScopeSpans().At(0).Spans().At(0).Name())
-	assert.Equal(t, "test-span-1-4", split.ResourceSpans().At(1).ScopeSpans().At(0).Spans().At(4).Name())
+	split = splitTracesByTrace(td, map[pcommon.TraceID]struct{}{{1}: {}, {3}: {}})
+	assert.Equal(t, 6, split.SpanCount())
+	assert.Zero(t, td.ResourceSpans().Len())
 }
 
-func TestSplitTracesMultipleILS(t *testing.T) {
-	td := testdata.GenerateTraces(20)
-	spans := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
-	for i := 0; i < spans.Len(); i++ {
-		spans.At(i).SetName(getTestSpanName(0, i))
+func TestBatchTracesSplitByTrace(t *testing.T) {
+	for _, mode := range []string{batching.SplitModeTrace, batching.SplitModeTraceStrict} {
+		t.Run(mode, func(t *testing.T) {
+			sink := new(consumertest.TracesSink)
+			bt := newBatchTraces(sink)
+			bt.splitMode = mode
+			// Traces 1 to 6 of 4 spans, then 7 of 10, spread over the
+			// requests.
+			bt.Add(interleavedTraces(2, 1, 2, 3, 7), 8)
+			bt.Add(interleavedTraces(2, 4, 1, 2, 3, 7), 10)
+			bt.Add(interleavedTraces(4, 5, 6, 7), 12)
+			bt.Add(interleavedTraces(2, 4, 7), 4)
+			for bt.ItemCount() > 0 {
+				_, _, _, err := bt.Export(context.Background(), 10)
+				require.NoError(t, err)
+			}
+
+			seen := map[pcommon.TraceID]bool{}
+			total := 0
+			for _, td := range sink.AllTraces() {
+				ids := traceIDs(td)
+				largest := 0
+				for id, n := range ids {
+					assert.False(t, seen[id], "trace %v sent twice", id)
+					seen[id] = true
+					if n > largest {
+						largest = n
+					}
+				}
+				switch {
+				case mode == batching.SplitModeTrace:
+					assert.Less(t, td.SpanCount(), 10+largest)
+				case len(ids) > 1:
+					assert.LessOrEqual(t, td.SpanCount(), 10)
+				}
+				total += td.SpanCount()
+			}
+			assert.Len(t, seen, 7)
+			assert.Equal(t, 34, total)
+		})
 	}
-	// add second index to ILS
-	td.ResourceSpans().At(0).ScopeSpans().At(0).
-		CopyTo(td.ResourceSpans().At(0).ScopeSpans().AppendEmpty())
-	spans = td.ResourceSpans().At(0).ScopeSpans().At(1).Spans()
-	for i := 0; i < spans.Len(); i++ {
-		spans.At(i).SetName(getTestSpanName(1, i))
+}
+
+func TestBatchProcessorSplitModeTraceStrict(t *testing.T) {
+	sink := new(consumertest.TracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 4
+	cfg.SendBatchMaxSize = 4
+	cfg.SplitMode = batching.SplitModeTraceStrict
+	cfg.SyncConsume = true
+	bp, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, bp.ConsumeTraces(context.Background(), interleavedTraces(3, 1, 2, 3)))
+	require.NoError(t, bp.Shutdown(context.Background()))
+
+	// Each request holds one trace of 3 spans.
+	require.Len(t, sink.AllTraces(), 3)
+	for i, td := range sink.AllTraces() {
+		assert.Equal(t, map[pcommon.TraceID]int{{byte(i + 1)}: 3}, traceIDs(td))
 	}
+}
 
-	// add third index to ILS
-	td.ResourceSpans().At(0).ScopeSpans().At(0).
-		CopyTo(td.ResourceSpans().At(0).ScopeSpans().AppendEmpty())
-	spans = td.ResourceSpans().At(0).ScopeSpans().At(2).Spans()
-	for i := 0; i < spans.Len(); i++ {
-		spans.At(i).SetName(getTestSpanName(2, i))
+func TestSplitTracesAtResource(t *testing.T) {
+	td := ptrace.NewTraces()
+	for _, spans := range []int{3, 4, 6, 1} {
+		testdata.GenerateTraces(spans).ResourceSpans().MoveAndAppendTo(td.ResourceSpans())
 	}
 
-	splitSize := 40
-	split := splitTraces(splitSize, td)
-	assert.Equal(t, splitSize, split.SpanCount())
-	assert.Equal(t, 20, td.SpanCount())
-	assert.Equal(t, "test-span-0-0", split.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Name())
-	assert.Equal(t, "test-span-0-4", split.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(4).Name())
+	// Below the limit, the next resource does not fit.
+	split, n := splitTracesAtResource(4, td)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, 1, split.ResourceSpans().Len())
+	// At the limit.
+	split, n = splitTracesAtResource(4, td)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, 1, split.ResourceSpans().Len())
+	// Above the limit, the resource is sent alone.
+	split, n = splitTracesAtResource(4, td)
+	assert.Equal(t, 6, n)
+	assert.Equal(t, 6, split.SpanCount())
+	assert.Equal(t, 1, td.SpanCount())
 }
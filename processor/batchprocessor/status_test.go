@@ -0,0 +1,104 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/internal/testdata"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+// statusHost is a component.Host recording the status events reported
+// by its components.
+type statusHost struct {
+	component.Host
+
+	lock   sync.Mutex
+	events []component.StatusEvent
+}
+
+func (h *statusHost) ReportComponentStatus(_ component.ID, event component.StatusEvent) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.events = append(h.events, event)
+}
+
+func (h *statusHost) statusEvents() []component.StatusEvent {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return append([]component.StatusEvent(nil), h.events...)
+}
+
+func TestBatchProcessorStatusReporting(t *testing.T) {
+	exportErr := errors.New("unavailable")
+	sink := &flakyTracesSink{failures: 3, err: exportErr}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1
+	cfg.MetadataKeys = []string{"tenant"}
+	cfg.StatusReporting.FailureThreshold = 2
+	host := &statusHost{Host: componenttest.NewNopHost()}
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), host))
+
+	ctx := client.NewContext(context.Background(), client.Info{
+		Metadata: client.NewMetadata(map[string][]string{"tenant": {"acme"}}),
+	})
+	for i := 0; i < 4; i++ {
+		require.NoError(t, batcher.ConsumeTraces(ctx, testdata.GenerateTraces(1)))
+	}
+	require.Eventually(t, func() bool {
+		return sink.SpanCount() == 1
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, batcher.Shutdown(context.Background()))
+
+	// The error is reported once, on the second failure.
+	assert.Equal(t, []component.StatusEvent{
+		{
+			Status:     component.StatusRecoverableError,
+			Err:        exportErr,
+			Attributes: map[string]string{"tenant": "acme"},
+		},
+		{Status: component.StatusOK},
+	}, host.statusEvents())
+}
+
+func TestBatchProcessorStatusReportingDisabled(t *testing.T) {
+	sink := &flakyTracesSink{failures: 3, err: errors.New("unavailable")}
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1
+	cfg.StatusReporting.FailureThreshold = 0
+	host := &statusHost{Host: componenttest.NewNopHost()}
+	batcher, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, batcher.Start(context.Background(), host))
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, batcher.ConsumeTraces(context.Background(), testdata.GenerateTraces(1)))
+	}
+	require.NoError(t, batcher.Shutdown(context.Background()))
+	assert.Empty(t, host.statusEvents())
+}
@@ -0,0 +1,236 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/internal/testdata"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+func TestBatchProcessorSendSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	producer := tp.Tracer("producer")
+
+	sink := new(consumertest.TracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 6
+	cfg.Timeout = time.Hour
+	cfg.MetadataKeys = []string{"tenant"}
+	set := processortest.NewNopCreateSettings()
+	set.ID = component.NewID(typeStr)
+	set.TracerProvider = tp
+	bp, err := newBatchTracesProcessor(set, sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+
+	ctx := client.NewContext(context.Background(), client.Info{
+		Metadata: client.NewMetadata(map[string][]string{"tenant": {"a"}}),
+	})
+	var producers []trace.SpanContext
+	for i := 0; i < 3; i++ {
+		pctx, span := producer.Start(ctx, "receive")
+		require.NoError(t, bp.ConsumeTraces(pctx, testdata.GenerateTraces(2)))
+		producers = append(producers, span.SpanContext())
+		span.End()
+	}
+	require.NoError(t, bp.Shutdown(context.Background()))
+
+	var sends []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "processor/batch/send" {
+			sends = append(sends, span)
+		}
+	}
+	require.Len(t, sends, 1)
+	var linked []trace.SpanContext
+	for _, link := range sends[0].Links() {
+		linked = append(linked, link.SpanContext)
+	}
+	assert.Equal(t, producers, linked)
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		attribute.String("batch.trigger", "batch_size"),
+		attribute.String("tenant", "a"),
+		attribute.Int("batch.items", 6),
+		attribute.Int("batch.bytes", 0),
+	}, sends[0].Attributes())
+	assert.Equal(t, codes.Unset, sends[0].Status().Code)
+
+	// The next consumer is called with the context of the span.
+	require.Len(t, sink.AllTraces(), 1)
+}
+
+func TestBatchProcessorSendSpanLinksBounded(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder), sdktrace.WithSpanLimits(sdktrace.SpanLimits{
+		AttributeValueLengthLimit:   -1,
+		AttributeCountLimit:         -1,
+		EventCountLimit:             -1,
+		LinkCountLimit:              -1,
+		AttributePerEventCountLimit: -1,
+		AttributePerLinkCountLimit:  -1,
+	}))
+	producer := tp.Tracer("producer")
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = time.Hour
+	set := processortest.NewNopCreateSettings()
+	set.ID = component.NewID(typeStr)
+	set.TracerProvider = tp
+	bp, err := newBatchTracesProcessor(set, consumertest.NewErr(errors.New("export failed")), cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+
+	// The send span links at most 128 of the receive spans.
+	const maxSpanLinks = 128
+	for i := 0; i < 2*maxSpanLinks; i++ {
+		pctx, span := producer.Start(context.Background(), "receive")
+		require.NoError(t, bp.ConsumeTraces(pctx, testdata.GenerateTraces(1)))
+		span.End()
+	}
+	require.NoError(t, bp.Shutdown(context.Background()))
+
+	var sends []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "processor/batch/send" {
+			sends = append(sends, span)
+		}
+	}
+	require.Len(t, sends, 1)
+	assert.Len(t, sends[0].Links(), maxSpanLinks)
+	assert.Equal(t, codes.Error, sends[0].Status().Code)
+	assert.Equal(t, "export failed", sends[0].Status().Description)
+}
+
+// spanMeterProvider records the spans active when its histograms record
+// measurements, by instrument name, along with whether they were still
+// recording.
+type spanMeterProvider struct {
+	metric.MeterProvider
+
+	mu    sync.Mutex
+	spans map[string][]recordedSpan
+}
+
+type recordedSpan struct {
+	sc        trace.SpanContext
+	recording bool
+}
+
+func (mp *spanMeterProvider) Meter(name string, opts ...metric.MeterOption) metric.Meter {
+	return &spanMeter{Meter: mp.MeterProvider.Meter(name, opts...), mp: mp}
+}
+
+func (mp *spanMeterProvider) observe(name string, ctx context.Context) {
+	span := trace.SpanFromContext(ctx)
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	mp.spans[name] = append(mp.spans[name], recordedSpan{sc: span.SpanContext(), recording: span.IsRecording()})
+}
+
+type spanMeter struct {
+	metric.Meter
+	mp *spanMeterProvider
+}
+
+func (m *spanMeter) Int64Histogram(name string, options ...metric.Int64HistogramOption) (metric.Int64Histogram, error) {
+	h, err := m.Meter.Int64Histogram(name, options...)
+	return &spanInt64Histogram{Int64Histogram: h, name: name, mp: m.mp}, err
+}
+
+func (m *spanMeter) Float64Histogram(name string, options ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	h, err := m.Meter.Float64Histogram(name, options...)
+	return &spanFloat64Histogram{Float64Histogram: h, name: name, mp: m.mp}, err
+}
+
+type spanInt64Histogram struct {
+	metric.Int64Histogram
+	name string
+	mp   *spanMeterProvider
+}
+
+func (h *spanInt64Histogram) Record(ctx context.Context, incr int64, opts ...metric.RecordOption) {
+	h.mp.observe(h.name, ctx)
+	h.Int64Histogram.Record(ctx, incr, opts...)
+}
+
+type spanFloat64Histogram struct {
+	metric.Float64Histogram
+	name string
+	mp   *spanMeterProvider
+}
+
+func (h *spanFloat64Histogram) Record(ctx context.Context, incr float64, opts ...metric.RecordOption) {
+	h.mp.observe(h.name, ctx)
+	h.Float64Histogram.Record(ctx, incr, opts...)
+}
+
+func TestBatchProcessorHistogramsInSendSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	mp := &spanMeterProvider{
+		MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewManualReader())),
+		spans:         map[string][]recordedSpan{},
+	}
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 2
+	cfg.Timeout = time.Hour
+	cfg.SyncConsume = true
+	set := processortest.NewNopCreateSettings()
+	set.ID = component.NewID(typeStr)
+	set.TracerProvider = tp
+	set.MeterProvider = mp
+	set.MetricsLevel = configtelemetry.LevelDetailed
+	bp, err := newBatchTracesProcessor(set, consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, bp.ConsumeTraces(context.Background(), testdata.GenerateTraces(2)))
+	require.NoError(t, bp.Shutdown(context.Background()))
+
+	var sends []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "processor/batch/send" {
+			sends = append(sends, span)
+		}
+	}
+	require.Len(t, sends, 1)
+	expected := []recordedSpan{{sc: sends[0].SpanContext(), recording: true}}
+	for _, name := range []string{"processor/batch/batch_send_size", "processor/batch/batch_send_size_bytes", "processor/batch/send_latency", "processor/batch/batch_age"} {
+		assert.Equal(t, expected, mp.spans[name], name)
+	}
+}
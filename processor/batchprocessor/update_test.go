@@ -0,0 +1,167 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/internal/testdata"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+func spanCounts(sink *consumertest.TracesSink) []int {
+	var counts []int
+	for _, td := range sink.AllTraces() {
+		counts = append(counts, td.SpanCount())
+	}
+	return counts
+}
+
+func TestBatchProcessorUpdateConfigSize(t *testing.T) {
+	sink := new(consumertest.TracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 10
+	cfg.Timeout = time.Hour
+	cfg.SyncConsume = true
+	bp, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+
+	for i := 0; i < 6; i++ {
+		require.NoError(t, bp.ConsumeTraces(context.Background(), testdata.GenerateTraces(2)))
+	}
+	assert.Equal(t, []int{10}, spanCounts(sink))
+
+	updated := *cfg
+	updated.SendBatchSize = 4
+	updated.SendBatchMaxSize = 4
+	require.NoError(t, bp.UpdateConfig(&updated))
+
+	// The 2 pending spans are kept, and sent with the next ones.
+	for i := 0; i < 5; i++ {
+		require.NoError(t, bp.ConsumeTraces(context.Background(), testdata.GenerateTraces(2)))
+	}
+	assert.Equal(t, []int{10, 4, 4, 4}, spanCounts(sink))
+
+	require.NoError(t, bp.Shutdown(context.Background()))
+	assert.Equal(t, 22, sink.SpanCount())
+}
+
+func TestBatchProcessorUpdateConfigTimeout(t *testing.T) {
+	sink := new(consumertest.TracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 2
+	cfg.Timeout = time.Hour
+	bp, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+
+	updated := *cfg
+	updated.Timeout = 10 * time.Millisecond
+	require.NoError(t, bp.UpdateConfig(&updated))
+
+	// The timer is reset with the new timeout after the next send.
+	require.NoError(t, bp.ConsumeTraces(context.Background(), testdata.GenerateTraces(2)))
+	require.NoError(t, bp.ConsumeTraces(context.Background(), testdata.GenerateTraces(1)))
+	assert.Eventually(t, func() bool { return sink.SpanCount() == 3 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, bp.Shutdown(context.Background()))
+}
+
+func TestBatchProcessorUpdateConfigOverride(t *testing.T) {
+	sink := new(consumertest.TracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 10
+	cfg.Timeout = time.Hour
+	cfg.SyncConsume = true
+	cfg.MetadataKeys = []string{"tenant"}
+	overrideSize := uint32(2)
+	cfg.Overrides = []BatchOverride{{Metadata: map[string]string{"tenant": "a"}, SendBatchSize: &overrideSize}}
+	bp, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+
+	updated := *cfg
+	updated.SendBatchSize = 3
+	require.NoError(t, bp.UpdateConfig(&updated))
+
+	for _, tenant := range []string{"a", "b"} {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"tenant": {tenant}}),
+		})
+		for i := 0; i < 3; i++ {
+			require.NoError(t, bp.ConsumeTraces(ctx, testdata.GenerateTraces(1)))
+		}
+	}
+	// The override of tenant "a" still applies.
+	assert.Equal(t, []int{2, 3}, spanCounts(sink))
+	require.NoError(t, bp.Shutdown(context.Background()))
+}
+
+func TestBatchProcessorUpdateConfigSignals(t *testing.T) {
+	sink := new(consumertest.TracesSink)
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 10
+	cfg.Timeout = time.Hour
+	cfg.SyncConsume = true
+	bp, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+
+	// Only the section of the signal of the processor applies.
+	updated := *cfg
+	tracesSize, logsSize := uint32(2), uint32(4)
+	updated.Signals.Traces.SendBatchSize = &tracesSize
+	updated.Signals.Logs.SendBatchSize = &logsSize
+	require.NoError(t, bp.UpdateConfig(&updated))
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, bp.ConsumeTraces(context.Background(), testdata.GenerateTraces(1)))
+	}
+	assert.Equal(t, []int{2}, spanCounts(sink))
+	require.NoError(t, bp.Shutdown(context.Background()))
+}
+
+// hintingTracesSink prefers requests of items spans.
+type hintingTracesSink struct {
+	consumertest.TracesSink
+	items int
+}
+
+func (s *hintingTracesSink) PreferredBatchSize() (int, int) {
+	return s.items, 1 << 20
+}
+
+func TestBatchProcessorBatchHintApplied(t *testing.T) {
+	sink := &hintingTracesSink{items: 4}
+	cfg := createDefaultConfig().(*Config)
+	cfg.Timeout = time.Hour
+	cfg.SyncConsume = true
+	bp, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, bp.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, bp.ConsumeTraces(context.Background(), testdata.GenerateTraces(10)))
+	assert.Equal(t, []int{4, 4}, spanCounts(&sink.TracesSink))
+	require.NoError(t, bp.Shutdown(context.Background()))
+	assert.Equal(t, []int{4, 4, 2}, spanCounts(&sink.TracesSink))
+}
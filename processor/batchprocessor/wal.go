@@ -0,0 +1,46 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor // import "go.opentelemetry.io/collector/processor/batchprocessor"
+
+import (
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// walMarshalers returns the functions encoding and decoding the items
+// of dataType in write-ahead log records.
+func walMarshalers(dataType component.DataType) (func(any) ([]byte, error), func([]byte) (any, error)) {
+	switch dataType {
+	case component.DataTypeTraces:
+		return func(item any) ([]byte, error) {
+				return (&ptrace.ProtoMarshaler{}).MarshalTraces(item.(ptrace.Traces))
+			}, func(buf []byte) (any, error) {
+				return (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(buf)
+			}
+	case component.DataTypeMetrics:
+		return func(item any) ([]byte, error) {
+				return (&pmetric.ProtoMarshaler{}).MarshalMetrics(item.(pmetric.Metrics))
+			}, func(buf []byte) (any, error) {
+				return (&pmetric.ProtoUnmarshaler{}).UnmarshalMetrics(buf)
+			}
+	}
+	return func(item any) ([]byte, error) {
+			return (&plog.ProtoMarshaler{}).MarshalLogs(item.(plog.Logs))
+		}, func(buf []byte) (any, error) {
+			return (&plog.ProtoUnmarshaler{}).UnmarshalLogs(buf)
+		}
+}
@@ -0,0 +1,246 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchprocessor
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/internal/testdata"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+func persistentConfig(dir string) *Config {
+	cfg := createDefaultConfig().(*Config)
+	cfg.SendBatchSize = 1000
+	cfg.Timeout = 10 * time.Minute
+	cfg.MetadataKeys = []string{"tenant"}
+	cfg.Persistence.Directory = dir
+	return cfg
+}
+
+// walSegments returns the segment files of the traces processors
+// created with processortest.NewNopCreateSettings, whose logs are in
+// the traces directory of dir.
+func walSegments(t *testing.T, dir string) []string {
+	paths, err := filepath.Glob(filepath.Join(dir, string(component.DataTypeTraces), "*.wal"))
+	require.NoError(t, err)
+	return paths
+}
+
+// consumeTenantTraces sends spanCount spans for each tenant.
+func consumeTenantTraces(t *testing.T, next consumer.Traces, tenants []string, spanCount int) {
+	for _, tenant := range tenants {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"tenant": {tenant}}),
+		})
+		require.NoError(t, next.ConsumeTraces(ctx, testdata.GenerateTraces(spanCount)))
+	}
+}
+
+func TestBatchProcessorPersistenceReplay(t *testing.T) {
+	dir := t.TempDir()
+	cfg := persistentConfig(dir)
+	// Stopping without flushing leaves the pending data on disk, as
+	// when the process is killed.
+	flushOnShutdown := false
+	cfg.FlushOnShutdown = &flushOnShutdown
+
+	first, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+	require.NoError(t, first.Start(context.Background(), componenttest.NewNopHost()))
+	consumeTenantTraces(t, first, []string{"a", "b"}, 3)
+	require.NoError(t, first.Shutdown(context.Background()))
+	require.Len(t, walSegments(t, dir), 2)
+
+	sink := new(contextMetadataTracesSink)
+	cfg.FlushOnShutdown = nil
+	second, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, second.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, second.Shutdown(context.Background()))
+
+	// The data is replayed into the batchers of its metadata.
+	assert.Equal(t, 6, sink.SpanCount())
+	var tenants []string
+	for _, md := range sink.metadata {
+		tenants = append(tenants, md.Get("tenant")...)
+	}
+	sort.Strings(tenants)
+	assert.Equal(t, []string{"a", "b"}, tenants)
+}
+
+func TestBatchProcessorPersistenceReplaySource(t *testing.T) {
+	dir := t.TempDir()
+	cfg := persistentConfig(dir)
+	cfg.MetadataKeys = nil
+	cfg.GroupBy = []GroupBySource{{Source: true}}
+	flushOnShutdown := false
+	cfg.FlushOnShutdown = &flushOnShutdown
+
+	first, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+	require.NoError(t, first.Start(context.Background(), componenttest.NewNopHost()))
+	for _, source := range []string{"otlp", "otlp/internal"} {
+		ctx := client.NewContext(context.Background(), client.Info{Source: source})
+		require.NoError(t, first.ConsumeTraces(ctx, testdata.GenerateTraces(2)))
+	}
+	require.NoError(t, first.Shutdown(context.Background()))
+
+	var sources []string
+	next, err := consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+		sources = append(sources, client.FromContext(ctx).Source)
+		return nil
+	})
+	require.NoError(t, err)
+	cfg.FlushOnShutdown = nil
+	second, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), next, cfg)
+	require.NoError(t, err)
+	require.NoError(t, second.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, second.Shutdown(context.Background()))
+
+	// The data is replayed into the batchers of its source.
+	sort.Strings(sources)
+	assert.Equal(t, []string{"otlp", "otlp/internal"}, sources)
+}
+
+func TestBatchProcessorPersistenceFailedExport(t *testing.T) {
+	dir := t.TempDir()
+	cfg := persistentConfig(dir)
+
+	// Every export fails, including the flush on shutdown.
+	first, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), consumertest.NewErr(errors.New("export failed")), cfg)
+	require.NoError(t, err)
+	require.NoError(t, first.Start(context.Background(), componenttest.NewNopHost()))
+	consumeTenantTraces(t, first, []string{"a", "b"}, 3)
+	require.NoError(t, first.Shutdown(context.Background()))
+
+	// The data of the failed exports is replayed on restart.
+	sink := new(consumertest.TracesSink)
+	second, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, second.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, second.Shutdown(context.Background()))
+	assert.Equal(t, 6, sink.SpanCount())
+}
+
+func TestBatchProcessorPersistenceFailedThenExported(t *testing.T) {
+	dir := t.TempDir()
+	cfg := persistentConfig(dir)
+	cfg.SendBatchSize = 2
+
+	sink := &flakyTracesSink{failures: 1, err: errors.New("export failed")}
+	first, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, first.Start(context.Background(), componenttest.NewNopHost()))
+	consumeTenantTraces(t, first, []string{"a", "a"}, 2)
+	require.Eventually(t, func() bool {
+		return sink.SpanCount() == 2
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, first.Shutdown(context.Background()))
+
+	// The later successful export does not remove the records of
+	// the failed one, replayed on restart, possibly along with the
+	// data appended before the batcher rolled over.
+	restarted := new(consumertest.TracesSink)
+	second, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), restarted, cfg)
+	require.NoError(t, err)
+	require.NoError(t, second.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, second.Shutdown(context.Background()))
+	assert.GreaterOrEqual(t, restarted.SpanCount(), 2)
+}
+
+// slowTracesSink takes delay to consume every request.
+type slowTracesSink struct {
+	consumertest.TracesSink
+	delay time.Duration
+}
+
+func (s *slowTracesSink) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	time.Sleep(s.delay)
+	return s.TracesSink.ConsumeTraces(ctx, td)
+}
+
+func TestBatchProcessorPersistenceReplayFull(t *testing.T) {
+	dir := t.TempDir()
+	cfg := persistentConfig(dir)
+	flushOnShutdown := false
+	cfg.FlushOnShutdown = &flushOnShutdown
+
+	first, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+	require.NoError(t, first.Start(context.Background(), componenttest.NewNopHost()))
+	for i := 0; i < 50; i++ {
+		consumeTenantTraces(t, first, []string{"a"}, 1)
+	}
+	require.NoError(t, first.Shutdown(context.Background()))
+
+	// A batcher exporting every span slowly fills its queue, whose
+	// data is replayed once accepted rather than rejected.
+	sink := &slowTracesSink{delay: time.Millisecond}
+	cfg.FlushOnShutdown = nil
+	cfg.SendBatchSize = 1
+	cfg.OnFull = "error"
+	second, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, second.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, second.Shutdown(context.Background()))
+
+	assert.Equal(t, 50, sink.SpanCount())
+}
+
+func TestBatchProcessorPersistenceReplayCardinalityLimit(t *testing.T) {
+	dir := t.TempDir()
+	cfg := persistentConfig(dir)
+	flushOnShutdown := false
+	cfg.FlushOnShutdown = &flushOnShutdown
+
+	first, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), consumertest.NewNop(), cfg)
+	require.NoError(t, err)
+	require.NoError(t, first.Start(context.Background(), componenttest.NewNopHost()))
+	consumeTenantTraces(t, first, []string{"a", "b"}, 2)
+	require.NoError(t, first.Shutdown(context.Background()))
+
+	// The data of the tenant over the limit is kept on disk.
+	sink := new(consumertest.TracesSink)
+	cfg.FlushOnShutdown = nil
+	cfg.MetadataCardinalityLimit = 1
+	second, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, second.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, second.Shutdown(context.Background()))
+	assert.Equal(t, 2, sink.SpanCount())
+
+	// And replayed once the limit allows it.
+	cfg.MetadataCardinalityLimit = 2
+	third, err := newBatchTracesProcessor(processortest.NewNopCreateSettings(), sink, cfg)
+	require.NoError(t, err)
+	require.NoError(t, third.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, third.Shutdown(context.Background()))
+	assert.Equal(t, 4, sink.SpanCount())
+}
@@ -25,11 +25,13 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
+	"go.opentelemetry.io/collector/client"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/internal/testdata"
 	"go.opentelemetry.io/collector/obsreport"
+	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
 	"go.opentelemetry.io/collector/receiver/receivertest"
 )
@@ -68,6 +70,18 @@ func TestExport_ErrorConsumer(t *testing.T) {
 	assert.Equal(t, plogotlp.ExportResponse{}, resp)
 }
 
+func TestExport_Source(t *testing.T) {
+	var source string
+	nc, err := consumer.NewLogs(func(ctx context.Context, _ plog.Logs) error {
+		source = client.FromContext(ctx).Source
+		return nil
+	})
+	require.NoError(t, err)
+	_, err = makeLogsServiceClient(t, nc).Export(context.Background(), plogotlp.NewExportRequestFromLogs(testdata.GenerateLogs(1)))
+	require.NoError(t, err)
+	assert.Equal(t, "otlp/log", source)
+}
+
 func makeLogsServiceClient(t *testing.T, lc consumer.Logs) plogotlp.GRPCClient {
 	addr := otlpReceiverOnGRPCServer(t, lc)
 	cc, err := grpc.Dial(addr.String(), grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
@@ -95,7 +109,7 @@ func otlpReceiverOnGRPCServer(t *testing.T, lc consumer.Logs) net.Addr {
 		ReceiverCreateSettings: set,
 	})
 	require.NoError(t, err)
-	r := New(lc, obsrecv)
+	r := New(set.ID, lc, obsrecv)
 	// Now run it as a gRPC server
 	srv := grpc.NewServer()
 	plogotlp.RegisterGRPCServer(srv, r)
@@ -25,11 +25,13 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
+	"go.opentelemetry.io/collector/client"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/internal/testdata"
 	"go.opentelemetry.io/collector/obsreport"
+	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
 	"go.opentelemetry.io/collector/receiver/receivertest"
 )
@@ -68,6 +70,18 @@ func TestExport_ErrorConsumer(t *testing.T) {
 	assert.Equal(t, pmetricotlp.ExportResponse{}, resp)
 }
 
+func TestExport_Source(t *testing.T) {
+	var source string
+	nc, err := consumer.NewMetrics(func(ctx context.Context, _ pmetric.Metrics) error {
+		source = client.FromContext(ctx).Source
+		return nil
+	})
+	require.NoError(t, err)
+	_, err = makeMetricsServiceClient(t, nc).Export(context.Background(), pmetricotlp.NewExportRequestFromMetrics(testdata.GenerateMetrics(1)))
+	require.NoError(t, err)
+	assert.Equal(t, "otlp/metrics", source)
+}
+
 func makeMetricsServiceClient(t *testing.T, mc consumer.Metrics) pmetricotlp.GRPCClient {
 	addr := otlpReceiverOnGRPCServer(t, mc)
 
@@ -96,7 +110,7 @@ func otlpReceiverOnGRPCServer(t *testing.T, mc consumer.Metrics) net.Addr {
 		ReceiverCreateSettings: set,
 	})
 	require.NoError(t, err)
-	r := New(mc, obsrecv)
+	r := New(set.ID, mc, obsrecv)
 	// Now run it as a gRPC server
 	srv := grpc.NewServer()
 	pmetricotlp.RegisterGRPCServer(srv, r)
@@ -25,11 +25,13 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
+	"go.opentelemetry.io/collector/client"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/internal/testdata"
 	"go.opentelemetry.io/collector/obsreport"
+	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
 	"go.opentelemetry.io/collector/receiver/receivertest"
 )
@@ -66,6 +68,18 @@ func TestExport_ErrorConsumer(t *testing.T) {
 	assert.Equal(t, ptraceotlp.ExportResponse{}, resp)
 }
 
+func TestExport_Source(t *testing.T) {
+	var source string
+	nc, err := consumer.NewTraces(func(ctx context.Context, _ ptrace.Traces) error {
+		source = client.FromContext(ctx).Source
+		return nil
+	})
+	require.NoError(t, err)
+	_, err = makeTraceServiceClient(t, nc).Export(context.Background(), ptraceotlp.NewExportRequestFromTraces(testdata.GenerateTraces(1)))
+	require.NoError(t, err)
+	assert.Equal(t, "otlp/trace", source)
+}
+
 func makeTraceServiceClient(t *testing.T, tc consumer.Traces) ptraceotlp.GRPCClient {
 	addr := otlpReceiverOnGRPCServer(t, tc)
 	cc, err := grpc.Dial(addr.String(), grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
@@ -93,7 +107,7 @@ func otlpReceiverOnGRPCServer(t *testing.T, tc consumer.Traces) net.Addr {
 		ReceiverCreateSettings: set,
 	})
 	require.NoError(t, err)
-	r := New(tc, obsrecv)
+	r := New(set.ID, tc, obsrecv)
 	// Now run it as a gRPC server
 	srv := grpc.NewServer()
 	ptraceotlp.RegisterGRPCServer(srv, r)
@@ -197,8 +197,8 @@ func (r *otlpReceiver) registerTraceConsumer(tc consumer.Traces) error {
 	if tc == nil {
 		return component.ErrNilNextConsumer
 	}
-	r.tracesReceiver = trace.New(tc, r.obsrepGRPC)
-	httpTracesReceiver := trace.New(tc, r.obsrepHTTP)
+	r.tracesReceiver = trace.New(r.settings.ID, tc, r.obsrepGRPC)
+	httpTracesReceiver := trace.New(r.settings.ID, tc, r.obsrepHTTP)
 	if r.httpMux != nil {
 		r.httpMux.HandleFunc("/v1/traces", func(resp http.ResponseWriter, req *http.Request) {
 			if req.Method != http.MethodPost {
@@ -222,8 +222,8 @@ func (r *otlpReceiver) registerMetricsConsumer(mc consumer.Metrics) error {
 	if mc == nil {
 		return component.ErrNilNextConsumer
 	}
-	r.metricsReceiver = metrics.New(mc, r.obsrepGRPC)
-	httpMetricsReceiver := metrics.New(mc, r.obsrepHTTP)
+	r.metricsReceiver = metrics.New(r.settings.ID, mc, r.obsrepGRPC)
+	httpMetricsReceiver := metrics.New(r.settings.ID, mc, r.obsrepHTTP)
 	if r.httpMux != nil {
 		r.httpMux.HandleFunc("/v1/metrics", func(resp http.ResponseWriter, req *http.Request) {
 			if req.Method != http.MethodPost {
@@ -247,8 +247,8 @@ func (r *otlpReceiver) registerLogsConsumer(lc consumer.Logs) error {
 	if lc == nil {
 		return component.ErrNilNextConsumer
 	}
-	r.logsReceiver = logs.New(lc, r.obsrepGRPC)
-	httpLogsReceiver := logs.New(lc, r.obsrepHTTP)
+	r.logsReceiver = logs.New(r.settings.ID, lc, r.obsrepGRPC)
+	httpLogsReceiver := logs.New(r.settings.ID, lc, r.obsrepHTTP)
 	if r.httpMux != nil {
 		r.httpMux.HandleFunc("/v1/logs", func(resp http.ResponseWriter, req *http.Request) {
 			if req.Method != http.MethodPost {
@@ -0,0 +1,49 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fanoutconsumer // import "go.opentelemetry.io/collector/service/internal/fanoutconsumer"
+
+import "go.opentelemetry.io/collector/consumer"
+
+// acceptsReadOnly returns whether all the consumers given the incoming
+// data as is accept it marked read-only.
+func acceptsReadOnly[C interface{ Capabilities() consumer.Capabilities }](pass []C) bool {
+	for _, c := range pass {
+		if !c.Capabilities().AcceptsReadOnly {
+			return false
+		}
+	}
+	return len(pass) != 0
+}
+
+// preferredBatchSize returns the smallest of the batch size hints of the
+// consumers, for each of them to get requests no larger than it prefers.
+func preferredBatchSize[C any](consumers ...[]C) (items int, bytes int) {
+	for _, cs := range consumers {
+		for _, c := range cs {
+			hp, ok := any(c).(consumer.BatchHintProvider)
+			if !ok {
+				continue
+			}
+			i, b := hp.PreferredBatchSize()
+			if i > 0 && (items == 0 || i < items) {
+				items = i
+			}
+			if b > 0 && (bytes == 0 || b < bytes) {
+				bytes = b
+			}
+		}
+	}
+	return items, bytes
+}
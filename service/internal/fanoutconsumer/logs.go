@@ -32,6 +32,7 @@ import (
 // It fanouts the incoming data to all the consumers, and does smart routing:
 //   - Clones only to the consumer that needs to mutate the data.
 //   - If all consumers needs to mutate the data one will get the original data.
+//   - Marks the data read-only when it is shared by several consumers.
 func NewLogs(lcs []consumer.Logs) consumer.Logs {
 	if len(lcs) == 1 {
 		// Don't wrap if no need to do it.
@@ -64,7 +65,12 @@ type logsConsumer struct {
 }
 
 func (lsc *logsConsumer) Capabilities() consumer.Capabilities {
-	return consumer.Capabilities{MutatesData: false}
+	return consumer.Capabilities{MutatesData: false, AcceptsReadOnly: acceptsReadOnly(lsc.pass)}
+}
+
+// PreferredBatchSize implements consumer.BatchHintProvider.
+func (lsc *logsConsumer) PreferredBatchSize() (int, int) {
+	return preferredBatchSize(lsc.pass, lsc.clone)
 }
 
 // ConsumeLogs exports the plog.Logs to all consumers wrapped by the current one.
@@ -78,6 +84,10 @@ func (lsc *logsConsumer) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
 		ld.CopyTo(clonedLogs)
 		errs = multierr.Append(errs, lc.ConsumeLogs(ctx, clonedLogs))
 	}
+	if len(lsc.pass) > 1 {
+		// Shared by the consumers, which must copy it to mutate it.
+		ld.MarkReadOnly()
+	}
 	for _, lc := range lsc.pass {
 		errs = multierr.Append(errs, lc.ConsumeLogs(ctx, ld))
 	}
@@ -30,6 +30,7 @@ import (
 // It fanouts the incoming data to all the consumers, and does smart routing:
 //   - Clones only to the consumer that needs to mutate the data.
 //   - If all consumers needs to mutate the data one will get the original data.
+//   - Marks the data read-only when it is shared by several consumers.
 func NewMetrics(mcs []consumer.Metrics) consumer.Metrics {
 	if len(mcs) == 1 {
 		// Don't wrap if no need to do it.
@@ -62,7 +63,12 @@ type metricsConsumer struct {
 }
 
 func (msc *metricsConsumer) Capabilities() consumer.Capabilities {
-	return consumer.Capabilities{MutatesData: false}
+	return consumer.Capabilities{MutatesData: false, AcceptsReadOnly: acceptsReadOnly(msc.pass)}
+}
+
+// PreferredBatchSize implements consumer.BatchHintProvider.
+func (msc *metricsConsumer) PreferredBatchSize() (int, int) {
+	return preferredBatchSize(msc.pass, msc.clone)
 }
 
 // ConsumeMetrics exports the pmetric.Metrics to all consumers wrapped by the current one.
@@ -76,6 +82,10 @@ func (msc *metricsConsumer) ConsumeMetrics(ctx context.Context, md pmetric.Metri
 		md.CopyTo(clonedMetrics)
 		errs = multierr.Append(errs, mc.ConsumeMetrics(ctx, clonedMetrics))
 	}
+	if len(msc.pass) > 1 {
+		// Shared by the consumers, which must copy it to mutate it.
+		md.MarkReadOnly()
+	}
 	for _, mc := range msc.pass {
 		errs = multierr.Append(errs, mc.ConsumeMetrics(ctx, md))
 	}
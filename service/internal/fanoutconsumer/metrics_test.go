@@ -68,6 +68,7 @@ func TestMetricsMultiplexingNonMutating(t *testing.T) {
 	assert.True(t, md == p3.AllMetrics()[1])
 	assert.EqualValues(t, md, p3.AllMetrics()[0])
 	assert.EqualValues(t, md, p3.AllMetrics()[1])
+	assert.True(t, md.IsReadOnly())
 }
 
 func TestMetricsMultiplexingMutating(t *testing.T) {
@@ -102,6 +103,7 @@ func TestMetricsMultiplexingMutating(t *testing.T) {
 	assert.True(t, md == p3.AllMetrics()[1])
 	assert.EqualValues(t, md, p3.AllMetrics()[0])
 	assert.EqualValues(t, md, p3.AllMetrics()[1])
+	assert.False(t, md.IsReadOnly())
 }
 
 func TestMetricsMultiplexingMixLastMutating(t *testing.T) {
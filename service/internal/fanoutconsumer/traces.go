@@ -30,6 +30,7 @@ import (
 // It fanouts the incoming data to all the consumers, and does smart routing:
 //   - Clones only to the consumer that needs to mutate the data.
 //   - If all consumers needs to mutate the data one will get the original data.
+//   - Marks the data read-only when it is shared by several consumers.
 func NewTraces(tcs []consumer.Traces) consumer.Traces {
 	if len(tcs) == 1 {
 		// Don't wrap if no need to do it.
@@ -62,7 +63,12 @@ type tracesConsumer struct {
 }
 
 func (tsc *tracesConsumer) Capabilities() consumer.Capabilities {
-	return consumer.Capabilities{MutatesData: false}
+	return consumer.Capabilities{MutatesData: false, AcceptsReadOnly: acceptsReadOnly(tsc.pass)}
+}
+
+// PreferredBatchSize implements consumer.BatchHintProvider.
+func (tsc *tracesConsumer) PreferredBatchSize() (int, int) {
+	return preferredBatchSize(tsc.pass, tsc.clone)
 }
 
 // ConsumeTraces exports the ptrace.Traces to all consumers wrapped by the current one.
@@ -76,6 +82,10 @@ func (tsc *tracesConsumer) ConsumeTraces(ctx context.Context, td ptrace.Traces)
 		td.CopyTo(clonedTraces)
 		errs = multierr.Append(errs, tc.ConsumeTraces(ctx, clonedTraces))
 	}
+	if len(tsc.pass) > 1 {
+		// Shared by the consumers, which must copy it to mutate it.
+		td.MarkReadOnly()
+	}
 	for _, tc := range tsc.pass {
 		errs = multierr.Append(errs, tc.ConsumeTraces(ctx, td))
 	}
@@ -68,6 +68,7 @@ func TestTracesMultiplexingNonMutating(t *testing.T) {
 	assert.True(t, td == p3.AllTraces()[1])
 	assert.EqualValues(t, td, p3.AllTraces()[0])
 	assert.EqualValues(t, td, p3.AllTraces()[1])
+	assert.True(t, td.IsReadOnly())
 }
 
 func TestTracesMultiplexingMutating(t *testing.T) {
@@ -102,6 +103,7 @@ func TestTracesMultiplexingMutating(t *testing.T) {
 	assert.True(t, td == p3.AllTraces()[1])
 	assert.EqualValues(t, td, p3.AllTraces()[0])
 	assert.EqualValues(t, td, p3.AllTraces()[1])
+	assert.False(t, td.IsReadOnly())
 }
 
 func TestTracesMultiplexingMixLastMutating(t *testing.T) {
@@ -173,6 +175,61 @@ func TestTracesMultiplexingMixLastNonMutating(t *testing.T) {
 	assert.EqualValues(t, td, p3.AllTraces()[1])
 }
 
+func TestTracesAcceptsReadOnly(t *testing.T) {
+	acceptsReadOnly, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error { return nil },
+		consumer.WithCapabilities(consumer.Capabilities{MutatesData: true, AcceptsReadOnly: true}))
+	assert.NoError(t, err)
+
+	tfc := NewTraces([]consumer.Traces{new(consumertest.TracesSink), new(consumertest.TracesSink)})
+	assert.False(t, tfc.Capabilities().AcceptsReadOnly)
+
+	tfc = NewTraces([]consumer.Traces{acceptsReadOnly, new(consumertest.TracesSink), acceptsReadOnly})
+	assert.False(t, tfc.Capabilities().AcceptsReadOnly)
+
+	passReadOnly, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error { return nil },
+		consumer.WithCapabilities(consumer.Capabilities{AcceptsReadOnly: true}))
+	assert.NoError(t, err)
+	tfc = NewTraces([]consumer.Traces{passReadOnly, passReadOnly})
+	assert.True(t, tfc.Capabilities().AcceptsReadOnly)
+
+	// The cloned consumers get copies of the data.
+	tfc = NewTraces([]consumer.Traces{&mutatingTracesSink{}, acceptsReadOnly})
+	assert.True(t, tfc.Capabilities().AcceptsReadOnly)
+
+	tfc = NewTraces([]consumer.Traces{acceptsReadOnly, new(consumertest.TracesSink)})
+	assert.False(t, tfc.Capabilities().AcceptsReadOnly)
+
+	tfc = NewTraces([]consumer.Traces{&mutatingTracesSink{}, &mutatingTracesSink{}})
+	assert.False(t, tfc.Capabilities().AcceptsReadOnly)
+}
+
+type hintingTracesSink struct {
+	consumertest.TracesSink
+	items, bytes int
+}
+
+func (hts *hintingTracesSink) PreferredBatchSize() (int, int) {
+	return hts.items, hts.bytes
+}
+
+func TestTracesPreferredBatchSize(t *testing.T) {
+	tfc := NewTraces([]consumer.Traces{new(consumertest.TracesSink), new(consumertest.TracesSink)})
+	items, bytes := tfc.(consumer.BatchHintProvider).PreferredBatchSize()
+	assert.Zero(t, items)
+	assert.Zero(t, bytes)
+
+	// The smallest hint of the consumers is returned for each size.
+	tfc = NewTraces([]consumer.Traces{
+		&hintingTracesSink{items: 100},
+		&mutatingTracesSink{},
+		&hintingTracesSink{items: 500, bytes: 4096},
+		&hintingTracesSink{items: 200, bytes: 1024},
+	})
+	items, bytes = tfc.(consumer.BatchHintProvider).PreferredBatchSize()
+	assert.Equal(t, 100, items)
+	assert.Equal(t, 1024, bytes)
+}
+
 func TestTracesWhenErrors(t *testing.T) {
 	p1 := mutatingErr{Consumer: consumertest.NewErr(errors.New("my error"))}
 	p2 := consumertest.NewErr(errors.New("my error"))